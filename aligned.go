@@ -0,0 +1,52 @@
+package sonic
+
+import "fmt"
+
+// writeAligned writes p to the stream in chunks of streamBufferFrames frames, holding back the
+// rest of the last chunk until it is complete or Flush is called, if WithAlignedChunks is set.
+// It returns the number of bytes of p consumed.
+func (t *Transformer) writeAligned(p []byte) (int, error) {
+	if t.alignBuf == nil {
+		return t.write(p)
+	}
+	if len(p)%t.format.SampleSize() != 0 {
+		return 0, fmt.Errorf("%w: 'p' must be a multiple of the sample size", ErrInvalid)
+	}
+
+	consumed := 0
+	var recovered error // Reported once all of p is written
+	if len(t.alignBuf) > 0 {
+		n := min(len(p), cap(t.alignBuf)-len(t.alignBuf))
+		t.alignBuf = append(t.alignBuf, p[:n]...)
+		consumed = n
+		if len(t.alignBuf) < cap(t.alignBuf) {
+			return consumed, nil
+		}
+		chunk := t.alignBuf
+		t.alignBuf = t.alignBuf[:0]
+		if _, err := t.writeUninterrupted(t.write, chunk); deferRecovered(&recovered, err) != nil {
+			return consumed, err
+		}
+	}
+
+	// Write whole chunks directly, since write splits them into the same chunks.
+	whole := (len(p) - consumed) / cap(t.alignBuf) * cap(t.alignBuf)
+	n, err := t.write(p[consumed : consumed+whole])
+	consumed += n
+	if deferRecovered(&recovered, err) != nil {
+		return consumed, err
+	}
+	t.alignBuf = append(t.alignBuf, p[consumed:]...)
+	return len(p), recovered
+}
+
+// flushAligned writes the input held back by writeAligned.
+func (t *Transformer) flushAligned() error {
+	if len(t.alignBuf) == 0 {
+		return nil
+	}
+	chunk := t.alignBuf
+	t.alignBuf = t.alignBuf[:0]
+	_, err := t.write(chunk)
+	return err
+}
@@ -0,0 +1,91 @@
+package sonic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_AlignedChunks(t *testing.T) {
+	speech := audiotest.Speech()[:2*audiotest.SpeechSampleRate]
+	stereo := make([]int16, 2*len(speech))
+	for i, s := range speech {
+		stereo[2*i], stereo[2*i+1] = s, -s/2
+	}
+
+	tests := []struct {
+		name   string
+		format AudioFormat
+		input  []byte
+		opts   []Option
+	}{
+		{"mono PCM", AudioFormatPCM, pcm.EncodeInt16(nil, speech), []Option{WithSpeed(1.5)}},
+		{"stereo PCM", AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{WithChannels(2), WithSpeed(0.75)}},
+		{"float", AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, int16Scaling)), []Option{WithSpeed(2)}},
+		{"short input pad", AudioFormatPCM, pcm.EncodeInt16(nil, speech), []Option{WithSpeed(1.5), WithShortInput(ShortInputPad)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// transform writes the input in writes of chunk bytes and returns the output.
+			transform := func(chunk int) []byte {
+				var out bytes.Buffer
+				tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, tt.format, append(tt.opts, WithAlignedChunks())...)
+				if err != nil {
+					t.Fatalf("NewTransformer() error = %v", err)
+				}
+				defer tr.Close()
+				for p := tt.input; len(p) > 0; {
+					n := min(len(p), chunk)
+					if m, err := tr.Write(p[:n]); err != nil || m != n {
+						t.Fatalf("Write() = %d, %v, want %d", m, err, n)
+					}
+					p = p[n:]
+				}
+				if err := tr.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+				return out.Bytes()
+			}
+
+			want := transform(len(tt.input))
+			if len(want) == 0 {
+				t.Fatal("no output")
+			}
+			for _, chunk := range []int{4, 88, 1764, 8192, 20000} {
+				if got := transform(chunk); !bytes.Equal(got, want) {
+					t.Errorf("writes of %d bytes: %d output bytes differ from a single write (%d bytes)", chunk, len(got), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestTransformer_AlignedChunksPending(t *testing.T) {
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 44100, AudioFormatPCM, WithAlignedChunks())
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	if _, err := tr.Write(make([]byte, 2*1000)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := tr.InputPending(); got != 1000 {
+		t.Errorf("InputPending() = %d, want 1000", got)
+	}
+	if out.Len() != 0 {
+		t.Errorf("output before a complete chunk = %d bytes, want 0", out.Len())
+	}
+	if _, err := tr.Write([]byte{0}); err != nil { // Carried over, and not flushed
+		t.Fatalf("Write() of an incomplete sample error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := out.Len(); got != 2*1000 {
+		t.Errorf("output after Flush = %d bytes, want %d", got, 2*1000)
+	}
+}
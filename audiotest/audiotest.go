@@ -0,0 +1,61 @@
+// Package audiotest provides a tiny speech corpus embedded in the binary, for tests and examples that should run
+// without external testdata downloads.
+//
+// The corpus is a 16-bit PCM, 48kHz mono recording of about 4.5 seconds of English speech from the
+// Common Voice Corpus 1, which is licensed under CC-0.
+package audiotest
+
+import (
+	_ "embed"
+	"encoding/binary"
+)
+
+// Properties of the embedded speech.
+const (
+	SpeechSampleRate    = 48000
+	SpeechNumChannels   = 1
+	SpeechBitsPerSample = 16
+)
+
+//go:embed testdata/speech.wav
+var speechWAV []byte
+
+// SpeechWAV returns the embedded speech as a complete WAV file.
+//
+// The returned slice is a copy and may be modified by the caller.
+func SpeechWAV() []byte {
+	return append([]byte(nil), speechWAV...)
+}
+
+// SpeechPCM returns the embedded speech as 16-bit little-endian PCM bytes, without the WAV header.
+//
+// The returned slice is a copy and may be modified by the caller.
+// It can be written directly to a sonic.Transformer created with sonic.AudioFormatPCM.
+func SpeechPCM() []byte {
+	return append([]byte(nil), dataChunk(speechWAV)...)
+}
+
+// Speech returns the embedded speech as int16 samples.
+func Speech() []int16 {
+	data := dataChunk(speechWAV)
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return samples
+}
+
+// dataChunk returns the payload of the "data" chunk of the WAV file b.
+func dataChunk(b []byte) []byte {
+	pos := 12 // Skip RIFF header
+	for pos+8 <= len(b) {
+		id := string(b[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		pos += 8
+		if id == "data" {
+			return b[pos:min(pos+size, len(b))]
+		}
+		pos += size + size%2
+	}
+	panic("audiotest: embedded WAV has no data chunk")
+}
@@ -0,0 +1,64 @@
+package audiotest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSpeechWAV(t *testing.T) {
+	wav := SpeechWAV()
+	if !bytes.Equal(wav[0:4], []byte("RIFF")) || !bytes.Equal(wav[8:12], []byte("WAVE")) {
+		t.Fatalf("SpeechWAV() is not a WAV file: header %q", wav[:12])
+	}
+
+	wav[0] = 'X'
+	if SpeechWAV()[0] != 'R' {
+		t.Error("modifying the result of SpeechWAV() changed the embedded corpus")
+	}
+}
+
+func TestSpeechPCM(t *testing.T) {
+	pcm := SpeechPCM()
+	if len(pcm)%2 != 0 {
+		t.Fatalf("len(SpeechPCM()) = %d, want a multiple of 2", len(pcm))
+	}
+	if len(pcm)+44 != len(speechWAV) {
+		t.Errorf("len(SpeechPCM()) = %d, want %d", len(pcm), len(speechWAV)-44)
+	}
+
+	seconds := float64(len(pcm)) / 2 / SpeechSampleRate / SpeechNumChannels
+	if seconds < 1 || 10 < seconds {
+		t.Errorf("speech duration = %.2fs, want a few seconds", seconds)
+	}
+}
+
+func TestSpeech(t *testing.T) {
+	samples := Speech()
+	pcm := SpeechPCM()
+	if len(samples) != len(pcm)/2 {
+		t.Fatalf("len(Speech()) = %d, want %d", len(samples), len(pcm)/2)
+	}
+	peak := 0
+	for _, s := range samples {
+		peak = max(peak, int(s), -int(s))
+	}
+	if peak < 1000 {
+		t.Errorf("speech peak amplitude = %d, want a non-silent recording", peak)
+	}
+}
+
+func TestDataChunk(t *testing.T) {
+	wav := []byte("RIFF\x00\x00\x00\x00WAVE" +
+		"LIST\x03\x00\x00\x00abc\x00" + // Odd-sized chunk with pad byte
+		"data\x04\x00\x00\x00\x01\x02\x03\x04")
+	if got := dataChunk(wav); !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Errorf("dataChunk() = %v, want [1 2 3 4]", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("dataChunk() did not panic for a WAV without data chunk")
+		}
+	}()
+	dataChunk([]byte("RIFF\x00\x00\x00\x00WAVE"))
+}
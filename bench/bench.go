@@ -0,0 +1,221 @@
+// Package bench is a quality harness comparing engines and settings of sonic.Transformer with
+// objective metrics.
+//
+// Measure runs mono float input through a Transformer and compares the output with the input:
+// the log-spectral distance tells how much the spectrum is colored by the processing, and the
+// transient smearing tells how much onsets are blurred. Both metrics compare time-aligned frames
+// and assume that the pitch is preserved, so they are meaningful for speed changes only, not for
+// pitch or rate changes. WriteReport formats the results as a table, e.g. to choose defaults or
+// to validate a new engine against EngineSonic.
+//
+// QualityCosts and WriteQualityReport quantify the CPU cost of sonic.WithQuality across speeds
+// and sample rates, to decide whether quality mode is affordable for realtime use.
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/internal/fft"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+const (
+	frameSize = 1024 // Analysis frame size of the metrics
+	hopSize   = 256  // Analysis hop size of the metrics
+
+	silenceDB = -60 // Frames this far below the loudest input frame are ignored
+)
+
+// Setting is a named set of transformer options to measure.
+type Setting struct {
+	Name    string
+	Options []sonic.Option
+
+	// WriteFrames is the number of frames passed to each Write call, e.g. to measure the effect
+	// of the buffer size of a live source. 0 writes the whole input at once.
+	WriteFrames int
+}
+
+// Result holds the metrics of one Setting.
+type Result struct {
+	Setting string
+
+	// LogSpectralDistance is the root-mean-square difference in dB between the power spectra of
+	// time-aligned input and output frames, averaged over the non-silent frames. Lower is better.
+	LogSpectralDistance float64
+
+	// TransientSmearing is the ratio of the crest factors of the onset strength (spectral flux)
+	// of the input and of the output. 1 means that onsets are as sharp as in the input, higher
+	// values mean smeared onsets.
+	TransientSmearing float64
+
+	// RealtimeFactor is the duration of the input divided by the processing time.
+	RealtimeFactor float64
+}
+
+// DefaultSettings returns settings comparing EngineSonic and EngineMusic at typical speeds.
+func DefaultSettings() []Setting {
+	var settings []Setting
+	for _, e := range []struct {
+		name   string
+		engine sonic.Engine
+	}{
+		{"sonic", sonic.EngineSonic},
+		{"music", sonic.EngineMusic},
+	} {
+		for _, speed := range []float32{0.75, 1.5, 2, 3} {
+			settings = append(settings, Setting{
+				Name:    fmt.Sprintf("%s speed=%v", e.name, speed),
+				Options: []sonic.Option{sonic.WithEngine(e.engine), sonic.WithSpeed(speed)},
+			})
+		}
+	}
+	return settings
+}
+
+// Run measures every setting on input. See Measure.
+func Run(input []float32, sampleRate int, settings []Setting) ([]Result, error) {
+	results := make([]Result, 0, len(settings))
+	for _, s := range settings {
+		r, err := Measure(input, sampleRate, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Measure runs the mono float samples of input at sampleRate through a Transformer with the
+// options of setting and computes the metrics of the output.
+func Measure(input []float32, sampleRate int, setting Setting) (Result, error) {
+	if len(input) < frameSize {
+		return Result{}, errors.New("input is shorter than one analysis frame")
+	}
+
+	var out bytes.Buffer
+	tr, err := sonic.NewTransformer(&out, sampleRate, sonic.AudioFormatIEEEFloat, setting.Options...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tr.Close()
+	start := time.Now()
+	p := pcm.EncodeFloat32(nil, input)
+	chunk := len(p)
+	if setting.WriteFrames > 0 {
+		chunk = setting.WriteFrames * 4
+	}
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		if _, err := tr.Write(p[:n]); err != nil {
+			return Result{}, err
+		}
+		p = p[n:]
+	}
+	if err := tr.Flush(); err != nil {
+		return Result{}, err
+	}
+	elapsed := time.Since(start)
+	output := pcm.DecodeFloat32(nil, out.Bytes())
+	if len(output) < frameSize {
+		return Result{}, errors.New("output is shorter than one analysis frame")
+	}
+
+	in := spectrogram(input)
+	outSpec := spectrogram(output)
+	return Result{
+		Setting:             setting.Name,
+		LogSpectralDistance: logSpectralDistance(in, outSpec),
+		TransientSmearing:   onsetCrest(in) / onsetCrest(outSpec),
+		RealtimeFactor:      float64(len(input)) / float64(sampleRate) / elapsed.Seconds(),
+	}, nil
+}
+
+// WriteReport writes results to w as a table.
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "setting\tLSD [dB]\tsmearing\trealtime\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.0fx\t\n", r.Setting, r.LogSpectralDistance, r.TransientSmearing, r.RealtimeFactor)
+	}
+	return tw.Flush()
+}
+
+// spectrogram returns the power spectra of the Hann-windowed frames of samples.
+func spectrogram(samples []float32) [][]float64 {
+	f := fft.New(frameSize)
+	frame := make([]complex128, frameSize)
+	var spec [][]float64
+	for p := 0; p+frameSize <= len(samples); p += hopSize {
+		for i := range frame {
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/frameSize)
+			frame[i] = complex(w*float64(samples[p+i]), 0)
+		}
+		f.Transform(frame, false)
+		power := make([]float64, frameSize/2+1)
+		for k := range power {
+			a := cmplx.Abs(frame[k])
+			power[k] = a * a
+		}
+		spec = append(spec, power)
+	}
+	return spec
+}
+
+// logSpectralDistance returns the mean log-spectral distance between the frames of out and the
+// frames of in at the same relative position, ignoring silent input frames.
+func logSpectralDistance(in, out [][]float64) float64 {
+	energies := make([]float64, len(in))
+	loudest := 0.0
+	for i, power := range in {
+		for _, p := range power {
+			energies[i] += p
+		}
+		loudest = math.Max(loudest, energies[i])
+	}
+	threshold := loudest * math.Pow(10, silenceDB/10.0)
+
+	sum, n := 0.0, 0
+	for j, power := range out {
+		i := int(math.Round(float64(j) * float64(len(in)-1) / math.Max(float64(len(out)-1), 1)))
+		if energies[i] <= threshold {
+			continue
+		}
+		d := 0.0
+		for k, p := range power {
+			diff := 10 * math.Log10((in[i][k]+1e-12)/(p+1e-12))
+			d += diff * diff
+		}
+		sum += math.Sqrt(d / float64(len(power)))
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// onsetCrest returns the crest factor (peak over mean) of the spectral flux of spec.
+func onsetCrest(spec [][]float64) float64 {
+	peak, sum := 0.0, 0.0
+	for t := 1; t < len(spec); t++ {
+		flux := 0.0
+		for k, p := range spec[t] {
+			flux += math.Max(0, math.Sqrt(p)-math.Sqrt(spec[t-1][k]))
+		}
+		peak = math.Max(peak, flux)
+		sum += flux
+	}
+	if sum == 0 {
+		return 1
+	}
+	return peak / (sum / float64(len(spec)-1))
+}
@@ -0,0 +1,91 @@
+package bench
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestRun(t *testing.T) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech(), pcm.Scaling32767)
+	settings := append([]Setting{
+		{Name: "identity", Options: []sonic.Option{sonic.WithEngine(sonic.EngineMusic)}},
+		{Name: "half volume", Options: []sonic.Option{sonic.WithEngine(sonic.EngineMusic), sonic.WithVolume(0.5)}},
+	}, DefaultSettings()...)
+
+	results, err := Run(speech, audiotest.SpeechSampleRate, settings)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != len(settings) {
+		t.Fatalf("Run() returned %d results, want %d", len(results), len(settings))
+	}
+	for _, r := range results {
+		if math.IsNaN(r.LogSpectralDistance) || math.IsNaN(r.TransientSmearing) || r.RealtimeFactor <= 0 {
+			t.Errorf("%s: invalid result %+v", r.Setting, r)
+		}
+	}
+	if r := results[0]; r.LogSpectralDistance > 1 || math.Abs(r.TransientSmearing-1) > 0.05 {
+		t.Errorf("identity: LSD = %.2f, smearing = %.2f, want about 0 and 1", r.LogSpectralDistance, r.TransientSmearing)
+	}
+	// Halving the amplitude lowers the power of every bin by 6.02 dB.
+	if r := results[1]; math.Abs(r.LogSpectralDistance-6.02) > 0.1 {
+		t.Errorf("half volume: LSD = %.2f, want 6.02", r.LogSpectralDistance)
+	}
+	for _, r := range results[2:] {
+		if r.LogSpectralDistance <= results[0].LogSpectralDistance {
+			t.Errorf("%s: LSD = %.2f, want more than identity (%.2f)", r.Setting, r.LogSpectralDistance, results[0].LogSpectralDistance)
+		}
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, results); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	t.Log("\n" + report.String())
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != len(results)+1 || !strings.Contains(lines[0], "LSD") || !strings.Contains(lines[2], "half volume") {
+		t.Errorf("WriteReport() =\n%s", report.String())
+	}
+}
+
+func TestMeasure_ShortInput(t *testing.T) {
+	if _, err := Measure(make([]float32, 10), 8000, Setting{Name: "short"}); err == nil {
+		t.Error("Measure() error = nil, want an error")
+	}
+}
+
+// TestAlignedChunks is an A/B test of WithAlignedChunks: with small writes, the aligned output
+// matches the output of a single write, and its quality is no worse than without alignment.
+func TestAlignedChunks(t *testing.T) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech(), pcm.Scaling32767)
+	writeFrames := audiotest.SpeechSampleRate / 100 // 10 ms buffers of a live source
+	for _, speed := range []float32{0.75, 2} {
+		opts := []sonic.Option{sonic.WithSpeed(speed)}
+		aligned := append(opts, sonic.WithAlignedChunks())
+		results, err := Run(speech, audiotest.SpeechSampleRate, []Setting{
+			{Name: "single write", Options: opts},
+			{Name: "small writes", Options: opts, WriteFrames: writeFrames},
+			{Name: "small writes aligned", Options: aligned, WriteFrames: writeFrames},
+		})
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		var report bytes.Buffer
+		WriteReport(&report, results)
+		t.Logf("speed %v:\n%s", speed, report.String())
+
+		single, small, alignedSmall := results[0], results[1], results[2]
+		if alignedSmall.LogSpectralDistance != single.LogSpectralDistance || alignedSmall.TransientSmearing != single.TransientSmearing {
+			t.Errorf("speed %v: aligned small writes differ from a single write: %+v, %+v", speed, alignedSmall, single)
+		}
+		if alignedSmall.LogSpectralDistance > small.LogSpectralDistance+0.1 {
+			t.Errorf("speed %v: aligned LSD = %.2f, unaligned %.2f", speed, alignedSmall.LogSpectralDistance, small.LogSpectralDistance)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// qualityRuns is the number of times each configuration is processed by QualityCosts. The
+// fastest run is kept, which is the most stable estimate of the cost on a busy machine.
+const qualityRuns = 5
+
+// QualityCost holds the processing cost of one speed and sample rate without and with
+// sonic.WithQuality.
+type QualityCost struct {
+	Speed      float32
+	SampleRate int
+
+	// Default and Quality are the processing times per second of input without and with
+	// WithQuality.
+	Default time.Duration
+	Quality time.Duration
+}
+
+// Ratio returns how many times more CPU time WithQuality takes.
+func (c QualityCost) Ratio() float64 {
+	return float64(c.Quality) / float64(c.Default)
+}
+
+// RealtimeFactor returns the duration of the input divided by the processing time with
+// WithQuality. Below 1, quality mode cannot keep up with a live source on this machine.
+func (c QualityCost) RealtimeFactor() float64 {
+	return float64(time.Second) / float64(c.Quality)
+}
+
+// DefaultQualitySpeeds returns the speeds measured by QualityCosts if none are given.
+func DefaultQualitySpeeds() []float32 {
+	return []float32{0.5, 1.5, 2, 3}
+}
+
+// DefaultQualitySampleRates returns the sample rates measured by QualityCosts if none are given.
+func DefaultQualitySampleRates() []int {
+	return []int{8000, 16000, 44100, 48000}
+}
+
+// QualityCosts measures the CPU cost of sonic.WithQuality, which disables the speed-up
+// heuristics of the pitch period search, for every combination of speeds and sampleRates. The
+// mono float samples of input at inputRate are resampled to each sample rate, since the cost of
+// the period search grows with the sample rate. nil speeds or sampleRates select
+// DefaultQualitySpeeds or DefaultQualitySampleRates.
+//
+// Each configuration is processed several times and the fastest run is kept. The times are still
+// wall-clock times of this machine, so compare the ratios rather than the absolute values across
+// machines.
+func QualityCosts(input []float32, inputRate int, speeds []float32, sampleRates []int) ([]QualityCost, error) {
+	if speeds == nil {
+		speeds = DefaultQualitySpeeds()
+	}
+	if sampleRates == nil {
+		sampleRates = DefaultQualitySampleRates()
+	}
+	var costs []QualityCost
+	for _, rate := range sampleRates {
+		samples := resample(input, inputRate, rate)
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("sample rate %d: input is empty", rate)
+		}
+		p := pcm.EncodeFloat32(nil, samples)
+		duration := time.Duration(len(samples)) * time.Second / time.Duration(rate)
+		for _, speed := range speeds {
+			c := QualityCost{Speed: speed, SampleRate: rate}
+			for _, quality := range []bool{false, true} {
+				opts := []sonic.Option{sonic.WithSpeed(speed)}
+				if quality {
+					opts = append(opts, sonic.WithQuality())
+				}
+				elapsed, err := processTime(p, rate, opts)
+				if err != nil {
+					return nil, fmt.Errorf("sample rate %d, speed %v: %w", rate, speed, err)
+				}
+				perSecond := elapsed * time.Second / duration
+				if quality {
+					c.Quality = perSecond
+				} else {
+					c.Default = perSecond
+				}
+			}
+			costs = append(costs, c)
+		}
+	}
+	return costs, nil
+}
+
+// processTime returns the shortest time of qualityRuns runs of process.
+func processTime(p []byte, sampleRate int, opts []sonic.Option) (time.Duration, error) {
+	best := time.Duration(-1)
+	for range qualityRuns {
+		elapsed, err := process(p, sampleRate, opts)
+		if err != nil {
+			return 0, err
+		}
+		if best < 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	// A zero duration would make the ratios meaningless on coarse clocks.
+	return max(best, time.Nanosecond), nil
+}
+
+// process returns the time to write the float input p at sampleRate to a new Transformer with
+// opts and flush it, excluding its creation.
+func process(p []byte, sampleRate int, opts []sonic.Option) (time.Duration, error) {
+	tr, err := sonic.NewTransformer(io.Discard, sampleRate, sonic.AudioFormatIEEEFloat, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer tr.Close()
+	start := time.Now()
+	if _, err := tr.Write(p); err != nil {
+		return 0, err
+	}
+	if err := tr.Flush(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// resample converts the mono samples of input from the rate from to the rate to with linear
+// interpolation. It is good enough to give the input a realistic spectrum at every rate, not for
+// listening.
+func resample(input []float32, from, to int) []float32 {
+	if from == to || len(input) == 0 {
+		return input
+	}
+	out := make([]float32, int(int64(len(input))*int64(to)/int64(from)))
+	for i := range out {
+		pos := float64(i) * float64(from) / float64(to)
+		j := int(pos)
+		if j+1 >= len(input) {
+			out[i] = input[len(input)-1]
+			continue
+		}
+		f := float32(pos - float64(j))
+		out[i] = (1-f)*input[j] + f*input[j+1]
+	}
+	return out
+}
+
+// WriteQualityReport writes costs to w as a table: the processing time per second of input
+// without and with WithQuality, the ratio of the two, and the realtime factor with WithQuality.
+func WriteQualityReport(w io.Writer, costs []QualityCost) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "rate\tspeed\tdefault\tquality\tratio\trealtime\t")
+	for _, c := range costs {
+		fmt.Fprintf(tw, "%d\t%v\t%v\t%v\t%.1fx\t%.0fx\t\n", c.SampleRate, c.Speed, c.Default.Round(time.Microsecond), c.Quality.Round(time.Microsecond), c.Ratio(), c.RealtimeFactor())
+	}
+	return tw.Flush()
+}
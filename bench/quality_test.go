@@ -0,0 +1,106 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestQualityCosts(t *testing.T) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech(), pcm.Scaling32767)
+	speeds := []float32{0.5, 2}
+	rates := []int{8000, 48000}
+	costs, err := QualityCosts(speech[:audiotest.SpeechSampleRate], audiotest.SpeechSampleRate, speeds, rates)
+	if err != nil {
+		t.Fatalf("QualityCosts() error = %v", err)
+	}
+	if len(costs) != len(speeds)*len(rates) {
+		t.Fatalf("QualityCosts() returned %d costs, want %d", len(costs), len(speeds)*len(rates))
+	}
+	for i, c := range costs {
+		if want := (QualityCost{Speed: speeds[i%2], SampleRate: rates[i/2]}); c.Speed != want.Speed || c.SampleRate != want.SampleRate {
+			t.Errorf("cost %d is of speed %v at %d Hz, want speed %v at %d Hz", i, c.Speed, c.SampleRate, want.Speed, want.SampleRate)
+		}
+		if c.Default <= 0 || c.Quality <= 0 {
+			t.Errorf("speed %v at %d Hz: Default = %v, Quality = %v, want positive", c.Speed, c.SampleRate, c.Default, c.Quality)
+		}
+	}
+	// Disabling the heuristics searches every period at the full rate, which is much slower at
+	// 48 kHz. The margin keeps the test stable on a loaded machine.
+	for _, c := range costs[2:] {
+		if c.Ratio() < 1.5 {
+			t.Errorf("speed %v at %d Hz: Ratio() = %.2f, want WithQuality to be slower", c.Speed, c.SampleRate, c.Ratio())
+		}
+	}
+
+	var report bytes.Buffer
+	if err := WriteQualityReport(&report, costs); err != nil {
+		t.Fatalf("WriteQualityReport() error = %v", err)
+	}
+	t.Log("\n" + report.String())
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != len(costs)+1 || !strings.Contains(lines[0], "ratio") || !strings.Contains(lines[4], "48000") {
+		t.Errorf("WriteQualityReport() =\n%s", report.String())
+	}
+}
+
+func TestQualityCosts_Errors(t *testing.T) {
+	if _, err := QualityCosts(nil, 8000, nil, nil); err == nil {
+		t.Error("QualityCosts() of empty input error = nil, want an error")
+	}
+	if _, err := QualityCosts(make([]float32, 8000), 8000, nil, []int{100}); err == nil {
+		t.Error("QualityCosts() at 100 Hz error = nil, want an error")
+	}
+}
+
+func TestQualityCost(t *testing.T) {
+	c := QualityCost{Default: time.Millisecond, Quality: 4 * time.Millisecond}
+	if c.Ratio() != 4 {
+		t.Errorf("Ratio() = %v, want 4", c.Ratio())
+	}
+	if c.RealtimeFactor() != 250 {
+		t.Errorf("RealtimeFactor() = %v, want 250", c.RealtimeFactor())
+	}
+}
+
+func TestResample(t *testing.T) {
+	input := []float32{0, 1, 2, 3}
+	if got := resample(input, 8000, 16000); len(got) != 8 || got[1] != 0.5 || got[7] != 3 {
+		t.Errorf("resample() up = %v", got)
+	}
+	if got := resample(input, 16000, 8000); len(got) != 2 || got[1] != 2 {
+		t.Errorf("resample() down = %v", got)
+	}
+}
+
+// BenchmarkQuality compares the CPU cost of the default heuristics of the pitch period search
+// with WithQuality across speeds and sample rates, on a second of the embedded speech. Compare
+// the quality=0 and quality=1 results with benchstat, or run QualityCosts for a summary table.
+func BenchmarkQuality(b *testing.B) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech(), pcm.Scaling32767)[:audiotest.SpeechSampleRate]
+	for _, rate := range DefaultQualitySampleRates() {
+		p := pcm.EncodeFloat32(nil, resample(speech, audiotest.SpeechSampleRate, rate))
+		for _, speed := range DefaultQualitySpeeds() {
+			for quality := range 2 {
+				opts := []sonic.Option{sonic.WithSpeed(speed)}
+				if quality == 1 {
+					opts = append(opts, sonic.WithQuality())
+				}
+				b.Run(fmt.Sprintf("rate=%d/speed=%v/quality=%d", rate, speed, quality), func(b *testing.B) {
+					b.SetBytes(int64(len(p)))
+					for b.Loop() {
+						if _, err := process(p, rate, opts); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package sonic
+
+import "github.com/nakat-t/sonic-go/internal/cgosonic"
+
+// stretchLimits returns the range of the time-stretch factor, the speed divided by the pitch,
+// that the stream can apply at sampleRate.
+//
+// libsonic changes the speed by inserting or skipping a fraction of each pitch period, and
+// resamples for the pitch. The fraction is rounded down to whole frames, and if it rounds to
+// nothing, the stream stops producing output while its input buffer keeps growing. The shortest
+// period it detects is sampleRate/MAX_PITCH frames, which leaves at least one frame for factors
+// in [1/minPeriod, minPeriod]. At 8000 Hz and above, this is the whole speed range; at 1000 Hz it
+// is [0.5, 2].
+func stretchLimits(sampleRate int) (lo, hi float32) {
+	minPeriod := float32(sampleRate / cgosonic.MAX_PITCH)
+	lo, hi = cgosonic.MIN_SPEED, cgosonic.MAX_SPEED
+	if 1/minPeriod > lo {
+		lo = 1 / minPeriod
+	}
+	if minPeriod < hi {
+		hi = minPeriod
+	}
+	return lo, hi
+}
+
+// streamPitch returns the pitch to set on the stream together with speed. It is the configured
+// pitch, unless the time-stretch factor would exceed stretchLimits, e.g. at speed 20 and pitch
+// 0.05. Then the pitch is moved towards the speed to stay within the limits, which keeps the
+// length of the output, but not the pitch, as configured. Other engines than EngineSonic get the
+// configured pitch.
+func (t *Transformer) streamPitch(speed float32) float32 {
+	pitch := float32(1)
+	if t.pitch != nil {
+		pitch = *t.pitch
+	}
+	if t.engine != EngineSonic {
+		return pitch
+	}
+	lo, hi := stretchLimits(t.sampleRate)
+	switch {
+	case speed/pitch < lo:
+		return speed / lo
+	case speed/pitch > hi:
+		return speed / hi
+	}
+	return pitch
+}
@@ -0,0 +1,107 @@
+package sonic
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestStretchLimits(t *testing.T) {
+	tests := []struct {
+		sampleRate int
+		lo, hi     float32
+	}{
+		{1000, 0.5, 2},
+		{2000, 0.2, 5},
+		{8000, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED},
+		{48000, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED},
+	}
+	for _, tt := range tests {
+		lo, hi := stretchLimits(tt.sampleRate)
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("stretchLimits(%d) = %v, %v, want %v, %v", tt.sampleRate, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+// TestTransformer_Bounds runs the transformer at the bounds of speed, pitch and rate, and checks
+// that the output keeps up with the input and has about the length of OutputSamplesForInput.
+// libsonic works in whole pitch periods, which makes the length less accurate at extreme
+// settings, especially at low sample rates.
+func TestTransformer_Bounds(t *testing.T) {
+	settings := []struct {
+		name string
+		opts []Option
+	}{
+		{"speed min", []Option{WithSpeed(cgosonic.MIN_SPEED)}},
+		{"speed max", []Option{WithSpeed(cgosonic.MAX_SPEED)}},
+		{"pitch min", []Option{WithPitch(cgosonic.MIN_PITCH_SETTING)}},
+		{"pitch max", []Option{WithPitch(cgosonic.MAX_PITCH_SETTING)}},
+		{"rate min", []Option{WithRate(cgosonic.MIN_RATE)}},
+		{"rate max", []Option{WithRate(cgosonic.MAX_RATE)}},
+		{"speed max pitch min", []Option{WithSpeed(cgosonic.MAX_SPEED), WithPitch(cgosonic.MIN_PITCH_SETTING)}},
+		{"speed min pitch max", []Option{WithSpeed(cgosonic.MIN_SPEED), WithPitch(cgosonic.MAX_PITCH_SETTING)}},
+		{"pitch min rate max", []Option{WithPitch(cgosonic.MIN_PITCH_SETTING), WithRate(cgosonic.MAX_RATE)}},
+		{"all min", []Option{WithSpeed(cgosonic.MIN_SPEED), WithPitch(cgosonic.MIN_PITCH_SETTING), WithRate(cgosonic.MIN_RATE), WithQuality()}},
+		{"all max", []Option{WithSpeed(cgosonic.MAX_SPEED), WithPitch(cgosonic.MAX_PITCH_SETTING), WithRate(cgosonic.MAX_RATE), WithQuality()}},
+	}
+	sampleRates := []struct {
+		sampleRate int
+		tolerance  float64 // Relative tolerance of the output length
+	}{
+		{1000, 0.25},
+		{8000, 0.25},
+		{48000, 0.1},
+	}
+	const slack = 10 // Output frames allowed beyond the tolerance, for very short output
+
+	for _, s := range settings {
+		for _, sr := range sampleRates {
+			for _, block := range []int{7, 4096} {
+				t.Run(fmt.Sprintf("%s/%dHz/block %d", s.name, sr.sampleRate, block), func(t *testing.T) {
+					input := pcm.EncodeInt16(nil, audiotest.Speech()[:sr.sampleRate])
+					frames := 0
+					opts := append(slices.Clone(s.opts), WithOutputFunc(func(p []byte) error {
+						frames += len(p) / 2
+						return nil
+					}))
+					tr, err := NewTransformer(nil, sr.sampleRate, AudioFormatPCM, opts...)
+					if err != nil {
+						t.Fatalf("NewTransformer() error = %v", err)
+					}
+					defer tr.Close()
+					check := func(what string, got, want int, tolerance float64) {
+						t.Helper()
+						if math.Abs(float64(got-want)) > tolerance*float64(want)+slack {
+							t.Errorf("%s: %d output frames, want %d", what, got, want)
+						}
+					}
+
+					total := 0
+					for i, half := range [][]byte{input[:len(input)/2], input[len(input)/2:]} {
+						for chunk := range slices.Chunk(half, 2*block) {
+							if _, err := tr.Write(chunk); err != nil {
+								t.Fatalf("Write() error = %v", err)
+							}
+						}
+						if i == 0 {
+							total, frames = frames, 0
+						}
+					}
+					// A stream that stalls stops producing output and accumulates its input.
+					check("second half of the input", frames, tr.OutputSamplesForInput(len(input)/4), 0.5)
+
+					if err := tr.Flush(); err != nil {
+						t.Fatalf("Flush() error = %v", err)
+					}
+					check("whole input", total+frames, tr.OutputSamplesForInput(len(input)/2), sr.tolerance)
+				})
+			}
+		}
+	}
+}
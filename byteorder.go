@@ -0,0 +1,72 @@
+package sonic
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// isBigEndian reports whether order puts the most significant byte first.
+func isBigEndian(order binary.ByteOrder) bool {
+	var b [2]byte
+	order.PutUint16(b[:], 0x0102)
+	return b[0] == 0x01
+}
+
+// reverseSampleBytes copies the samples of src, of size bytes each, to dst with their bytes
+// reversed, which converts them between little-endian and big-endian, and returns the copy.
+// dst must be at least as long as src.
+func reverseSampleBytes(dst, src []byte, size int) []byte {
+	dst = dst[:len(src)]
+	switch size {
+	case 2:
+		for i := 0; i+1 < len(src); i += 2 {
+			dst[i], dst[i+1] = src[i+1], src[i]
+		}
+	case 3:
+		for i := 0; i+2 < len(src); i += 3 {
+			dst[i], dst[i+1], dst[i+2] = src[i+2], src[i+1], src[i]
+		}
+	case 4:
+		for i := 0; i+3 < len(src); i += 4 {
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = src[i+3], src[i+2], src[i+1], src[i]
+		}
+	case 8:
+		for i := 0; i+7 < len(src); i += 8 {
+			dst[i], dst[i+1], dst[i+2], dst[i+3], dst[i+4], dst[i+5], dst[i+6], dst[i+7] =
+				src[i+7], src[i+6], src[i+5], src[i+4], src[i+3], src[i+2], src[i+1], src[i]
+		}
+	default:
+		copy(dst, src)
+	}
+	return dst
+}
+
+// writeBigEndian converts big-endian input to little-endian in chunks of the stream buffer, which
+// give the same output as the whole input, and writes them. See writeInput.
+func (t *Transformer) writeBigEndian(p []byte) (int, error) {
+	sampleSize := t.format.SampleSize()
+	if len(p)%sampleSize != 0 {
+		return 0, fmt.Errorf("%w: 'p' must be a multiple of the sample size", ErrInvalid)
+	}
+	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
+	for len(p) > 0 {
+		chunk := reverseSampleBytes(t.orderIn, p[:min(len(p), len(t.orderIn))], sampleSize)
+		n, err := t.writeLittleEndian(chunk)
+		numWrittenBytes += n
+		if deferRecovered(&recovered, err) != nil {
+			return numWrittenBytes, err
+		}
+		p = p[len(chunk):]
+	}
+	return numWrittenBytes, recovered
+}
+
+// bigEndianOutput returns a big-endian copy of the little-endian output p, valid until the next
+// call.
+func (t *Transformer) bigEndianOutput(p []byte) []byte {
+	if cap(t.orderOut) < len(p) {
+		t.orderOut = make([]byte, len(p))
+	}
+	return reverseSampleBytes(t.orderOut, p, t.outFormat.SampleSize())
+}
@@ -0,0 +1,104 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestReverseSampleBytes(t *testing.T) {
+	src := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	tests := []struct {
+		size int
+		want []byte
+	}{
+		{1, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{2, []byte{2, 1, 4, 3, 6, 5, 8, 7}},
+		{4, []byte{4, 3, 2, 1, 8, 7, 6, 5}},
+	}
+	for _, tt := range tests {
+		got := reverseSampleBytes(make([]byte, 10), src, tt.size)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("reverseSampleBytes(size %d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestTransformer_ByteOrder(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	stereo := make([]int16, 2*len(speech))
+	for i, v := range speech {
+		stereo[2*i], stereo[2*i+1] = v, v/2
+	}
+	float := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, stereo, int16Scaling))
+	u8 := pcm.Int16ToUint8(nil, speech)
+	const rate = audiotest.SpeechSampleRate
+
+	tests := []struct {
+		name       string
+		sampleRate int
+		format     AudioFormat
+		outFormat  AudioFormat
+		input      []byte
+		opts       []Option
+	}{
+		{"PCM", rate, AudioFormatPCM, AudioFormatPCM, pcm.EncodeInt16(nil, speech), []Option{WithSpeed(1.5)}},
+		{"float stereo", rate, AudioFormatIEEEFloat, AudioFormatIEEEFloat, float, []Option{WithChannels(2), WithSpeed(0.8), WithPitch(1.2)}},
+		{"PCM to float", rate, AudioFormatPCM, AudioFormatIEEEFloat, pcm.EncodeInt16(nil, speech), []Option{WithSpeed(2), WithOutputFormat(AudioFormatIEEEFloat)}},
+		{"float to U8", rate, AudioFormatIEEEFloat, AudioFormatU8, float, []Option{WithChannels(2), WithSpeed(2), WithOutputFormat(AudioFormatU8)}},
+		{"PCM24 stereo", rate, AudioFormatPCM24, AudioFormatPCM24, pcm.Int16ToInt24(nil, stereo), []Option{WithChannels(2), WithSpeed(1.5), WithFadeOut(20 * time.Millisecond)}},
+		{"PCM24 resampled", 800, AudioFormatPCM24, AudioFormatPCM, pcm.Int16ToInt24(nil, speech), []Option{WithSampleRatePolicy(SampleRateResample, nil), WithSpeed(1.5), WithOutputFormat(AudioFormatPCM)}},
+		{"PCM32 to float64", rate, AudioFormatPCM32, AudioFormatIEEEFloat64, pcm.Int16ToInt32(nil, stereo), []Option{WithChannels(2), WithSpeed(0.8), WithOutputFormat(AudioFormatIEEEFloat64)}},
+		{"float64 resampled", 800, AudioFormatIEEEFloat64, AudioFormatIEEEFloat64, pcm.Float32ToFloat64(nil, pcm.Int16ToFloat32(nil, speech, int16Scaling)), []Option{WithSampleRatePolicy(SampleRateResample, nil), WithSpeed(1.5), WithFadeIn(20 * time.Millisecond)}},
+		{"U8 to PCM", rate, AudioFormatU8, AudioFormatPCM, u8, []Option{WithSpeed(2), WithOutputFormat(AudioFormatPCM)}},
+		{"fades and latency", rate, AudioFormatPCM, AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{
+			WithChannels(2), WithSpeed(1.5), WithEdgeFades(20*time.Millisecond, 20*time.Millisecond), WithFixedLatency(50 * time.Millisecond),
+		}},
+		{"resampled", 800, AudioFormatPCM, AudioFormatPCM, pcm.EncodeInt16(nil, speech), []Option{WithSampleRatePolicy(SampleRateResample, nil), WithSpeed(1.5)}},
+	}
+	for _, tt := range tests {
+		// transform writes input in chunks of chunkSize bytes.
+		transform := func(t *testing.T, input []byte, chunkSize int, opts ...Option) ([]byte, *Transformer) {
+			t.Helper()
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, tt.sampleRate, tt.format, append(tt.opts[:len(tt.opts):len(tt.opts)], opts...)...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			t.Cleanup(func() { tr.Close() })
+			for p := input; len(p) > 0; {
+				n := min(len(p), chunkSize)
+				if _, err := tr.Write(p[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				p = p[n:]
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			return out.Bytes(), tr
+		}
+		for _, chunkSize := range []int{len(tt.input), 3000} {
+			t.Run(fmt.Sprintf("%s/chunk=%d", tt.name, chunkSize), func(t *testing.T) {
+				want, little := transform(t, tt.input, chunkSize)
+				want = reverseSampleBytes(make([]byte, len(want)), want, tt.outFormat.SampleSize())
+
+				// Big-endian input gives the big-endian output of the same samples.
+				input := reverseSampleBytes(make([]byte, len(tt.input)), tt.input, tt.format.SampleSize())
+				got, big := transform(t, input, chunkSize, WithByteOrder(binary.BigEndian))
+				if len(got) == 0 || !bytes.Equal(got, want) {
+					t.Errorf("big-endian output (%d bytes) differs from the little-endian output (%d bytes) with its bytes reversed", len(got), len(want))
+				}
+				if little.Fingerprint() == big.Fingerprint() {
+					t.Errorf("Fingerprint() is the same for both byte orders")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sonic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// CacheStore is the byte store backend of a Cache, e.g. a blob store or a local directory.
+// Implementations must be safe for concurrent use if the Cache is.
+type CacheStore interface {
+	// Get returns the value stored for key. ok is false if there is none.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Put stores value for key.
+	Put(ctx context.Context, key string, value []byte) error
+}
+
+// Cache returns transformed outputs from a store and processes only inputs it has not seen with
+// the same parameters, e.g. for a podcast platform serving the same episodes at popular speeds.
+//
+// Outputs are keyed by a key of the input given by the caller, e.g. a hash of its content or
+// a versioned ID, together with the Fingerprint of the transformer. A Cache is safe for
+// concurrent use if its store is; concurrent misses of the same key process the input twice.
+type Cache struct {
+	store CacheStore
+}
+
+// NewCache creates a cache backed by store.
+func NewCache(store CacheStore) *Cache {
+	return &Cache{store: store}
+}
+
+// CacheKey returns the key of the output for inputKey transformed by a transformer with
+// fingerprint, as used by Cache. It is inputKey and the fingerprint joined by a slash, so that
+// stores can list the outputs of an input by prefix.
+func CacheKey(inputKey, fingerprint string) string {
+	return inputKey + "/" + fingerprint
+}
+
+// Transform returns the output of a transformer created with sampleRate, format and opts for the
+// input read from r, which inputKey identifies. If the store has the output, r is not read, and
+// hit is true. Otherwise the input is copied to the transformer as by CopyContext, and the
+// output is stored and returned.
+//
+// WithOutputFunc is overridden, since the cache collects the output itself. Errors of the store
+// wrap ErrCacheStore; if storing the output fails, the output is returned with the error.
+func (c *Cache) Transform(ctx context.Context, inputKey string, r io.Reader, sampleRate int, format AudioFormat, opts ...Option) (out []byte, hit bool, err error) {
+	var buf bytes.Buffer
+	opts = append(opts[:len(opts):len(opts)], WithOutputFunc(func(p []byte) error {
+		buf.Write(p)
+		return nil
+	}))
+	t, err := NewTransformer(nil, sampleRate, format, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer t.Close()
+
+	key := CacheKey(inputKey, t.Fingerprint())
+	out, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: failed to get %s: %w", ErrCacheStore, key, err)
+	}
+	if ok {
+		return out, true, nil
+	}
+
+	if _, err := CopyContext(ctx, t, r); err != nil {
+		return nil, false, err
+	}
+	out = buf.Bytes()
+	if err := c.store.Put(ctx, key, out); err != nil {
+		return out, false, fmt.Errorf("%w: failed to put %s: %w", ErrCacheStore, key, err)
+	}
+	return out, false, nil
+}
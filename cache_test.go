@@ -0,0 +1,137 @@
+package sonic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// mapStore is a CacheStore in memory.
+type mapStore struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	getErr  error
+	putErr  error
+	puts    int
+	lastGet string
+}
+
+func (s *mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastGet = key
+	if s.getErr != nil {
+		return nil, false, s.getErr
+	}
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *mapStore) Put(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.putErr != nil {
+		return s.putErr
+	}
+	if s.values == nil {
+		s.values = make(map[string][]byte)
+	}
+	s.values[key] = bytes.Clone(value)
+	s.puts++
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate])
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := CopyContext(t.Context(), tr, bytes.NewReader(input)); err != nil {
+		t.Fatalf("CopyContext() error = %v", err)
+	}
+
+	store := &mapStore{}
+	c := NewCache(store)
+	transform := func(r io.Reader, opts ...Option) ([]byte, bool) {
+		t.Helper()
+		out, hit, err := c.Transform(t.Context(), "episode-1", r, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+		if err != nil {
+			t.Fatalf("Transform() error = %v", err)
+		}
+		return out, hit
+	}
+
+	out, hit := transform(bytes.NewReader(input), WithSpeed(1.5))
+	if hit || !bytes.Equal(out, want.Bytes()) {
+		t.Errorf("Transform() = %d bytes, hit %v, want the %d bytes of the transformer, miss", len(out), hit, want.Len())
+	}
+	if want := CacheKey("episode-1", tr.Fingerprint()); store.lastGet != want {
+		t.Errorf("Transform() used key %q, want %q", store.lastGet, want)
+	}
+
+	// The input is not read on a hit.
+	out, hit = transform(iotest.ErrReader(errors.New("input read on a hit")), WithSpeed(1.5))
+	if !hit || !bytes.Equal(out, want.Bytes()) {
+		t.Errorf("Transform() = %d bytes, hit %v, want the cached %d bytes, hit", len(out), hit, want.Len())
+	}
+
+	// Other parameters miss.
+	if _, hit := transform(bytes.NewReader(input), WithSpeed(2)); hit {
+		t.Error("Transform() hit with another speed")
+	}
+	if store.puts != 2 || len(store.values) != 2 {
+		t.Errorf("store has %d values after %d puts, want 2 each", len(store.values), store.puts)
+	}
+}
+
+func TestCache_Errors(t *testing.T) {
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:4800])
+	errStore := errors.New("bucket unavailable")
+
+	t.Run("get", func(t *testing.T) {
+		c := NewCache(&mapStore{getErr: errStore})
+		_, _, err := c.Transform(t.Context(), "x", bytes.NewReader(input), 48000, AudioFormatPCM)
+		if !errors.Is(err, ErrCacheStore) || !errors.Is(err, errStore) {
+			t.Errorf("Transform() error = %v, want %v and %v", err, ErrCacheStore, errStore)
+		}
+	})
+	t.Run("put", func(t *testing.T) {
+		c := NewCache(&mapStore{putErr: errStore})
+		out, hit, err := c.Transform(t.Context(), "x", bytes.NewReader(input), 48000, AudioFormatPCM, WithSpeed(1.5))
+		if !errors.Is(err, ErrCacheStore) || !errors.Is(err, errStore) {
+			t.Errorf("Transform() error = %v, want %v and %v", err, ErrCacheStore, errStore)
+		}
+		if hit || len(out) == 0 {
+			t.Errorf("Transform() = %d bytes, hit %v, want the output, miss", len(out), hit)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		store := &mapStore{}
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, _, err := NewCache(store).Transform(ctx, "x", bytes.NewReader(input), 48000, AudioFormatPCM)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Transform() error = %v, want %v", err, context.Canceled)
+		}
+		if store.puts != 0 {
+			t.Error("Transform() stored the output of a canceled copy")
+		}
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		store := &mapStore{}
+		_, _, err := NewCache(store).Transform(t.Context(), "x", bytes.NewReader(input), 48000, AudioFormatPCM, WithChannelGains([]float32{1, 1}))
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("Transform() error = %v, want %v", err, ErrInvalid)
+		}
+	})
+}
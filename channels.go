@@ -0,0 +1,122 @@
+package sonic
+
+// selectChannels copies the given channels of the interleaved src frames into dst and returns the filled part of dst.
+// dst must be large enough to hold len(src)/numChannels*len(channels) samples.
+func selectChannels[T Sample](dst, src []T, numChannels int, channels []int) []T {
+	n := 0
+	for i := 0; i+numChannels <= len(src); i += numChannels {
+		for _, ch := range channels {
+			dst[n] = src[i+ch]
+			n++
+		}
+	}
+	return dst[:n]
+}
+
+// remixChannels converts the interleaved frames of src from inChannels to outChannels into dst
+// and returns the filled part of dst: all channels are averaged down to mono, and mono is
+// duplicated up to every channel. dst must be large enough to hold len(src)/inChannels*outChannels
+// samples, and must not overlap src.
+func remixChannels[T Sample](dst, src []T, inChannels, outChannels int) []T {
+	numFrames := len(src) / inChannels
+	dst = dst[:numFrames*outChannels]
+	switch {
+	case inChannels == 1:
+		for i, s := range src[:numFrames] {
+			for ch := range outChannels {
+				dst[i*outChannels+ch] = s
+			}
+		}
+	case outChannels == 1:
+		for i := range dst {
+			var sum float32
+			for _, s := range src[i*inChannels : (i+1)*inChannels] {
+				sum += float32(s)
+			}
+			dst[i] = T(sum / float32(inChannels))
+		}
+	default:
+		copy(dst, src)
+	}
+	return dst
+}
+
+// Sample is the constraint for the sample types supported by the transformer.
+type Sample interface {
+	int16 | float32
+}
+
+// Interleave interleaves the planar channel buffers planes into frames, writing
+// planes[0][i], planes[1][i], ... for every frame i, and returns the interleaved samples.
+// The number of frames is the length of the shortest plane. dst is reused if it has enough
+// capacity, otherwise a new slice is allocated.
+func Interleave[T Sample](dst []T, planes ...[]T) []T {
+	numChannels := len(planes)
+	if numChannels == 0 {
+		return dst[:0]
+	}
+	numFrames := len(planes[0])
+	for _, p := range planes[1:] {
+		numFrames = min(numFrames, len(p))
+	}
+	n := numFrames * numChannels
+	if cap(dst) < n {
+		dst = make([]T, n)
+	}
+	dst = dst[:n]
+
+	// Mono and stereo are by far the most common layouts; give them simple loops the compiler
+	// can keep free of bounds checks.
+	switch numChannels {
+	case 1:
+		copy(dst, planes[0])
+	case 2:
+		l, r := planes[0][:numFrames], planes[1][:numFrames]
+		for i := range l {
+			dst[2*i] = l[i]
+			dst[2*i+1] = r[i]
+		}
+	default:
+		for ch, p := range planes {
+			for i, s := range p[:numFrames] {
+				dst[i*numChannels+ch] = s
+			}
+		}
+	}
+	return dst
+}
+
+// Deinterleave splits the interleaved frames of src into len(planes) planar channel buffers and
+// returns planes. Each plane is reused if it has enough capacity, otherwise a new slice is
+// allocated. A trailing incomplete frame of src is ignored.
+func Deinterleave[T Sample](planes [][]T, src []T) [][]T {
+	numChannels := len(planes)
+	if numChannels == 0 {
+		return planes
+	}
+	numFrames := len(src) / numChannels
+	for ch, p := range planes {
+		if cap(p) < numFrames {
+			p = make([]T, numFrames)
+		}
+		planes[ch] = p[:numFrames]
+	}
+
+	switch numChannels {
+	case 1:
+		copy(planes[0], src)
+	case 2:
+		l, r := planes[0], planes[1]
+		for i := range l {
+			l[i] = src[2*i]
+			r[i] = src[2*i+1]
+		}
+	default:
+		for ch, p := range planes {
+			for i := range p {
+				p[i] = src[i*numChannels+ch]
+			}
+		}
+	}
+	return planes
+}
@@ -0,0 +1,291 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestSelectChannels(t *testing.T) {
+	src := []int16{1, 2, 3, 4, 5, 6}
+	tests := []struct {
+		name        string
+		numChannels int
+		channels    []int
+		expected    []int16
+	}{
+		{"first of stereo", 2, []int{0}, []int16{1, 3, 5}},
+		{"second of stereo", 2, []int{1}, []int16{2, 4, 6}},
+		{"swap stereo", 2, []int{1, 0}, []int16{2, 1, 4, 3, 6, 5}},
+		{"subset of 3ch", 3, []int{2, 0}, []int16{3, 1, 6, 4}},
+		{"partial frame ignored", 4, []int{0}, []int16{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := make([]int16, len(src)*2)
+			got := selectChannels(dst, src, tt.numChannels, tt.channels)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("selectChannels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRemixChannels(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         []float32
+		inChannels  int
+		outChannels int
+		expected    []float32
+	}{
+		{"mono to stereo", []float32{1, 2, 3}, 1, 2, []float32{1, 1, 2, 2, 3, 3}},
+		{"mono to 3ch", []float32{1, 2}, 1, 3, []float32{1, 1, 1, 2, 2, 2}},
+		{"stereo to mono", []float32{1, 3, -2, 2, 0.5, 0}, 2, 1, []float32{2, 0, 0.25}},
+		{"3ch to mono", []float32{1, 2, 3, 3, 3, 3}, 3, 1, []float32{2, 3}},
+		{"same channels", []float32{1, 2, 3, 4}, 2, 2, []float32{1, 2, 3, 4}},
+		{"partial frame ignored", []float32{1, 3, 5}, 2, 1, []float32{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := make([]float32, len(tt.src)*3)
+			got := remixChannels(dst, tt.src, tt.inChannels, tt.outChannels)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("remixChannels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// int16 averages are truncated toward zero.
+	got := remixChannels(make([]int16, 2), []int16{1, 2, -1, -2}, 2, 1)
+	if want := []int16{1, -1}; !slices.Equal(got, want) {
+		t.Errorf("remixChannels() = %v, want %v", got, want)
+	}
+}
+
+func TestTransformer_WithSelectChannels(t *testing.T) {
+	invalidCases := []struct {
+		name string
+		opts []Option
+	}{
+		{"no channels", []Option{WithChannels(2), WithSelectChannels()}},
+		{"negative channel", []Option{WithChannels(2), WithSelectChannels(-1)}},
+		{"channel out of range", []Option{WithChannels(2), WithSelectChannels(2)}},
+		{"mid-side with one selected channel", []Option{WithChannels(2), WithSelectChannels(0), WithMidSide()}},
+	}
+	for _, tc := range invalidCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, tc.opts...)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewTransformer() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+
+	// At the default speed, sonic passes samples through unchanged, so the output must be exactly the selected channels.
+	const numFrames = 5000
+	in := make([]int16, numFrames*3)
+	for i := range numFrames {
+		in[3*i] = int16(i)
+		in[3*i+1] = int16(-i)
+		in[3*i+2] = int16(2 * i)
+	}
+	inBytes := new(bytes.Buffer)
+	binary.Write(inBytes, binary.LittleEndian, in)
+
+	out := new(bytes.Buffer)
+	tr, err := NewTransformer(out, 44100, AudioFormatPCM,
+		WithChannels(3),
+		WithSelectChannels(2, 0),
+		WithChannelGains([]float32{1, 0, 0.5}),
+	)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	n, err := tr.Write(inBytes.Bytes())
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n != inBytes.Len() {
+		t.Errorf("Write() n = %d, want %d", n, inBytes.Len())
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	got := make([]int16, out.Len()/2)
+	binary.Read(out, binary.LittleEndian, got)
+	if len(got) != numFrames*2 {
+		t.Fatalf("output samples = %d, want %d", len(got), numFrames*2)
+	}
+	for i := range numFrames {
+		// Channel 2 with gain 0.5, then channel 0 with gain 1.
+		if got[2*i] != int16(i) || got[2*i+1] != int16(i) {
+			t.Fatalf("frame %d = [%d %d], want [%d %d]", i, got[2*i], got[2*i+1], i, i)
+		}
+	}
+}
+
+func TestTransformer_WithOutputChannels(t *testing.T) {
+	invalidCases := []struct {
+		name string
+		opts []Option
+	}{
+		{"stereo to 3ch", []Option{WithChannels(2), WithOutputChannels(3)}},
+		{"3ch to stereo", []Option{WithChannels(3), WithOutputChannels(2)}},
+		{"selected stereo to 4ch", []Option{WithChannels(3), WithSelectChannels(0, 1), WithOutputChannels(4)}},
+	}
+	for _, tc := range invalidCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, tc.opts...)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewTransformer() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+
+	// At the default speed, sonic passes samples through unchanged, so the output must be exactly
+	// the remixed input.
+	const numFrames = 5000
+	tests := []struct {
+		name        string
+		format      AudioFormat
+		opts        []Option
+		input       func(i int) []float32 // Samples of input frame i
+		outChannels int
+		expected    func(i int) []float32 // Samples of output frame i
+	}{
+		{"PCM mono to stereo", AudioFormatPCM, nil,
+			func(i int) []float32 { return []float32{float32(i)} }, 2,
+			func(i int) []float32 { return []float32{float32(i), float32(i)} }},
+		{"PCM stereo to mono", AudioFormatPCM, []Option{WithChannels(2)},
+			func(i int) []float32 { return []float32{float32(i), float32(i + 2)} }, 1,
+			func(i int) []float32 { return []float32{float32(i + 1)} }},
+		{"float stereo to mono", AudioFormatIEEEFloat, []Option{WithChannels(2)},
+			func(i int) []float32 { return []float32{float32(i) / numFrames, 0.5} }, 1,
+			func(i int) []float32 { return []float32{(float32(i)/numFrames + 0.5) / 2} }},
+		{"selected channel to stereo", AudioFormatIEEEFloat, []Option{WithChannels(3), WithSelectChannels(2)},
+			func(i int) []float32 { return []float32{0.25, -0.25, float32(i) / numFrames} }, 2,
+			func(i int) []float32 { return []float32{float32(i) / numFrames, float32(i) / numFrames} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in, want []float32
+			for i := range numFrames {
+				in = append(in, tt.input(i)...)
+				want = append(want, tt.expected(i)...)
+			}
+			var inBytes []byte
+			if tt.format == AudioFormatPCM {
+				for _, s := range in {
+					inBytes = binary.LittleEndian.AppendUint16(inBytes, uint16(int16(s)))
+				}
+			} else {
+				for _, s := range in {
+					inBytes = binary.LittleEndian.AppendUint32(inBytes, math.Float32bits(s))
+				}
+			}
+
+			out := new(bytes.Buffer)
+			tr, err := NewTransformer(out, 44100, tt.format, append(tt.opts, WithOutputChannels(tt.outChannels))...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if got, want := tr.OutputFrameSize(), tt.outChannels*tt.format.SampleSize(); got != want {
+				t.Errorf("OutputFrameSize() = %d, want %d", got, want)
+			}
+			if _, err := tr.Write(inBytes); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var got []float32
+			for b := out.Bytes(); len(b) >= tt.format.SampleSize(); b = b[tt.format.SampleSize():] {
+				if tt.format == AudioFormatPCM {
+					got = append(got, float32(int16(binary.LittleEndian.Uint16(b))))
+				} else {
+					got = append(got, math.Float32frombits(binary.LittleEndian.Uint32(b)))
+				}
+			}
+			if len(got) != len(want) {
+				t.Fatalf("output samples = %d, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-want[i])) > 1.0/16384 { // libsonic quantizes float samples to int16
+					t.Fatalf("output sample %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	tests := []struct {
+		name     string
+		planes   [][]int16
+		expected []int16
+	}{
+		{"no planes", nil, []int16{}},
+		{"mono", [][]int16{{1, 2, 3}}, []int16{1, 2, 3}},
+		{"stereo", [][]int16{{1, 3, 5}, {2, 4, 6}}, []int16{1, 2, 3, 4, 5, 6}},
+		{"3ch", [][]int16{{1, 4}, {2, 5}, {3, 6}}, []int16{1, 2, 3, 4, 5, 6}},
+		{"shortest plane wins", [][]int16{{1, 3, 5}, {2, 4}}, []int16{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Interleave(nil, tt.planes...)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Interleave() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// dst is reused when it is large enough.
+	dst := make([]float32, 0, 8)
+	got := Interleave(dst, []float32{1, 3}, []float32{2, 4})
+	if &got[0] != &dst[:1][0] {
+		t.Error("Interleave() did not reuse dst")
+	}
+}
+
+func TestDeinterleave(t *testing.T) {
+	tests := []struct {
+		name        string
+		numChannels int
+		src         []int16
+		expected    [][]int16
+	}{
+		{"mono", 1, []int16{1, 2, 3}, [][]int16{{1, 2, 3}}},
+		{"stereo", 2, []int16{1, 2, 3, 4, 5, 6}, [][]int16{{1, 3, 5}, {2, 4, 6}}},
+		{"3ch", 3, []int16{1, 2, 3, 4, 5, 6}, [][]int16{{1, 4}, {2, 5}, {3, 6}}},
+		{"partial frame ignored", 2, []int16{1, 2, 3}, [][]int16{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Deinterleave(make([][]int16, tt.numChannels), tt.src)
+			if !slices.EqualFunc(got, tt.expected, slices.Equal) {
+				t.Errorf("Deinterleave() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// Planes are reused when they are large enough, and the round trip is lossless.
+	src := []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8}
+	planes := [][]float32{make([]float32, 0, 4), make([]float32, 0, 4)}
+	l := &planes[0][:1][0]
+	planes = Deinterleave(planes, src)
+	if &planes[0][0] != l {
+		t.Error("Deinterleave() did not reuse the planes")
+	}
+	if got := Interleave(nil, planes...); !slices.Equal(got, src) {
+		t.Errorf("Interleave(Deinterleave()) = %v, want %v", got, src)
+	}
+}
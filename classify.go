@@ -0,0 +1,359 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/internal/fft"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// Content is the kind of audio content found by a Classifier.
+type Content int
+
+// Constants for content types
+const (
+	ContentUnknown Content = iota // Too short or too quiet to tell
+	ContentSpeech                 // Speech, e.g. podcasts and audiobooks
+	ContentMusic                  // Music
+)
+
+// String returns the string representation of the Content.
+func (c Content) String() string {
+	m := map[Content]string{
+		ContentUnknown: "ContentUnknown",
+		ContentSpeech:  "ContentSpeech",
+		ContentMusic:   "ContentMusic",
+	}
+	if s, ok := m[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Content(%d)", c)
+}
+
+// Values returns the all possible values of Content.
+func (Content) Values() []Content {
+	return []Content{
+		ContentUnknown,
+		ContentSpeech,
+		ContentMusic,
+	}
+}
+
+// maxMusicSpeed is the highest speed suggested for music. Beyond it, the phase vocoder smears
+// notes into each other and the rhythm gets lost.
+const maxMusicSpeed = 2
+
+// Options returns the options that usually sound best for content played at speed: EngineSonic
+// for speech, and EngineMusic with the speed limited to 2X for music. Content that could not be
+// classified gets EngineSonic with WithQuality, which is safe for any content.
+func (c Content) Options(speed float32) []Option {
+	switch c {
+	case ContentSpeech:
+		return []Option{WithEngine(EngineSonic), WithSpeed(speed)}
+	case ContentMusic:
+		if speed > maxMusicSpeed {
+			speed = maxMusicSpeed
+		}
+		return []Option{WithEngine(EngineMusic), WithSpeed(speed)}
+	}
+	return []Option{WithEngine(EngineSonic), WithQuality(), WithSpeed(speed)}
+}
+
+const (
+	classifyFrameDuration = 32 * time.Millisecond // Analysis frame length, rounded up to a power of 2
+	classifyMinDuration   = time.Second           // Shortest non-silent input that is classified
+	classifyMaxDuration   = 30 * time.Second      // Longest input read by Classify
+	classifySilence       = -50                   // Frames this far below the loudest in dB are silent
+	classifyLowFreq       = 100                   // Lowest frequency of the flatness in Hz
+	classifyHighFreq      = 6000                  // Highest frequency of the flatness in Hz
+	classifyMinBeat       = 250 * time.Millisecond
+	classifyMaxBeat       = 1500 * time.Millisecond
+	classifyMinOnset      = 1e-3 // Lowest standard deviation of the relative onset strength
+)
+
+// Thresholds of the features, calibrated on recordings of speech and music. Each feature that
+// is on the speech side of its threshold is a vote for speech.
+const (
+	speechLowEnergy = 0.35 // Speech pauses between words, so many frames are quiet
+	speechFlatness  = 0.05 // Voiced and unvoiced sounds alternate, so the flatness varies a lot
+	speechRhythm    = 0.4  // Syllables are not periodic, unlike beats
+)
+
+// Classification is the result of a Classifier: the content type and the features it was
+// decided by.
+type Classification struct {
+	Content Content
+
+	// LowEnergyRatio is the share of frames whose level is less than half of the mean level.
+	// Speech has many low energy frames, from the pauses between words.
+	LowEnergyRatio float64
+
+	// FlatnessVariation is the standard deviation of the spectral flatness of the non-silent
+	// frames, where the flatness is the geometric mean of the power spectrum divided by its
+	// arithmetic mean. The spectrum of speech alternates between tonal vowels and noisy
+	// consonants, while the flatness of music changes slowly.
+	FlatnessVariation float64
+
+	// Rhythm is the highest normalized autocorrelation of the onset strength at beat periods from
+	// 0.25 to 1.5 seconds, from 0 to 1. Music with a beat has a strong rhythm, speech has not.
+	Rhythm float64
+}
+
+// Classifier tells speech from music with lightweight heuristics, to pick the engine and the
+// settings with Content.Options, e.g. for apps that want the best sound at a given speed without
+// asking the user what they are playing.
+//
+// The input is cut into frames of about 32 ms. Three features of the frames vote: the share of
+// low energy frames, the variation of the spectral flatness, and the periodicity of the onsets.
+// The content is speech if at least two of them are on the speech side, see Classification.
+// The heuristics are reliable for clean speech and for most music with a beat; sung vocals and
+// speech over music may go either way. A Classifier is not safe for concurrent use.
+type Classifier struct {
+	sampleRate  int
+	numChannels int
+	frameSize   int
+	fft         *fft.FFT
+	window      []float64
+	lowBin      int
+	highBin     int
+	pending     []float64    // Mono input not analyzed yet
+	spectrum    []complex128 // Work buffer of the FFT
+	magnitude   []float64    // Magnitude spectrum of the previous frame
+	energy      []float64    // Energy of each frame
+	flatness    []float64    // Natural logarithm of the spectral flatness of each frame
+	flux        []float64    // Onset strength of each frame
+}
+
+// NewClassifier creates a classifier of input with numChannels interleaved channels at
+// sampleRate. The channels are mixed down for the analysis.
+func NewClassifier(sampleRate, numChannels int) (*Classifier, error) {
+	if sampleRate < cgosonic.MIN_SAMPLE_RATE || cgosonic.MAX_SAMPLE_RATE < sampleRate {
+		return nil, fmt.Errorf("%w: sampleRate %d is out of range [%d, %d]", ErrInvalid, sampleRate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE)
+	}
+	if numChannels < 1 {
+		return nil, fmt.Errorf("%w: numChannels %d must be positive", ErrInvalid, numChannels)
+	}
+	frameSize := 1
+	for time.Duration(frameSize)*time.Second < classifyFrameDuration*time.Duration(sampleRate) {
+		frameSize *= 2
+	}
+	highBin := classifyHighFreq * frameSize / sampleRate
+	if highBin > frameSize/2 {
+		highBin = frameSize / 2
+	}
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize))
+	}
+	return &Classifier{
+		sampleRate:  sampleRate,
+		numChannels: numChannels,
+		frameSize:   frameSize,
+		fft:         fft.New(frameSize),
+		window:      window,
+		lowBin:      max(1, classifyLowFreq*frameSize/sampleRate),
+		highBin:     highBin,
+		spectrum:    make([]complex128, frameSize),
+	}, nil
+}
+
+// AddInt16 adds interleaved 16-bit samples to the classifier. A trailing partial frame is
+// ignored.
+func (c *Classifier) AddInt16(samples []int16) {
+	addClassifierSamples(c, samples, 32767)
+}
+
+// AddFloat32 adds interleaved float samples to the classifier. A trailing partial frame is
+// ignored.
+func (c *Classifier) AddFloat32(samples []float32) {
+	addClassifierSamples(c, samples, 1)
+}
+
+// addClassifierSamples mixes samples down, scaled to ±1 by full, and analyzes them.
+func addClassifierSamples[T int16 | float32](c *Classifier, samples []T, full float64) {
+	for i := 0; i+c.numChannels <= len(samples); i += c.numChannels {
+		sum := 0.0
+		for _, v := range samples[i : i+c.numChannels] {
+			sum += float64(v)
+		}
+		c.pending = append(c.pending, sum/float64(c.numChannels)/full)
+	}
+	hop := c.frameSize / 2
+	consumed := 0
+	for len(c.pending)-consumed >= c.frameSize {
+		c.analyzeFrame(c.pending[consumed : consumed+c.frameSize])
+		consumed += hop
+	}
+	c.pending = c.pending[:copy(c.pending, c.pending[consumed:])]
+}
+
+// analyzeFrame computes the features of one frame.
+func (c *Classifier) analyzeFrame(frame []float64) {
+	for i, v := range frame {
+		c.spectrum[i] = complex(v*c.window[i], 0)
+	}
+	c.fft.Transform(c.spectrum, false)
+
+	magnitude := make([]float64, c.highBin-c.lowBin)
+	var energy, logSum, sum, flux float64
+	for k := c.lowBin; k < c.highBin; k++ {
+		v := c.spectrum[k]
+		power := real(v)*real(v) + imag(v)*imag(v)
+		energy += power
+		logSum += math.Log(power + 1e-20)
+		magnitude[k-c.lowBin] = math.Sqrt(power)
+		sum += magnitude[k-c.lowBin]
+		if c.magnitude != nil {
+			flux += max(0, magnitude[k-c.lowBin]-c.magnitude[k-c.lowBin])
+		}
+	}
+	c.magnitude = magnitude
+	n := float64(len(magnitude))
+	c.energy = append(c.energy, energy)
+	// The geometric mean of the power divided by the arithmetic mean
+	c.flatness = append(c.flatness, math.Exp(logSum/n)/(energy/n+1e-20))
+	// The flux relative to the magnitude, so that the onsets of quiet and loud passages count alike
+	c.flux = append(c.flux, flux/(sum+1e-10))
+}
+
+// Classify returns the classification of the input added so far. It can be called at any time,
+// e.g. to decide as soon as enough input has been seen.
+func (c *Classifier) Classify() Classification {
+	var result Classification
+	loudest := 0.0
+	for _, e := range c.energy {
+		loudest = max(loudest, e)
+	}
+	if loudest == 0 {
+		return result
+	}
+	threshold := loudest * math.Pow(10, classifySilence/10)
+
+	// Low energy ratio: frames below half of the mean RMS level
+	var meanRMS float64
+	for _, e := range c.energy {
+		meanRMS += math.Sqrt(e)
+	}
+	meanRMS /= float64(len(c.energy))
+	var low int
+	for _, e := range c.energy {
+		if math.Sqrt(e) < meanRMS/2 {
+			low++
+		}
+	}
+	result.LowEnergyRatio = float64(low) / float64(len(c.energy))
+
+	// Flatness variation of the non-silent frames
+	var sum, sq float64
+	var voiced int
+	for i, f := range c.flatness {
+		if c.energy[i] >= threshold {
+			sum += f
+			sq += f * f
+			voiced++
+		}
+	}
+	hop := time.Duration(c.frameSize/2) * time.Second / time.Duration(c.sampleRate)
+	if time.Duration(voiced)*hop < classifyMinDuration {
+		return result
+	}
+	mean := sum / float64(voiced)
+	result.FlatnessVariation = math.Sqrt(max(0, sq/float64(voiced)-mean*mean))
+	result.Rhythm = c.rhythm(hop)
+
+	votes := 0
+	if result.LowEnergyRatio > speechLowEnergy {
+		votes++
+	}
+	if result.FlatnessVariation > speechFlatness {
+		votes++
+	}
+	if result.Rhythm < speechRhythm {
+		votes++
+	}
+	if votes >= 2 {
+		result.Content = ContentSpeech
+	} else {
+		result.Content = ContentMusic
+	}
+	return result
+}
+
+// rhythm returns the highest normalized autocorrelation of the onset strength at the beat
+// periods, with frames hop apart.
+func (c *Classifier) rhythm(hop time.Duration) float64 {
+	var mean float64
+	for _, f := range c.flux {
+		mean += f
+	}
+	mean /= float64(len(c.flux))
+	onsets := make([]float64, len(c.flux))
+	var power float64
+	for i, f := range c.flux {
+		onsets[i] = f - mean
+		power += onsets[i] * onsets[i]
+	}
+	// Steady sounds have no onsets; their flux is numerical noise.
+	if math.Sqrt(power/float64(len(onsets))) < classifyMinOnset {
+		return 0
+	}
+	best := 0.0
+	for lag := int(classifyMinBeat / hop); lag <= int(classifyMaxBeat/hop) && lag < len(onsets)/2; lag++ {
+		var r float64
+		for i := lag; i < len(onsets); i++ {
+			r += onsets[i] * onsets[i-lag]
+		}
+		best = max(best, r/power)
+	}
+	return best
+}
+
+// Classify reads up to 30 seconds of interleaved samples in format with numChannels channels at
+// sampleRate from r and classifies them. It stops at the end of r without an error; a trailing
+// partial frame is ignored. Wrap r with io.TeeReader to keep the samples read, e.g. to
+// transform them afterwards with the options of Content.Options.
+func Classify(r io.Reader, sampleRate, numChannels int, format AudioFormat) (Classification, error) {
+	c, err := NewClassifier(sampleRate, numChannels)
+	if err != nil {
+		return Classification{}, err
+	}
+	if format.SampleSize() == 0 {
+		return Classification{}, fmt.Errorf("%w: unsupported audio format %v", ErrInvalid, format)
+	}
+	frameSize := format.SampleSize() * numChannels
+	limit := int64(classifyMaxDuration/time.Second) * int64(sampleRate) * int64(frameSize)
+	r = io.LimitReader(r, limit)
+
+	buf := make([]byte, 4096*frameSize)
+	var int16s []int16
+	var floats []float32
+	for {
+		n, err := io.ReadFull(r, buf)
+		p := buf[:n-n%frameSize]
+		switch format {
+		case AudioFormatPCM:
+			int16s = pcm.DecodeInt16(int16s, p)
+			c.AddInt16(int16s)
+		case AudioFormatU8:
+			int16s = pcm.Uint8ToInt16(int16s, p)
+			c.AddInt16(int16s)
+		case AudioFormatIEEEFloat:
+			floats = pcm.DecodeFloat32(floats, p)
+			c.AddFloat32(floats)
+		default:
+			floats = format.toFloat32(floats, p)
+			c.AddFloat32(floats)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return c.Classify(), nil
+		}
+		if err != nil {
+			return Classification{}, err
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/signal"
+)
+
+// music returns seconds of a synthetic song at sampleRate: chords changing every other beat, with
+// a kick drum and a noisy hi-hat on every beat at bpm.
+func music(sampleRate, seconds int, bpm float64) []float32 {
+	notes := []float64{220, 261.63, 329.63, 392, 293.66, 349.23}
+	beat := int(60 / bpm * float64(sampleRate))
+	samples := make([]float32, sampleRate*seconds)
+	hihat := signal.WhiteNoise(1, 1, len(samples))
+	for i := range samples {
+		t := float64(i) / float64(sampleRate)
+		chord := i / (2 * beat)
+		v := 0.0
+		for j, interval := range []float64{1, 1.25, 1.5} {
+			v += 0.12 * math.Sin(2*math.Pi*notes[(chord+j)%len(notes)]*interval*t)
+		}
+		decay := math.Exp(-float64(i%beat) / (0.03 * float64(sampleRate)))
+		v += 0.4 * decay * (float64(hihat[i]) + math.Sin(2*math.Pi*60*t))
+		samples[i] = float32(v)
+	}
+	return samples
+}
+
+func TestClassifier(t *testing.T) {
+	speech := audiotest.Speech()
+	stereo := make([]int16, 2*len(speech))
+	for i, s := range speech {
+		stereo[2*i], stereo[2*i+1] = s, s/2
+	}
+
+	tests := []struct {
+		name        string
+		sampleRate  int
+		numChannels int
+		int16s      []int16
+		floats      []float32
+		want        Content
+	}{
+		{"speech", audiotest.SpeechSampleRate, 1, speech, nil, ContentSpeech},
+		{"stereo speech", audiotest.SpeechSampleRate, 2, stereo, nil, ContentSpeech},
+		{"music 120 BPM", 44100, 1, nil, music(44100, 8, 120), ContentMusic},
+		{"music 90 BPM", 22050, 1, nil, music(22050, 8, 90), ContentMusic},
+		{"music 150 BPM", 16000, 1, nil, music(16000, 8, 150), ContentMusic},
+		{"silence", 16000, 1, make([]int16, 16000*3), nil, ContentUnknown},
+		{"too short", 16000, 1, nil, signal.Sine(16000, 440, 0.5, 8000), ContentUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClassifier(tt.sampleRate, tt.numChannels)
+			if err != nil {
+				t.Fatalf("NewClassifier() error = %v", err)
+			}
+			// Add the input in odd chunks, to cover frames spanning calls.
+			const chunk = 1001
+			for i := 0; i < len(tt.int16s); i += chunk * tt.numChannels {
+				c.AddInt16(tt.int16s[i:min(i+chunk*tt.numChannels, len(tt.int16s))])
+			}
+			for i := 0; i < len(tt.floats); i += chunk * tt.numChannels {
+				c.AddFloat32(tt.floats[i:min(i+chunk*tt.numChannels, len(tt.floats))])
+			}
+			if got := c.Classify(); got.Content != tt.want {
+				t.Errorf("Classify() = %+v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	speech := audiotest.Speech()
+	float := pcm.Int16ToFloat32(nil, speech, int16Scaling)
+	tests := []struct {
+		name   string
+		format AudioFormat
+		data   []byte
+	}{
+		{"PCM", AudioFormatPCM, pcm.EncodeInt16(nil, speech)},
+		{"U8", AudioFormatU8, pcm.Int16ToUint8(nil, speech)},
+		{"PCM24", AudioFormatPCM24, pcm.Int16ToInt24(nil, speech)},
+		{"float", AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, float)},
+		{"float64", AudioFormatIEEEFloat64, pcm.Float32ToFloat64(nil, float)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Classify(bytes.NewReader(tt.data), audiotest.SpeechSampleRate, 1, tt.format)
+			if err != nil {
+				t.Fatalf("Classify() error = %v", err)
+			}
+			if got.Content != ContentSpeech {
+				t.Errorf("Classify() = %+v, want %v", got, ContentSpeech)
+			}
+		})
+	}
+
+	t.Run("reads at most 30 seconds", func(t *testing.T) {
+		const rate = 8000
+		data := pcm.EncodeInt16(nil, signal.Int16(music(rate, 40, 120)))
+		r := bytes.NewReader(data)
+		got, err := Classify(r, rate, 1, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("Classify() error = %v", err)
+		}
+		if got.Content != ContentMusic {
+			t.Errorf("Classify() = %+v, want %v", got, ContentMusic)
+		}
+		if read, want := len(data)-r.Len(), 30*rate*2; read != want {
+			t.Errorf("Classify() read %d bytes, want %d", read, want)
+		}
+	})
+}
+
+func TestClassify_Errors(t *testing.T) {
+	if _, err := NewClassifier(100, 1); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewClassifier() with invalid sample rate error = %v, want %v", err, ErrInvalid)
+	}
+	if _, err := NewClassifier(8000, 0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewClassifier() with no channels error = %v, want %v", err, ErrInvalid)
+	}
+	if _, err := Classify(bytes.NewReader(nil), 8000, 1, AudioFormat(99)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Classify() with unknown format error = %v, want %v", err, ErrInvalid)
+	}
+	errRead := errors.New("read error")
+	if _, err := Classify(iotest.ErrReader(errRead), 8000, 1, AudioFormatPCM); !errors.Is(err, errRead) {
+		t.Errorf("Classify() with failing reader error = %v, want %v", err, errRead)
+	}
+}
+
+func TestContent_Options(t *testing.T) {
+	tests := []struct {
+		content     Content
+		speed       float32
+		wantEngine  Engine
+		wantQuality int
+		wantSpeed   float32
+	}{
+		{ContentSpeech, 3, EngineSonic, 0, 3},
+		{ContentMusic, 1.7, EngineMusic, 0, 1.7},
+		{ContentMusic, 3, EngineMusic, 0, 2},
+		{ContentUnknown, 1.5, EngineSonic, 1, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content.String(), func(t *testing.T) {
+			tr, err := NewTransformer(&bytes.Buffer{}, 44100, AudioFormatPCM, tt.content.Options(tt.speed)...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			quality := 0
+			if tr.quality != nil {
+				quality = *tr.quality
+			}
+			if tr.engine != tt.wantEngine || quality != tt.wantQuality || *tr.speed != tt.wantSpeed {
+				t.Errorf("Options(%v) = engine %v, quality %d, speed %v, want %v, %d, %v", tt.speed, tr.engine, quality, *tr.speed, tt.wantEngine, tt.wantQuality, tt.wantSpeed)
+			}
+		})
+	}
+}
+
+func TestContent_String(t *testing.T) {
+	if got := ContentMusic.String(); got != "ContentMusic" {
+		t.Errorf("String() = %q, want %q", got, "ContentMusic")
+	}
+	if got := Content(42).String(); got != "Content(42)" {
+		t.Errorf("String() = %q, want %q", got, "Content(42)")
+	}
+}
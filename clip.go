@@ -0,0 +1,134 @@
+package sonic
+
+import (
+	"fmt"
+	"math"
+)
+
+// FloatClipping represents how float32 output beyond full scale is handled.
+// See WithFloatClipping.
+type FloatClipping int
+
+// Constants for float clipping policies
+const (
+	FloatClippingClamp FloatClipping = iota // Clamp each sample to [-1, 1]
+	FloatClippingNone                       // Leave samples unclamped
+	FloatClippingScale                      // Scale down each output block so that its peak is 1
+)
+
+// String returns the string representation of the FloatClipping.
+func (c FloatClipping) String() string {
+	m := map[FloatClipping]string{
+		FloatClippingClamp: "FloatClippingClamp",
+		FloatClippingNone:  "FloatClippingNone",
+		FloatClippingScale: "FloatClippingScale",
+	}
+	if s, ok := m[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("FloatClipping(%d)", c)
+}
+
+// Values returns the all possible values of FloatClipping.
+func (FloatClipping) Values() []FloatClipping {
+	return []FloatClipping{
+		FloatClippingClamp,
+		FloatClippingNone,
+		FloatClippingScale,
+	}
+}
+
+// clipFloat32 applies the clipping policy to samples in place.
+// NaN samples are replaced by 0 and infinite samples by ±1 under every policy, so that the output
+// never contains non-finite values.
+func clipFloat32(samples []float32, policy FloatClipping) {
+	peak := float32(0)
+	for i, s := range samples {
+		switch {
+		case s != s: // NaN
+			s = 0
+		case math.IsInf(float64(s), 0):
+			s = float32(math.Copysign(1, float64(s)))
+		}
+		switch policy {
+		case FloatClippingClamp:
+			if s > 1 {
+				s = 1
+			} else if s < -1 {
+				s = -1
+			}
+		case FloatClippingScale:
+			if a := float32(math.Abs(float64(s))); a > peak {
+				peak = a
+			}
+		}
+		samples[i] = s
+	}
+	if policy == FloatClippingScale && peak > 1 {
+		scale := 1 / peak
+		for i := range samples {
+			samples[i] *= scale
+		}
+	}
+}
+
+// ClippingReportFunc receives the number of clipped output samples and the output frame of the
+// first of them. See WithClippingReport.
+type ClippingReportFunc func(clipped, firstFrame int64)
+
+// countClippedInt16 counts the samples of an output block at full scale, where libsonic and the
+// post filters saturate, if WithClippingReport is given.
+func (t *Transformer) countClippedInt16(samples []int16) {
+	if !t.clipReport {
+		return
+	}
+	first, clipped := -1, 0
+	for i, s := range samples {
+		if s >= math.MaxInt16 || s <= -math.MaxInt16 {
+			if clipped == 0 {
+				first = i
+			}
+			clipped++
+		}
+	}
+	t.recordClipping(len(samples), first, clipped)
+}
+
+// countClippedFloat32 counts the samples of an output block at or beyond full scale, before the
+// float clipping policy is applied, if WithClippingReport is given.
+func (t *Transformer) countClippedFloat32(samples []float32) {
+	if !t.clipReport {
+		return
+	}
+	first, clipped := -1, 0
+	for i, s := range samples {
+		if s >= 1 || s <= -1 {
+			if clipped == 0 {
+				first = i
+			}
+			clipped++
+		}
+	}
+	t.recordClipping(len(samples), first, clipped)
+}
+
+// recordClipping adds the clipped samples of an output block of numSamples samples to the
+// counters, given the index of the first of them.
+func (t *Transformer) recordClipping(numSamples, first, clipped int) {
+	if clipped > 0 {
+		if t.clippedSamples == 0 {
+			t.firstClip = t.clipFrames + int64(first/t.streamChannels)
+		}
+		t.clippedSamples += int64(clipped)
+	}
+	t.clipFrames += int64(numSamples / t.streamChannels)
+}
+
+// reportClipping calls the function given with WithClippingReport if samples clipped since the
+// last report.
+func (t *Transformer) reportClipping() {
+	if t.clipFunc != nil && t.clippedSamples > t.clipReported {
+		t.clipReported = t.clippedSamples
+		t.clipFunc(t.clippedSamples, t.firstClip)
+	}
+}
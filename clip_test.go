@@ -0,0 +1,189 @@
+package sonic
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestClipFloat32(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+
+	tests := []struct {
+		name   string
+		policy FloatClipping
+		in     []float32
+		want   []float32
+	}{
+		{"clamp", FloatClippingClamp, []float32{0.5, 1.5, -2, -0.25}, []float32{0.5, 1, -1, -0.25}},
+		{"none", FloatClippingNone, []float32{0.5, 1.5, -2, -0.25}, []float32{0.5, 1.5, -2, -0.25}},
+		{"scale", FloatClippingScale, []float32{0.5, 1, -2, -0.25}, []float32{0.25, 0.5, -1, -0.125}},
+		{"scale within full scale", FloatClippingScale, []float32{0.5, -1}, []float32{0.5, -1}},
+		{"clamp non-finite", FloatClippingClamp, []float32{nan, inf, -inf}, []float32{0, 1, -1}},
+		{"none non-finite", FloatClippingNone, []float32{nan, inf, -inf}, []float32{0, 1, -1}},
+		{"scale non-finite", FloatClippingScale, []float32{nan, inf, -inf, 0.5}, []float32{0, 1, -1, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Clone(tt.in)
+			clipFloat32(got, tt.policy)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("clipFloat32(%v, %v) = %v, want %v", tt.in, tt.policy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloatClipping_String(t *testing.T) {
+	if got := FloatClippingScale.String(); got != "FloatClippingScale" {
+		t.Errorf("String() = %q, want %q", got, "FloatClippingScale")
+	}
+	if got := FloatClipping(42).String(); got != "FloatClipping(42)" {
+		t.Errorf("String() = %q, want %q", got, "FloatClipping(42)")
+	}
+}
+
+// TestTransformer_FloatClipping drives full-scale float input at volume 100 with a channel gain
+// beyond full scale, and checks the output range under each policy.
+func TestTransformer_FloatClipping(t *testing.T) {
+	in := make([]float32, 44100)
+	for i := range in {
+		in[i] = float32(math.Sin(2 * math.Pi * 220 * float64(i) / 44100))
+	}
+	input := pcm.EncodeFloat32(nil, in)
+
+	tests := []struct {
+		policy   FloatClipping
+		wantPeak func(peak float32) bool
+	}{
+		{FloatClippingClamp, func(peak float32) bool { return peak == 1 }},
+		{FloatClippingNone, func(peak float32) bool { return peak > 1.9 }},
+		{FloatClippingScale, func(peak float32) bool { return peak > 0.999 && peak <= 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tr, err := NewTransformer(out, 44100, AudioFormatIEEEFloat,
+				WithVolume(100), WithChannelGains([]float32{2}), WithFloatClipping(tt.policy))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(input); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			got := pcm.DecodeFloat32(nil, out.Bytes())
+			peak := float32(0)
+			for i, s := range got {
+				if s != s || math.IsInf(float64(s), 0) {
+					t.Fatalf("sample %d = %v, want finite", i, s)
+				}
+				peak = float32(math.Max(float64(peak), math.Abs(float64(s))))
+			}
+			if !tt.wantPeak(peak) {
+				t.Errorf("output peak = %v", peak)
+			}
+		})
+	}
+}
+
+// TestTransformer_ClippingReport writes a second of quiet input followed by a second of loud input
+// at volume 4, and checks that only the loud second is reported as clipped.
+func TestTransformer_ClippingReport(t *testing.T) {
+	in := make([]float32, 2*44100)
+	for i := range in {
+		amplitude := 0.1
+		if i >= 44100 {
+			amplitude = 0.5
+		}
+		in[i] = float32(amplitude * math.Sin(2*math.Pi*220*float64(i)/44100))
+	}
+
+	tests := []struct {
+		name      string
+		format    AudioFormat
+		opts      []Option
+		wantFirst int64
+	}{
+		{"PCM", AudioFormatPCM, nil, 44100},
+		{"IEEEFloat", AudioFormatIEEEFloat, nil, 44100},
+		{"PCM with channel gain", AudioFormatPCM, []Option{WithChannelGains([]float32{1.5})}, 44100},
+		{"output sample rate", AudioFormatPCM, []Option{WithOutputSampleRate(48000)}, 48000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input []byte
+			if tt.format == AudioFormatPCM {
+				input = pcm.EncodeInt16(nil, pcm.Float32ToInt16(nil, in, pcm.Scaling32767))
+			} else {
+				input = pcm.EncodeFloat32(nil, in)
+			}
+			var reports [][2]int64
+			opts := append([]Option{WithVolume(4), WithClippingReport(func(clipped, firstFrame int64) {
+				reports = append(reports, [2]int64{clipped, firstFrame})
+			})}, tt.opts...)
+			tr, err := NewTransformer(io.Discard, 44100, tt.format, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(input); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			stats := tr.Stats()
+			if stats.ClippedSamples < 1000 || stats.ClippedSamples > 44100 {
+				t.Errorf("ClippedSamples = %d, want between 1000 and 44100", stats.ClippedSamples)
+			}
+			if stats.FirstClipFrame < tt.wantFirst || stats.FirstClipFrame > tt.wantFirst+tt.wantFirst/200 {
+				t.Errorf("FirstClipFrame = %d, want shortly after %d", stats.FirstClipFrame, tt.wantFirst)
+			}
+			if want := [][2]int64{{stats.ClippedSamples, stats.FirstClipFrame}}; !slices.Equal(reports, want) {
+				t.Errorf("reports = %v, want %v", reports, want)
+			}
+
+			// Quiet input does not clip, so it is not reported again.
+			if _, err := tr.Write(input[:len(input)/2]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if got := tr.Stats(); got.ClippedSamples != stats.ClippedSamples || got.FirstClipFrame != stats.FirstClipFrame {
+				t.Errorf("Stats() after quiet input = %d, %d, want %d, %d", got.ClippedSamples, got.FirstClipFrame, stats.ClippedSamples, stats.FirstClipFrame)
+			}
+			if len(reports) != 1 {
+				t.Errorf("reports after quiet input = %v, want 1 report", reports)
+			}
+		})
+	}
+}
+
+func TestTransformer_ClippingReportOff(t *testing.T) {
+	tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithVolume(100))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(pcm.EncodeInt16(nil, fullScaleSquare(4410, 100))); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if stats := tr.Stats(); stats.ClippedSamples != 0 || stats.FirstClipFrame != -1 {
+		t.Errorf("Stats() = %d clipped samples, first at %d, want 0, -1", stats.ClippedSamples, stats.FirstClipFrame)
+	}
+}
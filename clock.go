@@ -0,0 +1,32 @@
+package sonic
+
+import "time"
+
+// Clock tells the time to a Transformer. See WithClock.
+//
+// Time-dependent behavior, such as the wall-clock accounting of WallClockSpent, reads the time
+// from the clock instead of the operating system, and code pacing its processing in real time
+// waits with Sleep, so that tests can control the time with a fake clock, e.g. sonictest.Clock,
+// and run instantly and deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses the calling goroutine for at least d.
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the clock of the operating system, using time.Now and time.Sleep.
+var SystemClock Clock = systemClock{}
+
+// systemClock implements SystemClock.
+type systemClock struct{}
+
+// Now implements Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep implements Clock.
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
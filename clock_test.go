@@ -0,0 +1,70 @@
+package sonic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/sonictest"
+)
+
+func TestTransformer_WallClockSpent(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	clock := sonictest.NewClock(time.Unix(0, 0))
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate/4])
+	calls := 0
+	tr, err := sonic.NewTransformer(nil, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, sonic.WithSpeed(1.5),
+		sonic.WithClock(clock),
+		sonic.WithOutputFunc(func(p []byte) error {
+			calls++
+			clock.Advance(delay)
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if got := tr.WallClockSpent(); got != 0 {
+		t.Errorf("WallClockSpent() = %v before Write, want 0", got)
+	}
+
+	if _, err := tr.Write(input); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, want := tr.WallClockSpent(), time.Duration(calls)*delay; got != want || calls == 0 {
+		t.Errorf("WallClockSpent() = %v after Write, want the %v spent in %d calls of the output function", got, want, calls)
+	}
+
+	// The time between calls is not spent by the transformer.
+	clock.Sleep(time.Hour)
+	if got, want := tr.WallClockSpent(), time.Duration(calls)*delay; got != want {
+		t.Errorf("WallClockSpent() = %v after sleeping, want %v", got, want)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := tr.WallClockSpent(), time.Duration(calls)*delay; got != want {
+		t.Errorf("WallClockSpent() = %v after Flush, want %v", got, want)
+	}
+}
+
+func TestTransformer_WallClockSpent_SystemClock(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate/4])
+	tr, err := sonic.NewTransformer(nil, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, sonic.WithOutputFunc(func(p []byte) error {
+		time.Sleep(delay)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(input); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := tr.WallClockSpent(); got < delay {
+		t.Errorf("WallClockSpent() = %v after Write, want at least the %v spent in the output function", got, delay)
+	}
+}
@@ -0,0 +1,4 @@
+// Package e2e tests the sonic command end to end: its tests build the command and run the scripts
+// in cmd/sonic/testdata/script with testscript. It is a nested module, so that the core module
+// does not depend on testscript.
+package e2e
@@ -0,0 +1,130 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/wav"
+	"github.com/rogpeppe/go-internal/testscript"
+)
+
+// binDir is the directory of the sonic binary built by TestMain.
+var binDir string
+
+// TestMain builds the sonic command, which the scripts run from the PATH.
+func TestMain(m *testing.M) {
+	os.Exit(buildAndRun(m))
+}
+
+func buildAndRun(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "sonic-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	exe := "sonic"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, exe), "github.com/nakat-t/sonic-go/cmd/sonic")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "building sonic: %v\n", err)
+		return 1
+	}
+	binDir = dir
+	return m.Run()
+}
+
+// TestScript runs the scripts in cmd/sonic/testdata/script, which run the sonic command end to end
+// and compare its output, error messages and exit codes with golden files. Next to the builtin
+// commands of testscript, the scripts can use:
+//
+//	speechwav file         write the speech of audiotest as a WAVE file
+//	speechraw file         write the speech of audiotest as raw 16-bit samples
+//	wavinfo file           print the format, channels, sample rate and frames of a WAVE file
+//	frames size file       print the number of frames of size bytes in file
+//	exitcode code cmd ...  run cmd and check that it exits with code
+func TestScript(t *testing.T) {
+	testscript.Run(t, testscript.Params{
+		Dir: "../testdata/script",
+		Setup: func(env *testscript.Env) error {
+			env.Setenv("PATH", binDir+string(os.PathListSeparator)+env.Getenv("PATH"))
+			return nil
+		},
+		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
+			"speechwav": writeFileCmd("speechwav", audiotest.SpeechWAV),
+			"speechraw": writeFileCmd("speechraw", audiotest.SpeechPCM),
+			"wavinfo":   cmdWavinfo,
+			"frames":    cmdFrames,
+			"exitcode":  cmdExitcode,
+		},
+	})
+}
+
+// writeFileCmd returns a script command that writes the bytes returned by data to a file.
+func writeFileCmd(name string, data func() []byte) func(ts *testscript.TestScript, neg bool, args []string) {
+	return func(ts *testscript.TestScript, neg bool, args []string) {
+		if neg || len(args) != 1 {
+			ts.Fatalf("usage: %s file", name)
+		}
+		ts.Check(os.WriteFile(ts.MkAbs(args[0]), data(), 0o644))
+	}
+}
+
+func cmdWavinfo(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 1 {
+		ts.Fatalf("usage: wavinfo file")
+	}
+	f, err := os.Open(ts.MkAbs(args[0]))
+	ts.Check(err)
+	defer f.Close()
+	d, err := wav.NewDecoder(f)
+	ts.Check(err)
+	n, err := io.Copy(io.Discard, d)
+	ts.Check(err)
+	fmt.Fprintf(ts.Stdout(), "%v, %d channels, %d Hz, %d frames\n", d.Format(), d.NumChannels(), d.SampleRate(), n/int64(d.FrameSize()))
+}
+
+func cmdFrames(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 2 {
+		ts.Fatalf("usage: frames size file")
+	}
+	size, err := strconv.Atoi(args[0])
+	if err != nil || size < 1 {
+		ts.Fatalf("invalid frame size %q", args[0])
+	}
+	info, err := os.Stat(ts.MkAbs(args[1]))
+	ts.Check(err)
+	fmt.Fprintf(ts.Stdout(), "%d frames\n", info.Size()/int64(size))
+}
+
+func cmdExitcode(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) < 2 {
+		ts.Fatalf("usage: exitcode code cmd [args...]")
+	}
+	want, err := strconv.Atoi(args[0])
+	if err != nil {
+		ts.Fatalf("invalid exit code %q", args[0])
+	}
+	got := 0
+	if err := ts.Exec(args[1], args[2:]...); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			ts.Fatalf("%v", err)
+		}
+		got = exitErr.ExitCode()
+	}
+	if got != want {
+		ts.Fatalf("%s exited with %d, want %d", args[1], got, want)
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/nakat-t/sonic-go/cmd/sonic/e2e
+
+go 1.24
+
+require github.com/nakat-t/sonic-go v0.0.0
+
+require (
+	github.com/rogpeppe/go-internal v1.14.1
+	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/tools v0.26.0 // indirect
+)
+
+replace github.com/nakat-t/sonic-go => ../../..
@@ -0,0 +1,197 @@
+// Command sonic speeds up, slows down or changes the pitch of a WAVE file, as a drop-in
+// replacement of the sonic command of libsonic built with Go.
+//
+// Usage:
+//
+//	sonic [-s speed] [-p pitch] [-r rate] [-v volume] [-q] [-auto] infile outfile
+//	sonic -raw [-samplerate hz] [-channels n] [-format s16|s24|s32|f32|f64|u8] [options] [infile [outfile]]
+//
+// infile and outfile are WAVE files in the formats of the wav package, and the output has the
+// sample rate, the number of channels and the format of the input. With -raw, they are raw
+// interleaved little-endian samples described by -samplerate, -channels and -format instead, and
+// default to stdin and stdout; "-" also stands for them. For example, to play a recording twice
+// as fast:
+//
+//	sonic -s 2.0 in.wav out.wav
+//	sox in.wav -t raw - | sonic -raw -samplerate 44100 -s 2.0 | aplay -f S16_LE -r 44100
+//
+// With -auto, the first 30 seconds of the input are classified as speech or music, and the
+// engine, the quality and the speed limit are picked for the content, see sonic.Classify. The
+// detected content is printed to stderr.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// rawFormats maps the names of -format to the audio formats.
+var rawFormats = map[string]sonic.AudioFormat{
+	"s16": sonic.AudioFormatPCM,
+	"s24": sonic.AudioFormatPCM24,
+	"s32": sonic.AudioFormatPCM32,
+	"f32": sonic.AudioFormatIEEEFloat,
+	"f64": sonic.AudioFormatIEEEFloat64,
+	"u8":  sonic.AudioFormatU8,
+}
+
+// errUsage is returned by run for invalid arguments, after printing the usage.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	os.Exit(exitCode(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr), os.Stderr))
+}
+
+// exitCode returns the exit status of the command for err, the error returned by run: 0 on
+// success and for -h, 2 for invalid arguments, whose usage run has printed, and 1 for other
+// errors, which it prints to stderr.
+func exitCode(err error, stderr io.Writer) int {
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "sonic: %v\n", err)
+		return 1
+	}
+}
+
+// run runs the command with args, the arguments without the program name.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	fs := flag.NewFlagSet("sonic", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	speed := fs.Float64("s", 1, "speed up factor; 2.0 means 2X faster")
+	pitch := fs.Float64("p", 1, "pitch scaling factor; 1.3 means 30% higher")
+	rate := fs.Float64("r", 1, "playback rate; 2.0 means 2X faster, and 2X pitch")
+	volume := fs.Float64("v", 1, "scale volume by a constant factor")
+	quality := fs.Bool("q", false, "disable speed-up heuristics; may increase quality")
+	auto := fs.Bool("auto", false, "pick engine, quality and speed limit for speech or music content")
+	raw := fs.Bool("raw", false, "read and write raw samples instead of WAVE files")
+	sampleRate := fs.Int("samplerate", 44100, "sample rate of raw samples")
+	channels := fs.Int("channels", 1, "number of channels of raw samples")
+	format := fs.String("format", "s16", "format of raw samples: s16, s24, s32, f32, f64 or u8")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: sonic [options] infile outfile\n")
+		fmt.Fprintf(fs.Output(), "       sonic -raw [options] [infile [outfile]]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	opts := []sonic.Option{
+		sonic.WithSpeed(float32(*speed)),
+		sonic.WithPitch(float32(*pitch)),
+		sonic.WithRate(float32(*rate)),
+		sonic.WithVolume(float32(*volume)),
+	}
+	if *quality {
+		opts = append(opts, sonic.WithQuality())
+	}
+
+	if !*raw {
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("%w: infile and outfile are required", errUsage)
+		}
+		if *auto {
+			content, err := classifyFile(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stderr, "sonic: detected %v\n", content)
+			opts = append(opts, content.Options(float32(*speed))...)
+		}
+		return sonic.TransformFile(fs.Arg(0), fs.Arg(1), opts...)
+	}
+
+	if fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("%w: too many arguments", errUsage)
+	}
+	audioFormat, ok := rawFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q, want s16, s24, s32, f32, f64 or u8", *format)
+	}
+	in, out := stdin, stdout
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if name := fs.Arg(1); name != "" && name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		out = f
+	}
+	if *auto {
+		var head bytes.Buffer
+		c, err := sonic.Classify(io.TeeReader(in, &head), *sampleRate, *channels, audioFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "sonic: detected %v\n", c.Content)
+		opts = append(opts, c.Content.Options(float32(*speed))...)
+		in = io.MultiReader(&head, in)
+	}
+	opts = append(opts, sonic.WithChannels(*channels))
+	return transformRaw(in, out, *sampleRate, audioFormat, opts...)
+}
+
+// classifyFile classifies the beginning of the WAVE file name.
+func classifyFile(name string) (sonic.Content, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return sonic.ContentUnknown, err
+	}
+	defer f.Close()
+	d, err := wav.NewDecoder(bufio.NewReader(f))
+	if err != nil {
+		return sonic.ContentUnknown, fmt.Errorf("%s: %w", name, err)
+	}
+	c, err := sonic.Classify(d, d.SampleRate(), d.NumChannels(), sonic.AudioFormat(d.Format()))
+	if err != nil {
+		return sonic.ContentUnknown, err
+	}
+	return c.Content, nil
+}
+
+// transformRaw transforms the raw samples read from r and writes them to w. A trailing
+// incomplete frame is discarded.
+func transformRaw(r io.Reader, w io.Writer, sampleRate int, format sonic.AudioFormat, opts ...sonic.Option) error {
+	bw := bufio.NewWriter(w)
+	t, err := sonic.NewTransformer(bw, sampleRate, format, opts...)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	if _, err := t.ReadFrom(r); err != nil {
+		return err
+	}
+	if err := t.Flush(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
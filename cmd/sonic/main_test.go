@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// transform returns the output of a Transformer to which data is written at once.
+func transform(t *testing.T, sampleRate int, format sonic.AudioFormat, data []byte, opts ...sonic.Option) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	tr, err := sonic.NewTransformer(&out, sampleRate, format, opts...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestRun_Wave(t *testing.T) {
+	speech := pcm.EncodeInt16(nil, audiotest.Speech())
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.wav")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := wav.NewEncoder(f, audiotest.SpeechSampleRate, 1, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	if _, err := e.Write(speech); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	f.Close()
+
+	out := filepath.Join(dir, "out.wav")
+	var stderr bytes.Buffer
+	if err := run([]string{"-s", "2.0", "-p", "1.1", "-v", "0.5", "-q", in, out}, nil, nil, &stderr); err != nil {
+		t.Fatalf("run() error = %v, stderr = %q", err, stderr.String())
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := wav.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	if d.SampleRate() != audiotest.SpeechSampleRate || d.NumChannels() != 1 || d.Format() != wav.FormatPCM {
+		t.Errorf("output = %v, %d channels at %d Hz, want PCM, 1 channel at %d Hz", d.Format(), d.NumChannels(), d.SampleRate(), audiotest.SpeechSampleRate)
+	}
+	want := transform(t, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, speech,
+		sonic.WithSpeed(2), sonic.WithPitch(1.1), sonic.WithVolume(0.5), sonic.WithQuality())
+	if got := b[44:]; !bytes.Equal(got, want) {
+		t.Errorf("output = %d bytes, want the %d bytes of a Transformer", len(got), len(want))
+	}
+}
+
+func TestRun_Raw(t *testing.T) {
+	speech := audiotest.Speech()
+	stereo := make([]int16, 2*len(speech))
+	for i, v := range speech {
+		stereo[2*i], stereo[2*i+1] = v, -v
+	}
+	mono := pcm.EncodeInt16(nil, speech)
+	stereoFloat := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, stereo, pcm.Scaling32767))
+
+	tests := []struct {
+		name   string
+		args   []string
+		format sonic.AudioFormat
+		data   []byte
+		opts   []sonic.Option
+	}{
+		{
+			name:   "s16",
+			args:   []string{"-raw", "-samplerate", "24000", "-s", "1.5"},
+			format: sonic.AudioFormatPCM,
+			data:   mono,
+			opts:   []sonic.Option{sonic.WithSpeed(1.5)},
+		},
+		{
+			name:   "f32 stereo",
+			args:   []string{"-raw", "-samplerate", "24000", "-channels", "2", "-format", "f32", "-r", "0.8", "-", "-"},
+			format: sonic.AudioFormatIEEEFloat,
+			data:   stereoFloat,
+			opts:   []sonic.Option{sonic.WithChannels(2), sonic.WithRate(0.8)},
+		},
+		{
+			name:   "s24",
+			args:   []string{"-raw", "-samplerate", "24000", "-format", "s24", "-s", "0.7"},
+			format: sonic.AudioFormatPCM24,
+			data:   pcm.Int16ToInt24(nil, speech),
+			opts:   []sonic.Option{sonic.WithSpeed(0.7)},
+		},
+		{
+			name:   "f64",
+			args:   []string{"-raw", "-samplerate", "24000", "-format", "f64", "-p", "1.2"},
+			format: sonic.AudioFormatIEEEFloat64,
+			data:   pcm.Float32ToFloat64(nil, pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767)),
+			opts:   []sonic.Option{sonic.WithPitch(1.2)},
+		},
+		{
+			name:   "trailing partial frame",
+			args:   []string{"-raw", "-samplerate", "24000", "-p", "0.9"},
+			format: sonic.AudioFormatPCM,
+			data:   append(mono[:len(mono):len(mono)], 1),
+			opts:   []sonic.Option{sonic.WithPitch(0.9)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(tt.args, bytes.NewReader(tt.data), &stdout, &stderr); err != nil {
+				t.Fatalf("run() error = %v, stderr = %q", err, stderr.String())
+			}
+			data := tt.data[:len(tt.data)-len(tt.data)%tt.format.SampleSize()] // Whole frames of mono input
+			want := transform(t, 24000, tt.format, data, tt.opts...)
+			if !bytes.Equal(stdout.Bytes(), want) {
+				t.Errorf("output = %d bytes, want the %d bytes of a Transformer", stdout.Len(), len(want))
+			}
+		})
+	}
+}
+
+func TestRun_RawFiles(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.raw")
+	out := filepath.Join(dir, "out.raw")
+	data := pcm.EncodeInt16(nil, audiotest.Speech())
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-raw", "-samplerate", "24000", "-s", "2", in, out}, nil, nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := transform(t, 24000, sonic.AudioFormatPCM, data, sonic.WithSpeed(2)); !bytes.Equal(got, want) {
+		t.Errorf("output = %d bytes, want the %d bytes of a Transformer", len(got), len(want))
+	}
+}
+
+func TestRun_Auto(t *testing.T) {
+	speech := pcm.EncodeInt16(nil, audiotest.Speech())
+	want := transform(t, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, speech, sonic.ContentSpeech.Options(2)...)
+
+	t.Run("raw", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		args := []string{"-raw", "-samplerate", "48000", "-s", "2", "-auto"}
+		if err := run(args, bytes.NewReader(speech), &stdout, &stderr); err != nil {
+			t.Fatalf("run() error = %v, stderr = %q", err, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), "ContentSpeech") {
+			t.Errorf("stderr = %q, want the detected content", stderr.String())
+		}
+		if !bytes.Equal(stdout.Bytes(), want) {
+			t.Errorf("output = %d bytes, want the %d bytes of a Transformer", stdout.Len(), len(want))
+		}
+	})
+
+	t.Run("wave", func(t *testing.T) {
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.wav")
+		var b bytes.Buffer
+		e, err := wav.NewEncoder(&b, audiotest.SpeechSampleRate, 1, wav.FormatPCM)
+		if err != nil {
+			t.Fatalf("NewEncoder() error = %v", err)
+		}
+		e.Write(speech)
+		e.Close()
+		if err := os.WriteFile(in, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(dir, "out.wav")
+		var stderr bytes.Buffer
+		if err := run([]string{"-s", "2", "-auto", in, out}, nil, nil, &stderr); err != nil {
+			t.Fatalf("run() error = %v, stderr = %q", err, stderr.String())
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got[44:], want) {
+			t.Errorf("output = %d bytes, want the %d bytes of a Transformer", len(got)-44, len(want))
+		}
+	})
+}
+
+func TestRun_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    error
+		wantStderr string
+	}{
+		{"help", []string{"-h"}, flag.ErrHelp, "usage: sonic"},
+		{"unknown flag", []string{"-x"}, errUsage, "usage: sonic"},
+		{"missing outfile", []string{"in.wav"}, errUsage, "usage: sonic"},
+		{"too many raw files", []string{"-raw", "a", "b", "c"}, errUsage, "usage: sonic"},
+		{"missing infile", []string{filepath.Join(dir, "missing.wav"), filepath.Join(dir, "out.wav")}, fs.ErrNotExist, ""},
+		{"missing infile with -auto", []string{"-auto", filepath.Join(dir, "missing.wav"), filepath.Join(dir, "out.wav")}, fs.ErrNotExist, ""},
+		{"invalid sample rate", []string{"-raw", "-samplerate", "0"}, sonic.ErrInvalid, ""},
+		{"unknown format", []string{"-raw", "-format", "s20"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			err := run(tt.args, strings.NewReader(""), &bytes.Buffer{}, &stderr)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("run() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.wav")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := wav.NewEncoder(f, 8000, 1, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	e.Write(make([]byte, 1600))
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		want       int
+		wantStderr string
+	}{
+		{"wave", []string{"-s", "2", in, filepath.Join(dir, "out.wav")}, 0, ""},
+		{"raw", []string{"-raw", "-samplerate", "8000", "-s", "2"}, 0, ""},
+		{"help", []string{"-h"}, 0, "usage: sonic"},
+		{"unknown flag", []string{"-x"}, 2, "usage: sonic"},
+		{"missing outfile", []string{in}, 2, "usage: sonic"},
+		{"too many raw files", []string{"-raw", "a", "b", "c"}, 2, "usage: sonic"},
+		{"missing infile", []string{filepath.Join(dir, "missing.wav"), filepath.Join(dir, "out.wav")}, 1, "sonic: open "},
+		{"unknown format", []string{"-raw", "-format", "s20"}, 1, "sonic: unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			err := run(tt.args, bytes.NewReader(make([]byte, 1600)), &bytes.Buffer{}, &stderr)
+			if got := exitCode(err, &stderr); got != tt.want {
+				t.Errorf("exit code = %d, want %d (error %v)", got, tt.want, err)
+			}
+			if tt.wantStderr == "" && stderr.Len() > 0 || !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
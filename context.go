@@ -0,0 +1,52 @@
+package sonic
+
+import (
+	"context"
+	"fmt"
+)
+
+// WriteContext writes input samples to the transformer as Write does, but stops early if ctx is
+// done, e.g. when the deadline of a server request passes while a big file is transformed.
+//
+// ctx is checked before every chunk of at most BufferFrames frames is processed; the processing
+// of a chunk and a blocking writer are not interrupted. If ctx is done, WriteContext returns the
+// number of bytes consumed so far and an error wrapping ctx.Err(). The transformer stays usable:
+// writing the rest of p afterwards gives the same output as if it had not been interrupted.
+func (t *Transformer) WriteContext(ctx context.Context, p []byte) (int, error) {
+	t.ctx = ctx
+	defer func() { t.ctx = nil }()
+	return t.Write(p)
+}
+
+// FlushContext flushes the transformer as Flush does, but stops early if ctx is done.
+//
+// ctx is checked before the flush and between the chunks of output drained from the stream. If
+// ctx is done, FlushContext returns an error wrapping ctx.Err() and the output is incomplete;
+// calling Flush afterwards completes it.
+func (t *Transformer) FlushContext(ctx context.Context) error {
+	t.ctx = ctx
+	defer func() { t.ctx = nil }()
+	return t.Flush()
+}
+
+// contextErr returns an error wrapping the error of the context of WriteContext or FlushContext
+// if it is done, and nil otherwise.
+func (t *Transformer) contextErr() error {
+	if t.ctx == nil {
+		return nil
+	}
+	if err := t.ctx.Err(); err != nil {
+		return fmt.Errorf("processing canceled: %w", err)
+	}
+	return nil
+}
+
+// writeUninterrupted calls write with p without the context of WriteContext. It is used for
+// input held back by earlier calls, which was already reported as consumed and would be lost if
+// the write stopped early.
+func (t *Transformer) writeUninterrupted(write func([]byte) (int, error), p []byte) (int, error) {
+	ctx := t.ctx
+	t.ctx = nil
+	defer func() { t.ctx = ctx }()
+	return write(p)
+}
@@ -0,0 +1,120 @@
+package sonic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+func TestTransformer_WriteContext(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", []Option{WithSpeed(1.5)}},
+		{"aligned chunks", []Option{WithSpeed(1.5), WithAlignedChunks()}},
+		{"mid-side", []Option{WithSpeed(1.5), WithChannels(2), WithMidSide()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(speech); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			// The output function cancels the context once the first output is delivered.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var got bytes.Buffer
+			output := WithOutputFunc(func(p []byte) error {
+				got.Write(p)
+				cancel()
+				return nil
+			})
+			tr, err = NewTransformer(nil, audiotest.SpeechSampleRate, AudioFormatPCM, append(tt.opts, output)...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			n, err := tr.WriteContext(ctx, speech)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("WriteContext() error = %v, want %v", err, context.Canceled)
+			}
+			if n == 0 || n >= len(speech) {
+				t.Fatalf("WriteContext() = %d, want between 0 and %d", n, len(speech))
+			}
+			if stats := tr.Stats(); stats.InputBytes != int64(n) {
+				t.Errorf("InputBytes = %d, want %d", stats.InputBytes, n)
+			}
+
+			// Writing the rest gives the output of the uninterrupted transformer.
+			if _, err := tr.Write(speech[n:]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("output has %d bytes, want the %d bytes of the uninterrupted output", got.Len(), want.Len())
+			}
+		})
+	}
+}
+
+func TestTransformer_WriteContextDone(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(speech); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	var got bytes.Buffer
+	tr, err = NewTransformer(&got, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if n, err := tr.WriteContext(ctx, speech); n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WriteContext() = %d, %v, want 0, %v", n, err, context.DeadlineExceeded)
+	}
+	if got.Len() != 0 {
+		t.Errorf("output has %d bytes after a canceled write, want 0", got.Len())
+	}
+
+	if _, err := tr.WriteContext(context.Background(), speech); err != nil {
+		t.Fatalf("WriteContext() error = %v", err)
+	}
+	if err := tr.FlushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FlushContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// Flush completes the canceled flush.
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("output has %d bytes, want the %d bytes of the uninterrupted output", got.Len(), want.Len())
+	}
+}
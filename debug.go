@@ -0,0 +1,94 @@
+package sonic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+// DebugDumpEnv is the environment variable that enables the debug dump mode.
+//
+// If it is set to a directory and no WithDebugDump option is given, every Transformer
+// writes its chunks to that directory as if created with WithDebugDump(DebugDumpDir(dir)).
+const DebugDumpEnv = "SONIC_DEBUG_DUMP"
+
+// Debug dump stages
+const (
+	DebugStageInput  = "input"  // Samples written to the stream
+	DebugStageOutput = "output" // Samples written to the writer
+)
+
+// DebugChunk is a chunk of samples passed to a DebugDumpFunc.
+type DebugChunk struct {
+	Index       int         // Chunk number. Input and output of the same chunk share the index.
+	Stage       string      // DebugStageInput or DebugStageOutput
+	Format      AudioFormat // Sample format of Data
+	NumChannels int         // Number of interleaved channels in Data
+	Speed       float32     // Speed of the stream when the chunk was processed
+	Pitch       float32     // Pitch of the stream when the chunk was processed
+	Rate        float32     // Rate of the stream when the chunk was processed
+	Volume      float32     // Volume of the stream when the chunk was processed
+
+	// Data holds the little-endian samples of the chunk.
+	// It is only valid until the DebugDumpFunc returns.
+	Data []byte
+}
+
+// DebugDumpFunc receives chunks in debug dump mode. See WithDebugDump.
+type DebugDumpFunc func(chunk DebugChunk) error
+
+// DebugDumpDir returns a DebugDumpFunc that writes each chunk to a numbered raw file in dir,
+// e.g. "000003-input.raw", and appends the chunk parameters to "chunks.log" in dir.
+// The directory is created if it does not exist.
+func DebugDumpDir(dir string) DebugDumpFunc {
+	var mu sync.Mutex
+	return func(chunk DebugChunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%06d-%s.raw", chunk.Index, chunk.Stage)
+		if err := os.WriteFile(filepath.Join(dir, name), chunk.Data, 0644); err != nil {
+			return err
+		}
+
+		log, err := os.OpenFile(filepath.Join(dir, "chunks.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		_, err = fmt.Fprintf(log, "%s format=%v channels=%d bytes=%d speed=%v pitch=%v rate=%v volume=%v\n",
+			name, chunk.Format, chunk.NumChannels, len(chunk.Data), chunk.Speed, chunk.Pitch, chunk.Rate, chunk.Volume)
+		return err
+	}
+}
+
+// dump passes samples of the current chunk to the debug dump function, if any.
+func dump[T int16 | float32](t *Transformer, stage string, samples []T) error {
+	if t.debugDump == nil || len(samples) == 0 {
+		return nil
+	}
+	stream := t.stream
+	if stream == nil {
+		stream = t.midSide.mid
+	}
+	chunk := DebugChunk{
+		Index:       t.debugChunk,
+		Stage:       stage,
+		Format:      t.format.processFormat(),
+		NumChannels: t.streamChannels,
+		Speed:       stream.GetSpeed(),
+		Pitch:       stream.GetPitch(),
+		Rate:        stream.GetRate(),
+		Volume:      stream.GetVolume(),
+		Data:        unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), len(samples)*int(unsafe.Sizeof(samples[0]))),
+	}
+	if err := t.debugDump(chunk); err != nil {
+		return fmt.Errorf("%w: failed to dump debug chunk: %w", ErrWrite, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+func TestTransformer_DebugDump(t *testing.T) {
+	var chunks []DebugChunk
+	fn := func(chunk DebugChunk) error {
+		chunk.Data = append([]byte(nil), chunk.Data...) // Data is only valid during the call
+		chunks = append(chunks, chunk)
+		return nil
+	}
+
+	out := new(bytes.Buffer)
+	tr, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2.0), WithDebugDump(fn))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	in := audiotest.SpeechPCM()
+	if _, err := tr.Write(in); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	var gotIn, gotOut []byte
+	lastIndex := 0
+	for _, c := range chunks {
+		if c.Index < lastIndex {
+			t.Fatalf("chunk index went backwards: %d after %d", c.Index, lastIndex)
+		}
+		lastIndex = c.Index
+		if c.Format != AudioFormatPCM || c.NumChannels != 1 || c.Speed != 2.0 || c.Pitch != 1.0 {
+			t.Fatalf("unexpected chunk parameters: %+v", c)
+		}
+		switch c.Stage {
+		case DebugStageInput:
+			gotIn = append(gotIn, c.Data...)
+		case DebugStageOutput:
+			gotOut = append(gotOut, c.Data...)
+		default:
+			t.Fatalf("unexpected stage %q", c.Stage)
+		}
+	}
+	if !bytes.Equal(gotIn, in) {
+		t.Errorf("dumped input (%d bytes) differs from written input (%d bytes)", len(gotIn), len(in))
+	}
+	if !bytes.Equal(gotOut, out.Bytes()) {
+		t.Errorf("dumped output (%d bytes) differs from written output (%d bytes)", len(gotOut), out.Len())
+	}
+	if lastIndex < 2 {
+		t.Errorf("last chunk index = %d, want several chunks", lastIndex)
+	}
+}
+
+func TestTransformer_DebugDumpError(t *testing.T) {
+	errDump := errors.New("dump failed")
+	tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithDebugDump(func(DebugChunk) error {
+		return errDump
+	}))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	_, err = tr.Write(make([]byte, 100))
+	if !errors.Is(err, errDump) || !errors.Is(err, ErrWrite) {
+		t.Errorf("Write() error = %v, want %v wrapping %v", err, ErrWrite, errDump)
+	}
+}
+
+func TestDebugDumpEnv(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dump")
+	t.Setenv(DebugDumpEnv, dir)
+
+	tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	if _, err := tr.Write(make([]byte, 10000)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "000000-input.raw"))
+	if err != nil {
+		t.Fatalf("failed to read dumped chunk: %v", err)
+	}
+	if len(data) != streamBufferFrames*2 {
+		t.Errorf("dumped chunk size = %d, want %d", len(data), streamBufferFrames*2)
+	}
+	log, err := os.ReadFile(filepath.Join(dir, "chunks.log"))
+	if err != nil {
+		t.Fatalf("failed to read chunks.log: %v", err)
+	}
+	if !strings.HasPrefix(string(log), "000000-input.raw format=AudioFormatPCM channels=1 bytes=4096 speed=1") {
+		t.Errorf("unexpected chunks.log: %q", log)
+	}
+}
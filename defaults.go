@@ -0,0 +1,86 @@
+package sonic
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// Limits bounds the settings of every Transformer created by NewTransformer. See SetLimits.
+//
+// A zero bound means no limit. Settings outside the limits are clamped, including the implicit
+// default of 1 of unset speed, pitch, rate and volume.
+type Limits struct {
+	MinSpeed, MaxSpeed   float32
+	MinPitch, MaxPitch   float32
+	MinRate, MaxRate     float32
+	MinVolume, MaxVolume float32
+}
+
+// defaultOptions and limits hold the process-wide settings of SetDefaultOptions and SetLimits.
+var (
+	defaultOptions atomic.Pointer[[]Option]
+	limits         atomic.Pointer[Limits]
+)
+
+// SetDefaultOptions sets options that NewTransformer applies to every Transformer before the
+// options passed to it, which can override them. It replaces the options of previous calls;
+// without arguments it removes them.
+//
+// It is meant to be called from an init function, so that a platform can enforce a policy, e.g.
+// WithQuality(), in one place. Transformers created before the call are not affected.
+func SetDefaultOptions(opts ...Option) {
+	opts = slices.Clone(opts)
+	defaultOptions.Store(&opts)
+}
+
+// SetLimits sets limits that NewTransformer applies to every Transformer after all options.
+// Unlike default options, limits cannot be overridden per Transformer. The zero Limits removes
+// all limits. Transformers created before the call are not affected.
+func SetLimits(l Limits) {
+	limits.Store(&l)
+}
+
+// DefaultOptions returns the options set with SetDefaultOptions.
+func DefaultOptions() []Option {
+	if opts := defaultOptions.Load(); opts != nil {
+		return slices.Clone(*opts)
+	}
+	return nil
+}
+
+// CurrentLimits returns the limits set with SetLimits.
+func CurrentLimits() Limits {
+	if l := limits.Load(); l != nil {
+		return *l
+	}
+	return Limits{}
+}
+
+// applyLimits clamps the settings of t to the limits set with SetLimits.
+func (t *Transformer) applyLimits() {
+	l := CurrentLimits()
+	t.speed = limitSetting(t.speed, l.MinSpeed, l.MaxSpeed)
+	t.pitch = limitSetting(t.pitch, l.MinPitch, l.MaxPitch)
+	t.rate = limitSetting(t.rate, l.MinRate, l.MaxRate)
+	t.volume = limitSetting(t.volume, l.MinVolume, l.MaxVolume)
+}
+
+// limitSetting returns value, or 1 if it is nil, clamped to [lo, hi], where a zero bound means
+// no limit. It returns value itself if it is within the limits.
+func limitSetting(value *float32, lo, hi float32) *float32 {
+	v := float32(1)
+	if value != nil {
+		v = *value
+	}
+	limited := v
+	if lo > 0 && limited < lo {
+		limited = lo
+	}
+	if hi > 0 && limited > hi {
+		limited = hi
+	}
+	if limited == v {
+		return value
+	}
+	return &limited
+}
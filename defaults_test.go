@@ -0,0 +1,102 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSetDefaultOptions(t *testing.T) {
+	t.Cleanup(func() { SetDefaultOptions() })
+
+	SetDefaultOptions(WithQuality(), WithSpeed(2))
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantSpeed   float32
+		wantQuality int
+	}{
+		{"defaults", nil, 2, 1},
+		{"override", []Option{WithSpeed(1.5)}, 1.5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if *tr.speed != tt.wantSpeed || *tr.quality != tt.wantQuality {
+				t.Errorf("speed = %v, quality = %v, want %v and %v", *tr.speed, *tr.quality, tt.wantSpeed, tt.wantQuality)
+			}
+			if got := tr.stream.GetSpeed(); got != tt.wantSpeed {
+				t.Errorf("stream speed = %v, want %v", got, tt.wantSpeed)
+			}
+		})
+	}
+
+	SetDefaultOptions(WithEngine(nil))
+	if _, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewTransformer() with invalid default option error = %v, want %v", err, ErrInvalid)
+	}
+
+	SetDefaultOptions()
+	if got := DefaultOptions(); got != nil {
+		t.Errorf("DefaultOptions() = %v, want nil", got)
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	t.Cleanup(func() { SetLimits(Limits{}) })
+
+	SetLimits(Limits{MaxSpeed: 3, MinPitch: 1.2, MaxVolume: 2})
+	if got := CurrentLimits(); got.MaxSpeed != 3 || got.MinPitch != 1.2 || got.MaxVolume != 2 {
+		t.Errorf("CurrentLimits() = %+v", got)
+	}
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantSpeed  *float32
+		wantPitch  *float32
+		wantVolume *float32
+	}{
+		{"within", []Option{WithSpeed(2), WithPitch(1.5), WithVolume(0.5)}, ptr(float32(2)), ptr(float32(1.5)), ptr(float32(0.5))},
+		{"clamped", []Option{WithSpeed(10), WithPitch(0.5), WithVolume(50)}, ptr(float32(3)), ptr(float32(1.2)), ptr(float32(2))},
+		{"unset", nil, nil, ptr(float32(1.2)), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			for _, c := range []struct {
+				name      string
+				got, want *float32
+			}{
+				{"speed", tr.speed, tt.wantSpeed},
+				{"pitch", tr.pitch, tt.wantPitch},
+				{"volume", tr.volume, tt.wantVolume},
+			} {
+				if (c.got == nil) != (c.want == nil) || (c.got != nil && *c.got != *c.want) {
+					t.Errorf("%s = %v, want %v", c.name, deref(c.got), deref(c.want))
+				}
+			}
+		})
+	}
+
+	SetLimits(Limits{})
+	if got := CurrentLimits(); got != (Limits{}) {
+		t.Errorf("CurrentLimits() = %+v, want no limits", got)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func deref(p *float32) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,65 @@
+package sonic
+
+import "fmt"
+
+// Dither represents the dither applied when float samples are quantized to an integer output
+// format. See WithDither.
+type Dither int
+
+// Constants for dither types
+const (
+	DitherNone Dither = iota // Round to the nearest step
+	DitherTPDF               // Add triangular noise of ±1 step before rounding
+)
+
+// String returns the string representation of the Dither.
+func (d Dither) String() string {
+	m := map[Dither]string{
+		DitherNone: "DitherNone",
+		DitherTPDF: "DitherTPDF",
+	}
+	if s, ok := m[d]; ok {
+		return s
+	}
+	return fmt.Sprintf("Dither(%d)", d)
+}
+
+// Values returns the all possible values of Dither.
+func (Dither) Values() []Dither {
+	return []Dither{
+		DitherNone,
+		DitherTPDF,
+	}
+}
+
+// ditherSeed is the initial state of the dither noise generator.
+const ditherSeed = 0x9e3779b9
+
+// ditherRand returns the next uniform random number in [0, 1) from the xorshift generator of t.
+func (t *Transformer) ditherRand() float32 {
+	x := t.ditherState
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	t.ditherState = x
+	return float32(x>>8) / (1 << 24)
+}
+
+// ditherFloat32 adds the dither noise for the output format to samples in place. Float output is
+// not quantized and left as it is.
+func (t *Transformer) ditherFloat32(samples []float32) {
+	var step float32 // Step of the output format in float units
+	switch t.outFormat {
+	case AudioFormatPCM:
+		step = 1.0 / 32767
+	case AudioFormatU8:
+		step = 256.0 / 32767
+	case AudioFormatPCM24:
+		step = 1.0 / 8388607
+	default:
+		return
+	}
+	for i := range samples {
+		samples[i] += (t.ditherRand() - t.ditherRand()) * step
+	}
+}
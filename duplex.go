@@ -0,0 +1,150 @@
+package sonic
+
+import (
+	"io"
+	"sync"
+)
+
+// Duplex transforms the samples written to it and returns the output from Read, on one object
+// without a destination writer, e.g. for relay servers that own both directions of a connection
+// like a net.Conn.
+//
+// Write and Read may be called from different goroutines: Read blocks until output is available,
+// and returns io.EOF once CloseWrite has flushed the transformer and all output has been read.
+// The output is buffered without limit until it is read, so the reader should keep up with the
+// writer. Writes must not be issued concurrently with each other, nor reads with each other.
+type Duplex struct {
+	t      *Transformer
+	wmu    sync.Mutex // Serializes the calls of t
+	mu     sync.Mutex
+	cond   *sync.Cond // Signaled when output arrives or the duplex is closed
+	out    []byte     // Output not read yet, starting at off
+	off    int
+	eof    bool // Whether CloseWrite flushed the transformer
+	closed bool
+}
+
+// NewDuplex creates a duplex that transforms the samples written to it, which are in format at
+// sampleRate.
+//
+// opts configure the underlying Transformer as for NewTransformer; WithOutputFunc is overridden,
+// since the duplex returns the output itself.
+func NewDuplex(sampleRate int, format AudioFormat, opts ...Option) (*Duplex, error) {
+	d := &Duplex{}
+	d.cond = sync.NewCond(&d.mu)
+	opts = append(opts[:len(opts):len(opts)], WithOutputFunc(d.receive))
+	t, err := NewTransformer(nil, sampleRate, format, opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.t = t
+	return d, nil
+}
+
+// receive collects the output of the transformer and wakes up a waiting Read.
+func (d *Duplex) receive(p []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.off > 0 && d.off >= len(d.out)/2 {
+		// Reclaim the output read so far, so that the buffer does not grow with a reader that
+		// keeps up but never drains it completely.
+		d.out, d.off = d.out[:copy(d.out, d.out[d.off:])], 0
+	}
+	d.out = append(d.out, p...)
+	d.cond.Broadcast()
+	return nil
+}
+
+// Write writes input samples to the transformer, as Transformer.Write does. It returns
+// io.ErrClosedPipe after CloseWrite or Close.
+func (d *Duplex) Write(p []byte) (int, error) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	if d.isShut() {
+		return 0, io.ErrClosedPipe
+	}
+	return d.t.Write(p)
+}
+
+// Flush flushes the transformer, so that Read returns all output of the input written so far.
+// It returns io.ErrClosedPipe after CloseWrite or Close.
+func (d *Duplex) Flush() error {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	if d.isShut() {
+		return io.ErrClosedPipe
+	}
+	return d.t.Flush()
+}
+
+// CloseWrite flushes the transformer and ends the input, so that Read returns io.EOF after the
+// remaining output. Writing is not possible afterwards; calling CloseWrite again returns nil.
+func (d *Duplex) CloseWrite() error {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	if d.isShut() {
+		return nil
+	}
+	err := d.t.Flush()
+	d.mu.Lock()
+	d.eof = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	return err
+}
+
+// isShut reports whether CloseWrite or Close was called.
+func (d *Duplex) isShut() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.eof || d.closed
+}
+
+// Read reads transformed output into p, blocking until some is available. It returns io.EOF
+// once CloseWrite was called and all output has been read, and io.ErrClosedPipe after Close.
+func (d *Duplex) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.off == len(d.out) {
+		switch {
+		case d.closed:
+			return 0, io.ErrClosedPipe
+		case d.eof:
+			return 0, io.EOF
+		}
+		d.cond.Wait()
+	}
+	n := copy(p, d.out[d.off:])
+	d.off += n
+	if d.off == len(d.out) {
+		d.out, d.off = d.out[:0], 0
+	}
+	return n, nil
+}
+
+// Transformer returns the underlying transformer, e.g. to call Update. It must not be written
+// to directly, and its methods must not be called concurrently with Write.
+func (d *Duplex) Transformer() *Transformer {
+	return d.t
+}
+
+// Close releases the resources of the duplex and discards the output not read yet. A Read
+// blocked in another goroutine returns io.ErrClosedPipe. Calling Close again returns nil.
+func (d *Duplex) Close() error {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	d.mu.Lock()
+	closed := d.closed
+	d.closed, d.out, d.off = true, nil, 0
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	if closed {
+		return nil
+	}
+	// The input not flushed yet is discarded with the output.
+	d.t.release()
+	return nil
+}
@@ -0,0 +1,150 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestDuplex(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	mono := pcm.EncodeInt16(nil, speech)
+	stereo := pcm.EncodeInt16(nil, Interleave(nil, speech, speech))
+
+	tests := []struct {
+		name  string
+		input []byte
+		opts  []Option
+		chunk int // Size of the writes
+		size  int // Size of the buffer passed to Read
+	}{
+		{"speed", mono, []Option{WithSpeed(1.5)}, 4096, 4096},
+		{"stereo", stereo, []Option{WithChannels(2), WithSpeed(0.8)}, 1000, 4096},
+		{"float output", mono, []Option{WithSpeed(2), WithOutputFormat(AudioFormatIEEEFloat)}, 4096, 4096},
+		{"odd buffer", stereo, []Option{WithChannels(2), WithSpeed(1.5)}, 4096, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			for chunk := range slices.Chunk(tt.input, tt.chunk) {
+				if _, err := tr.Write(chunk); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			d, err := NewDuplex(audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewDuplex() error = %v", err)
+			}
+			defer d.Close()
+			done := make(chan error)
+			go func() {
+				for chunk := range slices.Chunk(tt.input, tt.chunk) {
+					if _, err := d.Write(chunk); err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- d.CloseWrite()
+			}()
+			var got []byte
+			buf := make([]byte, tt.size)
+			for {
+				n, err := d.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("writer error = %v", err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("output = %d bytes, want the %d bytes of a Transformer", len(got), want.Len())
+			}
+			if _, err := d.Write(tt.input); !errors.Is(err, io.ErrClosedPipe) {
+				t.Errorf("Write() after CloseWrite error = %v, want %v", err, io.ErrClosedPipe)
+			}
+			if err := d.CloseWrite(); err != nil {
+				t.Errorf("second CloseWrite() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDuplex_Flush(t *testing.T) {
+	d, err := NewDuplex(8000, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewDuplex() error = %v", err)
+	}
+	defer d.Close()
+	input := pcm.EncodeInt16(nil, sineInt16(200, 8000, 800))
+	if _, err := d.Write(input); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	// At speed 1, all input is output after the flush, and can be read without blocking.
+	got := make([]byte, len(input))
+	if _, err := io.ReadFull(d, got); err != nil {
+		t.Fatalf("ReadFull() error = %v", err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Error("output differs from the input at speed 1")
+	}
+}
+
+func TestDuplex_Close(t *testing.T) {
+	if _, err := NewDuplex(8000, AudioFormatPCM, WithFloatClipping(FloatClipping(42))); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewDuplex() with invalid options error = %v, want %v", err, ErrInvalid)
+	}
+
+	d, err := NewDuplex(8000, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewDuplex() error = %v", err)
+	}
+	// A blocked Read returns when the duplex is closed.
+	done := make(chan error)
+	go func() {
+		_, err := d.Read(make([]byte, 100))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Read() returned %v without output", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-done; !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("blocked Read() error = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if _, err := d.Write(make([]byte, 100)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write() after Close error = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if err := d.Flush(); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Flush() after Close error = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
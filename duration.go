@@ -0,0 +1,80 @@
+package sonic
+
+import (
+	"math"
+	"time"
+)
+
+// SamplesForDuration returns the number of interleaved samples, i.e. frames times numChannels,
+// that make up d of audio at sampleRate. Partial frames are truncated.
+func SamplesForDuration(d time.Duration, sampleRate, numChannels int) int {
+	if d <= 0 || sampleRate <= 0 || numChannels <= 0 {
+		return 0
+	}
+	// Split d to avoid overflowing int64 for long durations.
+	frames := int64(d/time.Second)*int64(sampleRate) + int64(d%time.Second)*int64(sampleRate)/int64(time.Second)
+	return int(frames) * numChannels
+}
+
+// DurationForSamples returns the playback duration of n interleaved samples at sampleRate.
+// Samples of an incomplete trailing frame are ignored.
+func DurationForSamples(n, sampleRate, numChannels int) time.Duration {
+	if n <= 0 || sampleRate <= 0 || numChannels <= 0 {
+		return 0
+	}
+	frames := int64(n / numChannels)
+	rate := int64(sampleRate)
+	return time.Duration(frames/rate)*time.Second + time.Duration(frames%rate)*time.Second/time.Duration(rate)
+}
+
+// SamplesForDuration returns the number of interleaved input samples that make up d of audio,
+// using the sample rate and number of channels of the transformer.
+func (t *Transformer) SamplesForDuration(d time.Duration) int {
+	return SamplesForDuration(d, t.sampleRate, t.numChannels)
+}
+
+// DurationForSamples returns the playback duration of n interleaved input samples, using the
+// sample rate and number of channels of the transformer.
+func (t *Transformer) DurationForSamples(n int) time.Duration {
+	return DurationForSamples(n, t.sampleRate, t.numChannels)
+}
+
+// OutputSamplesForInput returns the number of interleaved samples the transformer writes for n
+// interleaved input samples, using the configured speed and rate. It takes channel selection
+// and the output channels into account, and does not include the latency reported by InputPending.
+//
+// The actual output is usually within a few percent of it. libsonic changes the speed in whole
+// pitch periods, so at extreme settings and low sample rates the output can differ more, by up
+// to about 25% at 1000 Hz.
+func (t *Transformer) OutputSamplesForInput(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	frames := float64(n/t.numChannels) / t.timeScale()
+	return int(math.Round(frames)) * t.outChannels
+}
+
+// InputSamplesForOutput is the inverse of OutputSamplesForInput. It returns the number of
+// interleaved input samples needed to produce n interleaved output samples.
+func (t *Transformer) InputSamplesForOutput(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	frames := float64(n/t.outChannels) * t.timeScale()
+	return int(math.Round(frames)) * t.numChannels
+}
+
+// timeScale returns the factor by which the transformer shortens the audio, in samples.
+func (t *Transformer) timeScale() float64 {
+	scale := 1.0
+	if t.speed != nil {
+		scale *= float64(*t.speed)
+	}
+	if t.rate != nil && !t.nominalRate {
+		scale *= float64(*t.rate)
+	}
+	if t.outRate > 0 {
+		scale *= float64(t.naturalSampleRate()) / float64(t.outRate) // See WithOutputSampleRate
+	}
+	return scale
+}
@@ -0,0 +1,90 @@
+package sonic
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSamplesForDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		d           time.Duration
+		sampleRate  int
+		numChannels int
+		want        int
+	}{
+		{"one second mono", time.Second, 44100, 1, 44100},
+		{"one second stereo", time.Second, 44100, 2, 88200},
+		{"20ms stereo", 20 * time.Millisecond, 48000, 2, 1920},
+		{"partial frame truncated", time.Microsecond * 30, 44100, 2, 2},
+		{"zero", 0, 44100, 2, 0},
+		{"negative", -time.Second, 44100, 2, 0},
+		{"long duration", 24 * time.Hour, 96000, 2, 24 * 3600 * 96000 * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SamplesForDuration(tt.d, tt.sampleRate, tt.numChannels); got != tt.want {
+				t.Errorf("SamplesForDuration() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationForSamples(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           int
+		sampleRate  int
+		numChannels int
+		want        time.Duration
+	}{
+		{"one second mono", 44100, 44100, 1, time.Second},
+		{"one second stereo", 88200, 44100, 2, time.Second},
+		{"20ms stereo", 1920, 48000, 2, 20 * time.Millisecond},
+		{"incomplete frame ignored", 1921, 48000, 2, 20 * time.Millisecond},
+		{"zero", 0, 44100, 2, 0},
+		{"long duration", 24 * 3600 * 96000 * 2, 96000, 2, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DurationForSamples(tt.n, tt.sampleRate, tt.numChannels); got != tt.want {
+				t.Errorf("DurationForSamples() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformer_SampleConversions(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		input      int
+		wantOutput int
+	}{
+		{"default", nil, 48000, 48000},
+		{"speed 2", []Option{WithSpeed(2)}, 48000, 24000},
+		{"speed and rate", []Option{WithSpeed(2), WithRate(2)}, 48000, 12000},
+		{"stereo speed 0.5", []Option{WithChannels(2), WithSpeed(0.5)}, 96000, 192000},
+		{"select one of two channels", []Option{WithChannels(2), WithSelectChannels(0), WithSpeed(2)}, 96000, 24000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(&bytes.Buffer{}, 48000, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			if got := tr.OutputSamplesForInput(tt.input); got != tt.wantOutput {
+				t.Errorf("OutputSamplesForInput(%d) = %d, want %d", tt.input, got, tt.wantOutput)
+			}
+			if got := tr.InputSamplesForOutput(tt.wantOutput); got != tt.input {
+				t.Errorf("InputSamplesForOutput(%d) = %d, want %d", tt.wantOutput, got, tt.input)
+			}
+			if got := tr.DurationForSamples(tr.SamplesForDuration(time.Second)); got != time.Second {
+				t.Errorf("DurationForSamples(SamplesForDuration(1s)) = %v, want 1s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package sonic
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransientEmphasis_Silence(t *testing.T) {
+	e := newTransientEmphasis(44100, 1, 1.0)
+	samples := make([]int16, 1024)
+	e.processInt16(samples)
+	for i, s := range samples {
+		if s != 0 {
+			t.Fatalf("sample %d = %d, want 0", i, s)
+		}
+	}
+}
+
+func TestTransientEmphasis_SteadyToneUnchanged(t *testing.T) {
+	const sampleRate = 44100
+	e := newTransientEmphasis(sampleRate, 1, 1.0)
+
+	// Let the envelopes settle on a steady tone, then check that the tone passes nearly untouched.
+	samples := make([]float32, sampleRate/2)
+	for i := range samples {
+		samples[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/sampleRate))
+	}
+	original := append([]float32(nil), samples...)
+	e.processFloat32(samples)
+
+	maxDiff := float32(0)
+	for i := sampleRate / 4; i < len(samples); i++ {
+		maxDiff = max(maxDiff, float32(math.Abs(float64(samples[i]-original[i]))))
+	}
+	if maxDiff > 0.01 {
+		t.Errorf("steady tone changed by %f, want <= 0.01", maxDiff)
+	}
+}
+
+func TestTransientEmphasis_BoostsOnset(t *testing.T) {
+	const sampleRate = 44100
+	e := newTransientEmphasis(sampleRate, 1, 1.0)
+
+	// Quiet low-frequency background followed by a sudden high-frequency burst.
+	samples := make([]float32, sampleRate/4)
+	for i := range samples {
+		samples[i] = float32(0.01 * math.Sin(2*math.Pi*100*float64(i)/sampleRate))
+	}
+	onset := sampleRate / 8
+	for i := onset; i < onset+64; i++ {
+		samples[i] += float32(0.2 * math.Sin(2*math.Pi*4000*float64(i)/sampleRate))
+	}
+	original := append([]float32(nil), samples...)
+	e.processFloat32(samples)
+
+	energy := func(s []float32) float64 {
+		sum := 0.0
+		for _, v := range s {
+			sum += float64(v) * float64(v)
+		}
+		return sum
+	}
+	if got, orig := energy(samples[onset:onset+64]), energy(original[onset:onset+64]); got <= orig*1.1 {
+		t.Errorf("onset energy = %f, want > %f", got, orig*1.1)
+	}
+}
+
+func TestTransientEmphasis_MultiChannelIndependent(t *testing.T) {
+	e := newTransientEmphasis(44100, 2, 1.0)
+
+	// Burst only on the right channel; the silent left channel must stay silent.
+	samples := make([]int16, 2048)
+	for i := 1024; i < len(samples); i += 2 {
+		if (i/2)%2 == 0 {
+			samples[i+1] = 20000
+		} else {
+			samples[i+1] = -20000
+		}
+	}
+	e.processInt16(samples)
+	for i := 0; i < len(samples); i += 2 {
+		if samples[i] != 0 {
+			t.Fatalf("left channel sample %d = %d, want 0", i/2, samples[i])
+		}
+	}
+}
+
+func TestSaturateInt16(t *testing.T) {
+	tests := []struct {
+		input    float32
+		expected int16
+	}{
+		{0, 0},
+		{1.4, 1},
+		{1.5, 2},
+		{-1.5, -2},
+		{40000, math.MaxInt16},
+		{-40000, math.MinInt16},
+	}
+	for _, tt := range tests {
+		if got := saturateInt16(tt.input); got != tt.expected {
+			t.Errorf("saturateInt16(%f) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTransformer_WithConsonantEmphasis(t *testing.T) {
+	out := new(bytes.Buffer)
+	tr, err := NewTransformer(out, 44100, AudioFormatPCM, WithSpeed(0.5), WithConsonantEmphasis(1.0))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if tr.emphasizer == nil {
+		t.Fatal("emphasizer is nil, want non-nil")
+	}
+
+	input := make([]int16, 44100)
+	for i := range input {
+		input[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/44100))
+	}
+	if _, err := tr.Write(pcm.EncodeInt16(nil, input)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if out.Len() == 0 {
+		t.Error("output is empty")
+	}
+}
@@ -0,0 +1,40 @@
+package sonic
+
+import "github.com/nakat-t/sonic-go/internal/cgosonic"
+
+// Engine is a time-stretching algorithm a Transformer can use.
+//
+// An engine creates the streams the transformer writes samples to and reads processed samples
+// from. All options of the transformer are applied through the Stream interface, so an engine
+// plugs in behind the same Writer and Options API; an engine may ignore parameters it does not
+// support, e.g. the quality flag. Use WithEngine to select an engine.
+type Engine interface {
+	// NewStream creates a stream for numChannels interleaved channels at sampleRate.
+	NewStream(sampleRate, numChannels int) (Stream, error)
+}
+
+// EngineSonic is the libsonic engine. Its PICOLA-style algorithm is optimized for speech,
+// especially for speed-ups beyond 2X.
+var EngineSonic Engine = sonicEngine{}
+
+// sonicEngine creates libsonic streams.
+type sonicEngine struct{}
+
+// NewStream implements Engine.
+func (sonicEngine) NewStream(sampleRate, numChannels int) (Stream, error) {
+	stream, err := cgosonic.CreateStream(sampleRate, numChannels)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// String returns the name of the engine.
+func (sonicEngine) String() string {
+	return "sonic"
+}
+
+// NewStream implements Engine, so that a StreamFactory can be used as an engine.
+func (f StreamFactory) NewStream(sampleRate, numChannels int) (Stream, error) {
+	return f(sampleRate, numChannels)
+}
@@ -0,0 +1,44 @@
+package sonic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+func TestEngineSonic(t *testing.T) {
+	transform := func(t *testing.T, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, append(opts, WithSpeed(1.5))...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(audiotest.SpeechPCM()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		return out.Bytes()
+	}
+
+	want := transform(t)
+	if got := transform(t, WithEngine(EngineSonic)); !bytes.Equal(got, want) {
+		t.Error("output with WithEngine(EngineSonic) differs from the default output")
+	}
+
+	var streams int
+	counting := StreamFactory(func(sampleRate, numChannels int) (Stream, error) {
+		streams++
+		return EngineSonic.NewStream(sampleRate, numChannels)
+	})
+	if got := transform(t, WithEngine(counting)); !bytes.Equal(got, want) {
+		t.Error("output with a wrapping engine differs from the default output")
+	}
+	if streams != 1 {
+		t.Errorf("engine created %d streams, want 1", streams)
+	}
+}
@@ -0,0 +1,167 @@
+// Stress runs many concurrent transforms and records CPU and heap profiles.
+//
+// It serves both as a load-test harness for sonic-go and as a template for diagnosing
+// CPU hot spots in your own deployment. Every job runs under pprof labels, so profiles
+// can be sliced per job or per parameter set:
+//
+//	go run ./examples/stress -jobs 64 -concurrency 8 -cpuprofile cpu.pprof -memprofile mem.pprof
+//
+//	# Where is the time spent overall?
+//	go tool pprof -top cpu.pprof
+//
+//	# Only samples from jobs running at speed 3.0
+//	go tool pprof -tagfocus speed=3.0 -top cpu.pprof
+//
+//	# Compare formats side by side
+//	go tool pprof -tags cpu.pprof
+//
+// Time spent inside libsonic shows up under the cgo call frames
+// (e.g. _Cfunc_sonicWriteShortToStream), while Go-side costs such as
+// sample conversion and writing to the destination show up under
+// (*Transformer).Write and (*Transformer).Flush.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func main() {
+	jobs := flag.Int("jobs", 32, "number of transform jobs to run")
+	concurrency := flag.Int("concurrency", runtime.GOMAXPROCS(0), "number of jobs running at the same time")
+	repeat := flag.Int("repeat", 4, "number of times the speech corpus is fed to each job")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file")
+	flag.Parse()
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	pcm := bytes.Repeat(audiotest.SpeechPCM(), *repeat)
+	float := pcmToFloat(pcm)
+	speeds := []float32{0.5, 1.5, 2.0, 3.0}
+
+	start := time.Now()
+	sem := make(chan struct{}, max(*concurrency, 1))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed int
+	var outBytes int64
+
+	for i := range *jobs {
+		speed := speeds[i%len(speeds)]
+		format := sonic.AudioFormatPCM
+		input := pcm
+		if i%2 == 1 {
+			format = sonic.AudioFormatIEEEFloat
+			input = float
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			labels := pprof.Labels(
+				"job", strconv.Itoa(i),
+				"speed", strconv.FormatFloat(float64(speed), 'f', 1, 32),
+				"format", format.String(),
+			)
+			pprof.Do(context.Background(), labels, func(context.Context) {
+				n, err := runJob(input, format, speed)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "job %d: %v\n", i, err)
+					failed++
+					return
+				}
+				outBytes += n
+			})
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	mediaSeconds := float64(len(pcm)) / 2 / audiotest.SpeechSampleRate * float64(*jobs)
+	fmt.Printf("jobs: %d (failed %d), concurrency: %d\n", *jobs, failed, *concurrency)
+	fmt.Printf("elapsed: %v, output: %d bytes\n", elapsed.Round(time.Millisecond), outBytes)
+	fmt.Printf("processed %.1fs of audio at %.1fx realtime\n", mediaSeconds, mediaSeconds/elapsed.Seconds())
+
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runJob transforms input once and returns the number of output bytes.
+func runJob(input []byte, format sonic.AudioFormat, speed float32) (int64, error) {
+	counter := &countingWriter{}
+	transformer, err := sonic.NewTransformer(counter, audiotest.SpeechSampleRate, format, sonic.WithSpeed(speed))
+	if err != nil {
+		return 0, err
+	}
+	defer transformer.Close()
+
+	if _, err := io.Copy(transformer, bytes.NewReader(input)); err != nil {
+		return 0, err
+	}
+	if err := transformer.Flush(); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// countingWriter discards its input and counts the bytes written.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// pcmToFloat converts 16-bit little-endian PCM to 32-bit little-endian float.
+func pcmToFloat(data []byte) []byte {
+	samples := pcm.DecodeInt16(nil, data)
+	return pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, samples, pcm.Scaling32767))
+}
@@ -0,0 +1,89 @@
+package sonic
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+const (
+	maxFadeIn  = 10 * time.Second
+	maxFadeOut = 10 * time.Second
+)
+
+// applyFadeIn fades in the first output since the start or the last Flush linearly from silence.
+// p is scaled in place.
+func (t *Transformer) applyFadeIn(p []byte) {
+	frameSize := t.OutputFrameSize()
+	for i := 0; i+frameSize <= len(p) && t.fadeInPos < t.fadeInFrames; i += frameSize {
+		t.scaleFrame(p[i:i+frameSize], float32(t.fadeInPos)/float32(t.fadeInFrames))
+		t.fadeInPos++
+	}
+}
+
+// writeDelayed writes p to the output, holding back the last output of the length of the
+// fade-out in fadeTail until it is faded out by flushFade.
+func (t *Transformer) writeDelayed(p []byte) error {
+	if excess := len(t.fadeTail) + len(p) - cap(t.fadeTail); excess > 0 {
+		fromTail := min(excess, len(t.fadeTail))
+		if err := t.queueOutput(t.fadeTail[:fromTail]); err != nil {
+			return err
+		}
+		t.fadeTail = t.fadeTail[:copy(t.fadeTail, t.fadeTail[fromTail:])]
+		if err := t.queueOutput(p[:excess-fromTail]); err != nil {
+			return err
+		}
+		p = p[excess-fromTail:]
+	}
+	t.fadeTail = append(t.fadeTail, p...)
+	return nil
+}
+
+// flushFade fades out the held back output linearly to silence and writes it, and restarts the
+// fade-in for the output after the Flush.
+func (t *Transformer) flushFade() error {
+	t.fadeInPos = 0
+	if len(t.fadeTail) == 0 {
+		return nil
+	}
+	frameSize := t.OutputFrameSize()
+	numFrames := len(t.fadeTail) / frameSize
+	for i := range numFrames {
+		gain := float32(0)
+		if numFrames > 1 {
+			gain = float32(numFrames-1-i) / float32(numFrames-1)
+		}
+		t.scaleFrame(t.fadeTail[i*frameSize:(i+1)*frameSize], gain)
+	}
+	err := t.queueOutput(t.fadeTail)
+	t.fadeTail = t.fadeTail[:0]
+	return err
+}
+
+// scaleFrame scales the little-endian output samples of frame in place by gain.
+func (t *Transformer) scaleFrame(frame []byte, gain float32) {
+	sampleSize := t.outFormat.SampleSize()
+	for j := 0; j < len(frame); j += sampleSize {
+		switch t.outFormat {
+		case AudioFormatPCM:
+			s := int16(binary.LittleEndian.Uint16(frame[j:]))
+			binary.LittleEndian.PutUint16(frame[j:], uint16(saturateInt16(float32(s)*gain)))
+		case AudioFormatIEEEFloat:
+			s := math.Float32frombits(binary.LittleEndian.Uint32(frame[j:]))
+			binary.LittleEndian.PutUint32(frame[j:], math.Float32bits(s*gain))
+		case AudioFormatU8:
+			s := saturateInt16(float32(int(frame[j])-128) * 256 * gain)
+			frame[j] = uint8(s>>8) + 128
+		case AudioFormatPCM24:
+			s := float32(int32(uint32(frame[j])<<8|uint32(frame[j+1])<<16|uint32(frame[j+2])<<24) >> 8)
+			v := int32(math.Round(math.Max(-1<<23, math.Min(1<<23-1, float64(s*gain)))))
+			frame[j], frame[j+1], frame[j+2] = byte(v), byte(v>>8), byte(v>>16)
+		case AudioFormatPCM32:
+			s := float64(int32(binary.LittleEndian.Uint32(frame[j:])))
+			binary.LittleEndian.PutUint32(frame[j:], uint32(int32(math.Round(math.Max(math.MinInt32, math.Min(math.MaxInt32, s*float64(gain)))))))
+		case AudioFormatIEEEFloat64:
+			s := math.Float64frombits(binary.LittleEndian.Uint64(frame[j:]))
+			binary.LittleEndian.PutUint64(frame[j:], math.Float64bits(s*float64(gain)))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package sonic
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_FadeOut(t *testing.T) {
+	const sampleRate = audiotest.SpeechSampleRate
+
+	tests := []struct {
+		name        string
+		format      AudioFormat
+		chunkFrames int
+		fade        time.Duration
+	}{
+		{"int16", AudioFormatPCM, 4096, 100 * time.Millisecond},
+		{"float32", AudioFormatIEEEFloat, 4096, 100 * time.Millisecond},
+		{"small chunks", AudioFormatPCM, 100, 500 * time.Millisecond},
+		{"longer than output", AudioFormatPCM, 4096, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := transformSpeechChunked(t, tt.format, tt.chunkFrames)
+			got := transformSpeechChunked(t, tt.format, tt.chunkFrames, WithFadeOut(tt.fade))
+			if len(got) != len(want) {
+				t.Fatalf("output samples = %d, want %d", len(got), len(want))
+			}
+			fadeFrames := min(SamplesForDuration(tt.fade, sampleRate, 1), len(want))
+			start := len(want) - fadeFrames
+			for i := range want {
+				w := want[i]
+				if i >= start {
+					w *= float32(len(want)-1-i) / float32(fadeFrames-1)
+				}
+				if math.Abs(float64(got[i]-w)) > 1.0/32767 {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], w)
+				}
+			}
+			if got[len(got)-1] != 0 {
+				t.Errorf("last sample = %v, want 0", got[len(got)-1])
+			}
+		})
+	}
+}
+
+// transformSpeechChunked transforms the test speech at speed 1.5, writing chunkFrames frames per Write,
+// and returns the output as float samples.
+func transformSpeechChunked(t *testing.T, format AudioFormat, chunkFrames int, opts ...Option) []float32 {
+	t.Helper()
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, append(opts, WithSpeed(1.5))...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	speech := audiotest.Speech()
+	var input []byte
+	if format == AudioFormatPCM {
+		input = pcm.EncodeInt16(nil, speech)
+	} else {
+		input = pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767))
+	}
+	for chunk := chunkFrames * format.SampleSize(); len(input) > 0; {
+		n := min(len(input), chunk)
+		if _, err := tr.Write(input[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		input = input[n:]
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if format == AudioFormatPCM {
+		return pcm.Int16ToFloat32(nil, pcm.DecodeInt16(nil, out.Bytes()), pcm.Scaling32767)
+	}
+	return pcm.DecodeFloat32(nil, out.Bytes())
+}
+
+func TestTransformer_FadeIn(t *testing.T) {
+	const sampleRate = audiotest.SpeechSampleRate
+
+	tests := []struct {
+		name    string
+		format  AudioFormat
+		opts    []Option
+		fadeIn  time.Duration
+		fadeOut time.Duration
+	}{
+		{"int16", AudioFormatPCM, []Option{WithFadeIn(200 * time.Millisecond)}, 200 * time.Millisecond, 0},
+		{"float32", AudioFormatIEEEFloat, []Option{WithFadeIn(time.Second)}, time.Second, 0},
+		{"edges", AudioFormatPCM, []Option{WithEdgeFades(300*time.Millisecond, 100*time.Millisecond)}, 300 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := transformSpeechChunked(t, tt.format, 4096)
+			got := transformSpeechChunked(t, tt.format, 4096, tt.opts...)
+			if len(got) != len(want) {
+				t.Fatalf("output samples = %d, want %d", len(got), len(want))
+			}
+			inFrames := SamplesForDuration(tt.fadeIn, sampleRate, 1)
+			outFrames := SamplesForDuration(tt.fadeOut, sampleRate, 1)
+			for i := range want {
+				w := want[i]
+				if i < inFrames {
+					w *= float32(i) / float32(inFrames)
+				}
+				if start := len(want) - outFrames; outFrames > 0 && i >= start {
+					w *= float32(len(want)-1-i) / float32(outFrames-1)
+				}
+				if math.Abs(float64(got[i]-w)) > 2.0/32767 {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformer_FadeInAfterFlush(t *testing.T) {
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 8000, AudioFormatIEEEFloat, WithFadeIn(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	ones := make([]float32, 800)
+	for i := range ones {
+		ones[i] = 0.5
+	}
+	for clip := range 2 {
+		out.Reset()
+		if _, err := tr.Write(pcm.EncodeFloat32(nil, ones)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		got := pcm.DecodeFloat32(nil, out.Bytes())
+		if len(got) < 100 || got[0] != 0 || got[40] >= 0.5 || math.Abs(float64(got[99]-0.5)) > 0.01 {
+			t.Errorf("clip %d: output starts with %v, want a fade-in over 80 samples", clip, got[:min(len(got), 100)])
+		}
+	}
+}
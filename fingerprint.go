@@ -0,0 +1,118 @@
+package sonic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// modulePath is the path of this module, used to look up its version in the build info.
+const modulePath = "github.com/nakat-t/sonic-go"
+
+// moduleVersion returns the version of this module in the build info of the binary, e.g.
+// "v1.2.0", or "(devel)" if it is the main module or the build info is not available.
+var moduleVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+})
+
+// Fingerprint returns a stable hash of all the parameters of the transformer that affect its
+// output, together with the version of the library, as a hex string.
+//
+// Transformers with the same fingerprint produce the same output for the same input, so caching
+// layers can use it with a hash of the input as the key of transformed artifacts. Unset options
+// hash the same as their defaults, e.g. no WithSpeed as WithSpeed(1), and values are taken after
+// clamping and SetLimits. Options that do not change the output, such as WithTracer, WithOutputFunc
+// and the hashes, are left out. Settings changed with Update are included once applied by Write
+// or Flush.
+//
+// Engines are identified by their String method, or their type without one, so custom engines
+// and stream factories should implement fmt.Stringer with a name that changes with their output.
+// The version of the library is the module version in the build info of the binary; it is
+// "(devel)" for builds of this module itself and of replaced local copies, which therefore
+// share fingerprints across changes of the library.
+func (t *Transformer) Fingerprint() string {
+	h := sha256.New()
+	field := func(key string, value any) {
+		fmt.Fprintf(h, "%s=%v\n", key, value)
+	}
+	float := func(p *float32, def float32) string {
+		if p == nil {
+			return strconv.FormatFloat(float64(def), 'g', -1, 32)
+		}
+		return strconv.FormatFloat(float64(*p), 'g', -1, 32)
+	}
+
+	field("version", moduleVersion())
+	field("sampleRate", t.sampleRate)
+	if t.inputRate != t.sampleRate {
+		// The input is resampled or clamped to sampleRate.
+		field("inputRate", t.inputRate)
+		field("ratePolicy", t.ratePolicy)
+	}
+	if t.bigEndian {
+		field("byteOrder", "big-endian")
+	}
+	field("numChannels", t.numChannels)
+	field("format", t.format)
+	field("outFormat", t.outFormat)
+	field("volume", float(t.volume, 1))
+	field("speed", float(t.speed, 1))
+	field("pitch", float(t.pitch, 1))
+	field("rate", float(t.rate, 1))
+	field("nominalRate", t.nominalRate)
+	quality := 0
+	if t.quality != nil {
+		quality = *t.quality
+	}
+	field("quality", quality)
+	field("emphasis", float(t.emphasis, 0))
+	field("midSide", t.midSideMode)
+	field("gains", t.gains)
+	field("channels", t.channels)
+	field("clipping", t.clipping)
+	if t.dither != DitherNone {
+		field("dither", t.dither)
+	}
+	if t.rounding != pcm.RoundHalfAwayFromZero {
+		field("rounding", t.rounding)
+	}
+	if t.outRate > 0 {
+		field("outRate", t.outRate)
+	}
+	if t.outChannels != t.streamChannels {
+		field("outChannels", t.outChannels)
+	}
+	if s, ok := t.engine.(fmt.Stringer); ok {
+		field("engine", s.String())
+	} else {
+		field("engine", fmt.Sprintf("%T", t.engine))
+	}
+	field("startupRamp", t.startupRamp)
+	field("fadeIn", t.fadeIn)
+	field("fadeOut", t.fadeOut)
+	field("latency", t.latency)
+	field("shortInput", t.shortInput)
+	field("aligned", t.aligned)
+	field("recovery", t.recovery)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package sonic
+
+import (
+	"crypto/sha256"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_Fingerprint(t *testing.T) {
+	fingerprint := func(t *testing.T, sampleRate int, format AudioFormat, opts ...Option) string {
+		t.Helper()
+		tr, err := NewTransformer(io.Discard, sampleRate, format, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		return tr.Fingerprint()
+	}
+	base := fingerprint(t, 48000, AudioFormatPCM)
+	if len(base) != 2*sha256.Size {
+		t.Errorf("Fingerprint() = %q, want %d hex digits", base, 2*sha256.Size)
+	}
+
+	same := []struct {
+		name string
+		opts []Option
+	}{
+		{"no options", nil},
+		{"defaults", []Option{WithSpeed(1), WithPitch(1), WithRate(1), WithVolume(1), WithOutputFormat(AudioFormatPCM), WithEngine(EngineSonic)}},
+		{"output func", []Option{WithOutputFunc(func([]byte) error { return nil })}},
+		{"hashes", []Option{WithInputHash(sha256.New()), WithOutputHash(sha256.New())}},
+		{"tracer", []Option{WithTracer("stage", &recordingTracer{})}},
+		{"input tee", []Option{WithInputTee(io.Discard)}},
+		{"default rounding", []Option{WithRounding(pcm.RoundHalfAwayFromZero)}},
+	}
+	for _, tt := range same {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(t, 48000, AudioFormatPCM, tt.opts...); got != base {
+				t.Errorf("Fingerprint() = %s, want %s", got, base)
+			}
+		})
+	}
+
+	differ := []struct {
+		name       string
+		sampleRate int
+		format     AudioFormat
+		opts       []Option
+	}{
+		{"sample rate", 44100, AudioFormatPCM, nil},
+		{"format", 48000, AudioFormatIEEEFloat, nil},
+		{"channels", 48000, AudioFormatPCM, []Option{WithChannels(2)}},
+		{"speed", 48000, AudioFormatPCM, []Option{WithSpeed(1.5)}},
+		{"pitch", 48000, AudioFormatPCM, []Option{WithPitch(1.5)}},
+		{"rate", 48000, AudioFormatPCM, []Option{WithRate(1.5)}},
+		{"volume", 48000, AudioFormatPCM, []Option{WithVolume(1.5)}},
+		{"quality", 48000, AudioFormatPCM, []Option{WithQuality()}},
+		{"output format", 48000, AudioFormatPCM, []Option{WithOutputFormat(AudioFormatIEEEFloat)}},
+		{"rounding", 48000, AudioFormatPCM, []Option{WithRounding(pcm.RoundHalfEven)}},
+		{"engine", 48000, AudioFormatPCM, []Option{WithEngine(EngineMusic)}},
+		{"fade in", 48000, AudioFormatPCM, []Option{WithFadeIn(10 * time.Millisecond)}},
+		{"fixed latency", 48000, AudioFormatPCM, []Option{WithFixedLatency(50 * time.Millisecond)}},
+		{"short input", 48000, AudioFormatPCM, []Option{WithShortInput(ShortInputPad)}},
+	}
+	for _, tt := range differ {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(t, tt.sampleRate, tt.format, tt.opts...); got == base {
+				t.Errorf("Fingerprint() = %s, want a different one", got)
+			}
+		})
+	}
+
+	t.Run("update", func(t *testing.T) {
+		tr, err := NewTransformer(io.Discard, 48000, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		speed := float32(1.5)
+		tr.Update(Settings{Speed: &speed})
+		if _, err := tr.Write(make([]byte, 100)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if got, want := tr.Fingerprint(), fingerprint(t, 48000, AudioFormatPCM, WithSpeed(1.5)); got != want {
+			t.Errorf("Fingerprint() = %s after Update, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package sonic
+
+import "fmt"
+
+// FrameSize returns the size of one input frame in bytes, i.e. the number of channels times
+// the sample size of the input format.
+func (t *Transformer) FrameSize() int {
+	return t.numChannels * t.format.SampleSize()
+}
+
+// OutputFrameSize returns the size of one output frame in bytes. It takes channel selection, the
+// output channels and the output format into account. Divide the length of the output by it to
+// count frames, e.g. in an OutputFunc.
+func (t *Transformer) OutputFrameSize() int {
+	return t.outChannels * t.outFormat.SampleSize()
+}
+
+// WriteFrames writes frames input frames from p to the transformer and returns the number of
+// frames consumed. len(p) must be frames times FrameSize. Otherwise, it returns an error
+// wrapping ErrInvalid and writes nothing. See Write.
+func (t *Transformer) WriteFrames(frames int, p []byte) (int, error) {
+	if t.closed {
+		return 0, ErrClosed
+	}
+	frameSize := t.FrameSize()
+	if frames < 0 || len(p) != frames*frameSize {
+		return 0, fmt.Errorf("%w: %d bytes given for %d frames of %d bytes", ErrInvalid, len(p), frames, frameSize)
+	}
+	n, err := t.Write(p)
+	return n / frameSize, err
+}
+
+// writeCarryOver writes the whole frames of p, completing the incomplete frame carried over
+// from the previous Write first, and carries a trailing incomplete frame of p over to the next
+// Write. It returns the number of bytes of p consumed, including the bytes carried over.
+func (t *Transformer) writeCarryOver(p []byte) (int, error) {
+	frameSize := t.FrameSize()
+	if t.carryOver == nil {
+		t.carryOver = make([]byte, 0, frameSize)
+	}
+
+	consumed := 0
+	var recovered error // Reported once all of p is written
+	if len(t.carryOver) > 0 {
+		consumed = min(len(p), frameSize-len(t.carryOver))
+		t.carryOver = append(t.carryOver, p[:consumed]...)
+		if len(t.carryOver) < frameSize {
+			return consumed, nil
+		}
+		frame := t.carryOver
+		t.carryOver = t.carryOver[:0]
+		if _, err := t.writeUninterrupted(t.writeInput, frame); deferRecovered(&recovered, err) != nil {
+			return consumed, err
+		}
+	}
+
+	whole := (len(p) - consumed) / frameSize * frameSize
+	n, err := t.writeInput(p[consumed : consumed+whole])
+	consumed += n
+	if deferRecovered(&recovered, err) != nil {
+		return consumed, err
+	}
+	t.carryOver = append(t.carryOver, p[consumed:]...)
+	return len(p), recovered
+}
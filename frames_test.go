@@ -0,0 +1,166 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_FrameSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     AudioFormat
+		opts       []Option
+		wantInput  int
+		wantOutput int
+	}{
+		{"mono PCM", AudioFormatPCM, nil, 2, 2},
+		{"stereo float", AudioFormatIEEEFloat, []Option{WithChannels(2)}, 8, 8},
+		{"selected channel", AudioFormatPCM, []Option{WithChannels(4), WithSelectChannels(1, 3)}, 8, 4},
+		{"output format", AudioFormatPCM, []Option{WithChannels(2), WithOutputFormat(AudioFormatIEEEFloat)}, 4, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(new(bytes.Buffer), 44100, tt.format, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if got := tr.FrameSize(); got != tt.wantInput {
+				t.Errorf("FrameSize() = %d, want %d", got, tt.wantInput)
+			}
+			if got := tr.OutputFrameSize(); got != tt.wantOutput {
+				t.Errorf("OutputFrameSize() = %d, want %d", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestTransformer_WriteFrames(t *testing.T) {
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 44100, AudioFormatPCM, WithChannels(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	tests := []struct {
+		name       string
+		frames     int
+		p          []byte
+		wantFrames int
+		wantErr    error
+	}{
+		{"valid", 1000, make([]byte, 4000), 1000, nil},
+		{"empty", 0, nil, 0, nil},
+		{"too short", 1000, make([]byte, 3998), 0, ErrInvalid},
+		{"too long", 1000, make([]byte, 4004), 0, ErrInvalid},
+		{"partial frame", 1, make([]byte, 2), 0, ErrInvalid},
+		{"negative", -1, nil, 0, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.WriteFrames(tt.frames, tt.p)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("WriteFrames() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.wantFrames {
+				t.Errorf("WriteFrames() = %d, want %d", got, tt.wantFrames)
+			}
+		})
+	}
+
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := out.Len() / tr.OutputFrameSize(); got != 1000 {
+		t.Errorf("output frames = %d, want 1000", got)
+	}
+}
+
+func TestTransformer_CarryOver(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate/2]
+	stereo := make([]int16, 2*len(speech))
+	for i, v := range speech {
+		stereo[2*i], stereo[2*i+1] = v, v/2
+	}
+	const rate = audiotest.SpeechSampleRate
+
+	tests := []struct {
+		name       string
+		sampleRate int
+		format     AudioFormat
+		input      []byte
+		opts       []Option
+	}{
+		{"PCM stereo", rate, AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{WithChannels(2), WithSpeed(1.5)}},
+		{"float mid-side", rate, AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, stereo, int16Scaling)), []Option{WithChannels(2), WithMidSide(), WithSpeed(2)}},
+		{"PCM24 mid-side", rate, AudioFormatPCM24, pcm.Int16ToInt24(nil, stereo), []Option{WithChannels(2), WithMidSide(), WithSpeed(1.5)}},
+		{"PCM32 stereo", rate, AudioFormatPCM32, pcm.Int16ToInt32(nil, stereo), []Option{WithChannels(2), WithSpeed(0.7)}},
+		{"float64 stereo", rate, AudioFormatIEEEFloat64, pcm.Float32ToFloat64(nil, pcm.Int16ToFloat32(nil, stereo, int16Scaling)), []Option{WithChannels(2), WithPitch(0.8)}},
+		{"U8 stereo", rate, AudioFormatU8, pcm.Int16ToUint8(nil, stereo), []Option{WithChannels(2), WithPitch(1.2)}},
+		{"big-endian", rate, AudioFormatPCM, reverseSampleBytes(make([]byte, 4*len(stereo)), pcm.EncodeInt16(nil, stereo), 2), []Option{WithChannels(2), WithByteOrder(binary.BigEndian), WithSpeed(1.5)}},
+		{"resampled", 800, AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{WithChannels(2), WithSampleRatePolicy(SampleRateResample, nil), WithSpeed(1.5)}},
+	}
+	for _, tt := range tests {
+		// With WithAlignedChunks, the output does not depend on how the input is split.
+		transform := func(t *testing.T, chunkSize int) []byte {
+			t.Helper()
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, tt.sampleRate, tt.format, append(tt.opts, WithAlignedChunks())...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			for chunk := range slices.Chunk(tt.input, chunkSize) {
+				if n, err := tr.Write(chunk); n != len(chunk) || err != nil {
+					t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(chunk))
+				}
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if got := tr.Stats().InputBytes; got != int64(len(tt.input)) {
+				t.Errorf("InputBytes = %d, want %d", got, len(tt.input))
+			}
+			return out.Bytes()
+		}
+		for _, chunkSize := range []int{7, 1001} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, chunkSize), func(t *testing.T) {
+				want := transform(t, len(tt.input))
+				if got := transform(t, chunkSize); !bytes.Equal(got, want) {
+					t.Errorf("output of %d-byte writes = %d bytes, want the %d bytes of a single write", chunkSize, len(got), len(want))
+				}
+			})
+		}
+	}
+}
+
+func TestTransformer_CarryOverFlush(t *testing.T) {
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 44100, AudioFormatPCM, WithChannels(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	// The incomplete frame is kept across Flush, so that the following input stays aligned.
+	input := pcm.EncodeInt16(nil, []int16{1, -1, 2, -2, 3, -3})
+	for _, p := range [][]byte{input[:3], input[3:5], input[5:]} {
+		if _, err := tr.Write(p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+	}
+	if got := pcm.DecodeInt16(nil, out.Bytes()); !slices.Equal(got, []int16{1, -1, 2, -2, 3, -3}) {
+		t.Errorf("output = %v, want the input", got)
+	}
+}
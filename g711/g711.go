@@ -0,0 +1,286 @@
+// Package g711 encodes and decodes G.711 μ-law and A-law audio, the 8-bit companded formats of
+// telephony, so that VoIP streams can be fed to sonic.Transformer and its output sent back
+// without another dependency:
+//
+//	enc, err := g711.NewEncoder(conn, g711.MuLaw)
+//	...
+//	dec, err := g711.NewDecoder(payloads, g711.MuLaw)
+//	...
+//	tr, err := sonic.NewTransformer(enc, 8000, sonic.AudioFormatPCM, sonic.WithSpeed(1.5))
+//	...
+//	_, err = tr.ReadFrom(dec)
+//
+// The codecs convert between G.711 bytes and 16-bit linear samples, as the reference
+// implementation of ITU-T G.711 does: μ-law covers ±32124 and A-law ±32256, with 14 and 13 bits
+// of resolution for quiet samples. The package only depends on the standard library.
+package g711
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Law is the companding law of G.711. The values are the format tags of WAVE files.
+type Law int
+
+// Constants for companding laws
+const (
+	ALaw  Law = 6 // A-law, used in Europe and most of the world
+	MuLaw Law = 7 // μ-law, used in North America and Japan
+)
+
+// String returns the string representation of the Law.
+func (l Law) String() string {
+	m := map[Law]string{
+		ALaw:  "ALaw",
+		MuLaw: "MuLaw",
+	}
+	if s, ok := m[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("Law(%d)", l)
+}
+
+// Values returns the all possible values of Law.
+func (Law) Values() []Law {
+	return []Law{
+		ALaw,
+		MuLaw,
+	}
+}
+
+// ErrUnsupported is returned for a Law other than ALaw and MuLaw.
+var ErrUnsupported = errors.New("unsupported G.711 law")
+
+// Tables for decoding
+var (
+	muLawTable = decodeTable(decodeMuLaw)
+	aLawTable  = decodeTable(decodeALaw)
+)
+
+// decodeTable returns the linear samples of all 256 codes.
+func decodeTable(decode func(byte) int16) *[256]int16 {
+	var t [256]int16
+	for i := range t {
+		t[i] = decode(byte(i))
+	}
+	return &t
+}
+
+// EncodeMuLaw encodes the linear samples of src as μ-law into dst and returns the encoded bytes.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func EncodeMuLaw(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src))
+	for i, s := range src {
+		dst[i] = encodeMuLaw(s)
+	}
+	return dst
+}
+
+// DecodeMuLaw decodes the μ-law bytes of src into dst and returns the linear samples.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func DecodeMuLaw(dst []int16, src []byte) []int16 {
+	dst = grow(dst, len(src))
+	for i, b := range src {
+		dst[i] = muLawTable[b]
+	}
+	return dst
+}
+
+// EncodeALaw encodes the linear samples of src as A-law into dst and returns the encoded bytes.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func EncodeALaw(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src))
+	for i, s := range src {
+		dst[i] = encodeALaw(s)
+	}
+	return dst
+}
+
+// DecodeALaw decodes the A-law bytes of src into dst and returns the linear samples.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func DecodeALaw(dst []int16, src []byte) []int16 {
+	dst = grow(dst, len(src))
+	for i, b := range src {
+		dst[i] = aLawTable[b]
+	}
+	return dst
+}
+
+// encodeMuLaw returns the μ-law code of s. The 14 most significant bits of s are encoded.
+func encodeMuLaw(s int16) byte {
+	const (
+		bias = 0x84 >> 2 // Bias of the magnitude, in units of the 14-bit input
+		clip = 8159      // Largest magnitude that can be encoded
+	)
+	v := int(s) >> 2
+	mask := byte(0xFF)
+	if v < 0 {
+		v = -v
+		mask = 0x7F
+	}
+	v = min(v, clip) + bias
+	seg := bits.Len(uint(v >> 6))
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	return (byte(seg<<4) | byte(v>>(seg+1))&0x0F) ^ mask
+}
+
+// decodeMuLaw returns the linear sample of the μ-law code b.
+func decodeMuLaw(b byte) int16 {
+	const bias = 0x84
+	b = ^b
+	t := (int(b&0x0F)<<3 + bias) << ((b & 0x70) >> 4)
+	if b&0x80 != 0 {
+		return int16(bias - t)
+	}
+	return int16(t - bias)
+}
+
+// encodeALaw returns the A-law code of s. The 13 most significant bits of s are encoded.
+func encodeALaw(s int16) byte {
+	v := int(s) >> 3
+	mask := byte(0xD5)
+	if v < 0 {
+		v = -v - 1
+		mask = 0x55
+	}
+	seg := bits.Len(uint(v >> 5))
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	shift := max(seg, 1)
+	return (byte(seg<<4) | byte(v>>shift)&0x0F) ^ mask
+}
+
+// decodeALaw returns the linear sample of the A-law code b.
+func decodeALaw(b byte) int16 {
+	b ^= 0x55
+	t := int(b&0x0F)<<4 + 8
+	if seg := (b & 0x70) >> 4; seg > 0 {
+		t = (t + 0x100) << (seg - 1)
+	}
+	if b&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
+
+// Encoder is an io.Writer that encodes 16-bit little-endian PCM samples, as written by a
+// sonic.Transformer with AudioFormatPCM output, to G.711 and writes the codes to the underlying
+// writer. Samples may be split across calls.
+type Encoder struct {
+	w       io.Writer
+	encode  func(dst []byte, src []int16) []byte
+	pending []byte // Incomplete sample carried over to the next Write
+	samples []int16
+	codes   []byte
+}
+
+// NewEncoder returns an Encoder that writes the samples written to it to w, encoded with law.
+// It returns an error wrapping ErrUnsupported for an unknown law.
+func NewEncoder(w io.Writer, law Law) (*Encoder, error) {
+	var encode func(dst []byte, src []int16) []byte
+	switch law {
+	case MuLaw:
+		encode = EncodeMuLaw
+	case ALaw:
+		encode = EncodeALaw
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, law)
+	}
+	return &Encoder{w: w, encode: encode, pending: make([]byte, 0, 2)}, nil
+}
+
+// Write encodes the samples of p and writes them to the underlying writer. It returns the number
+// of bytes of p consumed, which is less than len(p) only if writing failed.
+func (e *Encoder) Write(p []byte) (int, error) {
+	data := p
+	carried := len(e.pending)
+	if carried > 0 {
+		data = append(e.pending, p...)
+	}
+	whole := len(data) &^ 1
+	e.samples = grow(e.samples, whole/2)
+	for i := range e.samples {
+		e.samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	e.codes = e.encode(e.codes, e.samples)
+	n, err := e.w.Write(e.codes)
+	if err == nil && n < len(e.codes) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		e.pending = e.pending[:0]
+		return max(2*n-carried, 0), err
+	}
+	e.pending = append(e.pending[:0], data[whole:]...)
+	return len(p), nil
+}
+
+// Decoder is an io.Reader that reads G.711 codes from the underlying reader and returns them as
+// 16-bit little-endian PCM samples, to be written to a sonic.Transformer with AudioFormatPCM
+// input.
+type Decoder struct {
+	r      io.Reader
+	table  *[256]int16
+	codes  []byte
+	rest   []byte // Second byte of a sample that did not fit into the last Read
+	buffer [2]byte
+}
+
+// NewDecoder returns a Decoder that reads the codes of law from r. It returns an error wrapping
+// ErrUnsupported for an unknown law.
+func NewDecoder(r io.Reader, law Law) (*Decoder, error) {
+	var table *[256]int16
+	switch law {
+	case MuLaw:
+		table = muLawTable
+	case ALaw:
+		table = aLawTable
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, law)
+	}
+	return &Decoder{r: r, table: table}, nil
+}
+
+// Read reads codes from the underlying reader and decodes them into p, two bytes per code.
+func (d *Decoder) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(d.rest) > 0 {
+		p[0] = d.rest[0]
+		d.rest = d.rest[:0]
+		return 1, nil
+	}
+	if len(p) == 1 {
+		// Decode one code and keep its second byte for the next Read.
+		n, err := d.r.Read(d.buffer[:1])
+		if n == 0 {
+			return 0, err
+		}
+		binary.LittleEndian.PutUint16(d.buffer[:], uint16(d.table[d.buffer[0]]))
+		p[0] = d.buffer[0]
+		d.rest = d.buffer[1:2]
+		return 1, err
+	}
+	d.codes = grow(d.codes, len(p)/2)
+	n, err := d.r.Read(d.codes)
+	for i, b := range d.codes[:n] {
+		binary.LittleEndian.PutUint16(p[2*i:], uint16(d.table[b]))
+	}
+	return 2 * n, err
+}
+
+// grow returns s resized to n elements, reusing its memory if it has enough capacity.
+func grow[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,219 @@
+package g711
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		code  byte
+		muLaw int16
+		aLaw  int16
+	}{
+		{0x00, -32124, -5504},
+		{0x80, 32124, 5504},
+		{0xFF, 0, 848},
+		{0x7F, 0, -848},
+		{0xD5, 716, 8},
+		{0x55, -716, -8},
+		{0xAA, 5372, 32256},
+		{0x2A, -5372, -32256},
+	}
+	for _, tt := range tests {
+		if got := DecodeMuLaw(nil, []byte{tt.code})[0]; got != tt.muLaw {
+			t.Errorf("DecodeMuLaw(%#02x) = %d, want %d", tt.code, got, tt.muLaw)
+		}
+		if got := DecodeALaw(nil, []byte{tt.code})[0]; got != tt.aLaw {
+			t.Errorf("DecodeALaw(%#02x) = %d, want %d", tt.code, got, tt.aLaw)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		sample int16
+		muLaw  byte
+		aLaw   byte
+	}{
+		{0, 0xFF, 0xD5},
+		{-1, 0x7E, 0x55},
+		{32767, 0x80, 0xAA},
+		{-32768, 0x00, 0x2A},
+	}
+	for _, tt := range tests {
+		if got := EncodeMuLaw(nil, []int16{tt.sample})[0]; got != tt.muLaw {
+			t.Errorf("EncodeMuLaw(%d) = %#02x, want %#02x", tt.sample, got, tt.muLaw)
+		}
+		if got := EncodeALaw(nil, []int16{tt.sample})[0]; got != tt.aLaw {
+			t.Errorf("EncodeALaw(%d) = %#02x, want %#02x", tt.sample, got, tt.aLaw)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	codecs := []struct {
+		name   string
+		encode func([]byte, []int16) []byte
+		decode func([]int16, []byte) []int16
+		max    int16
+	}{
+		{"MuLaw", EncodeMuLaw, DecodeMuLaw, 32124},
+		{"ALaw", EncodeALaw, DecodeALaw, 32256},
+	}
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			// Every code decodes to a sample that encodes to a code of the same sample.
+			codes := make([]byte, 256)
+			for i := range codes {
+				codes[i] = byte(i)
+			}
+			decoded := c.decode(nil, codes)
+			if again := c.decode(nil, c.encode(nil, decoded)); !slices.Equal(again, decoded) {
+				t.Errorf("decode(encode(decode(codes))) = %v, want %v", again, decoded)
+			}
+
+			// Every sample is encoded to a code of a nearby sample, within half a step of the
+			// segment, which is at most 1/32 of the magnitude plus the step of the first segment.
+			samples := make([]int16, 0, 1<<16)
+			for s := -32768; s <= 32767; s++ {
+				samples = append(samples, int16(s))
+			}
+			for i, got := range c.decode(nil, c.encode(nil, samples)) {
+				s := int(max(min(samples[i], c.max), -c.max))
+				if d := abs(int(got) - s); d > abs(s)/32+16 {
+					t.Fatalf("sample %d decodes as %d", samples[i], got)
+				}
+			}
+		})
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestEncoder(t *testing.T) {
+	samples := make([]int16, 1001)
+	for i := range samples {
+		samples[i] = int16(i*67 - 30000)
+	}
+	input := pcm.EncodeInt16(nil, samples)
+	for _, law := range Law(0).Values() {
+		t.Run(law.String(), func(t *testing.T) {
+			want := EncodeMuLaw(nil, samples)
+			if law == ALaw {
+				want = EncodeALaw(nil, samples)
+			}
+			var out bytes.Buffer
+			enc, err := NewEncoder(&out, law)
+			if err != nil {
+				t.Fatalf("NewEncoder() error = %v", err)
+			}
+			// Written in odd-sized pieces that split samples.
+			for p := input; len(p) > 0; p = p[min(len(p), 7):] {
+				if n, err := enc.Write(p[:min(len(p), 7)]); err != nil || n != min(len(p), 7) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("Encoder wrote %d codes, want %d", out.Len(), len(want))
+			}
+		})
+	}
+}
+
+// shortWriter accepts n bytes and fails afterwards.
+type shortWriter struct{ n int }
+
+var errShort = errors.New("short")
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errShort
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestEncoder_Error(t *testing.T) {
+	enc, err := NewEncoder(&shortWriter{n: 2}, MuLaw)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	// The bytes of the samples whose codes were written are consumed.
+	if n, err := enc.Write(make([]byte, 10)); !errors.Is(err, errShort) || n != 4 {
+		t.Errorf("Write() = %d, %v, want 4, %v", n, err, errShort)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	codes := make([]byte, 1001)
+	for i := range codes {
+		codes[i] = byte(i * 7)
+	}
+	for _, law := range Law(0).Values() {
+		t.Run(law.String(), func(t *testing.T) {
+			want := pcm.EncodeInt16(nil, DecodeMuLaw(nil, codes))
+			if law == ALaw {
+				want = pcm.EncodeInt16(nil, DecodeALaw(nil, codes))
+			}
+			for _, size := range []int{1, 3, 4096} {
+				dec, err := NewDecoder(bytes.NewReader(codes), law)
+				if err != nil {
+					t.Fatalf("NewDecoder() error = %v", err)
+				}
+				var got []byte
+				buf := make([]byte, size)
+				for {
+					n, err := dec.Read(buf)
+					got = append(got, buf[:n]...)
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatalf("Read() error = %v", err)
+					}
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("reads of %d bytes: Decoder returned %d bytes, want %d", size, len(got), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestUnsupportedLaw(t *testing.T) {
+	if _, err := NewEncoder(io.Discard, Law(1)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewEncoder() error = %v, want %v", err, ErrUnsupported)
+	}
+	if _, err := NewDecoder(bytes.NewReader(nil), Law(1)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewDecoder() error = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestLaw_String(t *testing.T) {
+	tests := []struct {
+		law  Law
+		want string
+	}{
+		{MuLaw, "MuLaw"},
+		{ALaw, "ALaw"},
+		{Law(42), "Law(42)"},
+	}
+	for _, tt := range tests {
+		if got := tt.law.String(); got != tt.want {
+			t.Errorf("Law(%d).String() = %q, want %q", int(tt.law), got, tt.want)
+		}
+	}
+}
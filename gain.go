@@ -0,0 +1,17 @@
+package sonic
+
+// applyGainsInt16 scales interleaved int16 samples by per-channel gains in place, saturating on overflow.
+func applyGainsInt16(samples []int16, gains []float32) {
+	numChannels := len(gains)
+	for i, s := range samples {
+		samples[i] = saturateInt16(float32(s) * gains[i%numChannels])
+	}
+}
+
+// applyGainsFloat32 scales interleaved float32 samples by per-channel gains in place.
+func applyGainsFloat32(samples []float32, gains []float32) {
+	numChannels := len(gains)
+	for i, s := range samples {
+		samples[i] = s * gains[i%numChannels]
+	}
+}
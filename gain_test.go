@@ -0,0 +1,70 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestApplyGainsInt16(t *testing.T) {
+	samples := []int16{100, 100, -200, -200, 30000, 30000}
+	applyGainsInt16(samples, []float32{0.5, 2})
+	want := []int16{50, 200, -100, -400, 15000, math.MaxInt16}
+	if !slices.Equal(samples, want) {
+		t.Errorf("applyGainsInt16() = %v, want %v", samples, want)
+	}
+}
+
+func TestApplyGainsFloat32(t *testing.T) {
+	samples := []float32{0.1, 0.1, -0.2, -0.2}
+	applyGainsFloat32(samples, []float32{0, 3})
+	want := []float32{0, 0.3, 0, -0.6}
+	for i := range samples {
+		if math.Abs(float64(samples[i]-want[i])) > 1e-6 {
+			t.Errorf("applyGainsFloat32()[%d] = %f, want %f", i, samples[i], want[i])
+		}
+	}
+}
+
+func TestTransformer_WithChannelGains(t *testing.T) {
+	t.Run("gain count mismatch", func(t *testing.T) {
+		_, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithChannels(2), WithChannelGains([]float32{1}))
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("NewTransformer() error = %v, want %v", err, ErrInvalid)
+		}
+	})
+
+	t.Run("mute right channel", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		tr, err := NewTransformer(out, 44100, AudioFormatPCM, WithChannels(2), WithChannelGains([]float32{2, 0}))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		in := new(bytes.Buffer)
+		binary.Write(in, binary.LittleEndian, stereoSine(4410, 44100, 440, 1))
+		if _, err := tr.Write(in.Bytes()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+
+		outSamples := make([]int16, out.Len()/2)
+		binary.Read(out, binary.LittleEndian, outSamples)
+		nonZeroLeft := false
+		for i := 0; i+1 < len(outSamples); i += 2 {
+			if outSamples[i+1] != 0 {
+				t.Fatalf("right channel sample %d = %d, want 0", i/2, outSamples[i+1])
+			}
+			nonZeroLeft = nonZeroLeft || outSamples[i] != 0
+		}
+		if !nonZeroLeft {
+			t.Error("left channel is silent")
+		}
+	})
+}
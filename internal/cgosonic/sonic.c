@@ -1,3 +1,5 @@
+//go:build cgo && !purego && !nosonic_cgo
+
 /* Sonic library
    Copyright 2010
    Bill Cox
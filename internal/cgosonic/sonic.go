@@ -1,9 +1,45 @@
+//go:build cgo && !purego && !nosonic_cgo
+
 package cgosonic
 
 /*
-#cgo CFLAGS: -Wall -Wno-unused-function -g -ansi -fPIC -pthread -I${SRCDIR}
+#cgo CFLAGS: -Wall -Wno-unused-function -g -std=gnu89 -fPIC -pthread -I${SRCDIR}
 #include <stdlib.h>
 #include "sonic.h"
+
+typedef struct {
+  int ok;
+  int available;
+  int read;
+} flushResult;
+
+static flushResult flushAndReadShort(sonicStream stream, short* samples, int maxSamples) {
+  flushResult r = {0, 0, 0};
+  r.ok = sonicFlushStream(stream);
+  if (r.ok) {
+    r.available = sonicSamplesAvailable(stream);
+    r.read = sonicReadShortFromStream(stream, samples, maxSamples);
+  }
+  return r;
+}
+
+static flushResult flushAndReadFloat(sonicStream stream, float* samples, int maxSamples) {
+  flushResult r = {0, 0, 0};
+  r.ok = sonicFlushStream(stream);
+  if (r.ok) {
+    r.available = sonicSamplesAvailable(stream);
+    r.read = sonicReadFloatFromStream(stream, samples, maxSamples);
+  }
+  return r;
+}
+
+static int readShortInto(sonicStream stream, short* samples, int capSamples) {
+  return sonicReadShortFromStream(stream, samples, capSamples / sonicGetNumChannels(stream));
+}
+
+static int readFloatInto(sonicStream stream, float* samples, int capSamples) {
+  return sonicReadFloatFromStream(stream, samples, capSamples / sonicGetNumChannels(stream));
+}
 */
 import "C"
 import (
@@ -24,11 +60,25 @@ const (
 	MAX_SAMPLE_RATE   = int(C.SONIC_MAX_SAMPLE_RATE)
 	MIN_CHANNELS      = int(C.SONIC_MIN_CHANNELS)
 	MAX_CHANNELS      = int(C.SONIC_MAX_CHANNELS)
+	MIN_PITCH         = int(C.SONIC_MIN_PITCH)
+	MAX_PITCH         = int(C.SONIC_MAX_PITCH)
 )
 
 // Stream represents a SONIC audio stream
+//
+// A Stream is not safe for concurrent use. libsonic streams have no internal locking, and every
+// call may reallocate the stream's buffers, so all calls on one Stream, including reads, must be
+// serialized by the caller. Separate Streams can be used concurrently. Because output only
+// becomes available as a result of a write or a flush on the same goroutine, a read that returns
+// fewer frames than requested means that the stream is drained; there is no need to query
+// SamplesAvailable first.
 type Stream struct {
 	stream C.sonicStream
+
+	// Bookkeeping for PendingInputFrames, reset by FlushStream.
+	framesWritten int64 // Frames written since the last flush
+	framesRead    int64 // Frames read since the last flush
+	framesFlushed int64 // Frames available right after the last flush
 }
 
 // CreateStream creates a new sonic stream
@@ -37,7 +87,22 @@ func CreateStream(sampleRate int, numChannels int) (*Stream, error) {
 	if stream == nil {
 		return nil, errors.New("failed to create cgosonic.Stream")
 	}
-	return &Stream{stream: stream}, nil
+	s := &Stream{stream: stream}
+	if numChannels > 1 {
+		s.reallocate(func() { C.sonicSetSampleRate(stream, C.sonicGetSampleRate(stream)) })
+	}
+	return s, nil
+}
+
+// reallocate calls allocate, which makes libsonic allocate the buffers of the stream again, with
+// quality 1. libsonic sizes the down-sample buffer for the pitch search of quality 0, which skips
+// samples, but multi-channel streams search a second time without skipping, which overflows the
+// buffer. Nothing is skipped with quality 1, so the buffer is allocated at its full size.
+func (s *Stream) reallocate(allocate func()) {
+	quality := C.sonicGetQuality(s.stream)
+	C.sonicSetQuality(s.stream, 1)
+	allocate()
+	C.sonicSetQuality(s.stream, quality)
 }
 
 // DestroyStream destroys the sonic stream
@@ -48,39 +113,131 @@ func (s *Stream) DestroyStream() {
 	}
 }
 
+// CPointer returns the libsonic sonicStream of s, or nil after DestroyStream.
+func (s *Stream) CPointer() unsafe.Pointer {
+	return unsafe.Pointer(s.stream)
+}
+
 // The following symbols are not implemented yet.
 // void sonicSetUserData(sonicStream stream, void *userData);
 // void *sonicGetUserData(sonicStream stream);
 
 // WriteFloatToStream writes float samples to the stream
 func (s *Stream) WriteFloatToStream(samples []float32, numSamples int) int {
-	return int(C.sonicWriteFloatToStream(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(numSamples)))
+	ret := int(C.sonicWriteFloatToStream(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(numSamples)))
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
 }
 
 // WriteShortToStream writes short samples to the stream
 func (s *Stream) WriteShortToStream(samples []int16, numSamples int) int {
-	return int(C.sonicWriteShortToStream(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(numSamples)))
+	ret := int(C.sonicWriteShortToStream(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(numSamples)))
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
 }
 
-// The following symbol is not implemented yet.
-// int sonicWriteUnsignedCharToStream(sonicStream stream, const unsigned char* samples, int numSamples);
+// WriteUnsignedCharToStream writes unsigned 8-bit samples to the stream
+func (s *Stream) WriteUnsignedCharToStream(samples []uint8, numSamples int) int {
+	ret := int(C.sonicWriteUnsignedCharToStream(s.stream, (*C.uchar)(unsafe.Pointer(&samples[0])), C.int(numSamples)))
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
+}
 
 // ReadFloatFromStream reads float samples from the stream
 func (s *Stream) ReadFloatFromStream(samples []float32, maxSamples int) int {
-	return int(C.sonicReadFloatFromStream(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(maxSamples)))
+	n := int(C.sonicReadFloatFromStream(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(maxSamples)))
+	if n > 0 {
+		s.framesRead += int64(n)
+	}
+	return n
 }
 
 // ReadShortFromStream reads short samples from the stream
 func (s *Stream) ReadShortFromStream(samples []int16, maxSamples int) int {
-	return int(C.sonicReadShortFromStream(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(maxSamples)))
+	n := int(C.sonicReadShortFromStream(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(maxSamples)))
+	if n > 0 {
+		s.framesRead += int64(n)
+	}
+	return n
 }
 
-// The following symbol is not implemented yet.
-// int sonicReadUnsignedCharFromStream(sonicStream stream, unsigned char* samples, int maxSamples);
+// ReadShortInto reads as many frames as fit into the capacity of samples in a single call and
+// returns the number of frames read. The samples are stored in samples[:cap(samples)].
+func (s *Stream) ReadShortInto(samples []int16) int {
+	if cap(samples) == 0 {
+		return 0
+	}
+	samples = samples[:cap(samples)]
+	n := int(C.readShortInto(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(len(samples))))
+	if n > 0 {
+		s.framesRead += int64(n)
+	}
+	return n
+}
+
+// ReadFloatInto reads as many frames as fit into the capacity of samples in a single call and
+// returns the number of frames read. The samples are stored in samples[:cap(samples)].
+func (s *Stream) ReadFloatInto(samples []float32) int {
+	if cap(samples) == 0 {
+		return 0
+	}
+	samples = samples[:cap(samples)]
+	n := int(C.readFloatInto(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(len(samples))))
+	if n > 0 {
+		s.framesRead += int64(n)
+	}
+	return n
+}
+
+// ReadUnsignedCharFromStream reads unsigned 8-bit samples from the stream
+func (s *Stream) ReadUnsignedCharFromStream(samples []uint8, maxSamples int) int {
+	n := int(C.sonicReadUnsignedCharFromStream(s.stream, (*C.uchar)(unsafe.Pointer(&samples[0])), C.int(maxSamples)))
+	if n > 0 {
+		s.framesRead += int64(n)
+	}
+	return n
+}
 
 // FlushStream flushes the stream
 func (s *Stream) FlushStream() int {
-	return int(C.sonicFlushStream(s.stream))
+	ret := int(C.sonicFlushStream(s.stream))
+	if ret != 0 {
+		s.framesWritten = 0
+		s.framesRead = 0
+		s.framesFlushed = int64(s.SamplesAvailable())
+	}
+	return ret
+}
+
+// FlushAndReadShort flushes the stream and reads the first short samples from it in a single
+// cgo call. It returns the number of samples read, or -1 if the flush failed.
+func (s *Stream) FlushAndReadShort(samples []int16, maxSamples int) int {
+	r := C.flushAndReadShort(s.stream, (*C.short)(unsafe.Pointer(&samples[0])), C.int(maxSamples))
+	return s.afterFlushAndRead(r)
+}
+
+// FlushAndReadFloat flushes the stream and reads the first float samples from it in a single
+// cgo call. It returns the number of samples read, or -1 if the flush failed.
+func (s *Stream) FlushAndReadFloat(samples []float32, maxSamples int) int {
+	r := C.flushAndReadFloat(s.stream, (*C.float)(unsafe.Pointer(&samples[0])), C.int(maxSamples))
+	return s.afterFlushAndRead(r)
+}
+
+// afterFlushAndRead updates the bookkeeping the same way as FlushStream followed by a read.
+func (s *Stream) afterFlushAndRead(r C.flushResult) int {
+	if r.ok == 0 {
+		return -1
+	}
+	s.framesWritten = 0
+	s.framesRead = int64(r.read)
+	s.framesFlushed = int64(r.available)
+	return int(r.read)
 }
 
 // SamplesAvailable returns the number of samples in the output buffer
@@ -88,6 +245,22 @@ func (s *Stream) SamplesAvailable() int {
 	return int(C.sonicSamplesAvailable(s.stream))
 }
 
+// PendingInputFrames estimates the number of input frames held inside the stream that have not
+// been turned into output yet.
+//
+// libsonic does not expose its internal buffers, so the estimate is derived from the number of
+// frames written and produced since the last flush, using the current speed and rate. It is
+// exact while these parameters stay unchanged, up to the resolution of one pitch period.
+func (s *Stream) PendingInputFrames() int {
+	produced := s.framesRead + int64(s.SamplesAvailable()) - s.framesFlushed
+	consumed := float64(produced) * float64(s.GetSpeed()) * float64(s.GetRate())
+	pending := float64(s.framesWritten) - consumed
+	if pending < 0 {
+		return 0
+	}
+	return int(pending + 0.5)
+}
+
 // GetSpeed gets the speed of the stream
 func (s *Stream) GetSpeed() float32 {
 	return float32(C.sonicGetSpeed(s.stream))
@@ -128,9 +301,17 @@ func (s *Stream) SetVolume(volume float32) {
 	C.sonicSetVolume(s.stream, C.float(volume))
 }
 
-// The following symbols are not implemented yet.
-// int sonicGetChordPitch(sonicStream stream);
-// void sonicSetChordPitch(sonicStream stream, int useChordPitch);
+// GetChordPitch gets the chord pitch setting. Chord pitch is deprecated in libsonic, and this
+// always returns 0.
+func (s *Stream) GetChordPitch() int {
+	return int(C.sonicGetChordPitch(s.stream))
+}
+
+// SetChordPitch sets chord pitch mode on or off. Chord pitch is deprecated in libsonic, and this
+// has no effect; it exists for compatibility with the libsonic API.
+func (s *Stream) SetChordPitch(useChordPitch int) {
+	C.sonicSetChordPitch(s.stream, C.int(useChordPitch))
+}
 
 // GetQuality gets the quality setting.
 func (s *Stream) GetQuality() int {
@@ -149,7 +330,7 @@ func (s *Stream) GetSampleRate() int {
 
 // SetSampleRate sets the sample rate of the stream
 func (s *Stream) SetSampleRate(sampleRate int) {
-	C.sonicSetSampleRate(s.stream, C.int(sampleRate))
+	s.reallocate(func() { C.sonicSetSampleRate(s.stream, C.int(sampleRate)) })
 }
 
 // GetNumChannels gets the number of channels in the stream
@@ -159,11 +340,15 @@ func (s *Stream) GetNumChannels() int {
 
 // SetNumChannels sets the number of channels in the stream
 func (s *Stream) SetNumChannels(numChannels int) {
-	C.sonicSetNumChannels(s.stream, C.int(numChannels))
+	s.reallocate(func() { C.sonicSetNumChannels(s.stream, C.int(numChannels)) })
 }
 
 // ChangeFloatSpeed is a non-stream-oriented interface to change the speed of float audio samples
 func ChangeFloatSpeed(samples []float32, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	if numChannels > 1 {
+		return changeSpeed(samples, numSamples, speed, pitch, rate, volume, sampleRate, numChannels,
+			(*Stream).WriteFloatToStream, (*Stream).ReadFloatFromStream)
+	}
 	return int(C.sonicChangeFloatSpeed((*C.float)(unsafe.Pointer(&samples[0])), C.int(numSamples),
 		C.float(speed), C.float(pitch), C.float(rate), C.float(volume),
 		0, C.int(sampleRate), C.int(numChannels)))
@@ -171,12 +356,38 @@ func ChangeFloatSpeed(samples []float32, numSamples int, speed, pitch, rate, vol
 
 // ChangeShortSpeed is a non-stream-oriented interface to change the speed of short audio samples
 func ChangeShortSpeed(samples []int16, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	if numChannels > 1 {
+		return changeSpeed(samples, numSamples, speed, pitch, rate, volume, sampleRate, numChannels,
+			(*Stream).WriteShortToStream, (*Stream).ReadShortFromStream)
+	}
 	return int(C.sonicChangeShortSpeed((*C.short)(unsafe.Pointer(&samples[0])), C.int(numSamples),
 		C.float(speed), C.float(pitch), C.float(rate), C.float(volume),
 		0, C.int(sampleRate), C.int(numChannels)))
 }
 
-// The following symbols are not implemented yet (SONIC_SPECTROGRAM related features).
+// changeSpeed does what sonicChangeFloatSpeed and sonicChangeShortSpeed do, with a stream created
+// by CreateStream. libsonic creates its stream without the fix of reallocate, so multi-channel
+// samples would overflow its down-sample buffer.
+func changeSpeed[T int16 | float32](samples []T, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int,
+	write func(*Stream, []T, int) int, read func(*Stream, []T, int) int) int {
+	s, err := CreateStream(sampleRate, numChannels)
+	if err != nil {
+		return 0
+	}
+	defer s.DestroyStream()
+	s.SetSpeed(speed)
+	s.SetPitch(pitch)
+	s.SetRate(rate)
+	s.SetVolume(volume)
+	write(s, samples, numSamples)
+	s.FlushStream()
+	numSamples = s.SamplesAvailable()
+	read(s, samples, numSamples)
+	return numSamples
+}
+
+// The following symbols are not bound (SONIC_SPECTROGRAM related features): spectrogram.c is not
+// part of the vendored sources. The root package implements them in Go as sonic.Spectrogram.
 // void sonicComputeSpectrogram(sonicStream stream);
 // sonicSpectrogram sonicGetSpectrogram(sonicStream stream);
 // sonicSpectrogram sonicCreateSpectrogram(int sampleRate);
@@ -0,0 +1,34 @@
+//go:build cgo && !purego && !nosonic_cgo
+
+package cgosonic
+
+import "testing"
+
+func TestCreateDestroyStream_Libsonic(t *testing.T) {
+	s, err := CreateStream(testSampleRate, testNumChannels)
+	if err != nil {
+		t.Fatalf("CreateStream failed: %v", err)
+	}
+	if s.stream == nil {
+		t.Fatal("CreateStream returned stream with nil internal stream")
+	}
+	if s.CPointer() == nil {
+		t.Error("CPointer() = nil, want the internal stream")
+	}
+	s.DestroyStream()
+	if s.stream != nil {
+		t.Error("DestroyStream did not set internal stream to nil")
+	}
+	if s.CPointer() != nil {
+		t.Error("CPointer() after DestroyStream is not nil")
+	}
+
+	sClamped, err := CreateStream(0, 0)
+	if err != nil {
+		t.Fatalf("CreateStream failed: %v", err)
+	}
+	if sClamped.stream == nil {
+		t.Fatal("CreateStream returned stream with nil internal stream")
+	}
+	sClamped.DestroyStream()
+}
@@ -0,0 +1,44 @@
+//go:build !cgo || purego || nosonic_cgo
+
+package cgosonic
+
+import "github.com/nakat-t/sonic-go/internal/gosonic"
+
+const (
+	MIN_VOLUME        = gosonic.MIN_VOLUME
+	MAX_VOLUME        = gosonic.MAX_VOLUME
+	MIN_SPEED         = gosonic.MIN_SPEED
+	MAX_SPEED         = gosonic.MAX_SPEED
+	MIN_PITCH_SETTING = gosonic.MIN_PITCH_SETTING
+	MAX_PITCH_SETTING = gosonic.MAX_PITCH_SETTING
+	MIN_RATE          = gosonic.MIN_RATE
+	MAX_RATE          = gosonic.MAX_RATE
+	MIN_SAMPLE_RATE   = int(gosonic.MIN_SAMPLE_RATE)
+	MAX_SAMPLE_RATE   = int(gosonic.MAX_SAMPLE_RATE)
+	MIN_CHANNELS      = int(gosonic.MIN_CHANNELS)
+	MAX_CHANNELS      = int(gosonic.MAX_CHANNELS)
+	MIN_PITCH         = int(gosonic.MIN_PITCH)
+	MAX_PITCH         = int(gosonic.MAX_PITCH)
+)
+
+// Stream represents a SONIC audio stream
+//
+// In pure-Go builds, selected with the purego or nosonic_cgo build tag or by disabling cgo,
+// streams are implemented by the port of libsonic in internal/gosonic, which produces the same
+// output.
+type Stream = gosonic.Stream
+
+// CreateStream creates a new sonic stream
+func CreateStream(sampleRate int, numChannels int) (*Stream, error) {
+	return gosonic.CreateStream(sampleRate, numChannels)
+}
+
+// ChangeFloatSpeed is a non-stream-oriented interface to change the speed of float audio samples
+func ChangeFloatSpeed(samples []float32, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	return gosonic.ChangeFloatSpeed(samples, numSamples, speed, pitch, rate, volume, sampleRate, numChannels)
+}
+
+// ChangeShortSpeed is a non-stream-oriented interface to change the speed of short audio samples
+func ChangeShortSpeed(samples []int16, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	return gosonic.ChangeShortSpeed(samples, numSamples, speed, pitch, rate, volume, sampleRate, numChannels)
+}
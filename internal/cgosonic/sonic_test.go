@@ -2,6 +2,7 @@ package cgosonic
 
 import (
 	"math"
+	"slices"
 	"testing"
 )
 
@@ -23,9 +24,6 @@ func TestCreateDestroyStream(t *testing.T) {
 	if s == nil {
 		t.Fatal("CreateStream returned nil stream")
 	}
-	if s.stream == nil {
-		t.Fatal("CreateStream returned stream with nil internal stream")
-	}
 
 	if rate := s.GetSampleRate(); rate != testSampleRate {
 		t.Errorf("GetSampleRate() = %d, want %d", rate, testSampleRate)
@@ -35,9 +33,6 @@ func TestCreateDestroyStream(t *testing.T) {
 	}
 
 	s.DestroyStream()
-	if s.stream != nil {
-		t.Error("DestroyStream did not set internal stream to nil")
-	}
 
 	s.DestroyStream() // Test double destroy, should be a no-op
 
@@ -49,9 +44,6 @@ func TestCreateDestroyStream(t *testing.T) {
 	if sClamped == nil {
 		t.Fatal("CreateStream returned nil stream")
 	}
-	if sClamped.stream == nil {
-		t.Fatal("CreateStream returned stream with nil internal stream")
-	}
 	sClamped.DestroyStream()
 }
 
@@ -186,6 +178,84 @@ func TestStream_WriteReadShort(t *testing.T) {
 	}
 }
 
+func TestStream_WriteReadUnsignedChar(t *testing.T) {
+	s, err := CreateStream(testSampleRate, testNumChannels)
+	if err != nil {
+		t.Fatalf("CreateStream failed: %v", err)
+	}
+	defer s.DestroyStream()
+
+	// At speed 1 the stream passes the samples through, widened to short and narrowed back.
+	inputSamples := make([]uint8, 1024)
+	for i := range inputSamples {
+		inputSamples[i] = uint8(i)
+	}
+	if ret := s.WriteUnsignedCharToStream(inputSamples, len(inputSamples)); ret != 1 {
+		t.Errorf("WriteUnsignedCharToStream returned %d, want 1 (success)", ret)
+	}
+	if ret := s.FlushStream(); ret != 1 {
+		t.Errorf("FlushStream returned %d, want 1 (success)", ret)
+	}
+
+	outputSamples := make([]uint8, len(inputSamples))
+	numRead := s.ReadUnsignedCharFromStream(outputSamples, len(outputSamples))
+	if numRead != len(inputSamples) {
+		t.Fatalf("ReadUnsignedCharFromStream read %d samples, want %d", numRead, len(inputSamples))
+	}
+	for i, v := range outputSamples {
+		if v != inputSamples[i] {
+			t.Fatalf("sample %d = %d, want %d", i, v, inputSamples[i])
+		}
+	}
+	if s.ReadUnsignedCharFromStream(outputSamples, len(outputSamples)) != 0 {
+		t.Error("ReadUnsignedCharFromStream read samples from a drained stream")
+	}
+}
+
+func TestStream_WriteReadShortMultiChannel(t *testing.T) {
+	const numChannels = 2
+	for _, name := range []string{"CreateStream", "SetNumChannels"} {
+		t.Run(name, func(t *testing.T) {
+			var s *Stream
+			var err error
+			if name == "CreateStream" {
+				s, err = CreateStream(testSampleRate, numChannels)
+			} else {
+				s, err = CreateStream(testSampleRate, 1)
+				if err == nil {
+					s.SetNumChannels(numChannels)
+				}
+			}
+			if err != nil {
+				t.Fatalf("CreateStream failed: %v", err)
+			}
+			defer s.DestroyStream()
+
+			// Multi-channel pitch detection down samples the input twice; libsonic allocates the
+			// down-sample buffer for the first pass only, see reallocate.
+			s.SetSpeed(2.0)
+
+			inputSamples := make([]int16, 4410*numChannels)
+			for i := range inputSamples {
+				inputSamples[i] = int16((i * 37) % 8000)
+			}
+			if ret := s.WriteShortToStream(inputSamples, len(inputSamples)/numChannels); ret != 1 {
+				t.Errorf("WriteShortToStream returned %d, want 1 (success)", ret)
+			}
+			if ret := s.FlushStream(); ret != 1 {
+				t.Errorf("FlushStream returned %d, want 1 (success)", ret)
+			}
+
+			available := s.SamplesAvailable()
+			outputSamples := make([]int16, available*numChannels)
+			numRead := s.ReadShortFromStream(outputSamples, available)
+			if numRead != available {
+				t.Errorf("ReadShortFromStream read %d frames, want %d", numRead, available)
+			}
+		})
+	}
+}
+
 func TestStream_SetGetters(t *testing.T) {
 	s, err := CreateStream(testSampleRate, testNumChannels)
 	if err != nil {
@@ -243,6 +313,12 @@ func TestStream_SetGetters(t *testing.T) {
 		t.Errorf("GetQuality() after SetQuality(%d) = %d, want %d", newQuality, val, newQuality)
 	}
 
+	// ChordPitch is deprecated, and stays off
+	s.SetChordPitch(1)
+	if val := s.GetChordPitch(); val != 0 {
+		t.Errorf("GetChordPitch() after SetChordPitch(1) = %d, want 0", val)
+	}
+
 	// SampleRate
 	newSampleRate := 22050
 	s.SetSampleRate(newSampleRate)
@@ -368,3 +444,216 @@ func TestChangeShortSpeed(t *testing.T) {
 		t.Errorf("ChangeShortSpeed with 0 input samples returned %d, want 0", numSamplesOutZeroIn)
 	}
 }
+
+func TestChangeSpeed_MultiChannel(t *testing.T) {
+	const numChannels = 2
+	const numSamples = 4410
+	shorts := make([]int16, numSamples*numChannels)
+	floats := make([]float32, numSamples*numChannels)
+	for i := range shorts {
+		shorts[i] = int16((i / numChannels * 37) % 8000)
+		floats[i] = float32(shorts[i]) / 32767
+	}
+
+	n := ChangeShortSpeed(shorts, numSamples, 2.0, 1.0, 1.0, 1.0, testSampleRate, numChannels)
+	if n <= 0 || n > numSamples {
+		t.Fatalf("ChangeShortSpeed returned %d frames, want (0, %d]", n, numSamples)
+	}
+	for i := range n {
+		if shorts[2*i] != shorts[2*i+1] {
+			t.Fatalf("ChangeShortSpeed frame %d = %v, want equal channels", i, shorts[2*i:2*i+2])
+		}
+	}
+	if m := ChangeFloatSpeed(floats, numSamples, 2.0, 1.0, 1.0, 1.0, testSampleRate, numChannels); m != n {
+		t.Errorf("ChangeFloatSpeed returned %d frames, want %d", m, n)
+	}
+}
+
+func TestChangeShortSpeed_VolumeSaturates(t *testing.T) {
+	const numSamples = 4410
+	samples := make([]int16, numSamples)
+	for i := range samples {
+		if (i/100)%2 == 0 {
+			samples[i] = math.MaxInt16
+		} else {
+			samples[i] = math.MinInt16
+		}
+	}
+	original := append([]int16(nil), samples...)
+
+	n := ChangeShortSpeed(samples, numSamples, 1.0, 1.0, 1.0, MAX_VOLUME, testSampleRate, testNumChannels)
+	if n != numSamples {
+		t.Fatalf("ChangeShortSpeed returned %d samples, want %d", n, numSamples)
+	}
+	for i := range n {
+		if (samples[i] < 0) != (original[i] < 0) {
+			t.Fatalf("sample %d = %d wrapped around (input %d)", i, samples[i], original[i])
+		}
+	}
+}
+
+func TestStream_PendingInputFrames(t *testing.T) {
+	for _, speed := range []float32{0.5, 1.0, 2.0} {
+		s, err := CreateStream(testSampleRate, testNumChannels)
+		if err != nil {
+			t.Fatalf("CreateStream failed: %v", err)
+		}
+		s.SetSpeed(speed)
+
+		if got := s.PendingInputFrames(); got != 0 {
+			t.Errorf("speed %v: PendingInputFrames() of a new stream = %d, want 0", speed, got)
+		}
+
+		// Feed a periodic signal in small chunks while draining the output, as a live application would.
+		inputSamples := make([]int16, 441)
+		out := make([]int16, 4096)
+		maxPending := 0
+		for i := range 100 {
+			for j := range inputSamples {
+				inputSamples[j] = int16(8000 * math.Sin(2*math.Pi*200*float64(i*len(inputSamples)+j)/testSampleRate))
+			}
+			s.WriteShortToStream(inputSamples, len(inputSamples))
+			for s.ReadShortFromStream(out, len(out)) > 0 {
+			}
+			pending := s.PendingInputFrames()
+			maxPending = max(maxPending, pending)
+		}
+		// The stream needs a few pitch periods of look-ahead when changing speed (none at 1.0),
+		// but must not accumulate input.
+		if (speed != 1.0 && maxPending == 0) || maxPending > testSampleRate/10 {
+			t.Errorf("speed %v: max PendingInputFrames() = %d, want within (0, %d]", speed, maxPending, testSampleRate/10)
+		}
+
+		s.FlushStream()
+		if got := s.PendingInputFrames(); got != 0 {
+			t.Errorf("speed %v: PendingInputFrames() after flush = %d, want 0", speed, got)
+		}
+		for s.ReadShortFromStream(out, len(out)) > 0 {
+		}
+		if got := s.PendingInputFrames(); got != 0 {
+			t.Errorf("speed %v: PendingInputFrames() after draining flushed output = %d, want 0", speed, got)
+		}
+		s.DestroyStream()
+	}
+}
+
+func TestStream_FlushAndRead(t *testing.T) {
+	// The combined helpers must produce the same output as FlushStream followed by reads.
+	newStream := func() *Stream {
+		s, err := CreateStream(testSampleRate, testNumChannels)
+		if err != nil {
+			t.Fatalf("CreateStream failed: %v", err)
+		}
+		s.SetSpeed(1.5)
+		in := make([]float32, 3000)
+		for i := range in {
+			in[i] = float32(0.5 * math.Sin(2*math.Pi*200*float64(i)/testSampleRate))
+		}
+		s.WriteFloatToStream(in, len(in))
+		return s
+	}
+
+	want := newStream()
+	defer want.DestroyStream()
+	if want.FlushStream() == 0 {
+		t.Fatal("FlushStream failed")
+	}
+	wantOut := make([]float32, 8192)
+	wantN := want.ReadFloatFromStream(wantOut, len(wantOut))
+
+	got := newStream()
+	defer got.DestroyStream()
+	gotOut := make([]float32, 8192)
+	gotN := got.FlushAndReadFloat(gotOut, len(gotOut))
+	if gotN != wantN || gotN <= 0 {
+		t.Fatalf("FlushAndReadFloat() = %d, want %d", gotN, wantN)
+	}
+	for i := range gotN {
+		if gotOut[i] != wantOut[i] {
+			t.Fatalf("sample %d = %v, want %v", i, gotOut[i], wantOut[i])
+		}
+	}
+	if got.PendingInputFrames() != 0 || got.SamplesAvailable() != 0 {
+		t.Errorf("after FlushAndReadFloat: pending = %d, available = %d, want 0, 0", got.PendingInputFrames(), got.SamplesAvailable())
+	}
+
+	s, err := CreateStream(testSampleRate, testNumChannels)
+	if err != nil {
+		t.Fatalf("CreateStream failed: %v", err)
+	}
+	defer s.DestroyStream()
+	shortIn := make([]int16, 3000)
+	for i := range shortIn {
+		shortIn[i] = int16(8000 * math.Sin(2*math.Pi*200*float64(i)/testSampleRate))
+	}
+	s.SetSpeed(1.5)
+	s.WriteShortToStream(shortIn, len(shortIn))
+	shortOut := make([]int16, 100)
+	n := s.FlushAndReadShort(shortOut, len(shortOut))
+	if n != len(shortOut) {
+		t.Errorf("FlushAndReadShort() = %d, want %d", n, len(shortOut))
+	}
+	if s.SamplesAvailable() == 0 {
+		t.Error("FlushAndReadShort() should leave the rest of the flushed output in the stream")
+	}
+}
+
+func TestStream_ReadInto(t *testing.T) {
+	const numChannels = 2
+	const numFrames = 1000
+
+	input := make([]int16, numFrames*numChannels)
+	for i := range input {
+		input[i] = int16(i * 7)
+	}
+	newStream := func(t *testing.T) *Stream {
+		t.Helper()
+		s, err := CreateStream(testSampleRate, numChannels)
+		if err != nil {
+			t.Fatalf("CreateStream failed: %v", err)
+		}
+		t.Cleanup(s.DestroyStream)
+		s.SetSpeed(1.5)
+		if s.WriteShortToStream(input, numFrames) != 1 || s.FlushStream() != 1 {
+			t.Fatal("writing to the stream failed")
+		}
+		return s
+	}
+
+	ref := newStream(t)
+	want := make([]int16, ref.SamplesAvailable()*numChannels)
+	ref.ReadShortFromStream(want, len(want)/numChannels)
+
+	t.Run("short", func(t *testing.T) {
+		s := newStream(t)
+		var got []int16
+		buf := make([]int16, 0, 301) // Odd capacity: only whole frames must be read
+		for {
+			n := s.ReadShortInto(buf)
+			if n > cap(buf)/numChannels {
+				t.Fatalf("ReadShortInto() = %d, want at most %d", n, cap(buf)/numChannels)
+			}
+			if n == 0 {
+				break
+			}
+			got = append(got, buf[:n*numChannels]...)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("ReadShortInto() read %d samples differing from ReadShortFromStream (%d samples)", len(got), len(want))
+		}
+		if s.PendingInputFrames() != 0 {
+			t.Errorf("PendingInputFrames() = %d after reading everything, want 0", s.PendingInputFrames())
+		}
+	})
+
+	t.Run("float", func(t *testing.T) {
+		s := newStream(t)
+		buf := make([]float32, 0, len(want)+10)
+		if n := s.ReadFloatInto(buf); n != len(want)/numChannels {
+			t.Errorf("ReadFloatInto() = %d, want %d", n, len(want)/numChannels)
+		}
+		if n := s.ReadFloatInto(nil); n != 0 {
+			t.Errorf("ReadFloatInto(nil) = %d, want 0", n)
+		}
+	})
+}
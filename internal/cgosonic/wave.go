@@ -1,81 +1,555 @@
 package cgosonic
 
-/*
-#cgo CFLAGS: -Wall -Wno-unused-function -g -ansi -fPIC -pthread -I${SRCDIR}
-#include <stdlib.h>
-#include "wave.h"
-*/
-import "C"
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
 	"os"
-	"unsafe"
+	"slices"
+	"time"
+
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/wav"
 )
 
-// WaveFile represents a WAVE file
+// UnsupportedCodecError is returned when a WAVE file is encoded with a codec the reader cannot
+// decode, see wav.UnsupportedCodecError. The reader supports 16-bit PCM and 32-bit IEEE float.
+type UnsupportedCodecError = wav.UnsupportedCodecError
+
+var (
+	// ErrInvalidWaveHeader is returned when the header of a WAVE file is malformed.
+	ErrInvalidWaveHeader = errors.New("invalid wave header")
+
+	// ErrWaveLimitExceeded is returned when a WAVE file exceeds the configured WaveLimits.
+	ErrWaveLimitExceeded = errors.New("wave file exceeds limits")
+)
+
+// WaveLimits bounds the values accepted from WAVE headers, so that a crafted header cannot make
+// callers allocate huge buffers.
+type WaveLimits struct {
+	MaxDataBytes  int64 // Maximum size of the sample data
+	MaxChannels   int   // Maximum number of channels
+	MaxSampleRate int   // Maximum sample rate
+
+	MaxMetadataBytes int64 // Maximum total size of the metadata chunks kept in memory
+}
+
+// DefaultWaveLimits are the limits used by OpenInputWaveFile.
+var DefaultWaveLimits = WaveLimits{
+	MaxDataBytes:  1 << 31,
+	MaxChannels:   MAX_CHANNELS,
+	MaxSampleRate: MAX_SAMPLE_RATE,
+
+	MaxMetadataBytes: 1 << 20,
+}
+
+// Metadata holds the metadata chunks of a WAVE file, see wav.Metadata.
+type Metadata = wav.Metadata
+
+// WaveFile represents a WAVE file.
+//
+// 16-bit PCM files are handled by the wave file support of libsonic, and 32-bit IEEE float files,
+// which it does not support, in Go. Pure-Go builds, which have no libsonic, handle PCM files in Go
+// as well. Samples are converted if they are read or written in the other format, using the
+// scaling by 32767 of libsonic.
 type WaveFile struct {
-	file C.waveFile
+	file     *libsonicWaveFile // libsonic wave file of a PCM file, nil for a file handled in Go
+	native   *os.File          // File handled in Go, nil for a libsonic file
+	data     io.Reader         // Sample data of an input file handled in Go
+	written  int64             // Bytes of samples written to an output file handled in Go
+	buf      []byte
+	header   waveHeader
+	metadata Metadata
+	fileName string // Name of an output file, to append the metadata on close
 }
 
-// OpenInputWaveFile opens an input WAVE file
+// OpenInputWaveFile opens an input WAVE file with DefaultWaveLimits.
 func OpenInputWaveFile(fileName string) (*WaveFile, int, int, error) {
+	return OpenInputWaveFileWithLimits(fileName, DefaultWaveLimits)
+}
+
+// OpenInputWaveFileWithLimits opens an input WAVE file, rejecting files that exceed limits.
+func OpenInputWaveFileWithLimits(fileName string, limits WaveLimits) (*WaveFile, int, int, error) {
 	// openInputWaveFile outputs to stderr if file open fails.
 	// So, check here to prevent output.
 	f, err := os.Open(fileName)
 	if err != nil {
 		return nil, 0, 0, err
 	}
+	header, metadata, err := readWaveHeader(f, limits)
 	f.Close()
+	if err != nil {
+		return nil, 0, 0, err
+	}
 
-	var sampleRate C.int
-	var numChannels C.int
-	cFileName := C.CString(fileName)
-	defer C.free(unsafe.Pointer(cFileName))
+	if header.formatTag == wav.FormatTagIEEEFloat || !libsonicWave || !header.libsonicLayout {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		data := io.NewSectionReader(f, header.dataOffset, header.dataBytes)
+		return &WaveFile{native: f, data: data, header: header, metadata: metadata}, header.sampleRate, header.numChannels, nil
+	}
 
-	file := C.openInputWaveFile(cFileName, &sampleRate, &numChannels)
-	if file == nil {
-		return nil, 0, 0, errors.New("failed to open input wave file")
+	file, sampleRate, numChannels, err := openLibsonicInputWaveFile(fileName)
+	if err != nil {
+		return nil, 0, 0, err
 	}
-	return &WaveFile{file: file}, int(sampleRate), int(numChannels), nil
+	return &WaveFile{file: file, header: header, metadata: metadata}, sampleRate, numChannels, nil
+}
+
+// waveHeader holds the fields of a WAVE header.
+type waveHeader struct {
+	formatTag     int
+	numChannels   int
+	sampleRate    int
+	bitsPerSample int
+	dataBytes     int64 // Size of the sample data, limited to what the file actually contains
+	dataOffset    int64 // Offset of the sample data in the file
+
+	// libsonicLayout reports whether readHeader in wave.c can parse the file. It expects the
+	// fmt chunk first, skips chunks without their padding byte and loops forever on what it cannot
+	// parse, and reads samples up to the end of the file in frames of at most waveBufLen bytes.
+	libsonicLayout bool
+}
+
+// waveBufLen is WAVE_BUF_LEN in wave.c, the size of its sample buffer.
+const waveBufLen = 4096
+
+// readWaveHeader reads and validates the header of the WAVE file f, and collects its metadata
+// chunks before and after the data chunk. It returns an *UnsupportedCodecError if the fmt chunk
+// describes anything other than 16-bit PCM or 32-bit IEEE float.
+//
+// Files that wave.c cannot read safely are detected here rather than in C, see
+// waveHeader.libsonicLayout.
+//
+// All sizes are checked against the size of f, so a header claiming more data than the file
+// contains is accepted, as written by streaming encoders, but the reported data size is limited to
+// the actual data.
+func readWaveHeader(f *os.File, limits WaveLimits) (waveHeader, Metadata, error) {
+	var h waveHeader
+	m := Metadata{}
+	metadataBytes := int64(0)
+	info, err := f.Stat()
+	if err != nil {
+		return h, nil, err
+	}
+	fileSize := info.Size()
+
+	var riff [12]byte
+	if _, err := io.ReadFull(f, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return h, nil, fmt.Errorf("%w: not a RIFF/WAVE file", ErrInvalidWaveHeader)
+	}
+	offset := int64(len(riff))
+	haveFmt := false
+	evenChunks := true // No chunk between fmt and data has a padding byte
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(f, chunk[:]); err != nil {
+			return h, nil, fmt.Errorf("%w: no data chunk", ErrInvalidWaveHeader)
+		}
+		offset += int64(len(chunk))
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8])) // At most 4 GiB, so offset arithmetic cannot overflow
+		remaining := fileSize - offset
+
+		if id == "data" {
+			if !haveFmt {
+				return h, nil, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalidWaveHeader)
+			}
+			h.dataBytes = size
+			h.dataOffset = offset
+			h.libsonicLayout = h.libsonicLayout && evenChunks && size >= remaining &&
+				h.numChannels*2 <= waveBufLen
+			if h.dataBytes > remaining {
+				h.dataBytes = remaining
+			}
+			if h.dataBytes > limits.MaxDataBytes {
+				return h, nil, fmt.Errorf("%w: %d bytes of data, limit is %d", ErrWaveLimitExceeded, h.dataBytes, limits.MaxDataBytes)
+			}
+			if size > remaining {
+				return h, m, nil // Streaming header: the data extends to the end of the file.
+			}
+			// Metadata may follow the data. Chunks there are optional, so a truncated or malformed
+			// tail is ignored instead of failing the whole file.
+			if _, err := f.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return h, m, nil
+			}
+			offset += size + size%2
+			for {
+				var chunk [8]byte
+				if _, err := io.ReadFull(f, chunk[:]); err != nil {
+					return h, m, nil
+				}
+				offset += int64(len(chunk))
+				id := string(chunk[0:4])
+				size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+				if size > fileSize-offset {
+					return h, m, nil
+				}
+				if wav.IsMetadataChunk(id) {
+					if err := readMetadataChunk(f, m, id, size, &metadataBytes, limits); err != nil {
+						return h, nil, err
+					}
+				} else if _, err := f.Seek(size, io.SeekCurrent); err != nil {
+					return h, m, nil
+				}
+				if _, err := f.Seek(size%2, io.SeekCurrent); err != nil {
+					return h, m, nil
+				}
+				offset += size + size%2
+			}
+		}
+		if size > remaining {
+			return h, nil, fmt.Errorf("%w: %q chunk of %d bytes exceeds the file", ErrInvalidWaveHeader, id, size)
+		}
+		if haveFmt && (size%2 != 0 || size > math.MaxInt32) {
+			evenChunks = false
+		}
+		if wav.IsMetadataChunk(id) {
+			if err := readMetadataChunk(f, m, id, size, &metadataBytes, limits); err != nil {
+				return h, nil, err
+			}
+			if _, err := f.Seek(size%2, io.SeekCurrent); err != nil {
+				return h, nil, err
+			}
+			offset += size + size%2
+			continue
+		}
+		if id != "fmt " {
+			if _, err := f.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return h, nil, err
+			}
+			offset += size + size%2
+			continue
+		}
+
+		var fmtChunk [16]byte
+		h.libsonicLayout = offset == 20 && (size == 16 || size == 18)
+		if size < int64(len(fmtChunk)) {
+			return h, nil, fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidWaveHeader, size)
+		}
+		if _, err := io.ReadFull(f, fmtChunk[:]); err != nil {
+			return h, nil, fmt.Errorf("%w: truncated fmt chunk", ErrInvalidWaveHeader)
+		}
+		if _, err := f.Seek(size+size%2-int64(len(fmtChunk)), io.SeekCurrent); err != nil {
+			return h, nil, err
+		}
+		offset += size + size%2
+		h.formatTag = int(binary.LittleEndian.Uint16(fmtChunk[0:2]))
+		h.numChannels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+		h.sampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+		h.bitsPerSample = int(binary.LittleEndian.Uint16(fmtChunk[14:16]))
+		pcm16 := h.formatTag == wav.FormatTagPCM && h.bitsPerSample == 16
+		ieeeFloat := h.formatTag == wav.FormatTagIEEEFloat && h.bitsPerSample == 32
+		if !pcm16 && !ieeeFloat {
+			return h, nil, &UnsupportedCodecError{FormatTag: h.formatTag, BitsPerSample: h.bitsPerSample}
+		}
+		if h.numChannels < 1 || h.sampleRate < 1 {
+			return h, nil, fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidWaveHeader, h.numChannels, h.sampleRate)
+		}
+		if h.numChannels > limits.MaxChannels {
+			return h, nil, fmt.Errorf("%w: %d channels, limit is %d", ErrWaveLimitExceeded, h.numChannels, limits.MaxChannels)
+		}
+		if h.sampleRate > limits.MaxSampleRate {
+			return h, nil, fmt.Errorf("%w: sample rate %d, limit is %d", ErrWaveLimitExceeded, h.sampleRate, limits.MaxSampleRate)
+		}
+		haveFmt = true
+	}
+}
+
+// readMetadataChunk reads the contents of the chunk id of size bytes from f into m.
+// total accumulates the size of all metadata read so far, which is checked against limits.
+func readMetadataChunk(f *os.File, m Metadata, id string, size int64, total *int64, limits WaveLimits) error {
+	*total += size
+	if *total > limits.MaxMetadataBytes {
+		return fmt.Errorf("%w: more than %d bytes of metadata", ErrWaveLimitExceeded, limits.MaxMetadataBytes)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return fmt.Errorf("%w: truncated %q chunk", ErrInvalidWaveHeader, id)
+	}
+	m.AddChunk(id, data)
+	return nil
 }
 
 // OpenOutputWaveFile opens an output WAVE file
 func OpenOutputWaveFile(fileName string, sampleRate int, numChannels int) (*WaveFile, error) {
 	// openOutputWaveFile outputs to stderr if file open fails.
 	// So, check here to prevent output.
+	header := waveHeader{
+		formatTag:     wav.FormatTagPCM,
+		numChannels:   numChannels,
+		sampleRate:    sampleRate,
+		bitsPerSample: 16,
+		dataBytes:     0,
+	}
+	if !libsonicWave {
+		header.dataOffset = 44
+		return openNativeOutputWaveFile(fileName, header)
+	}
+
 	f, err := os.Create(fileName)
 	if err != nil {
 		return nil, err
 	}
 	f.Close()
 
-	cFileName := C.CString(fileName)
-	defer C.free(unsafe.Pointer(cFileName))
+	file, err := openLibsonicOutputWaveFile(fileName, sampleRate, numChannels)
+	if err != nil {
+		return nil, err
+	}
+	return &WaveFile{file: file, header: header, metadata: Metadata{}, fileName: fileName}, nil
+}
+
+// OpenOutputFloatWaveFile opens an output WAVE file of 32-bit IEEE float samples.
+func OpenOutputFloatWaveFile(fileName string, sampleRate int, numChannels int) (*WaveFile, error) {
+	header := waveHeader{
+		formatTag:     wav.FormatTagIEEEFloat,
+		numChannels:   numChannels,
+		sampleRate:    sampleRate,
+		bitsPerSample: 32,
+		dataBytes:     0,
+		dataOffset:    44,
+	}
+	return openNativeOutputWaveFile(fileName, header)
+}
+
+// openNativeOutputWaveFile opens an output WAVE file described by header, which is handled in Go.
+func openNativeOutputWaveFile(fileName string, header waveHeader) (*WaveFile, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(header.bytes(0)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &WaveFile{native: f, header: header, metadata: Metadata{}, fileName: fileName}, nil
+}
+
+// bytes returns the canonical 44-byte header of a file with dataBytes bytes of samples.
+func (h waveHeader) bytes(dataBytes int64) []byte {
+	blockAlign := h.numChannels * h.bitsPerSample / 8
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+dataBytes))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.formatTag))
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.numChannels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(h.sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(h.sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.bitsPerSample))
+	b = append(b, "data"...)
+	return binary.LittleEndian.AppendUint32(b, uint32(dataBytes))
+}
+
+// Metadata returns the metadata chunks of the WAVE file.
+// For output files, these are the chunks set with SetMetadata.
+func (w *WaveFile) Metadata() Metadata {
+	return maps.Clone(w.metadata)
+}
+
+// SetMetadata sets the metadata chunks of an output WAVE file, e.g. to pass through the metadata
+// of the input file. They are appended after the sample data when the file is closed.
+func (w *WaveFile) SetMetadata(m Metadata) {
+	w.metadata = maps.Clone(m)
+}
+
+// SampleRate returns the sample rate of the WAVE file.
+func (w *WaveFile) SampleRate() int {
+	return w.header.sampleRate
+}
+
+// NumChannels returns the number of channels of the WAVE file.
+func (w *WaveFile) NumChannels() int {
+	return w.header.numChannels
+}
+
+// BitsPerSample returns the number of bits per sample of the WAVE file.
+func (w *WaveFile) BitsPerSample() int {
+	return w.header.bitsPerSample
+}
 
-	file := C.openOutputWaveFile(cFileName, C.int(sampleRate), C.int(numChannels))
-	if file == nil {
-		return nil, errors.New("failed to open output wave file")
+// FormatTag returns the format tag of the WAVE file, e.g. wav.FormatTagPCM.
+func (w *WaveFile) FormatTag() int {
+	return w.header.formatTag
+}
+
+// DataBytes returns the size of the sample data of an input WAVE file in bytes.
+// It is limited to the data actually present in the file. It is 0 for output files.
+func (w *WaveFile) DataBytes() int64 {
+	return w.header.dataBytes
+}
+
+// NumFrames returns the number of sample frames of an input WAVE file.
+// It is 0 for output files.
+func (w *WaveFile) NumFrames() int64 {
+	frameBytes := int64(w.header.numChannels * w.header.bitsPerSample / 8)
+	if frameBytes == 0 {
+		return 0
+	}
+	return w.header.dataBytes / frameBytes
+}
+
+// Duration returns the playback duration of an input WAVE file.
+// It is 0 for output files.
+func (w *WaveFile) Duration() time.Duration {
+	if w.header.sampleRate == 0 {
+		return 0
 	}
-	return &WaveFile{file: file}, nil
+	frames, rate := w.NumFrames(), int64(w.header.sampleRate)
+	return time.Duration(frames/rate)*time.Second + time.Duration(frames%rate)*time.Second/time.Duration(rate)
 }
 
 // CloseWaveFile closes a WAVE file
 func (w *WaveFile) CloseWaveFile() int {
+	if w.native != nil {
+		return w.closeNative()
+	}
 	if w.file == nil {
 		// closeWaveFile returns 1 if success, 0 if fail.
 		return 1
 	}
-	result := C.closeWaveFile(w.file)
+	result := w.file.close()
 	w.file = nil
-	return int(result)
+	if result != 0 && w.fileName != "" && len(w.metadata) > 0 {
+		if err := appendMetadata(w.fileName, w.metadata); err != nil {
+			return 0
+		}
+	}
+	return result
 }
 
-// ReadFromWaveFile reads samples from a WAVE file
+// appendMetadata appends the chunks of m to the WAVE file fileName and updates its RIFF size.
+func appendMetadata(fileName string, m Metadata) error {
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	b := m.AppendChunks(nil)
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	var riffSize [4]byte
+	binary.LittleEndian.PutUint32(riffSize[:], uint32(end+int64(len(b))-8))
+	_, err = f.WriteAt(riffSize[:], 4)
+	return err
+}
+
+// closeNative closes a file handled in Go, completing the header of an output file.
+func (w *WaveFile) closeNative() int {
+	f := w.native
+	w.native = nil
+	w.data = nil
+	result := 1
+	if w.fileName != "" {
+		if _, err := f.WriteAt(w.header.bytes(w.written), 0); err != nil {
+			result = 0
+		}
+	}
+	if err := f.Close(); err != nil {
+		result = 0
+	}
+	if result != 0 && w.fileName != "" && len(w.metadata) > 0 {
+		if err := appendMetadata(w.fileName, w.metadata); err != nil {
+			return 0
+		}
+	}
+	return result
+}
+
+// ReadFromWaveFile reads samples from a WAVE file.
+// maxSamples and the result are numbers of frames; the result is 0 at the end or on failure.
 func (w *WaveFile) ReadFromWaveFile(buffer []int16, maxSamples int) int {
-	return int(C.readFromWaveFile(w.file, (*C.short)(unsafe.Pointer(&buffer[0])), C.int(maxSamples)))
+	numChannels := w.header.numChannels
+	if w.native == nil {
+		return w.file.read(buffer, maxSamples)
+	}
+	if w.header.formatTag == wav.FormatTagPCM {
+		b := w.readData(maxSamples)
+		pcm.DecodeInt16(buffer[:len(b)/2], b)
+		return len(b) / 2 / numChannels
+	}
+	samples := make([]float32, maxSamples*numChannels)
+	n := w.ReadFloatFromWaveFile(samples, maxSamples)
+	pcm.Float32ToInt16(buffer[:n*numChannels], samples[:n*numChannels], pcm.Scaling32767)
+	return n
 }
 
-// WriteToWaveFile writes samples to a WAVE file
+// WriteToWaveFile writes samples to a WAVE file.
+// numSamples is the number of frames. It returns 1 on success and 0 on failure.
 func (w *WaveFile) WriteToWaveFile(buffer []int16, numSamples int) int {
-	return int(C.writeToWaveFile(w.file, (*C.short)(unsafe.Pointer(&buffer[0])), C.int(numSamples)))
+	samples := buffer[:numSamples*w.header.numChannels]
+	if w.native == nil {
+		return w.file.write(buffer, numSamples)
+	}
+	if w.header.formatTag == wav.FormatTagPCM {
+		w.buf = pcm.EncodeInt16(w.buf[:0], samples)
+		return w.writeData(w.buf)
+	}
+	return w.WriteFloatToWaveFile(pcm.Int16ToFloat32(nil, samples, pcm.Scaling32767), numSamples)
+}
+
+// ReadFloatFromWaveFile reads float samples from a WAVE file, like ReadFromWaveFile.
+func (w *WaveFile) ReadFloatFromWaveFile(buffer []float32, maxSamples int) int {
+	numChannels := w.header.numChannels
+	if w.native == nil || w.header.formatTag == wav.FormatTagPCM {
+		samples := make([]int16, maxSamples*numChannels)
+		n := w.ReadFromWaveFile(samples, maxSamples)
+		pcm.Int16ToFloat32(buffer[:n*numChannels], samples[:n*numChannels], pcm.Scaling32767)
+		return n
+	}
+	b := w.readData(maxSamples)
+	pcm.DecodeFloat32(buffer[:len(b)/4], b)
+	return len(b) / 4 / numChannels
+}
+
+// WriteFloatToWaveFile writes float samples to a WAVE file, like WriteToWaveFile.
+func (w *WaveFile) WriteFloatToWaveFile(buffer []float32, numSamples int) int {
+	samples := buffer[:numSamples*w.header.numChannels]
+	if w.native == nil || w.header.formatTag == wav.FormatTagPCM {
+		out := pcm.Float32ToInt16(nil, samples, pcm.Scaling32767)
+		if len(out) == 0 {
+			return 1
+		}
+		return w.WriteToWaveFile(out, numSamples)
+	}
+	w.buf = pcm.EncodeFloat32(w.buf[:0], samples)
+	return w.writeData(w.buf)
+}
+
+// readData reads up to maxSamples frames from the sample data of an input file handled in Go,
+// and returns the bytes of the whole frames read.
+func (w *WaveFile) readData(maxSamples int) []byte {
+	if w.data == nil {
+		return nil
+	}
+	frameSize := w.header.numChannels * w.header.bitsPerSample / 8
+	w.buf = slices.Grow(w.buf[:0], maxSamples*frameSize)[:maxSamples*frameSize]
+	n, _ := io.ReadFull(w.data, w.buf)
+	return w.buf[:n/frameSize*frameSize]
+}
+
+// writeData writes the sample bytes b to an output file handled in Go. It returns 1 on success and
+// 0 on failure.
+func (w *WaveFile) writeData(b []byte) int {
+	if w.fileName == "" {
+		return 0
+	}
+	if _, err := w.native.Write(b); err != nil {
+		return 0
+	}
+	w.written += int64(len(b))
+	return 1
 }
@@ -0,0 +1,63 @@
+//go:build cgo && !purego && !nosonic_cgo
+
+package cgosonic
+
+/*
+#cgo CFLAGS: -Wall -Wno-unused-function -g -std=gnu89 -fPIC -pthread -I${SRCDIR}
+#include <stdlib.h>
+#include "wave.h"
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// libsonicWave reports whether 16-bit PCM files are handled by the wave file support of libsonic.
+const libsonicWave = true
+
+// libsonicWaveFile is a 16-bit PCM file handled by the wave file support of libsonic.
+type libsonicWaveFile struct {
+	file C.waveFile
+}
+
+// openLibsonicInputWaveFile opens an input 16-bit PCM file with libsonic.
+func openLibsonicInputWaveFile(fileName string) (*libsonicWaveFile, int, int, error) {
+	var sampleRate C.int
+	var numChannels C.int
+	cFileName := C.CString(fileName)
+	defer C.free(unsafe.Pointer(cFileName))
+
+	file := C.openInputWaveFile(cFileName, &sampleRate, &numChannels)
+	if file == nil {
+		return nil, 0, 0, errors.New("failed to open input wave file")
+	}
+	return &libsonicWaveFile{file: file}, int(sampleRate), int(numChannels), nil
+}
+
+// openLibsonicOutputWaveFile opens an output 16-bit PCM file with libsonic.
+func openLibsonicOutputWaveFile(fileName string, sampleRate int, numChannels int) (*libsonicWaveFile, error) {
+	cFileName := C.CString(fileName)
+	defer C.free(unsafe.Pointer(cFileName))
+
+	file := C.openOutputWaveFile(cFileName, C.int(sampleRate), C.int(numChannels))
+	if file == nil {
+		return nil, errors.New("failed to open output wave file")
+	}
+	return &libsonicWaveFile{file: file}, nil
+}
+
+// read reads up to maxSamples frames, like readFromWaveFile.
+func (f *libsonicWaveFile) read(buffer []int16, maxSamples int) int {
+	return int(C.readFromWaveFile(f.file, (*C.short)(unsafe.Pointer(&buffer[0])), C.int(maxSamples)))
+}
+
+// write writes numSamples frames, like writeToWaveFile.
+func (f *libsonicWaveFile) write(buffer []int16, numSamples int) int {
+	return int(C.writeToWaveFile(f.file, (*C.short)(unsafe.Pointer(&buffer[0])), C.int(numSamples)))
+}
+
+// close closes the file, like closeWaveFile.
+func (f *libsonicWaveFile) close() int {
+	return int(C.closeWaveFile(f.file))
+}
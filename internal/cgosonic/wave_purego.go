@@ -0,0 +1,27 @@
+//go:build !cgo || purego || nosonic_cgo
+
+package cgosonic
+
+import "errors"
+
+// libsonicWave reports whether 16-bit PCM files are handled by the wave file support of libsonic.
+// Pure-Go builds handle them in Go.
+const libsonicWave = false
+
+// errNoLibsonicWave is returned by the libsonic functions, which are not called in pure-Go builds.
+var errNoLibsonicWave = errors.New("libsonic wave files are not available in pure-Go builds")
+
+// libsonicWaveFile is never created in pure-Go builds.
+type libsonicWaveFile struct{}
+
+func openLibsonicInputWaveFile(string) (*libsonicWaveFile, int, int, error) {
+	return nil, 0, 0, errNoLibsonicWave
+}
+
+func openLibsonicOutputWaveFile(string, int, int) (*libsonicWaveFile, error) {
+	return nil, errNoLibsonicWave
+}
+
+func (f *libsonicWaveFile) read([]int16, int) int  { return 0 }
+func (f *libsonicWaveFile) write([]int16, int) int { return 0 }
+func (f *libsonicWaveFile) close() int             { return 0 }
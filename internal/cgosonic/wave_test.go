@@ -1,12 +1,26 @@
 package cgosonic
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/wavtest"
+	"github.com/nakat-t/sonic-go/wav"
 )
 
+// isOpen reports whether wf holds an open file, of libsonic or handled in Go.
+func isOpen(wf *WaveFile) bool {
+	return wf.file != nil || wf.native != nil
+}
+
 // createDummyWav creates a minimal WAV file for testing.
 // numSampleFrames is the number of sample frames.
 // bitsPerSample is typically 16 for int16 samples.
@@ -96,7 +110,7 @@ func TestOpenInputWaveFile(t *testing.T) {
 		if wf == nil {
 			t.Fatal("OpenInputWaveFile returned nil WaveFile for valid file")
 		}
-		if wf.file == nil {
+		if !isOpen(wf) {
 			t.Fatal("WaveFile.file is nil after successful open")
 		}
 		if sr != expectedSampleRate {
@@ -123,7 +137,7 @@ func TestOpenInputWaveFile(t *testing.T) {
 		}
 		if wf != nil {
 			t.Error("OpenInputWaveFile returned non-nil WaveFile for non-existent file")
-			if wf.file != nil {
+			if isOpen(wf) {
 				wf.CloseWaveFile() // Attempt to clean up if C layer somehow opened it
 			}
 		}
@@ -152,7 +166,7 @@ func TestOpenOutputWaveFile(t *testing.T) {
 		if wf == nil {
 			t.Fatal("OpenOutputWaveFile returned nil WaveFile")
 		}
-		if wf.file == nil {
+		if !isOpen(wf) {
 			t.Fatal("WaveFile.file is nil after successful open")
 		}
 		defer os.Remove(validFileName) // Clean up the created file
@@ -170,7 +184,7 @@ func TestOpenOutputWaveFile(t *testing.T) {
 		if err == nil {
 			t.Fatal("OpenOutputWaveFile succeeded for invalid path, expected error")
 			if wf != nil {
-				if wf.file != nil {
+				if isOpen(wf) {
 					wf.CloseWaveFile()
 				}
 				os.Remove(invalidPath) // Attempt to clean up if created
@@ -203,17 +217,17 @@ func TestWaveFile_CloseWaveFile(t *testing.T) {
 	if result != 1 { // Assuming 0 is success for C.closeWaveFile
 		t.Errorf("CloseWaveFile returned %d, expected 1 for success", result)
 	}
-	if wf.file != nil {
+	if isOpen(wf) {
 		t.Error("WaveFile.file is not nil after CloseWaveFile")
 	}
 
-	// Test closing an already closed file (wf.file is now nil)
+	// Test closing an already closed file (isOpen(wf) is now false)
 	// The C function `closeWaveFile` will receive a NULL pointer.
 	// Behavior depends on C library (could be no-op, error, or crash).
 	resultAlreadyClosed := wf.CloseWaveFile()
 	t.Logf("Closing an already closed file (nil C pointer) returned: %d. This behavior depends on the C library.", resultAlreadyClosed)
-	if wf.file != nil {
-		// This should not happen as Go wrapper sets wf.file to nil and doesn't reset it.
+	if isOpen(wf) {
+		// This should not happen as Go wrapper clears the file and doesn't reset it.
 		t.Error("WaveFile.file is not nil after second CloseWaveFile call (should remain nil)")
 	}
 }
@@ -302,3 +316,375 @@ func TestWaveFile_ReadWriteToWaveFile(t *testing.T) {
 
 	t.Logf("Successfully read %d shorts.", totalShortsRead)
 }
+
+func TestWaveFile_Float(t *testing.T) {
+	tempDir := t.TempDir()
+	samples := []float32{0, 0.5, -0.5, 1, -1, 0.25, 1.5, -0.125}
+
+	floatFileName := filepath.Join(tempDir, "float.wav")
+	out, err := OpenOutputFloatWaveFile(floatFileName, 8000, 2)
+	if err != nil {
+		t.Fatalf("OpenOutputFloatWaveFile() error = %v", err)
+	}
+	out.SetMetadata(Metadata{"LIST/INFO": []byte("ISFT\x04\x00\x00\x00test")})
+	if out.WriteFloatToWaveFile(samples[:4], 2) == 0 || out.WriteFloatToWaveFile(samples[4:], 2) == 0 {
+		t.Fatal("WriteFloatToWaveFile() failed")
+	}
+	if out.CloseWaveFile() == 0 {
+		t.Fatal("CloseWaveFile() failed")
+	}
+
+	in, sampleRate, numChannels, err := OpenInputWaveFile(floatFileName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile() error = %v", err)
+	}
+	defer in.CloseWaveFile()
+	if sampleRate != 8000 || numChannels != 2 || in.FormatTag() != wav.FormatTagIEEEFloat || in.BitsPerSample() != 32 || in.NumFrames() != 4 {
+		t.Errorf("OpenInputWaveFile() = %d Hz, %d channels, format %d, %d bits, %d frames", sampleRate, numChannels, in.FormatTag(), in.BitsPerSample(), in.NumFrames())
+	}
+	if _, ok := in.Metadata()["LIST/INFO"]; !ok {
+		t.Errorf("Metadata() = %v, want LIST/INFO", in.Metadata())
+	}
+	var got []float32
+	buf := make([]float32, 6)
+	for {
+		n := in.ReadFloatFromWaveFile(buf, 3)
+		if n == 0 {
+			break
+		}
+		got = append(got, buf[:n*2]...)
+	}
+	if !slices.Equal(got, samples) {
+		t.Errorf("ReadFloatFromWaveFile() = %v, want %v", got, samples)
+	}
+
+	// Reading as int16 saturates like libsonic.
+	in2, _, _, err := OpenInputWaveFile(floatFileName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile() error = %v", err)
+	}
+	defer in2.CloseWaveFile()
+	shorts := make([]int16, 8)
+	if n := in2.ReadFromWaveFile(shorts, 4); n != 4 {
+		t.Fatalf("ReadFromWaveFile() = %d, want 4", n)
+	}
+	if want := []int16{0, 16384, -16384, 32767, -32767, 8192, 32767, -4096}; !slices.Equal(shorts, want) {
+		t.Errorf("ReadFromWaveFile() = %v, want %v", shorts, want)
+	}
+
+	// PCM files can be read and written as float.
+	pcmFileName := filepath.Join(tempDir, "pcm.wav")
+	pcmOut, err := OpenOutputWaveFile(pcmFileName, 8000, 2)
+	if err != nil {
+		t.Fatalf("OpenOutputWaveFile() error = %v", err)
+	}
+	if pcmOut.WriteFloatToWaveFile(samples, 4) == 0 || pcmOut.CloseWaveFile() == 0 {
+		t.Fatal("WriteFloatToWaveFile() to PCM file failed")
+	}
+	pcmIn, _, _, err := OpenInputWaveFile(pcmFileName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile() error = %v", err)
+	}
+	defer pcmIn.CloseWaveFile()
+	if n := pcmIn.ReadFloatFromWaveFile(buf, 3); n != 3 {
+		t.Fatalf("ReadFloatFromWaveFile() = %d, want 3", n)
+	}
+	for i, want := range samples[:6] {
+		if want > 1 {
+			want = 1
+		}
+		if math.Abs(float64(buf[i]-want)) > 1.0/32767 {
+			t.Errorf("ReadFloatFromWaveFile()[%d] = %v, want %v", i, buf[i], want)
+		}
+	}
+}
+
+// createWavWithFormat creates a WAV file with the given format tag and bits per sample, preceded by a LIST chunk.
+func createWavWithFormat(t *testing.T, filename string, formatTag int, bitsPerSample int) {
+	t.Helper()
+
+	header := make([]byte, 0, 64)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, 4+(8+4)+(8+16)+8)
+	header = append(header, "WAVE"...)
+	header = append(header, "LIST"...)
+	header = binary.LittleEndian.AppendUint32(header, 4)
+	header = append(header, "INFO"...)
+	header = append(header, "fmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, uint16(formatTag))
+	header = binary.LittleEndian.AppendUint16(header, 1)
+	header = binary.LittleEndian.AppendUint32(header, 8000)
+	header = binary.LittleEndian.AppendUint32(header, uint32(8000*bitsPerSample/8))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bitsPerSample/8))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bitsPerSample))
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, 0)
+
+	if err := os.WriteFile(filename, header, 0644); err != nil {
+		t.Fatalf("Failed to create wav file %s: %v", filename, err)
+	}
+}
+
+func TestOpenInputWaveFile_UnsupportedCodec(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name          string
+		formatTag     int
+		bitsPerSample int
+	}{
+		{"mu-law", wav.FormatTagMuLaw, 8},
+		{"A-law", wav.FormatTagALaw, 8},
+		{"IMA ADPCM", wav.FormatTagIMAADPCM, 4},
+		{"64-bit IEEE float", wav.FormatTagIEEEFloat, 64},
+		{"8-bit PCM", wav.FormatTagPCM, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(tempDir, tt.name+".wav")
+			createWavWithFormat(t, fileName, tt.formatTag, tt.bitsPerSample)
+
+			wf, _, _, err := OpenInputWaveFile(fileName)
+			if wf != nil {
+				wf.CloseWaveFile()
+			}
+			var codecErr *UnsupportedCodecError
+			if !errors.As(err, &codecErr) {
+				t.Fatalf("OpenInputWaveFile() error = %v, want *UnsupportedCodecError", err)
+			}
+			if codecErr.FormatTag != tt.formatTag || codecErr.BitsPerSample != tt.bitsPerSample {
+				t.Errorf("UnsupportedCodecError = %+v, want format tag %d, bits %d", codecErr, tt.formatTag, tt.bitsPerSample)
+			}
+			if !errors.Is(err, wav.ErrUnsupported) {
+				t.Errorf("OpenInputWaveFile() error = %v, want it to wrap %v", err, wav.ErrUnsupported)
+			}
+		})
+	}
+}
+
+// FuzzOpenInputWaveFile checks that hostile files make the WAVE reader fail cleanly instead of
+// crashing, hanging or allocating without bound.
+func FuzzOpenInputWaveFile(f *testing.F) {
+	for _, seed := range wavtest.Seeds() {
+		f.Add(seed)
+	}
+
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fileName := filepath.Join(dir, "fuzz.wav")
+		if err := os.WriteFile(fileName, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		wf, _, numChannels, err := OpenInputWaveFile(fileName)
+		if err != nil {
+			return
+		}
+		defer wf.CloseWaveFile()
+		if numChannels < 1 {
+			t.Fatalf("OpenInputWaveFile() succeeded with %d channels", numChannels)
+		}
+
+		buf := make([]int16, 1024*numChannels)
+		total := 0
+		for {
+			n := wf.ReadFromWaveFile(buf, 1024)
+			if n <= 0 {
+				break
+			}
+			total += n * numChannels * 2
+			if total > len(data) {
+				t.Fatalf("read %d bytes of samples from a %d byte file", total, len(data))
+			}
+		}
+	})
+}
+
+func TestOpenInputWaveFileWithLimits(t *testing.T) {
+	samples := make([]byte, 64)
+	tests := []struct {
+		name    string
+		data    []byte
+		limits  WaveLimits
+		wantErr error
+	}{
+		{"valid", wavtest.Header(16, 1, 8000, wavtest.Chunk("data", 64, samples)), DefaultWaveLimits, nil},
+		{"data size claims 4GB", wavtest.Header(16, 1, 8000, wavtest.Chunk("data", 0xFFFFFFFF, samples)), DefaultWaveLimits, nil},
+		{"data over limit", wavtest.Header(16, 1, 8000, wavtest.Chunk("data", 64, samples)), WaveLimits{MaxDataBytes: 32, MaxChannels: 2, MaxSampleRate: 8000}, ErrWaveLimitExceeded},
+		{"channels over limit", wavtest.Header(16, 4, 8000, wavtest.Chunk("data", 64, samples)), WaveLimits{MaxDataBytes: 64, MaxChannels: 2, MaxSampleRate: 8000}, ErrWaveLimitExceeded},
+		{"sample rate over limit", wavtest.Header(16, 1, 48000, wavtest.Chunk("data", 64, samples)), WaveLimits{MaxDataBytes: 64, MaxChannels: 2, MaxSampleRate: 8000}, ErrWaveLimitExceeded},
+		{"chunk exceeds file", wavtest.Header(16, 1, 8000, wavtest.Chunk("LIST", 0x7FFFFFFF, nil), wavtest.Chunk("data", 64, samples)), DefaultWaveLimits, ErrInvalidWaveHeader},
+		{"fmt chunk exceeds file", wavtest.Header(0xFFFFFFF0, 1, 8000, wavtest.Chunk("data", 64, samples)), DefaultWaveLimits, ErrInvalidWaveHeader},
+		{"zero channels", wavtest.Header(16, 0, 8000, wavtest.Chunk("data", 64, samples)), DefaultWaveLimits, ErrInvalidWaveHeader},
+		{"no data chunk", wavtest.Header(16, 1, 8000), DefaultWaveLimits, ErrInvalidWaveHeader},
+		{"padded chunk before data", wavtest.Header(16, 1, 8000, wavtest.Chunk("junk", 3, []byte("abc\x00")), wavtest.Chunk("data", 64, samples)), DefaultWaveLimits, nil},
+		{"data before fmt", append([]byte("RIFF\xff\xff\xff\xffWAVE"), wavtest.Chunk("data", 64, samples)...), DefaultWaveLimits, ErrInvalidWaveHeader},
+		{"not a wave file", []byte("ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00"), DefaultWaveLimits, ErrInvalidWaveHeader},
+	}
+
+	tempDir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(tempDir, tt.name+".wav")
+			if err := os.WriteFile(fileName, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			wf, _, _, err := OpenInputWaveFileWithLimits(fileName, tt.limits)
+			if wf != nil {
+				defer wf.CloseWaveFile()
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("OpenInputWaveFileWithLimits() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("OpenInputWaveFileWithLimits() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadWaveHeader_DataBytes(t *testing.T) {
+	// A data size beyond the end of the file, as written by streaming encoders, is limited to the actual data.
+	fileName := filepath.Join(t.TempDir(), "streaming.wav")
+	if err := os.WriteFile(fileName, wavtest.Header(16, 2, 8000, wavtest.Chunk("data", 0xFFFFFFFF, make([]byte, 40))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	h, _, err := readWaveHeader(f, DefaultWaveLimits)
+	if err != nil {
+		t.Fatalf("readWaveHeader() error = %v", err)
+	}
+	if h.dataBytes != 40 {
+		t.Errorf("dataBytes = %d, want 40", h.dataBytes)
+	}
+}
+
+func TestWaveFile_Metadata(t *testing.T) {
+	tempDir := t.TempDir()
+
+	inputName := filepath.Join(tempDir, "input.wav")
+	createDummyWav(t, inputName, 8000, 2, 12000, 16)
+	wf, _, _, err := OpenInputWaveFile(inputName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile failed: %v", err)
+	}
+	defer wf.CloseWaveFile()
+
+	if got := wf.SampleRate(); got != 8000 {
+		t.Errorf("SampleRate() = %d, want 8000", got)
+	}
+	if got := wf.NumChannels(); got != 2 {
+		t.Errorf("NumChannels() = %d, want 2", got)
+	}
+	if got := wf.BitsPerSample(); got != 16 {
+		t.Errorf("BitsPerSample() = %d, want 16", got)
+	}
+	if got := wf.FormatTag(); got != wav.FormatTagPCM {
+		t.Errorf("FormatTag() = %d, want %d", got, wav.FormatTagPCM)
+	}
+	if got := wf.DataBytes(); got != 12000*2*2 {
+		t.Errorf("DataBytes() = %d, want %d", got, 12000*2*2)
+	}
+	if got := wf.NumFrames(); got != 12000 {
+		t.Errorf("NumFrames() = %d, want 12000", got)
+	}
+	if got := wf.Duration(); got != 1500*time.Millisecond {
+		t.Errorf("Duration() = %v, want 1.5s", got)
+	}
+
+	outputName := filepath.Join(tempDir, "output.wav")
+	out, err := OpenOutputWaveFile(outputName, 44100, 1)
+	if err != nil {
+		t.Fatalf("OpenOutputWaveFile failed: %v", err)
+	}
+	defer out.CloseWaveFile()
+	if out.SampleRate() != 44100 || out.NumChannels() != 1 || out.BitsPerSample() != 16 || out.Duration() != 0 {
+		t.Errorf("output metadata = %d Hz, %d channels, %d bits, %v, want 44100 Hz, 1 channel, 16 bits, 0s",
+			out.SampleRate(), out.NumChannels(), out.BitsPerSample(), out.Duration())
+	}
+}
+
+func TestWaveFile_MetadataPassthrough(t *testing.T) {
+	tempDir := t.TempDir()
+	info := append([]byte("INFO"), wavtest.Chunk("INAM", 6, []byte("Title\x00"))...)
+	bext := make([]byte, 603) // Odd size to exercise padding
+	copy(bext, "Originator")
+	samples := make([]byte, 64)
+	for i := range samples {
+		samples[i] = byte(i)
+	}
+	input := wavtest.Header(16, 1, 8000, wavtest.Chunk("LIST", uint32(len(info)), info), wavtest.Chunk("data", 64, samples), wavtest.Chunk("bext", uint32(len(bext)), bext), []byte{0})
+	binary.LittleEndian.PutUint32(input[4:8], uint32(len(input)-8))
+	inputName := filepath.Join(tempDir, "input.wav")
+	if err := os.WriteFile(inputName, input, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, _, _, err := OpenInputWaveFile(inputName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile failed: %v", err)
+	}
+	defer in.CloseWaveFile()
+	want := Metadata{"LIST/INFO": info[4:], "bext": bext}
+	if got := in.Metadata(); !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Fatalf("Metadata() = %q, want %q", got, want)
+	}
+
+	outputName := filepath.Join(tempDir, "output.wav")
+	out, err := OpenOutputWaveFile(outputName, 8000, 1)
+	if err != nil {
+		t.Fatalf("OpenOutputWaveFile failed: %v", err)
+	}
+	out.SetMetadata(in.Metadata())
+	buf := make([]int16, 32)
+	n := in.ReadFromWaveFile(buf, len(buf))
+	out.WriteToWaveFile(buf, n)
+	if out.CloseWaveFile() == 0 {
+		t.Fatal("CloseWaveFile failed")
+	}
+
+	output, err := os.ReadFile(outputName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if riffSize := binary.LittleEndian.Uint32(output[4:8]); int(riffSize) != len(output)-8 {
+		t.Errorf("RIFF size = %d, want %d", riffSize, len(output)-8)
+	}
+	reopened, _, _, err := OpenInputWaveFile(outputName)
+	if err != nil {
+		t.Fatalf("OpenInputWaveFile(output) failed: %v", err)
+	}
+	defer reopened.CloseWaveFile()
+	if got := reopened.Metadata(); !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("output Metadata() = %q, want %q", got, want)
+	}
+	if got := reopened.DataBytes(); got != 64 {
+		t.Errorf("output DataBytes() = %d, want 64", got)
+	}
+}
+
+func TestOpenInputWaveFileWithLimits_Metadata(t *testing.T) {
+	bext := make([]byte, 100)
+	fileName := filepath.Join(t.TempDir(), "bext.wav")
+	data := wavtest.Header(16, 1, 8000, wavtest.Chunk("bext", uint32(len(bext)), bext), wavtest.Chunk("data", 2, []byte{0, 0}))
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	limits := DefaultWaveLimits
+	limits.MaxMetadataBytes = 99
+	wf, _, _, err := OpenInputWaveFileWithLimits(fileName, limits)
+	if wf != nil {
+		wf.CloseWaveFile()
+	}
+	if !errors.Is(err, ErrWaveLimitExceeded) {
+		t.Errorf("OpenInputWaveFileWithLimits() error = %v, want %v", err, ErrWaveLimitExceeded)
+	}
+}
@@ -0,0 +1,66 @@
+// Package fft implements the fast Fourier transform used by the pure-Go DSP code.
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FFT is an in-place radix-2 fast Fourier transform of a fixed size.
+type FFT struct {
+	twiddles []complex128
+	reversed []int // Bit-reversed index of each index
+}
+
+// New creates an FFT of size n, which must be a power of 2.
+func New(n int) *FFT {
+	f := &FFT{
+		twiddles: make([]complex128, n/2),
+		reversed: make([]int, n),
+	}
+	for i := range f.twiddles {
+		f.twiddles[i] = cmplx.Rect(1, -2*math.Pi*float64(i)/float64(n))
+	}
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+	for i := range f.reversed {
+		r := 0
+		for b := range bits {
+			r |= (i >> b & 1) << (bits - 1 - b)
+		}
+		f.reversed[i] = r
+	}
+	return f
+}
+
+// Transform computes the discrete Fourier transform of x in place, or the inverse transform,
+// scaled by 1/len(x), if inverse is set.
+func (f *FFT) Transform(x []complex128, inverse bool) {
+	n := len(x)
+	for i, r := range f.reversed {
+		if i < r {
+			x[i], x[r] = x[r], x[i]
+		}
+	}
+	for size := 2; size <= n; size *= 2 {
+		half, stride := size/2, n/size
+		for start := 0; start < n; start += size {
+			for k := range half {
+				w := f.twiddles[k*stride]
+				if inverse {
+					w = cmplx.Conj(w)
+				}
+				a, b := x[start+k], x[start+k+half]*w
+				x[start+k], x[start+k+half] = a+b, a-b
+			}
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
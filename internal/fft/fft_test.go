@@ -0,0 +1,35 @@
+package fft
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFFT(t *testing.T) {
+	const n = 16
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(float64(i%5), float64(i%3))
+	}
+	want := make([]complex128, n)
+	for k := range want {
+		for i, v := range x {
+			want[k] += v * complex(math.Cos(-2*math.Pi*float64(i*k)/n), math.Sin(-2*math.Pi*float64(i*k)/n))
+		}
+	}
+	orig := append([]complex128(nil), x...)
+
+	f := New(n)
+	f.Transform(x, false)
+	for k := range x {
+		if d := x[k] - want[k]; math.Hypot(real(d), imag(d)) > 1e-9 {
+			t.Errorf("Transform()[%d] = %v, want %v", k, x[k], want[k])
+		}
+	}
+	f.Transform(x, true)
+	for i := range x {
+		if d := x[i] - orig[i]; math.Hypot(real(d), imag(d)) > 1e-9 {
+			t.Errorf("inverse Transform()[%d] = %v, want %v", i, x[i], orig[i])
+		}
+	}
+}
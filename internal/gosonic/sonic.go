@@ -0,0 +1,885 @@
+// Package gosonic is a pure-Go port of libsonic, the time-stretching library that cgosonic
+// wraps. It has the same Stream API as cgosonic, so that sonic-go can be built without a C
+// toolchain; cgosonic uses it when built with the purego or nosonic_cgo build tag, or without
+// cgo.
+//
+// The port follows sonic.c in internal/cgosonic, including its local fixes, and reproduces its
+// integer and float32 arithmetic, so that it produces the same samples as the C library.
+package gosonic
+
+const (
+	MIN_VOLUME        = float32(0.01)
+	MAX_VOLUME        = float32(100.0)
+	MIN_SPEED         = float32(0.05)
+	MAX_SPEED         = float32(20.0)
+	MIN_PITCH_SETTING = float32(0.05)
+	MAX_PITCH_SETTING = float32(20.0)
+	MIN_RATE          = float32(0.05)
+	MAX_RATE          = float32(20.0)
+	MIN_SAMPLE_RATE   = 1000
+	MAX_SAMPLE_RATE   = 500000
+	MIN_CHANNELS      = 1
+	MAX_CHANNELS      = 32
+	MIN_PITCH         = 65
+	MAX_PITCH         = 400
+)
+
+const (
+	amdfFreq         = 4000 // Rate to down-sample to for pitch detection
+	sincFilterPoints = 12   // Number of points of the sinc FIR filter for resampling
+	sincTableSize    = 601
+)
+
+// Stream represents a SONIC audio stream
+//
+// As with cgosonic, a Stream is not safe for concurrent use, and all counts are in frames,
+// i.e. samples per channel.
+type Stream struct {
+	inputBuffer      []int16
+	outputBuffer     []int16
+	pitchBuffer      []int16
+	downSampleBuffer []int16
+	speed            float32
+	volume           float32
+	pitch            float32
+	rate             float32
+	samplePeriod     float32 // How long each output sample takes to play
+	inputPlayTime    float32 // How long we expect the entire input buffer to take to play
+	timeError        float32 // Difference between when the latest output sample was played and when we wanted
+	oldRatePosition  int
+	newRatePosition  int
+	quality          int
+	numChannels      int
+	numInputSamples  int
+	numOutputSamples int
+	numPitchSamples  int
+	minPeriod        int
+	maxPeriod        int
+	maxRequired      int
+	sampleRate       int
+	prevPeriod       int
+	prevMinDiff      int
+
+	// Bookkeeping for PendingInputFrames, reset by FlushStream.
+	framesWritten int64 // Frames written since the last flush
+	framesRead    int64 // Frames read since the last flush
+	framesFlushed int64 // Frames available right after the last flush
+}
+
+// CreateStream creates a new sonic stream. The sample rate and the number of channels are
+// clamped to their ranges, as by libsonic. It does not fail; the error is for compatibility with
+// cgosonic.
+func CreateStream(sampleRate int, numChannels int) (*Stream, error) {
+	s := &Stream{
+		speed:  1,
+		pitch:  1,
+		volume: 1,
+		rate:   1,
+	}
+	s.allocateStreamBuffers(clamp(sampleRate, MIN_SAMPLE_RATE, MAX_SAMPLE_RATE), clamp(numChannels, MIN_CHANNELS, MAX_CHANNELS))
+	return s, nil
+}
+
+// DestroyStream destroys the sonic stream
+func (s *Stream) DestroyStream() {
+	s.inputBuffer = nil
+	s.outputBuffer = nil
+	s.pitchBuffer = nil
+	s.downSampleBuffer = nil
+}
+
+// allocateStreamBuffers allocates the buffers for sampleRate and numChannels.
+func (s *Stream) allocateStreamBuffers(sampleRate, numChannels int) {
+	minPeriod := sampleRate / MAX_PITCH
+	maxPeriod := sampleRate / MIN_PITCH
+	maxRequired := 2 * maxPeriod
+
+	// Allocate 25% more than needed so we hopefully won't grow.
+	size := maxRequired + (maxRequired >> 2)
+	s.inputBuffer = make([]int16, size*numChannels)
+	s.outputBuffer = make([]int16, size*numChannels)
+	s.pitchBuffer = make([]int16, size*numChannels)
+	// The multi-channel path down samples again with skip == 1, so the buffer must hold
+	// maxRequired values regardless of skip.
+	s.downSampleBuffer = make([]int16, maxRequired)
+	s.sampleRate = sampleRate
+	s.samplePeriod = float32(1.0 / float64(sampleRate))
+	s.numChannels = numChannels
+	s.oldRatePosition = 0
+	s.newRatePosition = 0
+	s.minPeriod = minPeriod
+	s.maxPeriod = maxPeriod
+	s.maxRequired = maxRequired
+	s.prevPeriod = 0
+}
+
+// WriteFloatToStream writes float samples to the stream
+func (s *Stream) WriteFloatToStream(samples []float32, numSamples int) int {
+	s.addFloatSamplesToInputBuffer(samples, numSamples)
+	ret := s.processStreamInput()
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
+}
+
+// WriteShortToStream writes short samples to the stream
+func (s *Stream) WriteShortToStream(samples []int16, numSamples int) int {
+	s.addShortSamplesToInputBuffer(samples, numSamples)
+	ret := s.processStreamInput()
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
+}
+
+// WriteUnsignedCharToStream writes unsigned 8-bit samples to the stream
+func (s *Stream) WriteUnsignedCharToStream(samples []uint8, numSamples int) int {
+	s.addUnsignedCharSamplesToInputBuffer(samples, numSamples)
+	ret := s.processStreamInput()
+	if ret != 0 {
+		s.framesWritten += int64(numSamples)
+	}
+	return ret
+}
+
+// ReadFloatFromStream reads float samples from the stream
+func (s *Stream) ReadFloatFromStream(samples []float32, maxSamples int) int {
+	n := s.readFromStream(maxSamples, func(buffer []int16) {
+		for i, v := range buffer {
+			samples[i] = float32(v) / 32767.0
+		}
+	})
+	s.framesRead += int64(n)
+	return n
+}
+
+// ReadShortFromStream reads short samples from the stream
+func (s *Stream) ReadShortFromStream(samples []int16, maxSamples int) int {
+	n := s.readFromStream(maxSamples, func(buffer []int16) {
+		copy(samples, buffer)
+	})
+	s.framesRead += int64(n)
+	return n
+}
+
+// ReadUnsignedCharFromStream reads unsigned 8-bit samples from the stream
+func (s *Stream) ReadUnsignedCharFromStream(samples []uint8, maxSamples int) int {
+	n := s.readFromStream(maxSamples, func(buffer []int16) {
+		for i, v := range buffer {
+			samples[i] = uint8(v>>8) + 128
+		}
+	})
+	s.framesRead += int64(n)
+	return n
+}
+
+// ReadShortInto reads as many frames as fit into the capacity of samples in a single call and
+// returns the number of frames read. The samples are stored in samples[:cap(samples)].
+func (s *Stream) ReadShortInto(samples []int16) int {
+	if cap(samples) == 0 {
+		return 0
+	}
+	samples = samples[:cap(samples)]
+	return s.ReadShortFromStream(samples, len(samples)/s.numChannels)
+}
+
+// ReadFloatInto reads as many frames as fit into the capacity of samples in a single call and
+// returns the number of frames read. The samples are stored in samples[:cap(samples)].
+func (s *Stream) ReadFloatInto(samples []float32) int {
+	if cap(samples) == 0 {
+		return 0
+	}
+	samples = samples[:cap(samples)]
+	return s.ReadFloatFromStream(samples, len(samples)/s.numChannels)
+}
+
+// readFromStream passes up to maxSamples frames of the output buffer to read and removes them.
+// Sometimes no data will be available, and zero is returned, which is not an error condition.
+func (s *Stream) readFromStream(maxSamples int, read func(buffer []int16)) int {
+	numSamples := s.numOutputSamples
+	if numSamples == 0 || maxSamples <= 0 {
+		return 0
+	}
+	remainingSamples := 0
+	if numSamples > maxSamples {
+		remainingSamples = numSamples - maxSamples
+		numSamples = maxSamples
+	}
+	ch := s.numChannels
+	read(s.outputBuffer[:numSamples*ch])
+	if remainingSamples > 0 {
+		copy(s.outputBuffer, s.outputBuffer[numSamples*ch:(numSamples+remainingSamples)*ch])
+	}
+	s.numOutputSamples = remainingSamples
+	return numSamples
+}
+
+// FlushStream flushes the stream
+func (s *Stream) FlushStream() int {
+	ret := s.flushStream()
+	if ret != 0 {
+		s.framesWritten = 0
+		s.framesRead = 0
+		s.framesFlushed = int64(s.SamplesAvailable())
+	}
+	return ret
+}
+
+// FlushAndReadShort flushes the stream and reads the first short samples from it. It returns the
+// number of samples read, or -1 if the flush failed.
+func (s *Stream) FlushAndReadShort(samples []int16, maxSamples int) int {
+	if s.FlushStream() == 0 {
+		return -1
+	}
+	return s.ReadShortFromStream(samples, maxSamples)
+}
+
+// FlushAndReadFloat flushes the stream and reads the first float samples from it. It returns
+// the number of samples read, or -1 if the flush failed.
+func (s *Stream) FlushAndReadFloat(samples []float32, maxSamples int) int {
+	if s.FlushStream() == 0 {
+		return -1
+	}
+	return s.ReadFloatFromStream(samples, maxSamples)
+}
+
+// SamplesAvailable returns the number of samples in the output buffer
+func (s *Stream) SamplesAvailable() int {
+	return s.numOutputSamples
+}
+
+// PendingInputFrames estimates the number of input frames held inside the stream that have not
+// been turned into output yet. See cgosonic.Stream.PendingInputFrames.
+func (s *Stream) PendingInputFrames() int {
+	produced := s.framesRead + int64(s.SamplesAvailable()) - s.framesFlushed
+	consumed := float64(produced) * float64(s.GetSpeed()) * float64(s.GetRate())
+	pending := float64(s.framesWritten) - consumed
+	if pending < 0 {
+		return 0
+	}
+	return int(pending + 0.5)
+}
+
+// GetSpeed gets the speed of the stream
+func (s *Stream) GetSpeed() float32 {
+	return s.speed
+}
+
+// SetSpeed sets the speed of the stream
+func (s *Stream) SetSpeed(speed float32) {
+	s.speed = clamp(speed, MIN_SPEED, MAX_SPEED)
+}
+
+// GetPitch gets the pitch of the stream
+func (s *Stream) GetPitch() float32 {
+	return s.pitch
+}
+
+// SetPitch sets the pitch of the stream
+func (s *Stream) SetPitch(pitch float32) {
+	s.pitch = clamp(pitch, MIN_PITCH_SETTING, MAX_PITCH_SETTING)
+}
+
+// GetRate gets the rate of the stream
+func (s *Stream) GetRate() float32 {
+	return s.rate
+}
+
+// SetRate sets the rate of the stream
+func (s *Stream) SetRate(rate float32) {
+	s.rate = clamp(rate, MIN_RATE, MAX_RATE)
+	s.oldRatePosition = 0
+	s.newRatePosition = 0
+}
+
+// GetVolume gets the volume of the stream
+func (s *Stream) GetVolume() float32 {
+	return s.volume
+}
+
+// SetVolume sets the volume of the stream
+func (s *Stream) SetVolume(volume float32) {
+	s.volume = clamp(volume, MIN_VOLUME, MAX_VOLUME)
+}
+
+// GetChordPitch gets the chord pitch setting. Chord pitch is deprecated in libsonic, and this
+// always returns 0.
+func (s *Stream) GetChordPitch() int {
+	return 0
+}
+
+// SetChordPitch sets chord pitch mode on or off. Chord pitch is deprecated in libsonic, and this
+// has no effect; it exists for compatibility with the libsonic API.
+func (s *Stream) SetChordPitch(useChordPitch int) {}
+
+// GetQuality gets the quality setting.
+func (s *Stream) GetQuality() int {
+	return s.quality
+}
+
+// SetQuality sets the "quality".  Default 0 is virtually as good as 1, but very much faster.
+func (s *Stream) SetQuality(quality int) {
+	s.quality = 0
+	if quality != 0 {
+		s.quality = 1
+	}
+}
+
+// GetSampleRate gets the sample rate of the stream
+func (s *Stream) GetSampleRate() int {
+	return s.sampleRate
+}
+
+// SetSampleRate sets the sample rate of the stream. This will cause samples buffered in the
+// stream to be lost.
+func (s *Stream) SetSampleRate(sampleRate int) {
+	s.allocateStreamBuffers(clamp(sampleRate, MIN_SAMPLE_RATE, MAX_SAMPLE_RATE), s.numChannels)
+	s.discardBuffered()
+}
+
+// GetNumChannels gets the number of channels in the stream
+func (s *Stream) GetNumChannels() int {
+	return s.numChannels
+}
+
+// SetNumChannels sets the number of channels in the stream. This will cause samples buffered in
+// the stream to be lost.
+func (s *Stream) SetNumChannels(numChannels int) {
+	s.allocateStreamBuffers(s.sampleRate, clamp(numChannels, MIN_CHANNELS, MAX_CHANNELS))
+	s.discardBuffered()
+}
+
+// discardBuffered forgets the samples of the reallocated buffers. libsonic keeps the counts,
+// which then refer to zeroed or missing memory.
+func (s *Stream) discardBuffered() {
+	s.numInputSamples = 0
+	s.numOutputSamples = 0
+	s.numPitchSamples = 0
+	s.inputPlayTime = 0
+	s.timeError = 0
+}
+
+// ChangeFloatSpeed is a non-stream-oriented interface to change the speed of float audio samples
+func ChangeFloatSpeed(samples []float32, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	s, _ := CreateStream(sampleRate, numChannels)
+	s.SetSpeed(speed)
+	s.SetPitch(pitch)
+	s.SetRate(rate)
+	s.SetVolume(volume)
+	s.WriteFloatToStream(samples, numSamples)
+	s.FlushStream()
+	numSamples = s.SamplesAvailable()
+	s.ReadFloatFromStream(samples, numSamples)
+	s.DestroyStream()
+	return numSamples
+}
+
+// ChangeShortSpeed is a non-stream-oriented interface to change the speed of short audio samples
+func ChangeShortSpeed(samples []int16, numSamples int, speed, pitch, rate, volume float32, sampleRate, numChannels int) int {
+	s, _ := CreateStream(sampleRate, numChannels)
+	s.SetSpeed(speed)
+	s.SetPitch(pitch)
+	s.SetRate(rate)
+	s.SetVolume(volume)
+	s.WriteShortToStream(samples, numSamples)
+	s.FlushStream()
+	numSamples = s.SamplesAvailable()
+	s.ReadShortFromStream(samples, numSamples)
+	s.DestroyStream()
+	return numSamples
+}
+
+// clamp returns v clamped to [lo, hi].
+func clamp[T int | float32](v, lo, hi T) T {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// enlarge returns buffer, grown as libsonic does if used plus numSamples frames of numChannels
+// do not fit.
+func enlarge(buffer []int16, used, numSamples, numChannels int) []int16 {
+	size := len(buffer) / numChannels
+	if used+numSamples <= size {
+		return buffer
+	}
+	size += (size >> 1) + numSamples
+	grown := make([]int16, size*numChannels)
+	copy(grown, buffer[:used*numChannels])
+	return grown
+}
+
+// updateNumInputSamples updates numInputSamples and inputPlayTime. Call this whenever adding
+// samples to the input buffer, to keep track of total expected input play time accounting.
+func (s *Stream) updateNumInputSamples(numSamples int) {
+	speed := s.speed / s.pitch
+	s.numInputSamples += numSamples
+	s.inputPlayTime += float32(float32(numSamples)*s.samplePeriod) / speed
+}
+
+// addFloatSamplesToInputBuffer adds the input samples to the input buffer.
+func (s *Stream) addFloatSamplesToInputBuffer(samples []float32, numSamples int) {
+	if numSamples == 0 {
+		return
+	}
+	ch := s.numChannels
+	s.inputBuffer = enlarge(s.inputBuffer, s.numInputSamples, numSamples, ch)
+	buffer := s.inputBuffer[s.numInputSamples*ch:]
+	for i, value := range samples[:numSamples*ch] {
+		buffer[i] = int16(float32(value * 32767.0))
+	}
+	s.updateNumInputSamples(numSamples)
+}
+
+// addShortSamplesToInputBuffer adds the input samples to the input buffer.
+func (s *Stream) addShortSamplesToInputBuffer(samples []int16, numSamples int) {
+	if numSamples == 0 {
+		return
+	}
+	ch := s.numChannels
+	s.inputBuffer = enlarge(s.inputBuffer, s.numInputSamples, numSamples, ch)
+	copy(s.inputBuffer[s.numInputSamples*ch:], samples[:numSamples*ch])
+	s.updateNumInputSamples(numSamples)
+}
+
+// addUnsignedCharSamplesToInputBuffer adds the input samples to the input buffer.
+func (s *Stream) addUnsignedCharSamplesToInputBuffer(samples []uint8, numSamples int) {
+	if numSamples == 0 {
+		return
+	}
+	ch := s.numChannels
+	s.inputBuffer = enlarge(s.inputBuffer, s.numInputSamples, numSamples, ch)
+	buffer := s.inputBuffer[s.numInputSamples*ch:]
+	for i, value := range samples[:numSamples*ch] {
+		buffer[i] = int16(int(value)-128) << 8
+	}
+	s.updateNumInputSamples(numSamples)
+}
+
+// removeInputSamples removes input samples that we have already processed.
+func (s *Stream) removeInputSamples(position int) {
+	ch := s.numChannels
+	remainingSamples := s.numInputSamples - position
+	if remainingSamples > 0 {
+		copy(s.inputBuffer, s.inputBuffer[position*ch:s.numInputSamples*ch])
+	}
+	// If we play 3/4ths of the samples, then the expected play time of the remaining samples is
+	// 1/4th of the original expected play time.
+	s.inputPlayTime = float32(s.inputPlayTime*float32(remainingSamples)) / float32(s.numInputSamples)
+	s.numInputSamples = remainingSamples
+}
+
+// copyInputToOutput copies from the input buffer to the output buffer, and removes the samples
+// from the input buffer.
+func (s *Stream) copyInputToOutput(numSamples int) {
+	s.copyToOutput(s.inputBuffer, numSamples)
+	s.removeInputSamples(numSamples)
+}
+
+// copyToOutput copies numSamples frames of samples to the output buffer.
+func (s *Stream) copyToOutput(samples []int16, numSamples int) {
+	ch := s.numChannels
+	s.outputBuffer = enlarge(s.outputBuffer, s.numOutputSamples, numSamples, ch)
+	copy(s.outputBuffer[s.numOutputSamples*ch:], samples[:numSamples*ch])
+	s.numOutputSamples += numSamples
+}
+
+// flushStream forces the stream to generate output using whatever data it currently has. No
+// extra delay will be added to the output, but flushing in the middle of words could introduce
+// distortion.
+func (s *Stream) flushStream() int {
+	ch := s.numChannels
+	maxRequired := s.maxRequired
+	remainingSamples := s.numInputSamples
+	speed := s.speed / s.pitch
+	rate := s.rate * s.pitch
+	expectedOutputSamples := s.numOutputSamples +
+		int(float32(float32(remainingSamples)/speed+float32(s.numPitchSamples))/rate+0.5)
+
+	// Add enough silence to flush both input and pitch buffers.
+	s.inputBuffer = enlarge(s.inputBuffer, s.numInputSamples, remainingSamples+2*maxRequired, ch)
+	clear(s.inputBuffer[remainingSamples*ch : (remainingSamples+2*maxRequired)*ch])
+	s.numInputSamples += 2 * maxRequired
+	if s.processStreamInput() == 0 {
+		return 0
+	}
+	// Throw away any extra samples we generated due to the silence we added
+	if s.numOutputSamples > expectedOutputSamples {
+		s.numOutputSamples = expectedOutputSamples
+	}
+	// Empty input and pitch buffers
+	s.numInputSamples = 0
+	s.inputPlayTime = 0
+	s.timeError = 0
+	s.numPitchSamples = 0
+	return 1
+}
+
+// downSampleInput averages skip samples together, if skip is greater than one, and writes them
+// to the down-sample buffer. If numChannels is greater than one, it mixes the channels together
+// as it down samples.
+func (s *Stream) downSampleInput(samples []int16, skip int) {
+	numSamples := s.maxRequired / skip
+	samplesPerValue := s.numChannels * skip
+	k := 0
+	for i := range numSamples {
+		value := 0
+		for range samplesPerValue {
+			value += int(samples[k])
+			k++
+		}
+		value /= samplesPerValue
+		s.downSampleBuffer[i] = int16(value)
+	}
+}
+
+// findPitchPeriodInRange finds the best frequency match in the range, and given a sample skip
+// multiple. For now, just find the pitch of the first channel.
+func findPitchPeriodInRange(samples []int16, minPeriod, maxPeriod int) (bestPeriod, retMinDiff, retMaxDiff int) {
+	worstPeriod := 255
+	var minDiff, maxDiff uint64 = 1, 0
+	for period := minPeriod; period <= maxPeriod; period++ {
+		var diff uint64
+		for i := range period {
+			sVal, pVal := samples[i], samples[i+period]
+			if sVal >= pVal {
+				diff += uint64(uint16(sVal - pVal))
+			} else {
+				diff += uint64(uint16(pVal - sVal))
+			}
+		}
+		// Note that the highest number of samples we add into diff will be less than 256, since
+		// we skip samples. Thus, diff is a 24 bit number, and we can safely multiply by
+		// numSamples without overflow
+		if bestPeriod == 0 || diff*uint64(bestPeriod) < minDiff*uint64(period) {
+			minDiff = diff
+			bestPeriod = period
+		}
+		if diff*uint64(worstPeriod) > maxDiff*uint64(period) {
+			maxDiff = diff
+			worstPeriod = period
+		}
+	}
+	return bestPeriod, int(minDiff / uint64(bestPeriod)), int(maxDiff / uint64(worstPeriod))
+}
+
+// prevPeriodBetter reports whether the previous pitch period estimate is better. At abrupt ends
+// of voiced words, we can have pitch periods that are better approximated by it.
+func (s *Stream) prevPeriodBetter(minDiff, maxDiff int, preferNewPeriod bool) bool {
+	if minDiff == 0 || s.prevPeriod == 0 {
+		return false
+	}
+	if preferNewPeriod {
+		if maxDiff > minDiff*3 {
+			// Got a reasonable match this period
+			return false
+		}
+		if minDiff*2 <= s.prevMinDiff*3 {
+			// Mismatch is not that much greater this period
+			return false
+		}
+	} else if minDiff <= s.prevMinDiff {
+		return false
+	}
+	return true
+}
+
+// findPitchPeriod finds the pitch period. This is a critical step, and we may have to try
+// multiple ways to get a good answer. This version uses Average Magnitude Difference Function
+// (AMDF). To improve speed, we down sample by an integer factor get in the 11KHz range, and then
+// do it again with a narrower frequency range without down sampling
+func (s *Stream) findPitchPeriod(samples []int16, preferNewPeriod bool) int {
+	minPeriod := s.minPeriod
+	maxPeriod := s.maxPeriod
+	skip := 1
+	if s.sampleRate > amdfFreq && s.quality == 0 {
+		skip = s.sampleRate / amdfFreq
+	}
+
+	var period, minDiff, maxDiff int
+	if s.numChannels == 1 && skip == 1 {
+		period, minDiff, maxDiff = findPitchPeriodInRange(samples, minPeriod, maxPeriod)
+	} else {
+		s.downSampleInput(samples, skip)
+		period, minDiff, maxDiff = findPitchPeriodInRange(s.downSampleBuffer, minPeriod/skip, maxPeriod/skip)
+		if skip != 1 {
+			period *= skip
+			minPeriod = max(period-(skip<<2), s.minPeriod)
+			maxPeriod = min(period+(skip<<2), s.maxPeriod)
+			if s.numChannels == 1 {
+				period, minDiff, maxDiff = findPitchPeriodInRange(samples, minPeriod, maxPeriod)
+			} else {
+				s.downSampleInput(samples, 1)
+				period, minDiff, maxDiff = findPitchPeriodInRange(s.downSampleBuffer, minPeriod, maxPeriod)
+			}
+		}
+	}
+	retPeriod := period
+	if s.prevPeriodBetter(minDiff, maxDiff, preferNewPeriod) {
+		retPeriod = s.prevPeriod
+	}
+	s.prevMinDiff = minDiff
+	s.prevPeriod = period
+	return retPeriod
+}
+
+// overlapAdd overlaps two sound segments, ramps the volume of one down, while ramping the other
+// one from zero up, and adds them, storing the result at the output.
+func overlapAdd(numSamples, numChannels int, out, rampDown, rampUp []int16) {
+	for i := range numChannels {
+		k := i
+		for t := range numSamples {
+			out[k] = int16((int(rampDown[k])*(numSamples-t) + int(rampUp[k])*t) / numSamples)
+			k += numChannels
+		}
+	}
+}
+
+// moveNewSamplesToPitchBuffer just moves the new samples in the output buffer to the pitch
+// buffer.
+func (s *Stream) moveNewSamplesToPitchBuffer(originalNumOutputSamples int) {
+	ch := s.numChannels
+	numSamples := s.numOutputSamples - originalNumOutputSamples
+	s.pitchBuffer = enlarge(s.pitchBuffer, s.numPitchSamples, numSamples, ch)
+	copy(s.pitchBuffer[s.numPitchSamples*ch:], s.outputBuffer[originalNumOutputSamples*ch:s.numOutputSamples*ch])
+	s.numOutputSamples = originalNumOutputSamples
+	s.numPitchSamples += numSamples
+}
+
+// removePitchSamples removes processed samples from the pitch buffer.
+func (s *Stream) removePitchSamples(numSamples int) {
+	if numSamples == 0 {
+		return
+	}
+	ch := s.numChannels
+	if numSamples != s.numPitchSamples {
+		copy(s.pitchBuffer, s.pitchBuffer[numSamples*ch:s.numPitchSamples*ch])
+	}
+	s.numPitchSamples -= numSamples
+}
+
+// findSincCoefficient approximates the sinc function times a Hann window from the sinc table.
+func findSincCoefficient(i, ratio, width int) int {
+	const lobePoints = (sincTableSize - 1) / sincFilterPoints
+	left := i*lobePoints + (ratio*lobePoints)/width
+	right := left + 1
+	position := i*lobePoints*width + ratio*lobePoints - left*width
+	leftVal := int(sincTable[left])
+	rightVal := int(sincTable[right])
+	return ((leftVal*(width-position) + rightVal*position) << 1) / width
+}
+
+// getSign returns 1 if value >= 0, else -1. This represents the sign of value.
+func getSign(value int32) int {
+	if value >= 0 {
+		return 1
+	}
+	return -1
+}
+
+// interpolate computes the new output sample with an N-point sinc FIR-filter. It clips rather
+// than overflows.
+func (s *Stream) interpolate(in []int16, oldSampleRate, newSampleRate int) int16 {
+	position := s.newRatePosition * oldSampleRate
+	leftPosition := s.oldRatePosition * newSampleRate
+	rightPosition := (s.oldRatePosition + 1) * newSampleRate
+	ratio := rightPosition - position - 1
+	width := rightPosition - leftPosition
+	var total int32 // libsonic sums in a C int and detects its wraparound
+	overflowCount := 0
+	for i := range sincFilterPoints {
+		weight := findSincCoefficient(i, ratio, width)
+		value := int32(in[i*s.numChannels]) * int32(weight)
+		oldSign := getSign(total)
+		total += value
+		if oldSign != getSign(total) && getSign(value) == oldSign {
+			// We must have overflowed. This can happen with a sinc filter.
+			overflowCount += oldSign
+		}
+	}
+	// It is better to clip than to wrap if there was a overflow.
+	if overflowCount > 0 {
+		return 32767
+	} else if overflowCount < 0 {
+		return -32768
+	}
+	return int16(total >> 16)
+}
+
+// adjustRate changes the rate. It interpolates with a sinc FIR filter using a Hann window.
+func (s *Stream) adjustRate(rate float32, originalNumOutputSamples int) {
+	newSampleRate := int(float32(s.sampleRate) / rate)
+	oldSampleRate := s.sampleRate
+	ch := s.numChannels
+
+	// Set these values to help with the integer math
+	for newSampleRate > (1<<14) || oldSampleRate > (1<<14) {
+		newSampleRate >>= 1
+		oldSampleRate >>= 1
+	}
+	if s.numOutputSamples == originalNumOutputSamples {
+		return
+	}
+	s.moveNewSamplesToPitchBuffer(originalNumOutputSamples)
+	// Leave at least N pitch sample in the buffer
+	position := 0
+	for ; position < s.numPitchSamples-sincFilterPoints; position++ {
+		for (s.oldRatePosition+1)*newSampleRate > s.newRatePosition*oldSampleRate {
+			s.outputBuffer = enlarge(s.outputBuffer, s.numOutputSamples, 1, ch)
+			out := s.outputBuffer[s.numOutputSamples*ch:]
+			in := s.pitchBuffer[position*ch:]
+			for i := range ch {
+				out[i] = s.interpolate(in[i:], oldSampleRate, newSampleRate)
+			}
+			s.newRatePosition++
+			s.numOutputSamples++
+		}
+		s.oldRatePosition++
+		if s.oldRatePosition == oldSampleRate {
+			s.oldRatePosition = 0
+			s.newRatePosition = 0
+		}
+	}
+	s.removePitchSamples(position)
+}
+
+// skipPitchPeriod skips over a pitch period and returns the number of output samples.
+func (s *Stream) skipPitchPeriod(samples []int16, speed float32, period int) int {
+	ch := s.numChannels
+	newSamples := period
+	if speed >= 2.0 {
+		// For speeds >= 2.0, we skip over a portion of each pitch period rather than dropping
+		// whole pitch periods.
+		newSamples = int(float32(period) / (speed - 1.0))
+	}
+	s.outputBuffer = enlarge(s.outputBuffer, s.numOutputSamples, newSamples, ch)
+	overlapAdd(newSamples, ch, s.outputBuffer[s.numOutputSamples*ch:], samples, samples[period*ch:])
+	s.numOutputSamples += newSamples
+	return newSamples
+}
+
+// insertPitchPeriod inserts a pitch period, and determines how much input to copy directly.
+func (s *Stream) insertPitchPeriod(samples []int16, speed float32, period int) int {
+	ch := s.numChannels
+	newSamples := period
+	if speed <= 0.5 {
+		newSamples = int(float32(float32(period)*speed) / (1.0 - speed))
+	}
+	s.outputBuffer = enlarge(s.outputBuffer, s.numOutputSamples, period+newSamples, ch)
+	copy(s.outputBuffer[s.numOutputSamples*ch:], samples[:period*ch])
+	overlapAdd(newSamples, ch, s.outputBuffer[(s.numOutputSamples+period)*ch:], samples[period*ch:], samples)
+	s.numOutputSamples += period + newSamples
+	return newSamples
+}
+
+// copyUnmodifiedSamples copies input to output, as PICOLA does until the total output samples
+// == consumed input samples * speed, and returns the number of samples copied.
+//
+// Part of the arithmetic is in double precision in sonic.c, since 1.0 is a double constant.
+func (s *Stream) copyUnmodifiedSamples(samples []int16, speed float32, position int) int {
+	availableSamples := s.numInputSamples - position
+	inputToCopyFloat := float32(1 - float64(s.timeError*speed)/(float64(s.samplePeriod)*(float64(speed)-1.0)))
+
+	newSamples := int(inputToCopyFloat)
+	if inputToCopyFloat > float32(availableSamples) {
+		newSamples = availableSamples
+	}
+	s.copyToOutput(samples, newSamples)
+	s.timeError = float32(float64(s.timeError) + float64(float32(newSamples)*s.samplePeriod)*(float64(speed)-1.0)/float64(speed))
+	return newSamples
+}
+
+// changeSpeed resamples as many pitch periods as we have buffered on the input. It returns
+// false if a pitch period produced no output.
+func (s *Stream) changeSpeed(speed float32) bool {
+	numSamples := s.numInputSamples
+	position := 0
+	maxRequired := s.maxRequired
+
+	if s.numInputSamples < maxRequired {
+		return true
+	}
+	for {
+		samples := s.inputBuffer[position*s.numChannels:]
+		var newSamples int
+		if (speed > 1.0 && speed < 2.0 && s.timeError < 0.0) ||
+			(speed < 1.0 && speed > 0.5 && s.timeError > 0.0) {
+			// Deal with the case where PICOLA is still copying input samples to output
+			// unmodified,
+			newSamples = s.copyUnmodifiedSamples(samples, speed, position)
+			position += newSamples
+		} else {
+			// We are in the remaining cases, either inserting/removing a pitch period for speed
+			// < 2.0X, or a portion of one for speed >= 2.0X.
+			period := s.findPitchPeriod(samples, true)
+			if speed > 1.0 {
+				newSamples = s.skipPitchPeriod(samples, speed, period)
+				position += period + newSamples
+				if speed < 2.0 {
+					s.timeError += float32(float32(newSamples)*s.samplePeriod) -
+						float32(float32(period+newSamples)*s.inputPlayTime)/float32(s.numInputSamples)
+				}
+			} else {
+				newSamples = s.insertPitchPeriod(samples, speed, period)
+				position += newSamples
+				if speed > 0.5 {
+					s.timeError += float32(float32(period+newSamples)*s.samplePeriod) -
+						float32(float32(newSamples)*s.inputPlayTime)/float32(s.numInputSamples)
+				}
+			}
+			if newSamples == 0 {
+				return false // libsonic reports a failure to resize the output buffer
+			}
+		}
+		if position+maxRequired > numSamples {
+			break
+		}
+	}
+	s.removeInputSamples(position)
+	return true
+}
+
+// processStreamInput resamples as many pitch periods as we have buffered on the input, and
+// scales the output by the volume. It returns 1, since growing a buffer cannot fail in Go.
+func (s *Stream) processStreamInput() int {
+	originalNumOutputSamples := s.numOutputSamples
+	rate := s.rate * s.pitch
+
+	if s.numInputSamples == 0 {
+		return 1
+	}
+	localSpeed := float32(float32(s.numInputSamples)*s.samplePeriod) / s.inputPlayTime
+	if float64(localSpeed) > 1.00001 || float64(localSpeed) < 0.99999 {
+		s.changeSpeed(localSpeed)
+	} else {
+		s.copyInputToOutput(s.numInputSamples)
+	}
+	if rate != 1.0 {
+		s.adjustRate(rate, originalNumOutputSamples)
+	}
+	if s.volume != 1.0 {
+		// Adjust output volume.
+		scaleSamples(s.outputBuffer[originalNumOutputSamples*s.numChannels:s.numOutputSamples*s.numChannels], s.volume)
+	}
+	return 1
+}
+
+// scaleSamples scales the samples by volume.
+func scaleSamples(samples []int16, volume float32) {
+	// This is 24-bit integer and 8-bit fraction fixed-point representation.
+	fixedPointVolume := int(volume * 256.0)
+	for i, sample := range samples {
+		value := (int(sample) * fixedPointVolume) >> 8
+		if value > 32767 {
+			value = 32767
+		} else if value < -32767 {
+			value = -32767
+		}
+		samples[i] = int16(value)
+	}
+}
@@ -0,0 +1,228 @@
+//go:build cgo && !purego && !nosonic_cgo
+
+package gosonic_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/internal/gosonic"
+)
+
+// stream is the part of the Stream API shared by gosonic and cgosonic that the tests use.
+type stream interface {
+	WriteShortToStream(samples []int16, numSamples int) int
+	WriteFloatToStream(samples []float32, numSamples int) int
+	WriteUnsignedCharToStream(samples []uint8, numSamples int) int
+	ReadShortFromStream(samples []int16, maxSamples int) int
+	ReadFloatFromStream(samples []float32, maxSamples int) int
+	ReadUnsignedCharFromStream(samples []uint8, maxSamples int) int
+	FlushStream() int
+	SetSpeed(speed float32)
+	SetPitch(pitch float32)
+	SetRate(rate float32)
+	SetVolume(volume float32)
+	SetQuality(quality int)
+	DestroyStream()
+}
+
+type settings struct {
+	speed, pitch, rate, volume float32
+	quality                    int
+}
+
+func (s settings) String() string {
+	return fmt.Sprintf("speed=%v,pitch=%v,rate=%v,volume=%v,quality=%d", s.speed, s.pitch, s.rate, s.volume, s.quality)
+}
+
+var allSettings = []settings{
+	{1, 1, 1, 1, 0},
+	{1.5, 1, 1, 1, 0},
+	{0.7, 1, 1, 1, 0},
+	{2.5, 1, 1, 1, 0},
+	{0.3, 1, 1, 1, 0},
+	{1, 1.3, 1, 1, 0},
+	{1, 0.8, 1, 1, 0},
+	{1, 1, 1.25, 1, 0},
+	{1, 1, 0.6, 1, 0},
+	{1, 1, 1, 3, 0},
+	{1.5, 1, 1, 1, 1},
+	{1.8, 0.9, 1.1, 0.5, 0},
+	{20, 1, 1, 1, 0},
+	{0.05, 1, 1, 1, 0},
+}
+
+// sampleFormat selects the Write and Read functions of a stream.
+type sampleFormat string
+
+const (
+	formatShort        sampleFormat = "short"
+	formatFloat        sampleFormat = "float"
+	formatUnsignedChar sampleFormat = "uchar"
+)
+
+// speech returns a second of the embedded speech with numChannels channels, each a shifted copy
+// of the speech.
+func speech(numChannels int) []int16 {
+	mono := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	samples := make([]int16, len(mono)*numChannels)
+	for i := range mono {
+		for ch := range numChannels {
+			samples[i*numChannels+ch] = mono[(i+ch*97)%len(mono)]
+		}
+	}
+	return samples
+}
+
+// process writes samples to s in blocks of blockSize frames, reading the output after every
+// write, and flushes it.
+func process(t *testing.T, s stream, cfg settings, samples []int16, numChannels, blockSize int, format sampleFormat) []int16 {
+	t.Helper()
+	s.SetSpeed(cfg.speed)
+	s.SetPitch(cfg.pitch)
+	s.SetRate(cfg.rate)
+	s.SetVolume(cfg.volume)
+	s.SetQuality(cfg.quality)
+
+	var out []int16
+	buf := make([]int16, 4096*numChannels)
+	fbuf := make([]float32, 4096*numChannels)
+	ubuf := make([]uint8, 4096*numChannels)
+	drain := func() {
+		for {
+			var n int
+			switch format {
+			case formatFloat:
+				n = s.ReadFloatFromStream(fbuf, 4096)
+				for _, v := range fbuf[:n*numChannels] {
+					// The float output is the short output divided by 32767.
+					out = append(out, int16(v*32767))
+				}
+			case formatUnsignedChar:
+				n = s.ReadUnsignedCharFromStream(ubuf, 4096)
+				for _, v := range ubuf[:n*numChannels] {
+					out = append(out, int16(v))
+				}
+			default:
+				n = s.ReadShortFromStream(buf, 4096)
+				out = append(out, buf[:n*numChannels]...)
+			}
+			if n == 0 {
+				return
+			}
+		}
+	}
+	for i := 0; i < len(samples); i += blockSize * numChannels {
+		block := samples[i:min(i+blockSize*numChannels, len(samples))]
+		var ret int
+		switch format {
+		case formatFloat:
+			f := make([]float32, len(block))
+			for j, v := range block {
+				f[j] = float32(v) / 32768
+			}
+			ret = s.WriteFloatToStream(f, len(block)/numChannels)
+		case formatUnsignedChar:
+			u := make([]uint8, len(block))
+			for j, v := range block {
+				u[j] = uint8(v>>8) + 128
+			}
+			ret = s.WriteUnsignedCharToStream(u, len(block)/numChannels)
+		default:
+			ret = s.WriteShortToStream(block, len(block)/numChannels)
+		}
+		if ret == 0 {
+			t.Fatal("Write failed")
+		}
+		drain()
+	}
+	if s.FlushStream() == 0 {
+		t.Fatal("FlushStream failed")
+	}
+	drain()
+	return out
+}
+
+func TestStream_MatchesLibsonic(t *testing.T) {
+	for _, sampleRate := range []int{8000, 44100} {
+		for _, numChannels := range []int{1, 2} {
+			samples := speech(numChannels)
+			for _, cfg := range allSettings {
+				for _, blockSize := range []int{64, 48000} {
+					for _, format := range []sampleFormat{formatShort, formatFloat, formatUnsignedChar} {
+						name := fmt.Sprintf("%d/%dch/%v/block=%d/%s", sampleRate, numChannels, cfg, blockSize, format)
+						t.Run(name, func(t *testing.T) {
+							g, _ := gosonic.CreateStream(sampleRate, numChannels)
+							defer g.DestroyStream()
+							c, err := cgosonic.CreateStream(sampleRate, numChannels)
+							if err != nil {
+								t.Fatal(err)
+							}
+							defer c.DestroyStream()
+
+							got := process(t, g, cfg, samples, numChannels, blockSize, format)
+							want := process(t, c, cfg, samples, numChannels, blockSize, format)
+							if len(got) != len(want) {
+								t.Fatalf("output has %d samples, want %d", len(got), len(want))
+							}
+							for i := range got {
+								if got[i] != want[i] {
+									t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+								}
+							}
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestStream_GettersMatchLibsonic(t *testing.T) {
+	g, _ := gosonic.CreateStream(0, 100)
+	defer g.DestroyStream()
+	c, err := cgosonic.CreateStream(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.DestroyStream()
+
+	if g.GetSampleRate() != c.GetSampleRate() || g.GetNumChannels() != c.GetNumChannels() {
+		t.Errorf("clamped stream has %d Hz and %d channels, want %d Hz and %d channels",
+			g.GetSampleRate(), g.GetNumChannels(), c.GetSampleRate(), c.GetNumChannels())
+	}
+	for _, v := range []float32{0, 0.01, 1, 3, 1000} {
+		g.SetSpeed(v)
+		c.SetSpeed(v)
+		g.SetPitch(v)
+		c.SetPitch(v)
+		g.SetRate(v)
+		c.SetRate(v)
+		g.SetVolume(v)
+		c.SetVolume(v)
+		g.SetQuality(int(v))
+		c.SetQuality(int(v))
+		g.SetChordPitch(int(v))
+		c.SetChordPitch(int(v))
+		got := []float32{g.GetSpeed(), g.GetPitch(), g.GetRate(), g.GetVolume(), float32(g.GetQuality()), float32(g.GetChordPitch())}
+		want := []float32{c.GetSpeed(), c.GetPitch(), c.GetRate(), c.GetVolume(), float32(c.GetQuality()), float32(c.GetChordPitch())}
+		if !slices.Equal(got, want) {
+			t.Errorf("settings after setting %v = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestChangeShortSpeed_MatchesLibsonic(t *testing.T) {
+	samples := speech(1)
+	got := slices.Clone(samples)
+	want := slices.Clone(samples)
+	// Speed up, so that the output fits into the samples, which the call reuses for it.
+	n := gosonic.ChangeShortSpeed(got, len(got)/2, 1.2, 1, 1, 1, audiotest.SpeechSampleRate, 1)
+	m := cgosonic.ChangeShortSpeed(want, len(want)/2, 1.2, 1, 1, 1, audiotest.SpeechSampleRate, 1)
+	if n != m || !slices.Equal(got[:n], want[:m]) {
+		t.Errorf("ChangeShortSpeed() = %d samples, want the %d samples of libsonic", n, m)
+	}
+}
@@ -0,0 +1,66 @@
+// Package wavtest builds WAVE files for the tests of the WAVE readers, including the seed corpus
+// of malformed files shared by their fuzz tests.
+package wavtest
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// Header builds a RIFF/WAVE header with a 16-bit PCM fmt chunk of fmtSize bytes followed by the
+// given chunks. The RIFF size is unknown, as written by streaming encoders.
+func Header(fmtSize uint32, numChannels uint16, sampleRate uint32, chunks ...[]byte) []byte {
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, 0xFFFFFFFF)
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, fmtSize)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, numChannels)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate*uint32(numChannels)*2)
+	b = binary.LittleEndian.AppendUint16(b, numChannels*2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	return b
+}
+
+// Chunk builds a chunk header with the given id and size, followed by data. size need not match
+// the length of data.
+func Chunk(id string, size uint32, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32([]byte(id), size)
+	return append(b, data...)
+}
+
+// Seeds returns the seed corpus for fuzzing WAVE readers: well-formed files, truncated headers
+// and chunks, absurd sizes, invalid channel counts, overlapping chunks and unusual chunk layouts.
+func Seeds() [][]byte {
+	samples := make([]byte, 64)
+	valid := Header(16, 1, 8000, Chunk("data", 64, samples))
+	return [][]byte{
+		// Well-formed files
+		valid,
+		Header(16, 2, 44100, Chunk("LIST", 4, []byte("INFO")), Chunk("data", 64, samples)),
+		// Truncated headers and chunks
+		[]byte("RIFF"),
+		Header(16, 1, 8000)[:30],
+		Header(16, 1, 8000, []byte("da")),
+		Header(16, 1, 8000, Chunk("data", 64, samples[:10])),
+		// Missing data chunk
+		Header(16, 1, 8000, Chunk("LIST", 4, []byte("INFO"))),
+		// Absurd sizes
+		Header(16, 1, 8000, Chunk("LIST", 0x7FFFFFFF, nil), Chunk("data", 64, samples)),
+		Header(16, 1, 8000, Chunk("data", 0xFFFFFFFF, samples)),
+		Header(0xFFFFFFF0, 1, 8000, Chunk("data", 64, samples)),
+		Header(16, 0xFFFF, 0xFFFFFFFF, Chunk("data", 64, samples)),
+		// Invalid channel counts
+		Header(16, 0, 8000, Chunk("data", 64, samples)),
+		// Overlapping chunks: a size that points back into the header
+		Header(16, 1, 8000, Chunk("LIST", 0xFFFFFFF8, nil), Chunk("data", 64, samples)),
+		// Unusual layouts: a padded chunk before the data, and a chunk before fmt
+		Header(16, 1, 8000, Chunk("junk", 3, []byte("abc\x00")), Chunk("data", 64, samples)),
+		slices.Concat(valid[:12], Chunk("junk", 4, []byte("abcd")), valid[12:]),
+	}
+}
@@ -0,0 +1,73 @@
+package sonic
+
+import "time"
+
+const maxFixedLatency = 10 * time.Second
+
+// queueOutput delivers p to the output, or queues it to be released on the schedule of the fixed
+// latency, if any. Queued output first pays off the frames of silence inserted on underruns.
+func (t *Transformer) queueOutput(p []byte) error {
+	if t.latencyBuf == nil {
+		return t.writeOutputNow(p)
+	}
+	frameSize := t.OutputFrameSize()
+	drop := min(t.latencyDebt, len(p)/frameSize)
+	t.latencyDebt -= drop
+	t.latencyBuf = append(t.latencyBuf, p[drop*frameSize:]...)
+	return nil
+}
+
+// releaseLatency delivers the output due for n bytes of consumed input, so that the output lags
+// the input by exactly the fixed latency. If the stream has not produced enough output yet,
+// silence is delivered in its place, and as much of the later output is dropped to stay on
+// schedule.
+func (t *Transformer) releaseLatency(n int) error {
+	if t.latencyBuf == nil {
+		return nil
+	}
+	frameSize := t.OutputFrameSize()
+	t.latencyDue += float64(n/t.FrameSize()) / t.timeScale()
+	due := int(t.latencyDue + 1e-6) // Tolerate the rounding errors of the accumulation
+	t.latencyDue -= float64(due)
+
+	available := min(due, len(t.latencyBuf)/frameSize)
+	err := t.writeOutputNow(t.latencyBuf[:available*frameSize])
+	t.latencyBuf = t.latencyBuf[:copy(t.latencyBuf, t.latencyBuf[available*frameSize:])]
+	if err != nil {
+		return err
+	}
+	if missing := due - available; missing > 0 {
+		t.latencyDebt += missing
+		t.underrunFrames += int64(missing)
+		return t.writeSilence(missing)
+	}
+	return nil
+}
+
+// flushLatency delivers all queued output and primes the queue with the silence of the fixed
+// latency for the output after the Flush.
+func (t *Transformer) flushLatency() error {
+	if t.latencyBuf == nil {
+		return nil
+	}
+	err := t.writeOutputNow(t.latencyBuf)
+	t.latencyBuf = t.latencyBuf[:t.latencyFrames*t.OutputFrameSize()]
+	t.outFormat.fillSilence(t.latencyBuf)
+	t.latencyDue = 0
+	t.latencyDebt = 0
+	return err
+}
+
+// writeSilence delivers frames frames of silence to the output.
+func (t *Transformer) writeSilence(frames int) error {
+	frameSize := t.OutputFrameSize()
+	for frames > 0 {
+		silence := t.streamBuffer[:min(frames, len(t.streamBuffer)/frameSize)*frameSize]
+		t.outFormat.fillSilence(silence)
+		if err := t.writeOutputNow(silence); err != nil {
+			return err
+		}
+		frames -= len(silence) / frameSize
+	}
+	return nil
+}
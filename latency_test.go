@@ -0,0 +1,79 @@
+package sonic
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_FixedLatency(t *testing.T) {
+	speech := audiotest.Speech()
+	const block = 256
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		latency       time.Duration
+		scale         float64 // Input frames per output frame
+		wantUnderruns bool
+	}{
+		{"speed 1", nil, 100 * time.Millisecond, 1, false},
+		{"speed 2", []Option{WithSpeed(2)}, 100 * time.Millisecond, 2, false},
+		{"slow rate", []Option{WithSpeed(0.8), WithRate(0.9)}, 100 * time.Millisecond, 0.72, false},
+		{"fade-out", []Option{WithFadeOut(20 * time.Millisecond)}, 100 * time.Millisecond, 1, false},
+		{"underrun", []Option{WithSpeed(1.5)}, time.Millisecond, 1.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out []int16
+			opts := append(slices.Clone(tt.opts), WithFixedLatency(tt.latency), WithOutputFunc(func(p []byte) error {
+				out = append(out, pcm.DecodeInt16(nil, p)...)
+				return nil
+			}))
+			tr, err := NewTransformer(nil, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			latencyFrames := SamplesForDuration(tt.latency, audiotest.SpeechSampleRate, 1)
+			written := 0
+			for chunk := range slices.Chunk(speech, block) {
+				if _, err := tr.Write(pcm.EncodeInt16(nil, chunk)); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				written += len(chunk)
+				want := int(float64(written)/tt.scale + 1e-9)
+				if len(out) != want {
+					t.Fatalf("after %d input frames, output has %d frames, want %d", written, len(out), want)
+				}
+			}
+			if !slices.Equal(out[:latencyFrames], make([]int16, latencyFrames)) {
+				t.Errorf("output does not start with %d frames of silence", latencyFrames)
+			}
+			if underruns := tr.Stats().UnderrunFrames; (underruns > 0) != tt.wantUnderruns {
+				t.Errorf("UnderrunFrames = %d, want underruns %v", underruns, tt.wantUnderruns)
+			}
+
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if want := latencyFrames + int(math.Round(float64(len(speech))/tt.scale)); !tt.wantUnderruns && math.Abs(float64(len(out)-want)) > 0.01*float64(want) {
+				t.Errorf("output has %d frames after Flush, want about %d", len(out), want)
+			}
+
+			// The output after Flush is delayed by the latency again.
+			out = out[:0]
+			if _, err := tr.Write(pcm.EncodeInt16(nil, speech[:block])); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if n := min(len(out), latencyFrames); n == 0 || !slices.Equal(out[:n], make([]int16, n)) {
+				t.Errorf("output after Flush does not start with silence")
+			}
+		})
+	}
+}
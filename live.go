@@ -0,0 +1,184 @@
+package sonic
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+// Settings holds the parameters of a Transformer that can be changed while it is running.
+// Nil fields are left unchanged. Values are clamped as by WithSpeed, WithPitch, WithRate and
+// WithVolume, and to the limits set with SetLimits.
+type Settings struct {
+	Speed   *float32
+	Pitch   *float32
+	Rate    *float32 // Ignored with WithNominalRate, since the output sample rate is fixed
+	Volume  *float32
+	Quality *int // Non-zero disables the speed-up heuristics, as WithQuality does
+}
+
+// merge returns s with copies of the non-nil fields of u.
+func (s Settings) merge(u Settings) Settings {
+	if u.Speed != nil {
+		v := *u.Speed
+		s.Speed = &v
+	}
+	if u.Pitch != nil {
+		v := *u.Pitch
+		s.Pitch = &v
+	}
+	if u.Rate != nil {
+		v := *u.Rate
+		s.Rate = &v
+	}
+	if u.Volume != nil {
+		v := *u.Volume
+		s.Volume = &v
+	}
+	if u.Quality != nil {
+		v := *u.Quality
+		s.Quality = &v
+	}
+	return s
+}
+
+// Update changes the settings of the transformer.
+//
+// Unlike the other methods, Update may be called concurrently with Write and Flush. The settings
+// are applied all at once before the next Write or Flush processes any input, so a Write in
+// progress finishes with the previous settings. Updates made before they are applied are merged.
+func (t *Transformer) Update(s Settings) {
+	t.updateMu.Lock()
+	defer t.updateMu.Unlock()
+	if t.update == nil {
+		t.update = &Settings{}
+	}
+	*t.update = t.update.merge(s)
+}
+
+// SetSpeed changes the speed of the transformer, e.g. when the listener of a podcast player
+// picks another speed during playback. It is a shorthand for Update, so it may be called
+// concurrently with Write and Flush, and takes effect before the next one processes any input.
+func (t *Transformer) SetSpeed(speed float32) {
+	t.Update(Settings{Speed: &speed})
+}
+
+// SetPitch changes the pitch of the transformer, as Update does.
+func (t *Transformer) SetPitch(pitch float32) {
+	t.Update(Settings{Pitch: &pitch})
+}
+
+// SetRate changes the rate of the transformer, as Update does. It is ignored with
+// WithNominalRate.
+func (t *Transformer) SetRate(rate float32) {
+	t.Update(Settings{Rate: &rate})
+}
+
+// SetVolume changes the volume of the transformer, as Update does.
+func (t *Transformer) SetVolume(volume float32) {
+	t.Update(Settings{Volume: &volume})
+}
+
+// SetQuality changes the quality setting of the transformer, as Update does. Non-zero disables
+// the speed-up heuristics, as WithQuality does, and zero enables them again.
+func (t *Transformer) SetQuality(quality int) {
+	t.Update(Settings{Quality: &quality})
+}
+
+// applyUpdate applies the settings passed to Update since the last call.
+func (t *Transformer) applyUpdate() {
+	t.updateMu.Lock()
+	s := t.update
+	t.update = nil
+	t.updateMu.Unlock()
+	if s == nil {
+		return
+	}
+
+	if s.Speed != nil {
+		val := clamp(*s.Speed, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED)
+		t.speed = &val
+	}
+	if s.Pitch != nil {
+		val := clamp(*s.Pitch, cgosonic.MIN_PITCH_SETTING, cgosonic.MAX_PITCH_SETTING)
+		t.pitch = &val
+	}
+	if s.Rate != nil && !t.nominalRate {
+		val := clamp(*s.Rate, cgosonic.MIN_RATE, cgosonic.MAX_RATE)
+		t.rate = &val
+	}
+	if s.Volume != nil {
+		val := clamp(*s.Volume, cgosonic.MIN_VOLUME, cgosonic.MAX_VOLUME)
+		t.volume = &val
+	}
+	if s.Quality != nil {
+		val := 0
+		if *s.Quality != 0 {
+			val = 1
+		}
+		t.quality = &val
+	}
+	t.applyLimits()
+
+	if t.midSide != nil {
+		t.configureStream(t.midSide.mid)
+		t.configureStream(t.midSide.side)
+	} else {
+		t.configureStream(t.stream)
+	}
+}
+
+// LiveConfig applies settings from a configuration source to a set of running transformers,
+// e.g. so that the operators of a streaming daemon can tune the speed and volume at runtime.
+//
+// The zero value is ready to use. All methods may be called concurrently.
+type LiveConfig struct {
+	mu           sync.Mutex
+	current      Settings
+	transformers map[*Transformer]struct{}
+}
+
+// ConfigSource delivers settings to update until ctx is done or the source fails. It is
+// typically an adapter around a file watcher or a configuration service client.
+type ConfigSource func(ctx context.Context, update func(Settings)) error
+
+// Register adds t to the transformers that receive updates, and applies the settings received
+// so far to it. Call the returned function to remove t, e.g. before closing it.
+func (c *LiveConfig) Register(t *Transformer) (unregister func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transformers == nil {
+		c.transformers = make(map[*Transformer]struct{})
+	}
+	c.transformers[t] = struct{}{}
+	t.Update(c.current)
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.transformers, t)
+	}
+}
+
+// Apply passes s to all registered transformers. Each transformer applies it as a whole before
+// its next Write or Flush; see Transformer.Update.
+func (c *LiveConfig) Apply(s Settings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.current.merge(s)
+	for t := range c.transformers {
+		t.Update(s)
+	}
+}
+
+// Current returns the settings received so far, merged.
+func (c *LiveConfig) Current() Settings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// Watch runs src, applying every update it delivers with Apply, and returns its error.
+func (c *LiveConfig) Watch(ctx context.Context, src ConfigSource) error {
+	return src(ctx, c.Apply)
+}
@@ -0,0 +1,190 @@
+package sonic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_Update(t *testing.T) {
+	tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	speed, volume := float32(2), float32(0.5)
+	tr.Update(Settings{Speed: &speed})
+	tr.Update(Settings{Volume: &volume})
+	speed = 3 // Update copies the settings
+	if got := tr.stream.GetSpeed(); got != 1.5 {
+		t.Errorf("speed before Write = %v, want 1.5", got)
+	}
+	if _, err := tr.Write(make([]byte, 2)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := tr.stream.GetSpeed(); got != 2 {
+		t.Errorf("speed after Write = %v, want 2", got)
+	}
+	if got := tr.stream.GetVolume(); got != 0.5 {
+		t.Errorf("volume after Write = %v, want 0.5", got)
+	}
+
+	pitch := float32(100)
+	tr.Update(Settings{Pitch: &pitch})
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := tr.stream.GetPitch(); got != 20 {
+		t.Errorf("pitch after Flush = %v, want 20 (clamped)", got)
+	}
+	if got := tr.stream.GetSpeed(); got != 2 {
+		t.Errorf("speed after Flush = %v, want 2 (unchanged)", got)
+	}
+}
+
+func TestTransformer_Setters(t *testing.T) {
+	tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithQuality())
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	tr.SetSpeed(1.5)
+	tr.SetPitch(0.8)
+	tr.SetRate(1.25)
+	tr.SetVolume(50000)
+	tr.SetQuality(0)
+	if got := tr.stream.GetSpeed(); got != 1 {
+		t.Errorf("speed before Write = %v, want 1", got)
+	}
+	if _, err := tr.Write(make([]byte, 2)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got := []float32{tr.stream.GetSpeed(), tr.stream.GetPitch(), tr.stream.GetRate(), tr.stream.GetVolume(), float32(*tr.quality)}
+	if want := []float32{1.5, 0.8, 1.25, 100, 0}; !slices.Equal(got, want) {
+		t.Errorf("speed, pitch, rate, volume and quality after Write = %v, want %v", got, want)
+	}
+
+	tr.SetQuality(7)
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := *tr.quality; got != 1 {
+		t.Errorf("quality after Flush = %d, want 1", got)
+	}
+}
+
+func TestTransformer_UpdateNominalRate(t *testing.T) {
+	tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithRate(2), WithNominalRate())
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	rate := float32(1.5)
+	tr.Update(Settings{Rate: &rate})
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := tr.OutputSampleRate(); got != 88200 {
+		t.Errorf("OutputSampleRate() = %d, want 88200", got)
+	}
+}
+
+func TestLiveConfig(t *testing.T) {
+	var c LiveConfig
+	newTransformer := func() *Transformer {
+		tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		t.Cleanup(func() { tr.Close() })
+		return tr
+	}
+	a, b := newTransformer(), newTransformer()
+	c.Register(a)
+	unregisterB := c.Register(b)
+
+	speed, volume := float32(2), float32(0.5)
+	errStopped := errors.New("stopped")
+	err := c.Watch(context.Background(), func(ctx context.Context, update func(Settings)) error {
+		update(Settings{Speed: &speed})
+		update(Settings{Volume: &volume})
+		return errStopped
+	})
+	if !errors.Is(err, errStopped) {
+		t.Errorf("Watch() error = %v, want %v", err, errStopped)
+	}
+	if s := c.Current(); s.Speed == nil || *s.Speed != 2 || s.Volume == nil || *s.Volume != 0.5 || s.Pitch != nil {
+		t.Errorf("Current() = %+v", s)
+	}
+
+	unregisterB()
+	late := newTransformer()
+	c.Register(late)
+	pitch := float32(1.2)
+	c.Apply(Settings{Pitch: &pitch})
+
+	for _, tt := range []struct {
+		name                 string
+		tr                   *Transformer
+		speed, pitch, volume float32
+	}{
+		{"registered", a, 2, 1.2, 0.5},
+		{"unregistered", b, 2, 1, 0.5},
+		{"registered late", late, 2, 1.2, 0.5},
+	} {
+		if err := tt.tr.Flush(); err != nil {
+			t.Fatalf("%s: Flush() error = %v", tt.name, err)
+		}
+		s := tt.tr.stream
+		if s.GetSpeed() != tt.speed || s.GetPitch() != tt.pitch || s.GetVolume() != tt.volume {
+			t.Errorf("%s: speed, pitch, volume = %v, %v, %v, want %v, %v, %v",
+				tt.name, s.GetSpeed(), s.GetPitch(), s.GetVolume(), tt.speed, tt.pitch, tt.volume)
+		}
+	}
+}
+
+// TestLiveConfig_Concurrent updates settings while audio is written, for the race detector.
+func TestLiveConfig_Concurrent(t *testing.T) {
+	var c LiveConfig
+	tr, err := NewTransformer(new(bytes.Buffer), audiotest.SpeechSampleRate, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	defer c.Register(tr)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Watch(ctx, func(ctx context.Context, update func(Settings)) error {
+			for i := 0; ctx.Err() == nil; i++ {
+				speed := 1 + float32(i%10)/10
+				update(Settings{Speed: &speed})
+			}
+			return ctx.Err()
+		})
+	}()
+	input := pcm.EncodeInt16(nil, audiotest.Speech())
+	for len(input) > 0 {
+		n := min(len(input), 2048)
+		if _, err := tr.Write(input[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		input = input[n:]
+	}
+	cancel()
+	wg.Wait()
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+}
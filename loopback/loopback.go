@@ -0,0 +1,198 @@
+// Package loopback measures the end-to-end latency of sonic.Transformer configurations, to help
+// live-audio integrators budget the delay of the time stretching.
+//
+// Measure writes a test signal, silence with a tone burst, to a Transformer in the chunk size of
+// a live source, and detects the onset of the burst in the output as it is delivered. Signal and
+// Onset are exported as well, so that the same test signal can be sent through a complete audio
+// path, e.g. out of a speaker and into a microphone, and detected in the recording.
+package loopback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/signal"
+)
+
+const (
+	burstFreq      = 1000                  // Frequency of the tone burst in Hz
+	burstLength    = 20 * time.Millisecond // Length of the tone burst
+	burstAmplitude = 0.5                   // Amplitude of the tone burst
+	onsetLevel     = 0.25                  // Onset threshold relative to the amplitude of the burst
+
+	leadIn  = time.Second     // Silence before the burst, to settle the stream
+	leadOut = 2 * time.Second // Silence after the burst, to push it through the stream
+
+	defaultWriteFrames = 10 * time.Millisecond
+)
+
+// ErrNoOnset is returned when the tone burst is not found in the output.
+var ErrNoOnset = errors.New("onset of the test signal not found in the output")
+
+// Setting is a named transformer configuration to measure.
+type Setting struct {
+	Name    string
+	Options []sonic.Option
+
+	// WriteFrames is the number of frames passed to each Write call, i.e. the buffer size of the
+	// live source. The latency is measured in steps of this size. 0 writes 10 ms per call.
+	WriteFrames int
+}
+
+// Result holds the latency of one Setting.
+type Result struct {
+	Setting string
+
+	// Latency is the duration of the input written from the onset of the burst until the output
+	// containing the onset was delivered. It is the delay a live source experiences: it includes
+	// the write that holds the onset, since the source buffers it before writing, and the
+	// buffering of the stream and of WithFixedLatency. It is measured on the input timeline, so a
+	// fixed latency is scaled by the speed, and it is accurate to one write.
+	Latency time.Duration
+
+	// Flushed reports that the onset was only delivered by Flush, i.e. that the configuration
+	// holds back more input than the silence following the burst. Latency is a lower bound then.
+	Flushed bool
+
+	// Offset is the position of the onset in the output minus the position expected from the
+	// speed and rate, see Transformer.OutputSamplesForInput, on the output timeline. It tells
+	// how much the processing, e.g. WithFixedLatency, shifts the output.
+	Offset time.Duration
+
+	// Estimate is the input latency estimated by the Transformer with InputLatency when the
+	// onset was delivered, for comparison.
+	Estimate time.Duration
+}
+
+// Signal returns mono float samples at sampleRate of a tone burst, starting after lead-in
+// silence and followed by lead-out silence. It also returns the frame of the onset.
+func Signal(sampleRate int) ([]float32, int) {
+	onset := int(int64(leadIn) * int64(sampleRate) / int64(time.Second))
+	burst := int(int64(burstLength) * int64(sampleRate) / int64(time.Second))
+	tail := int(int64(leadOut) * int64(sampleRate) / int64(time.Second))
+	samples := make([]float32, onset+burst+tail)
+	copy(samples[onset:], signal.Sine(sampleRate, burstFreq, burstAmplitude, burst))
+	return samples, onset
+}
+
+// Onset returns the first frame of the interleaved samples with numChannels channels whose
+// magnitude reaches a quarter of the amplitude of the burst of Signal in any channel, or -1 if
+// there is none.
+func Onset(samples []float32, numChannels int) int {
+	for i, v := range samples {
+		if math.Abs(float64(v)) >= burstAmplitude*onsetLevel {
+			return i / numChannels
+		}
+	}
+	return -1
+}
+
+// Measure writes Signal at sampleRate through a Transformer with the options of setting and
+// measures the latency of the output. The input is float, with the burst in every channel; the
+// format of the output is forced to float. If the options lower the volume by 12 dB or more, the
+// onset is not detected and ErrNoOnset is returned.
+func Measure(sampleRate int, setting Setting) (Result, error) {
+	var output []float32
+	opts := append(setting.Options[:len(setting.Options):len(setting.Options)],
+		sonic.WithOutputFormat(sonic.AudioFormatIEEEFloat),
+		sonic.WithOutputFunc(func(p []byte) error {
+			output = append(output, pcm.DecodeFloat32(nil, p)...)
+			return nil
+		}))
+	tr, err := sonic.NewTransformer(nil, sampleRate, sonic.AudioFormatIEEEFloat, opts...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tr.Close()
+
+	mono, onset := Signal(sampleRate)
+	numChannels := tr.FrameSize() / sonic.AudioFormatIEEEFloat.SampleSize()
+	outChannels := tr.OutputFrameSize() / sonic.AudioFormatIEEEFloat.SampleSize()
+	input := make([]float32, len(mono)*numChannels)
+	for i, v := range mono {
+		for ch := range numChannels {
+			input[i*numChannels+ch] = v
+		}
+	}
+	p := pcm.EncodeFloat32(nil, input)
+
+	writeFrames := setting.WriteFrames
+	if writeFrames <= 0 {
+		writeFrames = tr.SamplesForDuration(defaultWriteFrames)
+	}
+	chunk := max(writeFrames, 1) * tr.FrameSize()
+
+	r := Result{Setting: setting.Name}
+	found := -1
+	written := 0 // Input frames written
+	scanned := 0 // Output frames without the onset
+	for len(p) > 0 && found < 0 {
+		n := min(len(p), chunk)
+		if _, err := tr.Write(p[:n]); err != nil {
+			return Result{}, err
+		}
+		p = p[n:]
+		written += n / tr.FrameSize()
+		if i := Onset(output[scanned*outChannels:], outChannels); i >= 0 {
+			found = scanned + i
+			r.Latency = tr.DurationForSamples(written - onset)
+			r.Estimate = tr.InputLatency()
+		}
+		scanned = len(output) / outChannels
+	}
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		if _, err := tr.Write(p[:n]); err != nil {
+			return Result{}, err
+		}
+		p = p[n:]
+	}
+	if err := tr.Flush(); err != nil {
+		return Result{}, err
+	}
+	if found < 0 {
+		if found = Onset(output, outChannels); found < 0 {
+			return Result{}, ErrNoOnset
+		}
+		r.Flushed = true
+		r.Latency = tr.DurationForSamples(len(mono) - onset)
+		r.Estimate = tr.InputLatency()
+	}
+
+	expected := tr.OutputSamplesForInput(onset*numChannels) / outChannels
+	r.Offset = time.Duration(found-expected) * time.Second / time.Duration(tr.OutputSampleRate())
+	return r, nil
+}
+
+// Run measures every setting at sampleRate. See Measure.
+func Run(sampleRate int, settings []Setting) ([]Result, error) {
+	results := make([]Result, 0, len(settings))
+	for _, s := range settings {
+		r, err := Measure(sampleRate, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// WriteReport writes results to w as a table.
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "setting\tlatency\testimate\toffset\t")
+	for _, r := range results {
+		latency := r.Latency.Round(time.Millisecond).String()
+		if r.Flushed {
+			latency = ">" + latency
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", r.Setting, latency, r.Estimate.Round(time.Millisecond), r.Offset.Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
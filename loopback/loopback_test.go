@@ -0,0 +1,104 @@
+package loopback
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+)
+
+func TestSignal(t *testing.T) {
+	for _, sampleRate := range []int{8000, 44100} {
+		samples, onset := Signal(sampleRate)
+		if want := sampleRate; onset != want {
+			t.Errorf("Signal(%d) onset = %d, want %d", sampleRate, onset, want)
+		}
+		// The sine starts at 0, so the first sample above the threshold follows the onset.
+		if got := Onset(samples, 1); got <= onset || onset+sampleRate/1000 < got {
+			t.Errorf("Onset() = %d, want within a millisecond after %d", got, onset)
+		}
+		if got := Onset(samples[:onset], 1); got != -1 {
+			t.Errorf("Onset() of the lead-in = %d, want -1", got)
+		}
+	}
+	if got := Onset([]float32{0, 0, 0, 0.1, 0, -0.2}, 2); got != 2 {
+		t.Errorf("Onset() of stereo samples = %d, want 2", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	settings := []Setting{
+		{Name: "identity", Options: []sonic.Option{sonic.WithSpeed(1)}},
+		{Name: "speed 2", Options: []sonic.Option{sonic.WithSpeed(2)}},
+		{Name: "speed 0.5 stereo", Options: []sonic.Option{sonic.WithSpeed(0.5), sonic.WithChannels(2)}},
+		{Name: "fixed latency", Options: []sonic.Option{sonic.WithFixedLatency(200 * time.Millisecond)}, WriteFrames: 441},
+		{Name: "large writes", Options: []sonic.Option{sonic.WithSpeed(2)}, WriteFrames: 4410},
+	}
+	results, err := Run(44100, settings)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != len(settings) {
+		t.Fatalf("Run() returned %d results, want %d", len(results), len(settings))
+	}
+	for _, r := range results {
+		if r.Flushed || r.Latency < 0 || r.Latency > 100*time.Millisecond && r.Setting != "fixed latency" {
+			t.Errorf("%s: Latency = %v, Flushed = %v, want below 100ms", r.Setting, r.Latency, r.Flushed)
+		}
+		if r.Setting != "fixed latency" && (r.Offset < -15*time.Millisecond || 15*time.Millisecond < r.Offset) {
+			t.Errorf("%s: Offset = %v, want about 0", r.Setting, r.Offset)
+		}
+	}
+	// The onset is at the start of a write, which is delivered at once without time stretching.
+	if r := results[0]; r.Latency != 10*time.Millisecond || r.Offset < 0 || time.Millisecond < r.Offset {
+		t.Errorf("identity: Latency = %v, Offset = %v, want one write and about 0", r.Latency, r.Offset)
+	}
+	if r := results[3]; r.Latency != 210*time.Millisecond || r.Offset < 199*time.Millisecond || 201*time.Millisecond < r.Offset {
+		t.Errorf("fixed latency: Latency = %v, Offset = %v, want one write plus 200ms and 200ms", r.Latency, r.Offset)
+	}
+	// The onset is at the start of a write, so the latency is a whole number of writes.
+	if r := results[4]; r.Latency%(100*time.Millisecond) != 0 {
+		t.Errorf("large writes: Latency = %v, want a multiple of 100ms", r.Latency)
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, results); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	t.Log("\n" + report.String())
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != len(results)+1 || !strings.Contains(lines[0], "latency") || !strings.Contains(lines[4], "fixed latency") {
+		t.Errorf("WriteReport() =\n%s", report.String())
+	}
+}
+
+func TestMeasure_Flushed(t *testing.T) {
+	// The fixed latency holds back more than the silence after the burst.
+	r, err := Measure(8000, Setting{Name: "long", Options: []sonic.Option{sonic.WithFixedLatency(5 * time.Second)}})
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if !r.Flushed {
+		t.Errorf("Flushed = false, want true")
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, []Result{r}); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	if !strings.Contains(report.String(), ">") {
+		t.Errorf("WriteReport() =\n%s, want the latency marked as a lower bound", report.String())
+	}
+}
+
+func TestMeasure_Errors(t *testing.T) {
+	if _, err := Measure(8000, Setting{Options: []sonic.Option{sonic.WithVolume(0.1)}}); !errors.Is(err, ErrNoOnset) {
+		t.Errorf("Measure() with a quiet output error = %v, want %v", err, ErrNoOnset)
+	}
+	if _, err := Measure(8000, Setting{Options: []sonic.Option{sonic.WithSelectChannels(5)}}); !errors.Is(err, sonic.ErrInvalid) {
+		t.Errorf("Measure() with invalid options error = %v, want %v", err, sonic.ErrInvalid)
+	}
+}
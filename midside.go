@@ -0,0 +1,135 @@
+package sonic
+
+import (
+	"errors"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// midSide processes a stereo signal as separate mid (L+R) and side (L-R) channels.
+//
+// Joint-channel time stretching picks one pitch period for both channels, which can collapse
+// the stereo image of music-with-speech content. midSide runs the M and S signals through two
+// independent mono streams and re-matrixes them on output, preserving the perceived width.
+type midSide struct {
+	mid  Stream
+	side Stream
+
+	midIn   []float32 // Scratch buffer for mid input samples
+	sideIn  []float32 // Scratch buffer for side input samples
+	midOut  []float32 // Mid output samples not yet re-matrixed
+	sideOut []float32 // Side output samples not yet re-matrixed
+	readBuf []float32 // Scratch buffer for reading from the streams
+	convBuf []float32 // Scratch buffer for int16 input converted by the transformer
+}
+
+// newMidSide creates a midSide processor with two mono streams created by engine.
+// configure is applied to both streams.
+func newMidSide(engine Engine, sampleRate int, configure func(Stream)) (*midSide, error) {
+	mid, err := engine.NewStream(sampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+	side, err := engine.NewStream(sampleRate, 1)
+	if err != nil {
+		mid.DestroyStream()
+		return nil, err
+	}
+	configure(mid)
+	configure(side)
+	return &midSide{
+		mid:     mid,
+		side:    side,
+		readBuf: make([]float32, streamBufferFrames),
+	}, nil
+}
+
+// write splits interleaved stereo samples into mid and side and writes them to the streams.
+func (m *midSide) write(samples []float32) error {
+	numFrames := len(samples) / 2
+	if numFrames == 0 {
+		return nil
+	}
+	m.midIn = m.midIn[:0]
+	m.sideIn = m.sideIn[:0]
+	for i := range numFrames {
+		l, r := samples[2*i], samples[2*i+1]
+		m.midIn = append(m.midIn, (l+r)/2)
+		m.sideIn = append(m.sideIn, (l-r)/2)
+	}
+	if m.mid.WriteFloatToStream(m.midIn, numFrames) == 0 {
+		return errors.New("failed to write samples to mid stream")
+	}
+	if m.side.WriteFloatToStream(m.sideIn, numFrames) == 0 {
+		return errors.New("failed to write samples to side stream")
+	}
+	m.drain()
+	return nil
+}
+
+// flush flushes both streams and pads the shorter output so that all samples can be read.
+func (m *midSide) flush() error {
+	if m.mid.FlushStream() == 0 {
+		return errors.New("failed to flush mid stream")
+	}
+	if m.side.FlushStream() == 0 {
+		return errors.New("failed to flush side stream")
+	}
+	m.drain()
+	for len(m.midOut) < len(m.sideOut) {
+		m.midOut = append(m.midOut, 0)
+	}
+	for len(m.sideOut) < len(m.midOut) {
+		m.sideOut = append(m.sideOut, 0)
+	}
+	return nil
+}
+
+// drain moves all available output of both streams into the pending buffers.
+func (m *midSide) drain() {
+	for {
+		n := m.mid.ReadFloatFromStream(m.readBuf, len(m.readBuf))
+		if n <= 0 {
+			break
+		}
+		m.midOut = append(m.midOut, m.readBuf[:n]...)
+	}
+	for {
+		n := m.side.ReadFloatFromStream(m.readBuf, len(m.readBuf))
+		if n <= 0 {
+			break
+		}
+		m.sideOut = append(m.sideOut, m.readBuf[:n]...)
+	}
+}
+
+// read re-matrixes pending output into dst as interleaved stereo samples.
+// It returns the number of frames written to dst.
+func (m *midSide) read(dst []float32) int {
+	numFrames := min(min(len(m.midOut), len(m.sideOut)), len(dst)/2)
+	for i := range numFrames {
+		mid, side := m.midOut[i], m.sideOut[i]
+		dst[2*i] = mid + side
+		dst[2*i+1] = mid - side
+	}
+	m.midOut = m.midOut[:copy(m.midOut, m.midOut[numFrames:])]
+	m.sideOut = m.sideOut[:copy(m.sideOut, m.sideOut[numFrames:])]
+	return numFrames
+}
+
+// readInt16 is like read, but writes int16 samples.
+func (m *midSide) readInt16(dst []int16) int {
+	if cap(m.convBuf) < len(dst) {
+		m.convBuf = make([]float32, len(dst))
+	}
+	buf := m.convBuf[:len(dst)]
+	numFrames := m.read(buf)
+	pcm.Float32ToInt16(dst[:numFrames*2], buf[:numFrames*2], int16Scaling)
+	return numFrames
+}
+
+// destroy releases both streams.
+func (m *midSide) destroy() {
+	m.mid.DestroyStream()
+	m.side.DestroyStream()
+}
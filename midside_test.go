@@ -0,0 +1,115 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// stereoSine generates interleaved stereo int16 samples. The right channel is the left channel scaled by rightGain.
+func stereoSine(numFrames int, sampleRate int, freq float64, rightGain float64) []int16 {
+	samples := make([]int16, numFrames*2)
+	for i := range numFrames {
+		v := 8000 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+		samples[2*i] = int16(v)
+		samples[2*i+1] = int16(v * rightGain)
+	}
+	return samples
+}
+
+func TestTransformer_MidSideRequiresStereo(t *testing.T) {
+	_, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithMidSide())
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewTransformer() error = %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestTransformer_MidSide(t *testing.T) {
+	const sampleRate = 44100
+
+	tests := []struct {
+		name      string
+		rightGain float64
+		check     func(t *testing.T, l, r int16)
+	}{
+		{"mono content", 1, func(t *testing.T, l, r int16) {
+			if abs(int(l)-int(r)) > 1 {
+				t.Fatalf("left %d != right %d for mono content", l, r)
+			}
+		}},
+		{"anti-phase content", -1, func(t *testing.T, l, r int16) {
+			if abs(int(l)+int(r)) > 1 {
+				t.Fatalf("left %d != -right %d for anti-phase content", l, r)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tr, err := NewTransformer(out, sampleRate, AudioFormatPCM, WithChannels(2), WithMidSide(), WithSpeed(1.5))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if tr.midSide == nil || tr.stream != nil {
+				t.Fatal("mid-side processor was not set up")
+			}
+
+			in := new(bytes.Buffer)
+			binary.Write(in, binary.LittleEndian, stereoSine(sampleRate, sampleRate, 300, tt.rightGain))
+			n, err := tr.Write(in.Bytes())
+			if err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if n != in.Len() {
+				t.Errorf("Write() n = %d, want %d", n, in.Len())
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if out.Len()%4 != 0 {
+				t.Fatalf("output length %d is not a multiple of the frame size", out.Len())
+			}
+			outSamples := make([]int16, out.Len()/2)
+			binary.Read(out, binary.LittleEndian, outSamples)
+
+			wantFrames := int(sampleRate / 1.5)
+			gotFrames := len(outSamples) / 2
+			if abs(gotFrames-wantFrames) > wantFrames/50 {
+				t.Errorf("output frames = %d, want about %d", gotFrames, wantFrames)
+			}
+			for i := 0; i < len(outSamples); i += 2 {
+				tt.check(t, outSamples[i], outSamples[i+1])
+			}
+		})
+	}
+}
+
+func TestTransformer_MidSideFloat32(t *testing.T) {
+	out := new(bytes.Buffer)
+	tr, err := NewTransformer(out, 44100, AudioFormatIEEEFloat, WithChannels(2), WithMidSide())
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	in := make([]float32, 44100*2)
+	for i := range in {
+		in[i] = float32(0.25 * math.Sin(float64(i/2)*0.05))
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, in)
+	if _, err := tr.Write(buf.Bytes()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := out.Len(), buf.Len(); abs(got-want) > want/100 {
+		t.Errorf("output length = %d, want about %d", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// goMod is the part of a go.mod file checked by checkModules.
+type goMod struct {
+	module   string
+	requires []string          // Required module paths
+	replaces map[string]string // Replacements of module paths, by the replaced path
+}
+
+// parseGoMod parses the go.mod file at name. An empty file yields a zero goMod.
+func parseGoMod(name string) (goMod, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return goMod{}, err
+	}
+	mod := goMod{replaces: make(map[string]string)}
+	block := "" // Directive of the enclosing block
+	for line := range strings.SplitSeq(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		var directive string
+		switch {
+		case len(fields) == 0:
+			continue
+		case block != "" && len(fields) == 1 && fields[0] == ")":
+			block = ""
+			continue
+		case block != "":
+			directive = block
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		default:
+			directive, fields = fields[0], fields[1:]
+		}
+		switch directive {
+		case "module":
+			if len(fields) == 1 {
+				mod.module = strings.Trim(fields[0], `"`)
+			}
+		case "require":
+			if len(fields) >= 1 {
+				mod.requires = append(mod.requires, fields[0])
+			}
+		case "replace":
+			if i := slices.Index(fields, "=>"); i >= 1 && i+1 < len(fields) {
+				mod.replaces[fields[0]] = fields[i+1]
+			}
+		}
+	}
+	return mod, nil
+}
+
+// isStandardImport reports whether importPath is a package of the standard library or cgo.
+func isStandardImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// checkModules checks the module boundaries of the repository at root, and returns the problems
+// found:
+//
+//   - The core module requires no other module, and its packages, including their tests, import
+//     only the standard library and the module itself, so that adding the core module to a
+//     project adds no dependencies.
+//   - Subpackages with third-party dependencies are nested modules, whose path is the path of
+//     the core module followed by their directory, so that they are tagged as dir/vX.Y.Z.
+//   - A nested module requiring the core module replaces it with the directory of the core
+//     module, so that its tests run against the core module in the same tree.
+//
+// Empty go.mod files, which only exclude a directory from the core module, are skipped.
+func checkModules(root string) ([]string, error) {
+	core, err := parseGoMod(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	if core.module == "" {
+		return nil, fmt.Errorf("%s: no module directive", filepath.Join(root, "go.mod"))
+	}
+	var problems []string
+	for _, req := range core.requires {
+		problems = append(problems, fmt.Sprintf("go.mod: the core module requires %s", req))
+	}
+
+	ctx := build.Default
+	ctx.UseAllFiles = true // Check the imports of all build configurations
+	ctx.CgoEnabled = true
+	err = filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			// Skip testdata, hidden directories and nested modules, as the go command does
+			name := d.Name()
+			if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				problems = append(problems, checkNestedModule(root, rel, core.module)...)
+				return filepath.SkipDir
+			}
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		pkg, err := ctx.ImportDir(abs, 0)
+		if err != nil {
+			var noGo *build.NoGoError
+			if errors.As(err, &noGo) {
+				return nil
+			}
+			return err
+		}
+		imports := slices.Concat(pkg.Imports, pkg.TestImports, pkg.XTestImports)
+		slices.Sort(imports)
+		for _, imp := range slices.Compact(imports) {
+			if !isStandardImport(imp) && imp != core.module && !strings.HasPrefix(imp, core.module+"/") {
+				problems = append(problems, fmt.Sprintf("%s: the core module imports %s", rel, imp))
+			}
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// checkNestedModule checks the nested module at rel of the core module at root.
+func checkNestedModule(root, rel, corePath string) []string {
+	name := path.Join(rel, "go.mod")
+	mod, err := parseGoMod(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if mod.module == "" {
+		return nil
+	}
+	var problems []string
+	if want := corePath + "/" + rel; mod.module != want {
+		problems = append(problems, fmt.Sprintf("%s: module %s, want %s", name, mod.module, want))
+	}
+	if slices.Contains(mod.requires, corePath) {
+		want := strings.TrimSuffix(strings.Repeat("../", strings.Count(rel, "/")+1), "/")
+		if got, ok := mod.replaces[corePath]; !ok || path.Clean(got) != want {
+			problems = append(problems, fmt.Sprintf("%s: %s is not replaced with %s", name, corePath, want))
+		}
+	}
+	return problems
+}
+
+func TestModules(t *testing.T) {
+	problems, err := checkModules(".")
+	if err != nil {
+		t.Fatalf("checkModules() error = %v", err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+func TestCheckModules(t *testing.T) {
+	const coreGoMod = "module example.com/core\n\ngo 1.24\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "dependency-free",
+			files: map[string]string{
+				"go.mod":            coreGoMod,
+				"core.go":           "package core\n\nimport (\n\t\"fmt\"\n\t_ \"example.com/core/sub\"\n)\n\nvar _ = fmt.Sprint\n",
+				"core_test.go":      "package core_test\n\nimport _ \"example.com/core\"\n",
+				"sub/sub.go":        "package sub\n\n// #include <stdlib.h>\nimport \"C\"\n",
+				"empty/go.mod":      "",
+				"empty/x/x.go":      "package x\n\nimport _ \"example.com/other\"\n",
+				"sub/testdata/x.go": "package x\n\nimport _ \"example.com/other\"\n",
+			},
+		},
+		{
+			name: "requirement",
+			files: map[string]string{
+				"go.mod":  coreGoMod + "\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0 // indirect\n)\n",
+				"core.go": "package core\n",
+			},
+			want: []string{"go.mod: the core module requires example.com/a", "go.mod: the core module requires example.com/b"},
+		},
+		{
+			name: "third-party imports",
+			files: map[string]string{
+				"go.mod":              "module example.com/core\n",
+				"core.go":             "package core\n\nimport _ \"example.com/a\"\n",
+				"sub/sub_test.go":     "package sub\n\nimport _ \"example.com/b\"\n",
+				"sub/sub_other.go":    "//go:build other\n\npackage sub\n\nimport _ \"example.com/c\"\n",
+				"sub/sub_ext_test.go": "package sub_test\n\nimport _ \"example.com/core/sub\"\n",
+				"nested/go.mod":       "module example.com/core/nested\n\nrequire example.com/d v1.0.0\n",
+				"nested/nested.go":    "package nested\n\nimport _ \"example.com/d\"\n",
+			},
+			want: []string{".: the core module imports example.com/a", "sub: the core module imports example.com/b", "sub: the core module imports example.com/c"},
+		},
+		{
+			name: "nested modules",
+			files: map[string]string{
+				"go.mod":  coreGoMod,
+				"core.go": "package core\n",
+				"integrations/beep/go.mod": "module example.com/core/integrations/beep\n\nrequire (\n\texample.com/core v0.0.0\n\texample.com/beep v1.0.0\n)\n\n" +
+					"replace example.com/core => ../../\n",
+				"integrations/oto/go.mod": "module example.com/core/oto\n\nrequire example.com/core v0.0.0\n",
+				"flac/go.mod":             "module example.com/core/flac\n\nrequire example.com/core v0.0.0\n\nreplace example.com/core v0.0.0 => ../core\n",
+			},
+			want: []string{
+				"flac/go.mod: example.com/core is not replaced with ..",
+				"integrations/oto/go.mod: example.com/core is not replaced with ../..",
+				"integrations/oto/go.mod: module example.com/core/oto, want example.com/core/integrations/oto",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				name = filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := checkModules(root)
+			if err != nil {
+				t.Fatalf("checkModules() error = %v", err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("checkModules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
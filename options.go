@@ -2,8 +2,15 @@ package sonic
 
 import (
 	"cmp"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"time"
 
 	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
 )
 
 type Option func(*Transformer) error
@@ -49,6 +56,9 @@ func WithSpeed(speed float32) Option {
 //
 // This value scales the pitch. 1.3 means 30% higher.
 // You can specify a value between 0.05 and 20. Values outside this range are clamped.
+// If the speed divided by the pitch is too extreme for the stream to apply at the sample rate,
+// e.g. speed 20 with pitch 0.05, the pitch is moved towards the speed so that the output keeps
+// its length.
 // The default value is 1.0.
 func WithPitch(pitch float32) Option {
 	return func(t *Transformer) error {
@@ -61,6 +71,7 @@ func WithPitch(pitch float32) Option {
 // WithRate sets the playback rate.
 //
 // This value scales the playback rate. 2.0 means 2X faster, and 2X pitch.
+// See WithNominalRate for how the rate is applied.
 // You can specify a value between 0.05 and 20. Values outside this range are clamped.
 // The default value is 1.0.
 func WithRate(rate float32) Option {
@@ -71,11 +82,280 @@ func WithRate(rate float32) Option {
 	}
 }
 
+// WithTempoPitch sets the speed, the pitch and the rate so that the output plays at tempo times
+// the original tempo, with the pitch scaled by pitch. See TempoPitch for how they are chosen.
+// It replaces the values set by WithSpeed, WithPitch and WithRate before it.
+// The default is a tempo and a pitch of 1.0.
+func WithTempoPitch(tempo, pitch float32) Option {
+	return func(t *Transformer) error {
+		s := TempoPitch(tempo, pitch)
+		t.speed, t.pitch, t.rate = s.Speed, s.Pitch, s.Rate
+		return nil
+	}
+}
+
+// WithStartupRamp ramps the speed from 1.0 to the speed set by WithSpeed over the first d of
+// input audio.
+//
+// Starting playback at a high speed can sound rough for the first few hundred milliseconds.
+// With a ramp, the speed increases (or decreases) gradually, in steps of 10 ms of input, which
+// improves the perceived quality at playback start. OutputSamplesForInput and
+// InputSamplesForOutput do not take the ramp into account.
+// You can specify a value between 0 and 10 seconds. Values outside this range are clamped.
+// The default is OFF.
+func WithStartupRamp(d time.Duration) Option {
+	return func(t *Transformer) error {
+		t.startupRamp = clamp(d, 0, maxStartupRamp)
+		return nil
+	}
+}
+
+// WithShortInput sets how input too short for libsonic is handled.
+//
+// libsonic needs about 31 ms of input (see ShortInputFrames) to detect a pitch period. Shorter
+// input between two Flush calls, such as a very short TTS fragment, produces fewer output samples
+// than expected, or none. With a policy other than ShortInputProcess, the transformer holds back
+// the input until it reaches ShortInputFrames, and if it is still shorter at Flush:
+// ShortInputPad pads it with silence and trims or pads the output to the expected length,
+// ShortInputPassthrough writes it without time stretching, i.e. unchanged except for channel
+// selection, channel gains and the post filters, and ShortInputError discards it and makes
+// Flush return ErrShortInput.
+// The default is ShortInputProcess.
+func WithShortInput(policy ShortInputPolicy) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(policy.Values(), policy) {
+			return fmt.Errorf("%w: short input policy %v is not supported", ErrInvalid, policy)
+		}
+		t.shortInput = policy
+		return nil
+	}
+}
+
+// WithSampleRatePolicy sets how a sample rate outside the range supported by libsonic,
+// [1000, 500000] Hz, is handled.
+//
+// With SampleRateError, NewTransformer fails with an error wrapping ErrInvalid that names the
+// nearest supported rate. With SampleRateResample, the input is resampled to the nearest
+// supported rate by linear interpolation as it is written, and the output has that rate, see
+// OutputSampleRate; Write then requires whole frames. With SampleRateClamp, the input is processed
+// as if it had the nearest supported rate, without converting it, so the output keeps the rate of
+// the input, but the pitch periods are searched in a range shifted by the ratio of the rates.
+// With both, the frame counts and durations of the Transformer, e.g. InputLatency, refer to the
+// supported rate, and warn, if not nil, is called by NewTransformer with the requested and the
+// supported rate.
+// The default is SampleRateError.
+func WithSampleRatePolicy(policy SampleRatePolicy, warn SampleRateWarningFunc) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(policy.Values(), policy) {
+			return fmt.Errorf("%w: sample rate policy %v is not supported", ErrInvalid, policy)
+		}
+		t.ratePolicy = policy
+		t.rateWarning = warn
+		return nil
+	}
+}
+
+// WithExpectedSampleRate sets the sample rate the caller expects the input to have, and how an
+// input of another rate is handled. It is meant for TransformFile, which takes the sample rate from
+// the header of the input file, when the caller assumes a rate, e.g. one configured for a pipeline.
+//
+// With SampleRateMismatchError, NewTransformer fails with an error wrapping ErrInvalid, instead of
+// producing output that plays at the wrong speed and pitch where the expected rate is assumed.
+// With SampleRateMismatchUseInput, the input is processed at its own rate, as if the option was
+// not given, and warn, if not nil, is called by NewTransformer with the expected rate and the rate
+// of the input, e.g. to reconfigure the consumer of the output.
+// The default is OFF (= any sample rate is accepted).
+func WithExpectedSampleRate(rate int, mismatch SampleRateMismatch, warn SampleRateWarningFunc) Option {
+	return func(t *Transformer) error {
+		if rate <= 0 {
+			return fmt.Errorf("%w: expected sample rate %d must be positive", ErrInvalid, rate)
+		}
+		if !slices.Contains(mismatch.Values(), mismatch) {
+			return fmt.Errorf("%w: sample rate mismatch %v is not supported", ErrInvalid, mismatch)
+		}
+		t.wantRate = rate
+		t.mismatch = mismatch
+		t.wantWarning = warn
+		return nil
+	}
+}
+
+// WithAlignedChunks feeds the stream in chunks of a fixed size, independent of the size of the
+// buffers passed to Write.
+//
+// The output of libsonic depends slightly on how its input is split, because pitch periods are
+// searched and overlap-added within what it has been given so far. With this option, the
+// transformer holds back the input until it has a complete chunk of 2048 frames, so that the
+// output is the same however the input is written, e.g. whether a live source delivers 10 ms or
+// 100 ms buffers. Measurements show no consistent difference in quality between chunk sizes;
+// the benefit is reproducibility, at the cost of up to one chunk of additional latency.
+// The default is OFF (= write the input as it arrives).
+func WithAlignedChunks() Option {
+	return func(t *Transformer) error {
+		t.aligned = true
+		return nil
+	}
+}
+
+// WithRecovery sets how the transformer recovers when its stream fails, e.g. because libsonic
+// failed to allocate memory.
+//
+// By default, Write and Flush return an error wrapping ErrSonicFailed, and the stream is left in
+// an unknown state. With RecoveryRetry or RecoveryDrop, the transformer destroys the stream and
+// creates a new one with the same settings, then writes the failed chunk of up to 2048 frames
+// again or drops it. The call finishes its work and returns an error wrapping ErrRecovered, so
+// that long-running services can log the glitch and keep going. ErrSonicFailed is still
+// returned if the new stream cannot be created or fails again.
+// The default is RecoveryNone.
+func WithRecovery(mode Recovery) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(mode.Values(), mode) {
+			return fmt.Errorf("%w: recovery mode %v is not supported", ErrInvalid, mode)
+		}
+		t.recovery = mode
+		return nil
+	}
+}
+
+// WithFadeIn fades in the first d of the output.
+//
+// The output fades in linearly from silence, so that clips cut out of a longer source do not
+// start with a click. The fade-in restarts after every Flush, so that each clip written between
+// two Flush calls fades in. See WithEdgeFades to fade both edges.
+// You can specify a value between 0 and 10 seconds. Values outside this range are clamped.
+// The default is OFF.
+func WithFadeIn(d time.Duration) Option {
+	return func(t *Transformer) error {
+		t.fadeIn = clamp(d, 0, maxFadeIn)
+		return nil
+	}
+}
+
+// WithEdgeFades fades in the first fadeIn and fades out the last fadeOut of the output.
+//
+// It is a shorthand for WithFadeIn(fadeIn) and WithFadeOut(fadeOut), giving clean clip
+// boundaries without a separate editing pass. A zero duration leaves that edge untouched.
+// The default is OFF.
+func WithEdgeFades(fadeIn, fadeOut time.Duration) Option {
+	return func(t *Transformer) error {
+		if err := WithFadeIn(fadeIn)(t); err != nil {
+			return err
+		}
+		return WithFadeOut(fadeOut)(t)
+	}
+}
+
+// WithFadeOut fades out the last d of the output at Flush.
+//
+// Sources that are truncated abruptly otherwise end with a click. To fade out the end, the
+// transformer holds back the last d of output until Flush, where it is faded out linearly to
+// silence and written. The output is therefore delayed by d. If Write is called again after
+// Flush, the new output fades out at the next Flush. See WithEdgeFades to fade both edges.
+// You can specify a value between 0 and 10 seconds. Values outside this range are clamped.
+// The default is OFF.
+func WithFadeOut(d time.Duration) Option {
+	return func(t *Transformer) error {
+		t.fadeOut = clamp(d, 0, maxFadeOut)
+		return nil
+	}
+}
+
+// WithFixedLatency delays the output by exactly d, so that e.g. a game audio engine can schedule
+// the transformed stream against a fixed delay instead of the variable buffering of the stream.
+//
+// The output starts with d of silence, and every Write then delivers exactly the output due for its
+// input at the current speed and rate, taken from the output the stream produced so far. d must
+// cover the buffering of the stream, e.g. about 60 ms for speech at speed 0.5 and 25 ms at speed
+// 1.5, plus any fade-out; if the stream lags behind, silence is delivered in place of the missing
+// output and the same amount of later output is dropped, which Stats reports as UnderrunFrames.
+// Flush delivers all remaining output, and the output after it starts with d of silence again.
+// You can specify a value between 0 and 10 seconds. Values outside this range are clamped.
+// The default is OFF.
+func WithFixedLatency(d time.Duration) Option {
+	return func(t *Transformer) error {
+		t.latency = clamp(d, 0, maxFixedLatency)
+		return nil
+	}
+}
+
+// WithNominalRate applies the playback rate by relabeling the output sample rate instead of
+// resampling.
+//
+// By default, WithRate resamples the audio, so that the output has the input sample rate but
+// plays faster (or slower) and at a higher (or lower) pitch. With this option, the samples are
+// not resampled. Instead, the output is meant to be played at OutputSampleRate, i.e. the input
+// sample rate times the rate, which has the same audible effect: a WAV file written with that
+// rate in its header plays at the expected speed and pitch. This avoids the resampling cost and
+// its interpolation artifacts, but the output is then no longer at the input sample rate, which
+// some players, devices and downstream processing cannot handle. Use WithOutputSampleRate if a
+// fixed sample rate is required.
+// This option has no effect without WithRate.
+// The default is OFF (= resample).
+func WithNominalRate() Option {
+	return func(t *Transformer) error {
+		t.nominalRate = true
+		return nil
+	}
+}
+
+// WithOutputSampleRate resamples the output to rate, e.g. to deliver a 44.1 kHz source to a
+// 48 kHz sink.
+//
+// The output is converted from the rate it would have otherwise, see OutputSampleRate, with a
+// Resampler after the output filters and before the fades, the fixed latency and the conversion
+// to the output format, which all use the new rate. The resampler holds back a few milliseconds
+// of output until Flush. Output processed as int16 is converted to float32 for resampling and
+// quantized again, see WithDither.
+// rate must be in the range supported by libsonic, [1000, 500000] Hz.
+// The default is OFF (= the output is not resampled).
+func WithOutputSampleRate(rate int) Option {
+	return func(t *Transformer) error {
+		if rate < cgosonic.MIN_SAMPLE_RATE || cgosonic.MAX_SAMPLE_RATE < rate {
+			return fmt.Errorf("%w: output sample rate %d is out of range [%d, %d]", ErrInvalid, rate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE)
+		}
+		t.outRate = rate
+		return nil
+	}
+}
+
+// WithEngine sets the time-stretching engine.
+//
+// EngineSonic is optimized for speech. Other engines can implement the Engine interface, and all
+// other options apply to them as well, as far as the engine supports them.
+// The default is EngineSonic.
+func WithEngine(engine Engine) Option {
+	return func(t *Transformer) error {
+		if engine == nil {
+			return fmt.Errorf("%w: engine is nil", ErrInvalid)
+		}
+		t.engine = engine
+		return nil
+	}
+}
+
+// WithStreamFactory sets the function that creates the streams doing the time stretching.
+//
+// This allows a fake Stream to be injected, e.g. from the sonictest package, so that code using
+// a Transformer, including its error paths, can be unit-tested without libsonic. In mid-side
+// mode, newStream is called twice to create two mono streams.
+// It is equivalent to WithEngine(newStream).
+// The default creates libsonic streams (see EngineSonic).
+func WithStreamFactory(newStream StreamFactory) Option {
+	return func(t *Transformer) error {
+		if newStream == nil {
+			return fmt.Errorf("%w: stream factory is nil", ErrInvalid)
+		}
+		t.engine = newStream
+		return nil
+	}
+}
+
 // WithQuality sets the quality.
 //
 // Setting the 'quality' flag disables speed-up heuristics. May increase quality.
 // The default value is OFF (= Enable speed-up heuristics).
 // Default OFF is virtually as good as ON (= Disable speed-up heuristics), but very much faster.
+// bench.QualityCosts measures how much slower ON is for given speeds and sample rates.
 func WithQuality() Option {
 	return func(t *Transformer) error {
 		val := 1
@@ -84,6 +364,317 @@ func WithQuality() Option {
 	}
 }
 
+// WithChordPitch enables the chord pitch mode of libsonic, meant to preserve the pitch ratios of
+// music rather than speech when the pitch is changed.
+//
+// The bundled libsonic deprecated chord pitch: sonicSetChordPitch ignores the setting, so this
+// option currently has no effect on the output. It is kept for compatibility with the libsonic
+// API. Use WithEngine(EngineMusic) to pitch-shift music without the speech-oriented artifacts.
+// The default is OFF.
+func WithChordPitch() Option {
+	return func(t *Transformer) error {
+		t.chordPitch = true
+		return nil
+	}
+}
+
+// WithConsonantEmphasis enables the consonant emphasis post filter.
+//
+// Slowed speech can smear consonants. This filter boosts high-frequency content only around
+// detected transients, which improves intelligibility at slow playback speeds (e.g. for
+// hearing-impaired listeners) while leaving vowels and steady sounds untouched.
+// The amount scales the emphasis. 0 means no emphasis, 1.0 doubles the high-frequency
+// component of transients.
+// You can specify a value between 0 and 2. Values outside this range are clamped.
+// The default is OFF.
+func WithConsonantEmphasis(amount float32) Option {
+	return func(t *Transformer) error {
+		val := clamp(amount, minConsonantEmphasis, maxConsonantEmphasis)
+		t.emphasis = &val
+		return nil
+	}
+}
+
+// WithChannelGains sets a gain factor for each channel.
+//
+// The gains are applied in addition to the volume, so that e.g. a quiet customer channel in a
+// stereo call recording can be boosted relative to the agent channel without a separate pass.
+// The number of gains must match the number of input channels (see WithChannels).
+// You can specify values between 0 and 100. Values outside this range are clamped.
+// The default is 1.0 for all channels.
+func WithChannelGains(gains []float32) Option {
+	return func(t *Transformer) error {
+		t.gains = make([]float32, len(gains))
+		for i, g := range gains {
+			t.gains[i] = clamp(g, 0, cgosonic.MAX_VOLUME)
+		}
+		return nil
+	}
+}
+
+// WithSelectChannels selects the input channels to process and output.
+//
+// Only the selected channels are time-stretched and written to the writer, in the given order;
+// the other channels are dropped. This avoids wasting CPU time on channels nobody listens to,
+// e.g. processing only channel 0 of a stereo call recording.
+// Channels are 0-based indexes into the input channels (see WithChannels).
+// The default is to process all channels.
+func WithSelectChannels(channels ...int) Option {
+	return func(t *Transformer) error {
+		t.channels = append([]int{}, channels...)
+		return nil
+	}
+}
+
+// WithOutputChannels remixes the output to the given number of channels, e.g. so that a mono
+// TTS stream can feed a stereo sink, or a stereo podcast be converted to mono.
+//
+// Mono is upmixed by duplicating it to every output channel, and any number of channels is
+// downmixed to mono by averaging them. Other conversions are not supported, and NewTransformer
+// fails with an error wrapping ErrInvalid for them. The channels selected with WithSelectChannels
+// are remixed after time-stretching, the output filters and the resampler, right before the
+// conversion to the output format, so that all of them process only the selected channels.
+// You can specify a value between 1 and 32. Values outside this range are clamped.
+// The default is the number of processed channels (= no remix).
+func WithOutputChannels(channels int) Option {
+	return func(t *Transformer) error {
+		t.outChannels = clamp(channels, cgosonic.MIN_CHANNELS, cgosonic.MAX_CHANNELS)
+		return nil
+	}
+}
+
+// WithMidSide enables mid-side processing for stereo audio.
+//
+// By default, all channels are time-stretched jointly, which can collapse the stereo image.
+// In mid-side mode, the mid (L+R) and side (L-R) signals are processed separately and
+// re-matrixed on output, preserving perceived width for music-with-speech content.
+// This option requires 2 channels (see WithChannels and WithSelectChannels).
+// The default is OFF.
+func WithMidSide() Option {
+	return func(t *Transformer) error {
+		t.midSideMode = true
+		return nil
+	}
+}
+
+// WithDebugDump enables the debug dump mode.
+//
+// In debug dump mode, the samples of every chunk are passed to fn twice: once before they are
+// written to the stream (DebugStageInput) and once after processing, just before they are
+// written to the writer (DebugStageOutput), together with the parameters in effect.
+// This allows artifact reports to be narrowed down to a specific chunk and parameter state.
+// Use DebugDumpDir to write the chunks to numbered files.
+// If this option is not given, the debug dump mode can also be enabled by setting the
+// environment variable SONIC_DEBUG_DUMP to a directory.
+// The default is OFF.
+func WithDebugDump(fn DebugDumpFunc) Option {
+	return func(t *Transformer) error {
+		t.debugDump = fn
+		return nil
+	}
+}
+
+// WithOutputFunc delivers the output to fn instead of the writer.
+//
+// fn receives the little-endian samples of each processed block as a slice borrowed from the
+// transformer's internal buffer, which is only valid until fn returns. This allows the samples to
+// be handed off, e.g. copied into a ring buffer or an encoder input queue, without an intermediate
+// writer. An error returned by fn is returned from Write or Flush, wrapped in ErrWrite.
+// The writer passed to NewTransformer may be nil when this option is given.
+// The default is OFF.
+func WithOutputFunc(fn OutputFunc) Option {
+	return func(t *Transformer) error {
+		t.output = fn
+		return nil
+	}
+}
+
+// WithInputTee writes the untransformed input to w as it is consumed, e.g. to archive the
+// originals while the transformed audio is delivered.
+//
+// w receives exactly the bytes of each Write that the transformer consumed, so the recording
+// matches what was transformed, also when Write fails. An error returned by w is returned from
+// Write, wrapped in ErrWrite, after the input has been transformed. If w has a Flush method, it
+// is called by Flush.
+// The default is OFF.
+func WithInputTee(w io.Writer) Option {
+	return func(t *Transformer) error {
+		t.inputTee = w
+		return nil
+	}
+}
+
+// WithInputHash feeds the input bytes consumed by Write to h, e.g. crc32.NewIEEE() or
+// sha256.New(). The checksum is reported by Stats as InputSum.
+// The default is OFF.
+func WithInputHash(h hash.Hash) Option {
+	return func(t *Transformer) error {
+		t.inputHash = h
+		return nil
+	}
+}
+
+// WithOutputHash feeds the output bytes accepted by the writer or the output function to h.
+// The checksum is reported by Stats as OutputSum.
+// The default is OFF.
+func WithOutputHash(h hash.Hash) Option {
+	return func(t *Transformer) error {
+		t.outputHash = h
+		return nil
+	}
+}
+
+// WithSpectrogram adds the input of the stream, after the channel selection, to s, e.g. to
+// visualize the pitch periods of the input. s must have been created with the sample rate and the
+// number of channels of the stream; otherwise, NewTransformer returns an error wrapping ErrInvalid.
+// The analysis allocates, so a transformer with a spectrogram does not run allocation-free.
+// The default is OFF.
+func WithSpectrogram(s *Spectrogram) Option {
+	return func(t *Transformer) error {
+		t.spectrogram = s
+		return nil
+	}
+}
+
+// WithTracer starts a span with tracer for every Write and Flush, named after the pipeline stage
+// name, e.g. "tts.speedup.Write". The spans carry the bytes and frames that passed through and the
+// parameters of the transformer; see the Attr constants. An empty name means "sonic".
+// The default is OFF.
+func WithTracer(name string, tracer Tracer) Option {
+	return func(t *Transformer) error {
+		if name == "" {
+			name = "sonic"
+		}
+		t.tracer = tracer
+		t.stage = name
+		return nil
+	}
+}
+
+// WithClock sets the clock the transformer tells the time with, e.g. a fake clock in tests of
+// WallClockSpent. A nil clock means SystemClock.
+// The default is SystemClock.
+func WithClock(clock Clock) Option {
+	return func(t *Transformer) error {
+		if clock == nil {
+			clock = SystemClock
+		}
+		t.clock = clock
+		return nil
+	}
+}
+
+// WithFloatClipping sets how float32 output beyond full scale is handled.
+//
+// libsonic itself keeps its output within [-1, 1], but the channel gains and the consonant
+// emphasis can push samples beyond full scale. Downstream encoders differ in what they expect:
+// FloatClippingClamp clamps each sample, FloatClippingNone leaves samples as they are, and
+// FloatClippingScale scales down every output block that exceeds full scale so that its peak is 1.
+// Under every policy, NaN samples are replaced by 0 and infinite samples by ±1.
+// This option has no effect on PCM and U8 input, which always saturate. With WithOutputFormat, it
+// applies before float samples are converted to PCM.
+// The default is FloatClippingClamp.
+func WithFloatClipping(clipping FloatClipping) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(clipping.Values(), clipping) {
+			return fmt.Errorf("%w: float clipping %v is not supported", ErrInvalid, clipping)
+		}
+		t.clipping = clipping
+		return nil
+	}
+}
+
+// WithClippingReport counts the output samples that clip, so that batch QA can flag the files
+// that need a lower volume or gain.
+//
+// A sample is counted as clipped when it is at or beyond full scale right before the float
+// clipping policy and the conversion to the output format: libsonic saturates its int16 samples
+// when the volume is raised, and the channel gains, the consonant emphasis and the output
+// resampler can push samples beyond full scale. Input samples at full scale that pass through
+// unchanged are counted too, but samples saturated by libsonic and scaled down by a channel gain
+// below 1 are not. The counts are reported by Stats. fn, if not nil, is also called by
+// Flush whenever samples clipped since the previous Flush, with the counts of Stats.
+// The default is OFF.
+func WithClippingReport(fn ClippingReportFunc) Option {
+	return func(t *Transformer) error {
+		t.clipReport = true
+		t.clipFunc = fn
+		return nil
+	}
+}
+
+// WithOutputFormat sets the format of the samples written to the writer.
+//
+// The samples are processed in the input format given to NewTransformer and converted to format
+// right before they are written, using the pcm.Scaling32767 convention. This allows e.g. an ASR
+// pipeline that requires int16 to consume a float source in one step. Float samples beyond full
+// scale saturate when converted to an integer format. Unsigned 8-bit samples are converted from
+// int16 by dropping the low byte, as libsonic does, and int16 samples become the high bits of PCM24
+// and PCM32 samples. PCM24, PCM32 and float64 input is processed as float32, which keeps the upper
+// 24 bits of PCM32 samples and rounds float64 samples. See WithDither for dithering the conversion
+// of float samples.
+// The default is the input format.
+func WithOutputFormat(format AudioFormat) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(format.Values(), format) {
+			return fmt.Errorf("%w: output format %v is not supported", ErrInvalid, format)
+		}
+		t.outFormat = format
+		return nil
+	}
+}
+
+// WithDither sets the dither applied when float samples are quantized to an integer output
+// format, i.e. when input processed as float32 (float, PCM24, PCM32 and float64 input) is written
+// as PCM, PCM24 or U8 with WithOutputFormat. Dither trades the distortion of rounding quiet
+// signals for a constant noise floor of about one step of the output format. The noise is
+// generated deterministically, so that the same input gives the same output. PCM32 output is not
+// dithered, as its steps are far below the resolution of float32 samples, and neither is the
+// output of PCM and U8 input, which is processed as int16, unless it is resampled with
+// WithOutputSampleRate.
+// The default is DitherNone.
+func WithDither(d Dither) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(d.Values(), d) {
+			return fmt.Errorf("%w: dither %v is not supported", ErrInvalid, d)
+		}
+		t.dither = d
+		return nil
+	}
+}
+
+// WithRounding sets how float samples are rounded when they are converted to int16, i.e. when
+// input processed as float32 (float, PCM24, PCM32 and float64 input), or output resampled with
+// WithOutputSampleRate, is written as PCM or U8. Downstream fixed-point pipelines, e.g. of ASR
+// vendors, may require a specific quantization to match their output bit for bit. The rounding
+// applies after the dither, if any. libsonic itself truncates when it converts float samples to
+// int16 internally, which this option does not change.
+// The default is pcm.RoundHalfAwayFromZero.
+func WithRounding(rounding pcm.Rounding) Option {
+	return func(t *Transformer) error {
+		if !slices.Contains(rounding.Values(), rounding) {
+			return fmt.Errorf("%w: rounding %v is not supported", ErrInvalid, rounding)
+		}
+		t.rounding = rounding
+		return nil
+	}
+}
+
+// WithByteOrder sets the byte order of the input and output samples, e.g. binary.BigEndian for
+// AIFF files or network protocols that carry big-endian PCM. Any binary.ByteOrder is accepted,
+// including binary.NativeEndian; a nil order means little-endian.
+//
+// The samples are converted to little-endian as they are written, and the output back to order
+// right before it is delivered, so the input tee and hashes see the bytes as given to Write and
+// the output hash the bytes as delivered. Unsigned 8-bit samples have no byte order.
+// The default is binary.LittleEndian.
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(t *Transformer) error {
+		t.bigEndian = order != nil && isBigEndian(order)
+		return nil
+	}
+}
+
 func clamp[T cmp.Ordered](value, min, max T) T {
 	if value < min {
 		return min
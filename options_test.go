@@ -1,9 +1,17 @@
 package sonic
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
 )
 
 // Note: These tests assume that the Transformer struct is defined elsewhere in the 'sonic' package
@@ -48,6 +56,33 @@ func TestWithChannels(t *testing.T) {
 	}
 }
 
+func TestWithOutputChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int
+		expected int
+	}{
+		{"within range (2)", 2, 2},
+		{"within range (1)", 1, 1},
+		{"below min", cgosonic.MIN_CHANNELS - 1, cgosonic.MIN_CHANNELS},
+		{"above max", cgosonic.MAX_CHANNELS + 1, cgosonic.MAX_CHANNELS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithOutputChannels(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithOutputChannels(%d) returned an error: %v", tt.input, err)
+			}
+			if tr.outChannels != tt.expected {
+				t.Errorf("WithOutputChannels(%d) set outChannels to %d; want %d", tt.input, tr.outChannels, tt.expected)
+			}
+		})
+	}
+}
+
 func TestWithVolume(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -172,6 +207,21 @@ func TestWithRate(t *testing.T) {
 	}
 }
 
+func TestWithTempoPitch(t *testing.T) {
+	tr := &Transformer{}
+	for _, opt := range []Option{WithSpeed(3), WithTempoPitch(1.5, 0.8)} {
+		if err := opt(tr); err != nil {
+			t.Fatalf("option returned an error: %v", err)
+		}
+	}
+	if tr.speed == nil || tr.pitch == nil || tr.rate == nil {
+		t.Fatalf("WithTempoPitch(1.5, 0.8) left speed, pitch or rate unset")
+	}
+	if *tr.speed != 1.5 || *tr.pitch != 0.8 || *tr.rate != 1 {
+		t.Errorf("WithTempoPitch(1.5, 0.8) set speed %v, pitch %v, rate %v; want 1.5, 0.8, 1", *tr.speed, *tr.pitch, *tr.rate)
+	}
+}
+
 func TestWithQuality(t *testing.T) {
 	tr := &Transformer{}
 	opt := WithQuality()
@@ -186,3 +236,818 @@ func TestWithQuality(t *testing.T) {
 		t.Errorf("WithQuality() set quality to %d; want 1", *tr.quality)
 	}
 }
+
+// chordPitchStream records the chord pitch mode set on the stream it wraps.
+type chordPitchStream struct {
+	Stream
+	chordPitch int
+}
+
+func (s *chordPitchStream) SetChordPitch(useChordPitch int) {
+	s.chordPitch = useChordPitch
+}
+
+func TestWithChordPitch(t *testing.T) {
+	tr := &Transformer{}
+	if err := WithChordPitch()(tr); err != nil {
+		t.Fatalf("WithChordPitch() returned an error: %v", err)
+	}
+	if !tr.chordPitch {
+		t.Error("WithChordPitch() did not set chordPitch")
+	}
+
+	var stream *chordPitchStream
+	factory := func(sampleRate, numChannels int) (Stream, error) {
+		s, err := EngineSonic.NewStream(sampleRate, numChannels)
+		if err != nil {
+			return nil, err
+		}
+		stream = &chordPitchStream{Stream: s}
+		return stream, nil
+	}
+	tr, err := NewTransformer(io.Discard, 8000, AudioFormatPCM, WithStreamFactory(factory), WithChordPitch())
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if stream.chordPitch != 1 {
+		t.Errorf("chord pitch of the stream = %d, want 1", stream.chordPitch)
+	}
+
+	// Chord pitch is a no-op in the bundled libsonic.
+	input := make([]byte, 8000)
+	for i := range input {
+		input[i] = byte(i * 7)
+	}
+	var outputs [2]bytes.Buffer
+	for i, opts := range [][]Option{{WithPitch(1.5)}, {WithPitch(1.5), WithChordPitch()}} {
+		tr, err := NewTransformer(&outputs[i], 8000, AudioFormatPCM, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		if _, err := tr.Write(input); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+	if !bytes.Equal(outputs[0].Bytes(), outputs[1].Bytes()) {
+		t.Error("WithChordPitch() changed the output")
+	}
+}
+
+func TestWithConsonantEmphasis(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    float32
+		expected float32
+	}{
+		{"within range (0.5)", 0.5, 0.5},
+		{"below min", minConsonantEmphasis - 0.1, minConsonantEmphasis},
+		{"at min", minConsonantEmphasis, minConsonantEmphasis},
+		{"above max", maxConsonantEmphasis + 0.1, maxConsonantEmphasis},
+		{"at max", maxConsonantEmphasis, maxConsonantEmphasis},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithConsonantEmphasis(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithConsonantEmphasis(%f) returned an error: %v", tt.input, err)
+			}
+			if tr.emphasis == nil {
+				t.Fatalf("WithConsonantEmphasis(%f) did not set emphasis, field is nil", tt.input)
+			}
+			if *tr.emphasis != tt.expected {
+				t.Errorf("WithConsonantEmphasis(%f) set emphasis to %f; want %f", tt.input, *tr.emphasis, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithMidSide(t *testing.T) {
+	tr := &Transformer{}
+	opt := WithMidSide()
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithMidSide() returned an error: %v", err)
+	}
+	if !tr.midSideMode {
+		t.Error("WithMidSide() did not enable mid-side mode")
+	}
+}
+
+func TestWithChannelGains(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []float32
+		expected []float32
+	}{
+		{"within range", []float32{1.0, 2.5}, []float32{1.0, 2.5}},
+		{"below min", []float32{-1.0}, []float32{0}},
+		{"above max", []float32{cgosonic.MAX_VOLUME + 1}, []float32{cgosonic.MAX_VOLUME}},
+		{"empty", []float32{}, []float32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithChannelGains(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithChannelGains(%v) returned an error: %v", tt.input, err)
+			}
+			if !slices.Equal(tr.gains, tt.expected) {
+				t.Errorf("WithChannelGains(%v) set gains to %v; want %v", tt.input, tr.gains, tt.expected)
+			}
+		})
+	}
+
+	t.Run("does not alias input", func(t *testing.T) {
+		gains := []float32{1.0, 2.0}
+		tr := &Transformer{}
+		WithChannelGains(gains)(tr)
+		gains[0] = 50
+		if tr.gains[0] != 1.0 {
+			t.Errorf("gains[0] = %f after modifying the input slice; want 1.0", tr.gains[0])
+		}
+	})
+}
+
+func TestWithSelectChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []int
+		expected []int
+	}{
+		{"single channel", []int{0}, []int{0}},
+		{"reordered channels", []int{2, 0}, []int{2, 0}},
+		{"no channels", nil, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithSelectChannels(tt.input...)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithSelectChannels(%v) returned an error: %v", tt.input, err)
+			}
+			if tr.channels == nil || !slices.Equal(tr.channels, tt.expected) {
+				t.Errorf("WithSelectChannels(%v) set channels to %v; want %v", tt.input, tr.channels, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithDebugDump(t *testing.T) {
+	called := false
+	fn := func(DebugChunk) error {
+		called = true
+		return nil
+	}
+
+	tr := &Transformer{}
+	opt := WithDebugDump(fn)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithDebugDump() returned an error: %v", err)
+	}
+	if tr.debugDump == nil {
+		t.Fatal("WithDebugDump() did not set debugDump, field is nil")
+	}
+	tr.debugDump(DebugChunk{})
+	if !called {
+		t.Error("WithDebugDump() set a different function")
+	}
+}
+
+func TestWithOutputFunc(t *testing.T) {
+	called := false
+	fn := func([]byte) error {
+		called = true
+		return nil
+	}
+
+	tr := &Transformer{}
+	opt := WithOutputFunc(fn)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithOutputFunc() returned an error: %v", err)
+	}
+	if tr.output == nil {
+		t.Fatal("WithOutputFunc() did not set output, field is nil")
+	}
+	tr.output(nil)
+	if !called {
+		t.Error("WithOutputFunc() set a different function")
+	}
+}
+
+func TestWithFloatClipping(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    FloatClipping
+		expected FloatClipping
+		wantErr  bool
+	}{
+		{"Clamp", FloatClippingClamp, FloatClippingClamp, false},
+		{"None", FloatClippingNone, FloatClippingNone, false},
+		{"Scale", FloatClippingScale, FloatClippingScale, false},
+		{"Unsupported", FloatClipping(42), FloatClippingClamp, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithFloatClipping(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithFloatClipping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.clipping != tt.expected {
+				t.Errorf("WithFloatClipping() clipping = %v, want %v", tr.clipping, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithClippingReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ClippingReportFunc
+		wantFunc bool
+	}{
+		{"Function", func(clipped, firstFrame int64) {}, true},
+		{"Nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithClippingReport(tt.input)
+			if err := opt(tr); err != nil {
+				t.Fatalf("WithClippingReport() error = %v", err)
+			}
+			if !tr.clipReport {
+				t.Errorf("WithClippingReport() clipReport = false, want true")
+			}
+			if (tr.clipFunc != nil) != tt.wantFunc {
+				t.Errorf("WithClippingReport() clipFunc set = %v, want %v", tr.clipFunc != nil, tt.wantFunc)
+			}
+		})
+	}
+}
+
+func TestWithDither(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Dither
+		expected Dither
+		wantErr  bool
+	}{
+		{"None", DitherNone, DitherNone, false},
+		{"TPDF", DitherTPDF, DitherTPDF, false},
+		{"Unsupported", Dither(42), DitherNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithDither(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithDither() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.dither != tt.expected {
+				t.Errorf("WithDither() dither = %v, want %v", tr.dither, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    pcm.Rounding
+		expected pcm.Rounding
+		wantErr  bool
+	}{
+		{"HalfAwayFromZero", pcm.RoundHalfAwayFromZero, pcm.RoundHalfAwayFromZero, false},
+		{"HalfEven", pcm.RoundHalfEven, pcm.RoundHalfEven, false},
+		{"TowardZero", pcm.RoundTowardZero, pcm.RoundTowardZero, false},
+		{"Unsupported", pcm.Rounding(42), pcm.RoundHalfAwayFromZero, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithRounding(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithRounding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.rounding != tt.expected {
+				t.Errorf("WithRounding() rounding = %v, want %v", tr.rounding, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithOutputFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    AudioFormat
+		expected AudioFormat
+		wantErr  bool
+	}{
+		{"PCM", AudioFormatPCM, AudioFormatPCM, false},
+		{"IEEEFloat", AudioFormatIEEEFloat, AudioFormatIEEEFloat, false},
+		{"U8", AudioFormatU8, AudioFormatU8, false},
+		{"PCM24", AudioFormatPCM24, AudioFormatPCM24, false},
+		{"PCM32", AudioFormatPCM32, AudioFormatPCM32, false},
+		{"IEEEFloat64", AudioFormatIEEEFloat64, AudioFormatIEEEFloat64, false},
+		{"Unsupported", AudioFormat(2), AudioFormat(0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithOutputFormat(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithOutputFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.outFormat != tt.expected {
+				t.Errorf("WithOutputFormat() outFormat = %v, want %v", tr.outFormat, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithNominalRate(t *testing.T) {
+	tr := &Transformer{}
+	opt := WithNominalRate()
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithNominalRate() returned an error: %v", err)
+	}
+	if !tr.nominalRate {
+		t.Error("WithNominalRate() did not enable nominal rate")
+	}
+}
+
+func TestWithOutputSampleRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int
+		expected int
+		wantErr  bool
+	}{
+		{"48000", 48000, 48000, false},
+		{"min", cgosonic.MIN_SAMPLE_RATE, cgosonic.MIN_SAMPLE_RATE, false},
+		{"max", cgosonic.MAX_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE, false},
+		{"below min", cgosonic.MIN_SAMPLE_RATE - 1, 0, true},
+		{"above max", cgosonic.MAX_SAMPLE_RATE + 1, 0, true},
+		{"negative", -44100, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithOutputSampleRate(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithOutputSampleRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.outRate != tt.expected {
+				t.Errorf("WithOutputSampleRate() outRate = %d, want %d", tr.outRate, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithStreamFactory(t *testing.T) {
+	tr := &Transformer{}
+	if err := WithStreamFactory(nil)(tr); err == nil {
+		t.Error("WithStreamFactory(nil) error = nil, want an error")
+	}
+	var called bool
+	factory := func(sampleRate, numChannels int) (Stream, error) {
+		called = true
+		return nil, nil
+	}
+	if err := WithStreamFactory(factory)(tr); err != nil {
+		t.Fatalf("WithStreamFactory() returned an error: %v", err)
+	}
+	if tr.engine == nil {
+		t.Fatal("WithStreamFactory() did not set engine, field is nil")
+	}
+	tr.engine.NewStream(8000, 1)
+	if !called {
+		t.Error("WithStreamFactory() set a different engine")
+	}
+}
+
+func TestWithEngine(t *testing.T) {
+	tr := &Transformer{}
+	if err := WithEngine(nil)(tr); err == nil {
+		t.Error("WithEngine(nil) error = nil, want an error")
+	}
+	if err := WithEngine(EngineSonic)(tr); err != nil {
+		t.Fatalf("WithEngine() returned an error: %v", err)
+	}
+	if tr.engine != EngineSonic {
+		t.Errorf("WithEngine() set engine to %v, want %v", tr.engine, EngineSonic)
+	}
+}
+
+func TestWithStartupRamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    time.Duration
+		expected time.Duration
+	}{
+		{"within range (1s)", time.Second, time.Second},
+		{"below min", -time.Second, 0},
+		{"above max", maxStartupRamp + time.Second, maxStartupRamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithStartupRamp(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithStartupRamp(%v) returned an error: %v", tt.input, err)
+			}
+			if tr.startupRamp != tt.expected {
+				t.Errorf("WithStartupRamp(%v) set startupRamp to %v; want %v", tt.input, tr.startupRamp, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithFadeOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    time.Duration
+		expected time.Duration
+	}{
+		{"within range (1s)", time.Second, time.Second},
+		{"below min", -time.Second, 0},
+		{"above max", maxFadeOut + time.Second, maxFadeOut},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithFadeOut(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithFadeOut(%v) returned an error: %v", tt.input, err)
+			}
+			if tr.fadeOut != tt.expected {
+				t.Errorf("WithFadeOut(%v) set fadeOut to %v; want %v", tt.input, tr.fadeOut, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithFadeIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    time.Duration
+		expected time.Duration
+	}{
+		{"within range (1s)", time.Second, time.Second},
+		{"below min", -time.Second, 0},
+		{"above max", maxFadeIn + time.Second, maxFadeIn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithFadeIn(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithFadeIn(%v) returned an error: %v", tt.input, err)
+			}
+			if tr.fadeIn != tt.expected {
+				t.Errorf("WithFadeIn(%v) set fadeIn to %v; want %v", tt.input, tr.fadeIn, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithEdgeFades(t *testing.T) {
+	tr := &Transformer{}
+	opt := WithEdgeFades(time.Second, maxFadeOut+time.Second)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithEdgeFades() returned an error: %v", err)
+	}
+	if tr.fadeIn != time.Second || tr.fadeOut != maxFadeOut {
+		t.Errorf("WithEdgeFades() set fadeIn, fadeOut to %v, %v; want %v, %v", tr.fadeIn, tr.fadeOut, time.Second, maxFadeOut)
+	}
+}
+
+func TestWithShortInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ShortInputPolicy
+		expected ShortInputPolicy
+		wantErr  bool
+	}{
+		{"Process", ShortInputProcess, ShortInputProcess, false},
+		{"Pad", ShortInputPad, ShortInputPad, false},
+		{"Passthrough", ShortInputPassthrough, ShortInputPassthrough, false},
+		{"Error", ShortInputError, ShortInputError, false},
+		{"Unsupported", ShortInputPolicy(42), ShortInputProcess, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithShortInput(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithShortInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.shortInput != tt.expected {
+				t.Errorf("WithShortInput() shortInput = %v, want %v", tr.shortInput, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithSampleRatePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    SampleRatePolicy
+		expected SampleRatePolicy
+		wantErr  bool
+	}{
+		{"Error", SampleRateError, SampleRateError, false},
+		{"Resample", SampleRateResample, SampleRateResample, false},
+		{"Clamp", SampleRateClamp, SampleRateClamp, false},
+		{"Unsupported", SampleRatePolicy(42), SampleRateError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			called := false
+			opt := WithSampleRatePolicy(tt.input, func(int, int) { called = true })
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithSampleRatePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.ratePolicy != tt.expected {
+				t.Errorf("WithSampleRatePolicy() ratePolicy = %v, want %v", tr.ratePolicy, tt.expected)
+			}
+			if (tr.rateWarning != nil) == tt.wantErr {
+				t.Errorf("WithSampleRatePolicy() rateWarning set = %v, want %v", tr.rateWarning != nil, !tt.wantErr)
+			}
+			if called {
+				t.Errorf("WithSampleRatePolicy() called the warning function")
+			}
+		})
+	}
+}
+
+func TestWithExpectedSampleRate(t *testing.T) {
+	tr := &Transformer{}
+	if err := WithExpectedSampleRate(0, SampleRateMismatchError, nil)(tr); !errors.Is(err, ErrInvalid) {
+		t.Errorf("WithExpectedSampleRate(0) error = %v, want %v", err, ErrInvalid)
+	}
+	if err := WithExpectedSampleRate(16000, SampleRateMismatch(42), nil)(tr); !errors.Is(err, ErrInvalid) {
+		t.Errorf("WithExpectedSampleRate() of an unsupported mismatch error = %v, want %v", err, ErrInvalid)
+	}
+	if err := WithExpectedSampleRate(16000, SampleRateMismatchUseInput, nil)(tr); err != nil || tr.wantRate != 16000 || tr.mismatch != SampleRateMismatchUseInput {
+		t.Errorf("WithExpectedSampleRate(16000) = %v, wantRate %d, mismatch %v, want nil, 16000, %v", err, tr.wantRate, tr.mismatch, SampleRateMismatchUseInput)
+	}
+
+	if _, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithExpectedSampleRate(48000, SampleRateMismatchError, nil)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewTransformer() of another rate error = %v, want %v", err, ErrInvalid)
+	}
+	var warned []int
+	warn := func(expected, input int) { warned = append(warned, expected, input) }
+	tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithExpectedSampleRate(48000, SampleRateMismatchUseInput, warn))
+	if err != nil {
+		t.Fatalf("NewTransformer() of another rate with SampleRateMismatchUseInput error = %v", err)
+	}
+	if !slices.Equal(warned, []int{48000, 44100}) || tr.OutputSampleRate() != 44100 {
+		t.Errorf("warned = %v, OutputSampleRate() = %d, want [48000 44100], 44100", warned, tr.OutputSampleRate())
+	}
+	tr.Close()
+	warned = nil
+	tr, err = NewTransformer(io.Discard, 48000, AudioFormatPCM, WithExpectedSampleRate(48000, SampleRateMismatchError, warn))
+	if err != nil {
+		t.Fatalf("NewTransformer() of the expected rate error = %v", err)
+	}
+	if warned != nil {
+		t.Errorf("NewTransformer() of the expected rate called the warning function with %v", warned)
+	}
+	tr.Close()
+}
+
+func TestWithAlignedChunks(t *testing.T) {
+	tr := &Transformer{}
+	opt := WithAlignedChunks()
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithAlignedChunks() returned an error: %v", err)
+	}
+	if !tr.aligned {
+		t.Error("WithAlignedChunks() did not enable aligned chunks")
+	}
+}
+
+func TestWithRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Recovery
+		expected Recovery
+		wantErr  bool
+	}{
+		{"None", RecoveryNone, RecoveryNone, false},
+		{"Retry", RecoveryRetry, RecoveryRetry, false},
+		{"Drop", RecoveryDrop, RecoveryDrop, false},
+		{"Unsupported", Recovery(42), RecoveryNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithRecovery(tt.input)
+			err := opt(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithRecovery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tr.recovery != tt.expected {
+				t.Errorf("WithRecovery() recovery = %v, want %v", tr.recovery, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithInputTee(t *testing.T) {
+	tr := &Transformer{}
+	var tee bytes.Buffer
+	opt := WithInputTee(&tee)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithInputTee() returned an error: %v", err)
+	}
+	if tr.inputTee != &tee {
+		t.Error("WithInputTee() did not set the input tee")
+	}
+}
+
+func TestWithInputHash(t *testing.T) {
+	tr := &Transformer{}
+	h := crc32.NewIEEE()
+	opt := WithInputHash(h)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithInputHash() returned an error: %v", err)
+	}
+	if tr.inputHash != h {
+		t.Error("WithInputHash() did not set the input hash")
+	}
+}
+
+func TestWithOutputHash(t *testing.T) {
+	tr := &Transformer{}
+	h := crc32.NewIEEE()
+	opt := WithOutputHash(h)
+	err := opt(tr)
+	if err != nil {
+		t.Fatalf("WithOutputHash() returned an error: %v", err)
+	}
+	if tr.outputHash != h {
+		t.Error("WithOutputHash() did not set the output hash")
+	}
+}
+
+func TestWithSpectrogram(t *testing.T) {
+	tr := &Transformer{}
+	s, err := NewSpectrogram(8000, 1)
+	if err != nil {
+		t.Fatalf("NewSpectrogram() error = %v", err)
+	}
+	opt := WithSpectrogram(s)
+	err = opt(tr)
+	if err != nil {
+		t.Fatalf("WithSpectrogram() returned an error: %v", err)
+	}
+	if tr.spectrogram != s {
+		t.Error("WithSpectrogram() did not set the spectrogram")
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	tests := []struct {
+		name      string
+		stage     string
+		wantStage string
+	}{
+		{"named", "tts.speedup", "tts.speedup"},
+		{"empty name", "", "sonic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			tracer := &recordingTracer{}
+			opt := WithTracer(tt.stage, tracer)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithTracer() returned an error: %v", err)
+			}
+			if tr.tracer != tracer || tr.stage != tt.wantStage {
+				t.Errorf("WithTracer() set tracer = %v, stage = %q, want %v, %q", tr.tracer, tr.stage, tracer, tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestWithByteOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order binary.ByteOrder
+		want  bool
+	}{
+		{"little-endian", binary.LittleEndian, false},
+		{"big-endian", binary.BigEndian, true},
+		{"native", binary.NativeEndian, isBigEndian(binary.NativeEndian)},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{bigEndian: !tt.want}
+			opt := WithByteOrder(tt.order)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithByteOrder() returned an error: %v", err)
+			}
+			if tr.bigEndian != tt.want {
+				t.Errorf("WithByteOrder() set bigEndian = %v, want %v", tr.bigEndian, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	clock := &systemClock{}
+	tests := []struct {
+		name  string
+		clock Clock
+		want  Clock
+	}{
+		{"clock", clock, clock},
+		{"nil", nil, SystemClock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithClock(tt.clock)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithClock() returned an error: %v", err)
+			}
+			if tr.clock != tt.want {
+				t.Errorf("WithClock() set clock = %v, want %v", tr.clock, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFixedLatency(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    time.Duration
+		expected time.Duration
+	}{
+		{"within range (100ms)", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"below min", -time.Second, 0},
+		{"above max", maxFixedLatency + time.Second, maxFixedLatency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transformer{}
+			opt := WithFixedLatency(tt.input)
+			err := opt(tr)
+			if err != nil {
+				t.Fatalf("WithFixedLatency(%v) returned an error: %v", tt.input, err)
+			}
+			if tr.latency != tt.expected {
+				t.Errorf("WithFixedLatency(%v) set latency to %v; want %v", tt.input, tr.latency, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Scaling represents a convention for converting between int16 and float32 samples. For 24-bit
+// and 32-bit samples, the conventions divide by the matching powers of two, e.g. by 8388608 and
+// 8388607 for 24-bit samples.
+//
+// Ecosystems disagree on how full scale maps between the two formats. Converting with one
+// convention and back with the other changes the level by about 0.003 dB, and can turn
+// full-scale samples into clipped ones, so pipelines should pick one convention and use it for
+// both directions.
+type Scaling int
+
+// Constants for scaling conventions
+const (
+	// Scaling32768 divides by 32768, so that -32768 maps to exactly -1 and 32767 to just below 1.
+	// Float 1.0 saturates to 32767. It is used by e.g. FFmpeg and WebAudio.
+	Scaling32768 Scaling = iota
+
+	// Scaling32767 divides by 32767, so that ±32767 map to exactly ±1 and -32768 to just below -1.
+	// It is used by libsonic, and therefore by sonic.Transformer.
+	Scaling32767
+)
+
+// String returns the string representation of the Scaling.
+func (s Scaling) String() string {
+	m := map[Scaling]string{
+		Scaling32768: "Scaling32768",
+		Scaling32767: "Scaling32767",
+	}
+	if str, ok := m[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Scaling(%d)", s)
+}
+
+// Rounding represents how float samples are rounded when they are converted to int16 samples.
+//
+// Fixed-point pipelines differ in how they quantize, so matching one of them bit for bit
+// requires the same rounding. The difference is at most one least significant bit per sample.
+type Rounding int
+
+// Constants for rounding modes
+const (
+	RoundHalfAwayFromZero Rounding = iota // Round to nearest, halves away from zero, as math.Round
+	RoundHalfEven                         // Round to nearest, halves to even, as math.RoundToEven
+	RoundTowardZero                       // Truncate the fraction, as a conversion in C does
+)
+
+// String returns the string representation of the Rounding.
+func (r Rounding) String() string {
+	m := map[Rounding]string{
+		RoundHalfAwayFromZero: "RoundHalfAwayFromZero",
+		RoundHalfEven:         "RoundHalfEven",
+		RoundTowardZero:       "RoundTowardZero",
+	}
+	if str, ok := m[r]; ok {
+		return str
+	}
+	return fmt.Sprintf("Rounding(%d)", r)
+}
+
+// Values returns the all possible values of Rounding.
+func (Rounding) Values() []Rounding {
+	return []Rounding{
+		RoundHalfAwayFromZero,
+		RoundHalfEven,
+		RoundTowardZero,
+	}
+}
+
+// round returns v rounded to an integer.
+func (r Rounding) round(v float64) float64 {
+	switch r {
+	case RoundHalfEven:
+		return math.RoundToEven(v)
+	case RoundTowardZero:
+		return math.Trunc(v)
+	}
+	return math.Round(v)
+}
+
+// factor returns the magnitude of full scale in int16 units.
+func (s Scaling) factor() float32 {
+	if s == Scaling32767 {
+		return 32767
+	}
+	return 32768
+}
+
+// fullScale returns the magnitude of full scale for signed samples of bits bits.
+func (s Scaling) fullScale(bits int) float64 {
+	if s == Scaling32767 {
+		return float64(int64(1)<<(bits-1) - 1)
+	}
+	return float64(int64(1) << (bits - 1))
+}
+
+// quantize returns v, a sample in units of full scale of signed samples of bits bits, rounded to
+// the nearest integer and saturated at their range. NaN converts to 0.
+func quantize(v float64, bits int) int64 {
+	v = math.Round(v)
+	switch lo, hi := -float64(int64(1)<<(bits-1)), float64(int64(1)<<(bits-1)-1); {
+	case v != v: // NaN
+		return 0
+	case v > hi:
+		return int64(hi)
+	case v < lo:
+		return int64(lo)
+	}
+	return int64(v)
+}
+
+// Int16ToFloat32 converts src to float32 samples in dst using scaling and returns the converted
+// samples. dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func Int16ToFloat32(dst []float32, src []int16, scaling Scaling) []float32 {
+	dst = grow(dst, len(src))
+	f := scaling.factor()
+	for i, s := range src {
+		dst[i] = float32(s) / f
+	}
+	return dst
+}
+
+// Float32ToInt16 converts src to int16 samples in dst using scaling and returns the converted
+// samples. Samples are rounded to the nearest integer and saturate at the int16 range; NaN
+// converts to 0. dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func Float32ToInt16(dst []int16, src []float32, scaling Scaling) []int16 {
+	return Float32ToInt16Rounded(dst, src, scaling, RoundHalfAwayFromZero)
+}
+
+// Float32ToInt16Rounded converts src to int16 samples as Float32ToInt16 does, but rounds the
+// samples with rounding. An unknown rounding rounds as RoundHalfAwayFromZero.
+func Float32ToInt16Rounded(dst []int16, src []float32, scaling Scaling, rounding Rounding) []int16 {
+	dst = grow(dst, len(src))
+	f := scaling.factor()
+	for i, s := range src {
+		v := rounding.round(float64(s * f))
+		switch {
+		case v != v: // NaN
+			dst[i] = 0
+		case v > math.MaxInt16:
+			dst[i] = math.MaxInt16
+		case v < math.MinInt16:
+			dst[i] = math.MinInt16
+		default:
+			dst[i] = int16(v)
+		}
+	}
+	return dst
+}
+
+// Uint8ToInt16 converts the unsigned 8-bit samples of src, centered at 128, to int16 samples in
+// dst and returns the converted samples. Each sample becomes the high byte of the int16 sample,
+// as libsonic does. dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func Uint8ToInt16(dst []int16, src []uint8) []int16 {
+	dst = grow(dst, len(src))
+	for i, s := range src {
+		dst[i] = int16(int(s)-128) << 8
+	}
+	return dst
+}
+
+// Int16ToUint8 converts src to unsigned 8-bit samples, centered at 128, in dst and returns the
+// converted samples. The low byte of each sample is dropped, as libsonic does. dst is reused if
+// it has enough capacity, otherwise a new slice is allocated.
+func Int16ToUint8(dst []uint8, src []int16) []uint8 {
+	dst = grow(dst, len(src))
+	for i, s := range src {
+		dst[i] = uint8(s>>8) + 128
+	}
+	return dst
+}
+
+// Int24ToFloat32 converts the packed little-endian 24-bit signed samples of src, 3 bytes each, to
+// float32 samples in dst using scaling and returns the converted samples. float32 represents every
+// 24-bit sample exactly. dst is reused if it has enough capacity, otherwise a new slice is
+// allocated. A trailing incomplete sample of src is ignored.
+func Int24ToFloat32(dst []float32, src []byte, scaling Scaling) []float32 {
+	dst = grow(dst, len(src)/3)
+	f := scaling.fullScale(24)
+	for i := range dst {
+		dst[i] = float32(float64(int24(src[i*3:])) / f)
+	}
+	return dst
+}
+
+// Float32ToInt24 converts src to packed little-endian 24-bit signed samples, 3 bytes each, in dst
+// using scaling and returns the encoded bytes. Samples are rounded to the nearest integer and
+// saturate at the 24-bit range; NaN converts to 0. dst is reused if it has enough capacity,
+// otherwise a new slice is allocated.
+func Float32ToInt24(dst []byte, src []float32, scaling Scaling) []byte {
+	dst = grow(dst, len(src)*3)
+	f := scaling.fullScale(24)
+	for i, s := range src {
+		putInt24(dst[i*3:], int32(quantize(float64(s)*f, 24)))
+	}
+	return dst
+}
+
+// Int16ToInt24 converts src to packed little-endian 24-bit signed samples, 3 bytes each, in dst
+// and returns the encoded bytes. Each sample becomes the high 16 bits of the 24-bit sample, so the
+// conversion is exact. dst is reused if it has enough capacity, otherwise a new slice is
+// allocated.
+func Int16ToInt24(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src)*3)
+	for i, s := range src {
+		putInt24(dst[i*3:], int32(s)<<8)
+	}
+	return dst
+}
+
+// Int32ToFloat32 converts the little-endian 32-bit signed samples of src to float32 samples in
+// dst using scaling and returns the converted samples. float32 keeps the upper 24 bits of each
+// sample, so the lower bits are rounded. dst is reused if it has enough capacity, otherwise a new
+// slice is allocated. A trailing incomplete sample of src is ignored.
+func Int32ToFloat32(dst []float32, src []byte, scaling Scaling) []float32 {
+	dst = grow(dst, len(src)/4)
+	f := scaling.fullScale(32)
+	for i := range dst {
+		dst[i] = float32(float64(int32(binary.LittleEndian.Uint32(src[i*4:]))) / f)
+	}
+	return dst
+}
+
+// Float32ToInt32 converts src to little-endian 32-bit signed samples in dst using scaling and
+// returns the encoded bytes. Samples are rounded to the nearest integer and saturate at the
+// 32-bit range; NaN converts to 0. dst is reused if it has enough capacity, otherwise a new slice
+// is allocated.
+func Float32ToInt32(dst []byte, src []float32, scaling Scaling) []byte {
+	dst = grow(dst, len(src)*4)
+	f := scaling.fullScale(32)
+	for i, s := range src {
+		binary.LittleEndian.PutUint32(dst[i*4:], uint32(quantize(float64(s)*f, 32)))
+	}
+	return dst
+}
+
+// Int16ToInt32 converts src to little-endian 32-bit signed samples in dst and returns the encoded
+// bytes. Each sample becomes the high 16 bits of the 32-bit sample, so the conversion is exact.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func Int16ToInt32(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src)*4)
+	for i, s := range src {
+		binary.LittleEndian.PutUint32(dst[i*4:], uint32(int32(s)<<16))
+	}
+	return dst
+}
+
+// Float64ToFloat32 converts the little-endian float64 samples of src to float32 samples in dst
+// and returns the converted samples. Samples beyond the range of float32 become infinite. dst is
+// reused if it has enough capacity, otherwise a new slice is allocated. A trailing incomplete
+// sample of src is ignored.
+func Float64ToFloat32(dst []float32, src []byte) []float32 {
+	dst = grow(dst, len(src)/8)
+	for i := range dst {
+		dst[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(src[i*8:])))
+	}
+	return dst
+}
+
+// Float32ToFloat64 converts src to little-endian float64 samples in dst and returns the encoded
+// bytes. The conversion is exact. dst is reused if it has enough capacity, otherwise a new slice
+// is allocated. src may occupy the second half of dst, so that samples can be widened in place.
+func Float32ToFloat64(dst []byte, src []float32) []byte {
+	dst = grow(dst, len(src)*8)
+	for i, s := range src {
+		binary.LittleEndian.PutUint64(dst[i*8:], math.Float64bits(float64(s)))
+	}
+	return dst
+}
+
+// int24 returns the little-endian 24-bit signed sample at the start of b.
+func int24(b []byte) int32 {
+	return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+}
+
+// putInt24 stores v as a little-endian 24-bit sample at the start of b.
+func putInt24(b []byte, v int32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,268 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestInt16ToFloat32(t *testing.T) {
+	in := []int16{0, 16384, math.MaxInt16, math.MinInt16, -32767}
+	tests := []struct {
+		scaling Scaling
+		want    []float32
+	}{
+		{Scaling32768, []float32{0, 0.5, 32767.0 / 32768, -1, -32767.0 / 32768}},
+		{Scaling32767, []float32{0, 16384.0 / 32767, 1, -32768.0 / 32767, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			if got := Int16ToFloat32(nil, in, tt.scaling); !slices.Equal(got, tt.want) {
+				t.Errorf("Int16ToFloat32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloat32ToInt16(t *testing.T) {
+	in := []float32{0, 0.5, 1, -1, 1.5, -1.5, float32(math.NaN()), float32(math.Inf(-1)), 0.25 / 32768}
+	tests := []struct {
+		scaling Scaling
+		want    []int16
+	}{
+		{Scaling32768, []int16{0, 16384, 32767, -32768, 32767, -32768, 0, -32768, 0}},
+		{Scaling32767, []int16{0, 16384, 32767, -32767, 32767, -32768, 0, -32768, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			if got := Float32ToInt16(nil, in, tt.scaling); !slices.Equal(got, tt.want) {
+				t.Errorf("Float32ToInt16() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloat32ToInt16Rounded(t *testing.T) {
+	// Halves and fractions of int16 steps with Scaling32768, which scales exactly.
+	in := []float32{0.5, 1.5, 2.5, -0.5, -1.5, -2.5, 2.7, -2.7, 0.25, 40000, -40000, float32(math.NaN())}
+	for i := range in {
+		in[i] /= 32768
+	}
+	tests := []struct {
+		rounding Rounding
+		want     []int16
+	}{
+		{RoundHalfAwayFromZero, []int16{1, 2, 3, -1, -2, -3, 3, -3, 0, 32767, -32768, 0}},
+		{RoundHalfEven, []int16{0, 2, 2, 0, -2, -2, 3, -3, 0, 32767, -32768, 0}},
+		{RoundTowardZero, []int16{0, 1, 2, 0, -1, -2, 2, -2, 0, 32767, -32768, 0}},
+		{Rounding(42), []int16{1, 2, 3, -1, -2, -3, 3, -3, 0, 32767, -32768, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rounding.String(), func(t *testing.T) {
+			if got := Float32ToInt16Rounded(nil, in, Scaling32768, tt.rounding); !slices.Equal(got, tt.want) {
+				t.Errorf("Float32ToInt16Rounded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Float32ToInt16 rounds halves away from zero.
+	if got, want := Float32ToInt16(nil, in, Scaling32768), Float32ToInt16Rounded(nil, in, Scaling32768, RoundHalfAwayFromZero); !slices.Equal(got, want) {
+		t.Errorf("Float32ToInt16() = %v, want %v", got, want)
+	}
+}
+
+func TestUint8ToInt16(t *testing.T) {
+	in := []uint8{128, 0, 255, 129, 127}
+	want := []int16{0, -32768, 32512, 256, -256}
+	if got := Uint8ToInt16(nil, in); !slices.Equal(got, want) {
+		t.Errorf("Uint8ToInt16() = %v, want %v", got, want)
+	}
+}
+
+func TestInt16ToUint8(t *testing.T) {
+	in := []int16{0, -32768, 32767, 256, 255, -1, -256, -257}
+	want := []uint8{128, 0, 255, 129, 128, 127, 127, 126}
+	if got := Int16ToUint8(nil, in); !slices.Equal(got, want) {
+		t.Errorf("Int16ToUint8() = %v, want %v", got, want)
+	}
+	// Every unsigned 8-bit sample survives the round trip.
+	for v := range 256 {
+		if got := Int16ToUint8(nil, Uint8ToInt16(nil, []uint8{uint8(v)})); got[0] != uint8(v) {
+			t.Errorf("round trip of %d = %d", v, got[0])
+		}
+	}
+}
+
+func TestScalingRoundTrip(t *testing.T) {
+	// Every int16 value survives a round trip with the same convention.
+	in := make([]int16, 0, 1<<16)
+	for v := math.MinInt16; v <= math.MaxInt16; v++ {
+		in = append(in, int16(v))
+	}
+	for _, scaling := range []Scaling{Scaling32768, Scaling32767} {
+		got := Float32ToInt16(nil, Int16ToFloat32(nil, in, scaling), scaling)
+		if !slices.Equal(got, in) {
+			t.Errorf("%v: round trip changed samples", scaling)
+		}
+	}
+}
+
+func TestScaling_String(t *testing.T) {
+	if got := Scaling(42).String(); got != "Scaling(42)" {
+		t.Errorf("String() = %q, want %q", got, "Scaling(42)")
+	}
+}
+
+func TestRounding_String(t *testing.T) {
+	if got := RoundHalfEven.String(); got != "RoundHalfEven" {
+		t.Errorf("String() = %q, want %q", got, "RoundHalfEven")
+	}
+	if got := Rounding(42).String(); got != "Rounding(42)" {
+		t.Errorf("String() = %q, want %q", got, "Rounding(42)")
+	}
+}
+
+func TestInt24ToFloat32(t *testing.T) {
+	in := []byte{
+		0x00, 0x00, 0x00, // 0
+		0x00, 0x00, 0x40, // 4194304
+		0xFF, 0xFF, 0x7F, // 8388607
+		0x00, 0x00, 0x80, // -8388608
+		0x01, 0x00, 0x80, // -8388607
+		0xFF, 0xFF, // Incomplete
+	}
+	tests := []struct {
+		scaling Scaling
+		want    []float32
+	}{
+		{Scaling32768, []float32{0, 0.5, 8388607.0 / 8388608, -1, -8388607.0 / 8388608}},
+		{Scaling32767, []float32{0, 4194304.0 / 8388607, 1, -8388608.0 / 8388607, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			if got := Int24ToFloat32(nil, in, tt.scaling); !slices.Equal(got, tt.want) {
+				t.Errorf("Int24ToFloat32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloat32ToInt24(t *testing.T) {
+	in := []float32{0, 0.5, 1, -1, 1.5, -1.5, float32(math.NaN()), -1 / 8388607.0}
+	tests := []struct {
+		scaling Scaling
+		want    []byte
+	}{
+		{Scaling32768, []byte{0, 0, 0, 0, 0, 0x40, 0xFF, 0xFF, 0x7F, 0, 0, 0x80, 0xFF, 0xFF, 0x7F, 0, 0, 0x80, 0, 0, 0, 0xFF, 0xFF, 0xFF}},
+		{Scaling32767, []byte{0, 0, 0, 0, 0, 0x40, 0xFF, 0xFF, 0x7F, 0x01, 0, 0x80, 0xFF, 0xFF, 0x7F, 0, 0, 0x80, 0, 0, 0, 0xFF, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			if got := Float32ToInt24(nil, in, tt.scaling); !slices.Equal(got, tt.want) {
+				t.Errorf("Float32ToInt24() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	// Every 24-bit sample survives a round trip with the same convention.
+	for _, scaling := range []Scaling{Scaling32768, Scaling32767} {
+		for v := int32(-1 << 23); v < 1<<23; v += 97 {
+			b := []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+			if got := Float32ToInt24(nil, Int24ToFloat32(nil, b, scaling), scaling); !slices.Equal(got, b) {
+				t.Fatalf("%v: round trip of %d = %x", scaling, v, got)
+			}
+		}
+	}
+}
+
+func TestInt16ToInt24(t *testing.T) {
+	in := []int16{0, 1, -1, math.MaxInt16, math.MinInt16}
+	want := []byte{0, 0, 0, 0, 1, 0, 0, 0xFF, 0xFF, 0, 0xFF, 0x7F, 0, 0, 0x80}
+	if got := Int16ToInt24(nil, in); !slices.Equal(got, want) {
+		t.Errorf("Int16ToInt24() = %x, want %x", got, want)
+	}
+}
+
+func TestInt32ToFloat32(t *testing.T) {
+	in := []byte{
+		0x00, 0x00, 0x00, 0x00, // 0
+		0x00, 0x00, 0x00, 0x40, // 1 << 30
+		0xFF, 0xFF, 0xFF, 0x7F, // MaxInt32
+		0x00, 0x00, 0x00, 0x80, // MinInt32
+		0xFF, 0xFF, // Incomplete
+	}
+	tests := []struct {
+		scaling Scaling
+		want    []float32
+	}{
+		{Scaling32768, []float32{0, 0.5, 1, -1}},
+		{Scaling32767, []float32{0, 0.5, 1, -1}}, // float32 rounds the difference away
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			if got := Int32ToFloat32(nil, in, tt.scaling); !slices.Equal(got, tt.want) {
+				t.Errorf("Int32ToFloat32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloat32ToInt32(t *testing.T) {
+	in := []float32{0, 0.5, 1, -1, 2, -2, float32(math.NaN())}
+	tests := []struct {
+		scaling Scaling
+		want    []int32
+	}{
+		{Scaling32768, []int32{0, 1 << 30, math.MaxInt32, math.MinInt32, math.MaxInt32, math.MinInt32, 0}},
+		{Scaling32767, []int32{0, 1 << 30, math.MaxInt32, -math.MaxInt32, math.MaxInt32, math.MinInt32, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scaling.String(), func(t *testing.T) {
+			got := Float32ToInt32(nil, in, tt.scaling)
+			want := make([]byte, 0, 4*len(tt.want))
+			for _, v := range tt.want {
+				want = binary.LittleEndian.AppendUint32(want, uint32(v))
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("Float32ToInt32() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestInt16ToInt32(t *testing.T) {
+	in := []int16{0, 1, -1, math.MaxInt16, math.MinInt16}
+	want := []byte{0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0xFF, 0xFF, 0, 0, 0xFF, 0x7F, 0, 0, 0, 0x80}
+	if got := Int16ToInt32(nil, in); !slices.Equal(got, want) {
+		t.Errorf("Int16ToInt32() = %x, want %x", got, want)
+	}
+	// The high 16 bits convert to the same float32 samples as the int16 samples.
+	if got, want := Int32ToFloat32(nil, want, Scaling32768), Int16ToFloat32(nil, in, Scaling32768); !slices.Equal(got, want) {
+		t.Errorf("Int32ToFloat32() = %v, want %v", got, want)
+	}
+}
+
+func TestFloat64(t *testing.T) {
+	in := []float32{0, 0.5, -1, 1.0 / 3, float32(math.Inf(1))}
+	b := Float32ToFloat64(nil, in)
+	for i, v := range in {
+		if got := math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:])); got != float64(v) {
+			t.Errorf("Float32ToFloat64() sample %d = %v, want %v", i, got, v)
+		}
+	}
+	if got := Float64ToFloat32(nil, append(b, 1, 2, 3)); !slices.Equal(got, in) {
+		t.Errorf("Float64ToFloat32() = %v, want %v", got, in)
+	}
+	big := binary.LittleEndian.AppendUint64(nil, math.Float64bits(1e300))
+	if got := Float64ToFloat32(nil, big); !math.IsInf(float64(got[0]), 1) {
+		t.Errorf("Float64ToFloat32(1e300) = %v, want +Inf", got[0])
+	}
+
+	// Widening in place, with the float32 samples in the second half of the buffer.
+	buf := UnsafeFloat32Bytes(make([]float32, 2*len(in)))
+	copy(buf[4*len(in):], EncodeFloat32(nil, in))
+	if got := Float32ToFloat64(buf, UnsafeFloat32s(buf[4*len(in):])); !slices.Equal(got, b) {
+		t.Errorf("Float32ToFloat64() in place = %x, want %x", got, b)
+	}
+}
@@ -0,0 +1,160 @@
+// Package pcm converts between audio samples and the little-endian PCM bytes consumed and
+// produced by sonic.Transformer.
+//
+// The Encode and Decode functions copy and work on any host. On little-endian hosts they copy
+// the memory as a whole, which is many times faster than encoding/binary.Read and binary.Write,
+// so use them rather than the binary package to turn the output of a Transformer into samples.
+// The Unsafe functions reinterpret the memory of a slice without copying. They are even faster
+// for large buffers, but the returned slice aliases its argument and they only work on
+// little-endian hosts with suitably aligned memory; see their documentation.
+package pcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// EncodeInt16 encodes src as little-endian bytes into dst and returns the encoded bytes.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func EncodeInt16(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src)*2)
+	if littleEndianHost {
+		copy(dst, sampleBytes(src))
+		return dst
+	}
+	for i, s := range src {
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(s))
+	}
+	return dst
+}
+
+// DecodeInt16 decodes the little-endian bytes of src into dst and returns the decoded samples.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+// A trailing incomplete sample of src is ignored.
+func DecodeInt16(dst []int16, src []byte) []int16 {
+	dst = grow(dst, len(src)/2)
+	if littleEndianHost {
+		copy(sampleBytes(dst), src)
+		return dst
+	}
+	for i := range dst {
+		dst[i] = int16(binary.LittleEndian.Uint16(src[i*2:]))
+	}
+	return dst
+}
+
+// EncodeFloat32 encodes src as little-endian IEEE 754 bytes into dst and returns the encoded bytes.
+// dst is reused if it has enough capacity, otherwise a new slice is allocated.
+func EncodeFloat32(dst []byte, src []float32) []byte {
+	dst = grow(dst, len(src)*4)
+	if littleEndianHost {
+		copy(dst, sampleBytes(src))
+		return dst
+	}
+	for i, s := range src {
+		binary.LittleEndian.PutUint32(dst[i*4:], math.Float32bits(s))
+	}
+	return dst
+}
+
+// DecodeFloat32 decodes the little-endian IEEE 754 bytes of src into dst and returns the decoded
+// samples. dst is reused if it has enough capacity, otherwise a new slice is allocated.
+// A trailing incomplete sample of src is ignored.
+func DecodeFloat32(dst []float32, src []byte) []float32 {
+	dst = grow(dst, len(src)/4)
+	if littleEndianHost {
+		copy(sampleBytes(dst), src)
+		return dst
+	}
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(src[i*4:]))
+	}
+	return dst
+}
+
+// UnsafeInt16s returns the int16 samples stored in b without copying.
+//
+// The returned slice shares memory with b: writes to one are visible through the other, and b
+// must stay alive while the samples are used. The bytes are interpreted in host byte order, so
+// the function panics on big-endian hosts, where the result would not match the little-endian
+// PCM format. It also panics if b is not 2-byte aligned. Go does not guarantee the alignment of
+// a []byte, so convert buffers whose samples were allocated as such, e.g. with UnsafeInt16Bytes,
+// and slice them at offsets that are multiples of the sample size. A trailing incomplete sample
+// of b is ignored.
+func UnsafeInt16s(b []byte) []int16 {
+	if len(b) < 2 {
+		return nil
+	}
+	checkUnsafe(b, 2)
+	return unsafe.Slice((*int16)(unsafe.Pointer(&b[0])), len(b)/2)
+}
+
+// UnsafeFloat32s returns the float32 samples stored in b without copying.
+//
+// The same constraints as for UnsafeInt16s apply, except that b must be 4-byte aligned.
+func UnsafeFloat32s(b []byte) []float32 {
+	if len(b) < 4 {
+		return nil
+	}
+	checkUnsafe(b, 4)
+	return unsafe.Slice((*float32)(unsafe.Pointer(&b[0])), len(b)/4)
+}
+
+// UnsafeInt16Bytes returns the bytes of the int16 samples s without copying.
+//
+// The returned slice shares memory with s, and the function panics on big-endian hosts.
+// See UnsafeInt16s.
+func UnsafeInt16Bytes(s []int16) []byte {
+	checkUnsafe(nil, 1)
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*2)
+}
+
+// UnsafeFloat32Bytes returns the bytes of the float32 samples s without copying.
+//
+// The returned slice shares memory with s, and the function panics on big-endian hosts.
+// See UnsafeInt16s.
+func UnsafeFloat32Bytes(s []float32) []byte {
+	checkUnsafe(nil, 1)
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*4)
+}
+
+// littleEndianHost reports whether the host stores integers in little-endian byte order.
+var littleEndianHost = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// checkUnsafe panics if b cannot be reinterpreted as samples of size bytes.
+func checkUnsafe(b []byte, size int) {
+	if !littleEndianHost {
+		panic("pcm: unsafe conversions require a little-endian host")
+	}
+	if len(b) > 0 && uintptr(unsafe.Pointer(&b[0]))%uintptr(size) != 0 {
+		panic(fmt.Sprintf("pcm: buffer is not %d-byte aligned", size))
+	}
+}
+
+// sampleBytes returns the memory of s as bytes in host byte order. Copying from or to it
+// replaces the per-sample conversion on little-endian hosts.
+func sampleBytes[T int16 | float32](s []T) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(s[0])))
+}
+
+// grow returns s resized to n elements, reusing its memory if possible.
+func grow[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
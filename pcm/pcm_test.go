@@ -0,0 +1,169 @@
+package pcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestInt16(t *testing.T) {
+	samples := []int16{0, 1, -1, math.MaxInt16, math.MinInt16, 0x0102}
+	want := []byte{0, 0, 1, 0, 0xff, 0xff, 0xff, 0x7f, 0, 0x80, 0x02, 0x01}
+
+	if got := EncodeInt16(nil, samples); !slices.Equal(got, want) {
+		t.Errorf("EncodeInt16() = %x, want %x", got, want)
+	}
+	if got := DecodeInt16(nil, want); !slices.Equal(got, samples) {
+		t.Errorf("DecodeInt16() = %v, want %v", got, samples)
+	}
+	if got := DecodeInt16(nil, want[:3]); !slices.Equal(got, samples[:1]) {
+		t.Errorf("DecodeInt16() with incomplete sample = %v, want %v", got, samples[:1])
+	}
+
+	b := UnsafeInt16Bytes(make([]int16, len(samples)))
+	copy(b, want)
+	view := UnsafeInt16s(b)
+	if !slices.Equal(view, samples) {
+		t.Errorf("UnsafeInt16s() = %v, want %v", view, samples)
+	}
+	view[0] = 0x0304
+	if b[0] != 0x04 || b[1] != 0x03 {
+		t.Error("UnsafeInt16s() does not share memory with its argument")
+	}
+	if got := UnsafeInt16Bytes(samples); !slices.Equal(got, want) {
+		t.Errorf("UnsafeInt16Bytes() = %x, want %x", got, want)
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	samples := []float32{0, 1, -0.5}
+	want := []byte{0, 0, 0, 0, 0, 0, 0x80, 0x3f, 0, 0, 0, 0xbf}
+
+	if got := EncodeFloat32(nil, samples); !slices.Equal(got, want) {
+		t.Errorf("EncodeFloat32() = %x, want %x", got, want)
+	}
+	if got := DecodeFloat32(nil, want); !slices.Equal(got, samples) {
+		t.Errorf("DecodeFloat32() = %v, want %v", got, samples)
+	}
+
+	b := UnsafeFloat32Bytes(make([]float32, len(samples)))
+	copy(b, want)
+	if got := UnsafeFloat32s(b); !slices.Equal(got, samples) {
+		t.Errorf("UnsafeFloat32s() = %v, want %v", got, samples)
+	}
+	if got := UnsafeFloat32Bytes(samples); !slices.Equal(got, want) {
+		t.Errorf("UnsafeFloat32Bytes() = %x, want %x", got, want)
+	}
+}
+
+func TestEncodeReusesDst(t *testing.T) {
+	dst := make([]byte, 0, 16)
+	got := EncodeInt16(dst, []int16{1, 2})
+	if &got[0] != &dst[:1][0] {
+		t.Error("EncodeInt16() did not reuse dst")
+	}
+	samples := make([]float32, 0, 4)
+	gotSamples := DecodeFloat32(samples, make([]byte, 8))
+	if &gotSamples[0] != &samples[:1][0] {
+		t.Error("DecodeFloat32() did not reuse dst")
+	}
+}
+
+func TestUnsafeEmpty(t *testing.T) {
+	if UnsafeInt16s(nil) != nil || UnsafeFloat32s([]byte{1, 2, 3}) != nil {
+		t.Error("conversion of buffers without a complete sample should return nil")
+	}
+	if UnsafeInt16Bytes(nil) != nil || UnsafeFloat32Bytes(nil) != nil {
+		t.Error("conversion of empty samples should return nil")
+	}
+}
+
+func TestUnsafeUnaligned(t *testing.T) {
+	// The bytes of a []float32 are 4-byte aligned; small []byte allocations need not be.
+	b := UnsafeFloat32Bytes(make([]float32, 3))
+	defer func() {
+		if recover() == nil {
+			t.Error("UnsafeFloat32s() of an unaligned buffer did not panic")
+		}
+	}()
+	UnsafeFloat32s(b[1:])
+}
+
+// benchmarkFrames is the number of samples decoded per iteration, one second of 48 kHz stereo.
+const benchmarkFrames = 2 * 48000
+
+func BenchmarkDecodeInt16(b *testing.B) {
+	src := EncodeInt16(nil, make([]int16, benchmarkFrames))
+	dst := make([]int16, benchmarkFrames)
+
+	b.Run("binary.Read", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			if err := binary.Read(bytes.NewReader(src), binary.LittleEndian, dst); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DecodeInt16", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			dst = DecodeInt16(dst, src)
+		}
+	})
+	b.Run("UnsafeInt16s", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			dst = UnsafeInt16s(src)
+		}
+	})
+}
+
+func BenchmarkDecodeFloat32(b *testing.B) {
+	src := EncodeFloat32(nil, make([]float32, benchmarkFrames))
+	dst := make([]float32, benchmarkFrames)
+
+	b.Run("binary.Read", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			if err := binary.Read(bytes.NewReader(src), binary.LittleEndian, dst); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DecodeFloat32", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			dst = DecodeFloat32(dst, src)
+		}
+	})
+	b.Run("UnsafeFloat32s", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		for b.Loop() {
+			dst = UnsafeFloat32s(src)
+		}
+	})
+}
+
+func BenchmarkEncodeInt16(b *testing.B) {
+	src := make([]int16, benchmarkFrames)
+	dst := make([]byte, 2*benchmarkFrames)
+
+	b.Run("binary.Write", func(b *testing.B) {
+		b.SetBytes(int64(len(dst)))
+		var buf bytes.Buffer
+		for b.Loop() {
+			buf.Reset()
+			if err := binary.Write(&buf, binary.LittleEndian, src); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("EncodeInt16", func(b *testing.B) {
+		b.SetBytes(int64(len(dst)))
+		for b.Loop() {
+			dst = EncodeInt16(dst, src)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+// Guess is a hypothesis about the layout of raw PCM data. See Sniff.
+type Guess struct {
+	Float       bool    // 32-bit IEEE 754 float samples instead of 16-bit signed integers
+	BigEndian   bool    // Big-endian instead of little-endian byte order
+	NumChannels int     // Number of interleaved channels
+	Score       float64 // Plausibility of the hypothesis in [0, 1]; higher is more plausible
+}
+
+// Sniff guesses the sample format, byte order and channel count of raw PCM data by measuring
+// signal statistics under each hypothesis, and returns the hypotheses ordered from most to least
+// plausible. Channel counts from 1 to maxChannels are considered.
+//
+// It is a best-effort diagnostic for headerless input, not a format detector: audio consists of
+// smooth waveforms, so the right interpretation yields small differences between consecutive
+// frames, while the wrong byte order or sample size turns the same bytes into noise, and floats
+// of the wrong byte order into implausible magnitudes. Silence, white noise, very short buffers
+// and channels carrying identical signals cannot be told apart reliably; a few hundred
+// milliseconds of program material are usually enough.
+func Sniff(b []byte, maxChannels int) []Guess {
+	maxChannels = max(maxChannels, 1)
+	var guesses []Guess
+	for _, float := range []bool{false, true} {
+		for _, bigEndian := range []bool{false, true} {
+			samples, plausibility := decodeForSniff(b, float, bigEndian)
+			for ch := 1; ch <= maxChannels; ch++ {
+				guesses = append(guesses, Guess{
+					Float:       float,
+					BigEndian:   bigEndian,
+					NumChannels: ch,
+					Score:       plausibility * smoothness(samples, ch),
+				})
+			}
+		}
+	}
+	// Prefer fewer channels on ties, since a signal that is smooth at stride n is usually also
+	// fairly smooth at multiples of n.
+	slices.SortStableFunc(guesses, func(a, b Guess) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return guesses
+}
+
+// decodeForSniff decodes b under one format hypothesis into normalized samples. It also returns
+// the fraction of samples that are plausible audio values, which is always 1 for int16.
+func decodeForSniff(b []byte, float, bigEndian bool) ([]float64, float64) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	if !float {
+		samples := make([]float64, len(b)/2)
+		for i := range samples {
+			samples[i] = float64(int16(order.Uint16(b[i*2:]))) / 32768
+		}
+		return samples, 1
+	}
+
+	samples := make([]float64, len(b)/4)
+	plausible := 0
+	for i := range samples {
+		v := float64(math.Float32frombits(order.Uint32(b[i*4:])))
+		// Real audio is finite, within a few times full scale, and not vanishingly small.
+		if a := math.Abs(v); a == 0 || (1e-7 < a && a <= 4) {
+			plausible++
+			samples[i] = v
+		}
+	}
+	if len(samples) == 0 {
+		return nil, 0
+	}
+	return samples, float64(plausible) / float64(len(samples))
+}
+
+// smoothness rates how smooth samples are when interpreted as interleaved frames of numChannels
+// channels, from 0 (noise or silence) to 1 (very smooth).
+func smoothness(samples []float64, numChannels int) float64 {
+	var level, diff float64
+	for i := numChannels; i < len(samples); i++ {
+		level += math.Abs(samples[i])
+		diff += math.Abs(samples[i] - samples[i-numChannels])
+	}
+	if level == 0 {
+		return 0
+	}
+	return 1 / (1 + diff/level)
+}
@@ -0,0 +1,78 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+// encodeForSniff encodes normalized samples under the given format hypothesis.
+func encodeForSniff(samples []float32, float, bigEndian bool) []byte {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	if float {
+		b := make([]byte, len(samples)*4)
+		for i, s := range samples {
+			order.PutUint32(b[i*4:], math.Float32bits(s))
+		}
+		return b
+	}
+	b := make([]byte, len(samples)*2)
+	for i, s := range Float32ToInt16(nil, samples, Scaling32768) {
+		order.PutUint16(b[i*2:], uint16(s))
+	}
+	return b
+}
+
+func TestSniff(t *testing.T) {
+	// Half a second of active speech; the clip starts and ends with near silence.
+	all := Int16ToFloat32(nil, audiotest.Speech(), Scaling32768)
+	speech := all[len(all)/4:][:audiotest.SpeechSampleRate/2]
+
+	// Stereo and 4-channel signals carry a different waveform on every channel.
+	multi := func(numChannels int) []float32 {
+		samples := make([]float32, 0, len(speech)*numChannels)
+		for i := range speech {
+			for ch := range numChannels {
+				delayed := speech[max(i-ch*97, 0)]
+				tone := float32(0.2 * math.Sin(2*math.Pi*float64(300+200*ch)*float64(i)/audiotest.SpeechSampleRate))
+				samples = append(samples, 0.5*delayed+tone)
+			}
+		}
+		return samples
+	}
+
+	for _, numChannels := range []int{1, 2, 4} {
+		samples := speech
+		if numChannels > 1 {
+			samples = multi(numChannels)
+		}
+		for _, float := range []bool{false, true} {
+			for _, bigEndian := range []bool{false, true} {
+				guesses := Sniff(encodeForSniff(samples, float, bigEndian), 8)
+				if len(guesses) != 4*8 {
+					t.Fatalf("Sniff() returned %d guesses, want %d", len(guesses), 4*8)
+				}
+				got := guesses[0]
+				if got.Float != float || got.BigEndian != bigEndian || got.NumChannels != numChannels {
+					t.Errorf("Sniff(float=%v, bigEndian=%v, channels=%d) = %+v", float, bigEndian, numChannels, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSniff_Silence(t *testing.T) {
+	for _, g := range Sniff(make([]byte, 4096), 2) {
+		if g.Score != 0 {
+			t.Errorf("Sniff() of silence scored %+v, want 0", g)
+		}
+	}
+	if got := Sniff(nil, 0); len(got) != 4 {
+		t.Errorf("Sniff(nil, 0) returned %d guesses, want 4", len(got))
+	}
+}
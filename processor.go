@@ -0,0 +1,76 @@
+package sonic
+
+import (
+	"slices"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// Processor transforms float32 samples in place of a writer, for embedding in a DSP graph that
+// pulls and pushes sample blocks, e.g. the audio callback of a game engine.
+//
+// Process takes a block of interleaved input samples and returns the output that became
+// available; the processor keeps the samples that are still being processed between calls.
+// It runs on the calling goroutine and, once its buffers have grown, does not allocate.
+// A Processor must not be used concurrently.
+type Processor struct {
+	t   *Transformer
+	in  []byte    // Input encoded for the transformer
+	out []float32 // Output of the current call
+}
+
+// NewProcessor creates a processor for float32 samples at sampleRate.
+//
+// opts configure the underlying Transformer as for NewTransformer, e.g. WithSpeed, WithRate and
+// WithChannels; WithOutputFunc and WithOutputFormat are overridden, since the processor returns
+// float32 samples itself.
+func NewProcessor(sampleRate int, opts ...Option) (*Processor, error) {
+	p := &Processor{}
+	opts = append(opts[:len(opts):len(opts)], WithOutputFormat(AudioFormatIEEEFloat), WithOutputFunc(p.receive))
+	t, err := NewTransformer(nil, sampleRate, AudioFormatIEEEFloat, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.t = t
+	return p, nil
+}
+
+// receive collects the output of the transformer.
+func (p *Processor) receive(b []byte) error {
+	n := len(p.out)
+	p.out = slices.Grow(p.out, len(b)/4)[:n+len(b)/4]
+	pcm.DecodeFloat32(p.out[n:], b)
+	return nil
+}
+
+// Process processes the interleaved samples of in and returns the output produced so far. in
+// must hold whole frames. The returned slice is owned by the processor and is only valid until
+// the next call of Process or Flush.
+func (p *Processor) Process(in []float32) ([]float32, error) {
+	p.out = p.out[:0]
+	p.in = pcm.EncodeFloat32(p.in[:0], in)
+	if _, err := p.t.Write(p.in); err != nil {
+		return nil, err
+	}
+	return p.out, nil
+}
+
+// Flush processes the samples the processor still holds, e.g. at the end of a sound, and returns
+// the remaining output. The returned slice is only valid until the next call of Process or Flush.
+func (p *Processor) Flush() ([]float32, error) {
+	p.out = p.out[:0]
+	if err := p.t.Flush(); err != nil {
+		return nil, err
+	}
+	return p.out, nil
+}
+
+// Transformer returns the underlying transformer, e.g. to call Update or Stats.
+func (p *Processor) Transformer() *Transformer {
+	return p.t
+}
+
+// Close releases the resources of the processor.
+func (p *Processor) Close() error {
+	return p.t.Close()
+}
@@ -0,0 +1,99 @@
+package sonic
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestProcessor(t *testing.T) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech()[:audiotest.SpeechSampleRate], pcm.Scaling32768)
+	stereo := Interleave(nil, speech, speech)
+
+	tests := []struct {
+		name    string
+		samples []float32
+		opts    []Option
+		block   int
+	}{
+		{"speed", speech, []Option{WithSpeed(1.5)}, 256},
+		{"rate", speech, []Option{WithRate(0.75), WithPitch(1.2)}, 480},
+		{"stereo", stereo, []Option{WithChannels(2), WithSpeed(0.8)}, 512},
+		{"output format overridden", speech, []Option{WithOutputFormat(AudioFormatPCM)}, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			opts := append(slices.Clone(tt.opts), WithOutputFormat(AudioFormatIEEEFloat))
+			tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatIEEEFloat, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			p, err := NewProcessor(audiotest.SpeechSampleRate, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewProcessor() error = %v", err)
+			}
+			defer p.Close()
+
+			var got []float32
+			for block := range slices.Chunk(tt.samples, tt.block) {
+				if _, err := tr.Write(pcm.EncodeFloat32(nil, block)); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				out, err := p.Process(block)
+				if err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+				got = append(got, out...)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			out, err := p.Flush()
+			if err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			got = append(got, out...)
+
+			if want := pcm.DecodeFloat32(nil, want.Bytes()); !slices.Equal(got, want) {
+				t.Errorf("Processor output has %d samples, want the %d samples of Transformer", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestProcessor_NoAllocs(t *testing.T) {
+	speech := pcm.Int16ToFloat32(nil, audiotest.Speech(), pcm.Scaling32768)
+	p, err := NewProcessor(audiotest.SpeechSampleRate, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewProcessor() error = %v", err)
+	}
+	defer p.Close()
+
+	pos := 0
+	next := func() []float32 {
+		if pos+256 > len(speech) {
+			pos = 0
+		}
+		pos += 256
+		return speech[pos-256 : pos]
+	}
+	for range 100 {
+		if _, err := p.Process(next()); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+	}
+	allocs := testing.AllocsPerRun(500, func() {
+		if _, err := p.Process(next()); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Process() allocates %v times per call, want 0", allocs)
+	}
+}
@@ -0,0 +1,46 @@
+package sonic
+
+import "time"
+
+const (
+	maxStartupRamp     = 10 * time.Second
+	rampStepsPerSecond = 100 // The speed is updated every 10 ms of input during the startup ramp
+)
+
+// rampChunk applies the startup ramp to the next chunk of at most size input samples. It sets
+// the speed of the stream for the chunk and returns the number of samples to write with it,
+// which is smaller than size while the ramp is in progress, so that the speed changes smoothly.
+func (t *Transformer) rampChunk(size int) int {
+	if t.rampFrames == 0 {
+		return size
+	}
+	if t.rampPos >= t.rampFrames {
+		t.setStreamSpeed(*t.speed)
+		t.rampFrames = 0
+		return size
+	}
+	frames := min(size/t.numChannels, max(t.sampleRate/rampStepsPerSecond, 1))
+	frames = min(frames, t.rampFrames-t.rampPos)
+	if frames == 0 {
+		return size
+	}
+	progress := float32(t.rampPos) / float32(t.rampFrames)
+	t.setStreamSpeed(1 + (*t.speed-1)*progress)
+	t.rampPos += frames
+	return frames * t.numChannels
+}
+
+// setStreamSpeed sets the speed of the stream, or of both streams in mid-side mode, together
+// with the pitch limited for it; see streamPitch.
+func (t *Transformer) setStreamSpeed(speed float32) {
+	pitch := t.streamPitch(speed)
+	if t.midSide != nil {
+		t.midSide.mid.SetSpeed(speed)
+		t.midSide.mid.SetPitch(pitch)
+		t.midSide.side.SetSpeed(speed)
+		t.midSide.side.SetPitch(pitch)
+		return
+	}
+	t.stream.SetSpeed(speed)
+	t.stream.SetPitch(pitch)
+}
@@ -0,0 +1,82 @@
+package sonic
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+// speedRecorder is a Stream that records the speed in effect for every frame written to it.
+type speedRecorder struct {
+	Stream
+	speeds []float32
+}
+
+func (r *speedRecorder) WriteShortToStream(samples []int16, numFrames int) int {
+	for range numFrames {
+		r.speeds = append(r.speeds, r.GetSpeed())
+	}
+	return r.Stream.WriteShortToStream(samples, numFrames)
+}
+
+func TestTransformer_StartupRamp(t *testing.T) {
+	const sampleRate = audiotest.SpeechSampleRate
+
+	tests := []struct {
+		name       string
+		speed      float32
+		ramp       time.Duration
+		wantFrames int // Frames written before the target speed is reached
+	}{
+		{"speed up", 2.5, 500 * time.Millisecond, sampleRate / 2},
+		{"slow down", 0.5, time.Second, sampleRate},
+		{"no ramp", 2.5, 0, 0},
+		{"no speed change", 1, time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec *speedRecorder
+			engine := StreamFactory(func(sampleRate, numChannels int) (Stream, error) {
+				s, err := EngineSonic.NewStream(sampleRate, numChannels)
+				rec = &speedRecorder{Stream: s}
+				return rec, err
+			})
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, sampleRate, AudioFormatPCM, WithEngine(engine), WithSpeed(tt.speed), WithStartupRamp(tt.ramp))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			speech := audiotest.SpeechPCM()
+			if _, err := tr.Write(speech); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if len(rec.speeds) != len(speech)/2 {
+				t.Fatalf("frames written = %d, want %d", len(rec.speeds), len(speech)/2)
+			}
+			if tt.wantFrames > 0 && rec.speeds[0] != 1 {
+				t.Errorf("initial speed = %v, want 1", rec.speeds[0])
+			}
+			for i, s := range rec.speeds {
+				if i >= tt.wantFrames {
+					if s != tt.speed {
+						t.Fatalf("speed at frame %d = %v, want %v", i, s, tt.speed)
+					}
+					break
+				}
+				if i > 0 && (s-rec.speeds[i-1])*(tt.speed-1) < 0 {
+					t.Fatalf("speed at frame %d = %v moves away from the target (previous %v)", i, s, rec.speeds[i-1])
+				}
+				if s == tt.speed {
+					t.Fatalf("target speed reached at frame %d, want %d", i, tt.wantFrames)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// RawCall calls f with the libsonic stream of the transformer, as a C sonicStream, so that
+// functions of libsonic that the bindings do not cover yet can be called from cgo code without
+// forking sonic-go:
+//
+//	// #include "sonic.h" // The header of the libsonic version vendored by sonic-go
+//	import "C"
+//
+//	err := tr.RawCall(func(stream unsafe.Pointer) {
+//		C.sonicSetUserData(C.sonicStream(stream), userData)
+//	})
+//
+// f is called once per stream: once, or twice in mid-side mode, for the mid stream and then for
+// the side stream. The following rules apply:
+//
+//   - The pointer is only valid during the call. Do not retain it: the stream is destroyed by
+//     Close and may be recreated by WithRecovery.
+//   - f runs on the goroutine calling RawCall. Like the other methods, RawCall must not be called
+//     concurrently with Write, Flush or Close; f must not call methods of the transformer.
+//   - f must not destroy the stream, change its sample rate or number of channels, or write or
+//     read samples, since the transformer keeps track of the stream's format and contents.
+//   - Parameters the transformer manages, such as the speed, are set again by the transformer,
+//     e.g. by Update; use its methods to change them.
+//
+// RawCall returns an error wrapping errors.ErrUnsupported if the streams are not libsonic
+// streams, e.g. with EngineMusic, a StreamFactory or in pure-Go builds, and ErrClosed after
+// Close. f is not called then.
+func (t *Transformer) RawCall(f func(stream unsafe.Pointer)) error {
+	if t.closed {
+		return ErrClosed
+	}
+	streams := []Stream{t.stream}
+	if t.midSide != nil {
+		streams = []Stream{t.midSide.mid, t.midSide.side}
+	}
+	pointers := make([]unsafe.Pointer, 0, len(streams))
+	for _, s := range streams {
+		c, ok := s.(interface{ CPointer() unsafe.Pointer })
+		if !ok || c.CPointer() == nil {
+			return fmt.Errorf("%w: stream %T is not a libsonic stream", errors.ErrUnsupported, s)
+		}
+		pointers = append(pointers, c.CPointer())
+	}
+	for _, p := range pointers {
+		f(p)
+	}
+	// The finalizer must not destroy the streams while f runs.
+	runtime.KeepAlive(t)
+	return nil
+}
@@ -0,0 +1,84 @@
+package sonic
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"unsafe"
+)
+
+func TestTransformer_RawCall(t *testing.T) {
+	// The default engine has libsonic streams in cgo builds only.
+	s, err := EngineSonic.NewStream(44100, 1)
+	if err != nil {
+		t.Fatalf("NewStream() error = %v", err)
+	}
+	_, libsonic := s.(interface{ CPointer() unsafe.Pointer })
+	s.DestroyStream()
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantCalls int
+	}{
+		{"mono", nil, 1},
+		{"mid-side", []Option{WithChannels(2), WithMidSide()}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			var pointers []unsafe.Pointer
+			err = tr.RawCall(func(stream unsafe.Pointer) {
+				pointers = append(pointers, stream)
+			})
+			if !libsonic {
+				if !errors.Is(err, errors.ErrUnsupported) || len(pointers) != 0 {
+					t.Errorf("RawCall() in a pure-Go build = %d calls, %v, want 0, %v", len(pointers), err, errors.ErrUnsupported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RawCall() error = %v", err)
+			}
+			if len(pointers) != tt.wantCalls {
+				t.Fatalf("RawCall() called f %d times, want %d", len(pointers), tt.wantCalls)
+			}
+			for i, p := range pointers {
+				if p == nil {
+					t.Errorf("RawCall() pointer %d is nil", i)
+				}
+			}
+			if tt.wantCalls == 2 && pointers[0] == pointers[1] {
+				t.Error("RawCall() passed the same stream twice in mid-side mode")
+			}
+		})
+	}
+
+	t.Run("EngineMusic", func(t *testing.T) {
+		tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithEngine(EngineMusic))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		called := false
+		if err := tr.RawCall(func(unsafe.Pointer) { called = true }); !errors.Is(err, errors.ErrUnsupported) || called {
+			t.Errorf("RawCall() = called %v, %v, want not called, %v", called, err, errors.ErrUnsupported)
+		}
+	})
+
+	t.Run("after Close", func(t *testing.T) {
+		tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		tr.Close()
+		called := false
+		if err := tr.RawCall(func(unsafe.Pointer) { called = true }); !errors.Is(err, ErrClosed) || called {
+			t.Errorf("RawCall() after Close = called %v, %v, want not called, %v", called, err, ErrClosed)
+		}
+	})
+}
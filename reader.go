@@ -0,0 +1,101 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reader transforms the samples read from a source reader, for pull-based pipelines, e.g. to
+// feed an audio output device that asks for the next buffer.
+//
+// Read reads input from the source as needed and returns the output. When the source returns
+// io.EOF, the reader flushes the transformer and returns io.EOF after the remaining output.
+// A Reader must not be used concurrently.
+type Reader struct {
+	t   *Transformer
+	r   io.Reader
+	in  []byte // Buffer for the input read from r
+	out []byte // Output not read yet, starting at off
+	off int
+	err error // Error to return once out is read; io.EOF after the final flush
+}
+
+// NewReader creates a reader that transforms the samples read from r, which are in format at
+// sampleRate.
+//
+// opts configure the underlying Transformer as for NewTransformer; WithOutputFunc is overridden,
+// since the reader returns the output itself. A trailing incomplete frame of r is discarded.
+func NewReader(r io.Reader, sampleRate int, format AudioFormat, opts ...Option) (*Reader, error) {
+	rd := &Reader{r: r}
+	opts = append(opts[:len(opts):len(opts)], WithOutputFunc(rd.receive))
+	t, err := NewTransformer(nil, sampleRate, format, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rd.t = t
+	rd.in = make([]byte, streamBufferFrames*t.FrameSize())
+	return rd, nil
+}
+
+// receive collects the output of the transformer.
+func (rd *Reader) receive(p []byte) error {
+	rd.out = append(rd.out, p...)
+	return nil
+}
+
+// Read reads transformed output into p. It returns io.EOF once the source is exhausted and all
+// output has been read. Other errors of the source are returned wrapped, and errors of the
+// transformer as they are; an error wrapping ErrRecovered is returned once, and reading can
+// continue after it.
+func (rd *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for rd.off == len(rd.out) {
+		if rd.err != nil {
+			err := rd.err
+			if errors.Is(err, ErrRecovered) {
+				rd.err = nil
+			}
+			return 0, err
+		}
+		rd.out, rd.off = rd.out[:0], 0
+		rd.err = rd.fill()
+	}
+	n := copy(p, rd.out[rd.off:])
+	rd.off += n
+	return n, nil
+}
+
+// fill reads the next chunk of the source and writes it to the transformer, whose carry-over
+// completes frames split across reads, flushing it at the end of the source. It returns io.EOF
+// after the flush.
+func (rd *Reader) fill() error {
+	n, rerr := rd.r.Read(rd.in)
+	if n > 0 {
+		if _, err := rd.t.Write(rd.in[:n]); err != nil {
+			return err
+		}
+	}
+	if rerr == io.EOF {
+		if err := rd.t.Flush(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	if rerr != nil {
+		return fmt.Errorf("failed to read input: %w", rerr)
+	}
+	return nil
+}
+
+// Transformer returns the underlying transformer, e.g. to call Update or Stats.
+func (rd *Reader) Transformer() *Transformer {
+	return rd.t
+}
+
+// Close releases the resources of the reader. It does not close the source reader.
+func (rd *Reader) Close() error {
+	return rd.t.Close()
+}
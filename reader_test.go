@@ -0,0 +1,120 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestReader(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	mono := pcm.EncodeInt16(nil, speech)
+	stereo := pcm.EncodeInt16(nil, Interleave(nil, speech, speech))
+
+	tests := []struct {
+		name   string
+		input  []byte
+		opts   []Option
+		source func(io.Reader) io.Reader
+		size   int // Size of the buffer passed to Read
+	}{
+		{"speed", mono, []Option{WithSpeed(1.5)}, nil, 4096},
+		{"rate", mono, []Option{WithRate(0.75), WithPitch(1.2)}, nil, 1000},
+		{"stereo", stereo, []Option{WithChannels(2), WithSpeed(0.8)}, nil, 4096},
+		{"float output", mono, []Option{WithSpeed(2), WithOutputFormat(AudioFormatIEEEFloat)}, nil, 4096},
+		{"one byte reads of the source", mono, []Option{WithSpeed(1.5)}, iotest.OneByteReader, 4096},
+		{"half reads of the source", stereo, []Option{WithChannels(2), WithSpeed(1.5)}, iotest.HalfReader, 4096},
+		{"odd buffer", stereo, []Option{WithChannels(2), WithSpeed(1.5)}, nil, 3},
+		{"source returning data with EOF", mono, []Option{WithSpeed(1.5)}, iotest.DataErrReader, 4096},
+		{"trailing incomplete frame", append(slices.Clone(stereo), 1, 2, 3), []Option{WithChannels(2), WithSpeed(1.5)}, nil, 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			// libsonic output depends on the chunks written, so give both the same reads
+			source := func() io.Reader {
+				if tt.source == nil {
+					return bytes.NewReader(tt.input)
+				}
+				return tt.source(bytes.NewReader(tt.input))
+			}
+			if _, err := CopyContext(t.Context(), tr, source()); err != nil {
+				t.Fatalf("CopyContext() error = %v", err)
+			}
+
+			rd, err := NewReader(source(), audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			defer rd.Close()
+			var got []byte
+			buf := make([]byte, tt.size)
+			for {
+				n, err := rd.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("Read() returned %d bytes, want the %d bytes of the transformer", len(got), want.Len())
+			}
+			if n, err := rd.Read(buf); n != 0 || err != io.EOF {
+				t.Errorf("Read() after EOF = %d, %v, want 0, EOF", n, err)
+			}
+			if got := rd.Transformer().Stats().InputBytes; got != tr.Stats().InputBytes {
+				t.Errorf("InputBytes = %d, want %d", got, tr.Stats().InputBytes)
+			}
+		})
+	}
+}
+
+func TestReader_SourceError(t *testing.T) {
+	errSource := errors.New("device unplugged")
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate])
+	src := io.MultiReader(bytes.NewReader(input), iotest.ErrReader(errSource))
+	rd, err := NewReader(src, audiotest.SpeechSampleRate, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	defer rd.Close()
+
+	got, err := io.ReadAll(rd)
+	if !errors.Is(err, errSource) {
+		t.Errorf("ReadAll() error = %v, want %v", err, errSource)
+	}
+	if len(got) == 0 {
+		t.Error("ReadAll() returned no output before the error")
+	}
+	if _, err := rd.Read(make([]byte, 10)); !errors.Is(err, errSource) {
+		t.Errorf("Read() after the error = %v, want %v", err, errSource)
+	}
+}
+
+func TestReader_EmptyBuffer(t *testing.T) {
+	rd, err := NewReader(bytes.NewReader(make([]byte, 100)), 48000, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	defer rd.Close()
+	if n, err := rd.Read(nil); n != 0 || err != nil {
+		t.Errorf("Read(nil) = %d, %v, want 0, nil", n, err)
+	}
+	if rd.Transformer().Stats().InputBytes != 0 {
+		t.Error("Read(nil) read from the source")
+	}
+}
@@ -0,0 +1,44 @@
+package sonic
+
+import (
+	"fmt"
+	"io"
+)
+
+var _ io.ReaderFrom = (*Transformer)(nil)
+
+// ReadFrom implements io.ReaderFrom, so that io.Copy(t, r) lets the transformer read the input in
+// chunks of its stream buffer, instead of writing whatever the buffer of io.Copy holds. It reads r
+// until io.EOF and returns the number of bytes written to the transformer.
+//
+// The chunks give the same output as writing all of r at once, whatever sizes the reads of r
+// return. A trailing incomplete frame of r is carried over like one given to Write, to be completed
+// by the next Write or ReadFrom. Errors of r are returned wrapped; an error wrapping ErrRecovered
+// is returned once all of r is written. Unlike CopyContext, ReadFrom does not flush the
+// transformer. It does not allocate after the first call.
+func (t *Transformer) ReadFrom(r io.Reader) (int64, error) {
+	if t.closed {
+		return 0, ErrClosed // Before r is read, so that no input is lost
+	}
+	if t.fromBuf == nil {
+		t.fromBuf = make([]byte, streamBufferFrames*t.FrameSize())
+	}
+	var written int64
+	var recovered error // Reported once all of r is written
+	for {
+		n, rerr := io.ReadFull(r, t.fromBuf)
+		if n > 0 {
+			m, err := t.Write(t.fromBuf[:n])
+			written += int64(m)
+			if deferRecovered(&recovered, err) != nil {
+				return written, err
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			return written, recovered
+		}
+		if rerr != nil {
+			return written, fmt.Errorf("failed to read input: %w", rerr)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// readerOnly hides the io.WriterTo of a reader, so that io.Copy uses io.ReaderFrom.
+type readerOnly struct {
+	io.Reader
+}
+
+func TestTransformer_ReadFrom(t *testing.T) {
+	speech := audiotest.Speech()
+	stereo := make([]int16, 2*len(speech))
+	for i, v := range speech {
+		stereo[2*i], stereo[2*i+1] = v, v/2
+	}
+	input := pcm.EncodeInt16(nil, stereo)
+	partial := append(input[:len(input):len(input)], 1, 2, 3) // Trailing incomplete frame
+	complete := append(partial[:len(partial):len(partial)], 4)
+
+	tests := []struct {
+		name string
+		r    func() io.Reader
+	}{
+		{"whole", func() io.Reader { return readerOnly{bytes.NewReader(partial)} }},
+		{"one byte", func() io.Reader { return iotest.OneByteReader(bytes.NewReader(partial)) }},
+		{"half", func() io.Reader { return iotest.HalfReader(bytes.NewReader(partial)) }},
+		{"data with EOF", func() io.Reader { return iotest.DataErrReader(bytes.NewReader(partial)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			ref, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithChannels(2), WithSpeed(1.7))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer ref.Close()
+			if _, err := ref.Write(complete); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := ref.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var got bytes.Buffer
+			tr, err := NewTransformer(&got, audiotest.SpeechSampleRate, AudioFormatPCM, WithChannels(2), WithSpeed(1.7))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			n, err := io.Copy(tr, tt.r())
+			if err != nil || n != int64(len(partial)) {
+				t.Errorf("io.Copy() = %d, %v, want %d, nil", n, err, len(partial))
+			}
+			// The incomplete frame is carried over to the next Write.
+			if _, err := tr.Write(complete[len(partial):]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("output = %d bytes, want the %d bytes of a single Write", got.Len(), want.Len())
+			}
+		})
+	}
+}
+
+func TestTransformer_ReadFrom_Errors(t *testing.T) {
+	errRead := errors.New("read failed")
+	input := make([]byte, 3*streamBufferFrames*2)
+
+	t.Run("read error", func(t *testing.T) {
+		tr, err := NewTransformer(io.Discard, 8000, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		r := io.MultiReader(bytes.NewReader(input[:1001]), iotest.ErrReader(errRead))
+		n, err := tr.ReadFrom(r)
+		if n != 1001 || !errors.Is(err, errRead) {
+			t.Errorf("ReadFrom() = %d, %v, want 1001, %v", n, err, errRead)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		tr, err := NewTransformer(&failingWriter{err: errors.New("write failed")}, 8000, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		n, err := tr.ReadFrom(bytes.NewReader(input))
+		if n >= int64(len(input)) || !errors.Is(err, ErrWrite) {
+			t.Errorf("ReadFrom() = %d, %v, want fewer than %d bytes and ErrWrite", n, err, len(input))
+		}
+	})
+}
+
+func TestTransformer_ReadFrom_NoAllocs(t *testing.T) {
+	input := audiotest.SpeechPCM()
+	tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	r := bytes.NewReader(input)
+	if _, err := tr.ReadFrom(r); err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	allocs := testing.AllocsPerRun(20, func() {
+		r.Reset(input)
+		if _, err := tr.ReadFrom(r); err != nil {
+			t.Fatalf("ReadFrom() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadFrom() allocates %v times per call, want 0", allocs)
+	}
+}
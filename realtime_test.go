@@ -0,0 +1,114 @@
+package sonic
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// TestTransformer_NoAllocs enforces the allocation-free guarantees documented on Transformer.
+func TestTransformer_NoAllocs(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	speechFloat := make([]byte, len(speech)*2)
+	for i := range len(speech) / 2 {
+		s := int16(uint16(speech[2*i]) | uint16(speech[2*i+1])<<8)
+		copy(speechFloat[4*i:], float32SliceAsLittleEndian([]float32{float32(s) / 32768}))
+	}
+	speechU8 := pcm.Int16ToUint8(nil, pcm.DecodeInt16(nil, speech))
+
+	tests := []struct {
+		name   string
+		format AudioFormat
+		input  []byte
+		opts   []Option
+	}{
+		{"int16", AudioFormatPCM, speech, nil},
+		{"float32", AudioFormatIEEEFloat, speechFloat, nil},
+		{"int16 speed", AudioFormatPCM, speech, []Option{WithSpeed(2.5), WithPitch(1.2)}},
+		{"float32 speed", AudioFormatIEEEFloat, speechFloat, []Option{WithSpeed(0.7)}},
+		{"int16 stereo", AudioFormatPCM, speech, []Option{WithChannels(2), WithSpeed(2.0)}},
+		{"int16 filters", AudioFormatPCM, speech, []Option{WithSpeed(0.5), WithConsonantEmphasis(1), WithChannelGains([]float32{2})}},
+		{"int16 select channels", AudioFormatPCM, speech, []Option{WithChannels(2), WithSelectChannels(1), WithSpeed(2.0)}},
+		{"int16 mid-side", AudioFormatPCM, speech, []Option{WithChannels(2), WithMidSide(), WithSpeed(2.0)}},
+		{"float32 mid-side", AudioFormatIEEEFloat, speechFloat, []Option{WithChannels(2), WithMidSide(), WithSpeed(2.0)}},
+		{"float32 to int16", AudioFormatIEEEFloat, speechFloat, []Option{WithSpeed(2.0), WithOutputFormat(AudioFormatPCM)}},
+		{"int16 to float32", AudioFormatPCM, speech, []Option{WithSpeed(2.0), WithOutputFormat(AudioFormatIEEEFloat)}},
+		{"U8 speed", AudioFormatU8, speechU8, []Option{WithSpeed(2.0), WithFadeOut(time.Second)}},
+		{"float32 to U8", AudioFormatIEEEFloat, speechFloat, []Option{WithSpeed(2.0), WithOutputFormat(AudioFormatU8)}},
+		{"int16 big-endian", AudioFormatPCM, speech, []Option{WithByteOrder(binary.BigEndian), WithSpeed(2.0)}},
+		{"float32 big-endian", AudioFormatIEEEFloat, speechFloat, []Option{WithByteOrder(binary.BigEndian), WithSpeed(0.7)}},
+		{"int16 startup ramp and fade-out", AudioFormatPCM, speech, []Option{WithSpeed(2.0), WithStartupRamp(time.Second), WithFadeOut(time.Second)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, tt.format, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			// Feed the speech in callback-sized chunks, looping over the input.
+			chunkSize := 256 * tr.numChannels * tt.format.SampleSize()
+			pos := 0
+			next := func() []byte {
+				if pos+chunkSize > len(tt.input) {
+					pos = 0
+				}
+				chunk := tt.input[pos : pos+chunkSize]
+				pos += chunkSize
+				return chunk
+			}
+
+			// Warm up so that libsonic and the mid-side buffers reach their steady-state sizes.
+			for range 200 {
+				if _, err := tr.Write(next()); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+
+			allocs := testing.AllocsPerRun(500, func() {
+				if _, err := tr.Write(next()); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				tr.InputPending()
+				tr.InputLatency()
+				tr.OutputAvailable()
+			})
+			if allocs != 0 {
+				t.Errorf("Write() allocates %v times per call, want 0", allocs)
+			}
+
+			allocs = testing.AllocsPerRun(50, func() {
+				if _, err := tr.Write(next()); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if err := tr.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("Flush() allocates %v times per call, want 0", allocs)
+			}
+		})
+	}
+}
+
+func TestSliceAsLittleEndian(t *testing.T) {
+	if got := int16SliceAsLittleEndian([]int16{0x0102, -2}); string(got) != "\x02\x01\xfe\xff" {
+		t.Errorf("int16SliceAsLittleEndian() = %x, want 0201feff", got)
+	}
+	if got := float32SliceAsLittleEndian([]float32{1.0}); string(got) != "\x00\x00\x80\x3f" {
+		t.Errorf("float32SliceAsLittleEndian() = %x, want 0000803f", got)
+	}
+	if got := int16SliceAsLittleEndian(nil); got != nil {
+		t.Errorf("int16SliceAsLittleEndian(nil) = %v, want nil", got)
+	}
+	if got := float32SliceAsLittleEndian(nil); got != nil {
+		t.Errorf("float32SliceAsLittleEndian(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,86 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Recovery represents how a transformer recovers from a failure of its stream.
+// See WithRecovery.
+type Recovery int
+
+// Constants for recovery modes
+const (
+	RecoveryNone  Recovery = iota // Return ErrSonicFailed and leave the stream as it is
+	RecoveryRetry                 // Recreate the stream and write the failed chunk again
+	RecoveryDrop                  // Recreate the stream and drop the failed chunk
+)
+
+// String returns the string representation of the Recovery.
+func (r Recovery) String() string {
+	m := map[Recovery]string{
+		RecoveryNone:  "RecoveryNone",
+		RecoveryRetry: "RecoveryRetry",
+		RecoveryDrop:  "RecoveryDrop",
+	}
+	if s, ok := m[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Recovery(%d)", r)
+}
+
+// Values returns the all possible values of Recovery.
+func (Recovery) Values() []Recovery {
+	return []Recovery{
+		RecoveryNone,
+		RecoveryRetry,
+		RecoveryDrop,
+	}
+}
+
+// recoverStream handles a failure of the stream to do what, e.g. "write samples to stream".
+// Unless recovery is disabled, it replaces the stream, or both streams in mid-side mode, with new
+// ones configured with the same settings, and returns an error wrapping ErrRecovered to report
+// once the call is done. Otherwise, or if the new stream cannot be created, it returns a fatal
+// error wrapping ErrSonicFailed.
+func (t *Transformer) recoverStream(what string, cause error) (recovered, fatal error) {
+	failure := fmt.Errorf("%w: failed to %s", ErrSonicFailed, what)
+	if cause != nil {
+		failure = fmt.Errorf("%w: failed to %s: %w", ErrSonicFailed, what, cause)
+	}
+	if t.recovery == RecoveryNone {
+		return nil, failure
+	}
+
+	if t.midSide != nil {
+		ms, err := newMidSide(t.engine, t.sampleRate, t.configureStream)
+		if err != nil {
+			return nil, fmt.Errorf("%w, and recreating the streams failed: %w", failure, err)
+		}
+		speed := t.midSide.mid.GetSpeed()
+		t.midSide.destroy()
+		t.midSide = ms
+		t.setStreamSpeed(speed) // Keep the speed of the startup ramp, if any
+	} else {
+		stream, err := t.engine.NewStream(t.sampleRate, t.streamChannels)
+		if err != nil {
+			return nil, fmt.Errorf("%w, and recreating the stream failed: %w", failure, err)
+		}
+		t.configureStream(stream)
+		speed := t.stream.GetSpeed()
+		t.stream.DestroyStream()
+		t.stream = stream
+		t.setStreamSpeed(speed) // Keep the speed of the startup ramp, if any
+	}
+	return fmt.Errorf("%w: the stream failed to %s and was recreated; its buffered input was lost", ErrRecovered, what), nil
+}
+
+// deferRecovered stores err in *recovered and returns nil if err wraps ErrRecovered, so that the
+// caller can finish its work before reporting it. Other errors are returned as they are.
+func deferRecovered(recovered *error, err error) error {
+	if errors.Is(err, ErrRecovered) {
+		*recovered = err
+		return nil
+	}
+	return err
+}
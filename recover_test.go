@@ -0,0 +1,128 @@
+package sonic_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/sonictest"
+)
+
+func TestTransformer_Recovery(t *testing.T) {
+	input := make([]int16, 3000) // More than one chunk of 2048 frames
+	for i := range input {
+		input[i] = int16(i)
+	}
+	failing := func(write, flush bool) *sonictest.Stream {
+		s := sonictest.NewStream()
+		s.FailWrite, s.FailFlush = write, flush
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		streams   []*sonictest.Stream
+		opts      []sonic.Option
+		wantWrite error
+		wantFlush error
+		want      []int16
+	}{
+		{
+			name:      "none",
+			streams:   []*sonictest.Stream{failing(true, false), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryNone)},
+			wantWrite: sonic.ErrSonicFailed,
+		},
+		{
+			name:      "retry",
+			streams:   []*sonictest.Stream{failing(true, false), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryRetry)},
+			wantWrite: sonic.ErrRecovered,
+			want:      input,
+		},
+		{
+			name:      "drop",
+			streams:   []*sonictest.Stream{failing(true, false), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryDrop)},
+			wantWrite: sonic.ErrRecovered,
+			want:      input[2048:],
+		},
+		{
+			name:      "retry fails again",
+			streams:   []*sonictest.Stream{failing(true, false), failing(true, false)},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryRetry)},
+			wantWrite: sonic.ErrSonicFailed,
+		},
+		{
+			name:      "recreate failure",
+			streams:   []*sonictest.Stream{failing(true, false)},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryDrop)},
+			wantWrite: sonic.ErrSonicFailed,
+		},
+		{
+			name:      "flush",
+			streams:   []*sonictest.Stream{failing(false, true), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryRetry)},
+			wantFlush: sonic.ErrRecovered,
+		},
+		{
+			name:      "mid-side retry",
+			streams:   []*sonictest.Stream{failing(true, false), sonictest.NewStream(), sonictest.NewStream(), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryRetry), sonic.WithChannels(2), sonic.WithMidSide()},
+			wantWrite: sonic.ErrRecovered,
+			want:      input,
+		},
+		{
+			name:      "aligned chunks",
+			streams:   []*sonictest.Stream{failing(true, false), sonictest.NewStream()},
+			opts:      []sonic.Option{sonic.WithRecovery(sonic.RecoveryDrop), sonic.WithAlignedChunks()},
+			wantWrite: sonic.ErrRecovered,
+			want:      input[2048:],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := append([]sonic.Option{sonic.WithStreamFactory(sonictest.Factory(tt.streams...)), sonic.WithSpeed(1.5)}, tt.opts...)
+			tr, err := sonic.NewTransformer(&out, 44100, sonic.AudioFormatPCM, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			p := pcm.EncodeInt16(nil, input)
+			n, err := tr.Write(p)
+			if !errors.Is(err, tt.wantWrite) || (err == nil) != (tt.wantWrite == nil) {
+				t.Fatalf("Write() error = %v, want %v", err, tt.wantWrite)
+			}
+			if errors.Is(err, sonic.ErrRecovered) && errors.Is(err, sonic.ErrSonicFailed) {
+				t.Errorf("Write() error = %v wraps both ErrRecovered and ErrSonicFailed", err)
+			}
+			if errors.Is(err, sonic.ErrSonicFailed) {
+				return
+			}
+			if n != len(p) {
+				t.Errorf("Write() = %d, want %d", n, len(p))
+			}
+			if err := tr.Flush(); !errors.Is(err, tt.wantFlush) || (err == nil) != (tt.wantFlush == nil) {
+				t.Fatalf("Flush() error = %v, want %v", err, tt.wantFlush)
+			}
+			if tt.want != nil {
+				if got := pcm.DecodeInt16(nil, out.Bytes()); !slices.Equal(got, tt.want) {
+					t.Errorf("output = %d samples, want %d", len(got), len(tt.want))
+				}
+			}
+
+			if !tt.streams[0].Destroyed {
+				t.Error("failed stream was not destroyed")
+			}
+			recreated := tt.streams[len(tt.streams)-1]
+			if recreated.Destroyed || recreated.Speed != 1.5 {
+				t.Errorf("recreated stream: destroyed = %v, speed = %v, want false and 1.5", recreated.Destroyed, recreated.Speed)
+			}
+		})
+	}
+}
@@ -2,14 +2,18 @@ package sonic
 
 import (
 	"bytes"
-	"encoding/binary"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/nakat-t/sonic-go/audiotest"
 	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/verify"
 )
 
 const (
@@ -19,6 +23,10 @@ const (
 	referenceWavDir = "./test/testdata/reference/"
 )
 
+// referenceFormats are the input formats tested against the reference audio. The reference audio
+// is 16-bit PCM, so float output is converted to int16 before it is compared.
+var referenceFormats = []AudioFormat{AudioFormatPCM, AudioFormatIEEEFloat}
+
 // TestReferenceVolume tests that volume modification matches the reference implementation
 func TestReferenceVolume(t *testing.T) {
 	volumeValues := []string{"0.01", "0.5", "1.0", "2.0", "100.0"}
@@ -28,9 +36,11 @@ func TestReferenceVolume(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to parse: %v", err)
 		}
-		t.Run("Volume_"+volumeStr, func(t *testing.T) {
-			testProcessedAudioMatchesReference(t, float32(volume), 1.0, 1.0, 0, "volume_"+volumeStr+".wav")
-		})
+		for _, format := range referenceFormats {
+			t.Run("Volume_"+volumeStr+"_"+format.String(), func(t *testing.T) {
+				testProcessedAudioMatchesReference(t, format, float32(volume), 1.0, 1.0, 0, "volume_"+volumeStr+".wav")
+			})
+		}
 	}
 }
 
@@ -43,9 +53,11 @@ func TestReferenceSpeed(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to parse: %v", err)
 		}
-		t.Run("Speed_"+speedStr, func(t *testing.T) {
-			testProcessedAudioMatchesReference(t, 1.0, float32(speed), 1.0, 0, "speed_"+speedStr+".wav")
-		})
+		for _, format := range referenceFormats {
+			t.Run("Speed_"+speedStr+"_"+format.String(), func(t *testing.T) {
+				testProcessedAudioMatchesReference(t, format, 1.0, float32(speed), 1.0, 0, "speed_"+speedStr+".wav")
+			})
+		}
 	}
 }
 
@@ -58,23 +70,110 @@ func TestReferencePitch(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to parse: %v", err)
 		}
-		t.Run("Pitch_"+pitchStr, func(t *testing.T) {
-			testProcessedAudioMatchesReference(t, 1.0, 1.0, float32(pitch), 0, "pitch_"+pitchStr+".wav")
-		})
+		for _, format := range referenceFormats {
+			t.Run("Pitch_"+pitchStr+"_"+format.String(), func(t *testing.T) {
+				testProcessedAudioMatchesReference(t, format, 1.0, 1.0, float32(pitch), 0, "pitch_"+pitchStr+".wav")
+			})
+		}
 	}
 }
 
 // TestReferenceQuality tests that quality setting matches the reference implementation
 func TestReferenceQuality(t *testing.T) {
-	t.Run("Quality_On", func(t *testing.T) {
-		testProcessedAudioMatchesReference(t, 1.0, 1.0, 1.0, 1, "quality_on.wav")
-	})
+	for _, format := range referenceFormats {
+		t.Run("Quality_On_"+format.String(), func(t *testing.T) {
+			testProcessedAudioMatchesReference(t, format, 1.0, 1.0, 1.0, 1, "quality_on.wav")
+		})
+	}
+}
+
+// TestFloatMatchesLibrary tests that the float code path produces the same samples as the float
+// API of the C library, fed in the chunks the transformer uses. Unlike the reference tests, it
+// needs no reference audio.
+func TestFloatMatchesLibrary(t *testing.T) {
+	tests := []struct {
+		name                       string
+		volume, speed, pitch, rate float32
+		quality                    int
+	}{
+		{"speed", 1.0, 2.0, 1.0, 1.0, 0},
+		{"slow", 1.0, 0.5, 1.0, 1.0, 0},
+		{"pitch", 1.0, 1.0, 1.5, 1.0, 0},
+		{"rate", 1.0, 1.0, 1.0, 0.8, 0},
+		{"volume", 0.5, 1.0, 1.0, 1.0, 0},
+		{"quality", 1.0, 1.5, 1.0, 1.0, 1},
+	}
+	samples := pcm.Int16ToFloat32(nil, audiotest.Speech(), int16Scaling)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := cgosonic.CreateStream(audiotest.SpeechSampleRate, 1)
+			if err != nil {
+				t.Fatalf("CreateStream() error = %v", err)
+			}
+			defer stream.DestroyStream()
+			stream.SetVolume(tt.volume)
+			stream.SetSpeed(tt.speed)
+			stream.SetPitch(tt.pitch)
+			stream.SetRate(tt.rate)
+			stream.SetQuality(tt.quality)
+
+			var want []float32
+			buf := make([]float32, streamBufferFrames)
+			readAll := func() {
+				for {
+					n := stream.ReadFloatFromStream(buf, len(buf))
+					want = append(want, buf[:n]...)
+					if n < len(buf) {
+						return
+					}
+				}
+			}
+			for chunk := range slices.Chunk(samples, streamBufferFrames) {
+				if stream.WriteFloatToStream(chunk, len(chunk)) == 0 {
+					t.Fatal("WriteFloatToStream() failed")
+				}
+				readAll()
+			}
+			if stream.FlushStream() == 0 {
+				t.Fatal("FlushStream() failed")
+			}
+			readAll()
+
+			opts := []Option{WithVolume(tt.volume), WithSpeed(tt.speed), WithPitch(tt.pitch), WithRate(tt.rate)}
+			if tt.quality != 0 {
+				opts = append(opts, WithQuality())
+			}
+			out := bytes.NewBuffer(nil)
+			transformer, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatIEEEFloat, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer transformer.Close()
+			if _, err := transformer.Write(pcm.EncodeFloat32(nil, samples)); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := transformer.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			got := pcm.DecodeFloat32(nil, out.Bytes())
+			if len(got) != len(want) {
+				t.Fatalf("Transformer produced %d samples, the C library %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("sample %d = %v, the C library produced %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
 }
 
-// testProcessedAudioMatchesReference verifies that audio processed with specified parameters matches the reference audio
-func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float32, quality int, referenceFileName string) {
+// testProcessedAudioMatchesReference verifies that audio processed in format with specified parameters matches the reference audio
+func testProcessedAudioMatchesReference(t *testing.T, format AudioFormat, volume, speed, pitch float32, quality int, referenceFileName string) {
 	t.Helper()
-	t.Logf("Testing volume=%v, speed=%v, pitch=%v, quality=%v, file=%v", volume, speed, pitch, quality, referenceFileName)
+	t.Logf("Testing format=%v, volume=%v, speed=%v, pitch=%v, quality=%v, file=%v", format, volume, speed, pitch, quality, referenceFileName)
 
 	const BUFFER_SIZE = 4096
 
@@ -101,6 +200,10 @@ func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float
 	}
 	fileIn.Close()
 	in.Next(44) // Skip the WAV header
+	if format == AudioFormatIEEEFloat {
+		samples := pcm.Int16ToFloat32(nil, pcm.DecodeInt16(nil, in.Bytes()), int16Scaling)
+		in = bytes.NewBuffer(pcm.EncodeFloat32(nil, samples))
+	}
 
 	opts := []Option{
 		WithSpeed(speed),
@@ -117,7 +220,7 @@ func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float
 	out := bytes.NewBuffer(nil)
 
 	// Create a Sonic instance
-	transformer, err := NewTransformer(out, sampleRate, AudioFormatPCM, opts...)
+	transformer, err := NewTransformer(out, sampleRate, format, opts...)
 	if err != nil {
 		t.Fatalf("Failed to create Sonic instance: %v", err)
 	}
@@ -129,9 +232,13 @@ func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float
 
 	transformer.Flush()
 
-	processedSamples := make([]int16, out.Len()/2)
-	if err := binary.Read(out, binary.LittleEndian, processedSamples); err != nil {
-		t.Fatalf("Failed to read processed samples: %v", err)
+	var processedSamples []int16
+	var processedFloats []float32
+	if format == AudioFormatIEEEFloat {
+		processedFloats = pcm.DecodeFloat32(nil, out.Bytes())
+		processedSamples = pcm.Float32ToInt16(nil, processedFloats, int16Scaling)
+	} else {
+		processedSamples = pcm.DecodeInt16(nil, out.Bytes())
 	}
 
 	// For Debug: Output processed wave file to 'test/testdata/processed/sonic/'
@@ -139,13 +246,23 @@ func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float
 		os.MkdirAll(filepath.Join(cwd, "./test/testdata/processed/sonic/"), 0755)
 
 		processedWavPath := filepath.Join(cwd, "./test/testdata/processed/sonic/", referenceFileName)
-		wfOut, err := cgosonic.OpenOutputWaveFile(processedWavPath, sampleRate, numChannels)
+		openOutput := cgosonic.OpenOutputWaveFile
+		if format == AudioFormatIEEEFloat {
+			processedWavPath = strings.TrimSuffix(processedWavPath, ".wav") + "_float.wav"
+			openOutput = cgosonic.OpenOutputFloatWaveFile
+		}
+		wfOut, err := openOutput(processedWavPath, sampleRate, numChannels)
 		if err != nil {
 			t.Fatalf("Failed to open output wave file: %v", err)
 		}
 		defer wfOut.CloseWaveFile()
 
-		okWritten := wfOut.WriteToWaveFile(processedSamples, len(processedSamples))
+		var okWritten int
+		if format == AudioFormatIEEEFloat {
+			okWritten = wfOut.WriteFloatToWaveFile(processedFloats, len(processedFloats)/numChannels)
+		} else {
+			okWritten = wfOut.WriteToWaveFile(processedSamples, len(processedSamples)/numChannels)
+		}
 		if okWritten == 0 {
 			t.Errorf("Failed to write all samples to output wave file")
 		}
@@ -195,48 +312,15 @@ func testProcessedAudioMatchesReference(t *testing.T, volume, speed, pitch float
 	// Trim reference buffer to actual size read
 	referenceBuffer = referenceBuffer[:numReferenceSamples]
 
-	// Compare sample counts
-	samplesAllowedDiffPercent := 1.0 // Allowable difference in sample count (1% of the smaller buffer)
-	samplesDiffPercent := float64(abs(len(processedSamples)-len(referenceBuffer))) / float64(min(len(processedSamples), len(referenceBuffer))) * 100.0
-	if samplesDiffPercent > samplesAllowedDiffPercent {
-		t.Errorf("Processed sample count differs from reference sample count: %.2f%% > %.2f%%",
-			samplesDiffPercent, samplesAllowedDiffPercent)
-		t.Logf("Processed samples: %d, Reference samples: %d", len(processedSamples), len(referenceBuffer))
-	}
-
-	// Compare sample content
-	// Due to potential minor differences in WAV encoding/decoding precision,
-	// check if the difference is within tolerance rather than requiring exact match
-	maxDiff := 0
-	maxDiffIndex := -1
-	maxSamplesToCompare := min(len(processedSamples), len(referenceBuffer))
-
-	const toleranceDiff = 5 // Tolerance threshold (absolute value)
-	differenceCount := 0
-
-	for i := range maxSamplesToCompare {
-		diff := abs(int(processedSamples[i]) - int(referenceBuffer[i]))
-		if diff > maxDiff {
-			maxDiff = diff
-			maxDiffIndex = i
-		}
-
-		if diff > toleranceDiff {
-			differenceCount++
-		}
-	}
-
-	// Test passes if percentage of samples outside tolerance is less than 1%
-	maxAllowedDiffPercent := 1.0
-	diffPercent := float64(differenceCount) / float64(maxSamplesToCompare) * 100.0
-
-	if diffPercent > maxAllowedDiffPercent {
-		t.Errorf("Difference between processed and reference samples exceeds tolerance: %.2f%% > %.2f%%",
-			diffPercent, maxAllowedDiffPercent)
-		t.Logf("Maximum difference: %d (index %d, processed=%d, reference=%d)",
-			maxDiff, maxDiffIndex, processedSamples[maxDiffIndex], referenceBuffer[maxDiffIndex])
+	// Compare the samples with the reference. Due to potential minor differences in WAV
+	// encoding/decoding precision, check if the difference is within tolerance rather than
+	// requiring an exact match.
+	c, err := verify.CompareSamples(processedSamples, referenceBuffer, verify.ReferenceTolerance)
+	if err != nil {
+		t.Errorf("Processed audio differs from reference: %v", err)
+		t.Logf("Processed samples: %d, Reference samples: %d", c.Samples, c.ReferenceSamples)
 	} else {
-		t.Logf("Sample comparison result: difference %.2f%%, maximum difference %d", diffPercent, maxDiff)
+		t.Logf("Sample comparison result: difference %.2f%%, maximum difference %d", c.Mismatch, c.MaxDiff)
 	}
 }
 
@@ -0,0 +1,168 @@
+package sonic
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Parameters of the resampling filter
+const (
+	resampleZeroCrossings = 64   // Zero crossings of the sinc on each side of the center
+	resamplePhases        = 256  // Entries of the filter table per zero crossing
+	resampleCutoff        = 0.95 // Cutoff relative to the lower Nyquist frequency
+	resampleKaiserBeta    = 8.6  // Shape of the Kaiser window, about 90 dB of stopband attenuation
+)
+
+// Resampler converts interleaved float32 samples from one sample rate to another with a
+// windowed-sinc polyphase filter, e.g. to deliver 44.1 kHz audio to a 48 kHz sink.
+//
+// The filter passes frequencies up to 90% of the lower Nyquist frequency of the two rates and
+// attenuates the frequencies beyond it by about 90 dB. Its delay is compensated, so that the output starts at the
+// time of the first input sample; the output is held back by the length of the filter until the
+// input after it arrives, and Flush delivers the rest. The output of n input frames has
+// ceil(n*outRate/inRate) frames.
+type Resampler struct {
+	numChannels int
+	up, down    int64     // outRate and inRate divided by their greatest common divisor
+	fc          float64   // Cutoff in units of the input Nyquist frequency
+	half        int       // Half the length of the filter in input frames
+	table       []float32 // Filter response at offsets of 1/resamplePhases zero crossings
+	coefs       []float32 // Filter taps of the current output frame
+	buf         []float32 // Input frames, starting at frame base
+	base        int64     // Input frame of the start of buf, negative for the leading silence
+	inFrames    int64     // Input frames written since the start or the last Flush
+	outFrames   int64     // Output frames produced since the start or the last Flush
+}
+
+// NewResampler creates a resampler for numChannels interleaved channels from inRate to outRate.
+// It returns an error wrapping ErrInvalid if a rate or the number of channels is not positive.
+func NewResampler(inRate, outRate, numChannels int) (*Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, fmt.Errorf("%w: sample rates %d and %d must be positive", ErrInvalid, inRate, outRate)
+	}
+	if numChannels <= 0 {
+		return nil, fmt.Errorf("%w: %d channels", ErrInvalid, numChannels)
+	}
+	g := gcd(inRate, outRate)
+	fc := resampleCutoff * math.Min(1, float64(outRate)/float64(inRate)) // In units of the input Nyquist
+	half := int(math.Ceil(resampleZeroCrossings / fc))
+	r := &Resampler{
+		numChannels: numChannels,
+		up:          int64(outRate / g),
+		down:        int64(inRate / g),
+		fc:          fc,
+		half:        half,
+		table:       make([]float32, resampleZeroCrossings*resamplePhases+1),
+		coefs:       make([]float32, 2*half),
+	}
+	for i := range r.table[:len(r.table)-1] {
+		x := float64(i) / resamplePhases // Offset in zero crossings
+		w := x / resampleZeroCrossings
+		kaiser := besselI0(resampleKaiserBeta*math.Sqrt(1-w*w)) / besselI0(resampleKaiserBeta)
+		r.table[i] = float32(fc * sinc(x) * kaiser)
+	}
+	r.Reset()
+	return r, nil
+}
+
+// Resample appends the output for the interleaved input frames of src to dst and returns the
+// extended slice. A trailing incomplete frame of src is ignored.
+func (r *Resampler) Resample(dst, src []float32) []float32 {
+	src = src[:len(src)-len(src)%r.numChannels]
+	r.buf = append(r.buf, src...)
+	r.inFrames += int64(len(src) / r.numChannels)
+	return r.drain(dst, r.base+int64(len(r.buf)/r.numChannels), r.inFrames*r.up)
+}
+
+// Flush appends the output held back for the input written so far to dst, and returns the
+// extended slice. The resampler starts over afterwards, as if it were new.
+func (r *Resampler) Flush(dst []float32) []float32 {
+	// The input is followed by silence.
+	available := r.base + int64(len(r.buf)/r.numChannels) + int64(r.half)
+	dst = r.drain(dst, available, r.inFrames*r.up)
+	r.Reset()
+	return dst
+}
+
+// Reset discards the input written so far, as if the resampler were new.
+func (r *Resampler) Reset() {
+	n := (r.half - 1) * r.numChannels
+	r.buf = slices.Grow(r.buf[:0], n)[:n]
+	clear(r.buf)
+	r.base = int64(1 - r.half)
+	r.inFrames = 0
+	r.outFrames = 0
+}
+
+// drain appends the output frames that need no input frames beyond available, and that start
+// before end, in units of 1/up input frames, to dst. Input beyond the end of buf is silence.
+func (r *Resampler) drain(dst []float32, available, end int64) []float32 {
+	frames := r.base + int64(len(r.buf)/r.numChannels) // End of buf
+	for {
+		pos := r.outFrames * r.down // In units of 1/up input frames
+		center := pos / r.up
+		if pos >= end || center+int64(r.half) >= available {
+			break
+		}
+		// Taps for the input frames center-half+1 to center+half.
+		offset := float64(pos%r.up) / float64(r.up)
+		for k := range r.coefs {
+			x := math.Abs(float64(k-r.half+1)-offset) * r.fc * resamplePhases
+			i := int(x)
+			if i >= len(r.table)-1 {
+				r.coefs[k] = 0 // Beyond the window
+				continue
+			}
+			f := float32(x - float64(i))
+			r.coefs[k] = r.table[i] + f*(r.table[i+1]-r.table[i])
+		}
+		first := center - int64(r.half) + 1
+		for ch := range r.numChannels {
+			var sum float32
+			for k, c := range r.coefs {
+				frame := first + int64(k)
+				if frame >= frames {
+					break // Silence after the end of the input
+				}
+				sum += c * r.buf[int(frame-r.base)*r.numChannels+ch]
+			}
+			dst = append(dst, sum)
+		}
+		r.outFrames++
+	}
+	// Keep the input frames needed by the next output frame.
+	next := (r.outFrames*r.down)/r.up - int64(r.half) + 1
+	if drop := min(int(next-r.base), len(r.buf)/r.numChannels); drop > 0 {
+		r.buf = r.buf[:copy(r.buf, r.buf[drop*r.numChannels:])]
+		r.base += int64(drop)
+	}
+	return dst
+}
+
+// sinc returns the normalized sinc function sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 returns the modified Bessel function of the first kind of order 0, by its power
+// series.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,240 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// sineFloat32 returns frames frames of a sine of freq Hz at sampleRate with amplitude 0.5.
+func sineFloat32(freq float64, sampleRate, frames int) []float32 {
+	s := make([]float32, frames)
+	for i := range s {
+		s[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return s
+}
+
+// toneLevel returns the amplitude of the component of samples at freq Hz, measured by correlation
+// with a sine and a cosine.
+func toneLevel(samples []float32, freq float64, sampleRate int) float64 {
+	var re, im float64
+	for i, s := range samples {
+		phase := 2 * math.Pi * freq * float64(i) / float64(sampleRate)
+		re += float64(s) * math.Cos(phase)
+		im += float64(s) * math.Sin(phase)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(samples))
+}
+
+func TestNewResampler(t *testing.T) {
+	tests := []struct {
+		inRate, outRate, numChannels int
+		wantErr                      bool
+	}{
+		{44100, 48000, 2, false},
+		{48000, 8000, 1, false},
+		{0, 48000, 1, true},
+		{44100, -1, 1, true},
+		{44100, 48000, 0, true},
+	}
+	for _, tt := range tests {
+		_, err := NewResampler(tt.inRate, tt.outRate, tt.numChannels)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalid)) {
+			t.Errorf("NewResampler(%d, %d, %d) error = %v, wantErr %v", tt.inRate, tt.outRate, tt.numChannels, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	tests := []struct {
+		inRate, outRate int
+		freq            float64 // Frequency of the tone, which passes the filter
+		alias           float64 // Frequency of a tone above the lower Nyquist frequency, or 0
+	}{
+		{44100, 48000, 1000, 0},
+		{48000, 44100, 15000, 23000},
+		{16000, 48000, 5000, 0},
+		{48000, 8000, 3000, 6000},
+		{22050, 22050, 9900, 0},
+		{8000, 8001, 440, 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d to %d", tt.inRate, tt.outRate), func(t *testing.T) {
+			r, err := NewResampler(tt.inRate, tt.outRate, 1)
+			if err != nil {
+				t.Fatalf("NewResampler() error = %v", err)
+			}
+			input := sineFloat32(tt.freq, tt.inRate, tt.inRate)
+			var out []float32
+			for i := 0; i < len(input); i += 1000 {
+				out = r.Resample(out, input[i:min(i+1000, len(input))])
+			}
+			out = r.Flush(out)
+			if want := int(math.Ceil(float64(len(input)) * float64(tt.outRate) / float64(tt.inRate))); len(out) != want {
+				t.Fatalf("output = %d frames, want %d", len(out), want)
+			}
+			// The tone keeps its level and frequency; the edges are left out, where the input
+			// starts and ends abruptly.
+			body := out[len(out)/10 : len(out)*9/10]
+			if level := toneLevel(body, tt.freq, tt.outRate); math.Abs(level-0.5) > 0.005 {
+				t.Errorf("tone level = %.4f, want 0.5", level)
+			}
+			// The output matches the tone at every output sample time.
+			want := sineFloat32(tt.freq, tt.outRate, len(out))
+			for i := len(out) / 10; i < len(out)*9/10; i++ {
+				if d := math.Abs(float64(out[i] - want[i])); d > 0.005 {
+					t.Fatalf("output sample %d = %v, want %v", i, out[i], want[i])
+				}
+			}
+
+			if tt.alias != 0 {
+				r.Reset()
+				out := r.Flush(r.Resample(nil, sineFloat32(tt.alias, tt.inRate, tt.inRate)))
+				if level := toneLevel(out[len(out)/10:len(out)*9/10], float64(tt.outRate)-tt.alias, tt.outRate); level > 0.5e-3 {
+					t.Errorf("alias level = %.6f, want below -60 dB", level)
+				}
+			}
+		})
+	}
+}
+
+func TestResampler_Chunks(t *testing.T) {
+	// The output does not depend on how the input is split, and Flush starts over.
+	r, err := NewResampler(44100, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewResampler() error = %v", err)
+	}
+	input := make([]float32, 2*4410)
+	for i := range input {
+		input[i] = float32(math.Sin(float64(i) / 7))
+	}
+	want := r.Flush(r.Resample(nil, input))
+	for _, chunk := range []int{2, 14, 1000} {
+		var got []float32
+		for i := 0; i < len(input); i += chunk {
+			got = r.Resample(got, input[i:min(i+chunk, len(input))])
+		}
+		got = r.Flush(got)
+		if len(got) != len(want) {
+			t.Fatalf("chunk %d: output = %d samples, want %d", chunk, len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("chunk %d: output sample %d = %v, want %v", chunk, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestTransformer_OutputSampleRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  int
+		format      AudioFormat
+		numChannels int
+		outRate     int
+		outFormat   AudioFormat
+	}{
+		{"PCM 44100 to 48000", 44100, AudioFormatPCM, 1, 48000, AudioFormatPCM},
+		{"float 48000 to 44100", 48000, AudioFormatIEEEFloat, 1, 44100, AudioFormatIEEEFloat},
+		{"stereo PCM24 16000 to 48000", 16000, AudioFormatPCM24, 2, 48000, AudioFormatPCM},
+		{"same rate", 22050, AudioFormatIEEEFloat, 1, 22050, AudioFormatIEEEFloat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tone := sineFloat32(1000, tt.sampleRate, tt.sampleRate) // 1 second
+			input := make([]float32, 0, len(tone)*tt.numChannels)
+			for _, s := range tone {
+				for range tt.numChannels {
+					input = append(input, s)
+				}
+			}
+			var p []byte
+			switch tt.format {
+			case AudioFormatPCM:
+				p = int16SliceAsLittleEndian(pcm.Float32ToInt16(nil, input, int16Scaling))
+			case AudioFormatPCM24:
+				p = pcm.Float32ToInt24(nil, input, int16Scaling)
+			default:
+				p = pcm.EncodeFloat32(nil, input)
+			}
+
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, tt.sampleRate, tt.format, WithChannels(tt.numChannels),
+				WithOutputSampleRate(tt.outRate), WithOutputFormat(tt.outFormat))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if got := tr.OutputSampleRate(); got != tt.outRate {
+				t.Errorf("OutputSampleRate() = %d, want %d", got, tt.outRate)
+			}
+			if got, want := tr.OutputSamplesForInput(len(input)), tt.outRate*tt.numChannels; got != want {
+				t.Errorf("OutputSamplesForInput() = %d, want %d", got, want)
+			}
+			if _, err := tr.Write(p); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var output []float32
+			if tt.outFormat == AudioFormatPCM {
+				output = pcm.Int16ToFloat32(nil, pcm.DecodeInt16(nil, out.Bytes()), int16Scaling)
+			} else {
+				output = pcm.DecodeFloat32(nil, out.Bytes())
+			}
+			frames := len(output) / tt.numChannels
+			if math.Abs(float64(frames-tt.outRate)) > float64(tt.outRate)/100 {
+				t.Errorf("output = %d frames, want about %d", frames, tt.outRate)
+			}
+			for ch := range tt.numChannels {
+				channel := make([]float32, 0, frames)
+				for i := ch; i < len(output); i += tt.numChannels {
+					channel = append(channel, output[i])
+				}
+				body := channel[frames/10 : frames*9/10]
+				if level := toneLevel(body, 1000, tt.outRate); math.Abs(level-0.5) > 0.01 {
+					t.Errorf("channel %d: tone level at %d Hz = %.4f, want 0.5", ch, tt.outRate, level)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformer_OutputSampleRateTiming(t *testing.T) {
+	// The fixed latency and the fades are measured at the resampled rate.
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 44100, AudioFormatIEEEFloat, WithOutputSampleRate(48000),
+		WithFixedLatency(50*time.Millisecond), WithFadeIn(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if tr.latencyFrames != 2400 || tr.fadeInFrames != 480 {
+		t.Errorf("latency = %d frames, fade-in = %d frames, want 2400 and 480", tr.latencyFrames, tr.fadeInFrames)
+	}
+	if _, err := tr.Write(pcm.EncodeFloat32(nil, sineFloat32(1000, 44100, 44100))); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	// The output due for 1 second of input at the resampled rate, including the latency.
+	if got := out.Len() / tr.OutputFrameSize(); got != 48000 {
+		t.Errorf("output after Write = %d frames, want 48000", got)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	output := pcm.DecodeFloat32(nil, out.Bytes())
+	for i, s := range output[:2400] {
+		if s != 0 {
+			t.Fatalf("output sample %d = %v during the latency, want 0", i, s)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Resume prepares a new transformer to continue the processing of an input at the byte offset,
+// e.g. after an interrupted resumable upload or download, and returns the offset at which the
+// caller must continue writing the input.
+//
+// The offset is aligned down to a frame boundary, so the rest of a frame torn by the interruption
+// is written again. The input before the aligned offset is read from src, which starts at the
+// beginning of the input, and replayed through the transformer to rebuild its state. The output
+// of the replay is not delivered, since it was delivered before the interruption; it is counted
+// in Stats, and hashed by WithOutputHash, so that Stats().OutputBytes is the offset in the output
+// at which the continuation starts. The replayed input is not written to the input tee.
+//
+// With WithAlignedChunks, the output of a transformer depends only on its input, not on how the
+// input was split into writes, so the continuation is exactly the output that the interrupted
+// transformer would have produced after the aligned offset. Without it, the continuation may
+// differ slightly, as by writing the input in other sizes. The interrupted transformer must not
+// have been flushed before the offset, and the transformer must have the same parameters, i.e.
+// the same Fingerprint.
+//
+// Resume must be called before the first Write; otherwise, it returns an error wrapping
+// ErrInvalid. If src ends before the aligned offset, it returns an error wrapping
+// io.ErrUnexpectedEOF.
+func (t *Transformer) Resume(src io.Reader, offset int64) (int64, error) {
+	if t.closed {
+		return 0, ErrClosed
+	}
+	defer t.spendWallClock(t.clock.Now())
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: resume offset %d is negative", ErrInvalid, offset)
+	}
+	if t.inputBytes != 0 || t.outputBytes != 0 {
+		return 0, fmt.Errorf("%w: transformer was written to before Resume", ErrInvalid)
+	}
+	aligned := offset - offset%int64(t.FrameSize())
+
+	t.replaying = true
+	defer func() { t.replaying = false }()
+	buf := make([]byte, streamBufferFrames*t.FrameSize())
+	for replayed := int64(0); replayed < aligned; {
+		chunk := buf
+		if rest := aligned - replayed; rest < int64(len(chunk)) {
+			chunk = chunk[:rest]
+		}
+		n, err := io.ReadFull(src, chunk)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("%w: input ends at %d before the resume offset %d", io.ErrUnexpectedEOF, replayed+int64(n), aligned)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the input to resume: %w", err)
+		}
+		written, err := t.writeInput(chunk)
+		t.recordInput(chunk[:written])
+		if err != nil {
+			return 0, err
+		}
+		replayed += int64(written)
+	}
+	return aligned, nil
+}
@@ -0,0 +1,153 @@
+package sonic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_Resume(t *testing.T) {
+	speech := audiotest.Speech()[:2*audiotest.SpeechSampleRate]
+	stereo := make([]int16, 2*len(speech))
+	for i, s := range speech {
+		stereo[2*i], stereo[2*i+1] = s, -s/2
+	}
+
+	tests := []struct {
+		name   string
+		format AudioFormat
+		input  []byte
+		offset int64
+		opts   []Option
+	}{
+		{"mono PCM", AudioFormatPCM, pcm.EncodeInt16(nil, speech), 50001, []Option{WithSpeed(1.5)}},
+		{"stereo PCM torn frame", AudioFormatPCM, pcm.EncodeInt16(nil, stereo), 100003, []Option{WithChannels(2), WithSpeed(0.75)}},
+		{"float", AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, int16Scaling)), 200002, []Option{WithSpeed(2), WithFadeIn(20 * time.Millisecond)}},
+		{"chunk boundary", AudioFormatPCM, pcm.EncodeInt16(nil, speech), 4 * 2048 * 2, []Option{WithSpeed(1.5)}},
+		{"start", AudioFormatPCM, pcm.EncodeInt16(nil, speech), 1, []Option{WithSpeed(1.5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append(tt.opts, WithAlignedChunks(), WithOutputHash(sha256.New()))
+			// write writes p to tr in writes of chunk bytes.
+			write := func(tr *Transformer, p []byte, chunk int) {
+				t.Helper()
+				for len(p) > 0 {
+					n := min(len(p), chunk)
+					if m, err := tr.Write(p[:n]); err != nil || m != n {
+						t.Fatalf("Write() = %d, %v, want %d", m, err, n)
+					}
+					p = p[n:]
+				}
+			}
+
+			// The interrupted transformer consumed the input up to the aligned offset when it
+			// delivered its last output.
+			var want bytes.Buffer
+			orig, err := NewTransformer(&want, audiotest.SpeechSampleRate, tt.format, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer orig.Close()
+			aligned := tt.offset - tt.offset%int64(orig.FrameSize())
+			write(orig, tt.input[:aligned], 1000*orig.FrameSize())
+			delivered := int64(want.Len())
+			write(orig, tt.input[aligned:], 333*orig.FrameSize())
+			if err := orig.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var got bytes.Buffer
+			tr, err := NewTransformer(&got, audiotest.SpeechSampleRate, tt.format, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			resumed, err := tr.Resume(bytes.NewReader(tt.input), tt.offset)
+			if err != nil {
+				t.Fatalf("Resume() error = %v", err)
+			}
+			if resumed != aligned {
+				t.Errorf("Resume() = %d, want %d", resumed, aligned)
+			}
+			if got.Len() != 0 {
+				t.Errorf("Resume() delivered %d bytes, want 0", got.Len())
+			}
+			if s := tr.Stats(); s.InputBytes != aligned || s.OutputBytes != delivered {
+				t.Errorf("Stats() after Resume() = %d input and %d output bytes, want %d and %d", s.InputBytes, s.OutputBytes, aligned, delivered)
+			}
+			write(tr, tt.input[resumed:], 777*tr.FrameSize())
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if !bytes.Equal(got.Bytes(), want.Bytes()[delivered:]) {
+				t.Errorf("continuation = %d bytes, want the %d bytes of the uninterrupted output after %d", got.Len(), int64(want.Len())-delivered, delivered)
+			}
+			if g, w := tr.Stats().OutputSum, orig.Stats().OutputSum; !bytes.Equal(g, w) {
+				t.Errorf("Stats().OutputSum = %x, want %x", g, w)
+			}
+		})
+	}
+}
+
+func TestTransformer_ResumeErrors(t *testing.T) {
+	input := make([]byte, 4000)
+	newTransformer := func(t *testing.T) *Transformer {
+		t.Helper()
+		tr, err := NewTransformer(io.Discard, 8000, AudioFormatPCM, WithSpeed(1.5), WithAlignedChunks())
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		t.Cleanup(func() { tr.Close() })
+		return tr
+	}
+
+	t.Run("negative offset", func(t *testing.T) {
+		if _, err := newTransformer(t).Resume(bytes.NewReader(input), -2); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Resume() error = %v, want %v", err, ErrInvalid)
+		}
+	})
+	t.Run("after write", func(t *testing.T) {
+		tr := newTransformer(t)
+		if _, err := tr.Write(input[:2]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if _, err := tr.Resume(bytes.NewReader(input), 2); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Resume() error = %v, want %v", err, ErrInvalid)
+		}
+	})
+	t.Run("short input", func(t *testing.T) {
+		if _, err := newTransformer(t).Resume(bytes.NewReader(input), 5000); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Resume() error = %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+	t.Run("read error", func(t *testing.T) {
+		errRead := errors.New("connection reset")
+		r := io.MultiReader(bytes.NewReader(input[:100]), iotest.ErrReader(errRead))
+		if _, err := newTransformer(t).Resume(r, 2000); !errors.Is(err, errRead) {
+			t.Errorf("Resume() error = %v, want %v", err, errRead)
+		}
+	})
+	t.Run("input tee", func(t *testing.T) {
+		var tee bytes.Buffer
+		tr, err := NewTransformer(io.Discard, 8000, AudioFormatPCM, WithInputTee(&tee))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Resume(bytes.NewReader(input), 2000); err != nil {
+			t.Fatalf("Resume() error = %v", err)
+		}
+		if tee.Len() != 0 {
+			t.Errorf("Resume() wrote %d bytes to the input tee, want 0", tee.Len())
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OverloadPolicy represents what a RingWriter does when its buffer is full, because the
+// transformer processes the input slower than it arrives, e.g. on an under-provisioned CPU.
+// See NewRingWriter.
+type OverloadPolicy int
+
+// Constants for overload policies
+const (
+	OverloadBlock      OverloadPolicy = iota // Wait in Write until the transformer makes room
+	OverloadDropOldest                       // Drop the oldest buffered input to make room
+	OverloadDropNewest                       // Drop the new input that does not fit
+)
+
+// String returns the string representation of the OverloadPolicy.
+func (p OverloadPolicy) String() string {
+	m := map[OverloadPolicy]string{
+		OverloadBlock:      "OverloadBlock",
+		OverloadDropOldest: "OverloadDropOldest",
+		OverloadDropNewest: "OverloadDropNewest",
+	}
+	if s, ok := m[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("OverloadPolicy(%d)", p)
+}
+
+// Values returns the all possible values of OverloadPolicy.
+func (OverloadPolicy) Values() []OverloadPolicy {
+	return []OverloadPolicy{
+		OverloadBlock,
+		OverloadDropOldest,
+		OverloadDropNewest,
+	}
+}
+
+// RingWriter decouples a live producer, e.g. an audio capture callback, from the processing of a
+// transformer: Write copies the input into a ring buffer and returns, and a goroutine writes the
+// buffered input to the transformer.
+//
+// When the transformer falls behind the input by the capacity of the buffer, Write applies the
+// OverloadPolicy. The frames dropped by the policy are counted in Stats().DroppedFrames, so that
+// live systems can detect and alert on overload. Write, Stats and Close may be called
+// concurrently; the transformer must not be used directly until Close returns.
+type RingWriter struct {
+	t      *Transformer
+	policy OverloadPolicy
+	mu     sync.Mutex
+	cond   *sync.Cond // Signaled when input is buffered or taken, on errors and on Close
+	buf    []byte     // Buffered input, starting at start and wrapping around
+	start  int
+	size   int
+	closed bool
+	err    error         // Error of the transformer, returned by the next Write or Close
+	stats  Stats         // Counters of the transformer after its last write, see Stats
+	done   chan struct{} // Closed when the goroutine returns
+}
+
+// NewRingWriter creates a ring writer that buffers up to capacity of input for t, and starts its
+// goroutine. The capacity is rounded down to whole frames. It returns an error wrapping
+// ErrInvalid if capacity is shorter than a frame or policy is unknown.
+func NewRingWriter(t *Transformer, capacity time.Duration, policy OverloadPolicy) (*RingWriter, error) {
+	if !slices.Contains(policy.Values(), policy) {
+		return nil, fmt.Errorf("%w: overload policy %v is not supported", ErrInvalid, policy)
+	}
+	frames := t.SamplesForDuration(capacity) / t.numChannels
+	if frames < 1 {
+		return nil, fmt.Errorf("%w: ring buffer capacity %v is shorter than a frame", ErrInvalid, capacity)
+	}
+	w := &RingWriter{
+		t:      t,
+		policy: policy,
+		buf:    make([]byte, frames*t.FrameSize()),
+		stats:  t.Stats(),
+		done:   make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w, nil
+}
+
+// Write buffers the whole frames of p for the transformer. It returns an error wrapping
+// ErrInvalid if p is not made of whole frames, and io.ErrClosedPipe after Close.
+//
+// Input dropped by the overload policy counts as written, so that a live producer is not stopped
+// by a short write. An error of the transformer is returned by the next Write and stops the
+// processing, except an error wrapping ErrRecovered, which is returned once.
+func (w *RingWriter) Write(p []byte) (int, error) {
+	frameSize := w.t.FrameSize()
+	if len(p)%frameSize != 0 {
+		return 0, fmt.Errorf("%w: write of %d bytes is not a multiple of the frame size %d", ErrInvalid, len(p), frameSize)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if err := w.takeError(); err != nil {
+		return 0, err
+	}
+
+	n := len(p)
+	switch w.policy {
+	case OverloadDropOldest:
+		if skip := len(p) - len(w.buf); skip > 0 {
+			w.drop(skip)
+			p = p[skip:]
+		}
+		if evict := len(p) - (len(w.buf) - w.size); evict > 0 {
+			w.drop(evict)
+			w.start = (w.start + evict) % len(w.buf)
+			w.size -= evict
+		}
+	case OverloadDropNewest:
+		if room := len(w.buf) - w.size; len(p) > room {
+			w.drop(len(p) - room)
+			p = p[:room]
+		}
+	}
+	for len(p) > 0 {
+		if w.closed {
+			return n - len(p), io.ErrClosedPipe
+		}
+		if err := w.takeError(); err != nil {
+			return n - len(p), err
+		}
+		if w.size == len(w.buf) {
+			w.cond.Wait()
+			continue
+		}
+		p = p[w.push(p):]
+		w.cond.Broadcast()
+	}
+	return n, nil
+}
+
+// takeError returns the error to report to the caller, if any. w.mu must be held.
+func (w *RingWriter) takeError() error {
+	err := w.err
+	if errors.Is(err, ErrRecovered) {
+		w.err = nil
+	}
+	return err
+}
+
+// drop counts n bytes of dropped input.
+func (w *RingWriter) drop(n int) {
+	w.t.droppedFrames.Add(int64(n / w.t.FrameSize()))
+}
+
+// push copies as much of p as fits into the buffer and returns the number of bytes copied.
+// w.mu must be held.
+func (w *RingWriter) push(p []byte) int {
+	end := (w.start + w.size) % len(w.buf)
+	n := copy(w.buf[end:min(len(w.buf), end+len(w.buf)-w.size)], p)
+	n += copy(w.buf[:len(w.buf)-w.size-n], p[n:])
+	w.size += n
+	return n
+}
+
+// pop moves up to len(p) bytes of the oldest buffered input into p and returns their number.
+// w.mu must be held.
+func (w *RingWriter) pop(p []byte) int {
+	n := copy(p[:min(len(p), w.size)], w.buf[w.start:])
+	n += copy(p[n:min(len(p), w.size)], w.buf)
+	w.start = (w.start + n) % len(w.buf)
+	w.size -= n
+	return n
+}
+
+// run writes the buffered input to the transformer until the writer is closed and the buffer is
+// drained, or the transformer fails.
+func (w *RingWriter) run() {
+	defer close(w.done)
+	chunk := make([]byte, min(len(w.buf), streamBufferFrames*w.t.FrameSize()))
+	for {
+		w.mu.Lock()
+		for w.size == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		n := w.pop(chunk)
+		w.cond.Broadcast()
+		w.mu.Unlock()
+		if n == 0 {
+			return
+		}
+
+		_, err := w.t.Write(chunk[:n])
+		stats := w.t.Stats()
+		w.mu.Lock()
+		w.stats = stats
+		if err != nil && (w.err == nil || errors.Is(w.err, ErrRecovered)) {
+			w.err = err
+			w.cond.Broadcast()
+		}
+		w.mu.Unlock()
+		if err != nil && !errors.Is(err, ErrRecovered) {
+			return
+		}
+	}
+}
+
+// Stats returns the counters of the transformer as of its last write, and the frames dropped by
+// the overload policy so far. Unlike Transformer.Stats, it may be called while the ring writer is
+// running, and does not wait for a write in progress, so that overload can be observed while the
+// transformer lags.
+func (w *RingWriter) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.stats
+	s.DroppedFrames = w.t.droppedFrames.Load()
+	return s
+}
+
+// Close waits until the buffered input is written to the transformer, flushes it and returns the
+// first error of the transformer. It does not close the transformer. Calling Close again returns
+// nil.
+func (w *RingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.done
+
+	w.mu.Lock()
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+	if err != nil && !errors.Is(err, ErrRecovered) {
+		return err
+	}
+	ferr := w.t.Flush()
+	stats := w.t.Stats()
+	w.mu.Lock()
+	w.stats = stats
+	w.mu.Unlock()
+	if ferr != nil {
+		return ferr
+	}
+	return err
+}
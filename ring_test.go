@@ -0,0 +1,193 @@
+package sonic_test
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/sonictest"
+)
+
+// ramp returns the samples from first up to but not including last.
+func ramp(first, last int16) []int16 {
+	s := make([]int16, 0, last-first)
+	for v := first; v < last; v++ {
+		s = append(s, v)
+	}
+	return s
+}
+
+func TestRingWriter(t *testing.T) {
+	var out []byte
+	tr, err := sonic.NewTransformer(nil, 8000, sonic.AudioFormatPCM,
+		sonic.WithStreamFactory(sonictest.Factory(sonictest.NewStream())),
+		sonic.WithOutputFunc(func(p []byte) error {
+			out = append(out, p...)
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	w, err := sonic.NewRingWriter(tr, 10*time.Millisecond, sonic.OverloadBlock)
+	if err != nil {
+		t.Fatalf("NewRingWriter() error = %v", err)
+	}
+	input := pcm.EncodeInt16(nil, ramp(0, 5000))
+	for chunk := range slices.Chunk(input, 2*30) {
+		if n, err := w.Write(chunk); n != len(chunk) || err != nil {
+			t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(chunk))
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !slices.Equal(out, input) {
+		t.Errorf("output = %d bytes, want the %d bytes of the input", len(out), len(input))
+	}
+	if s := w.Stats(); s.InputBytes != int64(len(input)) || s.DroppedFrames != 0 {
+		t.Errorf("Stats() = %+v, want InputBytes %d and no dropped frames", s, len(input))
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := w.Write(input[:2]); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write() after Close error = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestRingWriter_Overload(t *testing.T) {
+	// The buffer holds 80 frames. The first write is taken by the goroutine, which then blocks in
+	// the output function until the overload is set up.
+	tests := []struct {
+		policy      sonic.OverloadPolicy
+		want        []int16
+		wantDropped int64
+	}{
+		{sonic.OverloadBlock, ramp(0, 320), 0},
+		{sonic.OverloadDropOldest, slices.Concat(ramp(0, 80), ramp(240, 320)), 160},
+		{sonic.OverloadDropNewest, ramp(0, 160), 160},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			var out []byte
+			var once sync.Once
+			entered, gate := make(chan struct{}), make(chan struct{})
+			tr, err := sonic.NewTransformer(nil, 8000, sonic.AudioFormatPCM,
+				sonic.WithStreamFactory(sonictest.Factory(sonictest.NewStream())),
+				sonic.WithOutputFunc(func(p []byte) error {
+					once.Do(func() {
+						close(entered)
+						<-gate
+					})
+					out = append(out, p...)
+					return nil
+				}))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			w, err := sonic.NewRingWriter(tr, 10*time.Millisecond, tt.policy)
+			if err != nil {
+				t.Fatalf("NewRingWriter() error = %v", err)
+			}
+
+			if _, err := w.Write(pcm.EncodeInt16(nil, ramp(0, 80))); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			<-entered
+			done := make(chan error)
+			go func() {
+				for _, p := range [][]int16{ramp(80, 280), ramp(280, 320)} {
+					if n, err := w.Write(pcm.EncodeInt16(nil, p)); n != 2*len(p) || err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- nil
+			}()
+			if tt.policy == sonic.OverloadBlock {
+				select {
+				case <-done:
+					t.Error("Write() returned while the buffer was full")
+				case <-time.After(20 * time.Millisecond):
+				}
+			} else {
+				if err := <-done; err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if got := w.Stats().DroppedFrames; got != tt.wantDropped {
+					t.Errorf("DroppedFrames while running = %d, want %d", got, tt.wantDropped)
+				}
+			}
+			close(gate)
+			if tt.policy == sonic.OverloadBlock {
+				if err := <-done; err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := pcm.DecodeInt16(nil, out); !slices.Equal(got, tt.want) {
+				t.Errorf("output = %v, want %v", got, tt.want)
+			}
+			if got := tr.Stats().DroppedFrames; got != tt.wantDropped {
+				t.Errorf("DroppedFrames = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRingWriter_Errors(t *testing.T) {
+	newTransformer := func(t *testing.T, s *sonictest.Stream) *sonic.Transformer {
+		t.Helper()
+		tr, err := sonic.NewTransformer(nil, 8000, sonic.AudioFormatPCM,
+			sonic.WithStreamFactory(sonictest.Factory(s)),
+			sonic.WithOutputFunc(func([]byte) error { return nil }))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		t.Cleanup(func() { tr.Close() })
+		return tr
+	}
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		tr := newTransformer(t, sonictest.NewStream())
+		if _, err := sonic.NewRingWriter(tr, time.Second, sonic.OverloadPolicy(-1)); !errors.Is(err, sonic.ErrInvalid) {
+			t.Errorf("NewRingWriter() with an unknown policy error = %v, want %v", err, sonic.ErrInvalid)
+		}
+		if _, err := sonic.NewRingWriter(tr, 100*time.Microsecond, sonic.OverloadBlock); !errors.Is(err, sonic.ErrInvalid) {
+			t.Errorf("NewRingWriter() with a capacity shorter than a frame error = %v, want %v", err, sonic.ErrInvalid)
+		}
+		w, err := sonic.NewRingWriter(tr, time.Second, sonic.OverloadBlock)
+		if err != nil {
+			t.Fatalf("NewRingWriter() error = %v", err)
+		}
+		defer w.Close()
+		if _, err := w.Write([]byte{1, 2, 3}); !errors.Is(err, sonic.ErrInvalid) {
+			t.Errorf("Write() of a partial frame error = %v, want %v", err, sonic.ErrInvalid)
+		}
+	})
+
+	t.Run("transformer failure", func(t *testing.T) {
+		s := sonictest.NewStream()
+		s.FailWrite = true
+		w, err := sonic.NewRingWriter(newTransformer(t, s), time.Second, sonic.OverloadBlock)
+		if err != nil {
+			t.Fatalf("NewRingWriter() error = %v", err)
+		}
+		if _, err := w.Write(pcm.EncodeInt16(nil, ramp(0, 100))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := w.Close(); !errors.Is(err, sonic.ErrSonicFailed) {
+			t.Errorf("Close() error = %v, want %v", err, sonic.ErrSonicFailed)
+		}
+	})
+}
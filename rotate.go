@@ -0,0 +1,177 @@
+package sonic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// Rotation configures a Rotator. At least one of MaxDuration and MaxBytes must be positive.
+type Rotation struct {
+	// Pattern names the segment files by formatting it with the 0-based segment index, e.g.
+	// "capture-%05d.wav".
+	Pattern string
+
+	// MaxDuration is the duration of the input of a segment, 0 for no limit. The input is split
+	// at whole frames, so that every segment but the last holds the output of exactly this much
+	// input, rounded down to whole frames but at least one frame.
+	MaxDuration time.Duration
+
+	// MaxBytes is the size of the sample data of a segment, 0 for no limit. A new segment is
+	// started with the first Write after the data reaches it, so a segment exceeds it by the
+	// output of one Write and of the flush that completes the segment.
+	MaxBytes int64
+
+	// Done, if not nil, is called with the name of every segment once it is complete, e.g. to
+	// upload it.
+	Done func(name string)
+}
+
+// Rotator writes the output of a transformer to a series of WAVE files, for long-running
+// recording and monitoring services that capture continuously but deliver the output in
+// segments.
+//
+// Write the input through the rotator. Whenever the current segment is full according to the
+// Rotation, the rotator switches the transformer to the next file with SetWriter, so that the
+// output pending in the transformer is flushed to the old segment, and completes the header of
+// the old segment. Every segment is thus a WAVE file that can be played on its own, in the output
+// format, channels and sample rate of the transformer. Files are only created once input is
+// written to them.
+//
+// A Rotator must not be used concurrently, and the transformer must not be written to directly
+// while it is in use.
+type Rotator struct {
+	t        *Transformer
+	rotation Rotation
+	maxInput int64 // Input bytes of a segment, 0 for no limit
+
+	file     *os.File
+	enc      *wav.Encoder
+	input    int64 // Input bytes written to the current segment
+	segments []string
+}
+
+// NewRotator creates a rotator that writes the output of t to the files named by
+// rotation.Pattern. It returns an error wrapping ErrInvalid if no limit is set, a limit is
+// negative, the pattern does not give different names to different segments, or t delivers its
+// output with WithOutputFunc.
+func NewRotator(t *Transformer, rotation Rotation) (*Rotator, error) {
+	if rotation.MaxDuration < 0 || rotation.MaxBytes < 0 {
+		return nil, fmt.Errorf("%w: limits %v and %d bytes must not be negative", ErrInvalid, rotation.MaxDuration, rotation.MaxBytes)
+	}
+	if rotation.MaxDuration == 0 && rotation.MaxBytes == 0 {
+		return nil, fmt.Errorf("%w: neither a duration nor a size limit is set", ErrInvalid)
+	}
+	if first := fmt.Sprintf(rotation.Pattern, 0); first == fmt.Sprintf(rotation.Pattern, 1) || strings.Contains(first, "%!") {
+		return nil, fmt.Errorf("%w: pattern %q does not number the segments", ErrInvalid, rotation.Pattern)
+	}
+	if t.output != nil {
+		return nil, fmt.Errorf("%w: WithOutputFunc cannot be used with a Rotator", ErrInvalid)
+	}
+	var maxInput int64
+	if rotation.MaxDuration > 0 {
+		frames := max(SamplesForDuration(rotation.MaxDuration, t.sampleRate, 1), 1)
+		maxInput = int64(frames * t.FrameSize())
+	}
+	return &Rotator{t: t, rotation: rotation, maxInput: maxInput}, nil
+}
+
+// Write writes input samples to the transformer, as Transformer.Write does, starting a new
+// segment whenever the current one is full.
+func (r *Rotator) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if r.enc == nil || r.full() {
+			if err := r.next(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if r.maxInput > 0 {
+			chunk = p[:min(len(p), int(r.maxInput-r.input))]
+		}
+		n, err := r.t.Write(chunk)
+		written += n
+		r.input += int64(n)
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// full reports whether the current segment reached a limit of the rotation.
+func (r *Rotator) full() bool {
+	return r.maxInput > 0 && r.input >= r.maxInput || r.rotation.MaxBytes > 0 && r.enc.DataBytes() >= r.rotation.MaxBytes
+}
+
+// next switches the transformer to a new segment, and completes the current one, if any.
+func (r *Rotator) next() error {
+	name := fmt.Sprintf(r.rotation.Pattern, len(r.segments))
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	enc, err := wav.NewEncoder(file, r.t.OutputSampleRate(), r.t.outChannels, wav.Format(r.t.outFormat))
+	if err != nil {
+		file.Close()
+		os.Remove(name)
+		return fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	// SetWriter flushes the output of the current segment to it before switching.
+	err = r.t.SetWriter(enc)
+	if err != nil && !errors.Is(err, ErrRecovered) {
+		file.Close()
+		os.Remove(name)
+		return err
+	}
+	if cerr := r.complete(); cerr != nil {
+		err = cerr
+	}
+	r.file, r.enc, r.input = file, enc, 0
+	r.segments = append(r.segments, name)
+	return err
+}
+
+// complete completes the header of the current segment, if any, and closes its file.
+func (r *Rotator) complete() error {
+	if r.enc == nil {
+		return nil
+	}
+	name := r.segments[len(r.segments)-1]
+	err := r.enc.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.enc = nil, nil
+	if err != nil {
+		return fmt.Errorf("%w: failed to complete %s: %w", ErrWrite, name, err)
+	}
+	if r.rotation.Done != nil {
+		r.rotation.Done(name)
+	}
+	return nil
+}
+
+// Close flushes the transformer to the current segment and completes it. It does not close the
+// transformer, which must not be written to afterwards without setting a new writer.
+func (r *Rotator) Close() error {
+	if r.enc == nil {
+		return nil
+	}
+	err := r.t.Flush()
+	if cerr := r.complete(); cerr != nil && (err == nil || errors.Is(err, ErrRecovered)) {
+		err = cerr
+	}
+	return err
+}
+
+// Segments returns the names of the segment files created so far.
+func (r *Rotator) Segments() []string {
+	return append([]string(nil), r.segments...)
+}
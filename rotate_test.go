@@ -0,0 +1,182 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// readSegment decodes the WAVE file name and returns its sample data. The header must give the
+// actual size of the data.
+func readSegment(t *testing.T, name string) (*wav.Decoder, []byte) {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile(%s) error = %v", name, err)
+	}
+	dec, err := wav.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%s: NewDecoder() error = %v", name, err)
+	}
+	data, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("%s: ReadAll() error = %v", name, err)
+	}
+	if dec.DataBytes() != int64(len(data)) {
+		t.Errorf("%s: header gives %d bytes of data, file has %d", name, dec.DataBytes(), len(data))
+	}
+	return dec, data
+}
+
+func TestRotator(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	opts := []Option{WithSpeed(1.5), WithFadeOut(20 * time.Millisecond), WithOutputFormat(AudioFormatIEEEFloat)}
+	segmentBytes := audiotest.SpeechSampleRate * 2 // 1 second of input
+	const chunk = 777                              // Odd-sized pieces that split frames
+
+	// Every segment has the output of its input, as with a flush after it. The output depends on
+	// how the input is split, so it is written in the same pieces.
+	var want [][]byte
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	for start := 0; start < len(speech); start += segmentBytes {
+		end := min(start+segmentBytes, len(speech))
+		for pos := start; pos < end; pos = min((pos/chunk+1)*chunk, end) {
+			if _, err := tr.Write(speech[pos:min((pos/chunk+1)*chunk, end)]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		want = append(want, bytes.Clone(out.Bytes()))
+		out.Reset()
+	}
+
+	tr, err = NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	var done []string
+	pattern := filepath.Join(t.TempDir(), "capture-%03d.wav")
+	r, err := NewRotator(tr, Rotation{
+		Pattern:     pattern,
+		MaxDuration: time.Second,
+		Done:        func(name string) { done = append(done, name) },
+	})
+	if err != nil {
+		t.Fatalf("NewRotator() error = %v", err)
+	}
+	for p := speech; len(p) > 0; p = p[min(len(p), chunk):] {
+		if _, err := r.Write(p[:min(len(p), chunk)]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	segments := r.Segments()
+	if len(segments) != len(want) {
+		t.Fatalf("Segments() = %v, want %d segments", segments, len(want))
+	}
+	if !slices.Equal(done, segments) {
+		t.Errorf("Done called with %v, want %v", done, segments)
+	}
+	for i, name := range segments {
+		if want := filepath.Join(filepath.Dir(pattern), fmt.Sprintf("capture-%03d.wav", i)); name != want {
+			t.Errorf("segment %d = %s, want %s", i, name, want)
+		}
+		dec, data := readSegment(t, name)
+		if dec.SampleRate() != audiotest.SpeechSampleRate || dec.NumChannels() != 1 || dec.Format() != wav.FormatIEEEFloat {
+			t.Errorf("%s: %d Hz, %d channels, %v, want %d Hz, 1 channel, %v", name, dec.SampleRate(), dec.NumChannels(), dec.Format(), audiotest.SpeechSampleRate, wav.FormatIEEEFloat)
+		}
+		if !bytes.Equal(data, want[i]) {
+			t.Errorf("%s: %d bytes of data, want the %d bytes of the output of its input", name, len(data), len(want[i]))
+		}
+	}
+}
+
+func TestRotator_MaxBytes(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	const maxBytes = 8000
+	r, err := NewRotator(tr, Rotation{Pattern: filepath.Join(t.TempDir(), "%d.wav"), MaxBytes: maxBytes})
+	if err != nil {
+		t.Fatalf("NewRotator() error = %v", err)
+	}
+	for p := speech; len(p) > 0; p = p[min(len(p), 1000):] {
+		if _, err := r.Write(p[:min(len(p), 1000)]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	segments := r.Segments()
+	if len(segments) < 2 {
+		t.Fatalf("Segments() = %v, want at least 2 segments", segments)
+	}
+	total := 0
+	for i, name := range segments {
+		_, data := readSegment(t, name)
+		if i < len(segments)-1 && len(data) < maxBytes {
+			t.Errorf("%s: %d bytes of data, want at least %d", name, len(data), maxBytes)
+		}
+		total += len(data)
+	}
+	if want := tr.OutputSamplesForInput(len(speech)/2) * 2; total < want*9/10 || want*11/10 < total {
+		t.Errorf("segments have %d bytes of data, want about %d", total, want)
+	}
+
+	// Closing again does nothing.
+	if err := r.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestNewRotator_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		opts     []Option
+		rotation Rotation
+	}{
+		{"no limit", nil, Rotation{Pattern: filepath.Join(dir, "%d.wav")}},
+		{"negative duration", nil, Rotation{Pattern: filepath.Join(dir, "%d.wav"), MaxDuration: -time.Second}},
+		{"negative size", nil, Rotation{Pattern: filepath.Join(dir, "%d.wav"), MaxBytes: -1}},
+		{"pattern without index", nil, Rotation{Pattern: filepath.Join(dir, "out.wav"), MaxDuration: time.Second}},
+		{"output function", []Option{WithOutputFunc(func([]byte) error { return nil })}, Rotation{Pattern: filepath.Join(dir, "%d.wav"), MaxDuration: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := NewRotator(tr, tt.rotation); !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewRotator() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
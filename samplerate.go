@@ -0,0 +1,232 @@
+package sonic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+// SampleRatePolicy represents how a sample rate outside the range supported by libsonic is
+// handled. See WithSampleRatePolicy.
+type SampleRatePolicy int
+
+// Constants for sample rate policies
+const (
+	SampleRateError    SampleRatePolicy = iota // Fail with an error naming the nearest supported rate
+	SampleRateResample                         // Resample the input to the nearest supported rate
+	SampleRateClamp                            // Process the input as if it had the nearest supported rate
+)
+
+// String returns the string representation of the SampleRatePolicy.
+func (p SampleRatePolicy) String() string {
+	m := map[SampleRatePolicy]string{
+		SampleRateError:    "SampleRateError",
+		SampleRateResample: "SampleRateResample",
+		SampleRateClamp:    "SampleRateClamp",
+	}
+	if s, ok := m[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("SampleRatePolicy(%d)", p)
+}
+
+// Values returns the all possible values of SampleRatePolicy.
+func (SampleRatePolicy) Values() []SampleRatePolicy {
+	return []SampleRatePolicy{
+		SampleRateError,
+		SampleRateResample,
+		SampleRateClamp,
+	}
+}
+
+// SampleRateWarningFunc is called when a sample rate is adjusted. See WithSampleRatePolicy.
+type SampleRateWarningFunc func(requested, supported int)
+
+// SampleRateMismatch represents how an input whose sample rate differs from the expected one is
+// handled. See WithExpectedSampleRate.
+type SampleRateMismatch int
+
+// Constants for sample rate mismatches
+const (
+	SampleRateMismatchError    SampleRateMismatch = iota // Fail with an error wrapping ErrInvalid
+	SampleRateMismatchUseInput                           // Process the input at its own sample rate
+)
+
+// String returns the string representation of the SampleRateMismatch.
+func (m SampleRateMismatch) String() string {
+	names := map[SampleRateMismatch]string{
+		SampleRateMismatchError:    "SampleRateMismatchError",
+		SampleRateMismatchUseInput: "SampleRateMismatchUseInput",
+	}
+	if s, ok := names[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("SampleRateMismatch(%d)", m)
+}
+
+// Values returns the all possible values of SampleRateMismatch.
+func (SampleRateMismatch) Values() []SampleRateMismatch {
+	return []SampleRateMismatch{
+		SampleRateMismatchError,
+		SampleRateMismatchUseInput,
+	}
+}
+
+// checkExpectedSampleRate compares the sample rate of the input with the expected one, if any, and
+// handles a mismatch according to the mismatch policy.
+func (t *Transformer) checkExpectedSampleRate() error {
+	if t.wantRate == 0 || t.wantRate == t.inputRate {
+		return nil
+	}
+	if t.mismatch == SampleRateMismatchError {
+		return fmt.Errorf("%w: sampleRate %d differs from the expected %d; see WithExpectedSampleRate", ErrInvalid, t.inputRate, t.wantRate)
+	}
+	if t.wantWarning != nil {
+		t.wantWarning(t.wantRate, t.inputRate)
+	}
+	return nil
+}
+
+// applySampleRatePolicy checks the sample rate of the input, and adjusts the rate of the stream
+// according to the sample rate policy if it is out of range.
+func (t *Transformer) applySampleRatePolicy() error {
+	supported := clamp(t.inputRate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE)
+	if supported == t.inputRate {
+		return nil
+	}
+	if t.ratePolicy == SampleRateError {
+		return fmt.Errorf("%w: sampleRate %d is out of range [%d, %d], the nearest supported rate is %d; see WithSampleRatePolicy",
+			ErrInvalid, t.inputRate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE, supported)
+	}
+	if t.inputRate <= 0 {
+		return fmt.Errorf("%w: sampleRate %d must be positive", ErrInvalid, t.inputRate)
+	}
+	t.sampleRate = supported
+	if t.ratePolicy == SampleRateResample {
+		t.resampler = &inputResampler{
+			format:      t.format,
+			numChannels: t.numChannels,
+			step:        float64(t.inputRate) / float64(supported),
+			last:        nil,
+			pos:         0,
+			out:         nil,
+		}
+	}
+	if t.rateWarning != nil {
+		t.rateWarning(t.inputRate, supported)
+	}
+	return nil
+}
+
+// writeResampled resamples p with the input resampler and writes the result, in chunks of the
+// stream buffer so that the resampled input of a large p is not held at once. It returns the
+// number of bytes of p consumed, estimated from the resampled bytes consumed on errors.
+func (t *Transformer) writeResampled(p []byte) (int, error) {
+	frameSize := t.FrameSize()
+	if len(p)%frameSize != 0 {
+		return 0, fmt.Errorf("%w: 'p' must be a multiple of the frame size when resampling", ErrInvalid)
+	}
+	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
+	for len(p) > 0 {
+		chunk := p[:min(len(p), streamBufferFrames*frameSize)]
+		q := t.resampler.resample(chunk)
+		n, err := t.writeStreamInput(q)
+		if n < len(q) {
+			frames := int(int64(n) * int64(len(chunk)) / int64(len(q)) / int64(frameSize))
+			return numWrittenBytes + frames*frameSize, err
+		}
+		numWrittenBytes += len(chunk)
+		if deferRecovered(&recovered, err) != nil {
+			return numWrittenBytes, err
+		}
+		p = p[len(chunk):]
+	}
+	return numWrittenBytes, recovered
+}
+
+// inputResampler converts interleaved input from one sample rate to another by linear
+// interpolation across calls. It is meant to bring an unsupported rate into the range of
+// libsonic, not for high-fidelity conversion.
+type inputResampler struct {
+	format      AudioFormat
+	numChannels int
+	step        float64   // Input frames per output frame
+	last        []float64 // Last input frame of the previous call, nil before the first one
+	pos         float64   // Position of the next output frame, where -1 is last
+	out         []byte    // Resampled frames, reused across calls
+}
+
+// resample returns the frames of p resampled. The result is valid until the next call.
+func (r *inputResampler) resample(p []byte) []byte {
+	size := r.format.SampleSize()
+	frames := len(p) / (size * r.numChannels)
+	r.out = r.out[:0]
+	if frames == 0 {
+		return r.out
+	}
+	sample := func(i, ch int) float64 {
+		if i < 0 {
+			return r.last[ch]
+		}
+		return r.decode(p[(i*r.numChannels+ch)*size:])
+	}
+	for ; r.pos <= float64(frames-1); r.pos += r.step {
+		i := int(math.Floor(r.pos))
+		f := r.pos - float64(i)
+		for ch := range r.numChannels {
+			v := sample(i, ch)
+			if f > 0 {
+				v += f * (sample(i+1, ch) - v)
+			}
+			r.out = r.encode(r.out, v)
+		}
+	}
+	r.pos -= float64(frames)
+	if r.last == nil {
+		r.last = make([]float64, r.numChannels)
+	}
+	for ch := range r.last {
+		r.last[ch] = sample(frames-1, ch)
+	}
+	return r.out
+}
+
+// decode returns the first sample of b.
+func (r *inputResampler) decode(b []byte) float64 {
+	switch r.format {
+	case AudioFormatPCM:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case AudioFormatIEEEFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case AudioFormatPCM24:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8)
+	case AudioFormatPCM32:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	case AudioFormatIEEEFloat64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	default:
+		return float64(b[0])
+	}
+}
+
+// encode appends the sample v to b, rounded and clamped for integer formats.
+func (r *inputResampler) encode(b []byte, v float64) []byte {
+	switch r.format {
+	case AudioFormatPCM:
+		return binary.LittleEndian.AppendUint16(b, uint16(int16(math.Round(math.Max(-32768, math.Min(32767, v))))))
+	case AudioFormatIEEEFloat:
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+	case AudioFormatPCM24:
+		s := int32(math.Round(math.Max(-1<<23, math.Min(1<<23-1, v))))
+		return append(b, byte(s), byte(s>>8), byte(s>>16))
+	case AudioFormatPCM32:
+		return binary.LittleEndian.AppendUint32(b, uint32(int32(math.Round(math.Max(math.MinInt32, math.Min(math.MaxInt32, v))))))
+	case AudioFormatIEEEFloat64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	default:
+		return append(b, uint8(math.Round(math.Max(0, math.Min(255, v)))))
+	}
+}
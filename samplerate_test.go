@@ -0,0 +1,192 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// sineInt16 returns frames of a mono sine of freq Hz at sampleRate with amplitude 16000.
+func sineInt16(freq float64, sampleRate, frames int) []int16 {
+	samples := make([]int16, frames)
+	for i := range samples {
+		samples[i] = int16(16000 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// zeroCrossings returns the number of rising zero crossings of samples.
+func zeroCrossings(samples []int16) int {
+	n := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSampleRatePolicy_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		nearest    string
+	}{
+		{"high", 768000, "nearest supported rate is 500000"},
+		{"low", 500, "nearest supported rate is 1000"},
+		{"zero", 0, "nearest supported rate is 1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransformer(io.Discard, tt.sampleRate, AudioFormatPCM)
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.nearest) {
+				t.Errorf("NewTransformer() error = %v, want %v naming the nearest rate", err, ErrInvalid)
+			}
+		})
+	}
+	if _, err := NewTransformer(io.Discard, -8000, AudioFormatPCM, WithSampleRatePolicy(SampleRateClamp, nil)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewTransformer() with a negative rate error = %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestSampleRatePolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		sampleRate     int
+		policy         SampleRatePolicy
+		wantOutputRate int
+		wantFrames     int // Output frames of half a second of input at speed 2
+	}{
+		{"resample high", 768000, SampleRateResample, 500000, 125000},
+		{"resample low", 800, SampleRateResample, 1000, 250},
+		{"clamp high", 768000, SampleRateClamp, 768000, 192000},
+		{"clamp low", 800, SampleRateClamp, 800, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested, supported int
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, tt.sampleRate, AudioFormatPCM, WithSpeed(2),
+				WithSampleRatePolicy(tt.policy, func(r, s int) { requested, supported = r, s }))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if requested != tt.sampleRate || supported != clamp(tt.sampleRate, 1000, 500000) {
+				t.Errorf("warning got (%d, %d), want (%d, %d)", requested, supported, tt.sampleRate, clamp(tt.sampleRate, 1000, 500000))
+			}
+			if got := tr.OutputSampleRate(); got != tt.wantOutputRate {
+				t.Errorf("OutputSampleRate() = %d, want %d", got, tt.wantOutputRate)
+			}
+
+			// A tone of 100 Hz, written in uneven pieces.
+			input := pcm.EncodeInt16(nil, sineInt16(100, tt.sampleRate, tt.sampleRate/2))
+			for len(input) > 0 {
+				n := min(len(input), 2*1001)
+				if _, err := tr.Write(input[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				input = input[n:]
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			output := pcm.DecodeInt16(nil, out.Bytes())
+			// Short outputs lose up to a few pitch periods at the end.
+			if d, tol := len(output)-tt.wantFrames, max(tt.wantFrames/50, 10); d < -tol || tol < d {
+				t.Errorf("output has %d frames, want about %d", len(output), tt.wantFrames)
+			}
+			// Played at the output rate, the tone keeps its pitch: 25 periods in a quarter of a
+			// second.
+			if n := zeroCrossings(output); n < 23 || 26 < n {
+				t.Errorf("output has %d periods, want 25", n)
+			}
+		})
+	}
+}
+
+func TestSampleRatePolicy_InRange(t *testing.T) {
+	// The policy has no effect on supported rates.
+	called := false
+	tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithSampleRatePolicy(SampleRateResample, func(int, int) { called = true }))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	def, err := NewTransformer(io.Discard, 44100, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer def.Close()
+	if called || tr.resampler != nil || tr.OutputSampleRate() != 44100 || tr.Fingerprint() != def.Fingerprint() {
+		t.Errorf("in-range rate: warned = %v, resampled = %v, OutputSampleRate() = %d, same fingerprint = %v, want no change",
+			called, tr.resampler != nil, tr.OutputSampleRate(), tr.Fingerprint() == def.Fingerprint())
+	}
+
+	resample, err := NewTransformer(io.Discard, 768000, AudioFormatPCM, WithSampleRatePolicy(SampleRateResample, nil))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer resample.Close()
+	clamped, err := NewTransformer(io.Discard, 768000, AudioFormatPCM, WithSampleRatePolicy(SampleRateClamp, nil))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer clamped.Close()
+	if resample.Fingerprint() == clamped.Fingerprint() {
+		t.Errorf("resampled and clamped transformers have the same fingerprint")
+	}
+}
+
+func TestInputResampler(t *testing.T) {
+	tests := []struct {
+		name   string
+		format AudioFormat
+		input  []byte
+		step   float64
+		want   []byte
+	}{
+		{"PCM up", AudioFormatPCM, pcm.EncodeInt16(nil, []int16{0, 100, 200, 300}), 0.5, pcm.EncodeInt16(nil, []int16{0, 50, 100, 150, 200, 250, 300})},
+		{"PCM down", AudioFormatPCM, pcm.EncodeInt16(nil, []int16{0, 100, 200, 300, 400}), 2, pcm.EncodeInt16(nil, []int16{0, 200, 400})},
+		{"float", AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, []float32{0, 1, -1}), 0.5, pcm.EncodeFloat32(nil, []float32{0, 0.5, 1, 0, -1})},
+		{"U8", AudioFormatU8, []byte{0, 255, 128}, 0.5, []byte{0, 128, 255, 192, 128}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &inputResampler{format: tt.format, numChannels: 1, step: tt.step}
+			if got := r.resample(tt.input); !bytes.Equal(got, tt.want) {
+				t.Errorf("resample() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputResampler_Split(t *testing.T) {
+	// Writing in pieces gives the same output as a single write.
+	input := pcm.EncodeInt16(nil, sineInt16(440, 768000, 10000))
+	stereo := make([]byte, 0, 2*len(input))
+	for i := 0; i < len(input); i += 2 {
+		stereo = append(stereo, input[i], input[i+1], input[i], input[i+1])
+	}
+	whole := &inputResampler{format: AudioFormatPCM, numChannels: 2, step: 768000.0 / 500000}
+	want := bytes.Clone(whole.resample(stereo))
+
+	split := &inputResampler{format: AudioFormatPCM, numChannels: 2, step: 768000.0 / 500000}
+	var got []byte
+	for p := stereo; len(p) > 0; {
+		n := min(len(p), 4*333)
+		got = append(got, split.resample(p[:n])...)
+		p = p[n:]
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("split resample() has %d bytes, want the %d bytes of a single call", len(got), len(want))
+	}
+	if frames, wantFrames := len(want)/4, 10000*500000/768000; frames < wantFrames || wantFrames+1 < frames {
+		t.Errorf("resample() has %d frames, want %d", frames, wantFrames)
+	}
+}
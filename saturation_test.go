@@ -0,0 +1,152 @@
+package sonic
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// fullScaleSquare returns a full-scale square wave alternating between MaxInt16 and MinInt16 every period samples.
+func fullScaleSquare(numSamples, period int) []int16 {
+	samples := make([]int16, numSamples)
+	for i := range samples {
+		if (i/period)%2 == 0 {
+			samples[i] = math.MaxInt16
+		} else {
+			samples[i] = math.MinInt16
+		}
+	}
+	return samples
+}
+
+// TestTransformer_VolumeSaturatesInt16 checks that full-scale input at maximum volume
+// saturates instead of wrapping around.
+func TestTransformer_VolumeSaturatesInt16(t *testing.T) {
+	const period = 100
+	in := fullScaleSquare(44100, period)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"volume 100", []Option{WithVolume(100)}},
+		{"volume 100 with channel gain 100", []Option{WithVolume(100), WithChannelGains([]float32{100})}},
+		{"volume 100 with consonant emphasis", []Option{WithVolume(100), WithConsonantEmphasis(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tr, err := NewTransformer(out, 44100, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			if _, err := tr.Write(pcm.EncodeInt16(nil, in)); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			got := pcm.DecodeInt16(nil, out.Bytes())
+			if len(got) != len(in) {
+				t.Fatalf("output samples = %d, want %d", len(got), len(in))
+			}
+			// At speed 1.0 samples pass through in place, so each output sample must keep the sign of its input.
+			for i := range got {
+				if (got[i] < 0) != (in[i] < 0) {
+					t.Fatalf("sample %d = %d wrapped around (input %d)", i, got[i], in[i])
+				}
+				if abs(int(got[i])) < math.MaxInt16-1 {
+					t.Fatalf("sample %d = %d, want saturated", i, got[i])
+				}
+			}
+		})
+	}
+}
+
+// TestTransformer_VolumeSaturatesFloat32 checks that float input beyond full scale at maximum volume
+// saturates instead of wrapping around.
+func TestTransformer_VolumeSaturatesFloat32(t *testing.T) {
+	in := make([]float32, 44100)
+	for i := range in {
+		in[i] = 1.5
+		if (i/100)%2 == 1 {
+			in[i] = -1.5
+		}
+	}
+
+	out := new(bytes.Buffer)
+	tr, err := NewTransformer(out, 44100, AudioFormatIEEEFloat, WithVolume(100))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+
+	if _, err := tr.Write(pcm.EncodeFloat32(nil, in)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	got := pcm.DecodeFloat32(nil, out.Bytes())
+	if len(got) != len(in) {
+		t.Fatalf("output samples = %d, want %d", len(got), len(in))
+	}
+	for i := range got {
+		if (got[i] < 0) != (in[i] < 0) || math.Abs(float64(got[i])) < 0.999 || math.Abs(float64(got[i])) > 1 {
+			t.Fatalf("sample %d = %f, want saturated %f", i, got[i], math.Copysign(1, float64(in[i])))
+		}
+	}
+}
+
+// TestTransformer_SaturatesFloat32Input checks that NaN and out-of-range float input reach the
+// stream as silence and full scale, without modifying the buffer passed to Write.
+func TestTransformer_SaturatesFloat32Input(t *testing.T) {
+	in := make([]float32, 2*4410)
+	for i := range in {
+		switch i % 4 {
+		case 0:
+			in[i] = 2
+		case 1:
+			in[i] = -2
+		case 2:
+			in[i] = float32(math.NaN())
+		case 3:
+			in[i] = 0.5
+		}
+	}
+	want := []float32{1, -1, 0, 0.5}
+
+	for _, opts := range [][]Option{{WithChannels(2)}, {WithChannels(2), WithMidSide()}} {
+		out := new(bytes.Buffer)
+		tr, err := NewTransformer(out, 44100, AudioFormatIEEEFloat, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		p := pcm.EncodeFloat32(nil, in)
+		if _, err := tr.Write(p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if got := pcm.DecodeFloat32(nil, p); !math.IsNaN(float64(got[2])) || got[0] != 2 {
+			t.Errorf("Write() modified its input: %v", got[:4])
+		}
+		got := pcm.DecodeFloat32(nil, out.Bytes())
+		if len(got) != len(in) {
+			t.Fatalf("output samples = %d, want %d", len(got), len(in))
+		}
+		for i, v := range got {
+			if math.Abs(float64(v-want[i%4])) > 1e-3 {
+				t.Fatalf("sample %d = %v, want %v", i, v, want[i%4])
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+#!/bin/bash
+set -euo pipefail
+
+# Runs go vet and go test in the core module and in every nested module, i.e.
+# the subpackages with third-party dependencies. Nested modules replace the
+# core module with the working tree, so their tests run against it. Arguments
+# are passed to go test, e.g. -short or -skip TestReference.
+
+root_dir="$(dirname "$(realpath "$0")")/.."
+
+# Empty go.mod files, such as the one of the libsonic submodule, only exclude
+# a directory from the core module.
+mapfile -t modules < <(cd "$root_dir" && find . -name go.mod -size +0 -not -path '*/testdata/*' -printf '%h\n' | sort)
+
+for module in "${modules[@]}"; do
+    echo "== $module"
+    (
+        cd "$root_dir/$module"
+        GOWORK=off go vet ./...
+        GOWORK=off go test "$@" ./...
+    )
+done
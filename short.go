@@ -0,0 +1,171 @@
+package sonic
+
+import (
+	"fmt"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// ShortInputPolicy represents how input too short for libsonic is handled.
+// See WithShortInput.
+type ShortInputPolicy int
+
+// Constants for short input policies
+const (
+	ShortInputProcess     ShortInputPolicy = iota // Process the input as it is
+	ShortInputPad                                 // Pad the input with silence and trim the output
+	ShortInputPassthrough                         // Write the input without time stretching
+	ShortInputError                               // Discard the input and return ErrShortInput
+)
+
+// String returns the string representation of the ShortInputPolicy.
+func (p ShortInputPolicy) String() string {
+	m := map[ShortInputPolicy]string{
+		ShortInputProcess:     "ShortInputProcess",
+		ShortInputPad:         "ShortInputPad",
+		ShortInputPassthrough: "ShortInputPassthrough",
+		ShortInputError:       "ShortInputError",
+	}
+	if s, ok := m[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("ShortInputPolicy(%d)", p)
+}
+
+// Values returns the all possible values of ShortInputPolicy.
+func (ShortInputPolicy) Values() []ShortInputPolicy {
+	return []ShortInputPolicy{
+		ShortInputProcess,
+		ShortInputPad,
+		ShortInputPassthrough,
+		ShortInputError,
+	}
+}
+
+// ShortInputFrames returns the number of input frames libsonic needs to process audio at
+// sampleRate: two periods of its lowest detectable pitch, about 31 ms. Input shorter than this
+// between two Flush calls is handled according to the ShortInputPolicy.
+func ShortInputFrames(sampleRate int) int {
+	return 2 * sampleRate / cgosonic.MIN_PITCH
+}
+
+// holdShortInput holds back p while the input since the last Flush is shorter than
+// ShortInputFrames, so that the short input policy can be applied at Flush. It reports whether
+// p was held back.
+func (t *Transformer) holdShortInput(p []byte) (bool, error) {
+	if t.shortHeld == nil || t.shortPassed {
+		return false, nil
+	}
+	if len(p)%t.format.SampleSize() != 0 {
+		return false, fmt.Errorf("%w: 'p' must be a multiple of the sample size", ErrInvalid)
+	}
+	if len(t.shortHeld)+len(p) < cap(t.shortHeld) {
+		t.shortHeld = append(t.shortHeld, p...)
+		return true, nil
+	}
+	t.shortPassed = true
+	held := t.shortHeld
+	t.shortHeld = t.shortHeld[:0]
+	if _, err := t.writeUninterrupted(t.writeAligned, held); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// flushShortInput applies the short input policy to the input held back by holdShortInput.
+// For ShortInputPad, it sets outputLimit to the number of output frames to trim or pad the
+// output to.
+func (t *Transformer) flushShortInput() error {
+	if t.shortHeld == nil {
+		return nil
+	}
+	held := t.shortHeld
+	t.shortHeld = t.shortHeld[:0]
+	passed := t.shortPassed
+	t.shortPassed = false
+	if passed || len(held) == 0 {
+		return nil
+	}
+
+	frameSize := t.numChannels * t.format.SampleSize()
+	numFrames := len(held) / frameSize
+	switch t.shortInput {
+	case ShortInputPad:
+		padded := held[:cap(held)]
+		t.format.fillSilence(padded[len(held):])
+		t.outputLimit = int(float64(numFrames)/t.timeScale() + 0.5)
+		_, err := t.write(padded[:len(padded)/frameSize*frameSize])
+		return err
+	case ShortInputPassthrough:
+		return t.passthrough(held[:numFrames*frameSize])
+	case ShortInputError:
+		return fmt.Errorf("%w: %d input frames are shorter than the %d frames needed", ErrShortInput, numFrames, ShortInputFrames(t.sampleRate))
+	default:
+		return fmt.Errorf("%w: short input policy is broken: %d", ErrInternal, t.shortInput)
+	}
+}
+
+// passthrough writes the frames of p to the output without time stretching, applying only
+// channel selection and the output post filters.
+func (t *Transformer) passthrough(p []byte) error {
+	chunkSize := streamBufferFrames * t.numChannels * t.format.SampleSize()
+	for len(p) > 0 {
+		chunk := p[:min(len(p), chunkSize)]
+		switch t.format {
+		case AudioFormatPCM, AudioFormatU8:
+			var in []int16
+			if t.format == AudioFormatU8 {
+				in = pcm.Uint8ToInt16(t.unsafeBytesAsInt16Slice(t.widenBuffer), chunk)
+			} else {
+				in = t.unsafeBytesAsInt16Slice(chunk)
+			}
+			if t.channels != nil {
+				in = selectChannels(t.unsafeBytesAsInt16Slice(t.selectBuffer), in, t.numChannels, t.channels)
+			}
+			if err := t.emitInt16(in); err != nil {
+				return err
+			}
+		case AudioFormatIEEEFloat, AudioFormatPCM24, AudioFormatPCM32, AudioFormatIEEEFloat64:
+			var in []float32
+			if t.format != AudioFormatIEEEFloat {
+				in = t.format.toFloat32(t.unsafeBytesAsFloat32Slice(t.widenBuffer), chunk)
+			} else {
+				in = t.unsafeBytesAsFloat32Slice(chunk)
+			}
+			if t.channels != nil {
+				in = selectChannels(t.unsafeBytesAsFloat32Slice(t.selectBuffer), in, t.numChannels, t.channels)
+			}
+			if err := t.emitFloat32(in); err != nil {
+				return err
+			}
+		}
+		p = p[len(chunk):]
+	}
+	return nil
+}
+
+// limitOutput trims p to the output frames still allowed by outputLimit, if it is set.
+func (t *Transformer) limitOutput(p []byte) []byte {
+	if t.outputLimit < 0 {
+		return p
+	}
+	frameSize := t.OutputFrameSize()
+	n := min(len(p)/frameSize, t.outputLimit)
+	t.outputLimit -= n
+	return p[:n*frameSize]
+}
+
+// padOutput writes silence for the output frames still allowed by outputLimit and clears it.
+func (t *Transformer) padOutput() error {
+	frameSize := t.OutputFrameSize()
+	for t.outputLimit > 0 {
+		silence := t.streamBuffer[:min(t.outputLimit, len(t.streamBuffer)/frameSize)*frameSize]
+		t.outFormat.fillSilence(silence)
+		if err := t.writeOutput(silence); err != nil {
+			return err
+		}
+	}
+	t.outputLimit = -1
+	return nil
+}
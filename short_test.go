@@ -0,0 +1,120 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_ShortInput(t *testing.T) {
+	const sampleRate = audiotest.SpeechSampleRate
+	speech := audiotest.Speech()[sampleRate : 2*sampleRate]
+
+	// transform writes input in writes of chunk frames, flushes, and returns the output.
+	transform := func(t *testing.T, input []int16, chunk int, opts ...Option) ([]int16, error) {
+		t.Helper()
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, sampleRate, AudioFormatPCM, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		for b := pcm.EncodeInt16(nil, input); len(b) > 0; {
+			n := min(len(b), 2*chunk)
+			if _, err := tr.Write(b[:n]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			b = b[n:]
+		}
+		err = tr.Flush()
+		return pcm.DecodeInt16(nil, out.Bytes()), err
+	}
+
+	for _, speed := range []float32{0.5, 2} {
+		for _, n := range []int{1, 2, 10, 50, 100} {
+			input := speech[:n]
+			wantFrames := int(math.Round(float64(n) / float64(speed)))
+
+			if _, err := transform(t, input, n, WithSpeed(speed)); err != nil {
+				t.Errorf("speed %v, %d frames, ShortInputProcess: Flush() error = %v", speed, n, err)
+			}
+			if got, err := transform(t, input, n, WithSpeed(speed), WithShortInput(ShortInputPad)); err != nil || len(got) != wantFrames {
+				t.Errorf("speed %v, %d frames, ShortInputPad: got %d frames, error %v; want %d frames", speed, n, len(got), err, wantFrames)
+			}
+			if got, err := transform(t, input, n, WithSpeed(speed), WithShortInput(ShortInputPassthrough)); err != nil || !slices.Equal(got, input) {
+				t.Errorf("speed %v, %d frames, ShortInputPassthrough: got %v, error %v; want the input", speed, n, got, err)
+			}
+			if got, err := transform(t, input, n, WithSpeed(speed), WithShortInput(ShortInputError)); !errors.Is(err, ErrShortInput) || len(got) != 0 {
+				t.Errorf("speed %v, %d frames, ShortInputError: got %d frames, error %v; want no output and %v", speed, n, len(got), err, ErrShortInput)
+			}
+		}
+	}
+
+	t.Run("long input", func(t *testing.T) {
+		want, err := transform(t, speech, len(speech), WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		for _, policy := range []ShortInputPolicy{ShortInputPad, ShortInputPassthrough, ShortInputError} {
+			got, err := transform(t, speech, len(speech), WithSpeed(1.5), WithShortInput(policy))
+			if err != nil || !slices.Equal(got, want) {
+				t.Errorf("%v: output differs from ShortInputProcess, error %v", policy, err)
+			}
+		}
+	})
+
+	t.Run("small writes", func(t *testing.T) {
+		// Input written in pieces shorter than ShortInputFrames is not short in total.
+		got, err := transform(t, speech, 10, WithSpeed(2), WithShortInput(ShortInputError))
+		if err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if want := len(speech) / 2; len(got) < want*9/10 || len(got) > want*11/10 {
+			t.Errorf("got %d frames, want about %d", len(got), want)
+		}
+	})
+
+	t.Run("reuse after Flush", func(t *testing.T) {
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, sampleRate, AudioFormatPCM, WithShortInput(ShortInputError))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		for i, n := range []int{len(speech), 50, len(speech)} {
+			if _, err := tr.Write(pcm.EncodeInt16(nil, speech[:n])); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); (n < ShortInputFrames(sampleRate)) != errors.Is(err, ErrShortInput) {
+				t.Errorf("Flush() #%d with %d frames error = %v", i, n, err)
+			}
+		}
+	})
+
+	t.Run("passthrough selects channels", func(t *testing.T) {
+		stereo := []int16{1, -1, 2, -2, 3, -3}
+		got, err := transform(t, stereo, 3, WithChannels(2), WithSelectChannels(1), WithShortInput(ShortInputPassthrough))
+		if err != nil || !slices.Equal(got, []int16{-1, -2, -3}) {
+			t.Errorf("got %v, error %v; want [-1 -2 -3]", got, err)
+		}
+	})
+
+	t.Run("pending input", func(t *testing.T) {
+		tr, err := NewTransformer(new(bytes.Buffer), sampleRate, AudioFormatPCM, WithShortInput(ShortInputPad))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(pcm.EncodeInt16(nil, speech[:100])); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if got := tr.InputPending(); got != 100 {
+			t.Errorf("InputPending() = %d, want 100", got)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package sonic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ShutdownContext returns a copy of parent that is canceled when the process receives SIGINT
+// or SIGTERM, or when the returned stop function is called. Pass it to CopyContext so that an
+// interrupted job still leaves playable output behind.
+func ShutdownContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+// CopyContext copies the samples from r to t until r returns io.EOF or ctx is done.
+// It then flushes t and closes the given closers in order, whether the copy completed or not,
+// so that outputs such as files or encoders are finalized instead of being left truncated.
+// It returns the number of bytes written to t.
+//
+// ctx is checked between reads; a blocking read of r is not interrupted. If ctx is done before
+// r is exhausted, the returned error wraps ctx.Err(). A trailing incomplete frame of r is carried
+// over to the next Write, see Transformer.Write.
+func CopyContext(ctx context.Context, t *Transformer, r io.Reader, closers ...io.Closer) (int64, error) {
+	written, err := copyFrames(ctx, t, r)
+	if err == nil || errors.Is(err, ctx.Err()) {
+		if ferr := t.Flush(); ferr != nil {
+			err = errors.Join(err, ferr)
+		}
+	}
+	for _, c := range closers {
+		if cerr := c.Close(); cerr != nil {
+			err = errors.Join(err, fmt.Errorf("%w: failed to close output: %w", ErrWrite, cerr))
+		}
+	}
+	return written, err
+}
+
+// copyFrames writes the data read from r to t until r is exhausted or ctx is done. Reads that
+// end in the middle of a frame are completed by the carry-over of Write.
+func copyFrames(ctx context.Context, t *Transformer, r io.Reader) (int64, error) {
+	buf := make([]byte, streamBufferFrames*t.FrameSize())
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, fmt.Errorf("copy interrupted: %w", err)
+		}
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			m, err := t.Write(buf[:n])
+			written += int64(m)
+			if err != nil {
+				return written, err
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, fmt.Errorf("failed to read input: %w", rerr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sonic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// cancelingReader cancels a context after reading n bytes.
+type cancelingReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n -= n
+	if c.n <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestCopyContext(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(speech); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		out := &closeRecorder{}
+		tr, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		// Reads of odd sizes split frames, which must not corrupt the output.
+		n, err := CopyContext(context.Background(), tr, iotest.HalfReader(bytes.NewReader(speech)), out)
+		if err != nil {
+			t.Fatalf("CopyContext() error = %v", err)
+		}
+		if n != int64(len(speech)) {
+			t.Errorf("CopyContext() = %d, want %d", n, len(speech))
+		}
+		if !out.closed {
+			t.Error("CopyContext() did not close the output")
+		}
+		// Different write sizes shift libsonic's pitch period boundaries slightly.
+		if diff := out.Len() - want.Len(); diff < -want.Len()/100 || want.Len()/100 < diff {
+			t.Errorf("output has %d bytes, want about the %d bytes of a direct write", out.Len(), want.Len())
+		}
+	})
+
+	t.Run("trailing incomplete frame", func(t *testing.T) {
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		// The last byte of speech is carried over to the next Write, which completes the frame.
+		n, err := CopyContext(context.Background(), tr, bytes.NewReader(speech[:len(speech)-1]))
+		if err != nil || n != int64(len(speech)-1) {
+			t.Fatalf("CopyContext() = %d, %v, want %d, nil", n, err, len(speech)-1)
+		}
+		if _, err := tr.Write(speech[len(speech)-1:]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if diff := out.Len() - want.Len(); diff < -want.Len()/100 || want.Len()/100 < diff {
+			t.Errorf("output has %d bytes, want about the %d bytes of a direct write", out.Len(), want.Len())
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		out := &closeRecorder{}
+		tr, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := &cancelingReader{r: bytes.NewReader(speech), n: len(speech) / 2, cancel: cancel}
+		n, err := CopyContext(ctx, tr, r, out)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CopyContext() error = %v, want %v", err, context.Canceled)
+		}
+		if n <= 0 || n >= int64(len(speech)) {
+			t.Errorf("CopyContext() = %d, want a partial copy", n)
+		}
+		if !out.closed {
+			t.Error("CopyContext() did not close the output")
+		}
+		// The flushed partial output must cover all input copied so far.
+		if wantLen := tr.OutputSamplesForInput(int(n)/2) * 2; out.Len() < wantLen*9/10 {
+			t.Errorf("partial output has %d bytes, want about %d", out.Len(), wantLen)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		out := &closeRecorder{}
+		tr, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		errRead := errors.New("read failed")
+		_, err = CopyContext(context.Background(), tr, iotest.ErrReader(errRead), out)
+		if !errors.Is(err, errRead) {
+			t.Errorf("CopyContext() error = %v, want %v", err, errRead)
+		}
+		if !out.closed {
+			t.Error("CopyContext() did not close the output")
+		}
+	})
+}
+
+func TestShutdownContext(t *testing.T) {
+	ctx, stop := ShutdownContext(context.Background())
+	if ctx.Err() != nil {
+		t.Fatalf("ShutdownContext() returned a done context: %v", ctx.Err())
+	}
+	stop()
+	if ctx.Err() == nil {
+		t.Error("stop did not cancel the context")
+	}
+}
@@ -0,0 +1,132 @@
+// Package signal generates test signals for sonic.Transformer: sine tones, sweeps, white noise
+// and speech-shaped noise, for tests, benchmarks and demos.
+//
+// The generators return mono float samples in the range [-1, 1]. Int16 converts them to int16
+// samples as libsonic does, and NewReader and NewFloatReader serve them as the little-endian PCM
+// bytes consumed by a Transformer created with sonic.AudioFormatPCM or sonic.AudioFormatIEEEFloat:
+//
+//	tone := signal.Sine(48000, 800, 0.5, 48000) // One second of 800 Hz at -6 dBFS
+//	tr, err := sonic.NewTransformer(w, 48000, sonic.AudioFormatPCM, sonic.WithSpeed(2))
+//	...
+//	_, err = io.Copy(tr, signal.NewReader(tone))
+//
+// The noise generators are deterministic: the same seed always produces the same samples, so
+// tests built on them are reproducible. The package only depends on the standard library and
+// the pcm package.
+package signal
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand/v2"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// Sine returns n samples at sampleRate of a sine tone of freq Hz with peak amplitude amp.
+func Sine(sampleRate int, freq float64, amp float64, n int) []float32 {
+	samples := make([]float32, max(n, 0))
+	for i := range samples {
+		t := float64(i) / float64(sampleRate)
+		samples[i] = float32(amp * math.Sin(2*math.Pi*freq*t))
+	}
+	return samples
+}
+
+// Sweep returns n samples at sampleRate of a sine sweep from freq Hz to toFreq Hz with peak
+// amplitude amp. The frequency changes exponentially, so that every octave takes the same time,
+// as in measurement sweeps. Both frequencies must be positive.
+func Sweep(sampleRate int, freq, toFreq float64, amp float64, n int) []float32 {
+	samples := make([]float32, max(n, 0))
+	if n == 0 {
+		return samples
+	}
+	// The phase is the integral of freq * (toFreq/freq)^(t/d) over t
+	d := float64(n) / float64(sampleRate)
+	k := math.Log(toFreq / freq)
+	for i := range samples {
+		t := float64(i) / float64(sampleRate)
+		var phase float64
+		if k == 0 {
+			phase = 2 * math.Pi * freq * t
+		} else {
+			phase = 2 * math.Pi * freq * d / k * (math.Exp(t/d*k) - 1)
+		}
+		samples[i] = float32(amp * math.Sin(phase))
+	}
+	return samples
+}
+
+// WhiteNoise returns n samples of white noise uniformly distributed in [-amp, amp]. The samples
+// are determined by seed.
+func WhiteNoise(seed uint64, amp float64, n int) []float32 {
+	r := newRand(seed)
+	samples := make([]float32, max(n, 0))
+	for i := range samples {
+		samples[i] = float32(amp * (2*r.Float64() - 1))
+	}
+	return samples
+}
+
+// Parameters of SpeechNoise
+const (
+	speechLowCut   = 100 // Hz, below the fundamental of most voices
+	speechHighCut  = 500 // Hz, above which the long-term spectrum of speech falls by 6 dB/octave
+	syllableRate   = 4   // Hz, the typical syllable rate of speech
+	speechHeadroom = 4   // Ratio of the peak to the RMS level of the filtered noise
+)
+
+// SpeechNoise returns n samples at sampleRate of noise shaped like speech, with peak amplitude
+// of about amp; the filtered noise is normalized statistically, so a few samples may exceed amp
+// and are clipped to it. The samples are determined by seed.
+//
+// The spectrum of the noise follows the long-term spectrum of speech: flat from 100 Hz to 500 Hz
+// and falling by 6 dB per octave above. The level is modulated at the syllable rate of 4 Hz,
+// down to silence between syllables, so that the noise has onsets and pauses like speech. It
+// is no substitute for real speech when judging quality, but exercises the same code paths.
+func SpeechNoise(sampleRate int, seed uint64, amp float64, n int) []float32 {
+	r := newRand(seed)
+	samples := make([]float32, max(n, 0))
+	hp := onePole(speechLowCut, sampleRate)
+	lp := onePole(speechHighCut, sampleRate)
+	var lowState, highState float64
+	// The filters pass about this share of the power of white noise, with unit variance
+	// Gaussian input: the band between the cutoffs relative to half the sample rate.
+	gain := math.Sqrt(float64(sampleRate) / 2 / (math.Pi / 2 * speechHighCut))
+	for i := range samples {
+		x := r.NormFloat64()
+		lowState += hp * (x - lowState)
+		highState += lp * (x - lowState - highState)
+		t := float64(i) / float64(sampleRate)
+		envelope := 0.5 - 0.5*math.Cos(2*math.Pi*syllableRate*t)
+		v := amp * gain / speechHeadroom * highState * envelope
+		samples[i] = float32(max(-amp, min(amp, v)))
+	}
+	return samples
+}
+
+// onePole returns the coefficient of a one-pole low-pass filter with cutoff Hz at sampleRate.
+func onePole(cutoff float64, sampleRate int) float64 {
+	return 1 - math.Exp(-2*math.Pi*cutoff/float64(sampleRate))
+}
+
+// newRand returns a deterministic random generator for seed.
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+}
+
+// Int16 converts samples to int16 samples with the scaling of libsonic, pcm.Scaling32767.
+func Int16(samples []float32) []int16 {
+	return pcm.Float32ToInt16(nil, samples, pcm.Scaling32767)
+}
+
+// NewReader returns a reader of samples as 16-bit little-endian PCM bytes, converted with Int16.
+func NewReader(samples []float32) io.Reader {
+	return bytes.NewReader(pcm.EncodeInt16(nil, Int16(samples)))
+}
+
+// NewFloatReader returns a reader of samples as 32-bit little-endian IEEE float bytes.
+func NewFloatReader(samples []float32) io.Reader {
+	return bytes.NewReader(pcm.EncodeFloat32(nil, samples))
+}
@@ -0,0 +1,152 @@
+package signal
+
+import (
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/internal/fft"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestSine(t *testing.T) {
+	samples := Sine(8000, 1000, 0.5, 8)
+	want := []float64{0, 0.5 * math.Sqrt2 / 2, 0.5, 0.5 * math.Sqrt2 / 2, 0, -0.5 * math.Sqrt2 / 2, -0.5, -0.5 * math.Sqrt2 / 2}
+	for i, v := range samples {
+		if math.Abs(float64(v)-want[i]) > 1e-6 {
+			t.Errorf("Sine()[%d] = %v, want %v", i, v, want[i])
+		}
+	}
+	if got := Sine(8000, 1000, 0.5, 0); len(got) != 0 {
+		t.Errorf("len(Sine(n=0)) = %d, want 0", len(got))
+	}
+}
+
+// zeroCrossings counts the sign changes of samples[from:to].
+func zeroCrossings(samples []float32, from, to int) int {
+	n := 0
+	for i := from + 1; i < to; i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSweep(t *testing.T) {
+	const rate = 48000
+	samples := Sweep(rate, 100, 1600, 1, rate)
+	if len(samples) != rate {
+		t.Fatalf("len(Sweep()) = %d, want %d", len(samples), rate)
+	}
+	// Four octaves in a second: the frequency doubles every quarter second.
+	for q := range 4 {
+		got := zeroCrossings(samples, q*rate/4, (q+1)*rate/4)
+		// The mean frequency of an octave from f to 2f is f/ln(2)
+		f := 100 * math.Exp2(float64(q)) / math.Ln2
+		want := int(2 * f / 4)
+		if got < want-2 || got > want+2 {
+			t.Errorf("zero crossings in quarter %d = %d, want about %d", q, got, want)
+		}
+	}
+	if flat := Sweep(rate, 440, 440, 0.5, 100); !slices.Equal(flat, Sine(rate, 440, 0.5, 100)) {
+		t.Error("Sweep() with equal frequencies differs from Sine()")
+	}
+}
+
+func TestWhiteNoise(t *testing.T) {
+	a := WhiteNoise(1, 0.5, 10000)
+	if !slices.Equal(a, WhiteNoise(1, 0.5, 10000)) {
+		t.Error("WhiteNoise() is not deterministic")
+	}
+	if slices.Equal(a, WhiteNoise(2, 0.5, 10000)) {
+		t.Error("WhiteNoise() does not depend on the seed")
+	}
+	var sum, sq float64
+	for _, v := range a {
+		if v < -0.5 || v > 0.5 {
+			t.Fatalf("WhiteNoise() sample %v out of range", v)
+		}
+		sum += float64(v)
+		sq += float64(v) * float64(v)
+	}
+	// Uniform noise in [-a, a] has a mean of 0 and a variance of a²/3
+	if mean := sum / float64(len(a)); math.Abs(mean) > 0.01 {
+		t.Errorf("mean = %v, want about 0", mean)
+	}
+	if variance := sq / float64(len(a)); math.Abs(variance-0.25/3) > 0.005 {
+		t.Errorf("variance = %v, want about %v", variance, 0.25/3)
+	}
+}
+
+func TestSpeechNoise(t *testing.T) {
+	const rate = 16000
+	samples := SpeechNoise(rate, 1, 0.8, rate)
+	if !slices.Equal(samples, SpeechNoise(rate, 1, 0.8, rate)) {
+		t.Error("SpeechNoise() is not deterministic")
+	}
+	peak := 0.0
+	for _, v := range samples {
+		peak = max(peak, math.Abs(float64(v)))
+	}
+	if peak > 0.8 || peak < 0.4 {
+		t.Errorf("peak = %v, want in [0.4, 0.8]", peak)
+	}
+
+	// The level is modulated at 4 Hz: silent between syllables, loud in between.
+	rms := func(from, to int) float64 {
+		var sq float64
+		for _, v := range samples[from:to] {
+			sq += float64(v) * float64(v)
+		}
+		return math.Sqrt(sq / float64(to-from))
+	}
+	pause := rms(0, rate/100)
+	syllable := rms(rate/8-rate/100, rate/8+rate/100)
+	if pause*10 > syllable {
+		t.Errorf("RMS in pause = %v, in syllable = %v, want a modulated level", pause, syllable)
+	}
+
+	// The spectrum falls above 500 Hz: the band around 2 kHz is much weaker than around 300 Hz.
+	const size = 4096
+	spectrum := make([]complex128, size)
+	for i := range size {
+		spectrum[i] = complex(float64(samples[i]), 0)
+	}
+	fft.New(size).Transform(spectrum, false)
+	band := func(freq float64) float64 {
+		bin := int(freq * size / rate)
+		var p float64
+		for _, c := range spectrum[bin-20 : bin+20] {
+			p += real(c)*real(c) + imag(c)*imag(c)
+		}
+		return p
+	}
+	if low, high := band(300), band(2000); high*4 > low {
+		t.Errorf("power around 2 kHz = %v, around 300 Hz = %v, want a falling spectrum", high, low)
+	}
+}
+
+func TestReaders(t *testing.T) {
+	samples := []float32{0, 1, -1, 0.5}
+
+	b, err := io.ReadAll(NewReader(samples))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pcm.DecodeInt16(nil, b), []int16{0, 32767, -32767, 16384}; !slices.Equal(got, want) {
+		t.Errorf("NewReader() = %v, want %v", got, want)
+	}
+	if got := Int16(samples); !slices.Equal(got, pcm.DecodeInt16(nil, b)) {
+		t.Errorf("Int16() = %v, differs from NewReader()", got)
+	}
+
+	b, err = io.ReadAll(NewFloatReader(samples))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pcm.DecodeFloat32(nil, b); !slices.Equal(got, samples) {
+		t.Errorf("NewFloatReader() = %v, want %v", got, samples)
+	}
+}
@@ -2,15 +2,22 @@
 package sonic
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
+	"os"
 	"runtime"
 	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
-	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
 )
 
 var (
@@ -26,25 +33,54 @@ var (
 	// ErrSonicFailed is returned when Sonic fails to process the audio.
 	ErrSonicFailed = errors.New("failed to process audio")
 
+	// ErrShortOutput is returned, wrapped in ErrWrite, when the writer accepts only part of the
+	// output. See Transformer.Write.
+	ErrShortOutput = errors.New("short write to writer")
+
+	// ErrShortInput is returned by Flush when the input is too short to be processed and the
+	// ShortInputError policy is set. See WithShortInput.
+	ErrShortInput = errors.New("input too short")
+
+	// ErrRecovered is returned by Write and Flush when the stream failed and was recreated with
+	// the same settings. The transformer remains usable, but the input buffered in the failed
+	// stream was lost. See WithRecovery.
+	ErrRecovered = errors.New("stream recovered")
+
+	// ErrCacheStore is returned by Cache.Transform when its store fails. See Cache.
+	ErrCacheStore = errors.New("cache store failed")
+
+	// ErrClosed is returned by the methods of a Transformer that write or flush, such as Write,
+	// Flush, ReadFrom and SetWriter, after the transformer was closed.
+	ErrClosed = errors.New("transformer is closed")
+
 	// ErrInternal is returned when an internal error occurs.
 	ErrInternal = errors.New("internal error")
 )
 
 // AudioFormat represents the format of the audio data.
-// It can be either 16-bit signed integer (PCM) or 32-bit IEEE 754 float.
+// It can be 16-bit signed integer (PCM), 32-bit IEEE 754 float, 8-bit unsigned integer, packed
+// 24-bit signed integer, 32-bit signed integer or 64-bit IEEE 754 float.
 type AudioFormat int
 
 // Constants for audio formats
 const (
-	AudioFormatPCM       AudioFormat = 1 // 16-bit signed integer
-	AudioFormatIEEEFloat AudioFormat = 3 // 32-bit IEEE 754 float
+	AudioFormatPCM         AudioFormat = 1  // 16-bit signed integer
+	AudioFormatIEEEFloat   AudioFormat = 3  // 32-bit IEEE 754 float
+	AudioFormatU8          AudioFormat = 8  // 8-bit unsigned integer, 128 is silence
+	AudioFormatPCM24       AudioFormat = 24 // 24-bit signed integer, packed in 3 bytes
+	AudioFormatPCM32       AudioFormat = 32 // 32-bit signed integer
+	AudioFormatIEEEFloat64 AudioFormat = 64 // 64-bit IEEE 754 float
 )
 
 // String returns the string representation of the AudioFormat.
 func (f AudioFormat) String() string {
 	m := map[AudioFormat]string{
-		AudioFormatPCM:       "AudioFormatPCM",
-		AudioFormatIEEEFloat: "AudioFormatIEEEFloat",
+		AudioFormatPCM:         "AudioFormatPCM",
+		AudioFormatIEEEFloat:   "AudioFormatIEEEFloat",
+		AudioFormatU8:          "AudioFormatU8",
+		AudioFormatPCM24:       "AudioFormatPCM24",
+		AudioFormatPCM32:       "AudioFormatPCM32",
+		AudioFormatIEEEFloat64: "AudioFormatIEEEFloat64",
 	}
 	if s, ok := m[f]; ok {
 		return s
@@ -57,14 +93,22 @@ func (AudioFormat) Values() []AudioFormat {
 	return []AudioFormat{
 		AudioFormatPCM,
 		AudioFormatIEEEFloat,
+		AudioFormatU8,
+		AudioFormatPCM24,
+		AudioFormatPCM32,
+		AudioFormatIEEEFloat64,
 	}
 }
 
 // SampleSize returns the size of the audio sample in bytes.
 func (f AudioFormat) SampleSize() int {
 	m := map[AudioFormat]int{
-		AudioFormatPCM:       2, // 16-bit signed integer
-		AudioFormatIEEEFloat: 4, // 32-bit IEEE 754 float
+		AudioFormatPCM:         2, // 16-bit signed integer
+		AudioFormatIEEEFloat:   4, // 32-bit IEEE 754 float
+		AudioFormatU8:          1, // 8-bit unsigned integer
+		AudioFormatPCM24:       3, // 24-bit signed integer
+		AudioFormatPCM32:       4, // 32-bit signed integer
+		AudioFormatIEEEFloat64: 8, // 64-bit IEEE 754 float
 	}
 	if s, ok := m[f]; ok {
 		return s
@@ -72,11 +116,80 @@ func (f AudioFormat) SampleSize() int {
 	return 0
 }
 
+// processFormat returns the format the samples of f are processed in. Unsigned 8-bit samples
+// are widened to int16, as libsonic does, and the other formats are converted to float32, which
+// keeps the resolution of 24-bit samples.
+func (f AudioFormat) processFormat() AudioFormat {
+	switch f {
+	case AudioFormatU8:
+		return AudioFormatPCM
+	case AudioFormatPCM24, AudioFormatPCM32, AudioFormatIEEEFloat64:
+		return AudioFormatIEEEFloat
+	}
+	return f
+}
+
+// toFloat32 converts the little-endian samples of p, in format f, to float32 samples in dst and
+// returns them. f must be processed as float32, see processFormat.
+func (f AudioFormat) toFloat32(dst []float32, p []byte) []float32 {
+	switch f {
+	case AudioFormatPCM24:
+		return pcm.Int24ToFloat32(dst, p, int16Scaling)
+	case AudioFormatPCM32:
+		return pcm.Int32ToFloat32(dst, p, int16Scaling)
+	case AudioFormatIEEEFloat64:
+		return pcm.Float64ToFloat32(dst, p)
+	}
+	return pcm.DecodeFloat32(dst, p)
+}
+
+// fillSilence fills the samples of p, in format f, with silence.
+func (f AudioFormat) fillSilence(p []byte) {
+	if f == AudioFormatU8 {
+		for i := range p {
+			p[i] = 128
+		}
+		return
+	}
+	clear(p)
+}
+
 const (
-	streamBufferSize = 4096 // Buffer size for cgosonic.Stream
+	streamBufferFrames = 2048 // Number of frames exchanged with cgosonic.Stream per call
+	maxStalledWrites   = 3    // Number of retries of a short write that made no progress
+
+	// int16Scaling is the convention for converting between int16 and float32 samples.
+	// It matches libsonic, which converts float input to int16 internally.
+	int16Scaling = pcm.Scaling32767
 )
 
+// streamBufferPool recycles stream buffers of closed Transformers, so that services creating a
+// Transformer per short clip do not allocate a new buffer for each one.
+var streamBufferPool sync.Pool // *[]byte
+
+// getStreamBuffer returns a buffer of size bytes from streamBufferPool, or a new one.
+func getStreamBuffer(size int) *[]byte {
+	if p, ok := streamBufferPool.Get().(*[]byte); ok && cap(*p) >= size {
+		*p = (*p)[:size]
+		return p
+	}
+	b := make([]byte, size)
+	return &b
+}
+
 // Transformer is a struct that transforms audio data using the Sonic library.
+//
+// Real-time use: once created, a Transformer does not allocate on the Go heap in Write, Flush,
+// InputPending or InputLatency, as long as no error occurs and the debug dump mode is off.
+// The input slice passed to Write is only read, never retained, and output is written from an
+// internal buffer that is reused for every call. Together with a writer that does not allocate
+// either (e.g. a pre-sized ring buffer), a Transformer can therefore be driven from a soft
+// real-time audio callback. Note that libsonic may still grow its internal C buffers with
+// realloc when a single Write is much larger than BufferFrames; feeding chunks of at most
+// BufferFrames frames avoids that after the first few calls.
+//
+// Wherever a Transformer converts between int16 and float32 samples, it uses the
+// pcm.Scaling32767 convention of libsonic: ±32767 correspond to ±1.
 type Transformer struct {
 	w           io.Writer
 	sampleRate  int
@@ -86,70 +199,335 @@ type Transformer struct {
 	speed       *float32
 	pitch       *float32
 	rate        *float32
+	nominalRate bool
 	quality     *int
+	chordPitch  bool
+	emphasis    *float32
+	midSideMode bool
+	gains       []float32
+	channels    []int
+	debugDump   DebugDumpFunc
+	output      OutputFunc
+	clipping    FloatClipping
+	outFormat   AudioFormat
+	engine      Engine
+	startupRamp time.Duration
+	fadeIn      time.Duration
+	fadeOut     time.Duration
+	latency     time.Duration
+	shortInput  ShortInputPolicy
+	aligned     bool
+	recovery    Recovery
+	inputTee    io.Writer
+	inputHash   hash.Hash
+	outputHash  hash.Hash
+	spectrogram *Spectrogram
+	tracer      Tracer
+	stage       string
+	inputRate   int
+	ratePolicy  SampleRatePolicy
+	rateWarning SampleRateWarningFunc
+	wantRate    int // Sample rate expected by the caller, 0 if any, see WithExpectedSampleRate
+	mismatch    SampleRateMismatch
+	wantWarning SampleRateWarningFunc
+	bigEndian   bool
+	dither      Dither
+	outRate     int
+	outChannels int
+	clipReport  bool
+	clipFunc    ClippingReportFunc
+	rounding    pcm.Rounding
 
-	stream       *cgosonic.Stream
-	streamBuffer []byte
+	stream         Stream
+	streamBuffer   []byte
+	pooledBuffer   *[]byte // Backing of streamBuffer, returned to streamBufferPool by Close
+	streamChannels int     // Number of channels processed by the stream
+	selectBuffer   []byte
+	saturated      []float32 // Float input with out-of-range samples saturated, see saturateFloat32
+	widenBuffer    []byte    // Input converted to its process format, nil for int16 and float32 input
+	convertBuffer  []byte    // Output samples converted to outFormat
+	emphasizer     *transientEmphasis
+	resampler      *inputResampler
+	orderIn        []byte // Big-endian input converted to little-endian, nil for little-endian
+	orderOut       []byte // Output converted to big-endian
+	fromBuf        []byte // Input read by ReadFrom, allocated on its first call
+	midSide        *midSide
+	debugChunk     int     // Index of the current chunk in debug dump mode
+	rampFrames     int     // Length of the startup ramp in input frames, 0 if there is none or it is over
+	rampPos        int     // Input frames written during the startup ramp
+	fadeTail       []byte  // Output held back for the fade-out, nil if there is none
+	fadeInFrames   int     // Length of the fade-in in output frames
+	fadeInPos      int     // Output frames faded in since the start or the last Flush
+	tornFrame      []byte  // Rest of an output frame the writer failed in the middle of
+	shortHeld      []byte  // Input held back while it is shorter than ShortInputFrames
+	shortPassed    bool    // Whether the input since the last Flush reached ShortInputFrames
+	outputLimit    int     // Number of output frames left to write for padded short input, or -1
+	alignBuf       []byte  // Input held back to complete a chunk, see WithAlignedChunks
+	carryOver      []byte  // Incomplete input frame held back until the next Write
+	inputBytes     int64   // Bytes consumed by Write, see Stats
+	outputBytes    int64   // Bytes delivered to the output, see Stats
+	latencyBuf     []byte  // Output queued for the fixed latency, nil if there is none
+	latencyFrames  int     // Length of the fixed latency in output frames
+	latencyDue     float64 // Fraction of an output frame due but not released yet
+	latencyDebt    int     // Output frames to drop after the silence inserted on underruns
+	underrunFrames int64   // Frames of silence inserted on underruns, see Stats
+	updateMu       sync.Mutex
+	update         *Settings     // Settings passed to Update and not applied yet
+	wallClock      time.Duration // Time spent in Write and Flush, see WallClockSpent
+	clock          Clock         // Source of the time, see WithClock
+	replaying      bool          // Whether Resume is replaying input, whose output is not delivered
+	ditherState    uint32        // State of the dither noise generator, see WithDither
+	outResampler   *Resampler    // Resampler of the output, nil without WithOutputSampleRate
+	resampleIn     []float32     // Output processed as int16 converted for outResampler
+	resampled      []float32     // Output of outResampler
+	remixBuffer    []byte        // Output remixed to outChannels, nil without remixing
+	clippedSamples int64         // Output samples at full scale, see WithClippingReport
+	firstClip      int64         // Output frame of the first clipped sample, or -1
+	clipFrames     int64         // Output frames checked for clipping
+	clipReported   int64         // clippedSamples when the clipping was last reported
+	unflushed      bool          // Whether input was written since the last Flush, see Close
+	closed         bool          // Whether Close was called
+	// Input frames dropped by a RingWriter, see Stats. Atomic, since it is counted by the
+	// goroutine writing to the RingWriter.
+	droppedFrames atomic.Int64
+	// Context of the running WriteContext or FlushContext, nil otherwise.
+	ctx context.Context
 }
 
+// OutputFunc receives the output of a transformer. See WithOutputFunc.
+type OutputFunc func(p []byte) error
+
 // NewTransformer creates a new Transformer instance.
+// w may be nil if the output is delivered with WithOutputFunc.
+// The options set with SetDefaultOptions are applied before opts, and the limits set with
+// SetLimits after them.
 func NewTransformer(w io.Writer, sampleRate int, format AudioFormat, opts ...Option) (*Transformer, error) {
-	if w == nil {
-		return nil, fmt.Errorf("%w: writer is nil", ErrInvalid)
-	}
-	if sampleRate < cgosonic.MIN_SAMPLE_RATE || cgosonic.MAX_SAMPLE_RATE < sampleRate {
-		return nil, fmt.Errorf("%w: sampleRate %d is out of range [%d, %d]", ErrInvalid, sampleRate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE)
-	}
 	if !slices.Contains(format.Values(), format) {
 		return nil, fmt.Errorf("%w: format %v is not supported", ErrInvalid, format)
 	}
 
 	t := &Transformer{
-		w:            w,
-		sampleRate:   sampleRate,
-		numChannels:  1,
-		format:       format,
-		volume:       nil,
-		speed:        nil,
-		pitch:        nil,
-		rate:         nil,
-		quality:      nil,
-		stream:       nil,
-		streamBuffer: nil,
-	}
-	for _, opt := range opts {
+		w:              w,
+		sampleRate:     sampleRate,
+		numChannels:    1,
+		format:         format,
+		volume:         nil,
+		speed:          nil,
+		pitch:          nil,
+		rate:           nil,
+		nominalRate:    false,
+		quality:        nil,
+		chordPitch:     false,
+		emphasis:       nil,
+		midSideMode:    false,
+		gains:          nil,
+		channels:       nil,
+		debugDump:      nil,
+		output:         nil,
+		clipping:       FloatClippingClamp,
+		outFormat:      format,
+		engine:         EngineSonic,
+		startupRamp:    0,
+		fadeIn:         0,
+		fadeOut:        0,
+		latency:        0,
+		shortInput:     ShortInputProcess,
+		aligned:        false,
+		recovery:       RecoveryNone,
+		inputTee:       nil,
+		inputHash:      nil,
+		outputHash:     nil,
+		spectrogram:    nil,
+		tracer:         nil,
+		stage:          "",
+		inputRate:      sampleRate,
+		ratePolicy:     SampleRateError,
+		rateWarning:    nil,
+		wantRate:       0,
+		mismatch:       SampleRateMismatchError,
+		wantWarning:    nil,
+		bigEndian:      false,
+		dither:         DitherNone,
+		outRate:        0,
+		outChannels:    0,
+		clipReport:     false,
+		clipFunc:       nil,
+		rounding:       pcm.RoundHalfAwayFromZero,
+		stream:         nil,
+		streamBuffer:   nil,
+		pooledBuffer:   nil,
+		streamChannels: 0,
+		selectBuffer:   nil,
+		widenBuffer:    nil,
+		convertBuffer:  nil,
+		emphasizer:     nil,
+		resampler:      nil,
+		orderIn:        nil,
+		orderOut:       nil,
+		fromBuf:        nil,
+		midSide:        nil,
+		debugChunk:     0,
+		rampFrames:     0,
+		rampPos:        0,
+		fadeTail:       nil,
+		fadeInFrames:   0,
+		fadeInPos:      0,
+		tornFrame:      nil,
+		shortHeld:      nil,
+		shortPassed:    false,
+		outputLimit:    -1,
+		alignBuf:       nil,
+		carryOver:      nil,
+		inputBytes:     0,
+		outputBytes:    0,
+		latencyBuf:     nil,
+		latencyFrames:  0,
+		latencyDue:     0,
+		latencyDebt:    0,
+		underrunFrames: 0,
+		updateMu:       sync.Mutex{},
+		update:         nil,
+		wallClock:      0,
+		clock:          SystemClock,
+		replaying:      false,
+		ditherState:    ditherSeed,
+		outResampler:   nil,
+		resampleIn:     nil,
+		resampled:      nil,
+		remixBuffer:    nil,
+		clippedSamples: 0,
+		firstClip:      -1,
+		clipFrames:     0,
+		clipReported:   0,
+		droppedFrames:  atomic.Int64{},
+		ctx:            nil,
+	}
+	for _, opt := range append(DefaultOptions(), opts...) {
 		if err := opt(t); err != nil {
 			return nil, err
 		}
 	}
+	t.applyLimits()
+	if err := t.checkExpectedSampleRate(); err != nil {
+		return nil, err
+	}
+	if err := t.applySampleRatePolicy(); err != nil {
+		return nil, err
+	}
+	if t.w == nil && t.output == nil {
+		return nil, fmt.Errorf("%w: writer is nil", ErrInvalid)
+	}
 
-	stream, err := cgosonic.CreateStream(t.sampleRate, t.numChannels)
-	if err != nil {
-		return nil, ErrSonicCreateFailed
+	if t.gains != nil && len(t.gains) != t.numChannels {
+		return nil, fmt.Errorf("%w: %d channel gains given for %d channels", ErrInvalid, len(t.gains), t.numChannels)
+	}
+	if t.debugDump == nil {
+		if dir := os.Getenv(DebugDumpEnv); dir != "" {
+			t.debugDump = DebugDumpDir(dir)
+		}
 	}
-	t.stream = stream
 
-	t.streamBuffer = make([]byte, streamBufferSize)
+	t.streamChannels = t.numChannels
+	if t.channels != nil {
+		if len(t.channels) == 0 {
+			return nil, fmt.Errorf("%w: no channels selected", ErrInvalid)
+		}
+		for _, ch := range t.channels {
+			if ch < 0 || t.numChannels <= ch {
+				return nil, fmt.Errorf("%w: selected channel %d is out of range [0, %d)", ErrInvalid, ch, t.numChannels)
+			}
+		}
+		t.streamChannels = len(t.channels)
+		t.selectBuffer = make([]byte, streamBufferFrames*t.streamChannels*t.format.processFormat().SampleSize())
+		if t.gains != nil {
+			t.gains = selectChannels(make([]float32, len(t.channels)), t.gains, t.numChannels, t.channels)
+		}
+	}
+	if t.midSideMode && t.streamChannels != 2 {
+		return nil, fmt.Errorf("%w: mid-side mode requires 2 channels, got %d", ErrInvalid, t.streamChannels)
+	}
+	if t.outChannels == 0 {
+		t.outChannels = t.streamChannels
+	}
+	if t.outChannels != t.streamChannels && t.outChannels != 1 && t.streamChannels != 1 {
+		return nil, fmt.Errorf("%w: remixing %d channels to %d is not supported", ErrInvalid, t.streamChannels, t.outChannels)
+	}
+	if t.spectrogram != nil && (t.spectrogram.sampleRate != t.sampleRate || t.spectrogram.numChannels != t.streamChannels) {
+		return nil, fmt.Errorf("%w: spectrogram of %d channels at %d Hz does not match the stream of %d channels at %d Hz", ErrInvalid, t.spectrogram.numChannels, t.spectrogram.sampleRate, t.streamChannels, t.sampleRate)
+	}
 
-	if t.volume != nil {
-		stream.SetVolume(*t.volume)
+	if t.midSideMode {
+		ms, err := newMidSide(t.engine, t.sampleRate, t.configureStream)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSonicCreateFailed, err)
+		}
+		t.midSide = ms
+	} else {
+		stream, err := t.engine.NewStream(t.sampleRate, t.streamChannels)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSonicCreateFailed, err)
+		}
+		t.configureStream(stream)
+		t.stream = stream
 	}
-	if t.speed != nil {
-		stream.SetSpeed(*t.speed)
+
+	t.pooledBuffer = getStreamBuffer(streamBufferFrames * t.streamChannels * t.format.processFormat().SampleSize())
+	t.streamBuffer = *t.pooledBuffer
+	if t.format != t.format.processFormat() {
+		t.widenBuffer = make([]byte, streamBufferFrames*t.numChannels*t.format.processFormat().SampleSize())
 	}
-	if t.pitch != nil {
-		stream.SetPitch(*t.pitch)
+
+	outputFrames := streamBufferFrames // Maximum number of frames passed to the output conversion
+	if t.outRate > 0 && t.outRate != t.naturalSampleRate() {
+		resampler, err := NewResampler(t.naturalSampleRate(), t.outRate, t.streamChannels)
+		if err != nil {
+			return nil, err
+		}
+		t.outResampler = resampler
+		outputFrames = max(outputFrames, streamBufferFrames*t.outRate/t.naturalSampleRate()+1)
+		t.resampled = make([]float32, 0, outputFrames*t.streamChannels)
+		if t.format.processFormat() == AudioFormatPCM {
+			t.resampleIn = make([]float32, streamBufferFrames*t.streamChannels)
+		}
 	}
-	if t.rate != nil {
-		stream.SetRate(*t.rate)
+	if t.outFormat != t.format.processFormat() || t.outResampler != nil && t.outFormat != AudioFormatIEEEFloat {
+		// U8 output is converted to int16 first and narrowed in place.
+		t.convertBuffer = make([]byte, outputFrames*t.outChannels*max(t.outFormat.SampleSize(), AudioFormatPCM.SampleSize()))
 	}
-	if t.quality != nil {
-		stream.SetQuality(*t.quality)
+	if t.outChannels != t.streamChannels {
+		t.remixBuffer = make([]byte, outputFrames*t.outChannels*AudioFormatIEEEFloat.SampleSize())
+	}
+
+	if t.speed != nil && *t.speed != 1 {
+		t.rampFrames = SamplesForDuration(t.startupRamp, t.sampleRate, 1)
+	}
+
+	if t.bigEndian && t.format.SampleSize() > 1 {
+		t.orderIn = make([]byte, streamBufferFrames*t.FrameSize())
+	}
+	if t.aligned {
+		t.alignBuf = make([]byte, 0, streamBufferFrames*t.numChannels*t.format.SampleSize())
+	}
+	if t.shortInput != ShortInputProcess {
+		t.shortHeld = make([]byte, 0, ShortInputFrames(t.sampleRate)*t.numChannels*t.format.SampleSize())
+	}
+	t.tornFrame = make([]byte, 0, t.OutputFrameSize())
+	t.fadeInFrames = SamplesForDuration(t.fadeIn, t.OutputSampleRate(), 1)
+	if fadeFrames := SamplesForDuration(t.fadeOut, t.OutputSampleRate(), 1); fadeFrames > 0 {
+		t.fadeTail = make([]byte, 0, fadeFrames*t.OutputFrameSize())
+	}
+	if t.latencyFrames = SamplesForDuration(t.latency, t.OutputSampleRate(), 1); t.latencyFrames > 0 {
+		t.latencyBuf = make([]byte, t.latencyFrames*t.OutputFrameSize(), (t.latencyFrames+streamBufferFrames)*t.OutputFrameSize())
+		t.outFormat.fillSilence(t.latencyBuf)
+	}
+
+	if t.emphasis != nil {
+		t.emphasizer = newTransientEmphasis(t.sampleRate, t.streamChannels, *t.emphasis)
 	}
 
 	runtime.SetFinalizer(t, func(t *Transformer) {
 		if t != nil {
-			t.Close()
+			t.release()
 		}
 	})
 
@@ -157,7 +535,83 @@ func NewTransformer(w io.Writer, sampleRate int, format AudioFormat, opts ...Opt
 }
 
 // Write writes the data to the transformer.
-func (t *Transformer) Write(p []byte) (int, error) {
+//
+// The output is written to the writer in whole frames. If the writer accepts only part of it
+// without an error, the rest is retried; if it makes no progress or fails in the middle of a
+// frame, an error wrapping ErrWrite and ErrShortOutput is returned, and the rest of the torn
+// frame is written before any further output, so that the output stays aligned to frames.
+//
+// p need not be made of whole frames, or even whole samples: a trailing incomplete frame is
+// carried over and completed by the next Write, so that input can be copied from sources that
+// split it at arbitrary byte offsets, e.g. with io.Copy. The incomplete frame is kept across
+// Flush, and discarded by Close.
+func (t *Transformer) Write(p []byte) (n int, err error) {
+	if t.closed {
+		return 0, ErrClosed
+	}
+	defer t.spendWallClock(t.clock.Now())
+	if t.tracer != nil {
+		span, inputBytes, outputBytes := t.startSpan("Write"), t.inputBytes, t.outputBytes
+		defer func() { t.endSpan(span, inputBytes, outputBytes, err) }()
+	}
+	n, err = t.writeCarryOver(p)
+	t.recordInput(p[:n])
+	return t.teeInput(p[:n], err)
+}
+
+// writeInput writes the data to the stream, applying the short input policy and chunk
+// alignment, and returns the number of bytes of p consumed.
+func (t *Transformer) writeInput(p []byte) (int, error) {
+	t.unflushed = true
+	t.applyUpdate()
+	if t.orderIn != nil {
+		return t.writeBigEndian(p)
+	}
+	return t.writeLittleEndian(p)
+}
+
+// writeLittleEndian writes little-endian input. See writeInput.
+func (t *Transformer) writeLittleEndian(p []byte) (int, error) {
+	if t.resampler != nil {
+		return t.writeResampled(p)
+	}
+	return t.writeStreamInput(p)
+}
+
+// writeStreamInput writes input at the sample rate of the stream. See writeInput.
+func (t *Transformer) writeStreamInput(p []byte) (int, error) {
+	var recovered error // See WithRecovery
+	held, err := t.holdShortInput(p)
+	if deferRecovered(&recovered, err) != nil {
+		return 0, err
+	}
+	if held {
+		if err := t.releaseLatency(len(p)); err != nil {
+			return len(p), err
+		}
+		return len(p), recovered
+	}
+	n, err := t.writeAligned(p)
+	if deferRecovered(&recovered, err) != nil {
+		return n, err
+	}
+	if err := t.releaseLatency(n); err != nil {
+		return n, err
+	}
+	return n, recovered
+}
+
+// write writes the data to the stream.
+func (t *Transformer) write(p []byte) (int, error) {
+	switch t.format {
+	case AudioFormatU8:
+		return t.writeUint8(p)
+	case AudioFormatPCM24, AudioFormatPCM32, AudioFormatIEEEFloat64:
+		return t.writeConverted(p)
+	}
+	if t.midSide != nil {
+		return t.writeMidSide(p)
+	}
 	switch t.format {
 	case AudioFormatPCM:
 		return t.writeInt16(p)
@@ -169,33 +623,333 @@ func (t *Transformer) Write(p []byte) (int, error) {
 }
 
 // Flush flushes the transformer.
-func (t *Transformer) Flush() error {
-	switch t.format {
-	case AudioFormatPCM:
-		return t.flushInt16()
-	case AudioFormatIEEEFloat:
-		return t.flushFloat32()
-	default:
-		return fmt.Errorf("%w: format is broken: %d", ErrInternal, t.format)
+//
+// All remaining output is written to the writer. If the writer has a Flush method, as
+// gzip.Writer and bufio.Writer do, it is called afterwards, so that the output written so far
+// reaches the underlying destination.
+func (t *Transformer) Flush() (err error) {
+	if t.closed {
+		return ErrClosed
+	}
+	defer t.spendWallClock(t.clock.Now())
+	if t.tracer != nil {
+		span, inputBytes, outputBytes := t.startSpan("Flush"), t.inputBytes, t.outputBytes
+		defer func() { t.endSpan(span, inputBytes, outputBytes, err) }()
+	}
+	if err := t.contextErr(); err != nil {
+		return err
+	}
+	t.unflushed = false
+	defer func() {
+		if t.contextErr() != nil {
+			t.unflushed = true // The flush is incomplete, see FlushContext
+		}
+	}()
+	t.applyUpdate()
+	defer func() { t.outputLimit = -1 }()
+	var recovered error // See WithRecovery
+	err = t.flushShortInput()
+	if deferRecovered(&recovered, err) != nil {
+		return err
+	}
+	if err := t.flushAligned(); deferRecovered(&recovered, err) != nil {
+		return err
+	}
+
+	if t.midSide != nil {
+		err = t.flushMidSide()
+	} else {
+		switch t.format.processFormat() {
+		case AudioFormatPCM:
+			err = t.flushInt16()
+		case AudioFormatIEEEFloat:
+			err = t.flushFloat32()
+		default:
+			err = fmt.Errorf("%w: format is broken: %d", ErrInternal, t.format)
+		}
+	}
+	if deferRecovered(&recovered, err) != nil {
+		return err
+	}
+	if t.outResampler != nil {
+		t.resampled = t.outResampler.Flush(t.resampled[:0])
+		if err := t.deliverFloat32(t.resampled); err != nil {
+			return err
+		}
+	}
+	if err := t.padOutput(); err != nil {
+		return err
+	}
+	if err := t.flushFade(); err != nil {
+		return err
+	}
+	if err := t.flushLatency(); err != nil {
+		return err
+	}
+	if len(t.tornFrame) > 0 {
+		if err := t.writeOutputNow(nil); err != nil {
+			return err
+		}
 	}
+	if err := t.flushWriter(); err != nil {
+		return err
+	}
+	if err := t.flushInputTee(); err != nil {
+		return err
+	}
+	t.reportClipping()
+	return recovered
 }
 
-// Close closes the transformer and releases resources.
+// flushWriter flushes the writer if it supports flushing.
+func (t *Transformer) flushWriter() error {
+	if t.output != nil {
+		return nil
+	}
+	f, ok := t.w.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+	if err := f.Flush(); err != nil {
+		return fmt.Errorf("%w: failed to flush writer: %w", ErrWrite, err)
+	}
+	return nil
+}
+
+// SetWriter changes the writer the output is written to, e.g. to rotate output files of a
+// continuous stream. It flushes the transformer first, so that all output of the input written so
+// far, including output held back for fades and the fixed latency, goes to the old writer.
+//
+// If the flush fails, the error is returned and the writer is not changed, so the output left
+// over can still be delivered to the old writer, e.g. by calling SetWriter again. An error
+// wrapping ErrRecovered does not prevent the change. It returns an error wrapping ErrInvalid if w
+// is nil or the output goes to the function given with WithOutputFunc.
+func (t *Transformer) SetWriter(w io.Writer) error {
+	if t.closed {
+		return ErrClosed
+	}
+	if w == nil {
+		return fmt.Errorf("%w: writer is nil", ErrInvalid)
+	}
+	if t.output != nil {
+		return fmt.Errorf("%w: output goes to the output function", ErrInvalid)
+	}
+	err := t.Flush()
+	if err != nil && !errors.Is(err, ErrRecovered) {
+		return err
+	}
+	t.w = w
+	return err
+}
+
+// OutputSampleRate returns the sample rate at which the output is meant to be played.
+//
+// It is the rate set with WithOutputSampleRate, if any. Otherwise, it is the input sample rate,
+// or the rate the input is resampled to with SampleRateResample, unless WithNominalRate is given
+// together with WithRate, in which case it is that rate times the rate, rounded to the nearest
+// integer. Use it as the sample rate of the output container, e.g. in the WAV header.
+func (t *Transformer) OutputSampleRate() int {
+	if t.outRate > 0 {
+		return t.outRate
+	}
+	return t.naturalSampleRate()
+}
+
+// naturalSampleRate returns the sample rate of the output before it is resampled with
+// WithOutputSampleRate.
+func (t *Transformer) naturalSampleRate() int {
+	sampleRate := t.sampleRate
+	if t.ratePolicy == SampleRateClamp {
+		sampleRate = t.inputRate // The samples are not converted.
+	}
+	if t.rate == nil || !t.nominalRate {
+		return sampleRate
+	}
+	return int(math.Round(float64(sampleRate) * float64(*t.rate)))
+}
+
+// BufferFrames returns the number of frames exchanged with the Sonic stream per call.
+//
+// Writes are split into chunks of this many frames, and output is read back in chunks of at
+// most this many frames, regardless of the number of channels.
+func (t *Transformer) BufferFrames() int {
+	return streamBufferFrames
+}
+
+// InputPending estimates the number of input frames that have been written to the
+// transformer but are still held inside the Sonic stream, i.e. not yet written to the writer.
+//
+// Live applications can use it to compute the true end-to-end delay, e.g. to compensate lip-sync.
+// The estimate assumes the current speed and rate; see also InputLatency.
+func (t *Transformer) InputPending() int {
+	held := (len(t.shortHeld) + len(t.alignBuf)) / t.FrameSize() // See WithShortInput and WithAlignedChunks
+	if t.midSide != nil {
+		return held + max(t.midSide.mid.PendingInputFrames(), t.midSide.side.PendingInputFrames())
+	}
+	if t.stream == nil {
+		return held
+	}
+	return held + t.stream.PendingInputFrames()
+}
+
+// InputLatency returns the playback duration of the input held inside the Sonic stream.
+// See InputPending.
+func (t *Transformer) InputLatency() time.Duration {
+	return time.Duration(t.InputPending()) * time.Second / time.Duration(t.sampleRate)
+}
+
+// OutputAvailable returns the number of output frames that the transformer has produced
+// but not written to the writer yet: the output ready in the Sonic stream, the output held back
+// for the fade-out and the output queued for the fixed latency, including its silence. Flush
+// writes them.
+//
+// Together with InputPending, it gives the buffering delay of a real-time application,
+// e.g. to decide when to flush. Write drains the Sonic stream before it returns, so its output
+// is only counted while it is written, e.g. from an output function.
+func (t *Transformer) OutputAvailable() int {
+	held := (len(t.fadeTail) + len(t.latencyBuf) + len(t.tornFrame)) / t.OutputFrameSize() // See WithFadeOut and WithFixedLatency
+	ready := 0
+	if t.midSide != nil {
+		ready = min(len(t.midSide.midOut)+samplesAvailable(t.midSide.mid), len(t.midSide.sideOut)+samplesAvailable(t.midSide.side))
+	} else if t.stream != nil {
+		ready = samplesAvailable(t.stream)
+	}
+	if t.outResampler != nil {
+		ready = int(int64(ready) * int64(t.outRate) / int64(t.naturalSampleRate()))
+	}
+	return held + ready
+}
+
+// samplesAvailable returns the number of output frames ready in stream, if it reports them.
+func samplesAvailable(stream Stream) int {
+	if s, ok := stream.(interface{ SamplesAvailable() int }); ok {
+		return s.SamplesAvailable()
+	}
+	return 0
+}
+
+// Close flushes the input written since the last Flush, if any, and then closes the transformer
+// and releases resources, as gzip.Writer.Close does. The resources are released even if the
+// flush fails, and its error is returned. Afterwards, the methods that write or flush return
+// ErrClosed instead of touching the released stream; calling Close again returns nil.
+//
+// Close does not close the writer.
 func (t *Transformer) Close() error {
+	if t.closed {
+		return nil
+	}
+	var err error
+	if t.unflushed {
+		err = t.Flush()
+	}
+	t.release()
+	return err
+}
+
+// release closes the transformer without flushing it and releases resources. The finalizer
+// calls it, since the output must not be written from the garbage collector.
+func (t *Transformer) release() {
+	t.closed = true
 	if t.stream != nil {
 		t.stream.DestroyStream()
 		t.stream = nil
 	}
-	if t.streamBuffer != nil {
-		t.streamBuffer = nil
+	if t.midSide != nil {
+		t.midSide.destroy()
+		t.midSide = nil
 	}
-	return nil
+	if t.pooledBuffer != nil {
+		streamBufferPool.Put(t.pooledBuffer)
+		t.pooledBuffer = nil
+	}
+	t.streamBuffer = nil
+	t.carryOver = nil
+}
+
+// configureStream applies the configured parameters to stream.
+func (t *Transformer) configureStream(stream Stream) {
+	if t.volume != nil {
+		stream.SetVolume(*t.volume)
+	}
+	speed := float32(1)
+	if t.speed != nil {
+		speed = *t.speed
+		stream.SetSpeed(speed)
+	}
+	stream.SetPitch(t.streamPitch(speed))
+	if t.rate != nil && !t.nominalRate {
+		stream.SetRate(*t.rate)
+	}
+	if t.quality != nil {
+		stream.SetQuality(*t.quality)
+	}
+	if t.chordPitch {
+		setChordPitch(stream, 1)
+	}
+}
+
+// setChordPitch sets the chord pitch mode of stream, if it supports it, as the libsonic streams
+// of both the cgo and the pure-Go builds do.
+func setChordPitch(stream Stream, useChordPitch int) {
+	if s, ok := stream.(interface{ SetChordPitch(useChordPitch int) }); ok {
+		s.SetChordPitch(useChordPitch)
+	}
+}
+
+// writeUint8 widens unsigned 8-bit data to int16 and writes it to the transformer.
+func (t *Transformer) writeUint8(p []byte) (int, error) {
+	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
+	for len(p) > 0 {
+		chunk := p[:min(len(p), len(t.widenBuffer)/AudioFormatPCM.SampleSize())]
+		wide := t.widenBuffer[:len(chunk)*AudioFormatPCM.SampleSize()]
+		pcm.Uint8ToInt16(t.unsafeBytesAsInt16Slice(wide), chunk)
+		var n int
+		var err error
+		if t.midSide != nil {
+			n, err = t.writeMidSide(wide)
+		} else {
+			n, err = t.writeInt16(wide)
+		}
+		numWrittenBytes += n / AudioFormatPCM.SampleSize()
+		if deferRecovered(&recovered, err) != nil {
+			return numWrittenBytes, err
+		}
+		p = p[len(chunk):]
+	}
+	return numWrittenBytes, recovered
+}
+
+// writeConverted converts PCM24, PCM32 or float64 data to float32 and writes it to the
+// transformer.
+func (t *Transformer) writeConverted(p []byte) (int, error) {
+	sampleSize := t.format.SampleSize()
+	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
+	for len(p) > 0 {
+		chunk := p[:min(len(p), len(t.widenBuffer)/AudioFormatIEEEFloat.SampleSize()*sampleSize)]
+		wide := t.widenBuffer[:len(chunk)/sampleSize*AudioFormatIEEEFloat.SampleSize()]
+		t.format.toFloat32(t.unsafeBytesAsFloat32Slice(wide), chunk)
+		var n int
+		var err error
+		if t.midSide != nil {
+			n, err = t.writeMidSide(wide)
+		} else {
+			n, err = t.writeFloat32(wide)
+		}
+		numWrittenBytes += n / AudioFormatIEEEFloat.SampleSize() * sampleSize
+		if deferRecovered(&recovered, err) != nil {
+			return numWrittenBytes, err
+		}
+		p = p[len(chunk):]
+	}
+	return numWrittenBytes, recovered
 }
 
 // writeInt16 writes int16 data to the transformer.
 func (t *Transformer) writeInt16(p []byte) (int, error) {
-	sampleSize := t.format.SampleSize()
-	streamBufferSampleSize := streamBufferSize / sampleSize // Number of samples in the stream buffer
+	sampleSize := AudioFormatPCM.SampleSize()
+	chunkSize := streamBufferFrames * t.numChannels // Number of input samples written to the stream per call
 
 	if len(p)%sampleSize != 0 {
 		return 0, fmt.Errorf("%w: 'p' must be a multiple of the int16 type size", ErrInvalid)
@@ -206,39 +960,91 @@ func (t *Transformer) writeInt16(p []byte) (int, error) {
 	}
 
 	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
 
 	for {
-		size := min(len(samples), streamBufferSampleSize)
+		if err := t.contextErr(); err != nil && len(samples) > 0 {
+			return numWrittenBytes, err
+		}
+		size := t.rampChunk(min(len(samples), chunkSize))
 		if size <= 0 {
 			break
 		}
-		okInt := t.stream.WriteShortToStream(samples[:size], size/t.numChannels)
-		if okInt == 0 {
-			return numWrittenBytes, fmt.Errorf("%w: failed to write samples to stream", ErrSonicFailed)
+		in := samples[:size]
+		if t.channels != nil {
+			in = selectChannels(t.unsafeBytesAsInt16Slice(t.selectBuffer), in, t.numChannels, t.channels)
+		}
+		if err := dump(t, DebugStageInput, in); err != nil {
+			return numWrittenBytes, err
+		}
+		addSpectrogram(t, in)
+		if t.stream.WriteShortToStream(in, len(in)/t.streamChannels) == 0 {
+			var fatal error
+			recovered, fatal = t.recoverStream("write samples to stream", nil)
+			if fatal != nil {
+				return numWrittenBytes, fatal
+			}
+			if t.recovery == RecoveryRetry && t.stream.WriteShortToStream(in, len(in)/t.streamChannels) == 0 {
+				return numWrittenBytes, fmt.Errorf("%w: failed to write samples to recreated stream", ErrSonicFailed)
+			}
 		}
 		numWrittenBytes += size * sampleSize
 
 		buf := t.unsafeBytesAsInt16Slice(t.streamBuffer)
+		maxFrames := len(buf) / t.streamChannels
 		for {
-			nRead := t.stream.ReadShortFromStream(buf, len(buf)/t.numChannels)
+			nRead := t.stream.ReadShortFromStream(buf, maxFrames)
 			if nRead <= 0 {
 				break
 			}
-			if err := binary.Write(t.w, binary.LittleEndian, buf[:nRead]); err != nil {
-				return numWrittenBytes, fmt.Errorf("%w: failed to write samples: %w", ErrWrite, err)
+			if err := t.emitInt16(buf[:nRead*t.streamChannels]); err != nil {
+				return numWrittenBytes, err
+			}
+			if nRead < maxFrames {
+				break // A short read means the stream is drained.
 			}
 		}
 
+		t.debugChunk++
 		samples = samples[size:]
 	}
 
-	return numWrittenBytes, nil
+	return numWrittenBytes, recovered
+}
+
+// saturateFloat32 returns samples with NaN replaced by silence and samples beyond full scale
+// clamped to ±1, since libsonic converts float input to short without saturation, so that it
+// would wrap around. samples itself is not modified: it is copied if it has to change.
+func (t *Transformer) saturateFloat32(samples []float32) []float32 {
+	for i, v := range samples {
+		if -1 <= v && v <= 1 {
+			continue
+		}
+		if cap(t.saturated) < len(samples) {
+			t.saturated = make([]float32, len(samples))
+		}
+		out := t.saturated[:len(samples)]
+		copy(out, samples[:i])
+		for j, v := range samples[i:] {
+			switch {
+			case v > 1:
+				v = 1
+			case v < -1:
+				v = -1
+			case v != v:
+				v = 0
+			}
+			out[i+j] = v
+		}
+		return out
+	}
+	return samples
 }
 
 // writeFloat32 writes float32 data to the transformer.
 func (t *Transformer) writeFloat32(p []byte) (int, error) {
-	sampleSize := t.format.SampleSize()
-	streamBufferSampleSize := streamBufferSize / sampleSize // Number of samples in the stream buffer
+	sampleSize := AudioFormatIEEEFloat.SampleSize()
+	chunkSize := streamBufferFrames * t.numChannels // Number of input samples written to the stream per call
 
 	if len(p)%sampleSize != 0 {
 		return 0, fmt.Errorf("%w: 'p' must be a multiple of the float32 type size", ErrInvalid)
@@ -249,65 +1055,383 @@ func (t *Transformer) writeFloat32(p []byte) (int, error) {
 	}
 
 	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
 
 	for {
-		size := min(len(samples), streamBufferSampleSize)
+		if err := t.contextErr(); err != nil && len(samples) > 0 {
+			return numWrittenBytes, err
+		}
+		size := t.rampChunk(min(len(samples), chunkSize))
 		if size <= 0 {
 			break
 		}
-		okInt := t.stream.WriteFloatToStream(samples[:size], size/t.numChannels)
-		if okInt == 0 {
-			return numWrittenBytes, fmt.Errorf("%w: failed to write samples to stream", ErrSonicFailed)
+		in := samples[:size]
+		if t.channels != nil {
+			in = selectChannels(t.unsafeBytesAsFloat32Slice(t.selectBuffer), in, t.numChannels, t.channels)
+		}
+		in = t.saturateFloat32(in)
+		if err := dump(t, DebugStageInput, in); err != nil {
+			return numWrittenBytes, err
+		}
+		addSpectrogram(t, in)
+		if t.stream.WriteFloatToStream(in, len(in)/t.streamChannels) == 0 {
+			var fatal error
+			recovered, fatal = t.recoverStream("write samples to stream", nil)
+			if fatal != nil {
+				return numWrittenBytes, fatal
+			}
+			if t.recovery == RecoveryRetry && t.stream.WriteFloatToStream(in, len(in)/t.streamChannels) == 0 {
+				return numWrittenBytes, fmt.Errorf("%w: failed to write samples to recreated stream", ErrSonicFailed)
+			}
 		}
 		numWrittenBytes += size * sampleSize
 
 		buf := t.unsafeBytesAsFloat32Slice(t.streamBuffer)
+		maxFrames := len(buf) / t.streamChannels
 		for {
-			nRead := t.stream.ReadFloatFromStream(buf, len(buf)/t.numChannels)
+			nRead := t.stream.ReadFloatFromStream(buf, maxFrames)
 			if nRead <= 0 {
 				break
 			}
-			if err := binary.Write(t.w, binary.LittleEndian, buf[:nRead]); err != nil {
-				return numWrittenBytes, fmt.Errorf("%w: failed to write samples: %w", ErrWrite, err)
+			if err := t.emitFloat32(buf[:nRead*t.streamChannels]); err != nil {
+				return numWrittenBytes, err
+			}
+			if nRead < maxFrames {
+				break // A short read means the stream is drained.
 			}
 		}
 
+		t.debugChunk++
 		samples = samples[size:]
 	}
 
-	return numWrittenBytes, nil
+	return numWrittenBytes, recovered
+}
+
+// writeMidSide writes stereo data to the transformer in mid-side mode.
+func (t *Transformer) writeMidSide(p []byte) (int, error) {
+	sampleSize := t.format.processFormat().SampleSize()
+	chunkSize := streamBufferFrames * t.numChannels // Number of input samples written to the stream per call
+
+	if len(p)%(sampleSize*t.numChannels) != 0 {
+		return 0, fmt.Errorf("%w: 'p' must be a multiple of the frame size", ErrInvalid)
+	}
+
+	numWrittenBytes := 0
+	var recovered error // Reported once all of p is written
+	for len(p) > 0 {
+		if err := t.contextErr(); err != nil {
+			return numWrittenBytes, err
+		}
+		size := t.rampChunk(min(len(p), chunkSize*sampleSize)/sampleSize) * sampleSize
+		var in []float32
+		switch t.format.processFormat() {
+		case AudioFormatPCM:
+			in16 := t.unsafeBytesAsInt16Slice(p[:size])
+			if t.channels != nil {
+				in16 = selectChannels(t.unsafeBytesAsInt16Slice(t.selectBuffer), in16, t.numChannels, t.channels)
+			}
+			if err := dump(t, DebugStageInput, in16); err != nil {
+				return numWrittenBytes, err
+			}
+			addSpectrogram(t, in16)
+			t.midSide.convBuf = pcm.Int16ToFloat32(t.midSide.convBuf, in16, int16Scaling)
+			in = t.midSide.convBuf
+		case AudioFormatIEEEFloat:
+			in = t.unsafeBytesAsFloat32Slice(p[:size])
+			if t.channels != nil {
+				in = selectChannels(t.unsafeBytesAsFloat32Slice(t.selectBuffer), in, t.numChannels, t.channels)
+			}
+			in = t.saturateFloat32(in)
+			if err := dump(t, DebugStageInput, in); err != nil {
+				return numWrittenBytes, err
+			}
+			addSpectrogram(t, in)
+		}
+		if err := t.midSide.write(in); err != nil {
+			var fatal error
+			recovered, fatal = t.recoverStream("write samples to streams", err)
+			if fatal != nil {
+				return numWrittenBytes, fatal
+			}
+			if t.recovery == RecoveryRetry {
+				if err := t.midSide.write(in); err != nil {
+					return numWrittenBytes, fmt.Errorf("%w: %w", ErrSonicFailed, err)
+				}
+			}
+		}
+		numWrittenBytes += size
+
+		if err := t.emitMidSide(); err != nil {
+			return numWrittenBytes, err
+		}
+		t.debugChunk++
+		p = p[size:]
+	}
+
+	return numWrittenBytes, recovered
+}
+
+// flushMidSide flushes the transformer in mid-side mode.
+func (t *Transformer) flushMidSide() error {
+	if err := t.midSide.flush(); err != nil {
+		recovered, fatal := t.recoverStream("flush streams", err)
+		if fatal != nil {
+			return fatal
+		}
+		return recovered
+	}
+	return t.emitMidSide()
+}
+
+// emitMidSide writes all re-matrixed output of the mid-side processor to the writer.
+func (t *Transformer) emitMidSide() error {
+	for {
+		switch t.format.processFormat() {
+		case AudioFormatPCM:
+			buf := t.unsafeBytesAsInt16Slice(t.streamBuffer)
+			n := t.midSide.readInt16(buf)
+			if n <= 0 {
+				return nil
+			}
+			if err := t.emitInt16(buf[:n*2]); err != nil {
+				return err
+			}
+		case AudioFormatIEEEFloat:
+			buf := t.unsafeBytesAsFloat32Slice(t.streamBuffer)
+			n := t.midSide.read(buf)
+			if n <= 0 {
+				return nil
+			}
+			if err := t.emitFloat32(buf[:n*2]); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: format is broken: %d", ErrInternal, t.format)
+		}
+	}
 }
 
 func (t *Transformer) flushInt16() error {
-	ret := t.stream.FlushStream()
-	if ret == 0 {
-		return fmt.Errorf("%w: failed to flush stream", ErrSonicFailed)
+	buf := t.unsafeBytesAsInt16Slice(t.streamBuffer)
+	maxFrames := len(buf) / t.streamChannels
+	n := t.stream.FlushAndReadShort(buf, maxFrames)
+	if n < 0 {
+		recovered, fatal := t.recoverStream("flush stream", nil)
+		if fatal != nil {
+			return fatal
+		}
+		return recovered
 	}
-	for t.stream.SamplesAvailable() > 0 {
-		samples := make([]int16, t.stream.SamplesAvailable())
-		n := t.stream.ReadShortFromStream(samples, len(samples))
-		if n <= 0 {
-			return fmt.Errorf("%w: failed to read samples from stream", ErrSonicFailed)
+	for n > 0 {
+		if err := t.emitInt16(buf[:n*t.streamChannels]); err != nil {
+			return err
 		}
-		if err := binary.Write(t.w, binary.LittleEndian, samples[:n]); err != nil {
-			return fmt.Errorf("%w: failed to write samples: %w", ErrWrite, err)
+		if n < maxFrames {
+			break // A short read means the stream is drained.
 		}
+		if err := t.contextErr(); err != nil {
+			return err
+		}
+		n = t.stream.ReadShortFromStream(buf, maxFrames)
 	}
 	return nil
 }
 
 func (t *Transformer) flushFloat32() error {
-	ret := t.stream.FlushStream()
-	if ret == 0 {
-		return fmt.Errorf("%w: failed to flush stream", ErrSonicFailed)
+	buf := t.unsafeBytesAsFloat32Slice(t.streamBuffer)
+	maxFrames := len(buf) / t.streamChannels
+	n := t.stream.FlushAndReadFloat(buf, maxFrames)
+	if n < 0 {
+		recovered, fatal := t.recoverStream("flush stream", nil)
+		if fatal != nil {
+			return fatal
+		}
+		return recovered
+	}
+	for n > 0 {
+		if err := t.emitFloat32(buf[:n*t.streamChannels]); err != nil {
+			return err
+		}
+		if n < maxFrames {
+			break // A short read means the stream is drained.
+		}
+		if err := t.contextErr(); err != nil {
+			return err
+		}
+		n = t.stream.ReadFloatFromStream(buf, maxFrames)
+	}
+	return nil
+}
+
+// emitInt16 applies the output post filters to samples and writes them to the writer.
+// samples is encoded in place, so its contents are undefined afterwards.
+func (t *Transformer) emitInt16(samples []int16) error {
+	if t.gains != nil {
+		applyGainsInt16(samples, t.gains)
+	}
+	if t.emphasizer != nil {
+		t.emphasizer.processInt16(samples)
+	}
+	if t.outResampler != nil {
+		in := pcm.Int16ToFloat32(t.resampleIn, samples, int16Scaling)
+		t.resampled = t.outResampler.Resample(t.resampled[:0], in)
+		return t.deliverFloat32(t.resampled)
+	}
+	t.countClippedInt16(samples)
+	if err := dump(t, DebugStageOutput, samples); err != nil {
+		return err
+	}
+	if t.remixBuffer != nil {
+		samples = remixChannels(t.unsafeBytesAsInt16Slice(t.remixBuffer), samples, t.streamChannels, t.outChannels)
+	}
+	switch t.outFormat {
+	case AudioFormatIEEEFloat:
+		out := pcm.Int16ToFloat32(t.unsafeBytesAsFloat32Slice(t.convertBuffer), samples, int16Scaling)
+		return t.writeOutput(float32SliceAsLittleEndian(out))
+	case AudioFormatU8:
+		return t.writeOutput(pcm.Int16ToUint8(t.convertBuffer, samples))
+	case AudioFormatPCM24:
+		return t.writeOutput(pcm.Int16ToInt24(t.convertBuffer, samples))
+	case AudioFormatPCM32:
+		return t.writeOutput(pcm.Int16ToInt32(t.convertBuffer, samples))
+	case AudioFormatIEEEFloat64:
+		// Converted to float32 in the second half of the buffer and widened in place.
+		half := t.convertBuffer[len(samples)*AudioFormatIEEEFloat.SampleSize() : len(samples)*AudioFormatIEEEFloat64.SampleSize()]
+		out := pcm.Int16ToFloat32(t.unsafeBytesAsFloat32Slice(half), samples, int16Scaling)
+		return t.writeOutput(pcm.Float32ToFloat64(t.convertBuffer, out))
+	}
+	return t.writeOutput(int16SliceAsLittleEndian(samples))
+}
+
+// emitFloat32 applies the output post filters to samples and writes them to the writer.
+// samples is encoded in place, so its contents are undefined afterwards.
+func (t *Transformer) emitFloat32(samples []float32) error {
+	if t.gains != nil {
+		applyGainsFloat32(samples, t.gains)
+	}
+	if t.emphasizer != nil {
+		t.emphasizer.processFloat32(samples)
+	}
+	if t.outResampler != nil {
+		t.resampled = t.outResampler.Resample(t.resampled[:0], samples)
+		samples = t.resampled
+	}
+	return t.deliverFloat32(samples)
+}
+
+// deliverFloat32 clips, remixes, dithers and converts samples to the output format, after the
+// output filters and the resampler, and writes them to the writer. samples is encoded in place,
+// so its contents are undefined afterwards.
+func (t *Transformer) deliverFloat32(samples []float32) error {
+	// The resampler may deliver more than a stream buffer of output on Flush.
+	numSamples := len(samples) / t.streamChannels * t.outChannels
+	if size := numSamples * max(t.outFormat.SampleSize(), AudioFormatPCM.SampleSize()); t.outFormat != AudioFormatIEEEFloat && len(t.convertBuffer) < size {
+		t.convertBuffer = make([]byte, size)
+	}
+	if size := numSamples * AudioFormatIEEEFloat.SampleSize(); t.remixBuffer != nil && len(t.remixBuffer) < size {
+		t.remixBuffer = make([]byte, size)
+	}
+	t.countClippedFloat32(samples)
+	clipFloat32(samples, t.clipping)
+	if err := dump(t, DebugStageOutput, samples); err != nil {
+		return err
+	}
+	if t.remixBuffer != nil {
+		samples = remixChannels(t.unsafeBytesAsFloat32Slice(t.remixBuffer), samples, t.streamChannels, t.outChannels)
 	}
-	for t.stream.SamplesAvailable() > 0 {
-		samples := make([]float32, t.stream.SamplesAvailable())
-		n := t.stream.ReadFloatFromStream(samples, len(samples))
-		if n <= 0 {
-			return fmt.Errorf("%w: failed to read samples from stream", ErrSonicFailed)
+	if t.dither != DitherNone {
+		t.ditherFloat32(samples)
+	}
+	switch t.outFormat {
+	case AudioFormatPCM:
+		out := pcm.Float32ToInt16Rounded(t.unsafeBytesAsInt16Slice(t.convertBuffer), samples, int16Scaling, t.rounding)
+		return t.writeOutput(int16SliceAsLittleEndian(out))
+	case AudioFormatU8:
+		out := pcm.Float32ToInt16Rounded(t.unsafeBytesAsInt16Slice(t.convertBuffer), samples, int16Scaling, t.rounding)
+		return t.writeOutput(pcm.Int16ToUint8(t.convertBuffer, out)) // Narrowed in place
+	case AudioFormatPCM24:
+		return t.writeOutput(pcm.Float32ToInt24(t.convertBuffer, samples, int16Scaling))
+	case AudioFormatPCM32:
+		return t.writeOutput(pcm.Float32ToInt32(t.convertBuffer, samples, int16Scaling))
+	case AudioFormatIEEEFloat64:
+		return t.writeOutput(pcm.Float32ToFloat64(t.convertBuffer, samples))
+	}
+	return t.writeOutput(float32SliceAsLittleEndian(samples))
+}
+
+// writeOutput delivers p to the output, applying the fade-in and holding back the output to
+// fade out, if any.
+func (t *Transformer) writeOutput(p []byte) error {
+	p = t.limitOutput(p)
+	if len(p) == 0 {
+		return nil
+	}
+	if t.fadeInPos < t.fadeInFrames {
+		t.applyFadeIn(p)
+	}
+	if t.fadeTail != nil {
+		return t.writeDelayed(p)
+	}
+	return t.queueOutput(p)
+}
+
+// writeOutputNow delivers p to the output function if set, or to the writer otherwise.
+func (t *Transformer) writeOutputNow(p []byte) error {
+	if t.bigEndian && t.outFormat.SampleSize() > 1 {
+		p = t.bigEndianOutput(p)
+	}
+	if t.replaying {
+		t.recordOutput(p) // Delivered before the transformer was resumed, see Resume
+		return nil
+	}
+	if t.output != nil {
+		if len(p) == 0 {
+			return nil
+		}
+		if err := t.output(p); err != nil {
+			return fmt.Errorf("%w: output function failed: %w", ErrWrite, err)
 		}
-		if err := binary.Write(t.w, binary.LittleEndian, samples[:n]); err != nil {
+		t.recordOutput(p)
+		return nil
+	}
+	if len(t.tornFrame) > 0 {
+		if err := t.writeFrames(t.tornFrame); err != nil {
+			return err
+		}
+		t.tornFrame = t.tornFrame[:0]
+	}
+	return t.writeFrames(p)
+}
+
+// writeFrames writes the whole frames of p to the writer, retrying short writes as long as the
+// writer makes progress. If the writer fails in the middle of a frame, the rest of the frame is
+// kept in tornFrame and written before any further output, so that the output stays aligned to
+// frames.
+func (t *Transformer) writeFrames(p []byte) error {
+	total := len(p)
+	stalled := 0
+	for len(p) > 0 {
+		n, err := t.w.Write(p)
+		n = clamp(n, 0, len(p))
+		t.recordOutput(p[:n])
+		p = p[n:]
+		if err == nil && len(p) > 0 {
+			if n > 0 {
+				stalled = 0
+				continue
+			}
+			if stalled++; stalled < maxStalledWrites {
+				continue
+			}
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			frameSize := t.OutputFrameSize()
+			t.tornFrame = t.tornFrame[:copy(t.tornFrame[:cap(t.tornFrame)], p[:len(p)%frameSize])]
+			if len(t.tornFrame) > 0 || errors.Is(err, io.ErrShortWrite) {
+				return fmt.Errorf("%w: %w: %d of %d bytes written: %w", ErrWrite, ErrShortOutput, total-len(p), total, err)
+			}
 			return fmt.Errorf("%w: failed to write samples: %w", ErrWrite, err)
 		}
 	}
@@ -319,7 +1443,7 @@ func (t *Transformer) unsafeBytesAsInt16Slice(p []byte) []int16 {
 	if numSamples == 0 {
 		return nil
 	}
-	return (*[1 << 30]int16)(unsafe.Pointer(&p[0]))[:numSamples]
+	return unsafe.Slice((*int16)(unsafe.Pointer(&p[0])), numSamples)
 }
 
 func (t *Transformer) unsafeBytesAsFloat32Slice(p []byte) []float32 {
@@ -327,5 +1451,31 @@ func (t *Transformer) unsafeBytesAsFloat32Slice(p []byte) []float32 {
 	if numSamples == 0 {
 		return nil
 	}
-	return (*[1 << 30]float32)(unsafe.Pointer(&p[0]))[:numSamples]
+	return unsafe.Slice((*float32)(unsafe.Pointer(&p[0])), numSamples)
+}
+
+// int16SliceAsLittleEndian encodes samples as little-endian bytes in place and returns the bytes.
+// The contents of samples are undefined afterwards. It does not allocate.
+func int16SliceAsLittleEndian(samples []int16) []byte {
+	if len(samples) == 0 {
+		return nil
+	}
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(s))
+	}
+	return b
+}
+
+// float32SliceAsLittleEndian encodes samples as little-endian bytes in place and returns the bytes.
+// The contents of samples are undefined afterwards. It does not allocate.
+func float32SliceAsLittleEndian(samples []float32) []byte {
+	if len(samples) == 0 {
+		return nil
+	}
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), len(samples)*4)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(s))
+	}
+	return b
 }
@@ -2,15 +2,24 @@ package sonic
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"os"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
+	"github.com/nakat-t/sonic-go/audiotest"
 	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
 )
 
 // Mock writer that can fail
@@ -126,7 +135,7 @@ func TestNewTransformer(t *testing.T) {
 				if tr == nil {
 					t.Fatal("transformer should not be nil")
 				}
-				expectedBufLen := streamBufferSize
+				expectedBufLen := streamBufferFrames * 2 // 1 channel, 2 bytes per sample
 				if len(tr.streamBuffer) != expectedBufLen {
 					t.Errorf("streamBuffer length = %d, want %d", len(tr.streamBuffer), expectedBufLen)
 				}
@@ -146,7 +155,7 @@ func TestNewTransformer(t *testing.T) {
 				if tr == nil {
 					t.Fatal("transformer should not be nil")
 				}
-				expectedBufLen := streamBufferSize
+				expectedBufLen := streamBufferFrames * 4 // 1 channel, 4 bytes per sample
 				if len(tr.streamBuffer) != expectedBufLen {
 					t.Errorf("streamBuffer length = %d, want %d", len(tr.streamBuffer), expectedBufLen)
 				}
@@ -235,7 +244,7 @@ func TestNewTransformer(t *testing.T) {
 			if transformer != nil && transformer.stream != nil {
 				// Ensure stream is destroyed after test, if created.
 				// This should ideally be handled by a Close method on Transformer.
-				defer func(s *cgosonic.Stream) {
+				defer func(s Stream) {
 					if s != nil {
 						s.DestroyStream()
 					}
@@ -308,7 +317,7 @@ func TestTransformer_Write(t *testing.T) {
 	}
 
 	// Generate larger data to ensure stream buffer is filled and flushed multiple times if necessary
-	largeInt16Data := make([]int16, streamBufferSize*2) // Enough to fill buffer and require multiple reads
+	largeInt16Data := make([]int16, streamBufferFrames*4) // Enough to fill buffer and require multiple reads
 	largeInt16Bytes := make([]byte, len(largeInt16Data)*2)
 	for i := range largeInt16Data {
 		largeInt16Data[i] = int16(i % 256)
@@ -360,18 +369,20 @@ func TestTransformer_Write(t *testing.T) {
 			},
 		},
 		{
-			name:      "int16 invalid data length (odd)",
+			name:      "int16 incomplete sample carried over",
 			format:    AudioFormatPCM,
 			inputData: []byte{1, 2, 3},
-			wantErr:   ErrInvalid,
-			expectedN: 0,
+			writer:    new(bytes.Buffer),
+			wantErr:   nil,
+			expectedN: 3,
 		},
 		{
-			name:      "float32 invalid data length (not multiple of 4)",
+			name:      "float32 incomplete sample carried over",
 			format:    AudioFormatIEEEFloat,
 			inputData: []byte{1, 2, 3, 4, 5},
-			wantErr:   ErrInvalid,
-			expectedN: 0,
+			writer:    new(bytes.Buffer),
+			wantErr:   nil,
+			expectedN: 5,
 		},
 		{
 			name:      "write error from underlying writer (int16)",
@@ -384,7 +395,7 @@ func TestTransformer_Write(t *testing.T) {
 			// If binary.Write fails, it returns numWrittenBytes up to that point.
 			// For this test, we expect an error. The exact `n` might vary.
 		},
-		// Cannot easily test cgosonic.Stream.WriteShortToStream/WriteFloatToStream failure without mocking cgo.
+		// Stream write failures are tested with a fake stream in stream_test.go.
 	}
 
 	for _, tc := range testCases {
@@ -465,7 +476,7 @@ func TestTransformer_Flush(t *testing.T) {
 				// Behavior depends on sonic lib; likely empty output if no data processed.
 			},
 		},
-		// Cannot easily test cgosonic.Stream.FlushStream/ReadShortFromStream failure without mocking cgo.
+		// Stream flush failures are tested with a fake stream in stream_test.go.
 	}
 
 	for _, tc := range testCases {
@@ -502,6 +513,144 @@ func TestTransformer_Flush(t *testing.T) {
 	}
 }
 
+func TestTransformer_Close(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	tr.Write(speech)
+	tr.Flush()
+	tr.Close()
+
+	t.Run("flushes", func(t *testing.T) {
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		if _, err := tr.Write(speech); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), want.Bytes()) {
+			t.Errorf("output = %d bytes, want the %d bytes of Write and Flush", out.Len(), want.Len())
+		}
+	})
+
+	t.Run("after Flush", func(t *testing.T) {
+		fw := &failingWriter{err: errors.New("write after Flush"), bytesUntilFail: math.MaxInt}
+		tr, err := NewTransformer(fw, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		tr.Write(speech)
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		fw.bytesUntilFail = fw.writtenBytes
+		if err := tr.Close(); err != nil {
+			t.Errorf("Close() after Flush error = %v, want nil", err)
+		}
+	})
+
+	t.Run("flush error", func(t *testing.T) {
+		errFail := errors.New("write failed")
+		fw := &failingWriter{err: errFail, bytesUntilFail: math.MaxInt}
+		tr, err := NewTransformer(fw, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		tr.Write(speech)
+		fw.bytesUntilFail = fw.writtenBytes
+		if err := tr.Close(); !errors.Is(err, ErrWrite) || !errors.Is(err, errFail) {
+			t.Errorf("Close() error = %v, want %v wrapping %v", err, ErrWrite, errFail)
+		}
+		if tr.stream != nil {
+			t.Error("Close() did not release the stream after the flush failed")
+		}
+	})
+
+	t.Run("double Close", func(t *testing.T) {
+		for _, opts := range [][]Option{nil, {WithChannels(2), WithMidSide()}} {
+			tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			tr.Write(speech)
+			if err := tr.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if err := tr.Close(); err != nil {
+				t.Errorf("second Close() error = %v, want nil", err)
+			}
+		}
+	})
+}
+
+// TestTransformer_UseAfterClose calls every method of a closed transformer, which must not touch
+// the released stream.
+func TestTransformer_UseAfterClose(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	tests := []struct {
+		name       string
+		sampleRate int
+		opts       []Option
+	}{
+		{"mono", audiotest.SpeechSampleRate, nil},
+		{"mid-side", audiotest.SpeechSampleRate, []Option{WithChannels(2), WithMidSide()}},
+		{"resampled", 800, []Option{WithSampleRatePolicy(SampleRateResample, nil)}},
+		{"held output", audiotest.SpeechSampleRate, []Option{WithFadeOut(20 * time.Millisecond), WithFixedLatency(50 * time.Millisecond)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, tt.sampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			tr.Write(speech[:len(speech)/2])
+			if err := tr.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			calls := []struct {
+				name string
+				call func() error
+			}{
+				{"Write", func() error { _, err := tr.Write(speech); return err }},
+				{"WriteFrames", func() error { _, err := tr.WriteFrames(1, speech[:tr.FrameSize()]); return err }},
+				{"WriteContext", func() error { _, err := tr.WriteContext(context.Background(), speech); return err }},
+				{"Flush", tr.Flush},
+				{"FlushContext", func() error { return tr.FlushContext(context.Background()) }},
+				{"ReadFrom", func() error { _, err := tr.ReadFrom(bytes.NewReader(speech)); return err }},
+				{"Resume", func() error { _, err := tr.Resume(bytes.NewReader(speech), 0); return err }},
+				{"SetWriter", func() error { return tr.SetWriter(io.Discard) }},
+				{"RawCall", func() error { return tr.RawCall(func(unsafe.Pointer) {}) }},
+			}
+			for _, c := range calls {
+				if err := c.call(); !errors.Is(err, ErrClosed) {
+					t.Errorf("%s() after Close error = %v, want %v", c.name, err, ErrClosed)
+				}
+			}
+
+			// The methods without an error must not panic.
+			tr.SetSpeed(2)
+			tr.Update(Settings{})
+			tr.InputPending()
+			tr.InputLatency()
+			tr.OutputAvailable()
+			tr.Stats()
+			tr.Fingerprint()
+			if err := tr.Close(); err != nil {
+				t.Errorf("second Close() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
 // TestTransformer_unsafeBytesAsSlice tests the unsafe slice conversion methods.
 func TestTransformer_unsafeBytesAsSlice(t *testing.T) {
 	dummyWriter := new(bytes.Buffer)
@@ -584,6 +733,46 @@ func TestTransformer_unsafeBytesAsSlice(t *testing.T) {
 	})
 }
 
+// TestTransformer_LargeWrite writes more than 1<<30 samples in a single Write, which used to
+// exceed the cap of the unsafe conversion. It pushes more than 2 GiB through the transformer, so it
+// only runs when SONIC_LARGE_TESTS=1 is set.
+func TestTransformer_LargeWrite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping a multi-GB write in short mode")
+	}
+	if os.Getenv("SONIC_LARGE_TESTS") != "1" {
+		t.Skip("skipping a multi-GB write; set SONIC_LARGE_TESTS=1 to run it")
+	}
+	if strconv.IntSize < 64 {
+		t.Skip("skipping a multi-GB write on a 32-bit platform")
+	}
+	for _, format := range []AudioFormat{AudioFormatPCM, AudioFormatIEEEFloat} {
+		t.Run(format.String(), func(t *testing.T) {
+			numFrames := 1<<30 + streamBufferFrames + 1
+			p := make([]byte, numFrames*format.SampleSize()) // Zero pages, so it costs little memory
+			var numOutputBytes int
+			tr, err := NewTransformer(nil, 44100, format, WithOutputFunc(func(b []byte) error {
+				numOutputBytes += len(b)
+				return nil
+			}))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			n, err := tr.Write(p)
+			if err != nil || n != len(p) {
+				t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(p))
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if numOutputBytes != len(p) {
+				t.Errorf("output = %d bytes, want %d", numOutputBytes, len(p))
+			}
+		})
+	}
+}
+
 // newTestTransformer is a helper from TestTransformer_Write, made accessible for TestTransformer_unsafeBytesAsSlice
 func newTestTransformer(tb testing.TB, format AudioFormat, writer io.Writer) *Transformer {
 	tb.Helper()
@@ -603,3 +792,732 @@ func newTestTransformer(tb testing.TB, format AudioFormat, writer io.Writer) *Tr
 	})
 	return tr
 }
+
+// TestTransformer_Speech runs the embedded speech corpus through the transformer.
+// Unlike the reference tests, it needs no generated testdata.
+func TestTransformer_Speech(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+
+	for _, speed := range []float32{0.5, 1.0, 2.0, 3.0} {
+		t.Run(fmt.Sprintf("Speed_%v", speed), func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tr, err := NewTransformer(out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(speed))
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			if _, err := io.Copy(tr, bytes.NewReader(speech)); err != nil {
+				t.Fatalf("io.Copy() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			want := float64(len(speech)) / float64(speed)
+			if diff := math.Abs(float64(out.Len())-want) / want; diff > 0.01 {
+				t.Errorf("output length = %d, want %.0f (diff %.2f%%)", out.Len(), want, diff*100)
+			}
+		})
+	}
+}
+
+// TestTransformer_BufferFrames tests that the internal buffers are sized in frames for any channel count.
+func TestTransformer_BufferFrames(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        AudioFormat
+		opts          []Option
+		wantBufferLen int
+	}{
+		{"mono int16", AudioFormatPCM, nil, streamBufferFrames * 1 * 2},
+		{"stereo float32", AudioFormatIEEEFloat, []Option{WithChannels(2)}, streamBufferFrames * 2 * 4},
+		{"5.1 int16", AudioFormatPCM, []Option{WithChannels(6)}, streamBufferFrames * 6 * 2},
+		{"one of 5.1 selected", AudioFormatPCM, []Option{WithChannels(6), WithSelectChannels(0)}, streamBufferFrames * 1 * 2},
+		{"stereo U8 widened to int16", AudioFormatU8, []Option{WithChannels(2)}, streamBufferFrames * 2 * 2},
+		{"stereo PCM24 converted to float32", AudioFormatPCM24, []Option{WithChannels(2)}, streamBufferFrames * 2 * 4},
+		{"mono float64 converted to float32", AudioFormatIEEEFloat64, nil, streamBufferFrames * 1 * 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inputChunks []int
+			dumpFn := func(c DebugChunk) error {
+				if c.Stage == DebugStageInput {
+					inputChunks = append(inputChunks, len(c.Data))
+				}
+				return nil
+			}
+			opts := append(tt.opts, WithDebugDump(dumpFn))
+			tr, err := NewTransformer(new(bytes.Buffer), 44100, tt.format, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			if tr.BufferFrames() != streamBufferFrames {
+				t.Errorf("BufferFrames() = %d, want %d", tr.BufferFrames(), streamBufferFrames)
+			}
+			if len(tr.streamBuffer) != tt.wantBufferLen {
+				t.Errorf("streamBuffer length = %d, want %d", len(tr.streamBuffer), tt.wantBufferLen)
+			}
+
+			// Two and a half buffers of input must be written in full-buffer chunks.
+			frameSize := tr.numChannels * tt.format.SampleSize()
+			if _, err := tr.Write(make([]byte, streamBufferFrames*frameSize*5/2)); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			want := []int{tt.wantBufferLen, tt.wantBufferLen, tt.wantBufferLen / 2}
+			if !reflect.DeepEqual(inputChunks, want) {
+				t.Errorf("input chunk sizes = %v, want %v", inputChunks, want)
+			}
+		})
+	}
+}
+
+// TestTransformer_InputLatency tests the pending input estimate of the Transformer.
+func TestTransformer_InputLatency(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithSpeed(2.0)},
+		{WithSpeed(2.0), WithChannels(2), WithMidSide()},
+	} {
+		tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+
+		if tr.InputPending() != 0 || tr.InputLatency() != 0 {
+			t.Errorf("new transformer: InputPending() = %d, InputLatency() = %v, want 0", tr.InputPending(), tr.InputLatency())
+		}
+
+		speech := audiotest.SpeechPCM()
+		frameSize := 2 * tr.numChannels
+		if _, err := tr.Write(speech[:len(speech)/frameSize/2*frameSize]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		pending := tr.InputPending()
+		latency := tr.InputLatency()
+		if pending <= 0 || latency <= 0 || latency > 100*time.Millisecond {
+			t.Errorf("after write: InputPending() = %d, InputLatency() = %v, want a small positive latency", pending, latency)
+		}
+		if want := time.Duration(pending) * time.Second / audiotest.SpeechSampleRate; latency != want {
+			t.Errorf("InputLatency() = %v, want %v", latency, want)
+		}
+
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if tr.InputPending() != 0 {
+			t.Errorf("after flush: InputPending() = %d, want 0", tr.InputPending())
+		}
+	}
+}
+
+// TestTransformer_OutputAvailable tests the count of the output held back by the Transformer.
+func TestTransformer_OutputAvailable(t *testing.T) {
+	fadeFrames := SamplesForDuration(20*time.Millisecond, audiotest.SpeechSampleRate, 1)
+	latencyFrames := SamplesForDuration(50*time.Millisecond, audiotest.SpeechSampleRate, 1)
+	tests := []struct {
+		name       string
+		opts       []Option
+		afterWrite func(n int) bool
+		afterFlush int
+	}{
+		{"default", []Option{WithSpeed(2.0)}, func(n int) bool { return n == 0 }, 0},
+		{"mid-side", []Option{WithSpeed(2.0), WithChannels(2), WithMidSide()}, func(n int) bool { return n == 0 }, 0},
+		{"fade-out", []Option{WithSpeed(2.0), WithFadeOut(20 * time.Millisecond)}, func(n int) bool { return n == fadeFrames }, 0},
+		{"fixed latency", []Option{WithSpeed(2.0), WithFixedLatency(50 * time.Millisecond)}, func(n int) bool { return n > 0 && n <= 2*latencyFrames }, latencyFrames},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+
+			speech := audiotest.SpeechPCM()
+			if _, err := tr.Write(speech[:len(speech)/tr.FrameSize()/2*tr.FrameSize()]); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := tr.OutputAvailable(); !tt.afterWrite(got) {
+				t.Errorf("after write: OutputAvailable() = %d", got)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			// The fixed latency is primed with silence again after a flush.
+			if got := tr.OutputAvailable(); got != tt.afterFlush {
+				t.Errorf("after flush: OutputAvailable() = %d, want %d", got, tt.afterFlush)
+			}
+		})
+	}
+}
+
+// BenchmarkShortClipThroughput measures the per-clip cost of services that process many short
+// clips, each with its own Transformer: create, write a 2-second clip, flush and close.
+func BenchmarkShortClipThroughput(b *testing.B) {
+	speech := audiotest.SpeechPCM()
+	clip := speech[:min(len(speech), 2*audiotest.SpeechSampleRate*2)]
+
+	b.SetBytes(int64(len(clip)))
+	b.ReportAllocs()
+	for b.Loop() {
+		tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			b.Fatalf("NewTransformer() error = %v", err)
+		}
+		if _, err := tr.Write(clip); err != nil {
+			b.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			b.Fatalf("Flush() error = %v", err)
+		}
+		tr.Close()
+	}
+}
+
+func TestTransformer_OutputFunc(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+
+	// The output delivered to the function must match the output written to a writer.
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	if _, err := tr.Write(speech); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	tr.Close()
+
+	var got []byte
+	calls := 0
+	tr, err = NewTransformer(nil, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5), WithOutputFunc(func(p []byte) error {
+		got = append(got, p...)
+		calls++
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewTransformer() with nil writer and output function error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(speech); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if calls == 0 || !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("output function received %d bytes in %d calls, want the %d bytes written to the writer", len(got), calls, want.Len())
+	}
+
+	errFail := errors.New("queue full")
+	tr, err = NewTransformer(nil, audiotest.SpeechSampleRate, AudioFormatPCM, WithOutputFunc(func([]byte) error {
+		return errFail
+	}))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	_, err = tr.Write(speech)
+	if !errors.Is(err, ErrWrite) || !errors.Is(err, errFail) {
+		t.Errorf("Write() error = %v, want wrapping %v and %v", err, ErrWrite, errFail)
+	}
+}
+
+func TestTransformer_OutputFormat(t *testing.T) {
+	speech := audiotest.Speech()
+	speechFloat := pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767)
+
+	transform := func(t *testing.T, format AudioFormat, input []byte, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		opts = append(opts, WithSpeed(1.5))
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(input); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		return out.Bytes()
+	}
+
+	t.Run("float in, int16 out", func(t *testing.T) {
+		want := pcm.DecodeFloat32(nil, transform(t, AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, speechFloat)))
+		got := pcm.DecodeInt16(nil, transform(t, AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, speechFloat), WithOutputFormat(AudioFormatPCM)))
+		if len(got) != len(want) {
+			t.Fatalf("output samples = %d, want %d", len(got), len(want))
+		}
+		if conv := pcm.Float32ToInt16(nil, want, pcm.Scaling32767); !slices.Equal(got, conv) {
+			t.Error("int16 output differs from the converted float output")
+		}
+	})
+
+	t.Run("int16 in, float out", func(t *testing.T) {
+		want := pcm.DecodeInt16(nil, transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech)))
+		got := pcm.DecodeFloat32(nil, transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech), WithOutputFormat(AudioFormatIEEEFloat)))
+		if len(got) != len(want) {
+			t.Fatalf("output samples = %d, want %d", len(got), len(want))
+		}
+		if conv := pcm.Int16ToFloat32(nil, want, pcm.Scaling32767); !slices.Equal(got, conv) {
+			t.Error("float output differs from the converted int16 output")
+		}
+	})
+}
+
+func TestTransformer_U8(t *testing.T) {
+	speech := audiotest.Speech()[:2*audiotest.SpeechSampleRate]
+	speechU8 := pcm.Int16ToUint8(nil, speech)
+
+	transform := func(t *testing.T, format AudioFormat, input []byte, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		opts = append(opts, WithSpeed(1.5), WithAlignedChunks())
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := io.Copy(tr, bytes.NewReader(input)); err != nil {
+			t.Fatalf("io.Copy() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if got, want := tr.Stats().InputBytes, int64(len(input)); got != want {
+			t.Errorf("Stats().InputBytes = %d, want %d", got, want)
+		}
+		return out.Bytes()
+	}
+
+	t.Run("matches libsonic", func(t *testing.T) {
+		s, err := cgosonic.CreateStream(audiotest.SpeechSampleRate, 1)
+		if err != nil {
+			t.Fatalf("CreateStream() error = %v", err)
+		}
+		defer s.DestroyStream()
+		s.SetSpeed(1.5)
+		var want []byte
+		buf := make([]uint8, streamBufferFrames)
+		drain := func() {
+			for n := s.ReadUnsignedCharFromStream(buf, len(buf)); n > 0; n = s.ReadUnsignedCharFromStream(buf, len(buf)) {
+				want = append(want, buf[:n]...)
+			}
+		}
+		for p := speechU8; len(p) > 0; {
+			n := min(len(p), streamBufferFrames)
+			s.WriteUnsignedCharToStream(p[:n], n)
+			drain()
+			p = p[n:]
+		}
+		s.FlushStream()
+		drain()
+
+		if got := transform(t, AudioFormatU8, speechU8); !bytes.Equal(got, want) {
+			t.Errorf("output = %d bytes, want the %d bytes of libsonic", len(got), len(want))
+		}
+	})
+
+	t.Run("U8 in, int16 out", func(t *testing.T) {
+		want := transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, pcm.Uint8ToInt16(nil, speechU8)))
+		if got := transform(t, AudioFormatU8, speechU8, WithOutputFormat(AudioFormatPCM)); !bytes.Equal(got, want) {
+			t.Error("int16 output differs from the output of the widened input")
+		}
+	})
+
+	t.Run("U8 in, U8 out", func(t *testing.T) {
+		// Short input and mid-side mode process the widened samples like int16 input.
+		short := speechU8[:ShortInputFrames(audiotest.SpeechSampleRate)/2]
+		for _, opts := range [][]Option{
+			{WithShortInput(ShortInputPad)},
+			{WithShortInput(ShortInputPassthrough)},
+			{WithChannels(2), WithMidSide()},
+		} {
+			opts = append(opts, WithOutputFormat(AudioFormatU8))
+			want := transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, pcm.Uint8ToInt16(nil, short)), opts...)
+			if got := transform(t, AudioFormatU8, short, opts...); !bytes.Equal(got, want) {
+				t.Errorf("output = %d bytes, want the %d bytes of the widened input", len(got), len(want))
+			}
+		}
+	})
+
+	t.Run("int16 in, U8 out", func(t *testing.T) {
+		want := pcm.Int16ToUint8(nil, pcm.DecodeInt16(nil, transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech))))
+		if got := transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech), WithOutputFormat(AudioFormatU8)); !bytes.Equal(got, want) {
+			t.Error("U8 output differs from the narrowed int16 output")
+		}
+	})
+
+	t.Run("float in, U8 out", func(t *testing.T) {
+		input := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767))
+		out := pcm.DecodeFloat32(nil, transform(t, AudioFormatIEEEFloat, input))
+		want := pcm.Int16ToUint8(nil, pcm.Float32ToInt16(nil, out, pcm.Scaling32767))
+		if got := transform(t, AudioFormatIEEEFloat, input, WithOutputFormat(AudioFormatU8)); !bytes.Equal(got, want) {
+			t.Error("U8 output differs from the narrowed float output")
+		}
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		// The fixed latency starts the output with silence, which is 128 in U8.
+		const latencyFrames = audiotest.SpeechSampleRate / 100
+		out := transform(t, AudioFormatU8, speechU8, WithFixedLatency(10*time.Millisecond))
+		for i, v := range out[:latencyFrames] {
+			if v != 128 {
+				t.Fatalf("output sample %d = %d, want 128", i, v)
+			}
+		}
+		// The fade-out ends the output in silence.
+		out = transform(t, AudioFormatU8, speechU8, WithFadeOut(10*time.Millisecond))
+		if v := out[len(out)-1]; v < 127 || 129 < v {
+			t.Errorf("last output sample = %d, want about 128", v)
+		}
+	})
+}
+
+func TestTransformer_PCM24(t *testing.T) {
+	speech := audiotest.Speech()[:2*audiotest.SpeechSampleRate]
+	// Use the low byte, so that the input has more resolution than int16.
+	speech24 := pcm.Int16ToInt24(nil, speech)
+	for i := 0; i < len(speech24); i += 3 {
+		speech24[i] = byte(i * 37)
+	}
+	speechFloat := pcm.EncodeFloat32(nil, pcm.Int24ToFloat32(nil, speech24, pcm.Scaling32767))
+
+	transform := func(t *testing.T, format AudioFormat, input []byte, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		opts = append(opts, WithSpeed(1.5), WithAlignedChunks())
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := io.Copy(tr, bytes.NewReader(input)); err != nil {
+			t.Fatalf("io.Copy() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if got, want := tr.Stats().InputBytes, int64(len(input)); got != want {
+			t.Errorf("Stats().InputBytes = %d, want %d", got, want)
+		}
+		return out.Bytes()
+	}
+
+	t.Run("PCM24 in, float out", func(t *testing.T) {
+		want := transform(t, AudioFormatIEEEFloat, speechFloat)
+		if got := transform(t, AudioFormatPCM24, speech24, WithOutputFormat(AudioFormatIEEEFloat)); !bytes.Equal(got, want) {
+			t.Error("float output differs from the output of the converted input")
+		}
+	})
+
+	t.Run("PCM24 in, PCM24 out", func(t *testing.T) {
+		// Short input and mid-side mode process the converted samples like float input.
+		short := speech24[:3*ShortInputFrames(audiotest.SpeechSampleRate)/2]
+		shortFloat := speechFloat[:4*ShortInputFrames(audiotest.SpeechSampleRate)/2]
+		for _, opts := range [][]Option{
+			nil,
+			{WithShortInput(ShortInputPad)},
+			{WithShortInput(ShortInputPassthrough)},
+			{WithChannels(2), WithMidSide()},
+		} {
+			out := pcm.DecodeFloat32(nil, transform(t, AudioFormatIEEEFloat, shortFloat, opts...))
+			want := pcm.Float32ToInt24(nil, out, pcm.Scaling32767)
+			if got := transform(t, AudioFormatPCM24, short, opts...); !bytes.Equal(got, want) {
+				t.Errorf("output = %d bytes, want the %d bytes of the converted input", len(got), len(want))
+			}
+		}
+	})
+
+	t.Run("int16 in, PCM24 out", func(t *testing.T) {
+		want := pcm.Int16ToInt24(nil, pcm.DecodeInt16(nil, transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech))))
+		if got := transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech), WithOutputFormat(AudioFormatPCM24)); !bytes.Equal(got, want) {
+			t.Error("PCM24 output differs from the widened int16 output")
+		}
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		// The fixed latency starts the output with silence, and the fade-out ends it in silence.
+		const latencyFrames = audiotest.SpeechSampleRate / 100
+		out := transform(t, AudioFormatPCM24, speech24, WithFixedLatency(10*time.Millisecond))
+		if i := slices.IndexFunc(out[:3*latencyFrames], func(b byte) bool { return b != 0 }); i >= 0 {
+			t.Fatalf("output byte %d = %d, want 0", i, out[i])
+		}
+		out = transform(t, AudioFormatPCM24, speech24, WithFadeOut(10*time.Millisecond))
+		last := pcm.Int24ToFloat32(nil, out[len(out)-3:], pcm.Scaling32767)[0]
+		if math.Abs(float64(last)) > 1e-3 {
+			t.Errorf("last output sample = %v, want about 0", last)
+		}
+	})
+}
+
+func TestTransformer_PCM32AndFloat64(t *testing.T) {
+	speech := audiotest.Speech()[:2*audiotest.SpeechSampleRate]
+	speechFloat := pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767)
+	// Use the low bits, which float32 rounds away.
+	speech32 := pcm.Int16ToInt32(nil, speech)
+	for i := 0; i < len(speech32); i += 4 {
+		speech32[i] = byte(i * 37)
+	}
+	speech64 := pcm.Float32ToFloat64(nil, speechFloat)
+	for i := 0; i < len(speech64); i += 8 {
+		speech64[i] = byte(i * 37)
+	}
+
+	transform := func(t *testing.T, format AudioFormat, input []byte, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		opts = append(opts, WithSpeed(1.5), WithAlignedChunks())
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, opts...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := io.Copy(tr, bytes.NewReader(input)); err != nil {
+			t.Fatalf("io.Copy() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if got, want := tr.Stats().InputBytes, int64(len(input)); got != want {
+			t.Errorf("Stats().InputBytes = %d, want %d", got, want)
+		}
+		return out.Bytes()
+	}
+	short := ShortInputFrames(audiotest.SpeechSampleRate) / 2
+	tests := []struct {
+		format AudioFormat
+		input  []byte
+		encode func([]float32) []byte
+		widen  func([]int16) []byte // Converts int16 output
+	}{
+		{AudioFormatPCM32, speech32,
+			func(s []float32) []byte { return pcm.Float32ToInt32(nil, s, pcm.Scaling32767) },
+			func(s []int16) []byte { return pcm.Int16ToInt32(nil, s) }},
+		{AudioFormatIEEEFloat64, speech64,
+			func(s []float32) []byte { return pcm.Float32ToFloat64(nil, s) },
+			func(s []int16) []byte { return pcm.Float32ToFloat64(nil, pcm.Int16ToFloat32(nil, s, pcm.Scaling32767)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			for _, c := range []struct {
+				short bool
+				opts  []Option
+			}{
+				{false, nil},
+				{true, []Option{WithShortInput(ShortInputPassthrough)}},
+				{false, []Option{WithChannels(2), WithMidSide()}},
+			} {
+				// The input is processed as its float32 samples.
+				input, floatInput, opts := tt.input, tt.format.toFloat32(nil, tt.input), c.opts
+				if c.short {
+					input, floatInput = input[:short*tt.format.SampleSize()], floatInput[:short]
+				}
+				out := transform(t, AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, floatInput), opts...)
+				if got := transform(t, tt.format, input, append(opts, WithOutputFormat(AudioFormatIEEEFloat))...); !bytes.Equal(got, out) {
+					t.Errorf("float output differs from the output of the float32 input")
+				}
+				want := tt.encode(pcm.DecodeFloat32(nil, out))
+				if got := transform(t, tt.format, input, opts...); !bytes.Equal(got, want) {
+					t.Errorf("output = %d bytes, want the %d bytes of the converted float output", len(got), len(want))
+				}
+			}
+
+			want := tt.widen(pcm.DecodeInt16(nil, transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech))))
+			if got := transform(t, AudioFormatPCM, pcm.EncodeInt16(nil, speech), WithOutputFormat(tt.format)); !bytes.Equal(got, want) {
+				t.Error("output differs from the converted int16 output")
+			}
+		})
+	}
+}
+
+func TestTransformer_Dither(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	speechFloat := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767))
+	transform := func(t *testing.T, format AudioFormat, input []byte, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, format, append(opts, WithSpeed(1.5))...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(input); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		name      string
+		format    AudioFormat
+		input     []byte
+		outFormat AudioFormat
+		decode    func([]byte) []int32
+	}{
+		{"float to PCM", AudioFormatIEEEFloat, speechFloat, AudioFormatPCM, func(b []byte) []int32 {
+			s := make([]int32, len(b)/2)
+			for i, v := range pcm.DecodeInt16(nil, b) {
+				s[i] = int32(v)
+			}
+			return s
+		}},
+		{"float to PCM24", AudioFormatIEEEFloat, speechFloat, AudioFormatPCM24, func(b []byte) []int32 {
+			s := make([]int32, len(b)/3)
+			for i := range s {
+				s[i] = int32(uint32(b[3*i])<<8|uint32(b[3*i+1])<<16|uint32(b[3*i+2])<<24) >> 8
+			}
+			return s
+		}},
+		{"float to U8", AudioFormatIEEEFloat, speechFloat, AudioFormatU8, func(b []byte) []int32 {
+			s := make([]int32, len(b))
+			for i, v := range b {
+				s[i] = int32(v)
+			}
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.decode(transform(t, tt.format, tt.input, WithOutputFormat(tt.outFormat)))
+			dithered := transform(t, tt.format, tt.input, WithOutputFormat(tt.outFormat), WithDither(DitherTPDF))
+			got := tt.decode(dithered)
+			if len(got) != len(want) {
+				t.Fatalf("dithered output = %d samples, want %d", len(got), len(want))
+			}
+			changed := 0
+			for i := range got {
+				if d := got[i] - want[i]; d < -1 || 1 < d {
+					t.Fatalf("dithered sample %d = %d, want within one step of %d", i, got[i], want[i])
+				} else if d != 0 {
+					changed++
+				}
+			}
+			// TPDF noise of ±1 step changes about a quarter of the rounded samples.
+			if changed < len(got)/10 {
+				t.Errorf("dither changed %d of %d samples, want more", changed, len(got))
+			}
+			if again := transform(t, tt.format, tt.input, WithOutputFormat(tt.outFormat), WithDither(DitherTPDF)); !bytes.Equal(again, dithered) {
+				t.Error("dithered output differs between runs")
+			}
+		})
+	}
+
+	t.Run("integer output of integer input", func(t *testing.T) {
+		input := pcm.EncodeInt16(nil, speech)
+		want := transform(t, AudioFormatPCM, input, WithOutputFormat(AudioFormatPCM24))
+		if got := transform(t, AudioFormatPCM, input, WithOutputFormat(AudioFormatPCM24), WithDither(DitherTPDF)); !bytes.Equal(got, want) {
+			t.Error("dither changed the output of int16 input")
+		}
+	})
+
+	t.Run("float and PCM32 output", func(t *testing.T) {
+		for _, format := range []AudioFormat{AudioFormatIEEEFloat, AudioFormatPCM32, AudioFormatIEEEFloat64} {
+			want := transform(t, AudioFormatIEEEFloat, speechFloat, WithOutputFormat(format))
+			if got := transform(t, AudioFormatIEEEFloat, speechFloat, WithOutputFormat(format), WithDither(DitherTPDF)); !bytes.Equal(got, want) {
+				t.Errorf("dither changed %v output", format)
+			}
+		}
+	})
+}
+
+// TestTransformer_Rounding checks that the int16 output of float input is the float output
+// rounded with the rounding mode, bit for bit. libsonic outputs whole int16 steps, so a channel
+// gain is applied to get fractions to round.
+func TestTransformer_Rounding(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	input := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, speech, pcm.Scaling32767))
+	transform := func(t *testing.T, opts ...Option) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatIEEEFloat, append(opts, WithSpeed(1.5), WithChannelGains([]float32{0.7}))...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(input); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		return out.Bytes()
+	}
+
+	float := pcm.DecodeFloat32(nil, transform(t))
+	outputs := map[pcm.Rounding][]int16{}
+	for _, rounding := range pcm.Rounding(0).Values() {
+		t.Run(rounding.String(), func(t *testing.T) {
+			got := pcm.DecodeInt16(nil, transform(t, WithOutputFormat(AudioFormatPCM), WithRounding(rounding)))
+			want := pcm.Float32ToInt16Rounded(nil, float, pcm.Scaling32767, rounding)
+			if !slices.Equal(got, want) {
+				t.Errorf("output differs from the float output rounded with %v", rounding)
+			}
+			outputs[rounding] = got
+		})
+	}
+	if slices.Equal(outputs[pcm.RoundHalfAwayFromZero], outputs[pcm.RoundTowardZero]) {
+		t.Error("truncated output equals the rounded output")
+	}
+	if got := pcm.DecodeInt16(nil, transform(t, WithOutputFormat(AudioFormatPCM))); !slices.Equal(got, outputs[pcm.RoundHalfAwayFromZero]) {
+		t.Errorf("default output differs from the output with %v", pcm.RoundHalfAwayFromZero)
+	}
+}
+
+func TestTransformer_NominalRate(t *testing.T) {
+	speech := pcm.EncodeInt16(nil, audiotest.Speech())
+	inputSamples := len(speech) / 2
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		wantSampleRate int
+		wantScale      float64 // Output samples per input sample
+	}{
+		{"no rate", []Option{WithNominalRate()}, audiotest.SpeechSampleRate, 1},
+		{"resampled rate", []Option{WithRate(1.5)}, audiotest.SpeechSampleRate, 1 / 1.5},
+		{"nominal rate", []Option{WithRate(1.5), WithNominalRate()}, audiotest.SpeechSampleRate * 3 / 2, 1},
+		{"nominal rate with speed", []Option{WithRate(1.5), WithSpeed(2), WithNominalRate()}, audiotest.SpeechSampleRate * 3 / 2, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if got := tr.OutputSampleRate(); got != tt.wantSampleRate {
+				t.Errorf("OutputSampleRate() = %d, want %d", got, tt.wantSampleRate)
+			}
+			if _, err := tr.Write(speech); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			got := out.Len() / 2
+			want := tr.OutputSamplesForInput(inputSamples)
+			if math.Abs(float64(want)-float64(inputSamples)*tt.wantScale) > 1 {
+				t.Errorf("OutputSamplesForInput(%d) = %d, want %v", inputSamples, want, float64(inputSamples)*tt.wantScale)
+			}
+			if math.Abs(float64(got-want)) > float64(want)/100 {
+				t.Errorf("output samples = %d, want about %d", got, want)
+			}
+		})
+	}
+}
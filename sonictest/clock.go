@@ -0,0 +1,55 @@
+package sonictest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nakat-t/sonic-go"
+)
+
+// Clock is a sonic.Clock whose time only moves when told to. Sleep advances the time instead of
+// waiting, so tests of time-dependent behavior run instantly and deterministically. Inject it with
+// sonic.WithClock. It is safe for concurrent use.
+type Clock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+var _ sonic.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now implements sonic.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep implements sonic.Clock. It advances the time by d, if d is positive, and returns at once.
+func (c *Clock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+		c.slept += d
+	}
+}
+
+// Advance moves the time forward by d, e.g. to simulate the time spent by a writer.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Slept returns the total duration passed to Sleep.
+func (c *Clock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slept
+}
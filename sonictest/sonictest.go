@@ -0,0 +1,145 @@
+// Package sonictest provides an in-memory sonic.Stream and a fake sonic.Clock for tests.
+//
+// Stream does not time-stretch: it passes samples through unchanged, records the parameters set
+// by the transformer, and can be told to fail. Inject it with sonic.WithStreamFactory to test
+// code built on a sonic.Transformer, including its error paths, without libsonic. Clock tells a
+// time that only moves when the test advances it; inject it with sonic.WithClock.
+package sonictest
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// Stream is an in-memory sonic.Stream that passes samples through unchanged.
+type Stream struct {
+	SampleRate  int // Set by the factory
+	NumChannels int // Set by the factory
+
+	// Parameters set by the transformer.
+	Speed   float32
+	Pitch   float32
+	Rate    float32
+	Volume  float32
+	Quality int
+
+	FailWrite bool // Makes writes fail
+	FailFlush bool // Makes flushes fail
+	Destroyed bool // Reports whether DestroyStream was called
+
+	buf []float32 // Samples written and not yet read
+}
+
+var _ sonic.Stream = (*Stream)(nil)
+
+// NewStream returns a Stream with the default parameters of libsonic.
+func NewStream() *Stream {
+	return &Stream{Speed: 1, Pitch: 1, Rate: 1, Volume: 1}
+}
+
+// Factory returns a sonic.StreamFactory that hands out streams in order and fails once they are
+// exhausted. A Transformer creates one stream, or two in mid-side mode.
+func Factory(streams ...*Stream) sonic.StreamFactory {
+	return func(sampleRate, numChannels int) (sonic.Stream, error) {
+		if len(streams) == 0 {
+			return nil, errors.New("sonictest: no stream left")
+		}
+		s := streams[0]
+		streams = streams[1:]
+		s.SampleRate = sampleRate
+		s.NumChannels = numChannels
+		return s, nil
+	}
+}
+
+// WriteShortToStream implements sonic.Stream.
+func (s *Stream) WriteShortToStream(samples []int16, numFrames int) int {
+	if s.FailWrite {
+		return 0
+	}
+	s.buf = append(s.buf, pcm.Int16ToFloat32(nil, samples[:numFrames*s.NumChannels], pcm.Scaling32767)...)
+	return 1
+}
+
+// WriteFloatToStream implements sonic.Stream.
+func (s *Stream) WriteFloatToStream(samples []float32, numFrames int) int {
+	if s.FailWrite {
+		return 0
+	}
+	s.buf = append(s.buf, samples[:numFrames*s.NumChannels]...)
+	return 1
+}
+
+// ReadShortFromStream implements sonic.Stream.
+func (s *Stream) ReadShortFromStream(samples []int16, maxFrames int) int {
+	n := s.available(maxFrames)
+	pcm.Float32ToInt16(samples[:n*s.NumChannels], s.buf[:n*s.NumChannels], pcm.Scaling32767)
+	s.consume(n)
+	return n
+}
+
+// ReadFloatFromStream implements sonic.Stream.
+func (s *Stream) ReadFloatFromStream(samples []float32, maxFrames int) int {
+	n := s.available(maxFrames)
+	copy(samples, s.buf[:n*s.NumChannels])
+	s.consume(n)
+	return n
+}
+
+// FlushStream implements sonic.Stream.
+func (s *Stream) FlushStream() int {
+	if s.FailFlush {
+		return 0
+	}
+	return 1
+}
+
+// FlushAndReadShort implements sonic.Stream.
+func (s *Stream) FlushAndReadShort(samples []int16, maxFrames int) int {
+	if s.FlushStream() == 0 {
+		return -1
+	}
+	return s.ReadShortFromStream(samples, maxFrames)
+}
+
+// FlushAndReadFloat implements sonic.Stream.
+func (s *Stream) FlushAndReadFloat(samples []float32, maxFrames int) int {
+	if s.FlushStream() == 0 {
+		return -1
+	}
+	return s.ReadFloatFromStream(samples, maxFrames)
+}
+
+// PendingInputFrames implements sonic.Stream. It always returns 0, as Stream has no latency.
+func (s *Stream) PendingInputFrames() int { return 0 }
+
+func (s *Stream) GetSpeed() float32        { return s.Speed }
+func (s *Stream) SetSpeed(speed float32)   { s.Speed = speed }
+func (s *Stream) GetPitch() float32        { return s.Pitch }
+func (s *Stream) SetPitch(pitch float32)   { s.Pitch = pitch }
+func (s *Stream) GetRate() float32         { return s.Rate }
+func (s *Stream) SetRate(rate float32)     { s.Rate = rate }
+func (s *Stream) GetVolume() float32       { return s.Volume }
+func (s *Stream) SetVolume(volume float32) { s.Volume = volume }
+func (s *Stream) SetQuality(quality int)   { s.Quality = quality }
+func (s *Stream) DestroyStream()           { s.Destroyed = true }
+
+// available returns the number of frames that can be read, up to maxFrames.
+func (s *Stream) available(maxFrames int) int {
+	if s.NumChannels <= 0 {
+		return 0
+	}
+	n := len(s.buf) / s.NumChannels
+	if n > maxFrames {
+		n = maxFrames
+	}
+	return n
+}
+
+// consume removes n frames from the buffer.
+func (s *Stream) consume(n int) {
+	s.buf = slices.Delete(s.buf, 0, n*s.NumChannels)
+}
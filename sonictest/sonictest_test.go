@@ -0,0 +1,64 @@
+package sonictest
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	s := NewStream()
+	if _, err := Factory(s)(8000, 2); err != nil {
+		t.Fatalf("Factory() error = %v", err)
+	}
+	if s.SampleRate != 8000 || s.NumChannels != 2 {
+		t.Errorf("SampleRate, NumChannels = %d, %d, want 8000, 2", s.SampleRate, s.NumChannels)
+	}
+
+	if s.WriteShortToStream([]int16{1, 2, 3, 4, 5, 6}, 3) == 0 {
+		t.Fatal("WriteShortToStream() failed")
+	}
+	buf := make([]int16, 4)
+	if n := s.ReadShortFromStream(buf, 2); n != 2 || !slices.Equal(buf, []int16{1, 2, 3, 4}) {
+		t.Errorf("ReadShortFromStream() = %d, %v, want 2, [1 2 3 4]", n, buf)
+	}
+	if n := s.FlushAndReadShort(buf, 2); n != 1 || !slices.Equal(buf[:2], []int16{5, 6}) {
+		t.Errorf("FlushAndReadShort() = %d, %v, want 1, [5 6]", n, buf[:2])
+	}
+
+	s.FailWrite = true
+	s.FailFlush = true
+	if s.WriteFloatToStream([]float32{0, 0}, 1) != 0 {
+		t.Error("WriteFloatToStream() succeeded with FailWrite")
+	}
+	if n := s.FlushAndReadFloat(make([]float32, 2), 1); n != -1 {
+		t.Errorf("FlushAndReadFloat() = %d with FailFlush, want -1", n)
+	}
+}
+
+func TestFactory_Exhausted(t *testing.T) {
+	f := Factory(NewStream())
+	if _, err := f(8000, 1); err != nil {
+		t.Fatalf("first call error = %v", err)
+	}
+	if _, err := f(8000, 1); err == nil {
+		t.Error("second call error = nil, want an error")
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v, want %v", got, start)
+	}
+	c.Advance(time.Second)
+	c.Sleep(250 * time.Millisecond)
+	c.Sleep(-time.Second) // Ignored, as by time.Sleep
+	if got, want := c.Now(), start.Add(1250*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+	if got := c.Slept(); got != 250*time.Millisecond {
+		t.Errorf("Slept() = %v, want 250ms", got)
+	}
+}
@@ -0,0 +1,248 @@
+package sonic
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+const (
+	spectrogramMaxFreq = 5000 // Highest frequency shown, SONIC_MAX_SPECTRUM_FREQ of libsonic
+	spectrogramRange   = 80   // Dynamic range in dB, from black to white
+	spectrogramAMDF    = 4000 // Rate to down-sample to for pitch detection, as libsonic does
+)
+
+// Spectrogram is a pitch-synchronous spectrogram of speech, as computed by the spectrogram
+// support of libsonic, which is not part of the C sources vendored by sonic-go.
+//
+// Every pitch period of the input is turned into one spectral line with a time-aliased DFT: the
+// period and the next one are windowed and overlap-added, and the result is transformed. The
+// lines are therefore spaced one pitch period apart, and resolve the harmonics of the voice.
+// Images are rendered by linear interpolation between the lines and their frequencies, up to
+// 5 kHz, from black for the loudest power to white for 80 dB below it.
+//
+// Add input with AddInt16 or AddFloat32, or let a transformer add its input with
+// WithSpectrogram. The last two pitch periods of the input are not analyzed, since the next
+// period is needed to find them. A Spectrogram is not safe for concurrent use.
+type Spectrogram struct {
+	sampleRate  int
+	numChannels int
+	minPeriod   int       // Shortest pitch period in frames
+	maxPeriod   int       // Longest pitch period in frames
+	pending     []float64 // Mono input not analyzed yet
+	lines       []spectralLine
+	frames      int // Input frames analyzed, i.e. the start of the next line
+	maxPower    float64
+}
+
+// spectralLine is the spectrum of one pitch period.
+type spectralLine struct {
+	start  int       // First frame of the period
+	period int       // Length of the period in frames
+	power  []float64 // Power in dB at multiples of sampleRate/period Hz
+}
+
+// NewSpectrogram creates an empty spectrogram of input with numChannels interleaved channels at
+// sampleRate. The channels are mixed down for the analysis.
+func NewSpectrogram(sampleRate, numChannels int) (*Spectrogram, error) {
+	if sampleRate < cgosonic.MIN_SAMPLE_RATE || cgosonic.MAX_SAMPLE_RATE < sampleRate {
+		return nil, fmt.Errorf("%w: sampleRate %d is out of range [%d, %d]", ErrInvalid, sampleRate, cgosonic.MIN_SAMPLE_RATE, cgosonic.MAX_SAMPLE_RATE)
+	}
+	if numChannels < 1 {
+		return nil, fmt.Errorf("%w: numChannels %d must be positive", ErrInvalid, numChannels)
+	}
+	return &Spectrogram{
+		sampleRate:  sampleRate,
+		numChannels: numChannels,
+		minPeriod:   sampleRate / cgosonic.MAX_PITCH,
+		maxPeriod:   sampleRate / cgosonic.MIN_PITCH,
+		maxPower:    math.Inf(-1),
+	}, nil
+}
+
+// AddInt16 adds interleaved 16-bit samples to the spectrogram. A trailing partial frame is
+// ignored.
+func (s *Spectrogram) AddInt16(samples []int16) {
+	addSpectrogramSamples(s, samples, 32767)
+}
+
+// AddFloat32 adds interleaved float samples to the spectrogram. A trailing partial frame is
+// ignored.
+func (s *Spectrogram) AddFloat32(samples []float32) {
+	addSpectrogramSamples(s, samples, 1)
+}
+
+// addSpectrogramSamples mixes samples down, scaled to ±1 by full, and analyzes them.
+func addSpectrogramSamples[T int16 | float32](s *Spectrogram, samples []T, full float64) {
+	for i := 0; i+s.numChannels <= len(samples); i += s.numChannels {
+		sum := 0.0
+		for _, v := range samples[i : i+s.numChannels] {
+			sum += float64(v)
+		}
+		s.pending = append(s.pending, sum/float64(s.numChannels)/full)
+	}
+	s.analyze()
+}
+
+// analyze turns the pitch periods of the pending input into spectral lines, as long as there is
+// enough input to find the next period.
+func (s *Spectrogram) analyze() {
+	consumed := 0
+	for len(s.pending)-consumed >= 2*s.maxPeriod {
+		samples := s.pending[consumed:]
+		period := s.findPitchPeriod(samples[:2*s.maxPeriod])
+		s.addLine(samples[:2*period])
+		consumed += period
+	}
+	s.pending = s.pending[:copy(s.pending, s.pending[consumed:])]
+}
+
+// findPitchPeriod finds the pitch period at the start of samples, which holds two of the longest
+// periods, with the average magnitude difference function on the input down-sampled to about
+// 4 kHz, as libsonic does.
+func (s *Spectrogram) findPitchPeriod(samples []float64) int {
+	skip := max(1, s.sampleRate/spectrogramAMDF)
+	best, bestDiff := s.maxPeriod, math.Inf(1)
+	for period := s.minPeriod / skip; period <= s.maxPeriod/skip; period++ {
+		if period == 0 {
+			continue
+		}
+		diff := 0.0
+		for i := range period {
+			diff += math.Abs(samples[i*skip] - samples[(i+period)*skip])
+		}
+		// Compare the average difference per sample.
+		if diff/float64(period) < bestDiff {
+			best, bestDiff = period*skip, diff/float64(period)
+		}
+	}
+	return max(best, 1)
+}
+
+// addLine adds the spectral line of the two pitch periods in samples.
+func (s *Spectrogram) addLine(samples []float64) {
+	period := len(samples) / 2
+	aliased := make([]float64, period)
+	for i, v := range samples {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(samples)))
+		aliased[i%period] += v * w
+	}
+	numFreqs := min(period/2, spectrogramMaxFreq*period/s.sampleRate) + 1
+	power := make([]float64, numFreqs)
+	for k := range power {
+		var re, im float64
+		for i, v := range aliased {
+			sin, cos := math.Sincos(2 * math.Pi * float64(k*i%period) / float64(period))
+			re += v * cos
+			im -= v * sin
+		}
+		power[k] = 10 * math.Log10(re*re+im*im+1e-12)
+		s.maxPower = math.Max(s.maxPower, power[k])
+	}
+	s.lines = append(s.lines, spectralLine{start: s.frames, period: period, power: power})
+	s.frames += period
+}
+
+// NumLines returns the number of spectral lines, i.e. the number of pitch periods analyzed.
+func (s *Spectrogram) NumLines() int {
+	return len(s.lines)
+}
+
+// Duration returns the playback duration of the input analyzed so far.
+func (s *Spectrogram) Duration() time.Duration {
+	return time.Duration(s.frames) * time.Second / time.Duration(s.sampleRate)
+}
+
+// Image renders the spectrogram as a grayscale image of rows by cols pixels. Time runs from left
+// to right, and frequency from 0 at the bottom to 5 kHz, or half the sample rate if lower, at the
+// top. A spectrogram without lines renders white.
+func (s *Spectrogram) Image(rows, cols int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, max(cols, 0), max(rows, 0)))
+	maxFreq := math.Min(spectrogramMaxFreq, float64(s.sampleRate)/2)
+	line := 0
+	for col := range cols {
+		// Interpolate between the lines centered around the time of the column.
+		t := (float64(col) + 0.5) * float64(s.frames) / float64(cols)
+		for line+1 < len(s.lines) && s.lines[line+1].center() <= t {
+			line++
+		}
+		for row := range rows {
+			freq := (float64(rows-1-row) + 0.5) * maxFreq / float64(rows)
+			img.Pix[row*img.Stride+col] = s.gray(s.powerAt(line, t, freq))
+		}
+	}
+	return img
+}
+
+// center returns the time of the middle of the line in frames.
+func (l spectralLine) center() float64 {
+	return float64(l.start) + float64(l.period)/2
+}
+
+// powerAt returns the power in dB at freq Hz and frame t, interpolated between line and the
+// next one.
+func (s *Spectrogram) powerAt(line int, t, freq float64) float64 {
+	if len(s.lines) == 0 {
+		return math.Inf(-1)
+	}
+	a := s.lines[line]
+	if line+1 == len(s.lines) || t <= a.center() {
+		return s.linePower(a, freq)
+	}
+	b := s.lines[line+1]
+	f := math.Min((t-a.center())/(b.center()-a.center()), 1)
+	return (1-f)*s.linePower(a, freq) + f*s.linePower(b, freq)
+}
+
+// linePower returns the power of l in dB at freq Hz, interpolated between its frequencies.
+func (s *Spectrogram) linePower(l spectralLine, freq float64) float64 {
+	bin := freq * float64(l.period) / float64(s.sampleRate)
+	i := int(bin)
+	if i+1 >= len(l.power) {
+		return l.power[len(l.power)-1]
+	}
+	f := bin - float64(i)
+	return (1-f)*l.power[i] + f*l.power[i+1]
+}
+
+// gray returns the pixel value of power in dB: 0 (black) for the loudest power and 255 (white)
+// for 80 dB below it or less.
+func (s *Spectrogram) gray(power float64) uint8 {
+	v := (s.maxPower - power) / spectrogramRange
+	if math.IsNaN(v) || v >= 1 {
+		return 255
+	}
+	return uint8(math.Round(math.Max(v, 0) * 255))
+}
+
+// WritePGM writes the image of rows by cols pixels rendered by Image to w as a binary PGM file,
+// as sonicWritePGM of libsonic does.
+func (s *Spectrogram) WritePGM(w io.Writer, rows, cols int) error {
+	img := s.Image(rows, cols)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P5\n%d %d\n255\n", cols, rows)
+	bw.Write(img.Pix)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%w: failed to write PGM: %w", ErrWrite, err)
+	}
+	return nil
+}
+
+// addSpectrogram adds the input samples written to the stream to the spectrogram given with
+// WithSpectrogram, if any.
+func addSpectrogram[T int16 | float32](t *Transformer, samples []T) {
+	if t.spectrogram == nil {
+		return
+	}
+	switch s := any(samples).(type) {
+	case []int16:
+		t.spectrogram.AddInt16(s)
+	case []float32:
+		t.spectrogram.AddFloat32(s)
+	}
+}
@@ -0,0 +1,255 @@
+package sonic
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// harmonics returns a second of a voice-like signal at 8 kHz with a pitch of f0 Hz and its first
+// three harmonics.
+func harmonics(f0 float64) []int16 {
+	samples := make([]int16, 8000)
+	for i := range samples {
+		v := 0.0
+		for h := 1.0; h <= 3; h++ {
+			v += math.Sin(2*math.Pi*h*f0*float64(i)/8000) / h
+		}
+		samples[i] = int16(8000 * v)
+	}
+	return samples
+}
+
+// rowMean returns the mean pixel value of the row for freq Hz of an image of s, with 4 kHz at the
+// top.
+func rowMean(t *testing.T, s *Spectrogram, freq float64) float64 {
+	t.Helper()
+	const rows, cols = 400, 50
+	img := s.Image(rows, cols)
+	row := rows - 1 - int(freq*rows/4000)
+	sum := 0
+	for col := range cols {
+		sum += int(img.GrayAt(col, row).Y)
+	}
+	return float64(sum) / cols
+}
+
+func TestSpectrogram(t *testing.T) {
+	tests := []struct {
+		name       string
+		f0         float64
+		wantPeriod int
+	}{
+		{"100 Hz", 100, 80},
+		{"200 Hz", 200, 40},
+		{"250 Hz", 250, 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSpectrogram(8000, 1)
+			if err != nil {
+				t.Fatalf("NewSpectrogram() error = %v", err)
+			}
+			samples := harmonics(tt.f0)
+			// Add the input in uneven pieces, as a transformer does.
+			for len(samples) > 0 {
+				n := min(len(samples), 333)
+				s.AddInt16(samples[:n])
+				samples = samples[n:]
+			}
+			for i, l := range s.lines {
+				if d := l.period - tt.wantPeriod; d < -1 || 1 < d {
+					t.Fatalf("line %d has a period of %d, want %d", i, l.period, tt.wantPeriod)
+				}
+			}
+			if got, want := s.Duration(), time.Second-2*time.Second*time.Duration(s.maxPeriod)/8000; got < want {
+				t.Errorf("Duration() = %v, want at least %v", got, want)
+			}
+			if got, want := s.NumLines(), s.frames/tt.wantPeriod; got < want-1 || want+1 < got {
+				t.Errorf("NumLines() = %d, want about %d", got, want)
+			}
+			// The harmonics are dark, and the frequencies above the last one are light.
+			above := rowMean(t, s, 6*tt.f0)
+			for h := 1.0; h <= 3; h++ {
+				if harmonic := rowMean(t, s, h*tt.f0); harmonic+128 > above {
+					t.Errorf("row of %v Hz = %.1f, want darker than %.1f of %v Hz", h*tt.f0, harmonic, above, 6*tt.f0)
+				}
+			}
+		})
+	}
+}
+
+func TestSpectrogram_Channels(t *testing.T) {
+	mono := harmonics(200)
+	stereo := make([]float32, 2*len(mono))
+	for i, v := range pcm.Int16ToFloat32(nil, mono, int16Scaling) {
+		stereo[2*i], stereo[2*i+1] = v, v
+	}
+	want, err := NewSpectrogram(8000, 1)
+	if err != nil {
+		t.Fatalf("NewSpectrogram() error = %v", err)
+	}
+	want.AddInt16(mono)
+	got, err := NewSpectrogram(8000, 2)
+	if err != nil {
+		t.Fatalf("NewSpectrogram() error = %v", err)
+	}
+	got.AddFloat32(stereo)
+	if got.NumLines() != want.NumLines() || got.Duration() != want.Duration() {
+		t.Errorf("stereo spectrogram has %d lines over %v, want %d over %v", got.NumLines(), got.Duration(), want.NumLines(), want.Duration())
+	}
+}
+
+func TestSpectrogram_Empty(t *testing.T) {
+	s, err := NewSpectrogram(44100, 2)
+	if err != nil {
+		t.Fatalf("NewSpectrogram() error = %v", err)
+	}
+	s.AddInt16(make([]int16, 100)) // Too short to find a pitch period.
+	if s.NumLines() != 0 || s.Duration() != 0 {
+		t.Errorf("spectrogram has %d lines over %v, want none", s.NumLines(), s.Duration())
+	}
+	img := s.Image(3, 4)
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 3 {
+		t.Fatalf("Image() bounds = %v, want 4x3", b)
+	}
+	for i, p := range img.Pix {
+		if p != 255 {
+			t.Fatalf("Image() pixel %d = %d, want 255", i, p)
+		}
+	}
+}
+
+func TestNewSpectrogram_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  int
+		numChannels int
+	}{
+		{"low sample rate", 999, 1},
+		{"high sample rate", 1000000, 1},
+		{"no channels", 8000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSpectrogram(tt.sampleRate, tt.numChannels); !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewSpectrogram() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestSpectrogram_WritePGM(t *testing.T) {
+	s, err := NewSpectrogram(8000, 1)
+	if err != nil {
+		t.Fatalf("NewSpectrogram() error = %v", err)
+	}
+	s.AddInt16(harmonics(200))
+	var buf bytes.Buffer
+	if err := s.WritePGM(&buf, 30, 20); err != nil {
+		t.Fatalf("WritePGM() error = %v", err)
+	}
+	r := bufio.NewReader(&buf)
+	var width, height, maxVal int
+	if _, err := fmt.Fscanf(r, "P5\n%d %d\n%d\n", &width, &height, &maxVal); err != nil {
+		t.Fatalf("failed to read the PGM header: %v", err)
+	}
+	if width != 20 || height != 30 || maxVal != 255 {
+		t.Errorf("PGM header = %dx%d of %d, want 20x30 of 255", width, height, maxVal)
+	}
+	pix, _ := io.ReadAll(r)
+	if !bytes.Equal(pix, s.Image(30, 20).Pix) {
+		t.Errorf("PGM has %d pixels, want the %d of Image()", len(pix), 20*30)
+	}
+
+	if err := s.WritePGM(errWriter{}, 30, 20); !errors.Is(err, ErrWrite) {
+		t.Errorf("WritePGM() error = %v, want %v", err, ErrWrite)
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTransformer_Spectrogram(t *testing.T) {
+	mono := harmonics(200)
+	stereo := make([]int16, 2*len(mono))
+	for i, v := range mono {
+		stereo[2*i], stereo[2*i+1] = v, v/2
+	}
+	tests := []struct {
+		name   string
+		format AudioFormat
+		input  []byte
+		opts   []Option
+	}{
+		{"mono PCM", AudioFormatPCM, pcm.EncodeInt16(nil, mono), []Option{WithSpeed(2)}},
+		{"float", AudioFormatIEEEFloat, pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, mono, int16Scaling)), []Option{WithSpeed(2)}},
+		{"selected channel", AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{WithChannels(2), WithSelectChannels(0)}},
+		{"mid-side", AudioFormatPCM, pcm.EncodeInt16(nil, stereo), []Option{WithChannels(2), WithMidSide()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := NewSpectrogram(8000, 1)
+			if err != nil {
+				t.Fatalf("NewSpectrogram() error = %v", err)
+			}
+			want.AddInt16(mono)
+
+			channels := 1
+			if tt.name == "mid-side" {
+				channels = 2
+			}
+			s, err := NewSpectrogram(8000, channels)
+			if err != nil {
+				t.Fatalf("NewSpectrogram() error = %v", err)
+			}
+			tr, err := NewTransformer(io.Discard, 8000, tt.format, append(tt.opts, WithSpectrogram(s))...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(tt.input); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			// The spectrogram analyzes the input, not the output sped up by the stream.
+			if d := s.Duration() - want.Duration(); d < -10*time.Millisecond || 10*time.Millisecond < d {
+				t.Errorf("Duration() = %v, want %v", s.Duration(), want.Duration())
+			}
+		})
+	}
+}
+
+func TestWithSpectrogram_Mismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  int
+		numChannels int
+		opts        []Option
+	}{
+		{"channels", 8000, 1, []Option{WithChannels(2)}},
+		{"selected channels", 8000, 2, []Option{WithChannels(2), WithSelectChannels(1)}},
+		{"sample rate", 16000, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSpectrogram(tt.sampleRate, tt.numChannels)
+			if err != nil {
+				t.Fatalf("NewSpectrogram() error = %v", err)
+			}
+			if _, err := NewTransformer(io.Discard, 8000, AudioFormatPCM, append(tt.opts, WithSpectrogram(s))...); !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewTransformer() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
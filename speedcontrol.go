@@ -0,0 +1,113 @@
+package sonic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+// SpeedControl configures a SpeedController. Zero fields take the defaults given below.
+type SpeedControl struct {
+	// Target is the output to keep buffered ahead of the playback position, i.e. the depth of
+	// the jitter buffer. It must be positive.
+	Target time.Duration
+
+	// Response is the time in which a deviation from Target is made up, as long as the speed
+	// stays within its bounds. Shorter times track the target more tightly, but change the
+	// speed more abruptly. The default is 1 second.
+	Response time.Duration
+
+	// MinSpeed and MaxSpeed bound the speed set by the controller. The defaults are 0.5 and 2.
+	MinSpeed, MaxSpeed float32
+}
+
+// Defaults of SpeedControl
+const (
+	defaultSpeedResponse = time.Second
+	defaultMinSpeed      = 0.5
+	defaultMaxSpeed      = 2
+)
+
+// SpeedController adjusts the speed of a transformer so that the output stays buffered ahead of
+// an external playback clock at a target level, as a jitter buffer does, e.g. to keep live
+// translated audio in sync with the primary stream it is played along with.
+//
+// The controller assumes that the input arrives in real time. When more output is buffered than
+// the target, e.g. after a burst of input, it speeds the transformer up to catch up with the
+// playback; when less is buffered, it slows it down to build up the buffer again.
+//
+// Write the input through the controller, which measures the buffered output and sets the speed
+// before each write. A SpeedController must not be used concurrently, and the transformer must
+// not be written to directly while it is in use.
+type SpeedController struct {
+	t        *Transformer
+	playback func() time.Duration
+	control  SpeedControl
+	speed    float32
+}
+
+// NewSpeedController creates a controller for t. playback returns the playback position, i.e.
+// the duration of the output of t played so far, e.g. as told by the frames consumed by the
+// audio device or by the timestamps of the primary stream. It returns an error wrapping
+// ErrInvalid if the target is not positive, the response is negative or the speed bounds are
+// out of order.
+func NewSpeedController(t *Transformer, playback func() time.Duration, control SpeedControl) (*SpeedController, error) {
+	if control.Target <= 0 {
+		return nil, fmt.Errorf("%w: target %v is not positive", ErrInvalid, control.Target)
+	}
+	if control.Response < 0 {
+		return nil, fmt.Errorf("%w: response %v is negative", ErrInvalid, control.Response)
+	}
+	if control.Response == 0 {
+		control.Response = defaultSpeedResponse
+	}
+	if control.MinSpeed == 0 {
+		control.MinSpeed = defaultMinSpeed
+	}
+	if control.MaxSpeed == 0 {
+		control.MaxSpeed = defaultMaxSpeed
+	}
+	control.MinSpeed = clamp(control.MinSpeed, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED)
+	control.MaxSpeed = clamp(control.MaxSpeed, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED)
+	if control.MinSpeed > control.MaxSpeed {
+		return nil, fmt.Errorf("%w: speed bounds %v and %v are out of order", ErrInvalid, control.MinSpeed, control.MaxSpeed)
+	}
+	speed := float32(1)
+	if t.speed != nil {
+		speed = *t.speed
+	}
+	return &SpeedController{t: t, playback: playback, control: control, speed: speed}, nil
+}
+
+// Buffered returns the output of the transformer not played yet: the duration of the output
+// delivered so far minus the playback position. It is negative if the playback ran ahead, i.e.
+// the listener heard silence.
+func (c *SpeedController) Buffered() time.Duration {
+	frames := int(c.t.Stats().OutputBytes / int64(c.t.OutputFrameSize()))
+	return DurationForSamples(frames, c.t.OutputSampleRate(), 1) - c.playback()
+}
+
+// Speed returns the speed set by the last write, or the speed of the transformer before the
+// first one.
+func (c *SpeedController) Speed() float32 {
+	return c.speed
+}
+
+// speedFor returns the speed that makes up the deviation of buffered from the target in the
+// response time. With input arriving in real time, the transformer produces 1/speed seconds of
+// output per second while one second is played, so the buffer changes by 1/speed-1 per second.
+func (c *SpeedController) speedFor(buffered time.Duration) float32 {
+	rate := 1 - float64(buffered-c.control.Target)/float64(c.control.Response) // Output per second
+	if rate <= 0 {
+		return c.control.MaxSpeed
+	}
+	return clamp(float32(1/rate), c.control.MinSpeed, c.control.MaxSpeed)
+}
+
+// Write sets the speed for the buffered output, and writes p to the transformer.
+func (c *SpeedController) Write(p []byte) (int, error) {
+	c.speed = c.speedFor(c.Buffered())
+	c.t.SetSpeed(c.speed)
+	return c.t.Write(p)
+}
@@ -0,0 +1,137 @@
+package sonic
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestNewSpeedController(t *testing.T) {
+	tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	playback := func() time.Duration { return 0 }
+
+	tests := []struct {
+		name    string
+		control SpeedControl
+		wantErr error
+	}{
+		{"defaults", SpeedControl{Target: time.Second}, nil},
+		{"zero target", SpeedControl{}, ErrInvalid},
+		{"negative response", SpeedControl{Target: time.Second, Response: -time.Second}, ErrInvalid},
+		{"bounds out of order", SpeedControl{Target: time.Second, MinSpeed: 2, MaxSpeed: 1}, ErrInvalid},
+		{"bounds clamped", SpeedControl{Target: time.Second, MinSpeed: 0.001, MaxSpeed: 100}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewSpeedController(tr, playback, tt.control)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("NewSpeedController() error = %v, want %v", err, tt.wantErr)
+			}
+			if c != nil && c.Speed() != 1.5 {
+				t.Errorf("Speed() = %v, want the speed of the transformer 1.5", c.Speed())
+			}
+		})
+	}
+}
+
+func TestSpeedController_SpeedFor(t *testing.T) {
+	tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	c, err := NewSpeedController(tr, nil, SpeedControl{Target: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewSpeedController() error = %v", err)
+	}
+
+	tests := []struct {
+		buffered time.Duration
+		want     float32
+	}{
+		{200 * time.Millisecond, 1},
+		{700 * time.Millisecond, 2},       // Half the output per second drains the excess in 1s
+		{450 * time.Millisecond, 4.0 / 3}, // 3/4 of the output per second
+		{5 * time.Second, 2},              // Beyond the response, clamped to MaxSpeed
+		{-300 * time.Millisecond, 1 / 1.5},
+		{-time.Second, 0.5}, // Clamped to MinSpeed
+	}
+	for _, tt := range tests {
+		if got := c.speedFor(tt.buffered); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("speedFor(%v) = %v, want %v", tt.buffered, got, tt.want)
+		}
+	}
+}
+
+func TestSpeedController(t *testing.T) {
+	speech := pcm.EncodeInt16(nil, audiotest.Speech())
+	input := append(append(speech[:len(speech):len(speech)], speech...), speech...)
+	const (
+		step   = 20 * time.Millisecond
+		target = 200 * time.Millisecond
+	)
+	chunk := 2 * SamplesForDuration(step, audiotest.SpeechSampleRate, 1)
+
+	tests := []struct {
+		name  string
+		burst time.Duration // Input written at once before the real-time input
+	}{
+		{"building up", 0},
+		{"catching up", time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransformer(io.Discard, audiotest.SpeechSampleRate, AudioFormatPCM)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			// The playback advances in real time, but cannot play output that was not delivered.
+			var position time.Duration
+			c, err := NewSpeedController(tr, func() time.Duration { return position }, SpeedControl{
+				Target:   target,
+				Response: 500 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewSpeedController() error = %v", err)
+			}
+
+			p := input
+			if burst := 2 * SamplesForDuration(tt.burst, audiotest.SpeechSampleRate, 1); burst > 0 {
+				if _, err := tr.Write(p[:burst]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				p = p[burst:]
+				if _, err := c.Write(p[:chunk]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				p = p[chunk:]
+				if got := c.Speed(); got != defaultMaxSpeed {
+					t.Errorf("Speed() after the burst = %v, want %v", got, defaultMaxSpeed)
+				}
+			}
+			for elapsed := time.Duration(0); len(p) >= chunk; elapsed += step {
+				if _, err := c.Write(p[:chunk]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				p = p[chunk:]
+				position += step
+				if buffered := c.Buffered(); buffered < 0 {
+					position += buffered
+				}
+				if buffered := c.Buffered(); elapsed > 5*time.Second && (buffered-target).Abs() > 50*time.Millisecond {
+					t.Fatalf("Buffered() after %v = %v, want about %v", elapsed, buffered, target)
+				}
+			}
+		})
+	}
+}
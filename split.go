@@ -0,0 +1,66 @@
+package sonic
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+// WaveSplitter is an io.WriteCloser that writes 16-bit little-endian PCM samples to a series of
+// WAVE files of at most a given duration each, for delivery systems that cap file sizes.
+// Use it as the writer of a Transformer with AudioFormatPCM output.
+//
+// Files are named by formatting the file name pattern with the 0-based file index, e.g.
+// "out-%03d.wav". Each file gets a correct header when it is completed, i.e. when the next file
+// is started or the splitter is closed. Files are only created once samples are written to them.
+//
+// A WaveSplitter is a Rotator over a transformer that passes the samples through unchanged. Use a
+// Rotator directly to split by size or to be notified of completed files.
+type WaveSplitter struct {
+	t *Transformer // Passes the samples through unchanged
+	r *Rotator
+}
+
+// NewWaveSplitter returns a WaveSplitter that splits its input into WAVE files of at most
+// maxDuration each. The duration is rounded down to whole frames, but is at least one frame.
+// It returns an error wrapping ErrInvalid if pattern does not give different names to different
+// files.
+func NewWaveSplitter(pattern string, sampleRate, numChannels int, maxDuration time.Duration) (*WaveSplitter, error) {
+	if numChannels < cgosonic.MIN_CHANNELS || cgosonic.MAX_CHANNELS < numChannels {
+		return nil, fmt.Errorf("%w: numChannels %d is out of range [%d, %d]", ErrInvalid, numChannels, cgosonic.MIN_CHANNELS, cgosonic.MAX_CHANNELS)
+	}
+	if maxDuration <= 0 {
+		return nil, fmt.Errorf("%w: maxDuration %v must be positive", ErrInvalid, maxDuration)
+	}
+	// The rotator sets the writer of the first file.
+	t, err := NewTransformer(io.Discard, sampleRate, AudioFormatPCM, WithChannels(numChannels))
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRotator(t, Rotation{Pattern: pattern, MaxDuration: maxDuration})
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return &WaveSplitter{t: t, r: r}, nil
+}
+
+// Write writes interleaved 16-bit little-endian samples, starting a new file whenever the current
+// one reaches the maximum duration. Frames may be split across calls.
+func (s *WaveSplitter) Write(p []byte) (int, error) {
+	return s.r.Write(p)
+}
+
+// Close completes the last file. An incomplete trailing frame is discarded.
+func (s *WaveSplitter) Close() error {
+	err := s.r.Close()
+	s.t.Close()
+	return err
+}
+
+// Files returns the names of the files created so far.
+func (s *WaveSplitter) Files() []string {
+	return s.r.Segments()
+}
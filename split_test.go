@@ -0,0 +1,118 @@
+package sonic
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestWaveSplitter(t *testing.T) {
+	const sampleRate = 8000
+	pattern := filepath.Join(t.TempDir(), "out-%03d.wav")
+	s, err := NewWaveSplitter(pattern, sampleRate, 2, time.Second)
+	if err != nil {
+		t.Fatalf("NewWaveSplitter() error = %v", err)
+	}
+
+	// 2.5 seconds of stereo audio, written in odd-sized pieces that split frames.
+	samples := make([]int16, sampleRate*5/2*2)
+	for i := range samples {
+		samples[i] = int16(i)
+	}
+	data := pcm.EncodeInt16(nil, samples)
+	for len(data) > 0 {
+		n := min(len(data), 777)
+		if _, err := s.Write(data[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		data = data[n:]
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	files := s.Files()
+	wantDurations := []time.Duration{time.Second, time.Second, 500 * time.Millisecond}
+	if len(files) != len(wantDurations) {
+		t.Fatalf("Files() = %v, want %d files", files, len(wantDurations))
+	}
+	next := int16(0)
+	for i, name := range files {
+		if want := filepath.Join(filepath.Dir(pattern), []string{"out-000.wav", "out-001.wav", "out-002.wav"}[i]); name != want {
+			t.Errorf("file %d = %s, want %s", i, name, want)
+		}
+		wf, rate, channels, err := cgosonic.OpenInputWaveFile(name)
+		if err != nil {
+			t.Fatalf("OpenInputWaveFile(%s) error = %v", name, err)
+		}
+		if rate != sampleRate || channels != 2 || wf.Duration() != wantDurations[i] {
+			t.Errorf("%s: %d Hz, %d channels, %v, want %d Hz, 2 channels, %v", name, rate, channels, wf.Duration(), sampleRate, wantDurations[i])
+		}
+		// The files must continue each other seamlessly.
+		buf := make([]int16, 2*1024)
+		for {
+			n := wf.ReadFromWaveFile(buf, 1024)
+			if n <= 0 {
+				break
+			}
+			for _, v := range buf[:n*2] {
+				if v != next {
+					t.Fatalf("%s: sample = %d, want %d", name, v, next)
+				}
+				next++
+			}
+		}
+		wf.CloseWaveFile()
+	}
+}
+
+func TestWaveSplitter_Transformer(t *testing.T) {
+	pattern := filepath.Join(t.TempDir(), "part-%d.wav")
+	s, err := NewWaveSplitter(pattern, 44100, 1, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWaveSplitter() error = %v", err)
+	}
+	tr, err := NewTransformer(s, 44100, AudioFormatPCM, WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(make([]byte, 44100*2)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// 1 second at speed 2 gives about 0.5 seconds of output.
+	if got := len(s.Files()); got != 3 {
+		t.Errorf("Files() = %v, want 3 files", s.Files())
+	}
+}
+
+func TestNewWaveSplitter_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  int
+		numChannels int
+		maxDuration time.Duration
+	}{
+		{"sample rate", 0, 1, time.Second},
+		{"channels", 44100, 0, time.Second},
+		{"duration", 44100, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWaveSplitter("out-%d.wav", tt.sampleRate, tt.numChannels, tt.maxDuration)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("NewWaveSplitter() error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sonic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_Stats(t *testing.T) {
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate])
+
+	tests := []struct {
+		name   string
+		writer func() *shortWriter
+		output bool
+	}{
+		{"writer", func() *shortWriter { return &shortWriter{max: 1 << 30} }, false},
+		{"short writes", func() *shortWriter { return &shortWriter{max: 333} }, false},
+		{"failing writer", func() *shortWriter {
+			return &shortWriter{max: 1 << 30, failAfter: 1001, err: errors.New("connection reset")}
+		}, false},
+		{"output func", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := []Option{WithSpeed(1.5), WithInputHash(crc32.NewIEEE()), WithOutputHash(sha256.New())}
+			var w *shortWriter
+			if tt.output {
+				opts = append(opts, WithOutputFunc(func(p []byte) error {
+					out.Write(p)
+					return nil
+				}))
+			} else {
+				w = tt.writer()
+			}
+			var dst io.Writer
+			if w != nil {
+				dst = w
+			}
+			tr, err := NewTransformer(dst, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			for p := input; len(p) > 0; {
+				n, _ := tr.Write(p[:min(len(p), 5000)])
+				p = p[n:]
+			}
+			for range maxStalledWrites {
+				if tr.Flush() == nil {
+					break
+				}
+			}
+			if w != nil {
+				out = w.Buffer
+			}
+
+			s := tr.Stats()
+			if s.InputBytes != int64(len(input)) {
+				t.Errorf("InputBytes = %d, want %d", s.InputBytes, len(input))
+			}
+			if want := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(input)); !bytes.Equal(s.InputSum, want) {
+				t.Errorf("InputSum = %x, want %x", s.InputSum, want)
+			}
+			if s.OutputBytes != int64(out.Len()) || out.Len() == 0 {
+				t.Errorf("OutputBytes = %d, want %d", s.OutputBytes, out.Len())
+			}
+			if want := sha256.Sum256(out.Bytes()); !bytes.Equal(s.OutputSum, want[:]) {
+				t.Errorf("OutputSum = %x, want %x", s.OutputSum, want)
+			}
+		})
+	}
+}
+
+func TestTransformer_StatsWithoutHash(t *testing.T) {
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, 44100, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(make([]byte, 1000)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	s := tr.Stats()
+	if s.InputBytes != 1000 || s.OutputBytes != int64(out.Len()) || s.InputSum != nil || s.OutputSum != nil {
+		t.Errorf("Stats() = %+v, want 1000 input bytes, %d output bytes and no sums", s, out.Len())
+	}
+}
+
+func TestTransformer_ProcessedMediaDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		numChannels int
+		format      AudioFormat
+		opts        []Option
+		frames      int
+		want        time.Duration
+	}{
+		{"mono PCM", 1, AudioFormatPCM, nil, 24000, 500 * time.Millisecond},
+		{"stereo float", 2, AudioFormatIEEEFloat, nil, 12000, 250 * time.Millisecond},
+		{"speed does not matter", 1, AudioFormatPCM, []Option{WithSpeed(2), WithRate(0.5)}, 48000, time.Second},
+		{"held short input counts", 1, AudioFormatPCM, []Option{WithShortInput(ShortInputPad)}, 10, 10 * time.Second / 48000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithChannels(tt.numChannels)}, tt.opts...)
+			tr, err := NewTransformer(io.Discard, 48000, tt.format, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if got := tr.ProcessedMediaDuration(); got != 0 {
+				t.Errorf("ProcessedMediaDuration() = %v before Write, want 0", got)
+			}
+			input := make([]byte, tt.frames*tr.FrameSize())
+			for chunk := range slices.Chunk(input, 1000*tr.FrameSize()) {
+				if _, err := tr.Write(chunk); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if got := tr.ProcessedMediaDuration(); got != tt.want {
+				t.Errorf("ProcessedMediaDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package sonic
+
+import "github.com/nakat-t/sonic-go/internal/cgosonic"
+
+// Stream is the time-stretching stream a Transformer writes samples to and reads processed
+// samples from. Streams are created by an Engine; the default, EngineSonic, is backed by libsonic.
+//
+// Stream mirrors the libsonic stream API: all counts are in frames, i.e. samples per channel,
+// write and flush methods return 0 on failure, and FlushAndReadShort and FlushAndReadFloat
+// flush the stream and read its first output in one call, returning -1 if the flush failed.
+// Use WithEngine or WithStreamFactory to inject another implementation, e.g. an in-memory fake
+// from the sonictest package to test error paths without libsonic.
+type Stream interface {
+	WriteShortToStream(samples []int16, numFrames int) int
+	WriteFloatToStream(samples []float32, numFrames int) int
+	ReadShortFromStream(samples []int16, maxFrames int) int
+	ReadFloatFromStream(samples []float32, maxFrames int) int
+	FlushStream() int
+	FlushAndReadShort(samples []int16, maxFrames int) int
+	FlushAndReadFloat(samples []float32, maxFrames int) int
+
+	// PendingInputFrames estimates the number of input frames that have not been turned into
+	// output yet.
+	PendingInputFrames() int
+
+	GetSpeed() float32
+	SetSpeed(speed float32)
+	GetPitch() float32
+	SetPitch(pitch float32)
+	GetRate() float32
+	SetRate(rate float32)
+	GetVolume() float32
+	SetVolume(volume float32)
+	SetQuality(quality int)
+
+	// DestroyStream releases the resources of the stream. The stream is not used afterwards.
+	DestroyStream()
+}
+
+// StreamFactory creates a Stream for numChannels interleaved channels at sampleRate.
+// It implements Engine.
+type StreamFactory func(sampleRate, numChannels int) (Stream, error)
+
+var _ Stream = (*cgosonic.Stream)(nil)
@@ -0,0 +1,112 @@
+package sonic_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/sonictest"
+)
+
+func TestTransformer_StreamFactory(t *testing.T) {
+	input := []int16{1, -2, 3, -4, 5, -6, 7, -8}
+
+	tests := []struct {
+		name      string
+		streams   func() []*sonictest.Stream
+		opts      []sonic.Option
+		wantErr   error
+		wantWrite error
+		wantFlush error
+	}{
+		{
+			name:    "passthrough",
+			streams: func() []*sonictest.Stream { return []*sonictest.Stream{sonictest.NewStream()} },
+		},
+		{
+			name:    "mid-side",
+			streams: func() []*sonictest.Stream { return []*sonictest.Stream{sonictest.NewStream(), sonictest.NewStream()} },
+			opts:    []sonic.Option{sonic.WithMidSide()},
+		},
+		{
+			name:    "create failure",
+			streams: func() []*sonictest.Stream { return nil },
+			wantErr: sonic.ErrSonicCreateFailed,
+		},
+		{
+			name:    "mid-side create failure",
+			streams: func() []*sonictest.Stream { return []*sonictest.Stream{sonictest.NewStream()} },
+			opts:    []sonic.Option{sonic.WithMidSide()},
+			wantErr: sonic.ErrSonicCreateFailed,
+		},
+		{
+			name: "write failure",
+			streams: func() []*sonictest.Stream {
+				s := sonictest.NewStream()
+				s.FailWrite = true
+				return []*sonictest.Stream{s}
+			},
+			wantWrite: sonic.ErrSonicFailed,
+		},
+		{
+			name: "flush failure",
+			streams: func() []*sonictest.Stream {
+				s := sonictest.NewStream()
+				s.FailFlush = true
+				return []*sonictest.Stream{s}
+			},
+			wantFlush: sonic.ErrSonicFailed,
+		},
+		{
+			name: "mid-side flush failure",
+			streams: func() []*sonictest.Stream {
+				s := sonictest.NewStream()
+				s.FailFlush = true
+				return []*sonictest.Stream{sonictest.NewStream(), s}
+			},
+			opts:      []sonic.Option{sonic.WithMidSide()},
+			wantFlush: sonic.ErrSonicFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := tt.streams()
+			var out bytes.Buffer
+			opts := append([]sonic.Option{sonic.WithChannels(2), sonic.WithSpeed(2), sonic.WithStreamFactory(sonictest.Factory(streams...))}, tt.opts...)
+			tr, err := sonic.NewTransformer(&out, 8000, sonic.AudioFormatPCM, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewTransformer() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			for _, s := range streams {
+				if s.SampleRate != 8000 || s.Speed != 2 {
+					t.Errorf("stream sample rate = %d, speed = %v, want 8000, 2", s.SampleRate, s.Speed)
+				}
+			}
+			if _, err := tr.Write(pcm.EncodeInt16(nil, input)); !errors.Is(err, tt.wantWrite) {
+				t.Errorf("Write() error = %v, want %v", err, tt.wantWrite)
+			}
+			if err := tr.Flush(); !errors.Is(err, tt.wantFlush) {
+				t.Errorf("Flush() error = %v, want %v", err, tt.wantFlush)
+			}
+			if tt.wantWrite == nil && tt.wantFlush == nil {
+				if got := pcm.DecodeInt16(nil, out.Bytes()); !slices.Equal(got, input) {
+					t.Errorf("output = %v, want %v", got, input)
+				}
+			}
+
+			tr.Close()
+			for _, s := range streams {
+				if !s.Destroyed {
+					t.Error("Close() did not destroy the stream")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package sonic
+
+import "fmt"
+
+// teeInput writes the consumed input to the input tee, if any, and returns the number of bytes
+// consumed and err, or the error of the tee if err is nil.
+func (t *Transformer) teeInput(consumed []byte, err error) (int, error) {
+	if t.inputTee == nil || len(consumed) == 0 {
+		return len(consumed), err
+	}
+	if _, teeErr := t.inputTee.Write(consumed); teeErr != nil && err == nil {
+		err = fmt.Errorf("%w: failed to write to input tee: %w", ErrWrite, teeErr)
+	}
+	return len(consumed), err
+}
+
+// flushInputTee flushes the input tee if it supports flushing.
+func (t *Transformer) flushInputTee() error {
+	f, ok := t.inputTee.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+	if err := f.Flush(); err != nil {
+		return fmt.Errorf("%w: failed to flush input tee: %w", ErrWrite, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package sonic
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTransformer_InputTee(t *testing.T) {
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate])
+
+	// transform writes input in writes of chunk bytes and returns the output and the tee contents.
+	transform := func(t *testing.T, chunk int, opts ...Option) ([]byte, []byte) {
+		t.Helper()
+		var out, tee bytes.Buffer
+		tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, append(opts, WithSpeed(1.5), WithInputTee(&tee))...)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		for p := input; len(p) > 0; {
+			n := min(len(p), chunk)
+			if m, err := tr.Write(p[:n]); err != nil || m != n {
+				t.Fatalf("Write() = %d, %v, want %d", m, err, n)
+			}
+			p = p[n:]
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		return out.Bytes(), tee.Bytes()
+	}
+
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	tr.Write(input)
+	tr.Flush()
+
+	tests := []struct {
+		name  string
+		chunk int
+		opts  []Option
+	}{
+		{"single write", len(input), nil},
+		{"short input held", 100, []Option{WithShortInput(ShortInputPad)}},
+		{"aligned chunks", 882, []Option{WithAlignedChunks()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, tee := transform(t, tt.chunk, tt.opts...)
+			if !bytes.Equal(tee, input) {
+				t.Errorf("tee = %d bytes, want the %d input bytes", len(tee), len(input))
+			}
+			if tt.chunk == len(input) && !bytes.Equal(out, want.Bytes()) {
+				t.Error("output differs from a transformer without tee")
+			}
+		})
+	}
+}
+
+func TestTransformer_InputTeeErrors(t *testing.T) {
+	t.Run("incomplete sample", func(t *testing.T) {
+		var tee bytes.Buffer
+		tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithInputTee(&tee))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write([]byte{1, 2, 3}); err != nil {
+			t.Errorf("Write() error = %v", err)
+		}
+		if tee.Len() != 3 {
+			t.Errorf("tee = %d bytes, want the 3 bytes carried over", tee.Len())
+		}
+	})
+
+	t.Run("failing tee", func(t *testing.T) {
+		var out bytes.Buffer
+		tr, err := NewTransformer(&out, 44100, AudioFormatPCM, WithInputTee(&failingWriter{err: errors.New("disk full"), bytesUntilFail: -1}))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		n, err := tr.Write(make([]byte, 8192))
+		if !errors.Is(err, ErrWrite) || n != 8192 {
+			t.Errorf("Write() = %d, %v, want 8192 and %v", n, err, ErrWrite)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if out.Len() != 8192 {
+			t.Errorf("output = %d bytes, want 8192", out.Len())
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		var archive bytes.Buffer
+		tee := bufio.NewWriter(&archive)
+		tr, err := NewTransformer(new(bytes.Buffer), 44100, AudioFormatPCM, WithInputTee(tee))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(make([]byte, 100)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if archive.Len() != 0 {
+			t.Fatalf("archive before Flush = %d bytes, want 0", archive.Len())
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if archive.Len() != 100 {
+			t.Errorf("archive after Flush = %d bytes, want 100", archive.Len())
+		}
+	})
+}
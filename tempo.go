@@ -0,0 +1,42 @@
+package sonic
+
+import (
+	"math"
+
+	"github.com/nakat-t/sonic-go/internal/cgosonic"
+)
+
+// TempoPitch returns the speed, pitch and rate that make a transformer play its input at tempo
+// times the original tempo, with the pitch scaled by pitch, e.g. TempoPitch(1.5, 1) for 1.5x
+// faster speech at the original pitch, or TempoPitch(1, PitchForSemitones(-2)) for the original
+// tempo two semitones lower.
+//
+// The three parameters of libsonic interact: the speed changes the tempo only, the pitch changes
+// the pitch only, and the rate changes both, as playing the samples faster does. The output
+// plays at speed*rate times the tempo and pitch*rate times the pitch. TempoPitch uses the speed
+// and the pitch, and moves the part of the change that exceeds their ranges into the rate, so that
+// tempos and pitches down to 0.0025 and up to 400 are reachable. Tempo and pitch are clamped to
+// that range.
+//
+// The tempo divided by the pitch is the time-stretch factor, which no choice of the parameters
+// changes; if it exceeds the limits of the stream, the pitch is adjusted as described for
+// WithPitch. The rate is ignored by Update with WithNominalRate, so apply such settings with
+// WithTempoPitch instead. Apply the returned settings with Update.
+func TempoPitch(tempo, pitch float32) Settings {
+	tempo = clamp(tempo, cgosonic.MIN_SPEED*cgosonic.MIN_RATE, cgosonic.MAX_SPEED*cgosonic.MAX_RATE)
+	pitch = clamp(pitch, cgosonic.MIN_PITCH_SETTING*cgosonic.MIN_RATE, cgosonic.MAX_PITCH_SETTING*cgosonic.MAX_RATE)
+
+	// Choose the rate closest to 1 that keeps the speed and the pitch within their ranges.
+	lo := float32(math.Max(float64(tempo/cgosonic.MAX_SPEED), float64(pitch/cgosonic.MAX_PITCH_SETTING)))
+	hi := float32(math.Min(float64(tempo/cgosonic.MIN_SPEED), float64(pitch/cgosonic.MIN_PITCH_SETTING)))
+	rate := clamp(clamp(1, lo, hi), cgosonic.MIN_RATE, cgosonic.MAX_RATE)
+	speed := clamp(tempo/rate, cgosonic.MIN_SPEED, cgosonic.MAX_SPEED)
+	pitch = clamp(pitch/rate, cgosonic.MIN_PITCH_SETTING, cgosonic.MAX_PITCH_SETTING)
+	return Settings{Speed: &speed, Pitch: &pitch, Rate: &rate}
+}
+
+// PitchForSemitones returns the pitch factor that shifts the pitch by semitones, which may be
+// negative or fractional, e.g. 12 for 2 (one octave higher) or -1 for about 0.944.
+func PitchForSemitones(semitones float64) float32 {
+	return float32(math.Exp2(semitones / 12))
+}
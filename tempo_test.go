@@ -0,0 +1,113 @@
+package sonic
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestTempoPitch(t *testing.T) {
+	tests := []struct {
+		tempo, pitch               float32
+		wantSpeed, wantPitch       float32
+		wantRate                   float32
+		wantTempoOut, wantPitchOut float32 // speed*rate and pitch*rate
+	}{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1.5, 1, 1.5, 1, 1, 1.5, 1},
+		{1, 0.5, 1, 0.5, 1, 1, 0.5},
+		{2, 2, 2, 2, 1, 2, 2},
+		{40, 1, 20, 0.5, 2, 40, 1},        // The tempo exceeds the speed range
+		{1, 0.01, 5, 0.05, 0.2, 1, 0.01},  // The pitch falls below the pitch range
+		{0.01, 2, 0.05, 10, 0.2, 0.01, 2}, // The rate lowers both
+		{500, 1, 20, 0.05, 20, 400, 1},    // Clamped, stretched beyond the limits
+		{0.001, 0.001, 0.05, 0.05, 0.05, 0.0025, 0.0025},
+	}
+	near := func(got, want float32) bool { return math.Abs(float64(got-want)) <= 1e-5*math.Abs(float64(want)) }
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("tempo %v pitch %v", tt.tempo, tt.pitch), func(t *testing.T) {
+			s := TempoPitch(tt.tempo, tt.pitch)
+			if s.Speed == nil || s.Pitch == nil || s.Rate == nil {
+				t.Fatalf("TempoPitch() = %+v, want speed, pitch and rate", s)
+			}
+			speed, pitch, rate := *s.Speed, *s.Pitch, *s.Rate
+			if !near(speed, tt.wantSpeed) || !near(pitch, tt.wantPitch) || !near(rate, tt.wantRate) {
+				t.Errorf("TempoPitch() = speed %v, pitch %v, rate %v; want %v, %v, %v", speed, pitch, rate, tt.wantSpeed, tt.wantPitch, tt.wantRate)
+			}
+			if !near(speed*rate, tt.wantTempoOut) || !near(pitch*rate, tt.wantPitchOut) {
+				t.Errorf("output tempo %v and pitch %v, want %v and %v", speed*rate, pitch*rate, tt.wantTempoOut, tt.wantPitchOut)
+			}
+		})
+	}
+}
+
+// TestTransformer_TempoPitch checks the tempo and the pitch of a tone transformed with
+// WithTempoPitch: the output lasts 1/tempo times the input, and the tone has pitch times its
+// frequency.
+func TestTransformer_TempoPitch(t *testing.T) {
+	const (
+		sampleRate = 44100
+		freq       = 200
+	)
+	input := pcm.EncodeInt16(nil, sineInt16(freq, sampleRate, 2*sampleRate))
+	tests := []struct {
+		tempo, pitch float32
+		opts         []Option
+	}{
+		{1.5, 1, nil},
+		{1, PitchForSemitones(-5), nil},
+		{0.7, 1.3, nil},
+		{2, 2, nil},
+		{0.04, 0.5, nil}, // Uses the rate
+		{1.4, 0.6, []Option{WithNominalRate()}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("tempo %v pitch %v", tt.tempo, tt.pitch), func(t *testing.T) {
+			var out bytes.Buffer
+			tr, err := NewTransformer(&out, sampleRate, AudioFormatPCM, append(tt.opts, WithTempoPitch(tt.tempo, tt.pitch))...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(input); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			output := pcm.DecodeInt16(nil, out.Bytes())
+			duration := float64(len(output)) / float64(tr.OutputSampleRate())
+			if want := 2 / float64(tt.tempo); math.Abs(duration-want) > 0.05*want+0.01 {
+				t.Errorf("output lasts %.3fs, want %.3fs", duration, want)
+			}
+			if duration > 0.05 {
+				got := float64(zeroCrossings(output)) / duration
+				if want := freq * float64(tt.pitch); math.Abs(got-want) > 0.05*want {
+					t.Errorf("output tone = %.1f Hz, want %.1f Hz", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPitchForSemitones(t *testing.T) {
+	tests := []struct {
+		semitones float64
+		want      float32
+	}{
+		{0, 1},
+		{12, 2},
+		{-12, 0.5},
+		{7, 1.4983071},
+		{-1, 0.94387431},
+	}
+	for _, tt := range tests {
+		if got := PitchForSemitones(tt.semitones); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("PitchForSemitones(%v) = %v, want %v", tt.semitones, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// Command bazelgen generates BUILD.bazel files for sonic-go, so that the module can be vendored
+// into a Bazel monorepo together with the C sources of libsonic.
+//
+// The rules follow the layout and naming of gazelle with rules_go: one go_library per package,
+// named after the last element of its import path, a go_test embedding it, and a go_binary for
+// commands. The C sources, headers and CFLAGS of cgo packages are taken from the go/build
+// metadata of the package, the same as for go build, so the generated rules stay in sync with
+// the #cgo directives. ${SRCDIR} in the flags is replaced by the directory of the package in the
+// workspace. Files are listed regardless of their build constraints, as by gazelle, since
+// rules_go applies them itself; this keeps the cgo and the pure-Go variants of a package in one
+// rule.
+//
+// Usage:
+//
+//	go run ./tools/bazelgen [-prefix dir] [-w | -check] [root]
+//
+// root is the directory of the module and defaults to the current directory. -prefix is the
+// directory of the module in the workspace, e.g. third_party/sonic-go, and defaults to the
+// workspace root. Without -w or -check, the files are printed to stdout. -w writes them next to
+// the packages, and -check reports the files that are missing or out of date and exits with
+// status 1.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// buildFileName is the name of the generated files.
+const buildFileName = "BUILD.bazel"
+
+// buildContext imports the files of all build configurations, with cgo enabled so that the
+// #cgo directives are parsed.
+var buildContext = func() build.Context {
+	ctx := build.Default
+	ctx.UseAllFiles = true
+	ctx.CgoEnabled = true
+	return ctx
+}()
+
+func main() {
+	prefix := flag.String("prefix", "", "directory of the module in the workspace")
+	write := flag.Bool("w", false, "write the files instead of printing them")
+	check := flag.Bool("check", false, "report files that are missing or out of date")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bazelgen [-prefix dir] [-w | -check] [root]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	root := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		root = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := generate(root, *prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bazelgen: %v\n", err)
+		os.Exit(1)
+	}
+	stale := false
+	for _, rel := range slices.Sorted(maps.Keys(files)) {
+		name := filepath.Join(root, filepath.FromSlash(rel))
+		switch {
+		case *check:
+			if old, err := os.ReadFile(name); err != nil || !bytes.Equal(old, files[rel]) {
+				fmt.Printf("%s is out of date\n", name)
+				stale = true
+			}
+		case *write:
+			if err := os.WriteFile(name, files[rel], 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "bazelgen: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("# %s\n%s\n", name, files[rel])
+		}
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// generate returns the BUILD.bazel files for the packages of the module at root, keyed by their
+// slash-separated path relative to root. prefix is the directory of the module in the workspace.
+func generate(root, prefix string) (map[string][]byte, error) {
+	modulePath, err := readModulePath(root)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.Trim(path.Clean("/"+filepath.ToSlash(prefix)), "/")
+	g := &generator{modulePath: modulePath, prefix: prefix}
+
+	files := make(map[string][]byte)
+	err = filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if dir != root {
+			// Skip testdata, hidden directories and nested modules, as the go command does
+			name := d.Name()
+			if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		pkg, err := buildContext.ImportDir(abs, 0)
+		if err != nil {
+			var noGo *build.NoGoError
+			if errors.As(err, &noGo) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		content, err := g.buildFile(rel, pkg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		files[path.Join(rel, buildFileName)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readModulePath returns the module path declared in the go.mod file at root.
+func readModulePath(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("%s: no module directive", filepath.Join(root, "go.mod"))
+}
+
+// generator generates the rules of the packages of one module.
+type generator struct {
+	modulePath string // Module path from go.mod
+	prefix     string // Directory of the module in the workspace, "" for the workspace root
+}
+
+// workspaceDir returns the directory in the workspace of the package at rel in the module.
+func (g *generator) workspaceDir(rel string) string {
+	return path.Join(g.prefix, rel)
+}
+
+// importPath returns the import path of the package at rel in the module.
+func (g *generator) importPath(rel string) string {
+	return path.Join(g.modulePath, rel)
+}
+
+// libraryName returns the name of the go_library of the package at rel, as gazelle names it.
+func (g *generator) libraryName(rel string, isCommand bool) string {
+	name := path.Base(g.importPath(rel))
+	if isCommand {
+		return name + "_lib"
+	}
+	return name
+}
+
+// label returns the label of the go_library for importPath, and false if it is not a package of
+// the module. The name is left out if it matches the directory, as buildifier does.
+func (g *generator) label(importPath string) (string, bool) {
+	rel, ok := strings.CutPrefix(importPath, g.modulePath)
+	if !ok || (rel != "" && rel[0] != '/') {
+		return "", false
+	}
+	dir := g.workspaceDir(strings.TrimPrefix(rel, "/"))
+	name := path.Base(importPath)
+	if dir == "" || path.Base(dir) != name {
+		return "//" + dir + ":" + name, true
+	}
+	return "//" + dir, true
+}
+
+// deps returns the sorted labels of the packages of the module in imports, except the package
+// at rel itself. Imports from the standard library need no deps.
+func (g *generator) deps(rel string, imports []string) ([]string, error) {
+	self := g.importPath(rel)
+	var deps []string
+	for _, imp := range imports {
+		if imp == "C" || imp == self {
+			continue
+		}
+		if label, ok := g.label(imp); ok {
+			deps = append(deps, label)
+			continue
+		}
+		if !strings.Contains(strings.Split(imp, "/")[0], ".") {
+			continue // Standard library
+		}
+		return nil, fmt.Errorf("import of %s outside the module is not supported", imp)
+	}
+	slices.Sort(deps)
+	return slices.Compact(deps), nil
+}
+
+// visibility returns the visibility of the library at rel. As for the go command, packages in an
+// internal directory are visible to the tree rooted at the parent of internal only.
+func (g *generator) visibility(rel string, isCommand bool) string {
+	if isCommand {
+		return "//visibility:private"
+	}
+	elems := strings.Split(rel, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] == "internal" {
+			return "//" + g.workspaceDir(strings.Join(elems[:i], "/")) + ":__subpackages__"
+		}
+	}
+	return "//visibility:public"
+}
+
+// copts returns the CFLAGS of pkg with ${SRCDIR} replaced by the workspace directory of rel.
+// go/build has already expanded ${SRCDIR} to pkg.Dir. Flags repeated in the #cgo directives of
+// several files are listed once.
+func (g *generator) copts(rel string, pkg *build.Package) []string {
+	dir := g.workspaceDir(rel)
+	if dir == "" {
+		dir = "."
+	}
+	var copts []string
+	for _, flag := range pkg.CgoCFLAGS {
+		flag = strings.ReplaceAll(flag, pkg.Dir, dir)
+		if !slices.Contains(copts, flag) {
+			copts = append(copts, flag)
+		}
+	}
+	return copts
+}
+
+// buildFile returns the BUILD.bazel file of pkg at rel.
+func (g *generator) buildFile(rel string, pkg *build.Package) ([]byte, error) {
+	isCommand := pkg.Name == "main"
+	lib := g.libraryName(rel, isCommand)
+
+	var b bytes.Buffer
+	b.WriteString("# Generated by tools/bazelgen. Run it again after adding files or imports.\n\n")
+	kinds := []string{"go_library"}
+	if isCommand {
+		kinds = append(kinds, "go_binary")
+	}
+	hasTests := len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) > 0
+	if hasTests {
+		kinds = append(kinds, "go_test")
+	}
+	fmt.Fprintf(&b, "load(\"@io_bazel_rules_go//go:def.bzl\", %s)\n", strings.Join(quoteAll(kinds), ", "))
+	if rel == "" {
+		// Lets gazelle resolve imports of the module to these rules
+		fmt.Fprintf(&b, "\n# gazelle:prefix %s\n", g.modulePath)
+	}
+
+	deps, err := g.deps(rel, pkg.Imports)
+	if err != nil {
+		return nil, err
+	}
+	embedSrcs, err := embedFiles(pkg.Dir, pkg.EmbedPatterns)
+	if err != nil {
+		return nil, err
+	}
+	r := rule{kind: "go_library", name: lib}
+	r.list("srcs", slices.Sorted(slices.Values(slices.Concat(pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.HFiles))))
+	if len(pkg.CgoFiles) > 0 {
+		r.attr("cgo", "True")
+		r.list("clinkopts", pkg.CgoLDFLAGS)
+		r.list("copts", g.copts(rel, pkg))
+	}
+	r.list("embedsrcs", embedSrcs)
+	r.attr("importpath", quote(g.importPath(rel)))
+	r.attr("visibility", quoteList([]string{g.visibility(rel, isCommand)}))
+	r.list("deps", deps)
+	r.write(&b)
+
+	if isCommand {
+		r := rule{kind: "go_binary", name: path.Base(g.importPath(rel))}
+		r.attr("embed", quoteList([]string{":" + lib}))
+		r.attr("visibility", quoteList([]string{"//visibility:public"}))
+		r.write(&b)
+	}
+
+	if hasTests {
+		deps, err := g.deps(rel, slices.Concat(pkg.TestImports, pkg.XTestImports))
+		if err != nil {
+			return nil, err
+		}
+		embedSrcs, err := embedFiles(pkg.Dir, slices.Concat(pkg.TestEmbedPatterns, pkg.XTestEmbedPatterns))
+		if err != nil {
+			return nil, err
+		}
+		r := rule{kind: "go_test", name: path.Base(g.importPath(rel)) + "_test"}
+		r.list("srcs", slices.Sorted(slices.Values(slices.Concat(pkg.TestGoFiles, pkg.XTestGoFiles))))
+		r.list("embedsrcs", embedSrcs)
+		r.attr("embed", quoteList([]string{":" + lib}))
+		r.list("deps", deps)
+		r.write(&b)
+	}
+	return b.Bytes(), nil
+}
+
+// embedFiles returns the sorted files matched by the //go:embed patterns in dir, relative to dir.
+func embedFiles(dir string, patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "all:")
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(name string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, name)
+				files = append(files, filepath.ToSlash(rel))
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// rule is a Bazel rule being formatted as buildifier does.
+type rule struct {
+	kind  string
+	name  string
+	attrs []string
+}
+
+// attr adds the attribute name with the formatted value.
+func (r *rule) attr(name, value string) {
+	r.attrs = append(r.attrs, fmt.Sprintf("    %s = %s,\n", name, value))
+}
+
+// list adds the attribute name with the list of strings values, one per line. An empty list is
+// omitted.
+func (r *rule) list(name string, values []string) {
+	switch len(values) {
+	case 0:
+		return
+	case 1:
+		r.attr(name, quoteList(values))
+		return
+	}
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, v := range values {
+		fmt.Fprintf(&b, "        %s,\n", quote(v))
+	}
+	b.WriteString("    ]")
+	r.attr(name, b.String())
+}
+
+// write writes the rule to b, preceded by an empty line.
+func (r *rule) write(b *bytes.Buffer) {
+	fmt.Fprintf(b, "\n%s(\n    name = %s,\n", r.kind, quote(r.name))
+	for _, a := range r.attrs {
+		b.WriteString(a)
+	}
+	b.WriteString(")\n")
+}
+
+// quote returns s as a Starlark string literal.
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
+
+// quoteAll returns values as Starlark string literals.
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quote(v)
+	}
+	return quoted
+}
+
+// quoteList returns values as a Starlark list literal on one line.
+func quoteList(values []string) string {
+	return "[" + strings.Join(quoteAll(values), ", ") + "]"
+}
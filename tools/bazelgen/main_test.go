@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModule writes files, keyed by slash-separated paths, into a new module in a temporary
+// directory and returns its root.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGenerate(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go.mod":                  "module example.com/mod\n\ngo 1.24\n",
+		"mod.go":                  "package mod\n\nimport _ \"example.com/mod/internal/c\"\n",
+		"mod_test.go":             "package mod_test\n\nimport _ \"example.com/mod\"\n",
+		"internal/c/c.go":         "package c\n\n// #cgo CFLAGS: -I${SRCDIR}\n// #include \"c.h\"\nimport \"C\"\n",
+		"internal/c/c.h":          "",
+		"internal/c/c.c":          "",
+		"data/data.go":            "package data\n\nimport _ \"embed\"\n\n//go:embed testdata/x.txt\nvar x string\n",
+		"data/testdata/x.txt":     "x",
+		"cmd/tool/main.go":        "package main\n\nimport _ \"example.com/mod/data\"\n\nfunc main() {}\n",
+		"nested/go.mod":           "module example.com/nested\n",
+		"nested/nested.go":        "package nested\n",
+		"docs/README":             "no Go files",
+		"internal/c/testdata/t.c": "",
+	})
+
+	tests := []struct {
+		name   string
+		prefix string
+		file   string
+		want   []string
+	}{
+		{
+			name: "root",
+			file: "BUILD.bazel",
+			want: []string{
+				`load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")`,
+				`# gazelle:prefix example.com/mod`,
+				`name = "mod",`,
+				`srcs = ["mod.go"],`,
+				`importpath = "example.com/mod",`,
+				`visibility = ["//visibility:public"],`,
+				`deps = ["//internal/c"],`,
+				`name = "mod_test",`,
+				`srcs = ["mod_test.go"],`,
+				`embed = [":mod"],`,
+			},
+		},
+		{
+			name:   "cgo",
+			prefix: "third_party/mod",
+			file:   "internal/c/BUILD.bazel",
+			want: []string{
+				"srcs = [\n        \"c.c\",\n        \"c.go\",\n        \"c.h\",\n    ],",
+				`cgo = True,`,
+				`copts = ["-Ithird_party/mod/internal/c"],`,
+				`visibility = ["//third_party/mod:__subpackages__"],`,
+			},
+		},
+		{
+			name:   "dep on root with prefix",
+			prefix: "third_party/mod",
+			file:   "cmd/tool/BUILD.bazel",
+			want: []string{
+				`load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_binary")`,
+				`name = "tool_lib",`,
+				`visibility = ["//visibility:private"],`,
+				`deps = ["//third_party/mod/data"],`,
+				"go_binary(\n    name = \"tool\",\n    embed = [\":tool_lib\"],",
+			},
+		},
+		{
+			name: "embed",
+			file: "data/BUILD.bazel",
+			want: []string{
+				`embedsrcs = ["testdata/x.txt"],`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := generate(root, tt.prefix)
+			if err != nil {
+				t.Fatalf("generate() error = %v", err)
+			}
+			got, ok := files[tt.file]
+			if !ok {
+				t.Fatalf("generate() has no %s", tt.file)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("%s does not contain %q:\n%s", tt.file, want, got)
+				}
+			}
+			if tt.file != "BUILD.bazel" && strings.Contains(string(got), "gazelle:prefix") {
+				t.Errorf("%s contains the gazelle:prefix directive", tt.file)
+			}
+		})
+	}
+
+	t.Run("files", func(t *testing.T) {
+		files, err := generate(root, "")
+		if err != nil {
+			t.Fatalf("generate() error = %v", err)
+		}
+		for _, name := range []string{"BUILD.bazel", "internal/c/BUILD.bazel", "data/BUILD.bazel", "cmd/tool/BUILD.bazel"} {
+			if _, ok := files[name]; !ok {
+				t.Errorf("generate() has no %s", name)
+			}
+		}
+		for _, name := range []string{"nested/BUILD.bazel", "docs/BUILD.bazel", "internal/c/testdata/BUILD.bazel"} {
+			if _, ok := files[name]; ok {
+				t.Errorf("generate() has %s", name)
+			}
+		}
+	})
+}
+
+func TestGenerate_ExternalImport(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go.mod": "module example.com/mod\n",
+		"mod.go": "package mod\n\nimport _ \"example.org/other\"\n",
+	})
+	if _, err := generate(root, ""); err == nil {
+		t.Error("generate() error = nil, want an error for the import outside the module")
+	}
+}
+
+// TestGenerate_Module checks that the rule of the cgo package of this module lists every C
+// source it is built with.
+func TestGenerate_Module(t *testing.T) {
+	files, err := generate("../..", "")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	got := string(files["internal/cgosonic/BUILD.bazel"])
+	for _, want := range []string{`"sonic.c"`, `"sonic.h"`, `"wave.c"`, `"wave.h"`, `cgo = True`, `"-Iinternal/cgosonic"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("internal/cgosonic/BUILD.bazel does not contain %s:\n%s", want, got)
+		}
+	}
+	if _, ok := files["submodules/BUILD.bazel"]; ok {
+		t.Error("generate() has a file for the nested module in submodules")
+	}
+}
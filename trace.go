@@ -0,0 +1,69 @@
+package sonic
+
+// Tracer starts spans for the operations of a transformer. It is a thin hook for distributed
+// tracing systems such as OpenTelemetry, which the package does not import: adapt the tracer of
+// the system to this interface, e.g. by starting a child span of a context captured by the
+// adapter. See WithTracer.
+type Tracer interface {
+	// StartSpan starts a span named name.
+	StartSpan(name string) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span. value is an int64, a float64 or a string.
+	SetAttribute(key string, value any)
+
+	// End ends the span. err is the error returned by the operation, or nil.
+	End(err error)
+}
+
+// Span attribute keys
+const (
+	AttrStage       = "sonic.stage"        // Name of the transformer given with WithTracer
+	AttrInputBytes  = "sonic.input_bytes"  // Input bytes consumed by Write
+	AttrInputFrames = "sonic.input_frames" // Input frames consumed by Write
+	AttrOutputBytes = "sonic.output_bytes" // Output bytes delivered during the operation
+	AttrSampleRate  = "sonic.sample_rate"  // Input sample rate
+	AttrChannels    = "sonic.channels"     // Number of input channels
+	AttrSpeed       = "sonic.speed"
+	AttrPitch       = "sonic.pitch"
+	AttrRate        = "sonic.rate"
+	AttrVolume      = "sonic.volume"
+)
+
+// startSpan starts a span for the operation op, e.g. "Write", and sets the attributes describing
+// the transformer.
+func (t *Transformer) startSpan(op string) Span {
+	span := t.tracer.StartSpan(t.stage + "." + op)
+	span.SetAttribute(AttrStage, t.stage)
+	span.SetAttribute(AttrSampleRate, int64(t.sampleRate))
+	span.SetAttribute(AttrChannels, int64(t.numChannels))
+	for _, p := range []struct {
+		key   string
+		value *float32
+	}{
+		{AttrSpeed, t.speed},
+		{AttrPitch, t.pitch},
+		{AttrRate, t.rate},
+		{AttrVolume, t.volume},
+	} {
+		v := 1.0
+		if p.value != nil {
+			v = float64(*p.value)
+		}
+		span.SetAttribute(p.key, v)
+	}
+	return span
+}
+
+// endSpan sets the attributes of the data that passed through the transformer since the span
+// started, given the counters at the start, and ends it.
+func (t *Transformer) endSpan(span Span, inputBytes, outputBytes int64, err error) {
+	if n := t.inputBytes - inputBytes; n > 0 {
+		span.SetAttribute(AttrInputBytes, n)
+		span.SetAttribute(AttrInputFrames, n/int64(t.FrameSize()))
+	}
+	span.SetAttribute(AttrOutputBytes, t.outputBytes-outputBytes)
+	span.End(err)
+}
@@ -0,0 +1,132 @@
+package sonic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// recordingTracer records the spans it starts.
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (r *recordingTracer) StartSpan(name string) Span {
+	s := &recordingSpan{name: name, attrs: map[string]any{}}
+	r.spans = append(r.spans, s)
+	return s
+}
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]any
+	ended bool
+	err   error
+}
+
+func (s *recordingSpan) SetAttribute(key string, value any) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func TestTransformer_Tracer(t *testing.T) {
+	input := pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate])
+
+	tracer := &recordingTracer{}
+	var out bytes.Buffer
+	tr, err := NewTransformer(&out, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(2), WithTracer("tts", tracer))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(input); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	written := out.Len()
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("started %d spans, want 2", len(tracer.spans))
+	}
+	write, flush := tracer.spans[0], tracer.spans[1]
+	tests := []struct {
+		name      string
+		span      *recordingSpan
+		wantName  string
+		wantAttrs map[string]any
+	}{
+		{"write", write, "tts.Write", map[string]any{
+			AttrStage:       "tts",
+			AttrInputBytes:  int64(len(input)),
+			AttrInputFrames: int64(len(input) / 2),
+			AttrOutputBytes: int64(written),
+			AttrSampleRate:  int64(audiotest.SpeechSampleRate),
+			AttrChannels:    int64(1),
+			AttrSpeed:       2.0,
+			AttrPitch:       1.0,
+			AttrRate:        1.0,
+			AttrVolume:      1.0,
+		}},
+		{"flush", flush, "tts.Flush", map[string]any{
+			AttrStage:       "tts",
+			AttrOutputBytes: int64(out.Len() - written),
+			AttrSampleRate:  int64(audiotest.SpeechSampleRate),
+			AttrChannels:    int64(1),
+			AttrSpeed:       2.0,
+			AttrPitch:       1.0,
+			AttrRate:        1.0,
+			AttrVolume:      1.0,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.span.name != tt.wantName {
+				t.Errorf("span name = %q, want %q", tt.span.name, tt.wantName)
+			}
+			if !tt.span.ended || tt.span.err != nil {
+				t.Errorf("span ended = %v, err = %v, want true, nil", tt.span.ended, tt.span.err)
+			}
+			if len(tt.span.attrs) != len(tt.wantAttrs) {
+				t.Errorf("span attributes = %v, want %v", tt.span.attrs, tt.wantAttrs)
+			}
+			for key, want := range tt.wantAttrs {
+				if got := tt.span.attrs[key]; got != want {
+					t.Errorf("span attribute %s = %v (%T), want %v (%T)", key, got, got, want, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformer_TracerError(t *testing.T) {
+	writeErr := errors.New("connection reset")
+	tracer := &recordingTracer{}
+	tr, err := NewTransformer(&failingWriter{err: writeErr, bytesUntilFail: -1}, audiotest.SpeechSampleRate, AudioFormatPCM, WithTracer("", tracer))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	_, err = tr.Write(pcm.EncodeInt16(nil, audiotest.Speech()[:audiotest.SpeechSampleRate]))
+	if err == nil {
+		err = tr.Flush()
+	}
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("error = %v, want %v", err, writeErr)
+	}
+	span := tracer.spans[len(tracer.spans)-1]
+	if span.name != "sonic.Write" && span.name != "sonic.Flush" {
+		t.Errorf("span name = %q", span.name)
+	}
+	if !span.ended || !errors.Is(span.err, writeErr) {
+		t.Errorf("span ended = %v, err = %v, want true, %v", span.ended, span.err, writeErr)
+	}
+}
@@ -0,0 +1,97 @@
+package sonic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// transformFileBuffer is the size of the read buffer of TransformFile.
+const transformFileBuffer = 64 << 10
+
+// TransformFile transforms the WAVE file inPath with opts and writes the result to the WAVE file
+// outPath, e.g. to speed up a voice recording in one call.
+//
+// The sample rate, the number of channels and the format of the input are taken from its header,
+// overriding WithChannels. With WithExpectedSampleRate, a header with another sample rate is
+// rejected with an error wrapping ErrInvalid, or accepted with a warning, depending on the mismatch
+// policy. The output has the format set with WithOutputFormat, or the input format, the channels
+// selected with WithSelectChannels or set with WithOutputChannels, and the rate returned by
+// OutputSampleRate.
+// Its header is completed with the actual sizes once all output is written. The output is the same
+// as that of a Transformer to which the whole input is written at once; a trailing incomplete frame
+// of the input is discarded. Files in the formats of the wav package are supported; other files
+// fail with an error wrapping ErrInvalid and wav.ErrUnsupported. WithOutputFunc must not be given,
+// since the output goes to outPath.
+//
+// The metadata chunks of the input, see wav.Metadata, are written to the output, with the
+// positions of cue points scaled by the ratio of output to input frames.
+//
+// outPath is created or truncated, and removed again if the transformation fails. It must not
+// be the same file as inPath.
+func TransformFile(inPath, outPath string, opts ...Option) (err error) {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if inInfo, err := in.Stat(); err == nil {
+		if outInfo, err := os.Stat(outPath); err == nil && os.SameFile(inInfo, outInfo) {
+			return fmt.Errorf("%w: %s is both input and output", ErrInvalid, outPath)
+		}
+	}
+	dec, err := wav.NewDecoder(bufio.NewReaderSize(in, transformFileBuffer))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalid, inPath, err)
+	}
+
+	// The writer is set once the output format is known.
+	opts = append(opts[:len(opts):len(opts)], WithChannels(dec.NumChannels()))
+	t, err := NewTransformer(io.Discard, dec.SampleRate(), AudioFormat(dec.Format()), opts...)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	if t.output != nil {
+		return fmt.Errorf("%w: WithOutputFunc cannot be used with TransformFile", ErrInvalid)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("%w: %w", ErrWrite, cerr)
+		}
+		if err != nil {
+			os.Remove(outPath)
+		}
+	}()
+	enc, err := wav.NewEncoder(out, t.OutputSampleRate(), t.outChannels, wav.Format(t.outFormat))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	t.w = enc
+
+	if _, err := t.ReadFrom(dec); err != nil {
+		return err
+	}
+	if err := t.Flush(); err != nil {
+		return err
+	}
+	dec.ReadTrailingMetadata()
+	// Cue points are sample positions, which move with the effective speed of the whole file.
+	stats := t.Stats()
+	if inFrames := stats.InputBytes / int64(dec.FrameSize()); inFrames > 0 {
+		outFrames := stats.OutputBytes / int64(t.OutputFrameSize())
+		enc.SetMetadata(dec.Metadata().ScaleSampleOffsets(float64(outFrames) / float64(inFrames)))
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package sonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// writeWaveFile writes data as a WAVE file of format to a file in a temporary directory and
+// returns its path.
+func writeWaveFile(t *testing.T, sampleRate, numChannels int, format wav.Format, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "in.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	e, err := wav.NewEncoder(f, sampleRate, numChannels, format)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	if _, err := e.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return path
+}
+
+func TestTransformFile(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	stereo := make([]int16, 2*len(speech))
+	for i, v := range speech {
+		stereo[2*i], stereo[2*i+1] = v, v/2
+	}
+	stereoFloat := pcm.EncodeFloat32(nil, pcm.Int16ToFloat32(nil, stereo, int16Scaling))
+
+	tests := []struct {
+		name         string
+		numChannels  int
+		format       wav.Format
+		data         []byte
+		opts         []Option
+		wantRate     int
+		wantChannels int
+		wantFormat   wav.Format
+	}{
+		{
+			name: "speed", numChannels: 1, format: wav.FormatPCM, data: pcm.EncodeInt16(nil, speech),
+			opts:     []Option{WithSpeed(2)},
+			wantRate: 48000, wantChannels: 1, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "stereo float", numChannels: 2, format: wav.FormatIEEEFloat, data: stereoFloat,
+			opts:     []Option{WithSpeed(1.5)},
+			wantRate: 48000, wantChannels: 2, wantFormat: wav.FormatIEEEFloat,
+		},
+		{
+			name: "selected channel to PCM", numChannels: 2, format: wav.FormatIEEEFloat, data: stereoFloat,
+			opts:     []Option{WithSpeed(1.5), WithSelectChannels(1), WithOutputFormat(AudioFormatPCM)},
+			wantRate: 48000, wantChannels: 1, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "channels from the header", numChannels: 2, format: wav.FormatPCM, data: pcm.EncodeInt16(nil, stereo),
+			opts:     []Option{WithChannels(1), WithSpeed(2)},
+			wantRate: 48000, wantChannels: 2, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "nominal rate", numChannels: 1, format: wav.FormatPCM, data: pcm.EncodeInt16(nil, speech),
+			opts:     []Option{WithRate(0.5), WithNominalRate()},
+			wantRate: 24000, wantChannels: 1, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "expected sample rate", numChannels: 1, format: wav.FormatPCM, data: pcm.EncodeInt16(nil, speech),
+			opts:     []Option{WithSpeed(2), WithExpectedSampleRate(48000, SampleRateMismatchError, nil)},
+			wantRate: 48000, wantChannels: 1, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "sample rate mismatch using the header", numChannels: 1, format: wav.FormatPCM, data: pcm.EncodeInt16(nil, speech),
+			opts:     []Option{WithSpeed(2), WithExpectedSampleRate(16000, SampleRateMismatchUseInput, nil)},
+			wantRate: 48000, wantChannels: 1, wantFormat: wav.FormatPCM,
+		},
+		{
+			name: "U8", numChannels: 1, format: wav.FormatU8, data: func() []byte {
+				b := make([]byte, len(speech))
+				pcm.Int16ToUint8(b, speech)
+				return b
+			}(),
+			opts:     []Option{WithSpeed(3)},
+			wantRate: 48000, wantChannels: 1, wantFormat: wav.FormatU8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := writeWaveFile(t, 48000, tt.numChannels, tt.format, tt.data)
+			out := filepath.Join(t.TempDir(), "out.wav")
+			if err := TransformFile(in, out, tt.opts...); err != nil {
+				t.Fatalf("TransformFile() error = %v", err)
+			}
+
+			// The output matches a Transformer writing to memory.
+			var want bytes.Buffer
+			tr, err := NewTransformer(&want, 48000, AudioFormat(tt.format), append(tt.opts, WithChannels(tt.numChannels))...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			if _, err := tr.Write(tt.data); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			file, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := binary.LittleEndian.Uint32(file[4:8]); int(got) != len(file)-8 {
+				t.Errorf("RIFF size = %d, want %d", got, len(file)-8)
+			}
+			d, err := wav.NewDecoder(bytes.NewReader(file))
+			if err != nil {
+				t.Fatalf("NewDecoder() error = %v", err)
+			}
+			if d.SampleRate() != tt.wantRate || d.NumChannels() != tt.wantChannels || d.Format() != tt.wantFormat {
+				t.Errorf("output = %v, %d channels at %d Hz, want %v, %d channels at %d Hz", d.Format(), d.NumChannels(), d.SampleRate(), tt.wantFormat, tt.wantChannels, tt.wantRate)
+			}
+			if d.DataBytes() != int64(want.Len()) {
+				t.Errorf("DataBytes() = %d, want %d", d.DataBytes(), want.Len())
+			}
+			got, _ := io.ReadAll(d)
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("output data differs from the output of a Transformer")
+			}
+		})
+	}
+}
+
+func TestTransformFile_SampleRateMismatch(t *testing.T) {
+	in := writeWaveFile(t, 48000, 1, wav.FormatPCM, pcm.EncodeInt16(nil, audiotest.Speech()[:4800]))
+	dir := t.TempDir()
+
+	var warned []int
+	warn := func(expected, input int) { warned = append(warned, expected, input) }
+	out := filepath.Join(dir, "error.wav")
+	if err := TransformFile(in, out, WithExpectedSampleRate(16000, SampleRateMismatchError, warn)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("TransformFile() with SampleRateMismatchError error = %v, want %v", err, ErrInvalid)
+	}
+	if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("output file exists after the error")
+	}
+	if warned != nil {
+		t.Errorf("TransformFile() with SampleRateMismatchError called the warning function with %v", warned)
+	}
+
+	out = filepath.Join(dir, "input.wav")
+	if err := TransformFile(in, out, WithExpectedSampleRate(16000, SampleRateMismatchUseInput, warn)); err != nil {
+		t.Fatalf("TransformFile() with SampleRateMismatchUseInput error = %v", err)
+	}
+	if !slices.Equal(warned, []int{16000, 48000}) {
+		t.Errorf("warned = %v, want [16000 48000]", warned)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := wav.NewDecoder(f)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	if d.SampleRate() != 48000 {
+		t.Errorf("output sample rate = %d, want the 48000 of the input", d.SampleRate())
+	}
+}
+
+func TestTransformFile_Metadata(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	in := filepath.Join(t.TempDir(), "in.wav")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := wav.NewEncoder(f, 48000, 1, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	// One cue point at frame 24000.
+	cue := binary.LittleEndian.AppendUint32(nil, 1)
+	cue = append(cue, "\x01\x00\x00\x00\xC0\x5D\x00\x00data\x00\x00\x00\x00\x00\x00\x00\x00\xC0\x5D\x00\x00"...)
+	bext := []byte("originator")
+	e.SetMetadata(wav.Metadata{"cue ": cue, "bext": bext})
+	e.Write(pcm.EncodeInt16(nil, speech))
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	f.Close()
+
+	out := filepath.Join(t.TempDir(), "out.wav")
+	if err := TransformFile(in, out, WithSpeed(2)); err != nil {
+		t.Fatalf("TransformFile() error = %v", err)
+	}
+	r, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	d, err := wav.NewDecoder(r)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	samples, _ := io.ReadAll(d)
+	d.ReadTrailingMetadata()
+	m := d.Metadata()
+	if !bytes.Equal(m["bext"], bext) {
+		t.Errorf("bext = %q, want %q", m["bext"], bext)
+	}
+	if len(m["cue "]) != len(cue) {
+		t.Fatalf("cue = %v, want a chunk of %d bytes", m["cue "], len(cue))
+	}
+	// The cue point moves with the effective speed, to the middle of the output.
+	want := int64(len(samples) / 2 * 24000 / len(speech))
+	for _, offset := range []int{8, 24} {
+		if got := int64(binary.LittleEndian.Uint32(m["cue "][offset:])); got < want-1 || want+1 < got {
+			t.Errorf("cue point at byte %d = %d, want %d", offset, got, want)
+		}
+	}
+}
+
+func TestTransformFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeWaveFile(t, 8000, 1, wav.FormatPCM, make([]byte, 1600))
+	garbage := filepath.Join(dir, "garbage.wav")
+	if err := os.WriteFile(garbage, []byte("not a wave file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pcm20 := filepath.Join(dir, "pcm20.wav")
+	b := []byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\xc0\x5d\x00\x00\x03\x00\x14\x00data\x00\x00\x00\x00")
+	if err := os.WriteFile(pcm20, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		in        string
+		out       string
+		opts      []Option
+		wantErr   error
+		wantNoOut bool
+	}{
+		{"missing input", filepath.Join(dir, "missing.wav"), filepath.Join(dir, "out1.wav"), nil, fs.ErrNotExist, true},
+		{"not a wave file", garbage, filepath.Join(dir, "out2.wav"), nil, wav.ErrInvalidFile, true},
+		{"invalid", garbage, filepath.Join(dir, "out3.wav"), nil, ErrInvalid, true},
+		{"unsupported", pcm20, filepath.Join(dir, "out4.wav"), nil, wav.ErrUnsupported, true},
+		{"same file", valid, valid, nil, ErrInvalid, false},
+		{"output func", valid, filepath.Join(dir, "out5.wav"), []Option{WithOutputFunc(func([]byte) error { return nil })}, ErrInvalid, true},
+		{"invalid option", valid, filepath.Join(dir, "out6.wav"), []Option{WithSelectChannels(3)}, ErrInvalid, true},
+		{"sample rate mismatch", valid, filepath.Join(dir, "out7.wav"), []Option{WithExpectedSampleRate(16000, SampleRateMismatchError, nil)}, ErrInvalid, true},
+		{"missing output directory", valid, filepath.Join(dir, "missing", "out.wav"), nil, fs.ErrNotExist, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := TransformFile(tt.in, tt.out, tt.opts...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("TransformFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(tt.out); tt.wantNoOut && !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("output file exists after the error")
+			}
+		})
+	}
+	// The input is left intact.
+	if d, err := os.ReadFile(valid); err != nil || len(d) != 44+1600 {
+		t.Errorf("input has %d bytes after the errors, want %d", len(d), 44+1600)
+	}
+}
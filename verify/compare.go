@@ -0,0 +1,161 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// Errors of comparisons
+var (
+	// ErrFormatMismatch is reported when the sample rate or the number of channels of a file
+	// differs from the reference.
+	ErrFormatMismatch = errors.New("format does not match reference")
+
+	// ErrSampleMismatch is reported when more samples than allowed differ from the reference.
+	ErrSampleMismatch = errors.New("samples do not match reference")
+)
+
+// Tolerance configures how much a file may differ from a reference in Compare. The zero value
+// requires an exact match.
+type Tolerance struct {
+	// LengthPercent is the allowed difference of the number of samples, in percent of the
+	// shorter file.
+	LengthPercent float64
+
+	// SampleDiff is the largest difference of a sample from the reference sample at the same
+	// position, in int16 steps, for which the samples still match.
+	SampleDiff int
+
+	// MismatchPercent is the allowed share of the compared samples that do not match, in percent.
+	MismatchPercent float64
+}
+
+// ReferenceTolerance is the tolerance of the reference tests of sonic-go against the output of
+// the sonic command-line tool of libsonic: the lengths may differ by 1%, and 1% of the samples
+// may differ by more than 5 steps. It allows for the rounding differences between builds of
+// libsonic, but not for writing the input in other chunk sizes, which changes the output more.
+var ReferenceTolerance = Tolerance{LengthPercent: 1, SampleDiff: 5, MismatchPercent: 1}
+
+// Comparison holds the result of comparing samples with reference samples.
+type Comparison struct {
+	Samples          int     // Number of samples
+	ReferenceSamples int     // Number of reference samples
+	LengthDiff       float64 // Difference of the numbers of samples, in percent of the smaller one
+
+	Compared   int     // Number of samples compared, i.e. of the shorter of the two
+	Mismatched int     // Number of compared samples that differ by more than Tolerance.SampleDiff
+	Mismatch   float64 // Mismatched in percent of Compared
+
+	MaxDiff      int // Largest difference of a sample from the reference, in int16 steps
+	MaxDiffIndex int // Index of the sample with the largest difference, or -1 if none differs
+}
+
+// CompareSamples compares the int16 samples got with the reference samples want, position by
+// position, e.g. in a golden test of the output of a sonic.Transformer.
+//
+// The comparison describes the differences, and the error joins one error for every exceeded
+// tolerance, wrapping ErrLengthMismatch or ErrSampleMismatch. The error is nil if the samples
+// are within tol.
+func CompareSamples(got, want []int16, tol Tolerance) (Comparison, error) {
+	c := Comparison{
+		Samples:          len(got),
+		ReferenceSamples: len(want),
+		Compared:         min(len(got), len(want)),
+		MaxDiffIndex:     -1,
+	}
+	var problems []error
+	if len(got) != len(want) {
+		c.LengthDiff = math.Inf(1)
+		if c.Compared > 0 {
+			c.LengthDiff = math.Abs(float64(len(got)-len(want))) / float64(c.Compared) * 100
+		}
+		if c.LengthDiff > tol.LengthPercent {
+			problems = append(problems, fmt.Errorf("%w: %d samples, reference has %d: %.2f%% > %.2f%%", ErrLengthMismatch, len(got), len(want), c.LengthDiff, tol.LengthPercent))
+		}
+	}
+
+	for i := range c.Compared {
+		diff := int(got[i]) - int(want[i])
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > c.MaxDiff {
+			c.MaxDiff, c.MaxDiffIndex = diff, i
+		}
+		if diff > tol.SampleDiff {
+			c.Mismatched++
+		}
+	}
+	if c.Compared > 0 {
+		c.Mismatch = float64(c.Mismatched) / float64(c.Compared) * 100
+	}
+	if c.Mismatch > tol.MismatchPercent {
+		problems = append(problems, fmt.Errorf("%w: %.2f%% of the samples differ by more than %d > %.2f%%, at most %d at sample %d", ErrSampleMismatch, c.Mismatch, tol.SampleDiff, tol.MismatchPercent, c.MaxDiff, c.MaxDiffIndex))
+	}
+	return c, errors.Join(problems...)
+}
+
+// Compare compares the WAVE file r with the reference WAVE file ref. See CompareSamples.
+//
+// 16-bit PCM and 32-bit float files are supported; float samples are converted to int16 with
+// pcm.Scaling32767, as sonic.Transformer does. If a file cannot be parsed, the error wraps
+// ErrInvalidFile. If the sample rates or the numbers of channels differ, the error wraps
+// ErrFormatMismatch and the samples are not compared.
+func Compare(r, ref io.Reader, tol Tolerance) (Comparison, error) {
+	got, sampleRate, numChannels, err := readSamples(r)
+	if err != nil {
+		return Comparison{MaxDiffIndex: -1}, err
+	}
+	want, refSampleRate, refNumChannels, err := readSamples(ref)
+	if err != nil {
+		return Comparison{MaxDiffIndex: -1}, fmt.Errorf("reference: %w", err)
+	}
+	if sampleRate != refSampleRate || numChannels != refNumChannels {
+		return Comparison{Samples: len(got), ReferenceSamples: len(want), MaxDiffIndex: -1},
+			fmt.Errorf("%w: %d channels at %d Hz, reference has %d channels at %d Hz", ErrFormatMismatch, numChannels, sampleRate, refNumChannels, refSampleRate)
+	}
+	return CompareSamples(got, want, tol)
+}
+
+// CompareFiles compares the WAVE file at path with the reference WAVE file at refPath. See
+// Compare.
+func CompareFiles(path, refPath string, tol Tolerance) (Comparison, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Comparison{MaxDiffIndex: -1}, err
+	}
+	defer f.Close()
+	ref, err := os.Open(refPath)
+	if err != nil {
+		return Comparison{MaxDiffIndex: -1}, err
+	}
+	defer ref.Close()
+	return Compare(f, ref, tol)
+}
+
+// readSamples reads the samples of the WAVE file r as int16 samples.
+func readSamples(r io.Reader) (samples []int16, sampleRate, numChannels int, err error) {
+	dec, err := wav.NewDecoder(r)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	if dec.Format() != wav.FormatPCM && dec.Format() != wav.FormatIEEEFloat {
+		return nil, 0, 0, fmt.Errorf("%w: format %v is not supported", ErrInvalidFile, dec.Format())
+	}
+	data, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if dec.Format() == wav.FormatIEEEFloat {
+		samples = pcm.Float32ToInt16(nil, pcm.DecodeFloat32(nil, data), pcm.Scaling32767)
+	} else {
+		samples = pcm.DecodeInt16(nil, data)
+	}
+	return samples, dec.SampleRate(), dec.NumChannels(), nil
+}
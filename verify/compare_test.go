@@ -0,0 +1,136 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+func TestCompareSamples(t *testing.T) {
+	ref := make([]int16, 1000)
+	for i := range ref {
+		ref[i] = int16(i * 30)
+	}
+	// offset returns ref with n samples changed by diff, and cut to length samples.
+	offset := func(n, diff, length int) []int16 {
+		s := append([]int16(nil), ref...)
+		for i := range n {
+			s[i*7] += int16(diff)
+		}
+		return s[:length]
+	}
+	// percent returns n in percent of of, computed as CompareSamples does.
+	percent := func(n, of int) float64 {
+		return float64(n) / float64(of) * 100
+	}
+
+	tests := []struct {
+		name    string
+		got     []int16
+		tol     Tolerance
+		want    Comparison
+		wantErr []error
+	}{
+		{"identical", ref, Tolerance{}, Comparison{Samples: 1000, ReferenceSamples: 1000, Compared: 1000, MaxDiffIndex: -1}, nil},
+		{"within sample diff", offset(50, -5, 1000), ReferenceTolerance,
+			Comparison{Samples: 1000, ReferenceSamples: 1000, Compared: 1000, MaxDiff: 5, MaxDiffIndex: 0}, nil},
+		{"within mismatch", offset(10, 6, 1000), ReferenceTolerance,
+			Comparison{Samples: 1000, ReferenceSamples: 1000, Compared: 1000, Mismatched: 10, Mismatch: 1, MaxDiff: 6, MaxDiffIndex: 0}, nil},
+		{"too many mismatches", offset(11, 6, 1000), ReferenceTolerance,
+			Comparison{Samples: 1000, ReferenceSamples: 1000, Compared: 1000, Mismatched: 11, Mismatch: percent(11, 1000), MaxDiff: 6, MaxDiffIndex: 0}, []error{ErrSampleMismatch}},
+		{"exact match required", offset(1, 1, 1000), Tolerance{},
+			Comparison{Samples: 1000, ReferenceSamples: 1000, Compared: 1000, Mismatched: 1, Mismatch: percent(1, 1000), MaxDiff: 1, MaxDiffIndex: 0}, []error{ErrSampleMismatch}},
+		{"within length", offset(0, 0, 991), ReferenceTolerance,
+			Comparison{Samples: 991, ReferenceSamples: 1000, LengthDiff: percent(9, 991), Compared: 991, MaxDiffIndex: -1}, nil},
+		{"too short", offset(20, 100, 900), ReferenceTolerance,
+			Comparison{Samples: 900, ReferenceSamples: 1000, LengthDiff: percent(100, 900), Compared: 900, Mismatched: 20, Mismatch: percent(20, 900), MaxDiff: 100, MaxDiffIndex: 0},
+			[]error{ErrLengthMismatch, ErrSampleMismatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareSamples(tt.got, ref, tt.tol)
+			if got != tt.want {
+				t.Errorf("CompareSamples() = %+v, want %+v", got, tt.want)
+			}
+			if (err != nil) != (len(tt.wantErr) > 0) {
+				t.Errorf("CompareSamples() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("CompareSamples() error = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	speech := audiotest.Speech()[:audiotest.SpeechSampleRate]
+	// transform returns the output of speech at speed 1.5 as a WAVE file of format.
+	transform := func(t *testing.T, format sonic.AudioFormat) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		tr, err := sonic.NewTransformer(&out, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, sonic.WithSpeed(1.5), sonic.WithOutputFormat(format))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if _, err := tr.Write(pcm.EncodeInt16(nil, speech)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		tag, bits := formatPCM, 16
+		if format == sonic.AudioFormatIEEEFloat {
+			tag, bits = formatIEEEFloat, 32
+		}
+		return waveFile(tag, 1, audiotest.SpeechSampleRate, bits, out.Bytes())
+	}
+
+	ref := transform(t, sonic.AudioFormatPCM)
+	tests := []struct {
+		name    string
+		file    []byte
+		wantErr error
+	}{
+		{"same output", ref, nil},
+		{"float output", transform(t, sonic.AudioFormatIEEEFloat), nil},
+		{"other sample rate", waveFile(formatPCM, 1, 8000, 16, make([]byte, 1000)), ErrFormatMismatch},
+		{"other channels", waveFile(formatPCM, 2, audiotest.SpeechSampleRate, 16, make([]byte, 1000)), ErrFormatMismatch},
+		{"silence", waveFile(formatPCM, 1, audiotest.SpeechSampleRate, 16, make([]byte, len(ref)-44)), ErrSampleMismatch},
+		{"not a WAVE file", []byte("RIFF"), ErrInvalidFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Compare(bytes.NewReader(tt.file), bytes.NewReader(ref), ReferenceTolerance)
+			if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Compare() = %+v, %v, want %v", c, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	dir := t.TempDir()
+	path, refPath := filepath.Join(dir, "out.wav"), filepath.Join(dir, "ref.wav")
+	if err := os.WriteFile(path, waveFile(formatPCM, 1, 8000, 16, []byte{1, 0, 2, 0}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(refPath, waveFile(formatPCM, 1, 8000, 16, []byte{1, 0, 4, 0}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := CompareFiles(path, refPath, Tolerance{SampleDiff: 2})
+	if err != nil || c.Compared != 2 || c.MaxDiff != 2 || c.MaxDiffIndex != 1 {
+		t.Errorf("CompareFiles() = %+v, %v", c, err)
+	}
+	if _, err := CompareFiles(path, filepath.Join(dir, "missing.wav"), Tolerance{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("CompareFiles() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,261 @@
+// Package verify checks WAVE files produced with sonic.Transformer, as a quality gate at the end
+// of batch pipelines.
+//
+// File and Verify check that the header sizes match the data actually present, that the number
+// of sample frames matches the input length scaled by the speed and rate within a tolerance, and
+// that no more samples than allowed are clipped. 16-bit PCM and 32-bit float files are supported.
+//
+// Compare and CompareSamples compare output with reference output within a Tolerance, for golden
+// tests of services built on sonic-go.
+package verify
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// Errors
+var (
+	// ErrInvalidFile is returned when the file is not a WAVE file that can be checked.
+	ErrInvalidFile = errors.New("invalid WAVE file")
+
+	// ErrHeaderMismatch is reported when the sizes in the header do not match the data.
+	ErrHeaderMismatch = errors.New("header does not match data")
+
+	// ErrLengthMismatch is reported when the number of frames differs from the expected one.
+	ErrLengthMismatch = errors.New("unexpected number of frames")
+
+	// ErrClipping is reported when more samples than allowed are clipped.
+	ErrClipping = errors.New("clipping")
+)
+
+// WAVE format tags
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xFFFE
+)
+
+// DefaultTolerance is the relative frame count tolerance used when Expectation.Tolerance is 0.
+// libsonic does not produce exactly the scaled number of frames, see
+// sonic.Transformer.OutputSamplesForInput.
+const DefaultTolerance = 0.01
+
+// Expectation describes the file a pipeline is expected to produce.
+type Expectation struct {
+	// InputFrames is the number of frames of the input. If it is 0, the length is not checked.
+	InputFrames int64
+
+	// InputSampleRate is the sample rate of the input. If it is 0, the sample rate of the file is
+	// assumed, i.e. no resampling.
+	InputSampleRate int
+
+	// Speed and Rate are the speed and rate the input was processed with. The expected duration
+	// of the file is the input duration divided by Speed*Rate. 0 means 1.
+	Speed float64
+	Rate  float64
+
+	// Tolerance is the allowed relative difference between the number of frames of the file and
+	// the expected number. 0 means DefaultTolerance.
+	Tolerance float64
+
+	// ClipLevel is the magnitude from which a sample counts as clipped, relative to full scale.
+	// 0 means 1, i.e. samples at or beyond full scale. Non-finite float samples are always
+	// clipped.
+	ClipLevel float64
+
+	// MaxClippedRatio is the fraction of samples that may be clipped. The default 0 allows none.
+	MaxClippedRatio float64
+}
+
+// Report holds the properties of a checked file.
+type Report struct {
+	FormatTag     int
+	NumChannels   int
+	SampleRate    int
+	BitsPerSample int
+
+	Frames         int64 // Number of frames of the data present in the file
+	ExpectedFrames int64 // Expected number of frames, or -1 if the length was not checked
+	ClippedSamples int64 // Number of clipped samples
+}
+
+// File checks the WAVE file at path against exp. See Verify.
+func File(path string, exp Expectation) (Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Report{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Report{}, err
+	}
+	return Verify(f, info.Size(), exp)
+}
+
+// Verify checks the size bytes of WAVE data of r against exp.
+//
+// If the data cannot be parsed, the error wraps ErrInvalidFile. Otherwise, the report describes
+// the file, and the error joins one error for every failed check, wrapping ErrHeaderMismatch,
+// ErrLengthMismatch or ErrClipping. The error is nil if all checks pass.
+func Verify(r io.ReaderAt, size int64, exp Expectation) (Report, error) {
+	rep := Report{ExpectedFrames: -1}
+	var problems []error
+
+	var riff [12]byte
+	if _, err := r.ReadAt(riff[:], 0); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return rep, fmt.Errorf("%w: not a RIFF/WAVE file", ErrInvalidFile)
+	}
+	if riffSize := int64(binary.LittleEndian.Uint32(riff[4:8])); riffSize != size-8 {
+		problems = append(problems, fmt.Errorf("%w: RIFF size is %d, file has %d bytes after it", ErrHeaderMismatch, riffSize, size-8))
+	}
+
+	var dataOffset, dataBytes int64
+	blockAlign := 0
+	haveFmt := false
+	for offset := int64(len(riff)); ; {
+		var chunk [8]byte
+		if _, err := r.ReadAt(chunk[:], offset); err != nil {
+			return rep, fmt.Errorf("%w: no data chunk", ErrInvalidFile)
+		}
+		offset += int64(len(chunk))
+		id := string(chunk[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		if id == "fmt " {
+			var fmtChunk [26]byte
+			if chunkSize < 16 {
+				return rep, fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidFile, chunkSize)
+			}
+			n, _ := r.ReadAt(fmtChunk[:min(int(chunkSize), len(fmtChunk))], offset)
+			if n < 16 {
+				return rep, fmt.Errorf("%w: truncated fmt chunk", ErrInvalidFile)
+			}
+			rep.FormatTag = int(binary.LittleEndian.Uint16(fmtChunk[0:2]))
+			rep.NumChannels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			rep.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			blockAlign = int(binary.LittleEndian.Uint16(fmtChunk[12:14]))
+			rep.BitsPerSample = int(binary.LittleEndian.Uint16(fmtChunk[14:16]))
+			if rep.FormatTag == formatExtensible && n >= len(fmtChunk) {
+				rep.FormatTag = int(binary.LittleEndian.Uint16(fmtChunk[24:26])) // Sub format GUID
+			}
+			haveFmt = true
+		}
+		if id == "data" {
+			if !haveFmt {
+				return rep, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalidFile)
+			}
+			dataOffset, dataBytes = offset, chunkSize
+			if remaining := size - offset; dataBytes > remaining {
+				problems = append(problems, fmt.Errorf("%w: data chunk size is %d, file has %d bytes of data", ErrHeaderMismatch, dataBytes, remaining))
+				dataBytes = remaining
+			}
+			break
+		}
+		offset += chunkSize + chunkSize%2
+	}
+
+	sampleSize := rep.BitsPerSample / 8
+	switch {
+	case rep.FormatTag == formatPCM && rep.BitsPerSample == 16:
+	case rep.FormatTag == formatIEEEFloat && rep.BitsPerSample == 32:
+	default:
+		return rep, fmt.Errorf("%w: format %#x with %d bits per sample is not supported", ErrInvalidFile, rep.FormatTag, rep.BitsPerSample)
+	}
+	if rep.NumChannels < 1 || rep.SampleRate < 1 {
+		return rep, fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidFile, rep.NumChannels, rep.SampleRate)
+	}
+	frameSize := rep.NumChannels * sampleSize
+	if blockAlign != frameSize {
+		problems = append(problems, fmt.Errorf("%w: block align is %d, want %d", ErrHeaderMismatch, blockAlign, frameSize))
+	}
+	if dataBytes%int64(frameSize) != 0 {
+		problems = append(problems, fmt.Errorf("%w: data size %d is not a multiple of the frame size %d", ErrHeaderMismatch, dataBytes, frameSize))
+	}
+	rep.Frames = dataBytes / int64(frameSize)
+
+	if exp.InputFrames > 0 {
+		rep.ExpectedFrames = expectedFrames(exp, rep.SampleRate)
+		tolerance := exp.Tolerance
+		if tolerance == 0 {
+			tolerance = DefaultTolerance
+		}
+		if diff := math.Abs(float64(rep.Frames - rep.ExpectedFrames)); diff > tolerance*float64(rep.ExpectedFrames) {
+			problems = append(problems, fmt.Errorf("%w: %d frames, want %d ± %.1f%%", ErrLengthMismatch, rep.Frames, rep.ExpectedFrames, 100*tolerance))
+		}
+	}
+
+	clipped, err := countClipped(io.NewSectionReader(r, dataOffset, rep.Frames*int64(frameSize)), rep.FormatTag, exp.ClipLevel)
+	if err != nil {
+		return rep, err
+	}
+	rep.ClippedSamples = clipped
+	if numSamples := rep.Frames * int64(rep.NumChannels); float64(clipped) > exp.MaxClippedRatio*float64(numSamples) {
+		problems = append(problems, fmt.Errorf("%w: %d of %d samples", ErrClipping, clipped, numSamples))
+	}
+
+	return rep, errors.Join(problems...)
+}
+
+// expectedFrames returns the number of frames at sampleRate the input described by exp should
+// produce.
+func expectedFrames(exp Expectation, sampleRate int) int64 {
+	inputRate, speed, rate := float64(exp.InputSampleRate), exp.Speed, exp.Rate
+	if inputRate == 0 {
+		inputRate = float64(sampleRate)
+	}
+	if speed == 0 {
+		speed = 1
+	}
+	if rate == 0 {
+		rate = 1
+	}
+	seconds := float64(exp.InputFrames) / inputRate / (speed * rate)
+	return int64(math.Round(seconds * float64(sampleRate)))
+}
+
+// countClipped returns the number of samples of the data in r whose magnitude reaches clipLevel
+// relative to full scale.
+func countClipped(r io.Reader, formatTag int, clipLevel float64) (int64, error) {
+	if clipLevel == 0 {
+		clipLevel = 1
+	}
+	level := float32(clipLevel)
+	int16Level := float32(clipLevel * math.MaxInt16)
+
+	buf := make([]byte, 64*1024)
+	var i16 []int16
+	var f32 []float32
+	clipped := int64(0)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if formatTag == formatPCM {
+			i16 = pcm.DecodeInt16(i16, buf[:n])
+			for _, s := range i16 {
+				if v := float32(s); v >= int16Level || -v >= int16Level {
+					clipped++
+				}
+			}
+		} else {
+			f32 = pcm.DecodeFloat32(f32, buf[:n])
+			for _, s := range f32 {
+				if s >= level || -s >= level || s != s {
+					clipped++
+				}
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return clipped, nil
+		}
+		if err != nil {
+			return clipped, err
+		}
+	}
+}
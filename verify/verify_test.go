@@ -0,0 +1,112 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/audiotest"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// waveFile returns a WAVE file with the fmt chunk of formatTag and data.
+func waveFile(formatTag, numChannels, sampleRate, bitsPerSample int, data []byte) []byte {
+	blockAlign := numChannels * bitsPerSample / 8
+	b := []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00")
+	b = binary.LittleEndian.AppendUint16(b, uint16(formatTag))
+	b = binary.LittleEndian.AppendUint16(b, uint16(numChannels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bitsPerSample))
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, data...)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	speech := audiotest.Speech()
+	var out bytes.Buffer
+	tr, err := sonic.NewTransformer(&out, audiotest.SpeechSampleRate, sonic.AudioFormatPCM, sonic.WithSpeed(2))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(pcm.EncodeInt16(nil, speech)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	produced := waveFile(formatPCM, 1, audiotest.SpeechSampleRate, 16, out.Bytes())
+	exp := Expectation{InputFrames: int64(len(speech)), Speed: 2}
+
+	truncated := produced[:len(produced)-100]
+	riffMismatch := append(bytes.Clone(produced), 0, 0)
+	clipped := bytes.Clone(produced)
+	binary.LittleEndian.PutUint16(clipped[44:], 0x8000)
+	floatFile := waveFile(formatIEEEFloat, 2, 8000, 32, pcm.EncodeFloat32(nil, []float32{0, 0.5, -0.5, 1.5, 0.25, 0.25}))
+
+	tests := []struct {
+		name    string
+		file    []byte
+		exp     Expectation
+		wantErr []error
+	}{
+		{"produced", produced, exp, nil},
+		{"no length check", produced, Expectation{}, nil},
+		{"wrong speed", produced, Expectation{InputFrames: int64(len(speech)), Speed: 1.5}, []error{ErrLengthMismatch}},
+		{"resampled", produced, Expectation{InputFrames: 2 * int64(len(speech)), InputSampleRate: 2 * audiotest.SpeechSampleRate, Speed: 2}, nil},
+		{"rate", produced, Expectation{InputFrames: int64(len(speech)), Speed: 0.8, Rate: 2.5}, nil},
+		{"truncated", truncated, exp, []error{ErrHeaderMismatch}},
+		{"RIFF size", riffMismatch, exp, []error{ErrHeaderMismatch}},
+		{"clipped", clipped, exp, []error{ErrClipping}},
+		{"clipped allowed", clipped, Expectation{MaxClippedRatio: 0.001}, nil},
+		{"float clipped", floatFile, Expectation{}, []error{ErrClipping}},
+		{"float clip level", floatFile, Expectation{ClipLevel: 2}, nil},
+		{"float length", floatFile, Expectation{InputFrames: 6}, []error{ErrLengthMismatch, ErrClipping}},
+		{"not WAVE", []byte("RIFF\x00\x00\x00\x00AVI "), exp, []error{ErrInvalidFile}},
+		{"unsupported format", waveFile(formatPCM, 1, 8000, 24, make([]byte, 6)), exp, []error{ErrInvalidFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(bytes.NewReader(tt.file), int64(len(tt.file)), tt.exp)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("Verify() error = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+
+	rep, err := Verify(bytes.NewReader(clipped), int64(len(clipped)), exp)
+	if !errors.Is(err, ErrClipping) || rep.ClippedSamples != 1 || rep.Frames != int64(out.Len()/2) || rep.SampleRate != audiotest.SpeechSampleRate {
+		t.Errorf("Verify() = %+v, %v", rep, err)
+	}
+	if want := int64(len(speech)+1) / 2; rep.ExpectedFrames != want {
+		t.Errorf("ExpectedFrames = %d, want %d", rep.ExpectedFrames, want)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	if err := os.WriteFile(path, waveFile(formatPCM, 1, 8000, 16, make([]byte, 1600)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := File(path, Expectation{InputFrames: 1600, Speed: 2})
+	if err != nil || rep.Frames != 800 || rep.ExpectedFrames != 800 {
+		t.Errorf("File() = %+v, %v", rep, err)
+	}
+	if _, err := File(filepath.Join(t.TempDir(), "missing.wav"), Expectation{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("File() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
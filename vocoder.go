@@ -0,0 +1,344 @@
+package sonic
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/nakat-t/sonic-go/internal/fft"
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// EngineMusic is a pure-Go phase vocoder engine for music-heavy content.
+//
+// The PICOLA-style algorithm of EngineSonic repeats and drops whole pitch periods, which works
+// very well for speech but produces audible stutter on polyphonic music. The phase vocoder
+// stretches every frequency component separately instead, at the cost of slightly softened
+// transients and a latency of about one analysis frame (roughly 40 ms).
+// The quality flag is ignored.
+var EngineMusic Engine = vocoderEngine{}
+
+// vocoderEngine creates phase vocoder streams.
+type vocoderEngine struct{}
+
+// NewStream implements Engine.
+func (vocoderEngine) NewStream(sampleRate, numChannels int) (Stream, error) {
+	if sampleRate <= 0 || numChannels <= 0 {
+		return nil, errors.New("invalid sample rate or number of channels")
+	}
+	return newVocoderStream(sampleRate, numChannels), nil
+}
+
+// String returns the name of the engine.
+func (vocoderEngine) String() string {
+	return "music"
+}
+
+const (
+	vocoderMinFrameSize = 256
+	vocoderOverlap      = 4 // Number of frames overlapping each output sample
+)
+
+// vocoderStream is a Stream that time-stretches with a phase vocoder and changes the pitch by
+// resampling the stretched signal.
+//
+// Analysis frames are taken from the input every hop*speed/pitch frames and overlap-added to
+// the output every hop frames, which stretches the signal by pitch/speed. Resampling by
+// pitch*rate then yields the requested pitch and a total time scale of 1/(speed*rate), the same
+// as libsonic.
+type vocoderStream struct {
+	numChannels int
+	frameSize   int // Analysis frame size, a power of 2
+	hop         int // Synthesis hop size
+
+	speed   float32
+	pitch   float32
+	rate    float32
+	volume  float32
+	quality int
+
+	window     []float64
+	fft        *fft.FFT
+	frame      []complex128
+	in         [][]float32 // Pending input of each channel
+	pos        float64     // Position of the next analysis frame in in
+	first      bool        // Whether the next frame is the first since the start or the last flush
+	prevPhase  [][]float64 // Analysis phases of the previous frame of each channel
+	synthPhase [][]float64 // Synthesis phases of the previous frame of each channel
+	ola        [][]float64 // Overlap-add buffer of each channel
+	olaWeight  []float64   // Sum of the squared windows overlap-added at each position of ola
+	stretched  []float32   // Interleaved stretched frames not yet resampled
+	resPos     float64     // Resampling position in stretched
+	out        []float32   // Interleaved output frames not yet read
+
+	expected float64 // Output frames expected since the last flush
+	produced int     // Output frames produced since the last flush
+}
+
+// newVocoderStream creates a vocoderStream with an analysis frame of about 40 ms.
+func newVocoderStream(sampleRate, numChannels int) *vocoderStream {
+	frameSize := vocoderMinFrameSize
+	for frameSize*2 <= sampleRate/20 {
+		frameSize *= 2
+	}
+	s := &vocoderStream{
+		numChannels: numChannels,
+		frameSize:   frameSize,
+		hop:         frameSize / vocoderOverlap,
+		speed:       1,
+		pitch:       1,
+		rate:        1,
+		volume:      1,
+		window:      make([]float64, frameSize),
+		fft:         fft.New(frameSize),
+		frame:       make([]complex128, frameSize),
+		in:          make([][]float32, numChannels),
+		first:       true,
+		prevPhase:   make([][]float64, numChannels),
+		synthPhase:  make([][]float64, numChannels),
+		ola:         make([][]float64, numChannels),
+		olaWeight:   make([]float64, frameSize),
+	}
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize)) // Periodic Hann window
+	}
+	for ch := range numChannels {
+		s.prevPhase[ch] = make([]float64, frameSize/2+1)
+		s.synthPhase[ch] = make([]float64, frameSize/2+1)
+		s.ola[ch] = make([]float64, frameSize)
+	}
+	return s
+}
+
+// WriteFloatToStream implements Stream.
+func (s *vocoderStream) WriteFloatToStream(samples []float32, numFrames int) int {
+	for i := range numFrames {
+		for ch := range s.numChannels {
+			s.in[ch] = append(s.in[ch], samples[i*s.numChannels+ch])
+		}
+	}
+	s.expected += float64(numFrames) / float64(s.speed*s.rate)
+	s.process(false)
+	return 1
+}
+
+// WriteShortToStream implements Stream.
+func (s *vocoderStream) WriteShortToStream(samples []int16, numFrames int) int {
+	for i := range numFrames {
+		for ch := range s.numChannels {
+			s.in[ch] = append(s.in[ch], float32(samples[i*s.numChannels+ch])/32767)
+		}
+	}
+	s.expected += float64(numFrames) / float64(s.speed*s.rate)
+	s.process(false)
+	return 1
+}
+
+// ReadFloatFromStream implements Stream.
+func (s *vocoderStream) ReadFloatFromStream(samples []float32, maxFrames int) int {
+	n := min(len(s.out)/s.numChannels, maxFrames)
+	copy(samples, s.out[:n*s.numChannels])
+	s.out = s.out[:copy(s.out, s.out[n*s.numChannels:])]
+	return n
+}
+
+// ReadShortFromStream implements Stream.
+func (s *vocoderStream) ReadShortFromStream(samples []int16, maxFrames int) int {
+	n := min(len(s.out)/s.numChannels, maxFrames)
+	pcm.Float32ToInt16(samples[:n*s.numChannels], s.out[:n*s.numChannels], int16Scaling)
+	s.out = s.out[:copy(s.out, s.out[n*s.numChannels:])]
+	return n
+}
+
+// FlushStream implements Stream. It processes all pending input, so that the output has the
+// expected length, and resets the stream.
+func (s *vocoderStream) FlushStream() int {
+	s.process(true)
+
+	// Trim or pad the output to the expected length, which the vocoder only meets approximately.
+	want := int(math.Round(s.expected))
+	if excess := s.produced - want; excess > 0 {
+		s.out = s.out[:len(s.out)-min(excess*s.numChannels, len(s.out))]
+	}
+	for range want - s.produced {
+		for range s.numChannels {
+			s.out = append(s.out, 0)
+		}
+	}
+
+	for ch := range s.numChannels {
+		s.in[ch] = s.in[ch][:0]
+		clear(s.prevPhase[ch])
+		clear(s.synthPhase[ch])
+		clear(s.ola[ch])
+	}
+	clear(s.olaWeight)
+	s.pos = 0
+	s.first = true
+	s.stretched = s.stretched[:0]
+	s.resPos = 0
+	s.expected = 0
+	s.produced = 0
+	return 1
+}
+
+// FlushAndReadShort implements Stream.
+func (s *vocoderStream) FlushAndReadShort(samples []int16, maxFrames int) int {
+	s.FlushStream()
+	return s.ReadShortFromStream(samples, maxFrames)
+}
+
+// FlushAndReadFloat implements Stream.
+func (s *vocoderStream) FlushAndReadFloat(samples []float32, maxFrames int) int {
+	s.FlushStream()
+	return s.ReadFloatFromStream(samples, maxFrames)
+}
+
+// PendingInputFrames implements Stream.
+func (s *vocoderStream) PendingInputFrames() int {
+	pending := float64(len(s.in[0])) - s.pos
+	if pending < 0 {
+		return 0
+	}
+	return int(pending + 0.5)
+}
+
+func (s *vocoderStream) GetSpeed() float32        { return s.speed }
+func (s *vocoderStream) SetSpeed(speed float32)   { s.speed = speed }
+func (s *vocoderStream) GetPitch() float32        { return s.pitch }
+func (s *vocoderStream) SetPitch(pitch float32)   { s.pitch = pitch }
+func (s *vocoderStream) GetRate() float32         { return s.rate }
+func (s *vocoderStream) SetRate(rate float32)     { s.rate = rate }
+func (s *vocoderStream) GetVolume() float32       { return s.volume }
+func (s *vocoderStream) SetVolume(volume float32) { s.volume = volume }
+func (s *vocoderStream) SetQuality(quality int)   { s.quality = quality }
+func (s *vocoderStream) DestroyStream()           {}
+
+// process runs the vocoder on all complete analysis frames of the pending input and resamples
+// the result into out. If final is set, the input is padded with silence so that every input
+// frame is processed, and the overlap-add buffer is emptied.
+func (s *vocoderStream) process(final bool) {
+	end := len(s.in[0])
+	if final {
+		for ch := range s.numChannels {
+			for range s.frameSize {
+				s.in[ch] = append(s.in[ch], 0)
+			}
+		}
+	}
+
+	ha := float64(s.hop) * float64(s.speed) / float64(s.pitch) // Analysis hop size
+	for {
+		p := int(math.Round(s.pos))
+		if p+s.frameSize > len(s.in[0]) || (final && p >= end) {
+			break
+		}
+		s.processFrame(p, ha)
+		s.emit(s.hop)
+		s.pos += ha
+	}
+	if final {
+		s.emit(s.frameSize - s.hop)
+	}
+
+	// Drop the input before the next analysis frame.
+	if d := min(int(s.pos), len(s.in[0])); d > 0 {
+		for ch := range s.numChannels {
+			s.in[ch] = s.in[ch][:copy(s.in[ch], s.in[ch][d:])]
+		}
+		s.pos -= float64(d)
+	}
+
+	s.resample(final)
+}
+
+// processFrame overlap-adds the analysis frame at position p of the input of every channel.
+// ha is the distance to the previous analysis frame.
+func (s *vocoderStream) processFrame(p int, ha float64) {
+	n := s.frameSize
+	for ch := range s.numChannels {
+		in := s.in[ch][p : p+n]
+		for i, x := range in {
+			s.frame[i] = complex(s.window[i]*float64(x), 0)
+		}
+		s.fft.Transform(s.frame, false)
+
+		prev, synth := s.prevPhase[ch], s.synthPhase[ch]
+		for k := range n/2 + 1 {
+			mag, phase := cmplx.Abs(s.frame[k]), cmplx.Phase(s.frame[k])
+			if s.first {
+				synth[k] = phase
+			} else {
+				// Estimate the true frequency of bin k from the phase advance since the previous
+				// frame, and advance the synthesis phase by it over one synthesis hop.
+				omega := 2 * math.Pi * float64(k) / float64(n)
+				delta := phase - prev[k] - omega*ha
+				delta -= 2 * math.Pi * math.Round(delta/(2*math.Pi))
+				synth[k] += (omega + delta/ha) * float64(s.hop)
+			}
+			prev[k] = phase
+			s.frame[k] = cmplx.Rect(mag, synth[k])
+			if 0 < k && k < n/2 {
+				s.frame[n-k] = cmplx.Conj(s.frame[k])
+			}
+		}
+		s.fft.Transform(s.frame, true)
+
+		ola := s.ola[ch]
+		for i := range ola {
+			ola[i] += real(s.frame[i]) * s.window[i]
+		}
+	}
+	for i, w := range s.window {
+		s.olaWeight[i] += w * w
+	}
+	s.first = false
+}
+
+// emit moves the first n frames of the overlap-add buffers to stretched, normalized by the
+// overlap-added windows.
+func (s *vocoderStream) emit(n int) {
+	for i := range n {
+		w := s.olaWeight[i]
+		for ch := range s.numChannels {
+			v := 0.0
+			if w > 1e-3 {
+				v = s.ola[ch][i] / w
+			}
+			s.stretched = append(s.stretched, float32(v))
+		}
+	}
+	for ch := range s.numChannels {
+		ola := s.ola[ch]
+		copy(ola, ola[n:])
+		clear(ola[len(ola)-n:])
+	}
+	copy(s.olaWeight, s.olaWeight[n:])
+	clear(s.olaWeight[len(s.olaWeight)-n:])
+}
+
+// resample moves stretched to out, resampled by pitch*rate with linear interpolation.
+// If final is set, stretched is emptied, assuming silence after it.
+func (s *vocoderStream) resample(final bool) {
+	nc := s.numChannels
+	if final {
+		for range nc {
+			s.stretched = append(s.stretched, 0)
+		}
+	}
+	step := float64(s.pitch) * float64(s.rate)
+	numFrames := len(s.stretched) / nc
+	for s.resPos+1 < float64(numFrames) {
+		i := int(s.resPos)
+		frac := float32(s.resPos - float64(i))
+		for ch := range nc {
+			a, b := s.stretched[i*nc+ch], s.stretched[(i+1)*nc+ch]
+			s.out = append(s.out, (a+(b-a)*frac)*s.volume)
+		}
+		s.produced++
+		s.resPos += step
+	}
+	d := min(int(s.resPos), numFrames)
+	s.stretched = s.stretched[:copy(s.stretched, s.stretched[d*nc:])]
+	s.resPos -= float64(d)
+}
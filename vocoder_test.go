@@ -0,0 +1,114 @@
+package sonic
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/nakat-t/sonic-go/pcm"
+)
+
+// sine returns numFrames frames of a sine at freq, duplicated to numChannels channels.
+func sine(freq float64, sampleRate, numChannels, numFrames int) []float32 {
+	samples := make([]float32, numFrames*numChannels)
+	for i := range numFrames {
+		v := float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		for ch := range numChannels {
+			samples[i*numChannels+ch] = v
+		}
+	}
+	return samples
+}
+
+// dominantFrequency estimates the frequency of a mono sine from its zero crossings.
+func dominantFrequency(samples []float32, sampleRate int) float64 {
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / 2 / (float64(len(samples)) / float64(sampleRate))
+}
+
+func TestEngineMusic(t *testing.T) {
+	const sampleRate = 44100
+	const freq = 440
+
+	tests := []struct {
+		name        string
+		numChannels int
+		opts        []Option
+		wantScale   float64 // Output frames per input frame
+		wantFreq    float64
+	}{
+		{"identity", 1, nil, 1, freq},
+		{"speed up", 1, []Option{WithSpeed(2)}, 0.5, freq},
+		{"slow down", 2, []Option{WithSpeed(0.75)}, 1 / 0.75, freq},
+		{"pitch", 1, []Option{WithPitch(1.5)}, 1, freq * 1.5},
+		{"rate", 1, []Option{WithRate(1.25)}, 0.8, freq * 1.25},
+		{"speed and pitch", 2, []Option{WithSpeed(1.5), WithPitch(0.8)}, 1 / 1.5, freq * 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := sine(freq, sampleRate, tt.numChannels, sampleRate)
+			var out bytes.Buffer
+			opts := append([]Option{WithEngine(EngineMusic), WithChannels(tt.numChannels)}, tt.opts...)
+			tr, err := NewTransformer(&out, sampleRate, AudioFormatIEEEFloat, opts...)
+			if err != nil {
+				t.Fatalf("NewTransformer() error = %v", err)
+			}
+			defer tr.Close()
+			// Write in uneven chunks to exercise the streaming.
+			for b := pcm.EncodeFloat32(nil, input); len(b) > 0; {
+				n := min(len(b), 4*tt.numChannels*1000)
+				if _, err := tr.Write(b[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				b = b[n:]
+			}
+			if err := tr.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			output := pcm.DecodeFloat32(nil, out.Bytes())
+			numFrames := len(output) / tt.numChannels
+			if want := int(math.Round(sampleRate * tt.wantScale)); numFrames != want {
+				t.Errorf("output frames = %d, want %d", numFrames, want)
+			}
+			mono := make([]float32, numFrames)
+			for i := range mono {
+				mono[i] = output[i*tt.numChannels]
+			}
+			// Skip the fade-in and fade-out of the first and last frames.
+			steady := mono[numFrames/10 : numFrames*9/10]
+			if got := dominantFrequency(steady, sampleRate); math.Abs(got-tt.wantFreq) > tt.wantFreq/50 {
+				t.Errorf("frequency = %.1f Hz, want %.1f Hz", got, tt.wantFreq)
+			}
+			var peak float32
+			for _, v := range steady {
+				peak = max(peak, float32(math.Abs(float64(v))))
+			}
+			if peak < 0.4 || peak > 0.6 {
+				t.Errorf("peak = %v, want about 0.5", peak)
+			}
+		})
+	}
+}
+
+func TestEngineMusic_Identity(t *testing.T) {
+	const sampleRate = 16000
+	input := sine(300, sampleRate, 1, sampleRate/2)
+	s := newVocoderStream(sampleRate, 1)
+	s.WriteFloatToStream(input, len(input))
+	s.FlushStream()
+	output := make([]float32, len(input)+1)
+	if n := s.ReadFloatFromStream(output, len(output)); n != len(input) {
+		t.Fatalf("ReadFloatFromStream() = %d, want %d", n, len(input))
+	}
+	for i := s.frameSize; i < len(input)-s.frameSize; i++ {
+		if d := math.Abs(float64(output[i] - input[i])); d > 1e-4 {
+			t.Fatalf("output[%d] = %v, want %v", i, output[i], input[i])
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// imaStepTable holds the quantizer step sizes of IMA ADPCM, indexed by the step index.
+var imaStepTable = [89]int32{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21,
+	23, 25, 28, 31, 34, 37, 41, 45, 50, 55, 60, 66,
+	73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209,
+	230, 253, 279, 307, 337, 371, 408, 449, 494, 544, 598, 658,
+	724, 796, 876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+	2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358, 5894, 6484,
+	7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899, 15289, 16818, 18500, 20350,
+	22385, 24623, 27086, 29794, 32767,
+}
+
+// imaIndexTable holds the adjustments of the step index, indexed by the magnitude of a code.
+var imaIndexTable = [8]int32{-1, -1, -1, -1, 2, 4, 6, 8}
+
+// imaBlockFrames returns the number of frames in a block of blockAlign bytes of IMA ADPCM with
+// numChannels channels: the sample of the block header, followed by 8 samples for every 4 bytes
+// of each channel. Blocks that do not hold whole 4-byte groups are invalid, and give 0.
+func imaBlockFrames(blockAlign, numChannels int) int {
+	header := 4 * numChannels
+	if blockAlign < header || (blockAlign-header)%header != 0 {
+		return 0
+	}
+	return 1 + (blockAlign-header)/header*8
+}
+
+// imaDecoder decodes the blocks of IMA ADPCM read from r to interleaved 16-bit little-endian
+// samples. The last block may be shorter than blockAlign, as written by encoders that stop at the
+// end of the input.
+type imaDecoder struct {
+	r           io.Reader
+	numChannels int
+	blockAlign  int
+	frames      int64 // Frames left to decode, or -1 to decode up to the end of r
+	block       []byte
+	buf         []byte // Samples of a decoded block
+	out         []byte // Decoded samples not read yet
+	err         error
+}
+
+// newIMADecoder returns a decoder of the IMA ADPCM blocks read from r. If frames is not negative,
+// the output is limited to frames frames, the length given by the fact chunk, which drops the
+// padding of the last block.
+func newIMADecoder(r io.Reader, numChannels, blockAlign int, frames int64) *imaDecoder {
+	return &imaDecoder{
+		r:           r,
+		numChannels: numChannels,
+		blockAlign:  blockAlign,
+		frames:      frames,
+		block:       make([]byte, blockAlign),
+		buf:         make([]byte, 2*numChannels*imaBlockFrames(blockAlign, numChannels)),
+	}
+}
+
+// Read implements io.Reader.
+func (d *imaDecoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.decodeBlock()
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// decodeBlock reads and decodes the next block into out. It sets err at the end of the input.
+func (d *imaDecoder) decodeBlock() {
+	if d.frames == 0 {
+		// Skip the padding of the last block, so that the chunks after the data can be read.
+		_, d.err = io.Copy(io.Discard, d.r)
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		return
+	}
+	n, err := io.ReadFull(d.r, d.block)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF // A short last block is decoded as far as it goes.
+	}
+	d.err = err
+	header := 4 * d.numChannels
+	if n < header {
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		return
+	}
+	block := d.block[:n]
+	frames := 1 + (n-header)/header*8
+	if d.frames >= 0 && int64(frames) > d.frames {
+		frames = int(d.frames)
+	}
+
+	out := d.buf[:2*frames*d.numChannels]
+	for ch := range d.numChannels {
+		predictor := int32(int16(binary.LittleEndian.Uint16(block[4*ch:])))
+		index := min(int32(block[4*ch+2]), int32(len(imaStepTable)-1))
+		binary.LittleEndian.PutUint16(out[2*ch:], uint16(predictor))
+		// Each group of 4 bytes per channel holds 8 codes, the low nibble first.
+		for i := 1; i < frames; i++ {
+			group, pos := (i-1)/8, (i-1)%8
+			code := int32(block[header+(group*d.numChannels+ch)*4+pos/2]>>(4*(pos%2))) & 0x0F
+			step := imaStepTable[index]
+			diff := step >> 3
+			if code&1 != 0 {
+				diff += step >> 2
+			}
+			if code&2 != 0 {
+				diff += step >> 1
+			}
+			if code&4 != 0 {
+				diff += step
+			}
+			if code&8 != 0 {
+				diff = -diff
+			}
+			predictor = min(max(predictor+diff, -32768), 32767)
+			index = min(max(index+imaIndexTable[code&7], 0), int32(len(imaStepTable)-1))
+			binary.LittleEndian.PutUint16(out[2*(i*d.numChannels+ch):], uint16(predictor))
+		}
+	}
+	if d.frames > 0 {
+		d.frames -= int64(frames)
+	}
+	d.out = out
+}
+
+// imaDecodedBytes returns the size of the samples decoded from dataBytes bytes of IMA ADPCM,
+// limited to frames frames if it is not negative.
+func imaDecodedBytes(dataBytes int64, numChannels, blockAlign int, frames int64) int64 {
+	header := int64(4 * numChannels)
+	decoded := dataBytes / int64(blockAlign) * int64(imaBlockFrames(blockAlign, numChannels))
+	if rest := dataBytes % int64(blockAlign); rest >= header {
+		decoded += 1 + (rest-header)/header*8
+	}
+	if frames >= 0 {
+		decoded = min(decoded, frames)
+	}
+	return decoded * int64(numChannels) * 2
+}
@@ -0,0 +1,655 @@
+// Package wav decodes and encodes WAVE files, so that audio can be read from a file, transformed
+// with sonic.Transformer and written to another file without handling headers by hand:
+//
+//	dec, err := wav.NewDecoder(in)
+//	...
+//	enc, err := wav.NewEncoder(out, dec.SampleRate(), dec.NumChannels(), dec.Format())
+//	...
+//	tr, err := sonic.NewTransformer(enc, dec.SampleRate(), sonic.AudioFormat(dec.Format()),
+//		sonic.WithChannels(dec.NumChannels()), sonic.WithSpeed(2))
+//	...
+//	_, err = io.Copy(tr, dec)
+//	...
+//	err = tr.Close()  // Flushes the transformer
+//	...
+//	err = enc.Close() // Completes the header
+//
+// 16-bit PCM, 32-bit IEEE float, unsigned 8-bit PCM, packed 24-bit PCM, 32-bit PCM and 64-bit
+// IEEE float are supported. The values of Format are those of the matching sonic.AudioFormat.
+// G.711 μ-law and A-law files are decoded to 16-bit PCM with the g711 package, and 4-bit IMA
+// ADPCM files to 16-bit PCM as well; files of other codecs, such as Microsoft ADPCM, fail with an
+// *UnsupportedCodecError.
+// Of the other chunks, the metadata chunks LIST, bext and cue are kept as Metadata when decoding
+// and written after the data when encoding; the rest are skipped.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/nakat-t/sonic-go/g711"
+)
+
+// WAVE format tags of the fmt chunk
+const (
+	FormatTagPCM        = 0x0001
+	FormatTagADPCM      = 0x0002 // Microsoft ADPCM
+	FormatTagIEEEFloat  = 0x0003
+	FormatTagALaw       = 0x0006
+	FormatTagMuLaw      = 0x0007
+	FormatTagIMAADPCM   = 0x0011
+	FormatTagExtensible = 0xFFFE
+)
+
+// Format is the sample format of a WAVE file.
+type Format int
+
+const (
+	FormatPCM         Format = 1  // 16-bit signed integer PCM
+	FormatIEEEFloat   Format = 3  // 32-bit IEEE float
+	FormatU8          Format = 8  // 8-bit unsigned integer PCM
+	FormatPCM24       Format = 24 // 24-bit signed integer PCM, packed in 3 bytes
+	FormatPCM32       Format = 32 // 32-bit signed integer PCM
+	FormatIEEEFloat64 Format = 64 // 64-bit IEEE float
+)
+
+// String implements fmt.Stringer
+func (f Format) String() string {
+	switch f {
+	case FormatPCM:
+		return "PCM"
+	case FormatIEEEFloat:
+		return "IEEEFloat"
+	case FormatU8:
+		return "U8"
+	case FormatPCM24:
+		return "PCM24"
+	case FormatPCM32:
+		return "PCM32"
+	case FormatIEEEFloat64:
+		return "IEEEFloat64"
+	default:
+		return "Unknown"
+	}
+}
+
+// SampleSize returns the size of one sample in bytes, or 0 for an unknown format.
+func (f Format) SampleSize() int {
+	switch f {
+	case FormatPCM:
+		return 2
+	case FormatIEEEFloat:
+		return 4
+	case FormatU8:
+		return 1
+	case FormatPCM24:
+		return 3
+	case FormatPCM32:
+		return 4
+	case FormatIEEEFloat64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// formatTag returns the WAVE format tag of f.
+func (f Format) formatTag() int {
+	if f == FormatIEEEFloat || f == FormatIEEEFloat64 {
+		return FormatTagIEEEFloat
+	}
+	return FormatTagPCM
+}
+
+// Errors
+var (
+	// ErrInvalidFile is returned when the input is not a well-formed WAVE file.
+	ErrInvalidFile = errors.New("invalid WAVE file")
+
+	// ErrUnsupported is returned for invalid encoder parameters. An *UnsupportedCodecError of the
+	// decoder wraps it as well.
+	ErrUnsupported = errors.New("unsupported WAVE format")
+
+	// ErrClosed is returned when writing to a closed Encoder.
+	ErrClosed = errors.New("encoder is closed")
+)
+
+// UnsupportedCodecError is returned by NewDecoder for a WAVE file encoded with a codec it cannot
+// decode. It wraps ErrUnsupported.
+type UnsupportedCodecError struct {
+	FormatTag     int // Format tag of the fmt chunk, or of the sub-format of an extensible one
+	BitsPerSample int
+}
+
+func (e *UnsupportedCodecError) Error() string {
+	names := map[int]string{
+		FormatTagPCM:        "PCM",
+		FormatTagADPCM:      "MS ADPCM",
+		FormatTagIEEEFloat:  "IEEE float",
+		FormatTagALaw:       "A-law",
+		FormatTagMuLaw:      "mu-law",
+		FormatTagIMAADPCM:   "IMA ADPCM",
+		FormatTagExtensible: "extensible",
+	}
+	name, ok := names[e.FormatTag]
+	if !ok {
+		name = "unknown codec"
+	}
+	return fmt.Sprintf("%v: %s, format tag 0x%04X, %d bits per sample", ErrUnsupported, name, e.FormatTag, e.BitsPerSample)
+}
+
+// Unwrap returns ErrUnsupported.
+func (e *UnsupportedCodecError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// Metadata holds the metadata chunks of a WAVE file, keyed by chunk ID, e.g. "bext" for the
+// Broadcast Wave Format extension or "cue " for cue points. LIST chunks are keyed by "LIST/" and
+// their list type, e.g. "LIST/INFO". The values are the chunk contents, excluding the list type
+// of LIST chunks.
+type Metadata map[string][]byte
+
+// IsMetadataChunk reports whether the chunk with the given ID is kept as Metadata.
+func IsMetadataChunk(id string) bool {
+	return id == "LIST" || id == "bext" || id == "cue "
+}
+
+// AddChunk adds the contents data of the metadata chunk id to m. The list type of a LIST chunk is
+// moved from data to the key; LIST chunks too short to have one are dropped.
+func (m Metadata) AddChunk(id string, data []byte) {
+	if id == "LIST" {
+		if len(data) < 4 {
+			return
+		}
+		id, data = "LIST/"+string(data[:4]), data[4:]
+	}
+	m[id] = data
+}
+
+// ScaleSampleOffsets returns a copy of m with the sample positions of cue points ("cue " chunk)
+// and the sample lengths of labeled text regions ("ltxt" in "LIST/adtl") multiplied by factor,
+// so that markers stay in place when the audio is time-stretched. For a speed change by speed,
+// factor is 1/speed. Malformed chunks are copied unchanged.
+func (m Metadata) ScaleSampleOffsets(factor float64) Metadata {
+	scale := func(b []byte) {
+		v := math.Round(float64(binary.LittleEndian.Uint32(b)) * factor)
+		if !(v > 0) {
+			v = 0
+		} else if v > math.MaxUint32 {
+			v = math.MaxUint32
+		}
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	}
+
+	scaled := Metadata{}
+	for id, data := range m {
+		data = slices.Clone(data)
+		switch id {
+		case "cue ":
+			// dwCuePoints, followed by 24-byte cue points:
+			// dwName, dwPosition, fccChunk, dwChunkStart, dwBlockStart, dwSampleOffset
+			if len(data) < 4 {
+				break
+			}
+			n := int(binary.LittleEndian.Uint32(data))
+			for i := 0; i < n && 4+(i+1)*24 <= len(data); i++ {
+				point := data[4+i*24:]
+				scale(point[4:8])
+				scale(point[20:24])
+			}
+		case "LIST/adtl":
+			// Subchunks; ltxt starts with dwName, dwSampleLength.
+			for b := data; len(b) >= 8; {
+				size := int(binary.LittleEndian.Uint32(b[4:8]))
+				if size > len(b)-8 {
+					break
+				}
+				if string(b[:4]) == "ltxt" && size >= 8 {
+					scale(b[12:16])
+				}
+				next := 8 + size + size%2
+				if next > len(b) {
+					next = len(b)
+				}
+				b = b[next:]
+			}
+		}
+		scaled[id] = data
+	}
+	return scaled
+}
+
+// AppendChunks appends the chunks of m to b, sorted by key and padded to an even size, and
+// returns the extended slice.
+func (m Metadata) AppendChunks(b []byte) []byte {
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		id, data := key, m[key]
+		if listType, ok := strings.CutPrefix(key, "LIST/"); ok {
+			id, data = "LIST", append([]byte(listType), data...)
+		}
+		b = append(b, id...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+		if len(data)%2 != 0 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// headerSize is the size of the header written by Encoder: the RIFF header, a 16-byte fmt chunk
+// and the header of the data chunk.
+const headerSize = 44
+
+// unknownSize is the chunk size written by Encoder when the output cannot be seeked, as streaming
+// encoders do.
+const unknownSize = 0xFFFFFFFF
+
+// Decoder reads the sample data of a WAVE file.
+type Decoder struct {
+	r           io.Reader
+	sampleRate  int
+	numChannels int
+	format      Format
+	law         g711.Law  // Companding law of G.711 files, or 0
+	imaBlock    int       // Block size of IMA ADPCM files, or 0
+	factFrames  int64     // Number of frames given by the fact chunk, or -1 if there is none
+	data        io.Reader // Reader of the samples returned by Read
+	dataBytes   int64     // Size of the data chunk in the header, or -1 if unknown
+	remaining   int64     // Bytes of the data chunk not read yet, or -1 if unknown
+	metadata    Metadata
+	trailerRead bool // Whether the chunks after the data chunk have been read
+}
+
+// NewDecoder reads the header of the WAVE file r up to the start of the data chunk. Chunks before
+// the data chunk other than fmt and the metadata chunks are skipped. It returns an
+// *UnsupportedCodecError if the samples cannot be decoded.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a RIFF/WAVE file", ErrInvalidFile)
+	}
+	d := &Decoder{r: r, factFrames: -1, metadata: Metadata{}}
+	haveFmt := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("%w: no data chunk", ErrInvalidFile)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "data":
+			if !haveFmt {
+				return nil, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalidFile)
+			}
+			d.dataBytes, d.remaining = size, size
+			if size == unknownSize {
+				// Streaming header: the data extends to the end of the input.
+				d.dataBytes, d.remaining = -1, -1
+			}
+			d.data = readerFunc(d.readData)
+			if d.law != 0 {
+				d.data, _ = g711.NewDecoder(d.data, d.law)
+			}
+			if d.imaBlock != 0 {
+				d.data = newIMADecoder(d.data, d.numChannels, d.imaBlock, d.factFrames)
+			}
+			return d, nil
+		case "fmt ":
+			if err := d.readFmt(size); err != nil {
+				return nil, err
+			}
+			haveFmt = true
+		case "fact":
+			// The number of frames, which compressed formats need to drop the padding of the
+			// last block.
+			var fact [4]byte
+			n := min(size, int64(len(fact)))
+			if _, err := io.ReadFull(r, fact[:n]); err != nil {
+				return nil, fmt.Errorf("%w: truncated %q chunk", ErrInvalidFile, id)
+			}
+			if n == int64(len(fact)) {
+				d.factFrames = int64(binary.LittleEndian.Uint32(fact[:]))
+			}
+			if _, err := io.CopyN(io.Discard, r, size+size%2-n); err != nil {
+				return nil, fmt.Errorf("%w: truncated %q chunk", ErrInvalidFile, id)
+			}
+		default:
+			skip := size + size%2
+			if IsMetadataChunk(id) {
+				if err := d.readMetadataChunk(id, size); err != nil {
+					return nil, err
+				}
+				skip = size % 2
+			}
+			if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+				return nil, fmt.Errorf("%w: truncated %q chunk", ErrInvalidFile, id)
+			}
+		}
+	}
+}
+
+// readMetadataChunk reads the contents of the metadata chunk id of size bytes into the metadata.
+// Memory is allocated as the contents are read, so a size beyond the end of the input does not
+// allocate more than the input holds.
+func (d *Decoder) readMetadataChunk(id string, size int64) error {
+	data, err := io.ReadAll(io.LimitReader(d.r, size))
+	if err != nil || int64(len(data)) < size {
+		return fmt.Errorf("%w: truncated %q chunk", ErrInvalidFile, id)
+	}
+	d.metadata.AddChunk(id, data)
+	return nil
+}
+
+// ReadTrailingMetadata reads the chunks after the data chunk, once Read has returned io.EOF, and
+// adds the metadata among them to Metadata. Chunks there are optional, so a truncated or malformed
+// tail is ignored. It does nothing before the end of the data or for a streaming header, whose
+// data extends to the end of the input.
+func (d *Decoder) ReadTrailingMetadata() {
+	if d.remaining != 0 || d.trailerRead {
+		return
+	}
+	d.trailerRead = true
+	if _, err := io.CopyN(io.Discard, d.r, d.dataBytes%2); err != nil {
+		return
+	}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(d.r, chunk[:]); err != nil {
+			return
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		skip := size + size%2
+		if IsMetadataChunk(id) {
+			if d.readMetadataChunk(id, size) != nil {
+				return
+			}
+			skip = size % 2
+		}
+		if _, err := io.CopyN(io.Discard, d.r, skip); err != nil {
+			return
+		}
+	}
+}
+
+// readFmt reads the contents of the fmt chunk of size bytes.
+func (d *Decoder) readFmt(size int64) error {
+	// WAVEFORMATEXTENSIBLE holds the actual format tag in the first two bytes of the sub-format
+	// GUID, at offset 24.
+	var chunk [26]byte
+	if size < 16 {
+		return fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidFile, size)
+	}
+	n := min(size, int64(len(chunk)))
+	if _, err := io.ReadFull(d.r, chunk[:n]); err != nil {
+		return fmt.Errorf("%w: truncated fmt chunk", ErrInvalidFile)
+	}
+	if _, err := io.CopyN(io.Discard, d.r, size+size%2-n); err != nil {
+		return fmt.Errorf("%w: truncated fmt chunk", ErrInvalidFile)
+	}
+	formatTag := int(binary.LittleEndian.Uint16(chunk[0:2]))
+	d.numChannels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+	d.sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+	blockAlign := int(binary.LittleEndian.Uint16(chunk[12:14]))
+	bitsPerSample := int(binary.LittleEndian.Uint16(chunk[14:16]))
+	if formatTag == FormatTagExtensible && n == int64(len(chunk)) {
+		formatTag = int(binary.LittleEndian.Uint16(chunk[24:26]))
+	}
+	switch {
+	case formatTag == FormatTagPCM && bitsPerSample == 16:
+		d.format = FormatPCM
+	case formatTag == FormatTagIEEEFloat && bitsPerSample == 32:
+		d.format = FormatIEEEFloat
+	case formatTag == FormatTagPCM && bitsPerSample == 8:
+		d.format = FormatU8
+	case formatTag == FormatTagPCM && bitsPerSample == 24:
+		d.format = FormatPCM24
+	case formatTag == FormatTagPCM && bitsPerSample == 32:
+		d.format = FormatPCM32
+	case formatTag == FormatTagIEEEFloat && bitsPerSample == 64:
+		d.format = FormatIEEEFloat64
+	case formatTag == FormatTagALaw && bitsPerSample == 8:
+		d.format, d.law = FormatPCM, g711.ALaw
+	case formatTag == FormatTagMuLaw && bitsPerSample == 8:
+		d.format, d.law = FormatPCM, g711.MuLaw
+	case formatTag == FormatTagIMAADPCM && bitsPerSample == 4:
+		d.format, d.imaBlock = FormatPCM, blockAlign
+	default:
+		return &UnsupportedCodecError{FormatTag: formatTag, BitsPerSample: bitsPerSample}
+	}
+	if d.numChannels < 1 || d.sampleRate < 1 {
+		return fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidFile, d.numChannels, d.sampleRate)
+	}
+	if formatTag == FormatTagIMAADPCM && imaBlockFrames(d.imaBlock, d.numChannels) == 0 {
+		return fmt.Errorf("%w: IMA ADPCM block of %d bytes for %d channels", ErrInvalidFile, d.imaBlock, d.numChannels)
+	}
+	return nil
+}
+
+// SampleRate returns the sample rate of the file.
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// NumChannels returns the number of channels of the file.
+func (d *Decoder) NumChannels() int {
+	return d.numChannels
+}
+
+// Format returns the sample format of the samples returned by Read. It is FormatPCM for G.711
+// files, see Law, and for IMA ADPCM files.
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Metadata returns the metadata chunks before the data chunk, and those after it once
+// ReadTrailingMetadata has been called.
+func (d *Decoder) Metadata() Metadata {
+	return maps.Clone(d.metadata)
+}
+
+// Law returns the companding law of a G.711 file, whose samples Read decodes to 16-bit PCM, or 0
+// for a file of linear samples.
+func (d *Decoder) Law() g711.Law {
+	return d.law
+}
+
+// FrameSize returns the size of one frame, a sample of every channel, in bytes.
+func (d *Decoder) FrameSize() int {
+	return d.numChannels * d.format.SampleSize()
+}
+
+// DataBytes returns the size of the sample data given in the header, or -1 if the header was
+// written by a streaming encoder and the data extends to the end of the input. For G.711 and IMA
+// ADPCM files, it is the size after decoding, e.g. twice that of the data chunk for G.711.
+func (d *Decoder) DataBytes() int64 {
+	switch {
+	case d.dataBytes < 0:
+		return d.dataBytes
+	case d.law != 0:
+		return 2 * d.dataBytes
+	case d.imaBlock != 0:
+		return imaDecodedBytes(d.dataBytes, d.numChannels, d.imaBlock, d.factFrames)
+	default:
+		return d.dataBytes
+	}
+}
+
+// Read reads the interleaved little-endian samples of the data chunk, in the layout expected by
+// sonic.Transformer. It returns io.EOF at the end of the data chunk. If the input ends before the
+// size given in the header, as in files whose encoder was not closed, Read returns io.EOF there
+// as well. The codes of G.711 and IMA ADPCM files are decoded to 16-bit samples; the output of IMA
+// ADPCM files is limited to the number of frames given by their fact chunk, if any.
+func (d *Decoder) Read(p []byte) (int, error) {
+	return d.data.Read(p)
+}
+
+// readData reads the bytes of the data chunk.
+func (d *Decoder) readData(p []byte) (int, error) {
+	if d.remaining == 0 {
+		return 0, io.EOF
+	}
+	if d.remaining > 0 && int64(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	if d.remaining > 0 {
+		d.remaining -= int64(n)
+	}
+	return n, err
+}
+
+// readerFunc is an io.Reader that calls itself.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// Encoder writes sample data to a WAVE file.
+//
+// The header is written before the first sample. If the output can be seeked, such as an *os.File
+// of a regular file, Close seeks back to fill in the sizes of the header. Otherwise, the header
+// gives unknown sizes, as streaming encoders do, which Decoder and most players read to the end of
+// the file.
+type Encoder struct {
+	w           io.Writer
+	sampleRate  int
+	numChannels int
+	format      Format
+	dataBytes   int64 // Bytes of sample data written
+	start       int64 // Offset of the header in the output, or -1 if it cannot be seeked
+	err         error // First write error, returned by every later call
+	closed      bool
+	metadata    Metadata
+	trailer     []byte // Metadata chunks written after the data by Close
+}
+
+// NewEncoder writes the header of a WAVE file with numChannels channels of format at sampleRate
+// to w, and returns an Encoder for its sample data. It returns ErrUnsupported for an unknown
+// format or a sample rate or number of channels that a WAVE header cannot hold.
+func NewEncoder(w io.Writer, sampleRate, numChannels int, format Format) (*Encoder, error) {
+	if format.SampleSize() == 0 {
+		return nil, fmt.Errorf("%w: format %d", ErrUnsupported, int(format))
+	}
+	if sampleRate < 1 || numChannels < 1 || numChannels > 0xFFFF || int64(sampleRate)*int64(numChannels*format.SampleSize()) > 0xFFFFFFFF {
+		return nil, fmt.Errorf("%w: %d channels at %d Hz", ErrUnsupported, numChannels, sampleRate)
+	}
+	e := &Encoder{w: w, sampleRate: sampleRate, numChannels: numChannels, format: format, start: -1}
+	size := int64(unknownSize)
+	// Pipes are files as well, so try to seek to find out whether the header can be updated.
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			e.start, size = start, 0
+		}
+	}
+	if _, err := w.Write(e.header(size)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// header returns the header of the file with dataBytes of sample data, or with unknown sizes if
+// dataBytes is unknownSize.
+func (e *Encoder) header(dataBytes int64) []byte {
+	blockAlign := e.numChannels * e.format.SampleSize()
+	riffBytes := int64(unknownSize)
+	if dataBytes != unknownSize {
+		riffBytes = headerSize - 8 + dataBytes + dataBytes%2 + int64(len(e.trailer))
+	}
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(riffBytes))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, uint16(e.format.formatTag()))
+	b = binary.LittleEndian.AppendUint16(b, uint16(e.numChannels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(e.sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(e.sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(8*e.format.SampleSize()))
+	b = append(b, "data"...)
+	return binary.LittleEndian.AppendUint32(b, uint32(dataBytes))
+}
+
+// Write writes interleaved little-endian samples to the data chunk. An Encoder is an io.Writer,
+// so it can be the output of a sonic.Transformer.
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, ErrClosed
+	}
+	if e.dataBytes+int64(len(p)) > unknownSize-headerSize {
+		e.err = fmt.Errorf("%w: more than 4 GiB of data", ErrUnsupported)
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.dataBytes += int64(n)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// SetMetadata sets the metadata chunks written after the data by Close, e.g. to pass through the
+// metadata of a Decoder. They are only written if the output can be seeked, since readers take
+// everything after a streaming header as sample data.
+func (e *Encoder) SetMetadata(m Metadata) {
+	e.metadata = maps.Clone(m)
+}
+
+// Close completes the file: it pads the data chunk to an even size and, if the output can be
+// seeked, writes the metadata chunks, fills in the sizes of the header and seeks back to the end.
+// It does not close the output.
+func (e *Encoder) Close() error {
+	if e.err != nil || e.closed {
+		return e.err
+	}
+	e.closed = true
+	if e.dataBytes%2 != 0 {
+		if _, err := e.w.Write([]byte{0}); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	if e.start < 0 {
+		return nil
+	}
+	if len(e.metadata) > 0 {
+		e.trailer = e.metadata.AppendChunks(nil)
+		if _, err := e.w.Write(e.trailer); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	ws := e.w.(io.WriteSeeker)
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = ws.Seek(e.start, io.SeekStart)
+	}
+	if err == nil {
+		_, err = ws.Write(e.header(e.dataBytes))
+	}
+	if err == nil {
+		_, err = ws.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
+
+// DataBytes returns the number of bytes of sample data written so far.
+func (e *Encoder) DataBytes() int64 {
+	return e.dataBytes
+}
@@ -0,0 +1,645 @@
+package wav_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nakat-t/sonic-go"
+	"github.com/nakat-t/sonic-go/internal/wavtest"
+	"github.com/nakat-t/sonic-go/pcm"
+	"github.com/nakat-t/sonic-go/wav"
+)
+
+// fmtChunk returns a fmt chunk of formatTag, followed by extra bytes.
+func fmtChunk(formatTag, numChannels, sampleRate, bitsPerSample int, extra []byte) []byte {
+	blockAlign := numChannels * bitsPerSample / 8
+	b := []byte("fmt ")
+	b = binary.LittleEndian.AppendUint32(b, uint32(16+len(extra)))
+	b = binary.LittleEndian.AppendUint16(b, uint16(formatTag))
+	b = binary.LittleEndian.AppendUint16(b, uint16(numChannels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bitsPerSample))
+	b = append(b, extra...)
+	if len(extra)%2 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// chunk returns a chunk with id and data.
+func chunk(id string, data []byte) []byte {
+	b := []byte(id)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// riff returns a RIFF/WAVE file of chunks.
+func riff(chunks ...[]byte) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WAVE")
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+	return b
+}
+
+// extensible returns the extension of a WAVE_FORMAT_EXTENSIBLE fmt chunk for formatTag.
+func extensible(formatTag int) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, 22)
+	b = binary.LittleEndian.AppendUint16(b, 16) // Valid bits per sample
+	b = binary.LittleEndian.AppendUint32(b, 0)  // Channel mask
+	b = binary.LittleEndian.AppendUint16(b, uint16(formatTag))
+	return append(b, "\x00\x00\x00\x00\x10\x00\x80\x00\x00\xAA\x00\x38\x9B\x71"...)
+}
+
+// imaFmtChunk returns a fmt chunk of 4-bit IMA ADPCM with blocks of blockAlign bytes.
+func imaFmtChunk(numChannels, sampleRate, blockAlign int) []byte {
+	samplesPerBlock := (blockAlign-4*numChannels)*2/numChannels + 1
+	b := fmtChunk(0x11, numChannels, sampleRate, 4, binary.LittleEndian.AppendUint16([]byte{2, 0}, uint16(samplesPerBlock)))
+	binary.LittleEndian.PutUint16(b[20:22], uint16(blockAlign))
+	return b
+}
+
+func TestDecoder(t *testing.T) {
+	data := pcm.EncodeInt16(nil, []int16{1, -1, 2, -2, 3, -3})
+	// A block of IMA ADPCM starting at 100 with step index 0, followed by a block with only a header
+	ima := []byte{100, 0, 0, 0, 0x77, 0xF0, 0x08, 0x80, 100, 0, 0, 0}
+	imaDecoded := pcm.EncodeInt16(nil, []int16{100, 111, 141, 145, 89, 81, 88, 94, 88, 100})
+	// A stereo block starting at -200 with step index 10, and at 32760 with step index 88
+	imaStereo := []byte{0x38, 0xFF, 10, 0, 0xF8, 0x7F, 88, 0, 0x12, 0x34, 0x56, 0x78, 0x77, 0x77, 0xFF, 0x0F}
+	imaStereoDecoded := pcm.EncodeInt16(nil, []int16{
+		-200, 32760, -189, 32767, -183, 32767, -165, 32767, -150, 32767,
+		-123, -28669, -82, -32768, -87, -32768, -11, -28673,
+	})
+	tests := []struct {
+		name          string
+		file          []byte
+		format        wav.Format
+		numChannels   int
+		sampleRate    int
+		wantDataBytes int64
+		want          []byte
+	}{
+		{"PCM", riff(fmtChunk(1, 2, 44100, 16, nil), chunk("data", data)), wav.FormatPCM, 2, 44100, 12, data},
+		{"float", riff(fmtChunk(3, 1, 8000, 32, nil), chunk("data", data[:8])), wav.FormatIEEEFloat, 1, 8000, 8, data[:8]},
+		{"U8", riff(fmtChunk(1, 1, 8000, 8, nil), chunk("data", data[:3])), wav.FormatU8, 1, 8000, 3, data[:3]},
+		{"PCM24", riff(fmtChunk(1, 1, 8000, 24, nil), chunk("data", data[:9])), wav.FormatPCM24, 1, 8000, 9, data[:9]},
+		{"PCM32", riff(fmtChunk(1, 1, 8000, 32, nil), chunk("data", data[:8])), wav.FormatPCM32, 1, 8000, 8, data[:8]},
+		{"float64", riff(fmtChunk(3, 1, 8000, 64, nil), chunk("data", data[:8])), wav.FormatIEEEFloat64, 1, 8000, 8, data[:8]},
+		{"extensible float64", riff(fmtChunk(0xFFFE, 1, 8000, 64, extensible(3)), chunk("data", data[:8])), wav.FormatIEEEFloat64, 1, 8000, 8, data[:8]},
+		{"extensible", riff(fmtChunk(0xFFFE, 2, 48000, 16, extensible(1)), chunk("data", data)), wav.FormatPCM, 2, 48000, 12, data},
+		{"fmt with extension", riff(fmtChunk(3, 1, 8000, 32, []byte{0, 0}), chunk("data", data[:4])), wav.FormatIEEEFloat, 1, 8000, 4, data[:4]},
+		{"other chunks", riff(chunk("LIST", []byte("INFOx")), fmtChunk(1, 1, 16000, 16, nil), chunk("fact", []byte{6, 0, 0, 0}), chunk("data", data), chunk("cue ", []byte{0, 0, 0, 0})), wav.FormatPCM, 1, 16000, 12, data},
+		{"streaming header", append(riff(fmtChunk(1, 1, 8000, 16, nil)), append([]byte("data\xFF\xFF\xFF\xFF"), data...)...), wav.FormatPCM, 1, 8000, -1, data},
+		{"mu-law", riff(fmtChunk(7, 1, 8000, 8, nil), chunk("data", []byte{0xFF, 0x7F, 0x00})), wav.FormatPCM, 1, 8000, 6, pcm.EncodeInt16(nil, []int16{0, 0, -32124})},
+		{"A-law", riff(fmtChunk(6, 2, 8000, 8, nil), chunk("data", []byte{0xD5, 0x55})), wav.FormatPCM, 2, 8000, 4, pcm.EncodeInt16(nil, []int16{8, -8})},
+		{"extensible mu-law", riff(fmtChunk(0xFFFE, 1, 8000, 8, extensible(7)), chunk("data", []byte{0x80})), wav.FormatPCM, 1, 8000, 2, pcm.EncodeInt16(nil, []int16{32124})},
+		{"truncated data", riff(fmtChunk(1, 1, 8000, 16, nil), chunk("data", data))[:44+4], wav.FormatPCM, 1, 8000, 12, data[:4]},
+		{"IMA ADPCM", riff(imaFmtChunk(1, 8000, 8), chunk("data", ima)), wav.FormatPCM, 1, 8000, 20, imaDecoded},
+		{"IMA ADPCM with fact", riff(imaFmtChunk(1, 8000, 8), chunk("fact", []byte{5, 0, 0, 0}), chunk("data", ima)), wav.FormatPCM, 1, 8000, 10, imaDecoded[:10]},
+		{"IMA ADPCM stereo", riff(imaFmtChunk(2, 22050, 16), chunk("data", imaStereo)), wav.FormatPCM, 2, 22050, 36, imaStereoDecoded},
+		{"IMA ADPCM streaming header", append(riff(imaFmtChunk(1, 8000, 8)), append([]byte("data\xFF\xFF\xFF\xFF"), ima...)...), wav.FormatPCM, 1, 8000, -1, imaDecoded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := wav.NewDecoder(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("NewDecoder() error = %v", err)
+			}
+			if d.Format() != tt.format || d.NumChannels() != tt.numChannels || d.SampleRate() != tt.sampleRate {
+				t.Errorf("NewDecoder() = %v, %d channels at %d Hz, want %v, %d channels at %d Hz", d.Format(), d.NumChannels(), d.SampleRate(), tt.format, tt.numChannels, tt.sampleRate)
+			}
+			if got, want := d.FrameSize(), tt.numChannels*tt.format.SampleSize(); got != want {
+				t.Errorf("FrameSize() = %d, want %d", got, want)
+			}
+			if d.DataBytes() != tt.wantDataBytes {
+				t.Errorf("DataBytes() = %d, want %d", d.DataBytes(), tt.wantDataBytes)
+			}
+			got, err := io.ReadAll(d)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDecoder_Errors(t *testing.T) {
+	data := chunk("data", make([]byte, 4))
+	tests := []struct {
+		name    string
+		file    []byte
+		wantErr error
+	}{
+		{"empty", nil, wav.ErrInvalidFile},
+		{"not WAVE", []byte("RIFF\x04\x00\x00\x00AVI "), wav.ErrInvalidFile},
+		{"no data chunk", riff(fmtChunk(1, 1, 8000, 16, nil)), wav.ErrInvalidFile},
+		{"data before fmt", riff(data, fmtChunk(1, 1, 8000, 16, nil)), wav.ErrInvalidFile},
+		{"short fmt", riff(chunk("fmt ", make([]byte, 14)), data), wav.ErrInvalidFile},
+		{"truncated fmt", riff(fmtChunk(1, 1, 8000, 16, nil))[:30], wav.ErrInvalidFile},
+		{"truncated chunk", riff(chunk("LIST", make([]byte, 100)))[:40], wav.ErrInvalidFile},
+		{"no channels", riff(fmtChunk(1, 0, 8000, 16, nil), data), wav.ErrInvalidFile},
+		{"20-bit PCM", riff(fmtChunk(1, 1, 8000, 20, nil), data), wav.ErrUnsupported},
+		{"16-bit float", riff(fmtChunk(3, 1, 8000, 16, nil), data), wav.ErrUnsupported},
+		{"16-bit mu-law", riff(fmtChunk(7, 1, 8000, 16, nil), data), wav.ErrUnsupported},
+		{"MS ADPCM", riff(fmtChunk(2, 1, 8000, 4, nil), data), wav.ErrUnsupported},
+		{"8-bit IMA ADPCM", riff(fmtChunk(0x11, 1, 8000, 8, nil), data), wav.ErrUnsupported},
+		{"IMA ADPCM without a block", riff(fmtChunk(0x11, 1, 8000, 4, nil), data), wav.ErrInvalidFile},
+		{"IMA ADPCM block of partial groups", riff(imaFmtChunk(2, 8000, 10), data), wav.ErrInvalidFile},
+		{"extensible 20-bit", riff(fmtChunk(0xFFFE, 1, 8000, 20, extensible(1)), data), wav.ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := wav.NewDecoder(bytes.NewReader(tt.file)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewDecoder() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDecoder_UnsupportedCodec(t *testing.T) {
+	_, err := wav.NewDecoder(bytes.NewReader(riff(fmtChunk(wav.FormatTagADPCM, 1, 8000, 4, nil), chunk("data", nil))))
+	var codecErr *wav.UnsupportedCodecError
+	if !errors.As(err, &codecErr) {
+		t.Fatalf("NewDecoder() error = %v, want *UnsupportedCodecError", err)
+	}
+	if codecErr.FormatTag != wav.FormatTagADPCM || codecErr.BitsPerSample != 4 {
+		t.Errorf("UnsupportedCodecError = %+v, want format tag 0x0002, 4 bits", codecErr)
+	}
+	if want := "unsupported WAVE format: MS ADPCM, format tag 0x0002, 4 bits per sample"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDecoder_Metadata(t *testing.T) {
+	data := make([]byte, 6)
+	cue := []byte("\x01\x00\x00\x00cue point data")
+	file := riff(chunk("bext", []byte("origin")), fmtChunk(1, 1, 8000, 16, nil), chunk("LIST", []byte("INFOISFT\x03\x00\x00\x00abc")),
+		chunk("data", data), chunk("junk", []byte{1, 2, 3}), chunk("cue ", cue))
+	d, err := wav.NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	want := wav.Metadata{"bext": []byte("origin"), "LIST/INFO": []byte("ISFT\x03\x00\x00\x00abc")}
+	if got := d.Metadata(); !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("Metadata() = %q, want %q", got, want)
+	}
+
+	// The chunks after the data are only read at the end of the data.
+	d.ReadTrailingMetadata()
+	if got := d.Metadata(); !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("Metadata() before the end of the data = %q, want %q", got, want)
+	}
+	if got, _ := io.ReadAll(d); !bytes.Equal(got, data) {
+		t.Errorf("ReadAll() = %v, want %v", got, data)
+	}
+	d.ReadTrailingMetadata()
+	want["cue "] = cue
+	if got := d.Metadata(); !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("Metadata() after ReadTrailingMetadata() = %q, want %q", got, want)
+	}
+
+	// A truncated tail is ignored.
+	d, err = wav.NewDecoder(bytes.NewReader(file[:len(file)-4]))
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	io.ReadAll(d)
+	d.ReadTrailingMetadata()
+	if _, ok := d.Metadata()["cue "]; ok {
+		t.Error("Metadata() contains a truncated cue chunk")
+	}
+}
+
+func TestEncoder_Metadata(t *testing.T) {
+	m := wav.Metadata{"bext": []byte("odd"), "LIST/INFO": []byte("ISFT\x03\x00\x00\x00abc\x00")}
+	data := pcm.EncodeInt16(nil, []int16{1, 2, 3})
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	e, err := wav.NewEncoder(f, 8000, 1, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	e.SetMetadata(m)
+	e.Write(data)
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	file, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := binary.LittleEndian.Uint32(file[4:8]), uint32(len(file)-8); got != want {
+		t.Errorf("RIFF size = %d, want %d", got, want)
+	}
+	d, err := wav.NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	if got, _ := io.ReadAll(d); !bytes.Equal(got, data) {
+		t.Errorf("ReadAll() = %v, want %v", got, data)
+	}
+	d.ReadTrailingMetadata()
+	if got := d.Metadata(); !maps.EqualFunc(got, m, bytes.Equal) {
+		t.Errorf("Metadata() = %q, want %q", got, m)
+	}
+
+	// Nothing follows a streaming header, whose data extends to the end of the file.
+	var buf bytes.Buffer
+	e, err = wav.NewEncoder(&buf, 8000, 1, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	e.SetMetadata(m)
+	e.Write(data)
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got, want := buf.Len(), 44+len(data); got != want {
+		t.Errorf("streaming file has %d bytes, want %d", got, want)
+	}
+}
+
+func TestMetadata_Chunks(t *testing.T) {
+	m := wav.Metadata{}
+	m.AddChunk("bext", []byte("origin"))
+	m.AddChunk("LIST", []byte("INFOISFT\x03\x00\x00\x00abc"))
+	m.AddChunk("LIST", []byte("ab")) // No list type
+	want := wav.Metadata{"bext": []byte("origin"), "LIST/INFO": []byte("ISFT\x03\x00\x00\x00abc")}
+	if !maps.EqualFunc(m, want, bytes.Equal) {
+		t.Errorf("AddChunk() = %q, want %q", m, want)
+	}
+
+	got := m.AppendChunks([]byte("x"))
+	wantChunks := slices.Concat([]byte("x"), chunk("LIST", []byte("INFOISFT\x03\x00\x00\x00abc")), chunk("bext", []byte("origin")))
+	if !bytes.Equal(got, wantChunks) {
+		t.Errorf("AppendChunks() = %q, want %q", got, wantChunks)
+	}
+	for id, want := range map[string]bool{"LIST": true, "bext": true, "cue ": true, "fmt ": false, "junk": false} {
+		if got := wav.IsMetadataChunk(id); got != want {
+			t.Errorf("IsMetadataChunk(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestMetadata_ScaleSampleOffsets(t *testing.T) {
+	cuePoint := func(name, position, offset uint32) []byte {
+		b := make([]byte, 24)
+		binary.LittleEndian.PutUint32(b[0:4], name)
+		binary.LittleEndian.PutUint32(b[4:8], position)
+		copy(b[8:12], "data")
+		binary.LittleEndian.PutUint32(b[20:24], offset)
+		return b
+	}
+	cue := func(points ...[]byte) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, uint32(len(points)))
+		for _, p := range points {
+			b = append(b, p...)
+		}
+		return b
+	}
+	ltxt := func(name, length uint32) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, name)
+		b = binary.LittleEndian.AppendUint32(b, length)
+		b = append(b, "rgn \x00\x00\x00\x00\x00\x00\x00\x00"...)
+		return chunk("ltxt", b)
+	}
+	labl := chunk("labl", []byte("Mark\x00"))
+
+	tests := []struct {
+		name   string
+		m      wav.Metadata
+		factor float64
+		want   wav.Metadata
+	}{
+		{
+			name:   "cue points",
+			m:      wav.Metadata{"cue ": cue(cuePoint(1, 1000, 1000), cuePoint(2, 3, 3))},
+			factor: 0.5,
+			want:   wav.Metadata{"cue ": cue(cuePoint(1, 500, 500), cuePoint(2, 2, 2))},
+		},
+		{
+			name:   "adtl regions",
+			m:      wav.Metadata{"LIST/adtl": slices.Concat(labl, ltxt(1, 800))},
+			factor: 1.5,
+			want:   wav.Metadata{"LIST/adtl": slices.Concat(labl, ltxt(1, 1200))},
+		},
+		{
+			name:   "other chunks unchanged",
+			m:      wav.Metadata{"bext": {1, 2, 3, 4}, "LIST/INFO": {5, 6, 7, 8}},
+			factor: 2,
+			want:   wav.Metadata{"bext": {1, 2, 3, 4}, "LIST/INFO": {5, 6, 7, 8}},
+		},
+		{
+			name:   "truncated cue chunk",
+			m:      wav.Metadata{"cue ": cue(cuePoint(1, 1000, 1000))[:20]},
+			factor: 2,
+			want:   wav.Metadata{"cue ": cue(cuePoint(1, 1000, 1000))[:20]},
+		},
+		{
+			name:   "saturates",
+			m:      wav.Metadata{"cue ": cue(cuePoint(1, math.MaxUint32/2, 1))},
+			factor: 4,
+			want:   wav.Metadata{"cue ": cue(cuePoint(1, math.MaxUint32, 4))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := wav.Metadata{}
+			for id, data := range tt.m {
+				orig[id] = slices.Clone(data)
+			}
+			got := tt.m.ScaleSampleOffsets(tt.factor)
+			if !maps.EqualFunc(got, tt.want, bytes.Equal) {
+				t.Errorf("ScaleSampleOffsets(%v) = %v, want %v", tt.factor, got, tt.want)
+			}
+			if !maps.EqualFunc(tt.m, orig, bytes.Equal) {
+				t.Errorf("ScaleSampleOffsets modified its receiver")
+			}
+		})
+	}
+}
+
+// FuzzNewDecoder checks that hostile files make the Decoder fail cleanly instead of crashing,
+// hanging or allocating without bound.
+func FuzzNewDecoder(f *testing.F) {
+	for _, seed := range wavtest.Seeds() {
+		f.Add(seed)
+	}
+	ima := []byte{100, 0, 0, 0, 0x77, 0xF0, 0x08, 0x80, 100, 0, 0}
+	f.Add(riff(imaFmtChunk(1, 8000, 8), chunk("fact", []byte{5, 0, 0, 0}), chunk("data", ima)))
+	f.Add(riff(imaFmtChunk(2, 8000, 16), chunk("data", append(ima, ima...))))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := wav.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if d.NumChannels() < 1 || d.SampleRate() < 1 || d.FrameSize() < 1 {
+			t.Fatalf("NewDecoder() succeeded with %d channels at %d Hz, frame size %d", d.NumChannels(), d.SampleRate(), d.FrameSize())
+		}
+		samples, err := io.ReadAll(d)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		// G.711 codes decode to two bytes each, and IMA ADPCM codes to two bytes per nibble.
+		if len(samples) > 4*len(data) {
+			t.Fatalf("read %d bytes of samples from a %d byte file", len(samples), len(data))
+		}
+		d.ReadTrailingMetadata()
+		total := 0
+		for _, b := range d.Metadata() {
+			total += len(b)
+		}
+		if total > len(data) {
+			t.Fatalf("read %d bytes of metadata from a %d byte file", total, len(data))
+		}
+	})
+}
+
+func TestEncoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      wav.Format
+		numChannels int
+		data        []byte
+	}{
+		{"PCM", wav.FormatPCM, 2, pcm.EncodeInt16(nil, []int16{1, -1, 2, -2})},
+		{"float", wav.FormatIEEEFloat, 1, pcm.EncodeFloat32(nil, []float32{0.5, -0.5, 0.25})},
+		{"U8 odd", wav.FormatU8, 1, []byte{128, 0, 255}},
+		{"PCM24 odd", wav.FormatPCM24, 1, []byte{1, 2, 3, 0xFD, 0xFE, 0xFF, 0, 0, 0x80}},
+		{"PCM32", wav.FormatPCM32, 2, []byte{1, 2, 3, 4, 0xFC, 0xFD, 0xFE, 0xFF}},
+		{"float64", wav.FormatIEEEFloat64, 1, binary.LittleEndian.AppendUint64(nil, math.Float64bits(-0.25))},
+		{"empty", wav.FormatPCM, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(t *testing.T, file []byte, wantDataBytes int64) {
+				t.Helper()
+				if len(file)%2 != 0 {
+					t.Errorf("file has %d bytes, want an even size", len(file))
+				}
+				d, err := wav.NewDecoder(bytes.NewReader(file))
+				if err != nil {
+					t.Fatalf("NewDecoder() error = %v", err)
+				}
+				if d.Format() != tt.format || d.NumChannels() != tt.numChannels || d.SampleRate() != 22050 {
+					t.Errorf("header = %v, %d channels at %d Hz, want %v, %d channels at 22050 Hz", d.Format(), d.NumChannels(), d.SampleRate(), tt.format, tt.numChannels)
+				}
+				if d.DataBytes() != wantDataBytes {
+					t.Errorf("DataBytes() = %d, want %d", d.DataBytes(), wantDataBytes)
+				}
+				got, _ := io.ReadAll(d)
+				if wantDataBytes < 0 {
+					// Without sizes, the pad byte is read as data.
+					got = got[:min(len(got), len(tt.data))]
+				}
+				if !bytes.Equal(got, tt.data) {
+					t.Errorf("data = %v, want %v", got, tt.data)
+				}
+			}
+			encode := func(t *testing.T, w io.Writer) {
+				t.Helper()
+				e, err := wav.NewEncoder(w, 22050, tt.numChannels, tt.format)
+				if err != nil {
+					t.Fatalf("NewEncoder() error = %v", err)
+				}
+				if _, err := e.Write(tt.data); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if e.DataBytes() != int64(len(tt.data)) {
+					t.Errorf("DataBytes() = %d, want %d", e.DataBytes(), len(tt.data))
+				}
+				if err := e.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+				if err := e.Close(); err != nil {
+					t.Errorf("second Close() error = %v", err)
+				}
+				if _, err := e.Write(tt.data); !errors.Is(err, wav.ErrClosed) {
+					t.Errorf("Write() after Close() error = %v, want %v", err, wav.ErrClosed)
+				}
+			}
+
+			t.Run("stream", func(t *testing.T) {
+				var buf bytes.Buffer
+				encode(t, &buf)
+				check(t, buf.Bytes(), -1)
+			})
+			t.Run("file", func(t *testing.T) {
+				f, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				// The header is updated in place, after whatever precedes it.
+				if _, err := f.Write([]byte("prefix")); err != nil {
+					t.Fatal(err)
+				}
+				encode(t, f)
+				if _, err := f.Write([]byte("suffix")); err != nil {
+					t.Fatal(err)
+				}
+				file, err := os.ReadFile(f.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				file = file[len("prefix") : len(file)-len("suffix")]
+				if got, want := binary.LittleEndian.Uint32(file[4:8]), uint32(len(file)-8); got != want {
+					t.Errorf("RIFF size = %d, want %d", got, want)
+				}
+				check(t, file, int64(len(tt.data)))
+			})
+		})
+	}
+}
+
+func TestNewEncoder_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  int
+		numChannels int
+		format      wav.Format
+	}{
+		{"unknown format", 8000, 1, wav.Format(2)},
+		{"no channels", 8000, 0, wav.FormatPCM},
+		{"too many channels", 8000, 0x10000, wav.FormatPCM},
+		{"no sample rate", 0, 1, wav.FormatPCM},
+		{"byte rate overflow", 1 << 30, 2, wav.FormatIEEEFloat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := wav.NewEncoder(io.Discard, tt.sampleRate, tt.numChannels, tt.format); !errors.Is(err, wav.ErrUnsupported) {
+				t.Errorf("NewEncoder() error = %v, want %v", err, wav.ErrUnsupported)
+			}
+		})
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoder_WriteError(t *testing.T) {
+	if _, err := wav.NewEncoder(errWriter{}, 8000, 1, wav.FormatPCM); err == nil {
+		t.Errorf("NewEncoder() error = nil, want the error of the writer")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	// The values match sonic.AudioFormat, so that one converts into the other.
+	tests := []struct {
+		format wav.Format
+		want   sonic.AudioFormat
+	}{
+		{wav.FormatPCM, sonic.AudioFormatPCM},
+		{wav.FormatIEEEFloat, sonic.AudioFormatIEEEFloat},
+		{wav.FormatU8, sonic.AudioFormatU8},
+		{wav.FormatPCM24, sonic.AudioFormatPCM24},
+		{wav.FormatPCM32, sonic.AudioFormatPCM32},
+		{wav.FormatIEEEFloat64, sonic.AudioFormatIEEEFloat64},
+	}
+	for _, tt := range tests {
+		if got := sonic.AudioFormat(tt.format); got != tt.want || got.SampleSize() != tt.format.SampleSize() {
+			t.Errorf("sonic.AudioFormat(%v) = %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	// WAVE in, transformer, WAVE out.
+	samples := make([]int16, 2*8000)
+	for i := range samples {
+		samples[i] = int16(i%100*100 - 5000)
+	}
+	in := filepath.Join(t.TempDir(), "in.wav")
+	out := filepath.Join(t.TempDir(), "out.wav")
+
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := wav.NewEncoder(f, 8000, 2, wav.FormatPCM)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	e.Write(pcm.EncodeInt16(nil, samples))
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	f.Close()
+
+	r, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	d, err := wav.NewDecoder(r)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	w, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	e, err = wav.NewEncoder(w, d.SampleRate(), d.NumChannels(), wav.FormatIEEEFloat)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	tr, err := sonic.NewTransformer(e, d.SampleRate(), sonic.AudioFormat(d.Format()),
+		sonic.WithChannels(d.NumChannels()), sonic.WithSpeed(2), sonic.WithOutputFormat(sonic.AudioFormatIEEEFloat))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	if _, err := io.Copy(tr, d); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Transformer.Close() error = %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Encoder.Close() error = %v", err)
+	}
+
+	file, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = wav.NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("NewDecoder() of the output error = %v", err)
+	}
+	if d.Format() != wav.FormatIEEEFloat || d.NumChannels() != 2 || d.SampleRate() != 8000 {
+		t.Errorf("output = %v, %d channels at %d Hz, want IEEEFloat, 2 channels at 8000 Hz", d.Format(), d.NumChannels(), d.SampleRate())
+	}
+	// Half a second of stereo float at twice the speed.
+	if got, want := d.DataBytes(), int64(4000*2*4); got < want-1600 || want+1600 < got {
+		t.Errorf("DataBytes() = %d, want about %d", got, want)
+	}
+	if got := int64(len(file) - 44); got != d.DataBytes() {
+		t.Errorf("file has %d bytes of data, header says %d", got, d.DataBytes())
+	}
+}
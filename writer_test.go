@@ -0,0 +1,268 @@
+package sonic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nakat-t/sonic-go/audiotest"
+)
+
+// transformSpeech writes the speech sample to a new transformer writing to w and flushes it.
+func transformSpeech(t *testing.T, w io.Writer) {
+	t.Helper()
+	tr, err := NewTransformer(w, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(audiotest.SpeechPCM()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+}
+
+func TestTransformer_FlushGzipWriter(t *testing.T) {
+	var want bytes.Buffer
+	transformSpeech(t, &want)
+
+	// Without closing the gzip.Writer, everything written up to Flush must be decodable.
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	transformSpeech(t, zw)
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	got := make([]byte, want.Len())
+	if _, err := io.ReadFull(zr, got); err != nil {
+		t.Fatalf("reading flushed gzip stream: %v", err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("decompressed output differs from uncompressed output")
+	}
+}
+
+func TestTransformer_FlushCipherStreamWriter(t *testing.T) {
+	var want bytes.Buffer
+	transformSpeech(t, &want)
+
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatalf("aes.NewCipher() error = %v", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	var encrypted bytes.Buffer
+	transformSpeech(t, cipher.StreamWriter{S: cipher.NewCTR(block, iv), W: &encrypted})
+
+	got := make([]byte, encrypted.Len())
+	cipher.NewCTR(block, iv).XORKeyStream(got, encrypted.Bytes())
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("decrypted output differs from plain output")
+	}
+}
+
+type failingFlusher struct {
+	bytes.Buffer
+	err error
+}
+
+func (f *failingFlusher) Flush() error {
+	return f.err
+}
+
+func TestTransformer_FlushWriterError(t *testing.T) {
+	errFlush := errors.New("flush failed")
+	tr, err := NewTransformer(&failingFlusher{err: errFlush}, 44100, AudioFormatPCM)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	err = tr.Flush()
+	if !errors.Is(err, ErrWrite) || !errors.Is(err, errFlush) {
+		t.Errorf("Flush() error = %v, want wrapping %v and %v", err, ErrWrite, errFlush)
+	}
+}
+
+// shortWriter accepts at most max bytes per Write. It returns err once after writing
+// failAfter bytes in total, if err is set.
+type shortWriter struct {
+	bytes.Buffer
+	max       int
+	failAfter int
+	err       error
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.max)
+	if w.err != nil && w.Len()+n > w.failAfter {
+		n = w.failAfter - w.Len()
+		w.Buffer.Write(p[:n])
+		err := w.err
+		w.err = nil
+		return n, err
+	}
+	return w.Buffer.Write(p[:n])
+}
+
+func TestTransformer_ShortWrites(t *testing.T) {
+	var want bytes.Buffer
+	transformSpeech(t, &want)
+
+	t.Run("progress", func(t *testing.T) {
+		w := &shortWriter{max: 3}
+		transformSpeech(t, w)
+		if !bytes.Equal(w.Bytes(), want.Bytes()) {
+			t.Error("output with short writes differs from the plain output")
+		}
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		tr, err := NewTransformer(&shortWriter{max: 0}, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		_, err = tr.Write(audiotest.SpeechPCM())
+		if !errors.Is(err, ErrWrite) || !errors.Is(err, ErrShortOutput) {
+			t.Errorf("Write() error = %v, want %v and %v", err, ErrWrite, ErrShortOutput)
+		}
+	})
+
+	t.Run("torn frame", func(t *testing.T) {
+		errTorn := errors.New("torn")
+		w := &shortWriter{max: 1 << 30, failAfter: 1001, err: errTorn}
+		tr, err := NewTransformer(w, audiotest.SpeechSampleRate, AudioFormatPCM, WithSpeed(1.5))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		speech := audiotest.SpeechPCM()
+		_, err = tr.Write(speech[:len(speech)/2])
+		if !errors.Is(err, ErrShortOutput) || !errors.Is(err, errTorn) {
+			t.Fatalf("Write() error = %v, want %v and %v", err, ErrShortOutput, errTorn)
+		}
+		if err := tr.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		got := w.Bytes()
+		if len(got)%2 != 0 {
+			t.Errorf("output length = %d, want a multiple of the frame size", len(got))
+		}
+		if !bytes.Equal(got[:1002], want.Bytes()[:1002]) {
+			t.Error("torn frame was not completed")
+		}
+	})
+}
+
+func TestTransformer_SetWriter(t *testing.T) {
+	speech := audiotest.SpeechPCM()
+	half := len(speech) / 4 * 2
+	opts := []Option{WithSpeed(1.5), WithFixedLatency(50 * time.Millisecond), WithFadeOut(20 * time.Millisecond)}
+
+	// The output is the same as with a flush at the change, split at the flush.
+	var want bytes.Buffer
+	tr, err := NewTransformer(&want, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(speech[:half]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	split := want.Len()
+	if _, err := tr.Write(speech[half:]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	var first, second bytes.Buffer
+	tr, err = NewTransformer(&first, audiotest.SpeechSampleRate, AudioFormatPCM, opts...)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer tr.Close()
+	if _, err := tr.Write(speech[:half]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.SetWriter(&second); err != nil {
+		t.Fatalf("SetWriter() error = %v", err)
+	}
+	if _, err := tr.Write(speech[half:]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), want.Bytes()[:split]) {
+		t.Errorf("old writer got %d bytes, want the %d bytes before the flush", first.Len(), split)
+	}
+	if !bytes.Equal(second.Bytes(), want.Bytes()[split:]) {
+		t.Errorf("new writer got %d bytes, want the %d bytes after the flush", second.Len(), want.Len()-split)
+	}
+}
+
+func TestTransformer_SetWriterErrors(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		tr, err := NewTransformer(io.Discard, 44100, AudioFormatPCM)
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if err := tr.SetWriter(nil); !errors.Is(err, ErrInvalid) {
+			t.Errorf("SetWriter(nil) error = %v, want %v", err, ErrInvalid)
+		}
+		tr, err = NewTransformer(nil, 44100, AudioFormatPCM, WithOutputFunc(func([]byte) error { return nil }))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		if err := tr.SetWriter(io.Discard); !errors.Is(err, ErrInvalid) {
+			t.Errorf("SetWriter() with an output function error = %v, want %v", err, ErrInvalid)
+		}
+	})
+
+	t.Run("drain failure", func(t *testing.T) {
+		// The old writer fails in the middle of a frame during the drain. The writer is kept, and
+		// the next SetWriter completes the drain to it.
+		errWrite := errors.New("write failed")
+		old := &shortWriter{max: 1 << 20}
+		tr, err := NewTransformer(old, audiotest.SpeechSampleRate, AudioFormatPCM, WithChannels(2), WithFixedLatency(100*time.Millisecond))
+		if err != nil {
+			t.Fatalf("NewTransformer() error = %v", err)
+		}
+		defer tr.Close()
+		input := audiotest.SpeechPCM()[:4*audiotest.SpeechSampleRate/10]
+		if _, err := tr.Write(input); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		failAfter := old.Len() + 1001
+		old.failAfter, old.err = failAfter, errWrite
+		var next bytes.Buffer
+		if err := tr.SetWriter(&next); !errors.Is(err, errWrite) {
+			t.Fatalf("SetWriter() error = %v, want %v", err, errWrite)
+		}
+		if err := tr.SetWriter(&next); err != nil {
+			t.Fatalf("second SetWriter() error = %v", err)
+		}
+		if old.Len()%4 != 0 || old.Len() <= failAfter {
+			t.Errorf("old writer got %d bytes, want whole frames beyond the failure", old.Len())
+		}
+		if next.Len() != 0 {
+			t.Errorf("new writer got %d bytes before any further input", next.Len())
+		}
+	})
+}